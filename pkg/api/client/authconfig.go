@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+)
+
+// AuthConfigInterface ...
+type AuthConfigInterface interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*v1alpha1.AuthConfigList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	UpdateStatus(ctx context.Context, authConfig *v1alpha1.AuthConfig, opts metav1.UpdateOptions) (*v1alpha1.AuthConfig, error)
+}
+
+type authConfigs struct {
+	client rest.Interface
+	ns     string
+}
+
+func (c *authConfigs) List(ctx context.Context, opts metav1.ListOptions) (result *v1alpha1.AuthConfigList, err error) {
+	result = &v1alpha1.AuthConfigList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("authconfigs").
+		VersionedParams(&opts, parameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+func (c *authConfigs) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("authconfigs").
+		VersionedParams(&opts, parameterCodec).
+		Watch(ctx)
+}
+
+func (c *authConfigs) UpdateStatus(ctx context.Context, authConfig *v1alpha1.AuthConfig, opts metav1.UpdateOptions) (result *v1alpha1.AuthConfig, err error) {
+	result = &v1alpha1.AuthConfig{}
+	err = c.client.Put().
+		Namespace(authConfig.Namespace).
+		Resource("authconfigs").
+		Name(authConfig.Name).
+		SubResource("status").
+		VersionedParams(&opts, parameterCodec).
+		Body(authConfig).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// NewAuthConfigInformer creates a shared index informer of the AuthConfig
+// resources. An empty namespace watches the whole cluster.
+func NewAuthConfigInformer(client Interface, namespace string, resync time.Duration) cache.SharedIndexInformer {
+	return NewFilteredAuthConfigInformer(client, namespace, resync, nil)
+}
+
+// NewFilteredAuthConfigInformer creates a shared index informer of the AuthConfig
+// resources, tweakListOptions changes the list options, e.g. label and field
+// selectors, of the list and watch requests.
+func NewFilteredAuthConfigInformer(client Interface, namespace string, resync time.Duration, tweakListOptions func(*metav1.ListOptions)) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.HAProxyIngressV1alpha1().AuthConfigs(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.HAProxyIngressV1alpha1().AuthConfigs(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&v1alpha1.AuthConfig{},
+		resync,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+}
+
+// AuthConfigLister ...
+type AuthConfigLister interface {
+	List(selector labels.Selector) ([]*v1alpha1.AuthConfig, error)
+	Get(namespace, name string) (*v1alpha1.AuthConfig, error)
+}
+
+// NewAuthConfigLister creates a lister of the AuthConfig resources
+// stored in the indexer of an informer
+func NewAuthConfigLister(indexer cache.Indexer) AuthConfigLister {
+	return &authConfigLister{indexer: indexer}
+}
+
+type authConfigLister struct {
+	indexer cache.Indexer
+}
+
+func (l *authConfigLister) List(selector labels.Selector) (ret []*v1alpha1.AuthConfig, err error) {
+	err = cache.ListAll(l.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.AuthConfig))
+	})
+	return ret, err
+}
+
+func (l *authConfigLister) Get(namespace, name string) (*v1alpha1.AuthConfig, error) {
+	obj, exists, err := l.indexer.GetByKey(namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("authconfig"), name)
+	}
+	return obj.(*v1alpha1.AuthConfig), nil
+}
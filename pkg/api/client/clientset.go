@@ -0,0 +1,169 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package client implements a typed client, informers and listers of the
+// HAProxy Ingress custom resources and of the Multi-Cluster Services
+// ServiceImport resource. It follows the API of the code
+// generated by client-gen, informer-gen and lister-gen, restricted to
+// the operations the controller needs.
+package client
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/rest"
+
+	mcsv1alpha1 "github.com/jcmoraisjr/haproxy-ingress/pkg/api/mcs/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+)
+
+var (
+	scheme         = runtime.NewScheme()
+	codecs         = serializer.NewCodecFactory(scheme)
+	parameterCodec = runtime.NewParameterCodec(scheme)
+)
+
+func init() {
+	metav1.AddToGroupVersion(scheme, schema.GroupVersion{Version: "v1"})
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+	utilruntime.Must(mcsv1alpha1.AddToScheme(scheme))
+}
+
+// Interface ...
+type Interface interface {
+	HAProxyIngressV1alpha1() HAProxyIngressV1alpha1Interface
+	MulticlusterV1alpha1() MulticlusterV1alpha1Interface
+}
+
+// HAProxyIngressV1alpha1Interface ...
+type HAProxyIngressV1alpha1Interface interface {
+	AuthConfigs(namespace string) AuthConfigInterface
+	Backends(namespace string) BackendInterface
+	Globals() GlobalInterface
+	Hosts(namespace string) HostInterface
+	IngressClassParameters() IngressClassParametersInterface
+	IPLists() IPListInterface
+	Middlewares(namespace string) MiddlewareInterface
+	RateLimits() RateLimitInterface
+	TCPServices(namespace string) TCPServiceInterface
+	UDPServices(namespace string) UDPServiceInterface
+}
+
+// MulticlusterV1alpha1Interface ...
+type MulticlusterV1alpha1Interface interface {
+	ServiceImports(namespace string) ServiceImportInterface
+}
+
+// Clientset ...
+type Clientset struct {
+	v1alpha1    *v1alpha1Client
+	mcsv1alpha1 *mcsv1alpha1Client
+}
+
+// NewForConfig creates a new Clientset for the given config. The custom
+// resources are only served as JSON, the content type of the config is
+// overwritten.
+func NewForConfig(c *rest.Config) (*Clientset, error) {
+	restClient, err := newRESTClient(c, v1alpha1.SchemeGroupVersion)
+	if err != nil {
+		return nil, err
+	}
+	mcsRESTClient, err := newRESTClient(c, mcsv1alpha1.SchemeGroupVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &Clientset{
+		v1alpha1:    &v1alpha1Client{restClient: restClient},
+		mcsv1alpha1: &mcsv1alpha1Client{restClient: mcsRESTClient},
+	}, nil
+}
+
+func newRESTClient(c *rest.Config, gv schema.GroupVersion) (*rest.RESTClient, error) {
+	config := *c
+	config.GroupVersion = &gv
+	config.APIPath = "/apis"
+	config.ContentType = runtime.ContentTypeJSON
+	config.NegotiatedSerializer = codecs.WithoutConversion()
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+	return rest.RESTClientFor(&config)
+}
+
+// HAProxyIngressV1alpha1 ...
+func (c *Clientset) HAProxyIngressV1alpha1() HAProxyIngressV1alpha1Interface {
+	return c.v1alpha1
+}
+
+// MulticlusterV1alpha1 ...
+func (c *Clientset) MulticlusterV1alpha1() MulticlusterV1alpha1Interface {
+	return c.mcsv1alpha1
+}
+
+type v1alpha1Client struct {
+	restClient rest.Interface
+}
+
+func (c *v1alpha1Client) AuthConfigs(namespace string) AuthConfigInterface {
+	return &authConfigs{client: c.restClient, ns: namespace}
+}
+
+func (c *v1alpha1Client) Backends(namespace string) BackendInterface {
+	return &backends{client: c.restClient, ns: namespace}
+}
+
+func (c *v1alpha1Client) Globals() GlobalInterface {
+	return &globals{client: c.restClient}
+}
+
+func (c *v1alpha1Client) Hosts(namespace string) HostInterface {
+	return &hosts{client: c.restClient, ns: namespace}
+}
+
+func (c *v1alpha1Client) IngressClassParameters() IngressClassParametersInterface {
+	return &ingressClassParameters{client: c.restClient}
+}
+
+func (c *v1alpha1Client) IPLists() IPListInterface {
+	return &ipLists{client: c.restClient}
+}
+
+func (c *v1alpha1Client) Middlewares(namespace string) MiddlewareInterface {
+	return &middlewares{client: c.restClient, ns: namespace}
+}
+
+func (c *v1alpha1Client) RateLimits() RateLimitInterface {
+	return &rateLimits{client: c.restClient}
+}
+
+func (c *v1alpha1Client) TCPServices(namespace string) TCPServiceInterface {
+	return &tcpServices{client: c.restClient, ns: namespace}
+}
+
+func (c *v1alpha1Client) UDPServices(namespace string) UDPServiceInterface {
+	return &udpServices{client: c.restClient, ns: namespace}
+}
+
+type mcsv1alpha1Client struct {
+	restClient rest.Interface
+}
+
+func (c *mcsv1alpha1Client) ServiceImports(namespace string) ServiceImportInterface {
+	return &serviceImports{client: c.restClient, ns: namespace}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+)
+
+// GlobalInterface ...
+type GlobalInterface interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*v1alpha1.GlobalList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	UpdateStatus(ctx context.Context, global *v1alpha1.Global, opts metav1.UpdateOptions) (*v1alpha1.Global, error)
+}
+
+type globals struct {
+	client rest.Interface
+}
+
+func (c *globals) List(ctx context.Context, opts metav1.ListOptions) (result *v1alpha1.GlobalList, err error) {
+	result = &v1alpha1.GlobalList{}
+	err = c.client.Get().
+		Resource("globals").
+		VersionedParams(&opts, parameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+func (c *globals) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Resource("globals").
+		VersionedParams(&opts, parameterCodec).
+		Watch(ctx)
+}
+
+func (c *globals) UpdateStatus(ctx context.Context, global *v1alpha1.Global, opts metav1.UpdateOptions) (result *v1alpha1.Global, err error) {
+	result = &v1alpha1.Global{}
+	err = c.client.Put().
+		Resource("globals").
+		Name(global.Name).
+		SubResource("status").
+		VersionedParams(&opts, parameterCodec).
+		Body(global).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// NewGlobalInformer creates a shared index informer of the Global resources,
+// which are cluster scoped.
+func NewGlobalInformer(client Interface, resync time.Duration) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.HAProxyIngressV1alpha1().Globals().List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.HAProxyIngressV1alpha1().Globals().Watch(context.TODO(), options)
+			},
+		},
+		&v1alpha1.Global{},
+		resync,
+		cache.Indexers{},
+	)
+}
+
+// GlobalLister ...
+type GlobalLister interface {
+	List(selector labels.Selector) ([]*v1alpha1.Global, error)
+	Get(name string) (*v1alpha1.Global, error)
+}
+
+// NewGlobalLister creates a lister of the Global resources stored in the
+// indexer of an informer
+func NewGlobalLister(indexer cache.Indexer) GlobalLister {
+	return &globalLister{indexer: indexer}
+}
+
+type globalLister struct {
+	indexer cache.Indexer
+}
+
+func (l *globalLister) List(selector labels.Selector) (ret []*v1alpha1.Global, err error) {
+	err = cache.ListAll(l.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.Global))
+	})
+	return ret, err
+}
+
+func (l *globalLister) Get(name string) (*v1alpha1.Global, error) {
+	obj, exists, err := l.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("globals"), name)
+	}
+	return obj.(*v1alpha1.Global), nil
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+)
+
+// HostInterface ...
+type HostInterface interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*v1alpha1.HostList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	UpdateStatus(ctx context.Context, host *v1alpha1.Host, opts metav1.UpdateOptions) (*v1alpha1.Host, error)
+}
+
+type hosts struct {
+	client rest.Interface
+	ns     string
+}
+
+func (c *hosts) List(ctx context.Context, opts metav1.ListOptions) (result *v1alpha1.HostList, err error) {
+	result = &v1alpha1.HostList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("hosts").
+		VersionedParams(&opts, parameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+func (c *hosts) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("hosts").
+		VersionedParams(&opts, parameterCodec).
+		Watch(ctx)
+}
+
+func (c *hosts) UpdateStatus(ctx context.Context, host *v1alpha1.Host, opts metav1.UpdateOptions) (result *v1alpha1.Host, err error) {
+	result = &v1alpha1.Host{}
+	err = c.client.Put().
+		Namespace(host.Namespace).
+		Resource("hosts").
+		Name(host.Name).
+		SubResource("status").
+		VersionedParams(&opts, parameterCodec).
+		Body(host).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// NewHostInformer creates a shared index informer of the Host
+// resources. An empty namespace watches the whole cluster.
+func NewHostInformer(client Interface, namespace string, resync time.Duration) cache.SharedIndexInformer {
+	return NewFilteredHostInformer(client, namespace, resync, nil)
+}
+
+// NewFilteredHostInformer creates a shared index informer of the Host
+// resources, tweakListOptions changes the list options, e.g. label and field
+// selectors, of the list and watch requests.
+func NewFilteredHostInformer(client Interface, namespace string, resync time.Duration, tweakListOptions func(*metav1.ListOptions)) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.HAProxyIngressV1alpha1().Hosts(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.HAProxyIngressV1alpha1().Hosts(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&v1alpha1.Host{},
+		resync,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+}
+
+// HostLister ...
+type HostLister interface {
+	List(selector labels.Selector) ([]*v1alpha1.Host, error)
+	Get(namespace, name string) (*v1alpha1.Host, error)
+}
+
+// NewHostLister creates a lister of the Host resources
+// stored in the indexer of an informer
+func NewHostLister(indexer cache.Indexer) HostLister {
+	return &hostLister{indexer: indexer}
+}
+
+type hostLister struct {
+	indexer cache.Indexer
+}
+
+func (l *hostLister) List(selector labels.Selector) (ret []*v1alpha1.Host, err error) {
+	err = cache.ListAll(l.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.Host))
+	})
+	return ret, err
+}
+
+func (l *hostLister) Get(namespace, name string) (*v1alpha1.Host, error) {
+	obj, exists, err := l.indexer.GetByKey(namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("host"), name)
+	}
+	return obj.(*v1alpha1.Host), nil
+}
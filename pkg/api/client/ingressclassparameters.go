@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+)
+
+// IngressClassParametersInterface ...
+type IngressClassParametersInterface interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*v1alpha1.IngressClassParametersList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+}
+
+type ingressClassParameters struct {
+	client rest.Interface
+}
+
+func (c *ingressClassParameters) List(ctx context.Context, opts metav1.ListOptions) (result *v1alpha1.IngressClassParametersList, err error) {
+	result = &v1alpha1.IngressClassParametersList{}
+	err = c.client.Get().
+		Resource("ingressclassparameters").
+		VersionedParams(&opts, parameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+func (c *ingressClassParameters) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Resource("ingressclassparameters").
+		VersionedParams(&opts, parameterCodec).
+		Watch(ctx)
+}
+
+// NewIngressClassParametersInformer creates a shared index informer of the
+// IngressClassParameters resources, which are cluster scoped.
+func NewIngressClassParametersInformer(client Interface, resync time.Duration) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.HAProxyIngressV1alpha1().IngressClassParameters().List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.HAProxyIngressV1alpha1().IngressClassParameters().Watch(context.TODO(), options)
+			},
+		},
+		&v1alpha1.IngressClassParameters{},
+		resync,
+		cache.Indexers{},
+	)
+}
+
+// IngressClassParametersLister ...
+type IngressClassParametersLister interface {
+	List(selector labels.Selector) ([]*v1alpha1.IngressClassParameters, error)
+	Get(name string) (*v1alpha1.IngressClassParameters, error)
+}
+
+// NewIngressClassParametersLister creates a lister of the
+// IngressClassParameters resources stored in the indexer of an informer
+func NewIngressClassParametersLister(indexer cache.Indexer) IngressClassParametersLister {
+	return &ingressClassParametersLister{indexer: indexer}
+}
+
+type ingressClassParametersLister struct {
+	indexer cache.Indexer
+}
+
+func (l *ingressClassParametersLister) List(selector labels.Selector) (ret []*v1alpha1.IngressClassParameters, err error) {
+	err = cache.ListAll(l.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.IngressClassParameters))
+	})
+	return ret, err
+}
+
+func (l *ingressClassParametersLister) Get(name string) (*v1alpha1.IngressClassParameters, error) {
+	obj, exists, err := l.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("ingressclassparameters"), name)
+	}
+	return obj.(*v1alpha1.IngressClassParameters), nil
+}
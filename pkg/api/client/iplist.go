@@ -0,0 +1,126 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+)
+
+// IPListInterface ...
+type IPListInterface interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*v1alpha1.IPListList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	UpdateStatus(ctx context.Context, ipList *v1alpha1.IPList, opts metav1.UpdateOptions) (*v1alpha1.IPList, error)
+}
+
+type ipLists struct {
+	client rest.Interface
+}
+
+func (c *ipLists) List(ctx context.Context, opts metav1.ListOptions) (result *v1alpha1.IPListList, err error) {
+	result = &v1alpha1.IPListList{}
+	err = c.client.Get().
+		Resource("iplists").
+		VersionedParams(&opts, parameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+func (c *ipLists) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Resource("iplists").
+		VersionedParams(&opts, parameterCodec).
+		Watch(ctx)
+}
+
+func (c *ipLists) UpdateStatus(ctx context.Context, ipList *v1alpha1.IPList, opts metav1.UpdateOptions) (result *v1alpha1.IPList, err error) {
+	result = &v1alpha1.IPList{}
+	err = c.client.Put().
+		Resource("iplists").
+		Name(ipList.Name).
+		SubResource("status").
+		VersionedParams(&opts, parameterCodec).
+		Body(ipList).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// NewIPListInformer creates a shared index informer of the IPList resources,
+// which are cluster scoped.
+func NewIPListInformer(client Interface, resync time.Duration) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.HAProxyIngressV1alpha1().IPLists().List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.HAProxyIngressV1alpha1().IPLists().Watch(context.TODO(), options)
+			},
+		},
+		&v1alpha1.IPList{},
+		resync,
+		cache.Indexers{},
+	)
+}
+
+// IPListLister ...
+type IPListLister interface {
+	List(selector labels.Selector) ([]*v1alpha1.IPList, error)
+	Get(name string) (*v1alpha1.IPList, error)
+}
+
+// NewIPListLister creates a lister of the IPList resources stored in the
+// indexer of an informer
+func NewIPListLister(indexer cache.Indexer) IPListLister {
+	return &ipListLister{indexer: indexer}
+}
+
+type ipListLister struct {
+	indexer cache.Indexer
+}
+
+func (l *ipListLister) List(selector labels.Selector) (ret []*v1alpha1.IPList, err error) {
+	err = cache.ListAll(l.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.IPList))
+	})
+	return ret, err
+}
+
+func (l *ipListLister) Get(name string) (*v1alpha1.IPList, error) {
+	obj, exists, err := l.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("iplists"), name)
+	}
+	return obj.(*v1alpha1.IPList), nil
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+)
+
+// MiddlewareInterface ...
+type MiddlewareInterface interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*v1alpha1.MiddlewareList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	UpdateStatus(ctx context.Context, middleware *v1alpha1.Middleware, opts metav1.UpdateOptions) (*v1alpha1.Middleware, error)
+}
+
+type middlewares struct {
+	client rest.Interface
+	ns     string
+}
+
+func (c *middlewares) List(ctx context.Context, opts metav1.ListOptions) (result *v1alpha1.MiddlewareList, err error) {
+	result = &v1alpha1.MiddlewareList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("middlewares").
+		VersionedParams(&opts, parameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+func (c *middlewares) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("middlewares").
+		VersionedParams(&opts, parameterCodec).
+		Watch(ctx)
+}
+
+func (c *middlewares) UpdateStatus(ctx context.Context, middleware *v1alpha1.Middleware, opts metav1.UpdateOptions) (result *v1alpha1.Middleware, err error) {
+	result = &v1alpha1.Middleware{}
+	err = c.client.Put().
+		Namespace(middleware.Namespace).
+		Resource("middlewares").
+		Name(middleware.Name).
+		SubResource("status").
+		VersionedParams(&opts, parameterCodec).
+		Body(middleware).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// NewMiddlewareInformer creates a shared index informer of the Middleware
+// resources. An empty namespace watches the whole cluster.
+func NewMiddlewareInformer(client Interface, namespace string, resync time.Duration) cache.SharedIndexInformer {
+	return NewFilteredMiddlewareInformer(client, namespace, resync, nil)
+}
+
+// NewFilteredMiddlewareInformer creates a shared index informer of the Middleware
+// resources, tweakListOptions changes the list options, e.g. label and field
+// selectors, of the list and watch requests.
+func NewFilteredMiddlewareInformer(client Interface, namespace string, resync time.Duration, tweakListOptions func(*metav1.ListOptions)) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.HAProxyIngressV1alpha1().Middlewares(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.HAProxyIngressV1alpha1().Middlewares(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&v1alpha1.Middleware{},
+		resync,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+}
+
+// MiddlewareLister ...
+type MiddlewareLister interface {
+	List(selector labels.Selector) ([]*v1alpha1.Middleware, error)
+	Get(namespace, name string) (*v1alpha1.Middleware, error)
+}
+
+// NewMiddlewareLister creates a lister of the Middleware resources
+// stored in the indexer of an informer
+func NewMiddlewareLister(indexer cache.Indexer) MiddlewareLister {
+	return &middlewareLister{indexer: indexer}
+}
+
+type middlewareLister struct {
+	indexer cache.Indexer
+}
+
+func (l *middlewareLister) List(selector labels.Selector) (ret []*v1alpha1.Middleware, err error) {
+	err = cache.ListAll(l.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.Middleware))
+	})
+	return ret, err
+}
+
+func (l *middlewareLister) Get(namespace, name string) (*v1alpha1.Middleware, error) {
+	obj, exists, err := l.indexer.GetByKey(namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("middleware"), name)
+	}
+	return obj.(*v1alpha1.Middleware), nil
+}
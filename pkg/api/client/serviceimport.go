@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	mcsv1alpha1 "github.com/jcmoraisjr/haproxy-ingress/pkg/api/mcs/v1alpha1"
+)
+
+// ServiceImportInterface ...
+type ServiceImportInterface interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*mcsv1alpha1.ServiceImportList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+}
+
+type serviceImports struct {
+	client rest.Interface
+	ns     string
+}
+
+func (c *serviceImports) List(ctx context.Context, opts metav1.ListOptions) (result *mcsv1alpha1.ServiceImportList, err error) {
+	result = &mcsv1alpha1.ServiceImportList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("serviceimports").
+		VersionedParams(&opts, parameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+func (c *serviceImports) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("serviceimports").
+		VersionedParams(&opts, parameterCodec).
+		Watch(ctx)
+}
+
+// NewServiceImportInformer creates a shared index informer of the
+// ServiceImport resources. An empty namespace watches the whole cluster.
+func NewServiceImportInformer(client Interface, namespace string, resync time.Duration) cache.SharedIndexInformer {
+	return NewFilteredServiceImportInformer(client, namespace, resync, nil)
+}
+
+// NewFilteredServiceImportInformer creates a shared index informer of the ServiceImport
+// resources, tweakListOptions changes the list options, e.g. label and field
+// selectors, of the list and watch requests.
+func NewFilteredServiceImportInformer(client Interface, namespace string, resync time.Duration, tweakListOptions func(*metav1.ListOptions)) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.MulticlusterV1alpha1().ServiceImports(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.MulticlusterV1alpha1().ServiceImports(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&mcsv1alpha1.ServiceImport{},
+		resync,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+}
+
+// ServiceImportLister ...
+type ServiceImportLister interface {
+	Get(namespace, name string) (*mcsv1alpha1.ServiceImport, error)
+}
+
+// NewServiceImportLister creates a lister of the ServiceImport resources
+// stored in the indexer of an informer
+func NewServiceImportLister(indexer cache.Indexer) ServiceImportLister {
+	return &serviceImportLister{indexer: indexer}
+}
+
+type serviceImportLister struct {
+	indexer cache.Indexer
+}
+
+func (l *serviceImportLister) Get(namespace, name string) (*mcsv1alpha1.ServiceImport, error) {
+	obj, exists, err := l.indexer.GetByKey(namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(mcsv1alpha1.Resource("serviceimport"), name)
+	}
+	return obj.(*mcsv1alpha1.ServiceImport), nil
+}
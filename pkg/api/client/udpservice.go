@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+)
+
+// UDPServiceInterface ...
+type UDPServiceInterface interface {
+	List(ctx context.Context, opts metav1.ListOptions) (*v1alpha1.UDPServiceList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	UpdateStatus(ctx context.Context, udpService *v1alpha1.UDPService, opts metav1.UpdateOptions) (*v1alpha1.UDPService, error)
+}
+
+type udpServices struct {
+	client rest.Interface
+	ns     string
+}
+
+func (c *udpServices) List(ctx context.Context, opts metav1.ListOptions) (result *v1alpha1.UDPServiceList, err error) {
+	result = &v1alpha1.UDPServiceList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("udpservices").
+		VersionedParams(&opts, parameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+func (c *udpServices) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("udpservices").
+		VersionedParams(&opts, parameterCodec).
+		Watch(ctx)
+}
+
+func (c *udpServices) UpdateStatus(ctx context.Context, udpService *v1alpha1.UDPService, opts metav1.UpdateOptions) (result *v1alpha1.UDPService, err error) {
+	result = &v1alpha1.UDPService{}
+	err = c.client.Put().
+		Namespace(udpService.Namespace).
+		Resource("udpservices").
+		Name(udpService.Name).
+		SubResource("status").
+		VersionedParams(&opts, parameterCodec).
+		Body(udpService).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// NewUDPServiceInformer creates a shared index informer of the UDPService
+// resources. An empty namespace watches the whole cluster.
+func NewUDPServiceInformer(client Interface, namespace string, resync time.Duration) cache.SharedIndexInformer {
+	return NewFilteredUDPServiceInformer(client, namespace, resync, nil)
+}
+
+// NewFilteredUDPServiceInformer creates a shared index informer of the UDPService
+// resources, tweakListOptions changes the list options, e.g. label and field
+// selectors, of the list and watch requests.
+func NewFilteredUDPServiceInformer(client Interface, namespace string, resync time.Duration, tweakListOptions func(*metav1.ListOptions)) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.HAProxyIngressV1alpha1().UDPServices(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.HAProxyIngressV1alpha1().UDPServices(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&v1alpha1.UDPService{},
+		resync,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+}
+
+// UDPServiceLister ...
+type UDPServiceLister interface {
+	List(selector labels.Selector) ([]*v1alpha1.UDPService, error)
+	Get(namespace, name string) (*v1alpha1.UDPService, error)
+}
+
+// NewUDPServiceLister creates a lister of the UDPService resources
+// stored in the indexer of an informer
+func NewUDPServiceLister(indexer cache.Indexer) UDPServiceLister {
+	return &udpServiceLister{indexer: indexer}
+}
+
+type udpServiceLister struct {
+	indexer cache.Indexer
+}
+
+func (l *udpServiceLister) List(selector labels.Selector) (ret []*v1alpha1.UDPService, err error) {
+	err = cache.ListAll(l.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.UDPService))
+	})
+	return ret, err
+}
+
+func (l *udpServiceLister) Get(namespace, name string) (*v1alpha1.UDPService, error) {
+	obj, exists, err := l.indexer.GetByKey(namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("tcpservice"), name)
+	}
+	return obj.(*v1alpha1.UDPService), nil
+}
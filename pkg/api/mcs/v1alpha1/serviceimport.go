@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Labels added by the MCS implementation to the EndpointSlices of an
+// imported service
+const (
+	// LabelServiceName is the name of the ServiceImport the slice belongs to
+	LabelServiceName = "multicluster.kubernetes.io/service-name"
+
+	// LabelSourceCluster is the id of the cluster the endpoints come from
+	LabelSourceCluster = "multicluster.kubernetes.io/source-cluster"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ServiceImport describes a service imported from the clusters of a ClusterSet
+type ServiceImport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServiceImportSpec   `json:"spec,omitempty"`
+	Status ServiceImportStatus `json:"status,omitempty"`
+}
+
+// ServiceImportType designates the type of a ServiceImport
+type ServiceImportType string
+
+const (
+	// ClusterSetIP are only accessible via the ClusterSet IP
+	ClusterSetIP ServiceImportType = "ClusterSetIP"
+
+	// Headless services allow backend pods to be addressed directly
+	Headless ServiceImportType = "Headless"
+)
+
+// ServiceImportSpec ...
+type ServiceImportSpec struct {
+	// Ports exposed by the imported service
+	Ports []ServicePort `json:"ports"`
+
+	// IPs are the ClusterSet IPs of the imported service
+	IPs []string `json:"ips,omitempty"`
+
+	// Type of the imported service, ClusterSetIP or Headless
+	Type ServiceImportType `json:"type"`
+
+	// SessionAffinity of the imported service, ClientIP or None
+	SessionAffinity api.ServiceAffinity `json:"sessionAffinity,omitempty"`
+}
+
+// ServicePort ...
+type ServicePort struct {
+	// Name of the port, required if more than one port is declared
+	Name string `json:"name,omitempty"`
+
+	// Protocol of the port, TCP if not declared
+	Protocol api.Protocol `json:"protocol,omitempty"`
+
+	// Port number exposed by the imported service
+	Port int32 `json:"port"`
+}
+
+// ServiceImportStatus ...
+type ServiceImportStatus struct {
+	// Clusters is the list of clusters exporting the service
+	Clusters []ClusterStatus `json:"clusters,omitempty"`
+}
+
+// ClusterStatus ...
+type ClusterStatus struct {
+	// Cluster is the id of a cluster exporting the service
+	Cluster string `json:"cluster"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ServiceImportList is a list of ServiceImport resources
+type ServiceImportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []ServiceImport `json:"items"`
+}
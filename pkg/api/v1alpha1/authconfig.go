@@ -0,0 +1,139 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AuthConfig has the authentication settings of Basic, External and OIDC
+// authentication. Ingress resources reference an AuthConfig using the
+// auth-config configuration key, and Host resources using the auth field
+type AuthConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AuthConfigSpec   `json:"spec"`
+	Status AuthConfigStatus `json:"status,omitempty"`
+}
+
+// AuthConfigSpec ...
+type AuthConfigSpec struct {
+	// Basic configures HTTP Basic authentication
+	Basic *AuthConfigBasic `json:"basic,omitempty"`
+
+	// External configures authentication made by an external service,
+	// e.g. a forward auth service like oauth2-proxy or Authelia
+	External *AuthConfigExternal `json:"external,omitempty"`
+
+	// OIDC configures the built-in OpenID Connect authentication
+	OIDC *AuthConfigOIDC `json:"oidc,omitempty"`
+}
+
+// AuthConfigBasic ...
+type AuthConfigBasic struct {
+	// SecretName is the name of the secret with the users and passwords,
+	// in the same namespace, the auth-secret configuration key
+	SecretName string `json:"secretName"`
+
+	// Realm is the protection space of the authentication, the auth-realm
+	// configuration key
+	Realm string `json:"realm,omitempty"`
+
+	// Groups is a list of groups allowed to access the paths, the
+	// auth-groups configuration key
+	Groups []string `json:"groups,omitempty"`
+}
+
+// AuthConfigExternal ...
+type AuthConfigExternal struct {
+	// URL of the authentication service, the auth-url configuration key
+	URL string `json:"url"`
+
+	// Signin is the URL the user should be redirected to if the
+	// authentication fails, the auth-signin configuration key
+	Signin string `json:"signin,omitempty"`
+
+	// Method is the HTTP method used in the authentication requests, the
+	// auth-method configuration key
+	Method string `json:"method,omitempty"`
+
+	// ForwardHeaders adds the method and the URL of the client request to
+	// the authentication request, the auth-forward-headers configuration key
+	ForwardHeaders *bool `json:"forwardHeaders,omitempty"`
+
+	// Headers configures the headers copied from and to the authentication
+	// service
+	Headers *AuthConfigHeaders `json:"headers,omitempty"`
+}
+
+// AuthConfigHeaders ...
+type AuthConfigHeaders struct {
+	// Request is a list of header names copied from the client to the
+	// authentication service, the auth-headers-request configuration key
+	Request []string `json:"request,omitempty"`
+
+	// Succeed is a list of header names copied from the authentication
+	// service to the backend server, the auth-headers-succeed configuration key
+	Succeed []string `json:"succeed,omitempty"`
+
+	// Fail is a list of header names copied from the authentication service
+	// to the client, the auth-headers-fail configuration key
+	Fail []string `json:"fail,omitempty"`
+}
+
+// AuthConfigOIDC ...
+type AuthConfigOIDC struct {
+	// Issuer is the issuer URL of the OpenID Connect provider, the
+	// oidc-issuer configuration key
+	Issuer string `json:"issuer"`
+
+	// SecretName is the name of the secret with the client credentials,
+	// in the same namespace, the oidc-secret configuration key
+	SecretName string `json:"secretName"`
+
+	// Scopes is a list of the scopes requested to the provider, the
+	// oidc-scopes configuration key
+	Scopes []string `json:"scopes,omitempty"`
+
+	// CallbackPath is the path the provider sends the user back to, the
+	// oidc-callback-path configuration key
+	CallbackPath string `json:"callbackPath,omitempty"`
+}
+
+// AuthConfigStatus ...
+type AuthConfigStatus struct {
+	// ObservedGeneration is the generation of the spec the controller
+	// used to build the current status
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the current state of the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AuthConfigList is a list of AuthConfig resources
+type AuthConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []AuthConfig `json:"items"`
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Backend configures the proxy of a service, its configuration is used in
+// all the ingress resources that route requests to the same service
+type Backend struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BackendSpec   `json:"spec"`
+	Status BackendStatus `json:"status,omitempty"`
+}
+
+// BackendSpec ...
+type BackendSpec struct {
+	// ServiceName is the name of the service being configured, in the
+	// same namespace
+	ServiceName string `json:"serviceName"`
+
+	// BalanceAlgorithm is the load balancing algorithm of the endpoints,
+	// the balance-algorithm configuration key
+	BalanceAlgorithm string `json:"balanceAlgorithm,omitempty"`
+
+	// HealthCheck configures the health check of the endpoints
+	HealthCheck *BackendHealthCheck `json:"healthCheck,omitempty"`
+
+	// Timeouts configures the timeouts of the backend
+	Timeouts *BackendTimeouts `json:"timeouts,omitempty"`
+
+	// Limits configures the connection limits of the endpoints
+	Limits *BackendLimits `json:"limits,omitempty"`
+
+	// Config has configuration keys not covered by the typed fields, the
+	// typed fields have precedence if the same key is also declared here
+	Config map[string]string `json:"config,omitempty"`
+}
+
+// BackendHealthCheck ...
+type BackendHealthCheck struct {
+	// URI of the HTTP health check, health checks are made in the TCP
+	// layer if not declared, the health-check-uri configuration key
+	URI string `json:"uri,omitempty"`
+
+	// Method of the HTTP health check, the health-check-method
+	// configuration key
+	Method string `json:"method,omitempty"`
+
+	// Host header of the HTTP health check, the health-check-host
+	// configuration key
+	Host string `json:"host,omitempty"`
+
+	// Expect is the expected response of the HTTP health check, e.g.
+	// `status 200`, the health-check-expect configuration key
+	Expect string `json:"expect,omitempty"`
+
+	// Port is the port number of the health check if distinct of the
+	// endpoint port, the health-check-port configuration key
+	Port *int32 `json:"port,omitempty"`
+
+	// Interval between health checks, the health-check-interval
+	// configuration key
+	Interval string `json:"interval,omitempty"`
+
+	// Timeout of a health check, the health-check-timeout configuration key
+	Timeout string `json:"timeout,omitempty"`
+
+	// RiseCount is the number of successful health checks that turns a
+	// failed endpoint operational, the health-check-rise-count
+	// configuration key
+	RiseCount *int32 `json:"riseCount,omitempty"`
+
+	// FallCount is the number of failed health checks that turns an
+	// endpoint dead, the health-check-fall-count configuration key
+	FallCount *int32 `json:"fallCount,omitempty"`
+}
+
+// BackendTimeouts ...
+type BackendTimeouts struct {
+	// Connect is the maximum time to wait for a connection to an
+	// endpoint, the timeout-connect configuration key
+	Connect string `json:"connect,omitempty"`
+
+	// Queue is the maximum time a request can wait in the queue of a busy
+	// backend, the timeout-queue configuration key
+	Queue string `json:"queue,omitempty"`
+
+	// Server is the maximum inactivity time on the server side, the
+	// timeout-server configuration key
+	Server string `json:"server,omitempty"`
+
+	// ServerFin is the maximum inactivity time on the server side of half
+	// closed connections, the timeout-server-fin configuration key
+	ServerFin string `json:"serverFin,omitempty"`
+
+	// Tunnel is the maximum inactivity time of websocket and other tunnel
+	// connections, the timeout-tunnel configuration key
+	Tunnel string `json:"tunnel,omitempty"`
+}
+
+// BackendLimits ...
+type BackendLimits struct {
+	// MaxConnServer is the maximum number of concurrent connections of
+	// each endpoint, zero means unlimited, the maxconn-server
+	// configuration key
+	MaxConnServer *int32 `json:"maxConnServer,omitempty"`
+
+	// MaxQueueServer is the maximum number of requests waiting for a
+	// connection to each endpoint, the maxqueue-server configuration key
+	MaxQueueServer *int32 `json:"maxQueueServer,omitempty"`
+}
+
+// BackendStatus ...
+type BackendStatus struct {
+	// ObservedGeneration is the generation of the spec the controller
+	// used to build the current status
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the current state of the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// BackendList is a list of Backend resources
+type BackendList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Backend `json:"items"`
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Global configures the global configuration keys of the controller, as
+// an alternative to the global ConfigMap. The resource used is the one
+// named in the --global-resource command-line option.
+type Global struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GlobalSpec   `json:"spec"`
+	Status GlobalStatus `json:"status,omitempty"`
+}
+
+// GlobalSpec ...
+type GlobalSpec struct {
+	// Ports configures the listening ports of the controller
+	Ports *GlobalPorts `json:"ports,omitempty"`
+
+	// Process configures the haproxy process
+	Process *GlobalProcess `json:"process,omitempty"`
+
+	// Timeouts configures the default timeouts of the frontends and the
+	// backends
+	Timeouts *GlobalTimeouts `json:"timeouts,omitempty"`
+
+	// TLS configures the default TLS policy of the hosts
+	TLS *GlobalTLS `json:"tls,omitempty"`
+
+	// Syslog configures a syslog server to send the haproxy logs
+	Syslog *GlobalSyslog `json:"syslog,omitempty"`
+
+	// Config has configuration keys not covered by the typed fields, the
+	// typed fields have precedence if the same key is also declared here
+	Config map[string]string `json:"config,omitempty"`
+}
+
+// GlobalPorts ...
+type GlobalPorts struct {
+	// HTTP is the port of the plain HTTP requests, the http-port
+	// configuration key
+	HTTP *int32 `json:"http,omitempty"`
+
+	// HTTPS is the port of the HTTPS requests, the https-port
+	// configuration key
+	HTTPS *int32 `json:"https,omitempty"`
+
+	// Healthz is the port of the health check endpoint, the healthz-port
+	// configuration key
+	Healthz *int32 `json:"healthz,omitempty"`
+
+	// Prometheus is the port of the haproxy's internal Prometheus exporter,
+	// the prometheus-port configuration key
+	Prometheus *int32 `json:"prometheus,omitempty"`
+
+	// Stats is the port of the haproxy's stats page, the stats-port
+	// configuration key
+	Stats *int32 `json:"stats,omitempty"`
+}
+
+// GlobalProcess ...
+type GlobalProcess struct {
+	// MaxConnections is the maximum number of concurrent connections of
+	// the haproxy process, the max-connections configuration key
+	MaxConnections *int32 `json:"maxConnections,omitempty"`
+
+	// Nbthread is the number of threads of the haproxy process, the
+	// nbthread configuration key
+	Nbthread *int32 `json:"nbthread,omitempty"`
+
+	// TimeoutStop is the maximum time an old haproxy process can run after
+	// a reload, the timeout-stop configuration key
+	TimeoutStop string `json:"timeoutStop,omitempty"`
+}
+
+// GlobalTimeouts ...
+type GlobalTimeouts struct {
+	// Client is the maximum inactivity time on the client side, the
+	// timeout-client configuration key
+	Client string `json:"client,omitempty"`
+
+	// ClientFin is the maximum inactivity time on the client side of half
+	// closed connections, the timeout-client-fin configuration key
+	ClientFin string `json:"clientFin,omitempty"`
+
+	// Connect is the maximum time to wait for a connection to a backend
+	// server, the timeout-connect configuration key
+	Connect string `json:"connect,omitempty"`
+
+	// HTTPRequest is the maximum time to wait for a complete HTTP request,
+	// the timeout-http-request configuration key
+	HTTPRequest string `json:"httpRequest,omitempty"`
+
+	// KeepAlive is the maximum time to wait for a new HTTP request on a
+	// keep-alive connection, the timeout-keep-alive configuration key
+	KeepAlive string `json:"keepAlive,omitempty"`
+
+	// Queue is the maximum time a request can wait in the queue of a busy
+	// backend, the timeout-queue configuration key
+	Queue string `json:"queue,omitempty"`
+
+	// Server is the maximum inactivity time on the server side, the
+	// timeout-server configuration key
+	Server string `json:"server,omitempty"`
+
+	// ServerFin is the maximum inactivity time on the server side of half
+	// closed connections, the timeout-server-fin configuration key
+	ServerFin string `json:"serverFin,omitempty"`
+
+	// Tunnel is the maximum inactivity time of websocket and other tunnel
+	// connections, the timeout-tunnel configuration key
+	Tunnel string `json:"tunnel,omitempty"`
+}
+
+// GlobalTLS ...
+type GlobalTLS struct {
+	// Ciphers is a colon-separated list of the TLS 1.2 and older ciphers,
+	// the ssl-ciphers configuration key
+	Ciphers string `json:"ciphers,omitempty"`
+
+	// CipherSuites is a colon-separated list of the TLS 1.3 cipher suites,
+	// the ssl-cipher-suites configuration key
+	CipherSuites string `json:"cipherSuites,omitempty"`
+
+	// Options is a space-separated list of the default SSL options, e.g.
+	// `no-sslv3 no-tlsv10`, the ssl-options configuration key
+	Options string `json:"options,omitempty"`
+
+	// DHDefaultMaxSize is the maximum size of the temporary DH parameters,
+	// the ssl-dh-default-max-size configuration key
+	DHDefaultMaxSize *int32 `json:"dhDefaultMaxSize,omitempty"`
+
+	// Redirect configures if plain HTTP requests should be redirected to
+	// HTTPS, the ssl-redirect configuration key
+	Redirect *bool `json:"redirect,omitempty"`
+
+	// RedirectCode is the HTTP status code of the redirect to HTTPS, the
+	// ssl-redirect-code configuration key
+	RedirectCode *int32 `json:"redirectCode,omitempty"`
+}
+
+// GlobalSyslog ...
+type GlobalSyslog struct {
+	// Endpoint is the IP or hostname and port of the syslog server, the
+	// syslog-endpoint configuration key
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Format is the format of the log lines, the syslog-format
+	// configuration key
+	Format string `json:"format,omitempty"`
+
+	// Length is the maximum length of a log line, the syslog-length
+	// configuration key
+	Length *int32 `json:"length,omitempty"`
+
+	// Tag is the tag of the log lines, the syslog-tag configuration key
+	Tag string `json:"tag,omitempty"`
+}
+
+// GlobalStatus ...
+type GlobalStatus struct {
+	// ObservedGeneration is the generation of the spec the controller
+	// used to build the current status
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the current state of the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// GlobalList is a list of Global resources
+type GlobalList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Global `json:"items"`
+}
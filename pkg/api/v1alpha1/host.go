@@ -0,0 +1,156 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Host configures a hostname, its configuration is shared by all the
+// ingress resources that route requests to the same hostname
+type Host struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HostSpec   `json:"spec"`
+	Status HostStatus `json:"status,omitempty"`
+}
+
+// HostSpec ...
+type HostSpec struct {
+	// Hostname is the name of the host being configured, it should match
+	// the host field of the ingress rules
+	Hostname string `json:"hostname"`
+
+	// Frontend is the name of an extra frontend, declared in the
+	// extra-frontends global configuration key, that serves this host. The
+	// access logs of the host are sent to the syslog endpoint of the extra
+	// frontend, if configured in extra-frontends-syslog
+	Frontend string `json:"frontend,omitempty"`
+
+	// TLS configures the TLS policy of the host
+	TLS *HostTLS `json:"tls,omitempty"`
+
+	// Auth configures the authentication of the requests to the host
+	Auth *HostAuth `json:"auth,omitempty"`
+
+	// RateLimit configures the rate and connection limits of the host
+	RateLimit *HostRateLimit `json:"rateLimit,omitempty"`
+
+	// Config has configuration keys not covered by the typed fields, the
+	// typed fields have precedence if the same key is also declared here
+	Config map[string]string `json:"config,omitempty"`
+}
+
+// HostTLS ...
+type HostTLS struct {
+	// Ciphers is a colon-separated list of the TLS 1.2 and older ciphers,
+	// the ssl-ciphers configuration key
+	Ciphers string `json:"ciphers,omitempty"`
+
+	// CipherSuites is a colon-separated list of the TLS 1.3 cipher suites,
+	// the ssl-cipher-suites configuration key
+	CipherSuites string `json:"cipherSuites,omitempty"`
+
+	// Options is a space-separated list of the SSL options of the host,
+	// e.g. `ssl-min-ver TLSv1.2`, the ssl-options-host configuration key
+	Options string `json:"options,omitempty"`
+
+	// ALPN is the TLS ALPN advertisement, the tls-alpn configuration key
+	ALPN string `json:"alpn,omitempty"`
+
+	// Redirect configures if plain HTTP requests should be redirected to
+	// HTTPS, the ssl-redirect configuration key
+	Redirect *bool `json:"redirect,omitempty"`
+}
+
+// HostAuth ...
+type HostAuth struct {
+	// ConfigName is the name of an AuthConfig resource in the same
+	// namespace, the auth-config configuration key. Basic and External
+	// have precedence over the AuthConfig settings
+	ConfigName string `json:"configName,omitempty"`
+
+	// Basic configures HTTP Basic authentication
+	Basic *HostAuthBasic `json:"basic,omitempty"`
+
+	// External configures authentication made by an external service
+	External *HostAuthExternal `json:"external,omitempty"`
+}
+
+// HostAuthBasic ...
+type HostAuthBasic struct {
+	// SecretName is the name of the secret with the users and passwords,
+	// in the same namespace, the auth-secret configuration key
+	SecretName string `json:"secretName"`
+
+	// Realm is the protection space of the authentication, the auth-realm
+	// configuration key
+	Realm string `json:"realm,omitempty"`
+}
+
+// HostAuthExternal ...
+type HostAuthExternal struct {
+	// URL of the authentication service, the auth-url configuration key
+	URL string `json:"url"`
+
+	// Signin is the URL the user should be redirected to if the
+	// authentication fails, the auth-signin configuration key
+	Signin string `json:"signin,omitempty"`
+
+	// Method is the HTTP method used in the authentication requests, the
+	// auth-method configuration key
+	Method string `json:"method,omitempty"`
+}
+
+// HostRateLimit ...
+type HostRateLimit struct {
+	// RPS is the maximum number of requests per second of a single
+	// source IP, the limit-rps configuration key
+	RPS *int32 `json:"rps,omitempty"`
+
+	// Connections is the maximum number of concurrent connections of a
+	// single source IP, the limit-connections configuration key
+	Connections *int32 `json:"connections,omitempty"`
+
+	// AllowList is a list of CIDRs that are not limited, the
+	// limit-whitelist configuration key
+	AllowList []string `json:"allowList,omitempty"`
+}
+
+// HostStatus ...
+type HostStatus struct {
+	// ObservedGeneration is the generation of the spec the controller
+	// used to build the current status
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the current state of the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HostList is a list of Host resources
+type HostList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Host `json:"items"`
+}
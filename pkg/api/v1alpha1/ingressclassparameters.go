@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// IngressClassParameters configures the default values of the ingress
+// resources of an IngressClass whose spec.parameters references it
+type IngressClassParameters struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec IngressClassParametersSpec `json:"spec"`
+}
+
+// IngressClassParametersSpec ...
+type IngressClassParametersSpec struct {
+	// Frontend is the name of an extra frontend, declared in the
+	// extra-frontends global configuration key, that serves the hosts of
+	// this class, the frontend configuration key
+	Frontend string `json:"frontend,omitempty"`
+
+	// Timeouts configures the timeouts of the backends
+	Timeouts *IngressClassTimeouts `json:"timeouts,omitempty"`
+
+	// TLS configures the TLS policy of the hosts
+	TLS *IngressClassTLS `json:"tls,omitempty"`
+
+	// Config has configuration keys not covered by the typed fields, the
+	// typed fields have precedence if the same key is also declared here
+	Config map[string]string `json:"config,omitempty"`
+}
+
+// IngressClassTimeouts ...
+type IngressClassTimeouts struct {
+	// Connect is the maximum time to wait for a connection to a backend
+	// server, the timeout-connect configuration key
+	Connect string `json:"connect,omitempty"`
+
+	// HTTPRequest is the maximum time to wait for a complete HTTP request,
+	// the timeout-http-request configuration key
+	HTTPRequest string `json:"httpRequest,omitempty"`
+
+	// KeepAlive is the maximum time to wait for a new HTTP request on a
+	// keep-alive connection, the timeout-keep-alive configuration key
+	KeepAlive string `json:"keepAlive,omitempty"`
+
+	// Queue is the maximum time a request can wait in the queue of a busy
+	// backend, the timeout-queue configuration key
+	Queue string `json:"queue,omitempty"`
+
+	// Server is the maximum inactivity time on the server side, the
+	// timeout-server configuration key
+	Server string `json:"server,omitempty"`
+
+	// ServerFin is the maximum inactivity time on the server side of half
+	// closed connections, the timeout-server-fin configuration key
+	ServerFin string `json:"serverFin,omitempty"`
+
+	// Tunnel is the maximum inactivity time of websocket and other tunnel
+	// connections, the timeout-tunnel configuration key
+	Tunnel string `json:"tunnel,omitempty"`
+}
+
+// IngressClassTLS ...
+type IngressClassTLS struct {
+	// Ciphers is a colon-separated list of the TLS 1.2 and older ciphers,
+	// the ssl-ciphers configuration key
+	Ciphers string `json:"ciphers,omitempty"`
+
+	// CipherSuites is a colon-separated list of the TLS 1.3 cipher suites,
+	// the ssl-cipher-suites configuration key
+	CipherSuites string `json:"cipherSuites,omitempty"`
+
+	// Options is a space-separated list of the SSL options of the hosts,
+	// e.g. `ssl-min-ver TLSv1.2`, the ssl-options-host configuration key
+	Options string `json:"options,omitempty"`
+
+	// ALPN is the TLS ALPN advertisement, the tls-alpn configuration key
+	ALPN string `json:"alpn,omitempty"`
+
+	// Redirect configures if plain HTTP requests should be redirected to
+	// HTTPS, the ssl-redirect configuration key
+	Redirect *bool `json:"redirect,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// IngressClassParametersList is a list of IngressClassParameters resources
+type IngressClassParametersList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []IngressClassParameters `json:"items"`
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// IPList is a named list of IPs and CIDRs, referenced by the allowlist and
+// denylist configuration keys. Changes in the list are applied without
+// reloading HAProxy
+type IPList struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IPListSpec   `json:"spec"`
+	Status IPListStatus `json:"status,omitempty"`
+}
+
+// IPListSpec ...
+type IPListSpec struct {
+	// Entries is the list of IPs and CIDRs, e.g. 192.168.0.0/16
+	Entries []string `json:"entries"`
+}
+
+// IPListStatus ...
+type IPListStatus struct {
+	// ObservedGeneration is the generation of the spec the controller
+	// used to build the current status
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the current state of the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// IPListList is a list of IPList resources
+type IPListList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []IPList `json:"items"`
+}
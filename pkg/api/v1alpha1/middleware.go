@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Middleware is a reusable chain of request processing steps. Ingress
+// resources reference Middlewares using the middlewares configuration key,
+// and HTTPRoutes using an ExtensionRef filter
+type Middleware struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MiddlewareSpec   `json:"spec"`
+	Status MiddlewareStatus `json:"status,omitempty"`
+}
+
+// MiddlewareSpec ...
+type MiddlewareSpec struct {
+	// RateLimit configures the rate and connection limits of the requests
+	RateLimit *HostRateLimit `json:"rateLimit,omitempty"`
+
+	// Headers configures the HTTP headers of the requests and responses
+	Headers *MiddlewareHeaders `json:"headers,omitempty"`
+
+	// Auth configures the authentication of the requests
+	Auth *HostAuth `json:"auth,omitempty"`
+
+	// Rewrite configures the path sent to the backend servers
+	Rewrite *MiddlewareRewrite `json:"rewrite,omitempty"`
+
+	// Middlewares is a list of other Middlewares, in the same namespace,
+	// that are chained after this one. This Middleware has precedence if
+	// more than one of the chain configure the same key
+	Middlewares []string `json:"middlewares,omitempty"`
+
+	// Config has configuration keys not covered by the typed fields, the
+	// typed fields have precedence if the same key is also declared here
+	Config map[string]string `json:"config,omitempty"`
+}
+
+// MiddlewareHeaders ...
+type MiddlewareHeaders struct {
+	// Request changes the headers of the requests sent to the backend
+	// servers, the request-headers-* configuration keys
+	Request *MiddlewareHeaderActions `json:"request,omitempty"`
+
+	// Response changes the headers of the responses sent to the clients,
+	// the response-headers-* configuration keys
+	Response *MiddlewareHeaderActions `json:"response,omitempty"`
+}
+
+// MiddlewareHeaderActions ...
+type MiddlewareHeaderActions struct {
+	// Set adds headers, removing any header with the same name
+	Set []MiddlewareHeader `json:"set,omitempty"`
+
+	// Add adds headers, preserving any header with the same name
+	Add []MiddlewareHeader `json:"add,omitempty"`
+
+	// Remove is a list of header names that should be removed
+	Remove []string `json:"remove,omitempty"`
+}
+
+// MiddlewareHeader ...
+type MiddlewareHeader struct {
+	// Name of the HTTP header
+	Name string `json:"name"`
+
+	// Value of the HTTP header, used literally
+	Value string `json:"value"`
+}
+
+// MiddlewareRewrite ...
+type MiddlewareRewrite struct {
+	// Target replaces the path of the ingress rule in the requests sent to
+	// the backend servers, the rewrite-target configuration key
+	Target string `json:"target"`
+}
+
+// MiddlewareStatus ...
+type MiddlewareStatus struct {
+	// ObservedGeneration is the generation of the spec the controller
+	// used to build the current status
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the current state of the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// MiddlewareList is a list of Middleware resources
+type MiddlewareList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Middleware `json:"items"`
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RateLimit is a named rate limit, referenced by the rate-limit
+// configuration key. All the paths that reference the same RateLimit share
+// the same counters
+type RateLimit struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RateLimitSpec   `json:"spec"`
+	Status RateLimitStatus `json:"status,omitempty"`
+}
+
+// RateLimitSpec ...
+type RateLimitSpec struct {
+	// Key selects the counter of a request, requests are counted by their
+	// source IP if not declared
+	Key *RateLimitKey `json:"key,omitempty"`
+
+	// Rate is the number of requests allowed in a period of time
+	Rate RateLimitRate `json:"rate"`
+
+	// Burst is the number of requests, above the rate, that can be
+	// accepted at once. The average rate is still limited to Rate
+	Burst *int32 `json:"burst,omitempty"`
+
+	// Response configures the response of the denied requests
+	Response *RateLimitResponse `json:"response,omitempty"`
+}
+
+// RateLimitKey ...
+type RateLimitKey struct {
+	// Header is the name of a request header whose value is used as the
+	// key. Requests without the header are counted by their source IP
+	Header string `json:"header,omitempty"`
+}
+
+// RateLimitRate ...
+type RateLimitRate struct {
+	// Requests is the number of requests allowed in the period
+	Requests int32 `json:"requests"`
+
+	// Period is the period of time, defaults to 1s
+	Period string `json:"period,omitempty"`
+}
+
+// RateLimitResponse ...
+type RateLimitResponse struct {
+	// Status is the HTTP status code of the denied requests, defaults to 429
+	Status *int32 `json:"status,omitempty"`
+
+	// Headers are added to the response of the denied requests, e.g.
+	// Retry-After
+	Headers []MiddlewareHeader `json:"headers,omitempty"`
+}
+
+// RateLimitStatus ...
+type RateLimitStatus struct {
+	// ObservedGeneration is the generation of the spec the controller
+	// used to build the current status
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the current state of the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RateLimitList is a list of RateLimit resources
+type RateLimitList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []RateLimit `json:"items"`
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TCPService exposes a service of the same namespace in a TCP port of the controller
+type TCPService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TCPServiceSpec   `json:"spec"`
+	Status TCPServiceStatus `json:"status,omitempty"`
+}
+
+// TCPServiceSpec ...
+type TCPServiceSpec struct {
+	// Port is the public port number the controller listens to
+	Port int32 `json:"port"`
+
+	// Backend is the target service
+	Backend TCPServiceBackend `json:"backend"`
+
+	// ProxyProtocol configures the PROXY protocol in both the incoming and
+	// the outgoing connections
+	ProxyProtocol *TCPServiceProxyProtocol `json:"proxyProtocol,omitempty"`
+
+	// TLS configures the TLS offload of the incoming connections
+	TLS *TCPServiceTLS `json:"tls,omitempty"`
+
+	// AllowList is a list of CIDRs allowed to connect, all the source
+	// addresses are allowed if empty
+	AllowList []string `json:"allowList,omitempty"`
+
+	// CheckInterval is the interval between TCP health checks of the
+	// endpoints, uses `2s` if not declared, `-` disables health checks
+	CheckInterval string `json:"checkInterval,omitempty"`
+
+	// CheckSequence is a list of tcp-check rules used to health check the
+	// endpoints, eg `send PING\r\n` and `expect string +PONG`. A successful
+	// connection is the only check made if empty
+	CheckSequence []string `json:"checkSequence,omitempty"`
+}
+
+// TCPServiceBackend ...
+type TCPServiceBackend struct {
+	// Name of the target service
+	Name string `json:"name"`
+
+	// Port of the target service, number or name
+	Port intstr.IntOrString `json:"port"`
+}
+
+// TCPServiceProxyProtocol ...
+type TCPServiceProxyProtocol struct {
+	// Accept expects the PROXY protocol header in the incoming connections
+	Accept bool `json:"accept,omitempty"`
+
+	// Send adds the PROXY protocol header to the outgoing connections,
+	// `v1` or `v2`, does not send if empty
+	Send string `json:"send,omitempty"`
+}
+
+// TCPServiceTLS ...
+type TCPServiceTLS struct {
+	// SecretName is the name of the secret with the certificate and
+	// private key, in the same namespace
+	SecretName string `json:"secretName"`
+
+	// CASecretName is the name of the secret with the CA bundle and
+	// optional CRL used to verify client certificates, in the same namespace
+	CASecretName string `json:"caSecretName,omitempty"`
+}
+
+// TCPServiceStatus ...
+type TCPServiceStatus struct {
+	// ObservedGeneration is the generation of the spec the controller
+	// used to build the current status
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the current state of the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Condition types and reasons used in the status of the custom resources
+const (
+	// ConditionAccepted is true if the resource is valid and was added to
+	// the haproxy configuration
+	ConditionAccepted = "Accepted"
+
+	// ReasonAccepted is used in accepted resources
+	ReasonAccepted = "Accepted"
+
+	// ReasonConflict is used when another resource configures the same port or hostname
+	ReasonConflict = "Conflict"
+
+	// ReasonInvalid is used when the resource or a referenced object is invalid or missing
+	ReasonInvalid = "Invalid"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TCPServiceList is a list of TCPService resources
+type TCPServiceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []TCPService `json:"items"`
+}
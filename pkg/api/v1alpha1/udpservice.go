@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UDPService exposes the UDP port of a service of the same namespace in an
+// UDP port of the controller. haproxy only forwards syslog messages over
+// UDP, so the target service should be a syslog server.
+type UDPService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   UDPServiceSpec   `json:"spec"`
+	Status UDPServiceStatus `json:"status,omitempty"`
+}
+
+// UDPServiceSpec ...
+type UDPServiceSpec struct {
+	// Port is the public UDP port number the controller listens to
+	Port int32 `json:"port"`
+
+	// Backend is the target service
+	Backend UDPServiceBackend `json:"backend"`
+
+	// DgramBinds is a list of additional listening addresses, in the
+	// `[<ip>:]<port>` format. The IP address of bind-ip-addr-tcp is
+	// used if omitted
+	DgramBinds []string `json:"dgramBinds,omitempty"`
+}
+
+// UDPServiceBackend ...
+type UDPServiceBackend struct {
+	// Name of the target service
+	Name string `json:"name"`
+
+	// Port of the target service, number or name. The port should use
+	// the UDP protocol
+	Port intstr.IntOrString `json:"port"`
+}
+
+// UDPServiceStatus ...
+type UDPServiceStatus struct {
+	// ObservedGeneration is the generation of the spec the controller
+	// used to build the current status
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the current state of the resource
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// UDPServiceList is a list of UDPService resources
+type UDPServiceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []UDPService `json:"items"`
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// applyConfigFile reads a YAML file whose keys are the names of the
+// command-line options, and assigns the values of the options that were
+// not declared in the command line. Options not declared anywhere use
+// their default value.
+func applyConfigFile(flags *pflag.FlagSet, filename string) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return applyConfig(flags, content)
+}
+
+func applyConfig(flags *pflag.FlagSet, content []byte) error {
+	config := map[string]interface{}{}
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []string
+	for _, name := range names {
+		value, err := configValue(flags, name, config[name])
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("option '%s': %v", name, err))
+			continue
+		}
+		if flags.Changed(name) {
+			// the command line has precedence
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			errs = append(errs, fmt.Sprintf("option '%s': %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// configValue validates a config file entry against the option it configures,
+// and converts its value to the syntax of the command line.
+func configValue(flags *pflag.FlagSet, name string, value interface{}) (string, error) {
+	if name == "config" {
+		return "", fmt.Errorf("cannot be used in the config file")
+	}
+	flag := flags.Lookup(name)
+	if flag == nil {
+		return "", fmt.Errorf("unknown option")
+	}
+	isSlice := strings.HasSuffix(flag.Value.Type(), "Slice")
+	switch v := value.(type) {
+	case nil:
+		return "", fmt.Errorf("missing value")
+	case []interface{}:
+		if !isSlice {
+			return "", fmt.Errorf("a list is not allowed, option type is %s", flag.Value.Type())
+		}
+		items := make([]string, len(v))
+		for i, item := range v {
+			switch item.(type) {
+			case nil, []interface{}, map[interface{}]interface{}:
+				return "", fmt.Errorf("list items should be strings or numbers")
+			}
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ","), nil
+	case map[interface{}]interface{}:
+		return "", fmt.Errorf("an object is not allowed, option type is %s", flag.Value.Type())
+	}
+	return fmt.Sprint(value), nil
+}
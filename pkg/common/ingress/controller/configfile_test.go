@@ -0,0 +1,139 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestApplyConfig(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		config   string
+		expected map[string]string
+		expError string
+	}{
+		// 0
+		{
+			config: ``,
+			expected: map[string]string{
+				"configmap":          "",
+				"rate-limit-update":  "0.5",
+				"wait-before-update": "200ms",
+				"watch-gateway":      "false",
+				"external-fleet":     "[]",
+			},
+		},
+		// 1
+		{
+			config: `
+configmap: ingress/haproxy
+rate-limit-update: 2
+wait-before-update: 1s
+watch-gateway: true
+external-fleet:
+- https://10.0.0.1:8080
+- https://10.0.0.2:8080
+`,
+			expected: map[string]string{
+				"configmap":          "ingress/haproxy",
+				"rate-limit-update":  "2",
+				"wait-before-update": "1s",
+				"watch-gateway":      "true",
+				"external-fleet":     "[https://10.0.0.1:8080,https://10.0.0.2:8080]",
+			},
+		},
+		// 2
+		{
+			args: []string{"--configmap=default/cm", "--watch-gateway=false"},
+			config: `
+configmap: ingress/haproxy
+watch-gateway: true
+external-fleet: https://10.0.0.1:8080
+`,
+			expected: map[string]string{
+				"configmap":          "default/cm",
+				"rate-limit-update":  "0.5",
+				"wait-before-update": "200ms",
+				"watch-gateway":      "false",
+				"external-fleet":     "[https://10.0.0.1:8080]",
+			},
+		},
+		// 3
+		{
+			config: `
+config: /etc/controller.yaml
+configmaps: ingress/haproxy
+rate-limit-update: fast
+watch-gateway:
+  enabled: true
+configmap: [a, b]
+`,
+			expError: "invalid configuration: option 'config': cannot be used in the config file; option 'configmap': a list is not allowed, option type is string; option 'configmaps': unknown option; option 'rate-limit-update': invalid argument \"fast\" for \"--rate-limit-update\" flag: strconv.ParseFloat: parsing \"fast\": invalid syntax; option 'watch-gateway': an object is not allowed, option type is bool",
+		},
+		// 4
+		{
+			config: `
+wait-before-update:
+`,
+			expError: "invalid configuration: option 'wait-before-update': missing value",
+		},
+		// 5
+		{
+			config: `
+configmap: ingress/haproxy
+configmap: ingress/other
+`,
+			expError: "yaml: unmarshal errors:\n  line 3: key \"configmap\" already set in map",
+		},
+	}
+	for i, test := range testCases {
+		flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+		flags.String("config", "", "")
+		flags.String("configmap", "", "")
+		flags.Float32("rate-limit-update", 0.5, "")
+		flags.Duration("wait-before-update", 200*time.Millisecond, "")
+		flags.Bool("watch-gateway", false, "")
+		flags.StringSlice("external-fleet", nil, "")
+		if err := flags.Parse(test.args); err != nil {
+			t.Errorf("error parsing args on %d: %v", i, err)
+			continue
+		}
+		err := applyConfig(flags, []byte(test.config))
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != test.expError {
+			t.Errorf("error differs on %d - expected: '%s' - actual: '%s'", i, test.expError, errMsg)
+		}
+		if test.expected == nil {
+			continue
+		}
+		actual := map[string]string{}
+		for name := range test.expected {
+			actual[name] = flags.Lookup(name).Value.String()
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("options differ on %d - expected: %v - actual: %v", i, test.expected, actual)
+		}
+	}
+}
@@ -26,8 +26,10 @@ import (
 
 	apiv1 "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/record"
+	gateway "sigs.k8s.io/gateway-api/apis/v1alpha1"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/net/ssl"
@@ -38,6 +40,7 @@ import (
 // deprecated controller to call functionality moved to the new controller.
 type NewCtrlIntf interface {
 	GetIngressList() ([]*networking.Ingress, error)
+	GetGatewayList() ([]*gateway.Gateway, error)
 	GetSecret(name string) (*apiv1.Secret, error)
 	IsValidClass(ing *networking.Ingress) bool
 }
@@ -60,20 +63,37 @@ type Configuration struct {
 	RateLimitUpdate  float32
 	ResyncPeriod     time.Duration
 	WaitBeforeUpdate time.Duration
+	SyncQuietPeriod  time.Duration
+	SyncMaxWait      time.Duration
 
 	DefaultService           string
 	IngressClass             string
+	IngressClasses           []string
 	ControllerName           string
 	WatchIngressWithoutClass bool
 	WatchGateway             bool
+	WatchCRDs                bool
+	WatchServiceImports      bool
 	WatchNamespace           string
+	WatchNamespaces          []string
+	IgnoreNamespaces         []string
 	ConfigMapName            string
+	GlobalResource           string
+
+	ShardCount             int
+	ShardIndex             int
+	ShardNamespaceSelector labels.Selector
+
+	WatchIngressSelector labels.Selector
+	WatchServiceSelector labels.Selector
+	WatchSecretSelector  labels.Selector
 
 	ForceNamespaceIsolation bool
 	WaitBeforeShutdown      int
 	AllowCrossNamespace     bool
 	DisableNodeList         bool
 	DisablePodList          bool
+	EnableEndpointSlicesAPI bool
 	AnnPrefix               []string
 
 	AcmeServer              bool
@@ -92,12 +112,16 @@ type Configuration struct {
 	VerifyHostname         bool
 	DefaultHealthzURL      string
 	StatsCollectProcPeriod time.Duration
-	PublishService         string
+	PublishServices        []string
+	PublishAddresses       []string
 	Backend                ingress.Controller
 
 	UpdateStatus           bool
 	UseNodeInternalIP      bool
 	ElectionID             string
+	ElectionLock           string
+	ElectionLeaseDuration  time.Duration
+	ElectionRenewDeadline  time.Duration
 	UpdateStatusOnShutdown bool
 
 	BackendShards   int
@@ -129,6 +153,14 @@ func newIngressController(config *Configuration) *GenericController {
 	return &ic
 }
 
+// NewOfflineController creates an Ingress controller whose configuration
+// doesn't come from the command line, e.g. the offline render. The status
+// of the ingress resources is never updated.
+func NewOfflineController(config *Configuration) *GenericController {
+	config.UpdateStatus = false
+	return newIngressController(config)
+}
+
 // GetConfig expose the controller configuration
 func (ic *GenericController) GetConfig() *Configuration {
 	return ic.cfg
@@ -3,11 +3,15 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"expvar"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -17,14 +21,17 @@ import (
 	"github.com/spf13/pflag"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apiserver/pkg/server/healthz"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
 	gatewayv1alpha1 "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/typed/apis/v1alpha1"
 
+	haclient "github.com/jcmoraisjr/haproxy-ingress/pkg/api/client"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/k8s"
 )
@@ -49,7 +56,8 @@ func NewIngressController(backend ingress.Controller) *GenericController {
     	the default backend.`)
 
 		ingressClass = flags.String("ingress-class", "",
-			`Name of the IngressClass to route through this controller.`)
+			`Name of the IngressClass to route through this controller. A comma-separated list can be used to
+		listen to more than one class, the first one is also used as a suffix of the leader election IDs.`)
 
 		controllerClass = flags.String("controller-class", "",
 			`Defines an alternative controller name this controller should listen to. If empty, this controller will listen to
@@ -62,6 +70,15 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 
 		watchGateway = flags.Bool("watch-gateway", false, `Watch and parse resources from the Gateway API`)
 
+		watchCRDs = flags.Bool("watch-crds", false,
+			`Watch and parse the HAProxy Ingress custom resources, e.g. TCPService. The CRDs should be
+		installed in the cluster`)
+
+		watchServiceImports = flags.Bool("watch-service-imports", false,
+			`Watch ServiceImport resources from the Multi-Cluster Services API, allowing ingress
+		resources to use them as backends. The MCS CRDs should be installed in the cluster.
+		Implies --enable-endpointslices-api`)
+
 		masterSocket = flags.String("master-socket", "",
 			`Defines the master CLI unix socket of an external HAProxy running in master-worker mode.
 		Defaults to use the embedded HAProxy if not declared.`)
@@ -69,6 +86,11 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		configMap = flags.String("configmap", "",
 			`Name of the ConfigMap that contains the custom configuration to use`)
 
+		globalResource = flags.String("global-resource", "",
+			`Name of the cluster scoped Global custom resource that contains the custom configuration
+		to use. Keys declared in the Global resource have precedence over the same keys declared in
+		the --configmap ConfigMap. Needs --watch-crds`)
+
 		acmeServer = flags.Bool("acme-server", false,
 			`Enables acme server. This server is used to receive and answer challenges from
 		Lets Encrypt or other acme implementations.`)
@@ -107,7 +129,12 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		publishSvc = flags.String("publish-service", "",
 			`Service fronting the ingress controllers. Takes the form
  		namespace/name. The controller will set the endpoint records on the
- 		ingress objects to reflect those on the service.`)
+ 		ingress objects to reflect those on the service. A comma-separated list
+ 		of services can be used, the addresses of all of them are merged.`)
+
+		publishAddress = flags.String("publish-address", "",
+			`Comma-separated list of static IPs and/or hostnames used in the status of the ingress
+		and gateway resources. Can be combined with --publish-service.`)
 
 		tcpConfigMapName = flags.String("tcp-services-configmap", "",
 			`Name of the ConfigMap that contains the definition of the TCP services to expose.
@@ -128,12 +155,56 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 			`Amount of time to wait before start a reconciliation and update haproxy,
 		giving the time to receive all/most of the changes of a batch update.`)
 
+		syncQuietPeriod = flags.Duration("sync-quiet-period", 0,
+			`Amount of time without receiving any change notification before start a
+		reconciliation and update haproxy. Every new notification restarts the quiet
+		period, so a burst of changes is applied in a single reload. Overrides
+		--wait-before-update if greater than zero. Default is 0, disabled.`)
+
+		syncMaxWait = flags.Duration("sync-max-wait", 10*time.Second,
+			`Maximum amount of time to wait for the quiet period, starting from the first
+		pending change notification. Only used if --sync-quiet-period is configured.`)
+
 		resyncPeriod = flags.Duration("sync-period", 600*time.Second,
 			`Relist and confirm cloud resources this often. Default is 10 minutes`)
 
 		watchNamespace = flags.String("watch-namespace", apiv1.NamespaceAll,
 			`Namespace to watch for Ingress. Default is to watch all namespaces`)
 
+		watchNamespaces = flags.String("watch-namespaces", "",
+			`Comma-separated list of namespaces to watch for Ingress. Cannot be used with
+		--watch-namespace. Default is to watch all namespaces`)
+
+		ignoreNamespaces = flags.String("ignore-namespaces", "",
+			`Comma-separated list of namespaces whose resources should not be watched. Cannot be
+		used with --watch-namespace or --watch-namespaces. Default is to not ignore any namespace`)
+
+		shardCount = flags.Int("shard-count", 0,
+			`Number of controller deployments that split the ingress resources by a hash of the
+		namespace name. Every deployment should use the same --shard-count and a distinct
+		--shard-index. Default is 0, sharding by the namespace name is disabled.`)
+
+		shardIndex = flags.Int("shard-index", 0,
+			`Shard managed by this controller deployment, from 0 to --shard-count minus 1.`)
+
+		shardNamespaceSelector = flags.String("shard-namespace-selector", "",
+			`Label selector of the namespaces managed by this controller deployment, e.g.
+		shard=internal. Can be combined with --shard-count. Default is to not filter namespaces by label.`)
+
+		watchIngressSelector = flags.String("watch-ingress-selector", "",
+			`Label selector of the Ingress resources watched by the controller, e.g.
+		ingress-controller=haproxy. Default is to not filter Ingress resources by label.`)
+
+		watchServiceSelector = flags.String("watch-service-selector", "",
+			`Label selector of the Service resources watched by the controller. Services used as
+		backends, default backend or publish service should match the selector. Default is to
+		not filter Service resources by label.`)
+
+		watchSecretSelector = flags.String("watch-secret-selector", "",
+			`Label selector of the Secret resources watched by the controller. Secrets used as
+		certificates, CA, basic auth or acme account key should match the selector. Default
+		is to not filter Secret resources by label.`)
+
 		healthzPort = flags.Int("healthz-port", 10254, "port for healthz endpoint.")
 
 		statsCollectProcPeriod = flags.Duration("stats-collect-processing-period", 500*time.Millisecond,
@@ -141,7 +212,16 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		updates Idle_pct every 500ms, which makes that the best configuration value.
 		Change to 0 (zero) to disable this metric.`)
 
-		profiling = flags.Bool("profiling", true, `Enable profiling via web interface host:port/debug/pprof/`)
+		profiling = flags.Bool("profiling", true, `Enable profiling via web interface host:port/debug/pprof/.
+		Defaults to false if --debug-port is configured.`)
+
+		debugPort = flags.Int("debug-port", 0,
+			`Port of a private listener exposing the pprof profiles in /debug/pprof/ and the expvar
+		variables in /debug/vars. Default value is 0, which disables the listener.`)
+
+		debugBindAddress = flags.String("debug-bind-address", "127.0.0.1",
+			`IP address the --debug-port listener binds to. The default value only accepts local
+		connections, e.g. from kubectl port-forward.`)
 
 		defSSLCertificate = flags.String("default-ssl-certificate", "", `Name of the secret
 		that contains a SSL certificate to be used as default for a HTTPS catch-all server`)
@@ -158,6 +238,19 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 
 		electionID = flags.String("election-id", "ingress-controller-leader", `Election id to use for status update.`)
 
+		electionLock = flags.String("election-lock", resourcelock.ConfigMapsLeasesResourceLock,
+			`Resource used as the lock of the leader elections, the status update and the acme
+		ones. Options are: configmaps, leases, configmapsleases. configmapsleases holds both a ConfigMap
+		and a coordination.k8s.io Lease, and should be used while migrating from configmaps to leases.`)
+
+		electionLeaseDuration = flags.Duration("election-lease-duration", 30*time.Second,
+			`Time the non leader controllers wait before trying to acquire the leadership of an
+		election whose leader stopped renewing it.`)
+
+		electionRenewDeadline = flags.Duration("election-renew-deadline", 15*time.Second,
+			`Time the leader controller tries to renew the leadership before giving it up.
+		Should be lower than --election-lease-duration.`)
+
 		forceIsolation = flags.Bool("force-namespace-isolation", false,
 			`Force namespace isolation. This flag is required to avoid the reference of secrets,
 		configmaps or the default backend service located in a different namespace than the specified
@@ -173,6 +266,11 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		disableNodeList = flags.Bool("disable-node-list", false,
 			`Disable querying nodes. If --force-namespace-isolation is true, this should also be set.`)
 
+		enableEndpointSlicesAPI = flags.Bool("enable-endpointslices-api", false,
+			`Enables EndpointSlices API and disables watching Endpoints objects. EndpointSlices
+		do not truncate services with more than 1000 endpoints, and provide zone and topology
+		hints of the endpoints. Needs Kubernetes 1.21 or newer.`)
+
 		disablePodList = flags.Bool("disable-pod-list", false,
 			`Defines if HAProxy Ingress should disable pod watch and in memory list. Pod list is
 		mandatory for drain-support (should not be disabled) and optional for blue/green.`)
@@ -198,6 +296,11 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		showVersion = flags.Bool("version", false,
 			`Shows release information about the Ingress controller`)
 
+		configFile = flags.String("config", "",
+			`Path to a YAML file with command-line options, an alternative to declare them in the command line.
+		The keys are the option names without the leading dashes, e.g. watch-namespace: default. Options declared
+		in the command line have precedence over the ones declared in the file.`)
+
 		ignoreIngressWithoutClass = flags.Bool("ignore-ingress-without-class", false,
 			`DEPRECATED, this option is ignored. Use --watch-ingress-without-class command-line option instead to define
 		if ingress without class should be tracked.`)
@@ -206,6 +309,11 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 	flags.AddGoFlagSet(flag.CommandLine)
 	backend.ConfigureFlags(flags)
 	flags.Parse(os.Args)
+	if *configFile != "" {
+		if err := applyConfigFile(flags, *configFile); err != nil {
+			glog.Fatalf("error reading config file %s: %v", *configFile, err)
+		}
+	}
 	// Workaround for this issue:
 	// https://github.com/kubernetes/kubernetes/issues/17162
 	flag.CommandLine.Parse([]string{})
@@ -221,8 +329,10 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 
 	glog.Info(backend.Info())
 
-	if *ingressClass != "" {
-		glog.Infof("watching for ingress resources with 'kubernetes.io/ingress.class' annotation: %s", *ingressClass)
+	ingressClasses := splitList(*ingressClass)
+	if len(ingressClasses) > 0 {
+		*ingressClass = ingressClasses[0]
+		glog.Infof("watching for ingress resources with 'kubernetes.io/ingress.class' annotation: %s", strings.Join(ingressClasses, ", "))
 	}
 
 	controllerName := "haproxy-ingress.github.io/controller"
@@ -245,6 +355,22 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		glog.Infof("watching for Gateway API resources - --watch-gateway is true")
 	}
 
+	if *watchCRDs {
+		glog.Infof("watching for HAProxy Ingress custom resources - --watch-crds is true")
+	}
+
+	if *globalResource != "" && !*watchCRDs {
+		glog.Fatalf("--global-resource needs --watch-crds")
+	}
+
+	if *watchServiceImports {
+		glog.Infof("watching for Multi-Cluster Services ServiceImport resources - --watch-service-imports is true")
+		if !*enableEndpointSlicesAPI {
+			glog.Infof("enabling EndpointSlices API, used to read the endpoints of the imported services")
+			*enableEndpointSlicesAPI = true
+		}
+	}
+
 	kubeClient, err := createApiserverClient(*apiserverHost, *kubeConfigFile, *disableAPIWarnings)
 	if err != nil {
 		handleFatalInitError(err)
@@ -268,33 +394,60 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		glog.Infof("validated %v as the default backend", *defaultSvc)
 	}
 
-	if *publishSvc != "" {
-		ns, name, err := k8s.ParseNameNS(*publishSvc)
+	publishServices := splitList(*publishSvc)
+	for _, publishService := range publishServices {
+		ns, name, err := k8s.ParseNameNS(publishService)
 		if err != nil {
 			glog.Fatalf("invalid service format: %v", err)
 		}
 
 		svc, err := kubeClient.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
 		if err != nil {
-			glog.Fatalf("unexpected error getting information about service %v: %v", *publishSvc, err)
+			glog.Fatalf("unexpected error getting information about service %v: %v", publishService, err)
 		}
 
 		if len(svc.Status.LoadBalancer.Ingress) == 0 {
 			if len(svc.Spec.ExternalIPs) > 0 {
-				glog.Infof("service %v validated as assigned with externalIP", *publishSvc)
+				glog.Infof("service %v validated as assigned with externalIP", publishService)
+			} else if svc.Spec.Type == apiv1.ServiceTypeNodePort {
+				glog.Infof("service %v validated as NodePort, using the addresses of the nodes of its pods", publishService)
 			} else {
 				// We could poll here, but we instead just exit and rely on k8s to restart us
-				glog.Fatalf("service %s does not (yet) have ingress points", *publishSvc)
+				glog.Fatalf("service %s does not (yet) have ingress points", publishService)
 			}
 		} else {
-			glog.Infof("service %v validated as source of Ingress status", *publishSvc)
+			glog.Infof("service %v validated as source of Ingress status", publishService)
 		}
 	}
 
+	publishAddresses := splitList(*publishAddress)
+	if len(publishAddresses) > 0 {
+		glog.Infof("using static addresses in the status of Ingress: %s", strings.Join(publishAddresses, ", "))
+	}
+
+	watchNamespaceList := splitList(*watchNamespaces)
 	if *watchNamespace != "" {
-		_, err = kubeClient.NetworkingV1().Ingresses(*watchNamespace).List(ctx, metav1.ListOptions{Limit: 1})
-		if err != nil {
-			glog.Fatalf("no watchNamespace with name %v found: %v", *watchNamespace, err)
+		if len(watchNamespaceList) > 0 {
+			glog.Fatalf("--watch-namespace and --watch-namespaces cannot be used together")
+		}
+		watchNamespaceList = []string{*watchNamespace}
+	}
+	ignoreNamespaceList := splitList(*ignoreNamespaces)
+	if len(ignoreNamespaceList) > 0 {
+		if len(watchNamespaceList) > 0 {
+			glog.Fatalf("--ignore-namespaces cannot be used with --watch-namespace or --watch-namespaces")
+		}
+		glog.Infof("ignoring resources of the namespaces: %s", strings.Join(ignoreNamespaceList, ", "))
+	}
+	if len(watchNamespaceList) > 0 {
+		for _, ns := range watchNamespaceList {
+			_, err = kubeClient.NetworkingV1().Ingresses(ns).List(ctx, metav1.ListOptions{Limit: 1})
+			if err != nil {
+				glog.Fatalf("no watchNamespace with name %v found: %v", ns, err)
+			}
+		}
+		if len(watchNamespaceList) > 1 {
+			glog.Infof("watching resources of the namespaces: %s", strings.Join(watchNamespaceList, ", "))
 		}
 	} else {
 		_, err = kubeClient.CoreV1().Services("default").Get(ctx, "kubernetes", metav1.GetOptions{})
@@ -316,6 +469,10 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		glog.Fatalf("rate limit update is too high: up to %v Ingress reloads per second (max is 10)", *rateLimitUpdate)
 	}
 
+	if *syncQuietPeriod > 0 && *syncMaxWait < *syncQuietPeriod {
+		glog.Fatalf("sync max wait (%v) must not be lower than sync quiet period (%v)", *syncMaxWait, *syncQuietPeriod)
+	}
+
 	if resyncPeriod.Seconds() < 10 {
 		glog.Fatalf("resync period (%vs) is too low", resyncPeriod.Seconds())
 	}
@@ -336,6 +493,60 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		glog.Fatal("Cannot use --allow-cross-namespace if --force-namespace-isolation is true")
 	}
 
+	if *shardCount < 0 {
+		glog.Fatalf("--shard-count should not be negative: %d", *shardCount)
+	}
+	if *shardCount > 1 {
+		if *shardIndex < 0 || *shardIndex >= *shardCount {
+			glog.Fatalf("--shard-index should be between 0 and %d: %d", *shardCount-1, *shardIndex)
+		}
+		glog.Infof("managing namespaces of shard %d, from %d shards", *shardIndex, *shardCount)
+	}
+	var shardSelector labels.Selector
+	if *shardNamespaceSelector != "" {
+		shardSelector, err = labels.Parse(*shardNamespaceSelector)
+		if err != nil {
+			glog.Fatalf("invalid --shard-namespace-selector: %v", err)
+		}
+		glog.Infof("managing namespaces whose labels match: %s", shardSelector.String())
+	}
+
+	parseWatchSelector := func(name, kind, value string) labels.Selector {
+		if value == "" {
+			return nil
+		}
+		selector, err := labels.Parse(value)
+		if err != nil {
+			glog.Fatalf("invalid --%s: %v", name, err)
+		}
+		glog.Infof("watching %s resources whose labels match: %s", kind, selector.String())
+		return selector
+	}
+	ingressSelector := parseWatchSelector("watch-ingress-selector", "Ingress", *watchIngressSelector)
+	serviceSelector := parseWatchSelector("watch-service-selector", "Service", *watchServiceSelector)
+	secretSelector := parseWatchSelector("watch-secret-selector", "Secret", *watchSecretSelector)
+
+	if *debugPort < 0 || *debugPort > 65535 {
+		glog.Fatalf("--debug-port should be between 0 and 65535: %d", *debugPort)
+	}
+	if *debugPort > 0 && net.ParseIP(*debugBindAddress) == nil {
+		glog.Fatalf("invalid --debug-bind-address, should be an IP address: %s", *debugBindAddress)
+	}
+	if *debugPort > 0 && !flags.Changed("profiling") {
+		// profiles are served by the private listener, so they
+		// aren't exposed in the healthz port unless asked for
+		*profiling = false
+	}
+
+	switch *electionLock {
+	case resourcelock.ConfigMapsResourceLock, resourcelock.LeasesResourceLock, resourcelock.ConfigMapsLeasesResourceLock:
+	default:
+		glog.Fatalf("unsupported --election-lock option: %s", *electionLock)
+	}
+	if *electionRenewDeadline <= 0 || *electionLeaseDuration <= *electionRenewDeadline {
+		glog.Fatalf("--election-renew-deadline (%v) should be greater than zero and lower than --election-lease-duration (%v)", *electionRenewDeadline, *electionLeaseDuration)
+	}
+
 	var annPrefixList []string
 	for _, prefix := range strings.Split(*annPrefix, ",") {
 		prefix = strings.TrimSpace(prefix)
@@ -368,6 +579,9 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 	config := &Configuration{
 		UpdateStatus:             *updateStatus,
 		ElectionID:               *electionID,
+		ElectionLock:             *electionLock,
+		ElectionLeaseDuration:    *electionLeaseDuration,
+		ElectionRenewDeadline:    *electionRenewDeadline,
 		Client:                   kubeClient,
 		MasterSocket:             *masterSocket,
 		AcmeServer:               *acmeServer,
@@ -382,26 +596,42 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		RateLimitUpdate:          *rateLimitUpdate,
 		ResyncPeriod:             *resyncPeriod,
 		WaitBeforeUpdate:         *waitBeforeUpdate,
+		SyncQuietPeriod:          *syncQuietPeriod,
+		SyncMaxWait:              *syncMaxWait,
 		DefaultService:           *defaultSvc,
 		IngressClass:             *ingressClass,
+		IngressClasses:           ingressClasses,
 		ControllerName:           controllerName,
 		WatchIngressWithoutClass: *watchIngressWithoutClass,
 		WatchGateway:             *watchGateway,
+		WatchCRDs:                *watchCRDs,
+		WatchServiceImports:      *watchServiceImports,
 		WatchNamespace:           *watchNamespace,
+		WatchNamespaces:          watchNamespaceList,
+		IgnoreNamespaces:         ignoreNamespaceList,
+		ShardCount:               *shardCount,
+		ShardIndex:               *shardIndex,
+		ShardNamespaceSelector:   shardSelector,
+		WatchIngressSelector:     ingressSelector,
+		WatchServiceSelector:     serviceSelector,
+		WatchSecretSelector:      secretSelector,
 		ConfigMapName:            *configMap,
+		GlobalResource:           *globalResource,
 		TCPConfigMapName:         *tcpConfigMapName,
 		AnnPrefix:                annPrefixList,
 		DefaultSSLCertificate:    *defSSLCertificate,
 		VerifyHostname:           *verifyHostname,
 		DefaultHealthzURL:        *defHealthzURL,
 		StatsCollectProcPeriod:   *statsCollectProcPeriod,
-		PublishService:           *publishSvc,
+		PublishServices:          publishServices,
+		PublishAddresses:         publishAddresses,
 		Backend:                  backend,
 		ForceNamespaceIsolation:  *forceIsolation,
 		WaitBeforeShutdown:       *waitBeforeShutdown,
 		AllowCrossNamespace:      *allowCrossNamespace,
 		DisableNodeList:          *disableNodeList,
 		DisablePodList:           *disablePodList,
+		EnableEndpointSlicesAPI:  *enableEndpointSlicesAPI,
 		UpdateStatusOnShutdown:   *updateStatusOnShutdown,
 		BackendShards:            *backendShards,
 		SortEndpointsBy:          sortEndpoints,
@@ -410,17 +640,47 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 
 	ic := newIngressController(config)
 	go registerHandlers(*profiling, *healthzPort, ic)
+	if *debugPort > 0 {
+		go registerDebugHandlers(*debugBindAddress, *debugPort)
+	}
 	return ic
 }
 
 func registerHandlers(enableProfiling bool, port int, ic *GenericController) {
 	mux := http.NewServeMux()
 	// expose health check endpoint (/healthz)
-	healthz.InstallPathHandler(mux,
+	healthzMux := http.NewServeMux()
+	healthz.InstallPathHandler(healthzMux,
 		ic.cfg.DefaultHealthzURL,
 		healthz.PingHealthz,
 		ic.cfg.Backend,
 	)
+	mux.Handle(ic.cfg.DefaultHealthzURL+"/", healthzMux)
+	mux.HandleFunc(ic.cfg.DefaultHealthzURL, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "json" {
+			healthzMux.ServeHTTP(w, r)
+			return
+		}
+		// the same checks, also reporting the leadership state
+		status := struct {
+			Status         string                 `json:"status"`
+			Error          string                 `json:"error,omitempty"`
+			LeaderElection []leaderElectionStatus `json:"leaderElection"`
+		}{
+			Status:         "ok",
+			LeaderElection: leaderElections(),
+		}
+		code := http.StatusOK
+		if err := ic.cfg.Backend.Check(r); err != nil {
+			status.Status = "failed"
+			status.Error = err.Error()
+			code = http.StatusInternalServerError
+		}
+		b, _ := json.Marshal(status)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		w.Write(b)
+	})
 
 	mux.Handle("/metrics", promhttp.Handler())
 
@@ -445,6 +705,43 @@ func registerHandlers(enableProfiling bool, port int, ic *GenericController) {
 		w.Write([]byte(out))
 	})
 
+	mux.HandleFunc("/dry-run", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		result, err := ic.cfg.Backend.DryRun()
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(fmt.Sprintf("Error running the dry run: %v.\n", err)))
+			return
+		}
+		code := http.StatusOK
+		if !result.Valid {
+			code = http.StatusUnprocessableEntity
+		}
+		if r.URL.Query().Get("format") == "json" {
+			b, _ := json.Marshal(result)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			w.Write(b)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(code)
+		w.Write([]byte(result.String()))
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := ic.cfg.Backend.Ready(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(fmt.Sprintf("not ready: %v\n", err)))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
 	mux.HandleFunc("/build", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		b, _ := json.Marshal(ic.Info())
@@ -472,6 +769,34 @@ func registerHandlers(enableProfiling bool, port int, ic *GenericController) {
 	glog.Fatal(server.ListenAndServe())
 }
 
+// registerDebugHandlers exposes the runtime debug endpoints in a private
+// listener, so they can be used without exposing them in the healthz port.
+func registerDebugHandlers(address string, port int) {
+	server := &http.Server{
+		Addr:    net.JoinHostPort(address, strconv.Itoa(port)),
+		Handler: newDebugMux(),
+	}
+	glog.Infof("listening debug endpoints on %s", server.Addr)
+	glog.Fatal(server.ListenAndServe())
+}
+
+func newDebugMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+}
+
 const (
 	// High enough QPS to fit all expected use cases. QPS=0 is not set here, because
 	// client code is overriding it.
@@ -504,7 +829,8 @@ func buildConfigFromFlags(masterURL, kubeconfigPath string) (*rest.Config, error
 
 type client struct {
 	*kubernetes.Clientset
-	gateway *versioned.Clientset
+	gateway        *versioned.Clientset
+	haproxyingress *haclient.Clientset
 }
 
 // TODO is there a way to transparently embed k8s and crd clientsets into the outer struct?
@@ -512,6 +838,14 @@ func (c *client) NetworkingV1alpha1() gatewayv1alpha1.NetworkingV1alpha1Interfac
 	return c.gateway.NetworkingV1alpha1()
 }
 
+func (c *client) HAProxyIngressV1alpha1() haclient.HAProxyIngressV1alpha1Interface {
+	return c.haproxyingress.HAProxyIngressV1alpha1()
+}
+
+func (c *client) MulticlusterV1alpha1() haclient.MulticlusterV1alpha1Interface {
+	return c.haproxyingress.MulticlusterV1alpha1()
+}
+
 // createApiserverClient creates new Kubernetes Apiserver client. When kubeconfig or apiserverHost param is empty
 // the function assumes that it is running inside a Kubernetes cluster and attempts to
 // discover the Apiserver. Otherwise, it connects to the Apiserver specified.
@@ -542,6 +876,10 @@ func createApiserverClient(apiserverHost string, kubeConfig string, disableWarni
 	if err != nil {
 		return nil, err
 	}
+	haproxyingress, err := haclient.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	v, err := k8s.Discovery().ServerVersion()
 	if err != nil {
@@ -552,8 +890,9 @@ func createApiserverClient(apiserverHost string, kubeConfig string, disableWarni
 		v.Major, v.Minor, v.GitVersion, v.GitTreeState, v.GitCommit, v.Platform)
 
 	return &client{
-		Clientset: k8s,
-		gateway:   gateway,
+		Clientset:      k8s,
+		gateway:        gateway,
+		haproxyingress: haproxyingress,
 	}, nil
 }
 
@@ -566,3 +905,15 @@ func handleFatalInitError(err error) {
 		"This most likely means that the cluster is misconfigured (e.g., it has "+
 		"invalid apiserver certificates or service accounts configuration). Reason: %s", err)
 }
+
+// splitList splits a comma-separated command-line option, ignoring empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
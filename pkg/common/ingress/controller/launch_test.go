@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugMux(t *testing.T) {
+	testCases := []struct {
+		url     string
+		expCode int
+	}{
+		// 0
+		{
+			url:     "/debug/pprof/",
+			expCode: http.StatusOK,
+		},
+		// 1
+		{
+			url:     "/debug/pprof/cmdline",
+			expCode: http.StatusOK,
+		},
+		// 2
+		{
+			url:     "/debug/vars",
+			expCode: http.StatusOK,
+		},
+		// 3
+		{
+			url:     "/healthz",
+			expCode: http.StatusNotFound,
+		},
+		// 4
+		{
+			url:     "/metrics",
+			expCode: http.StatusNotFound,
+		},
+	}
+	mux := newDebugMux()
+	for i, test := range testCases {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.url, nil))
+		if w.Code != test.expCode {
+			t.Errorf("status code differs on %d - expected: %d - actual: %d", i, test.expCode, w.Code)
+		}
+	}
+}
+
+func TestDebugVarsGoroutines(t *testing.T) {
+	w := httptest.NewRecorder()
+	newDebugMux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	var vars map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("error decoding vars: %v", err)
+	}
+	goroutines, ok := vars["goroutines"].(float64)
+	if !ok || goroutines < 1 {
+		t.Errorf("expected a positive number of goroutines, found: %v", vars["goroutines"])
+	}
+	if _, ok := vars["memstats"]; !ok {
+		t.Errorf("expected memstats in the debug vars")
+	}
+}
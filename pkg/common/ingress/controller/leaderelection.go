@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+)
+
+var leaderGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: "haproxyingress",
+		Name:      "leader_election_is_leader",
+		Help:      "Whether this controller instance is the leader of an election, e.g. the status update or the acme one.",
+	},
+	[]string{"election"},
+)
+
+func init() {
+	prometheus.MustRegister(leaderGauge)
+}
+
+var (
+	electorsMutex sync.Mutex
+	electors      = map[string]*leaderelection.LeaderElector{}
+)
+
+// leaderElectionStatus is the leadership state of an election, added in
+// the JSON payload of the healthz endpoint
+type leaderElectionStatus struct {
+	ID       string `json:"id"`
+	Leader   string `json:"leader"`
+	IsLeader bool   `json:"isLeader"`
+}
+
+// NewLeaderElector creates the leader elector of an election, using the lock
+// and timings of the --election-* command-line options. The leadership state
+// is exported as a metric and in the JSON payload of the healthz endpoint.
+func NewLeaderElector(cfg *Configuration, id, namespace, identity, component string, callbacks leaderelection.LeaderCallbacks) (*leaderelection.LeaderElector, error) {
+	hostname, _ := os.Hostname()
+	lock, err := resourcelock.New(cfg.ElectionLock, namespace, id, cfg.Client.CoreV1(), cfg.Client.CoordinationV1(), resourcelock.ResourceLockConfig{
+		Identity: identity,
+		EventRecorder: record.NewBroadcaster().NewRecorder(scheme.Scheme, apiv1.EventSource{
+			Component: component,
+			Host:      hostname,
+		}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	gauge := leaderGauge.WithLabelValues(id)
+	gauge.Set(0)
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: cfg.ElectionLeaseDuration,
+		RenewDeadline: cfg.ElectionRenewDeadline,
+		RetryPeriod:   cfg.ElectionRenewDeadline / 2,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				gauge.Set(1)
+				if callbacks.OnStartedLeading != nil {
+					callbacks.OnStartedLeading(ctx)
+				}
+			},
+			OnStoppedLeading: func() {
+				gauge.Set(0)
+				if callbacks.OnStoppedLeading != nil {
+					callbacks.OnStoppedLeading()
+				}
+			},
+			OnNewLeader: callbacks.OnNewLeader,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	electorsMutex.Lock()
+	electors[id] = le
+	electorsMutex.Unlock()
+	return le, nil
+}
+
+// leaderElections returns the leadership state of the elections of this
+// controller instance, sorted by the election id
+func leaderElections() []leaderElectionStatus {
+	electorsMutex.Lock()
+	defer electorsMutex.Unlock()
+	status := make([]leaderElectionStatus, 0, len(electors))
+	for id, le := range electors {
+		status = append(status, leaderElectionStatus{
+			ID:       id,
+			Leader:   le.GetLeader(),
+			IsLeader: le.IsLeader(),
+		})
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].ID < status[j].ID
+	})
+	return status
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	gatewayv1alpha1 "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/typed/apis/v1alpha1"
+
+	haclient "github.com/jcmoraisjr/haproxy-ingress/pkg/api/client"
+)
+
+// fakeClient only implements the Kubernetes API
+type fakeClient struct {
+	*k8sfake.Clientset
+}
+
+func (c *fakeClient) NetworkingV1alpha1() gatewayv1alpha1.NetworkingV1alpha1Interface {
+	return nil
+}
+
+func (c *fakeClient) HAProxyIngressV1alpha1() haclient.HAProxyIngressV1alpha1Interface {
+	return nil
+}
+
+func (c *fakeClient) MulticlusterV1alpha1() haclient.MulticlusterV1alpha1Interface {
+	return nil
+}
+
+func TestNewLeaderElector(t *testing.T) {
+	client := &fakeClient{Clientset: k8sfake.NewSimpleClientset()}
+	cfg := &Configuration{
+		Client:                client,
+		ElectionLock:          resourcelock.LeasesResourceLock,
+		ElectionLeaseDuration: 2 * time.Second,
+		ElectionRenewDeadline: time.Second,
+	}
+	started := make(chan struct{})
+	le, err := NewLeaderElector(cfg, "election-test", "ingress", "haproxy-ingress-1", "test", leaderelection.LeaderCallbacks{
+		OnStartedLeading: func(context.Context) {
+			close(started)
+		},
+	})
+	if err != nil {
+		t.Fatalf("error creating leader elector: %v", err)
+	}
+	gauge := leaderGauge.WithLabelValues("election-test")
+	if leader := testutil.ToFloat64(gauge); leader != 0 {
+		t.Errorf("expected metric 0 before the election but was %v", leader)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go le.Run(ctx)
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting the leadership")
+	}
+
+	if leader := testutil.ToFloat64(gauge); leader != 1 {
+		t.Errorf("expected metric 1 on the leader but was %v", leader)
+	}
+	if _, err := client.CoordinationV1().Leases("ingress").Get(ctx, "election-test", metav1.GetOptions{}); err != nil {
+		t.Errorf("error reading the lease: %v", err)
+	}
+	status := leaderElections()
+	expected := leaderElectionStatus{ID: "election-test", Leader: "haproxy-ingress-1", IsLeader: true}
+	if len(status) != 1 || status[0] != expected {
+		t.Errorf("expected %+v but was %+v", []leaderElectionStatus{expected}, status)
+	}
+}
@@ -20,7 +20,6 @@ import (
 	"context"
 	"fmt"
 	"net"
-	"os"
 	"sort"
 	"strings"
 	"time"
@@ -35,13 +34,12 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
 	clientset "k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/leaderelection"
-	"k8s.io/client-go/tools/leaderelection/resourcelock"
-	"k8s.io/client-go/tools/record"
+	gateway "sigs.k8s.io/gateway-api/apis/v1alpha1"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/k8s"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/task"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 )
 
 const (
@@ -178,32 +176,7 @@ func NewStatusSyncer(ic *GenericController) StatusSync {
 		},
 	}
 
-	broadcaster := record.NewBroadcaster()
-	hostname, _ := os.Hostname()
-
-	recorder := broadcaster.NewRecorder(scheme.Scheme, apiv1.EventSource{
-		Component: "ingress-leader-elector",
-		Host:      hostname,
-	})
-
-	lock := resourcelock.ConfigMapLock{
-		ConfigMapMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: electionID},
-		Client:        ic.cfg.Client.CoreV1(),
-		LockConfig: resourcelock.ResourceLockConfig{
-			Identity:      pod.Name,
-			EventRecorder: recorder,
-		},
-	}
-
-	ttl := 30 * time.Second
-	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
-		Lock:          &lock,
-		LeaseDuration: ttl,
-		RenewDeadline: ttl / 2,
-		RetryPeriod:   ttl / 4,
-		Callbacks:     callbacks,
-	})
-
+	le, err := NewLeaderElector(ic.cfg, electionID, pod.Namespace, pod.Name, "ingress-leader-elector", callbacks)
 	if err != nil {
 		glog.Fatalf("unexpected error starting leader election: %v", err)
 	}
@@ -213,27 +186,24 @@ func NewStatusSyncer(ic *GenericController) StatusSync {
 }
 
 // runningAddresses returns a list of IP addresses and/or FQDN where the
-// ingress controller is currently running
+// ingress controller is currently running. Static addresses and the ones
+// of the publish services are merged, the addresses of the nodes running
+// the controller pods are used if none of them is configured.
 func (s *statusSync) runningAddresses() ([]string, error) {
-	if s.ic.cfg.PublishService != "" {
-		ns, name, _ := k8s.ParseNameNS(s.ic.cfg.PublishService)
-		svc, err := s.ic.cfg.Client.CoreV1().Services(ns).Get(s.ctx, name, metav1.GetOptions{})
+	addrs := []string{}
+	for _, addr := range s.ic.cfg.PublishAddresses {
+		addrs = appendAddress(addrs, addr)
+	}
+	for _, publishService := range s.ic.cfg.PublishServices {
+		svcAddrs, err := s.serviceAddresses(publishService)
 		if err != nil {
 			return nil, err
 		}
-
-		addrs := []string{}
-		for _, ip := range svc.Status.LoadBalancer.Ingress {
-			if ip.IP == "" {
-				addrs = append(addrs, ip.Hostname)
-			} else {
-				addrs = append(addrs, ip.IP)
-			}
+		for _, addr := range svcAddrs {
+			addrs = appendAddress(addrs, addr)
 		}
-		for _, ip := range svc.Spec.ExternalIPs {
-			addrs = append(addrs, ip)
-		}
-
+	}
+	if len(s.ic.cfg.PublishAddresses) > 0 || len(s.ic.cfg.PublishServices) > 0 {
 		return addrs, nil
 	}
 
@@ -244,17 +214,68 @@ func (s *statusSync) runningAddresses() ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	return s.nodeAddresses(pods.Items), nil
+}
+
+// serviceAddresses returns the load balancer and external IPs of a service.
+// NodePort services without any of them use the addresses of the nodes
+// running the pods selected by the service.
+func (s *statusSync) serviceAddresses(serviceName string) ([]string, error) {
+	ns, name, err := k8s.ParseNameNS(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := s.ic.cfg.Client.CoreV1().Services(ns).Get(s.ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
 
 	addrs := []string{}
-	for _, pod := range pods.Items {
-		name := k8s.GetNodeIP(s.ic.cfg.Client, pod.Spec.NodeName, s.ic.cfg.UseNodeInternalIP)
-		if !stringInSlice(name, addrs) {
-			addrs = append(addrs, name)
+	for _, ip := range svc.Status.LoadBalancer.Ingress {
+		if ip.IP == "" {
+			addrs = append(addrs, ip.Hostname)
+		} else {
+			addrs = append(addrs, ip.IP)
 		}
 	}
+	for _, ip := range svc.Spec.ExternalIPs {
+		addrs = append(addrs, ip)
+	}
+
+	if len(addrs) == 0 && svc.Spec.Type == apiv1.ServiceTypeNodePort && len(svc.Spec.Selector) > 0 {
+		pods, err := s.ic.cfg.Client.CoreV1().Pods(ns).List(s.ctx, metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		addrs = s.nodeAddresses(pods.Items)
+	}
 	return addrs, nil
 }
 
+// nodeAddresses returns the addresses of the nodes running a list of pods.
+// Pods using hostNetwork fall back to the host IP reported in the pod status
+// if the node does not have an address of the configured type.
+func (s *statusSync) nodeAddresses(pods []apiv1.Pod) []string {
+	addrs := []string{}
+	for _, pod := range pods {
+		addr := k8s.GetNodeIP(s.ic.cfg.Client, pod.Spec.NodeName, s.ic.cfg.UseNodeInternalIP)
+		if addr == "" && pod.Spec.HostNetwork {
+			addr = pod.Status.HostIP
+		}
+		addrs = appendAddress(addrs, addr)
+	}
+	return addrs
+}
+
+func appendAddress(addrs []string, addr string) []string {
+	if addr == "" || stringInSlice(addr, addrs) {
+		return addrs
+	}
+	return append(addrs, addr)
+}
+
 func (s *statusSync) isRunningMultiplePods() bool {
 	pods, err := s.ic.cfg.Client.CoreV1().Pods(s.pod.Namespace).List(s.ctx, metav1.ListOptions{
 		LabelSelector: labels.SelectorFromSet(s.pod.Labels).String(),
@@ -293,7 +314,7 @@ func sliceToStatus(endpoints []string) []apiv1.LoadBalancerIngress {
 	return lbi
 }
 
-// updateStatus changes the status information of Ingress rules
+// updateStatus changes the status information of Ingress rules and Gateways
 // If the backend function CustomIngressStatus returns a value different
 // of nil then it uses the returned value or the newIngressPoint values
 func (s *statusSync) updateStatus(newIngressPoint []apiv1.LoadBalancerIngress) error {
@@ -321,6 +342,15 @@ func (s *statusSync) updateStatus(newIngressPoint []apiv1.LoadBalancerIngress) e
 		batch.Queue(runUpdate(s.ctx, ing, newIngressPoint, s.ic.cfg.Client, callback))
 	}
 
+	gws, err := s.ic.newctrl.GetGatewayList()
+	if err != nil {
+		glog.Warningf("error reading Gateway list: %v", err)
+	}
+	gwAddrs := statusToGatewayAddresses(newIngressPoint)
+	for _, gw := range gws {
+		batch.Queue(runGatewayUpdate(s.ctx, gw, gwAddrs, s.ic.cfg.Client))
+	}
+
 	batch.QueueComplete()
 	batch.WaitAll()
 
@@ -368,6 +398,85 @@ func runUpdate(ctx context.Context, ing *networking.Ingress, status []apiv1.Load
 	}
 }
 
+func runGatewayUpdate(ctx context.Context, gw *gateway.Gateway, addrs []gateway.GatewayAddress,
+	client types.Client) pool.WorkFunc {
+	return func(wu pool.WorkUnit) (interface{}, error) {
+		if wu.IsCancelled() {
+			return nil, nil
+		}
+
+		curAddrs := append([]gateway.GatewayAddress{}, gw.Status.Addresses...)
+		sort.SliceStable(curAddrs, lessGatewayAddress(curAddrs))
+
+		if gatewayAddressesEqual(addrs, curAddrs) {
+			glog.V(3).Infof("skipping update of Gateway %v/%v (no change)", gw.Namespace, gw.Name)
+			return true, nil
+		}
+
+		gwClient := client.NetworkingV1alpha1().Gateways(gw.Namespace)
+
+		currGw, err := gwClient.Get(ctx, gw.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("unexpected error searching Gateway %v/%v", gw.Namespace, gw.Name))
+		}
+
+		glog.Infof("updating Gateway %v/%v status to %v", currGw.Namespace, currGw.Name, addrs)
+		currGw.Status.Addresses = addrs
+		_, err = gwClient.UpdateStatus(ctx, currGw, metav1.UpdateOptions{})
+		if err != nil {
+			glog.Warningf("error updating gateway: %v", err)
+		}
+
+		return true, nil
+	}
+}
+
+// statusToGatewayAddresses converts the status of Ingress rules to the
+// sorted addresses of a Gateway status
+func statusToGatewayAddresses(lbi []apiv1.LoadBalancerIngress) []gateway.GatewayAddress {
+	addrs := []gateway.GatewayAddress{}
+	for _, ing := range lbi {
+		addrType := gateway.IPAddressType
+		value := ing.IP
+		if value == "" {
+			addrType = gateway.NamedAddressType
+			value = ing.Hostname
+		}
+		addrs = append(addrs, gateway.GatewayAddress{Type: &addrType, Value: value})
+	}
+	sort.SliceStable(addrs, lessGatewayAddress(addrs))
+	return addrs
+}
+
+func lessGatewayAddress(addrs []gateway.GatewayAddress) func(int, int) bool {
+	return func(a, b int) bool {
+		return addrs[a].Value < addrs[b].Value
+	}
+}
+
+func gatewayAddressesEqual(lhs, rhs []gateway.GatewayAddress) bool {
+	if len(lhs) != len(rhs) {
+		return false
+	}
+	for i := range lhs {
+		if lhs[i].Value != rhs[i].Value {
+			return false
+		}
+		if gatewayAddressType(lhs[i]) != gatewayAddressType(rhs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func gatewayAddressType(addr gateway.GatewayAddress) gateway.AddressType {
+	if addr.Type == nil {
+		// the API's default
+		return gateway.IPAddressType
+	}
+	return *addr.Type
+}
+
 func lessLoadBalancerIngress(addrs []apiv1.LoadBalancerIngress) func(int, int) bool {
 	return func(a, b int) bool {
 		switch strings.Compare(addrs[a].Hostname, addrs[b].Hostname) {
@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	gateway "sigs.k8s.io/gateway-api/apis/v1alpha1"
+)
+
+func TestSplitList(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected []string
+	}{
+		// 0
+		{
+			value: "",
+		},
+		// 1
+		{
+			value:    "ingress/haproxy",
+			expected: []string{"ingress/haproxy"},
+		},
+		// 2
+		{
+			value:    " ingress/haproxy-lb, ,ingress/haproxy-np ,",
+			expected: []string{"ingress/haproxy-lb", "ingress/haproxy-np"},
+		},
+	}
+	for i, test := range testCases {
+		if actual := splitList(test.value); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%d: expected %v but was %v", i, test.expected, actual)
+		}
+	}
+}
+
+func TestStatusToGatewayAddresses(t *testing.T) {
+	ipType := gateway.IPAddressType
+	namedType := gateway.NamedAddressType
+	testCases := []struct {
+		status   []apiv1.LoadBalancerIngress
+		expected []gateway.GatewayAddress
+	}{
+		// 0
+		{
+			status:   []apiv1.LoadBalancerIngress{},
+			expected: []gateway.GatewayAddress{},
+		},
+		// 1
+		{
+			status: []apiv1.LoadBalancerIngress{
+				{IP: "192.168.0.11"},
+				{Hostname: "lb.local"},
+				{IP: "10.0.0.1"},
+			},
+			expected: []gateway.GatewayAddress{
+				{Type: &ipType, Value: "10.0.0.1"},
+				{Type: &ipType, Value: "192.168.0.11"},
+				{Type: &namedType, Value: "lb.local"},
+			},
+		},
+	}
+	for i, test := range testCases {
+		actual := statusToGatewayAddresses(test.status)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%d: expected %v but was %v", i, test.expected, actual)
+		}
+		if !gatewayAddressesEqual(actual, test.expected) {
+			t.Errorf("%d: expected equal addresses", i)
+		}
+	}
+}
+
+func TestGatewayAddressesEqual(t *testing.T) {
+	ipType := gateway.IPAddressType
+	namedType := gateway.NamedAddressType
+	addrs := func(addrs ...gateway.GatewayAddress) []gateway.GatewayAddress {
+		return addrs
+	}
+	testCases := []struct {
+		lhs, rhs []gateway.GatewayAddress
+		expected bool
+	}{
+		// 0
+		{
+			expected: true,
+		},
+		// 1
+		{
+			lhs:      addrs(gateway.GatewayAddress{Value: "10.0.0.1"}),
+			rhs:      addrs(gateway.GatewayAddress{Type: &ipType, Value: "10.0.0.1"}),
+			expected: true,
+		},
+		// 2
+		{
+			lhs:      addrs(gateway.GatewayAddress{Value: "lb.local"}),
+			rhs:      addrs(gateway.GatewayAddress{Type: &namedType, Value: "lb.local"}),
+			expected: false,
+		},
+		// 3
+		{
+			lhs:      addrs(gateway.GatewayAddress{Type: &ipType, Value: "10.0.0.1"}),
+			rhs:      addrs(),
+			expected: false,
+		},
+	}
+	for i, test := range testCases {
+		if actual := gatewayAddressesEqual(test.lhs, test.rhs); actual != test.expected {
+			t.Errorf("%d: expected %t but was %t", i, test.expected, actual)
+		}
+	}
+}
@@ -18,6 +18,9 @@ package ingress
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 	apiv1 "k8s.io/api/core/v1"
@@ -45,6 +48,12 @@ type Controller interface {
 	Info() *BackendInfo
 	// AcmeCheck starts a certificate missing/expiring/outdated check
 	AcmeCheck() (int, error)
+	// DryRun renders and validates the configuration without changing
+	// the running haproxy
+	DryRun() (*DryRunResult, error)
+	// Ready returns an error if the controller should not receive traffic
+	// yet, e.g. haproxy was not successfully updated since the start
+	Ready() error
 	// ConfigureFlags allow to configure more flags before the parsing of
 	// command line arguments
 	ConfigureFlags(*pflag.FlagSet)
@@ -70,6 +79,44 @@ type BackendInfo struct {
 	Repository string `json:"repository"`
 }
 
+// ReloadEvent describes a haproxy reload, the Kubernetes objects that
+// changed since the former update and the parts of the configuration
+// that couldn't be dynamically applied
+type ReloadEvent struct {
+	// ID is the update id which triggered the reload
+	ID int `json:"id"`
+	// Time is the time the reload was made
+	Time time.Time `json:"time"`
+	// FullSync is true if all the objects were parsed
+	FullSync bool `json:"fullSync"`
+	// Objects lists the changed Kubernetes objects, in the form
+	// `<action>/<kind>:<namespace>/<name>`
+	Objects []string `json:"objects"`
+	// Reasons lists the changed parts of the configuration, e.g.
+	// global, hosts or backends
+	Reasons []string `json:"reasons"`
+	// Attributes lists the changed attributes of the configuration,
+	// in the form `global/<attr>` or `<host|backend>:<id>/<attr>`
+	Attributes []string `json:"attributes"`
+	// Success is false if haproxy failed to reload
+	Success bool `json:"success"`
+}
+
+// DryRunResult describes the configuration rendered by a dry run, which
+// parses all the Kubernetes objects in a scratch configuration
+type DryRunResult struct {
+	// Files has the content of the rendered configuration files, indexed
+	// by their path relative to the configuration directory
+	Files map[string]string `json:"files"`
+	// Messages lists the warnings and errors logged while parsing
+	// the Kubernetes objects
+	Messages []string `json:"messages"`
+	// Valid is false if haproxy failed to validate the configuration
+	Valid bool `json:"valid"`
+	// Error has the output of the validation if it failed
+	Error string `json:"error,omitempty"`
+}
+
 func (bi BackendInfo) String() string {
 	return fmt.Sprintf(`
 Name:       %v
@@ -78,3 +125,45 @@ Build:      %v
 Repository: %v
 `, bi.Name, bi.Release, bi.Build, bi.Repository)
 }
+
+// Summary describes the outcome of a dry run without the content of the
+// rendered files.
+func (r DryRunResult) Summary() string {
+	out := &strings.Builder{}
+	fmt.Fprintf(out, "rendered %d configuration files\n", len(r.Files))
+	if len(r.Messages) > 0 {
+		fmt.Fprintf(out, "found %d warnings:\n", len(r.Messages))
+		for _, msg := range r.Messages {
+			fmt.Fprintf(out, "  - %s\n", msg)
+		}
+	} else {
+		fmt.Fprintf(out, "no warnings found\n")
+	}
+	if r.Valid {
+		fmt.Fprintf(out, "configuration is valid\n")
+	} else {
+		fmt.Fprintf(out, "configuration is invalid:\n%s\n", strings.TrimRight(r.Error, "\n"))
+	}
+	return out.String()
+}
+
+func (r DryRunResult) String() string {
+	names := make([]string, 0, len(r.Files))
+	for name := range r.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := &strings.Builder{}
+	for _, name := range names {
+		fmt.Fprintf(out, "==> %s <==\n%s\n", name, strings.TrimRight(r.Files[name], "\n"))
+	}
+	if len(r.Messages) > 0 {
+		fmt.Fprintf(out, "==> messages <==\n%s\n", strings.Join(r.Messages, "\n"))
+	}
+	if r.Valid {
+		fmt.Fprintf(out, "==> validation <==\nconfiguration is valid\n")
+	} else {
+		fmt.Fprintf(out, "==> validation <==\n%s\n", strings.TrimRight(r.Error, "\n"))
+	}
+	return out.String()
+}
@@ -361,6 +361,50 @@ func AddOrUpdateDHParam(name string, dh []byte) (string, error) {
 	return pemFileName, nil
 }
 
+// AddOrUpdatePublicKey creates a public key file with the specified name,
+// used to verify the signature of JSON Web Tokens
+func AddOrUpdatePublicKey(name string, key []byte) (string, error) {
+	pemName := fmt.Sprintf("pubkey_%v.pem", name)
+	pemFileName := fmt.Sprintf("%v/%v", ingress.DefaultCACertsDirectory, pemName)
+
+	pemBlock, _ := pem.Decode(key)
+	if pemBlock == nil {
+		return "", fmt.Errorf("no valid PEM formatted block found")
+	}
+
+	// If the file does not start with 'BEGIN PUBLIC KEY' it's invalid and must not be used.
+	if pemBlock.Type != "PUBLIC KEY" {
+		return "", fmt.Errorf("public key %v contains invalid data", name)
+	}
+
+	if _, err := x509.ParsePKIXPublicKey(pemBlock.Bytes); err != nil {
+		return "", err
+	}
+
+	tempPemFile, err := ioutil.TempFile(ingress.DefaultCACertsDirectory, pemName)
+	if err != nil {
+		return "", fmt.Errorf("could not create temp pem file %v: %v", pemFileName, err)
+	}
+	glog.V(3).Infof("Creating temp file %v for public key: %v", tempPemFile.Name(), pemName)
+
+	_, err = tempPemFile.Write(key)
+	if errClose := tempPemFile.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		_ = os.Remove(tempPemFile.Name())
+		return "", fmt.Errorf("could not write to pem file %v: %v", tempPemFile.Name(), err)
+	}
+
+	err = os.Rename(tempPemFile.Name(), pemFileName)
+	if err != nil {
+		_ = os.Remove(tempPemFile.Name())
+		return "", fmt.Errorf("could not move temp pem file %v to destination %v: %v", tempPemFile.Name(), pemFileName, err)
+	}
+
+	return pemFileName, nil
+}
+
 // GetFakeSSLCert creates a Self Signed Certificate
 // Based in the code https://golang.org/src/crypto/tls/generate_cert.go
 func GetFakeSSLCert(o []string, cn string, dns []string) (cert, key []byte) {
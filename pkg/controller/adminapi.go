@@ -0,0 +1,251 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress"
+	hautils "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// adminAPI is an authenticated HTTP API that proxies a small and safe
+// subset of the haproxy's runtime API, so operators can inspect and
+// change the state of the proxy without exec'ing into the pod.
+type adminAPI struct {
+	logger   types.Logger
+	token    string
+	mapsDir  string
+	socket   func() string
+	haproxy  func(socket string, observer func(duration time.Duration), command ...string) ([]string, error)
+	dump     func(sections map[string]bool) ([]byte, error)
+	reloads  func() []ingress.ReloadEvent
+	handlers *http.ServeMux
+}
+
+// an argument is sent to the haproxy's runtime API as part of a command line,
+// spaces and semicolons would allow to inject other arguments or commands
+var regexValidAdminArg = regexp.MustCompile(`^[^\s;]+$`)
+
+var validServerStates = map[string]bool{
+	"ready": true,
+	"drain": true,
+	"maint": true,
+}
+
+var validDumpSections = map[string]bool{
+	"files":   true,
+	"model":   true,
+	"tracker": true,
+}
+
+func newAdminAPI(logger types.Logger, token, mapsDir string, socket func() string, dump func(sections map[string]bool) ([]byte, error), reloads func() []ingress.ReloadEvent) *adminAPI {
+	api := &adminAPI{
+		logger:   logger,
+		token:    token,
+		mapsDir:  mapsDir,
+		socket:   socket,
+		haproxy:  hautils.HAProxyCommand,
+		dump:     dump,
+		reloads:  reloads,
+		handlers: http.NewServeMux(),
+	}
+	api.handlers.HandleFunc("/api/v1/stat", api.handle(http.MethodGet, api.showStat))
+	api.handlers.HandleFunc("/api/v1/server/state", api.handle(http.MethodPost, api.setServerState))
+	api.handlers.HandleFunc("/api/v1/table", api.handle(http.MethodGet, api.showTable))
+	api.handlers.HandleFunc("/api/v1/map", api.handle(http.MethodPost, api.setMap))
+	api.handlers.HandleFunc("/api/v1/dump", api.handleDump)
+	api.handlers.HandleFunc("/api/v1/reloads", api.handleReloads)
+	return api
+}
+
+func (a *adminAPI) Listen(port int, stopCh chan struct{}) {
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: a.handlers,
+	}
+	go func() {
+		<-stopCh
+		_ = server.Close()
+	}()
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			a.logger.Error("error listening admin API on port %d: %v", port, err)
+		}
+	}()
+	a.logger.Info("admin API listening on port %d", port)
+}
+
+func (a *adminAPI) handle(method string, command func(r *http.Request) (string, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.accept(w, r, method) {
+			return
+		}
+		cmd, err := command(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.logger.InfoV(2, "admin API: sending '%s' from %s", cmd, r.RemoteAddr)
+		out, err := a.haproxy(a.socket(), nil, cmd)
+		if err != nil {
+			a.logger.Warn("admin API: error sending '%s': %v", cmd, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		for _, o := range out {
+			_, _ = w.Write([]byte(o + "\n"))
+		}
+	}
+}
+
+// handleDump responds the internal state of the controller as a JSON object,
+// optionally filtered by a comma-separated list of sections in the `include`
+// parameter.
+func (a *adminAPI) handleDump(w http.ResponseWriter, r *http.Request) {
+	if !a.accept(w, r, http.MethodGet) {
+		return
+	}
+	sections := validDumpSections
+	if include := r.URL.Query().Get("include"); include != "" {
+		sections = map[string]bool{}
+		for _, section := range strings.Split(include, ",") {
+			if !validDumpSections[section] {
+				http.Error(w, fmt.Sprintf("invalid section, should be one of files, model or tracker: %s", section), http.StatusBadRequest)
+				return
+			}
+			sections[section] = true
+		}
+	}
+	a.logger.InfoV(2, "admin API: sending dump from %s", r.RemoteAddr)
+	out, err := a.dump(sections)
+	if err != nil {
+		a.logger.Warn("admin API: error building dump: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(out)
+}
+
+// handleReloads responds the most recent haproxy reloads and their
+// causes as a JSON array, see --reload-history-size
+func (a *adminAPI) handleReloads(w http.ResponseWriter, r *http.Request) {
+	if !a.accept(w, r, http.MethodGet) {
+		return
+	}
+	out, err := json.Marshal(a.reloads())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(out)
+}
+
+// accept writes an error response and returns false if the request
+// is not authorized or does not use the expected method
+func (a *adminAPI) accept(w http.ResponseWriter, r *http.Request, method string) bool {
+	if !a.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="haproxy-ingress"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if r.Method != method {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+func (a *adminAPI) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
+
+// readArgs reads the named query parameters, all of them mandatory
+// and validated against regexValidAdminArg
+func readArgs(r *http.Request, names ...string) ([]string, error) {
+	query := r.URL.Query()
+	args := make([]string, len(names))
+	for i, name := range names {
+		arg := query.Get(name)
+		if arg == "" {
+			return nil, fmt.Errorf("missing '%s' parameter", name)
+		}
+		if !regexValidAdminArg.MatchString(arg) {
+			return nil, fmt.Errorf("invalid '%s' parameter: %s", name, arg)
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+func (a *adminAPI) showStat(r *http.Request) (string, error) {
+	return "show stat", nil
+}
+
+func (a *adminAPI) setServerState(r *http.Request) (string, error) {
+	args, err := readArgs(r, "backend", "server", "state")
+	if err != nil {
+		return "", err
+	}
+	if !validServerStates[args[2]] {
+		return "", fmt.Errorf("invalid server state, should be one of ready, drain or maint: %s", args[2])
+	}
+	return fmt.Sprintf("set server %s/%s state %s", args[0], args[1], args[2]), nil
+}
+
+func (a *adminAPI) showTable(r *http.Request) (string, error) {
+	if r.URL.Query().Get("name") == "" {
+		return "show table", nil
+	}
+	args, err := readArgs(r, "name")
+	if err != nil {
+		return "", err
+	}
+	return "show table " + args[0], nil
+}
+
+func (a *adminAPI) setMap(r *http.Request) (string, error) {
+	args, err := readArgs(r, "map", "key", "value")
+	if err != nil {
+		return "", err
+	}
+	// only map files created by the controller can be changed
+	name := args[0]
+	if strings.Contains(name, "/") || !strings.HasSuffix(name, ".map") {
+		return "", fmt.Errorf("invalid map file name, should be a *.map file name without directory: %s", name)
+	}
+	return fmt.Sprintf("set map %s %s %s", filepath.Join(a.mapsDir, name), args[1], args[2]), nil
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress"
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestAdminAPI(t *testing.T) {
+	testCases := []struct {
+		method   string
+		url      string
+		token    string
+		expCmd   string
+		expCode  int
+		expBody  string
+		expError string
+	}{
+		// 0
+		{
+			method:  http.MethodGet,
+			url:     "/api/v1/stat",
+			expCode: http.StatusUnauthorized,
+			expBody: "unauthorized",
+		},
+		// 1
+		{
+			method:  http.MethodGet,
+			url:     "/api/v1/stat",
+			token:   "wrong",
+			expCode: http.StatusUnauthorized,
+			expBody: "unauthorized",
+		},
+		// 2
+		{
+			method:  http.MethodGet,
+			url:     "/api/v1/stat",
+			token:   "s3cr3t",
+			expCmd:  "show stat",
+			expCode: http.StatusOK,
+			expBody: "<output>",
+		},
+		// 3
+		{
+			method:  http.MethodGet,
+			url:     "/api/v1/server/state?backend=default_app_8080&server=srv001&state=drain",
+			token:   "s3cr3t",
+			expCode: http.StatusMethodNotAllowed,
+			expBody: "method not allowed",
+		},
+		// 4
+		{
+			method:  http.MethodPost,
+			url:     "/api/v1/server/state?backend=default_app_8080&server=srv001&state=drain",
+			token:   "s3cr3t",
+			expCmd:  "set server default_app_8080/srv001 state drain",
+			expCode: http.StatusOK,
+			expBody: "<output>",
+		},
+		// 5
+		{
+			method:  http.MethodPost,
+			url:     "/api/v1/server/state?backend=default_app_8080&server=srv001&state=stopped",
+			token:   "s3cr3t",
+			expCode: http.StatusBadRequest,
+			expBody: "invalid server state, should be one of ready, drain or maint: stopped",
+		},
+		// 6
+		{
+			method:  http.MethodPost,
+			url:     "/api/v1/server/state?backend=default_app_8080&state=ready",
+			token:   "s3cr3t",
+			expCode: http.StatusBadRequest,
+			expBody: "missing 'server' parameter",
+		},
+		// 7
+		{
+			method:  http.MethodPost,
+			url:     "/api/v1/server/state?backend=default_app_8080%3Bshutdown&server=srv001&state=ready",
+			token:   "s3cr3t",
+			expCode: http.StatusBadRequest,
+			expBody: "invalid 'backend' parameter: default_app_8080;shutdown",
+		},
+		// 8
+		{
+			method:  http.MethodGet,
+			url:     "/api/v1/table",
+			token:   "s3cr3t",
+			expCmd:  "show table",
+			expCode: http.StatusOK,
+			expBody: "<output>",
+		},
+		// 9
+		{
+			method:  http.MethodGet,
+			url:     "/api/v1/table?name=_front_https",
+			token:   "s3cr3t",
+			expCmd:  "show table _front_https",
+			expCode: http.StatusOK,
+			expBody: "<output>",
+		},
+		// 10
+		{
+			method:  http.MethodPost,
+			url:     "/api/v1/map?map=_front_http_host__begin.map&key=d1.local/&value=d1_app_8080",
+			token:   "s3cr3t",
+			expCmd:  "set map /etc/haproxy/maps/_front_http_host__begin.map d1.local/ d1_app_8080",
+			expCode: http.StatusOK,
+			expBody: "<output>",
+		},
+		// 11
+		{
+			method:  http.MethodPost,
+			url:     "/api/v1/map?map=../haproxy.cfg&key=k&value=v",
+			token:   "s3cr3t",
+			expCode: http.StatusBadRequest,
+			expBody: "invalid map file name, should be a *.map file name without directory: ../haproxy.cfg",
+		},
+		// 12
+		{
+			method:   http.MethodGet,
+			url:      "/api/v1/stat",
+			token:    "s3cr3t",
+			expCmd:   "show stat",
+			expError: "connection refused",
+			expCode:  http.StatusBadGateway,
+			expBody:  "connection refused",
+		},
+		// 13
+		{
+			method:  http.MethodGet,
+			url:     "/api/v1/dump",
+			expCode: http.StatusUnauthorized,
+			expBody: "unauthorized",
+		},
+		// 14
+		{
+			method:  http.MethodGet,
+			url:     "/api/v1/dump",
+			token:   "s3cr3t",
+			expCode: http.StatusOK,
+			expBody: `{"sections":"files,model,tracker"}`,
+		},
+		// 15
+		{
+			method:  http.MethodGet,
+			url:     "/api/v1/dump?include=tracker,files",
+			token:   "s3cr3t",
+			expCode: http.StatusOK,
+			expBody: `{"sections":"files,tracker"}`,
+		},
+		// 16
+		{
+			method:  http.MethodGet,
+			url:     "/api/v1/dump?include=files,secrets",
+			token:   "s3cr3t",
+			expCode: http.StatusBadRequest,
+			expBody: "invalid section, should be one of files, model or tracker: secrets",
+		},
+		// 17
+		{
+			method:  http.MethodPost,
+			url:     "/api/v1/dump",
+			token:   "s3cr3t",
+			expCode: http.StatusMethodNotAllowed,
+			expBody: "method not allowed",
+		},
+		// 18
+		{
+			method:   http.MethodGet,
+			url:      "/api/v1/dump",
+			token:    "s3cr3t",
+			expError: "instance not started",
+			expCode:  http.StatusInternalServerError,
+			expBody:  "instance not started",
+		},
+		// 19
+		{
+			method:  http.MethodGet,
+			url:     "/api/v1/reloads",
+			expCode: http.StatusUnauthorized,
+			expBody: "unauthorized",
+		},
+		// 20
+		{
+			method:  http.MethodGet,
+			url:     "/api/v1/reloads",
+			token:   "s3cr3t",
+			expCode: http.StatusOK,
+			expBody: `[{"id":3,"time":"2021-01-01T00:00:00Z","fullSync":false,"objects":["update/ingress:default/echo"],"reasons":["backends"],"attributes":["backend:default_echo_8080/Cookie"],"success":true}]`,
+		},
+		// 21
+		{
+			method:  http.MethodPost,
+			url:     "/api/v1/reloads",
+			token:   "s3cr3t",
+			expCode: http.StatusMethodNotAllowed,
+			expBody: "method not allowed",
+		},
+	}
+	for i, test := range testCases {
+		logger := types_helper.NewLoggerMock(t)
+		dump := func(sections map[string]bool) ([]byte, error) {
+			if test.expError != "" {
+				return nil, fmt.Errorf(test.expError)
+			}
+			var names []string
+			for name := range sections {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return []byte(fmt.Sprintf(`{"sections":"%s"}`, strings.Join(names, ","))), nil
+		}
+		reloads := func() []ingress.ReloadEvent {
+			return []ingress.ReloadEvent{{
+				ID:         3,
+				Time:       time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+				Objects:    []string{"update/ingress:default/echo"},
+				Reasons:    []string{"backends"},
+				Attributes: []string{"backend:default_echo_8080/Cookie"},
+				Success:    true,
+			}}
+		}
+		api := newAdminAPI(logger, "s3cr3t", "/etc/haproxy/maps", func() string { return "/var/run/haproxy/admin.sock" }, dump, reloads)
+		var cmd string
+		api.haproxy = func(socket string, observer func(duration time.Duration), command ...string) ([]string, error) {
+			cmd = strings.Join(command, ";")
+			if test.expError != "" {
+				return nil, fmt.Errorf(test.expError)
+			}
+			return []string{"<output>"}, nil
+		}
+		req := httptest.NewRequest(test.method, test.url, nil)
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		w := httptest.NewRecorder()
+		api.handlers.ServeHTTP(w, req)
+		if cmd != test.expCmd {
+			t.Errorf("command differs on %d - expected: '%s' - actual: '%s'", i, test.expCmd, cmd)
+		}
+		if w.Code != test.expCode {
+			t.Errorf("status code differs on %d - expected: %d - actual: %d", i, test.expCode, w.Code)
+		}
+		if body := strings.TrimSpace(w.Body.String()); body != test.expBody {
+			t.Errorf("body differs on %d - expected: '%s' - actual: '%s'", i, test.expBody, body)
+		}
+	}
+}
@@ -28,14 +28,19 @@ import (
 	"os"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networking "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
@@ -43,36 +48,53 @@ import (
 	gateway "sigs.k8s.io/gateway-api/apis/v1alpha1"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/acme"
+	mcsv1alpha1 "github.com/jcmoraisjr/haproxy-ingress/pkg/api/mcs/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
 	cfile "github.com/jcmoraisjr/haproxy-ingress/pkg/common/file"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress/controller"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/net/ssl"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	convutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
 
-const dhparamFilename = "dhparam.pem"
+const (
+	dhparamFilename   = "dhparam.pem"
+	publicKeyFilename = "jwt.pem"
+)
 
 type k8scache struct {
 	ctx                    context.Context
 	client                 types.Client
 	logger                 types.Logger
 	listers                *listers
+	recorder               record.EventRecorder
 	controller             *controller.GenericController
 	cfg                    *controller.Configuration
 	tracker                convtypes.Tracker
 	dynamicConfig          *convtypes.DynamicConfig
+	podName                string
 	podNamespace           string
 	globalConfigMapKey     string
+	globalResourceName     string
 	tcpConfigMapKey        string
 	acmeSecretKeyName      string
 	acmeTokenConfigmapName string
+	shard                  *namespaceShard
+	jwks                   *jwksCache
 	//
 	changed convtypes.ChangedObjects
 	//
 	updateQueue      utils.Queue
 	stateMutex       sync.RWMutex
 	waitBeforeUpdate time.Duration
+	syncQuietPeriod  time.Duration
+	syncMaxWait      time.Duration
+	syncTimer        syncTimer
+	syncFirstChange  time.Time
+	now              func() time.Time
+	afterFunc        func(d time.Duration, f func()) syncTimer
 	clear            bool
 	//
 }
@@ -94,6 +116,11 @@ func createCache(
 		// - leader elector will panic if this envvar is not provided
 		podNamespace = "default"
 	}
+	podName := os.Getenv("POD_NAME")
+	if podName == "" {
+		// the hostname of a pod is its name, unless configured otherwise
+		podName, _ = os.Hostname()
+	}
 	cfg := controller.GetConfig()
 	acmeSecretKeyName := cfg.AcmeSecretKeyName
 	if !strings.Contains(acmeSecretKeyName, "/") {
@@ -117,18 +144,27 @@ func createCache(
 		ctx:                    context.Background(),
 		client:                 cfg.Client,
 		logger:                 logger,
+		recorder:               recorder,
 		controller:             controller,
 		cfg:                    cfg,
 		tracker:                tracker,
 		dynamicConfig:          configOptions,
+		podName:                podName,
 		podNamespace:           podNamespace,
 		globalConfigMapKey:     globalConfigMapName,
+		globalResourceName:     cfg.GlobalResource,
 		tcpConfigMapKey:        tcpConfigMapName,
 		acmeSecretKeyName:      acmeSecretKeyName,
 		acmeTokenConfigmapName: acmeTokenConfigmapName,
+		shard:                  newNamespaceShard(cfg.ShardCount, cfg.ShardIndex, cfg.ShardNamespaceSelector),
+		jwks:                   newJWKSCache(logger),
 		stateMutex:             sync.RWMutex{},
 		updateQueue:            updateQueue,
 		waitBeforeUpdate:       cfg.WaitBeforeUpdate,
+		syncQuietPeriod:        cfg.SyncQuietPeriod,
+		syncMaxWait:            cfg.SyncMaxWait,
+		now:                    time.Now,
+		afterFunc:              afterFunc,
 		clear:                  true,
 	}
 	// TODO I'm a circular reference, can you fix me?
@@ -138,9 +174,19 @@ func createCache(
 		recorder,
 		cfg.Client,
 		cfg.WatchGateway,
-		cfg.WatchNamespace,
+		cfg.WatchCRDs,
+		cfg.WatchServiceImports,
+		cfg.WatchNamespaces,
+		cfg.IgnoreNamespaces,
 		cfg.ForceNamespaceIsolation,
+		watchSelectors{
+			ingress: cfg.WatchIngressSelector,
+			service: cfg.WatchServiceSelector,
+			secret:  cfg.WatchSecretSelector,
+		},
+		cfg.ShardNamespaceSelector != nil,
 		!cfg.DisablePodList,
+		cfg.EnableEndpointSlicesAPI,
 		cfg.ResyncPeriod,
 	)
 	return cache
@@ -162,6 +208,45 @@ func (c *k8scache) GetIngressPodName() (namespace, podname string, err error) {
 	return namespace, podname, nil
 }
 
+// RecordControllerEvent creates an event in the controller pod
+func (c *k8scache) RecordControllerEvent(eventtype, reason, messageFmt string, args ...interface{}) {
+	namespace := os.Getenv("POD_NAMESPACE")
+	podname := os.Getenv("POD_NAME")
+	if namespace == "" || podname == "" {
+		return
+	}
+	ref := &api.ObjectReference{
+		Kind:       "Pod",
+		APIVersion: "v1",
+		Namespace:  namespace,
+		Name:       podname,
+	}
+	c.recorder.Eventf(ref, eventtype, reason, messageFmt, args...)
+}
+
+// RecordEvent creates an event in the resource that declared a configuration,
+// only ingress and service resources are currently supported.
+func (c *k8scache) RecordEvent(kind, namespace, name, eventtype, reason, message string) {
+	var obj runtime.Object
+	switch strings.ToLower(kind) {
+	case "ingress":
+		ing, err := c.listers.ingressLister.Ingresses(namespace).Get(name)
+		if err != nil {
+			return
+		}
+		obj = ing
+	case "service":
+		svc, err := c.listers.serviceLister.Services(namespace).Get(name)
+		if err != nil {
+			return
+		}
+		obj = svc
+	default:
+		return
+	}
+	c.recorder.Event(obj, eventtype, reason, message)
+}
+
 func (c *k8scache) GetIngress(ingressName string) (*networking.Ingress, error) {
 	namespace, name, err := cache.SplitMetaNamespaceKey(ingressName)
 	if err != nil {
@@ -263,6 +348,348 @@ func (c *k8scache) GetHTTPRouteList(namespace string, match map[string]string) (
 	return c.listers.httpRouteLister.List(selector)
 }
 
+func (c *k8scache) hasCRDs() bool {
+	return c.listers.tcpServiceLister != nil
+}
+
+var errCRDsDisabled = fmt.Errorf("HAProxy Ingress custom resources weren't initialized")
+
+func (c *k8scache) GetTCPServiceList() ([]*v1alpha1.TCPService, error) {
+	if !c.hasCRDs() {
+		return nil, errCRDsDisabled
+	}
+	tcpServiceList, err := c.listers.tcpServiceLister.List(labels.Everything())
+	if err != nil || c.shard == nil {
+		return tcpServiceList, err
+	}
+	shardList := make([]*v1alpha1.TCPService, 0, len(tcpServiceList))
+	for _, tcpService := range tcpServiceList {
+		if c.inShard(tcpService.Namespace) {
+			shardList = append(shardList, tcpService)
+		}
+	}
+	return shardList, nil
+}
+
+// GetUDPServiceList lists the UDPService resources of the namespaces of this shard.
+func (c *k8scache) GetUDPServiceList() ([]*v1alpha1.UDPService, error) {
+	if !c.hasCRDs() {
+		return nil, errCRDsDisabled
+	}
+	udpServiceList, err := c.listers.udpServiceLister.List(labels.Everything())
+	if err != nil || c.shard == nil {
+		return udpServiceList, err
+	}
+	shardList := make([]*v1alpha1.UDPService, 0, len(udpServiceList))
+	for _, udpService := range udpServiceList {
+		if c.inShard(udpService.Namespace) {
+			shardList = append(shardList, udpService)
+		}
+	}
+	return shardList, nil
+}
+
+// GetHostList lists the Host resources of the namespaces of this shard.
+func (c *k8scache) GetHostList() ([]*v1alpha1.Host, error) {
+	if !c.hasCRDs() {
+		return nil, errCRDsDisabled
+	}
+	hostList, err := c.listers.hostLister.List(labels.Everything())
+	if err != nil || c.shard == nil {
+		return hostList, err
+	}
+	shardList := make([]*v1alpha1.Host, 0, len(hostList))
+	for _, host := range hostList {
+		if c.inShard(host.Namespace) {
+			shardList = append(shardList, host)
+		}
+	}
+	return shardList, nil
+}
+
+// GetBackendList lists the Backend resources of the namespaces of this shard.
+func (c *k8scache) GetBackendList() ([]*v1alpha1.Backend, error) {
+	if !c.hasCRDs() {
+		return nil, errCRDsDisabled
+	}
+	backendList, err := c.listers.backendLister.List(labels.Everything())
+	if err != nil || c.shard == nil {
+		return backendList, err
+	}
+	shardList := make([]*v1alpha1.Backend, 0, len(backendList))
+	for _, backend := range backendList {
+		if c.inShard(backend.Namespace) {
+			shardList = append(shardList, backend)
+		}
+	}
+	return shardList, nil
+}
+
+// GetAuthConfigList lists the AuthConfig resources of the namespaces of this shard.
+func (c *k8scache) GetAuthConfigList() ([]*v1alpha1.AuthConfig, error) {
+	if !c.hasCRDs() {
+		return nil, errCRDsDisabled
+	}
+	authConfigList, err := c.listers.authConfigLister.List(labels.Everything())
+	if err != nil || c.shard == nil {
+		return authConfigList, err
+	}
+	shardList := make([]*v1alpha1.AuthConfig, 0, len(authConfigList))
+	for _, authConfig := range authConfigList {
+		if c.inShard(authConfig.Namespace) {
+			shardList = append(shardList, authConfig)
+		}
+	}
+	return shardList, nil
+}
+
+// GetMiddlewareList lists the Middleware resources of the namespaces of this shard.
+func (c *k8scache) GetMiddlewareList() ([]*v1alpha1.Middleware, error) {
+	if !c.hasCRDs() {
+		return nil, errCRDsDisabled
+	}
+	middlewareList, err := c.listers.middlewareLister.List(labels.Everything())
+	if err != nil || c.shard == nil {
+		return middlewareList, err
+	}
+	shardList := make([]*v1alpha1.Middleware, 0, len(middlewareList))
+	for _, middleware := range middlewareList {
+		if c.inShard(middleware.Namespace) {
+			shardList = append(shardList, middleware)
+		}
+	}
+	return shardList, nil
+}
+
+func (c *k8scache) GetIngressClassParameters(name string) (*v1alpha1.IngressClassParameters, error) {
+	if !c.hasCRDs() {
+		return nil, errCRDsDisabled
+	}
+	return c.listers.ingClassParamLister.Get(name)
+}
+
+func (c *k8scache) GetGlobal(name string) (*v1alpha1.Global, error) {
+	if !c.hasCRDs() {
+		return nil, errCRDsDisabled
+	}
+	return c.listers.globalLister.Get(name)
+}
+
+// UpdateGlobalStatus updates the status of a Global resource if the
+// condition or the observed generation changed, see UpdateTCPServiceStatus.
+func (c *k8scache) UpdateGlobalStatus(global *v1alpha1.Global, condition metav1.Condition) error {
+	if !c.hasCRDs() {
+		return errCRDsDisabled
+	}
+	g := global.DeepCopy()
+	condition.ObservedGeneration = g.Generation
+	meta.SetStatusCondition(&g.Status.Conditions, condition)
+	g.Status.ObservedGeneration = g.Generation
+	if reflect.DeepEqual(g.Status, global.Status) {
+		return nil
+	}
+	_, err := c.client.HAProxyIngressV1alpha1().Globals().UpdateStatus(c.ctx, g, metav1.UpdateOptions{})
+	if k8serrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// GetIPListList lists the IPList resources, they are cluster scoped
+// and shared by all the shards.
+func (c *k8scache) GetIPListList() ([]*v1alpha1.IPList, error) {
+	if !c.hasCRDs() {
+		return nil, errCRDsDisabled
+	}
+	return c.listers.ipListLister.List(labels.Everything())
+}
+
+// GetIPList returns an IPList resource and tracks its usage, so changes in
+// the list resync the configuration that references it.
+func (c *k8scache) GetIPList(name string, track convtypes.TrackingTarget) (*v1alpha1.IPList, error) {
+	if !c.hasCRDs() {
+		return nil, errCRDsDisabled
+	}
+	ipList, err := c.listers.ipListLister.Get(name)
+	if err != nil {
+		c.tracker.Track(true, track, convtypes.IPListType, name)
+		return nil, err
+	}
+	c.tracker.Track(false, track, convtypes.IPListType, name)
+	return ipList, nil
+}
+
+// UpdateIPListStatus updates the status of an IPList resource if the
+// condition or the observed generation changed, see UpdateTCPServiceStatus.
+func (c *k8scache) UpdateIPListStatus(ipList *v1alpha1.IPList, condition metav1.Condition) error {
+	if !c.hasCRDs() {
+		return errCRDsDisabled
+	}
+	r := ipList.DeepCopy()
+	condition.ObservedGeneration = r.Generation
+	meta.SetStatusCondition(&r.Status.Conditions, condition)
+	r.Status.ObservedGeneration = r.Generation
+	if reflect.DeepEqual(r.Status, ipList.Status) {
+		return nil
+	}
+	_, err := c.client.HAProxyIngressV1alpha1().IPLists().UpdateStatus(c.ctx, r, metav1.UpdateOptions{})
+	if k8serrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// GetRateLimitList lists the RateLimit resources, they are cluster scoped
+// and shared by all the shards.
+func (c *k8scache) GetRateLimitList() ([]*v1alpha1.RateLimit, error) {
+	if !c.hasCRDs() {
+		return nil, errCRDsDisabled
+	}
+	return c.listers.rateLimitLister.List(labels.Everything())
+}
+
+// UpdateRateLimitStatus updates the status of a RateLimit resource if the
+// condition or the observed generation changed, see UpdateTCPServiceStatus.
+func (c *k8scache) UpdateRateLimitStatus(rateLimit *v1alpha1.RateLimit, condition metav1.Condition) error {
+	if !c.hasCRDs() {
+		return errCRDsDisabled
+	}
+	r := rateLimit.DeepCopy()
+	condition.ObservedGeneration = r.Generation
+	meta.SetStatusCondition(&r.Status.Conditions, condition)
+	r.Status.ObservedGeneration = r.Generation
+	if reflect.DeepEqual(r.Status, rateLimit.Status) {
+		return nil
+	}
+	_, err := c.client.HAProxyIngressV1alpha1().RateLimits().UpdateStatus(c.ctx, r, metav1.UpdateOptions{})
+	if k8serrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// UpdateHostStatus updates the status of a Host resource if the condition
+// or the observed generation changed, see UpdateTCPServiceStatus.
+func (c *k8scache) UpdateHostStatus(host *v1alpha1.Host, condition metav1.Condition) error {
+	if !c.hasCRDs() {
+		return errCRDsDisabled
+	}
+	h := host.DeepCopy()
+	condition.ObservedGeneration = h.Generation
+	meta.SetStatusCondition(&h.Status.Conditions, condition)
+	h.Status.ObservedGeneration = h.Generation
+	if reflect.DeepEqual(h.Status, host.Status) {
+		return nil
+	}
+	_, err := c.client.HAProxyIngressV1alpha1().Hosts(h.Namespace).UpdateStatus(c.ctx, h, metav1.UpdateOptions{})
+	if k8serrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// UpdateBackendStatus updates the status of a Backend resource if the condition
+// or the observed generation changed, see UpdateTCPServiceStatus.
+func (c *k8scache) UpdateBackendStatus(backend *v1alpha1.Backend, condition metav1.Condition) error {
+	if !c.hasCRDs() {
+		return errCRDsDisabled
+	}
+	b := backend.DeepCopy()
+	condition.ObservedGeneration = b.Generation
+	meta.SetStatusCondition(&b.Status.Conditions, condition)
+	b.Status.ObservedGeneration = b.Generation
+	if reflect.DeepEqual(b.Status, backend.Status) {
+		return nil
+	}
+	_, err := c.client.HAProxyIngressV1alpha1().Backends(b.Namespace).UpdateStatus(c.ctx, b, metav1.UpdateOptions{})
+	if k8serrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// UpdateAuthConfigStatus updates the status of an AuthConfig resource if the condition
+// or the observed generation changed, see UpdateTCPServiceStatus.
+func (c *k8scache) UpdateAuthConfigStatus(authConfig *v1alpha1.AuthConfig, condition metav1.Condition) error {
+	if !c.hasCRDs() {
+		return errCRDsDisabled
+	}
+	a := authConfig.DeepCopy()
+	condition.ObservedGeneration = a.Generation
+	meta.SetStatusCondition(&a.Status.Conditions, condition)
+	a.Status.ObservedGeneration = a.Generation
+	if reflect.DeepEqual(a.Status, authConfig.Status) {
+		return nil
+	}
+	_, err := c.client.HAProxyIngressV1alpha1().AuthConfigs(a.Namespace).UpdateStatus(c.ctx, a, metav1.UpdateOptions{})
+	if k8serrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// UpdateMiddlewareStatus updates the status of a Middleware resource if the condition
+// or the observed generation changed, see UpdateTCPServiceStatus.
+func (c *k8scache) UpdateMiddlewareStatus(middleware *v1alpha1.Middleware, condition metav1.Condition) error {
+	if !c.hasCRDs() {
+		return errCRDsDisabled
+	}
+	m := middleware.DeepCopy()
+	condition.ObservedGeneration = m.Generation
+	meta.SetStatusCondition(&m.Status.Conditions, condition)
+	m.Status.ObservedGeneration = m.Generation
+	if reflect.DeepEqual(m.Status, middleware.Status) {
+		return nil
+	}
+	_, err := c.client.HAProxyIngressV1alpha1().Middlewares(m.Namespace).UpdateStatus(c.ctx, m, metav1.UpdateOptions{})
+	if k8serrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// UpdateTCPServiceStatus updates the status of a TCPService resource if
+// the condition or the observed generation changed. All the controller
+// replicas build the same status, so conflicts are just ignored: the
+// next sync will have an up to date copy of the resource.
+func (c *k8scache) UpdateTCPServiceStatus(tcpService *v1alpha1.TCPService, condition metav1.Condition) error {
+	if !c.hasCRDs() {
+		return errCRDsDisabled
+	}
+	svc := tcpService.DeepCopy()
+	condition.ObservedGeneration = svc.Generation
+	meta.SetStatusCondition(&svc.Status.Conditions, condition)
+	svc.Status.ObservedGeneration = svc.Generation
+	if reflect.DeepEqual(svc.Status, tcpService.Status) {
+		return nil
+	}
+	_, err := c.client.HAProxyIngressV1alpha1().TCPServices(svc.Namespace).UpdateStatus(c.ctx, svc, metav1.UpdateOptions{})
+	if k8serrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// UpdateUDPServiceStatus updates the status of an UDPService resource if
+// the condition or the observed generation changed, see UpdateTCPServiceStatus.
+func (c *k8scache) UpdateUDPServiceStatus(udpService *v1alpha1.UDPService, condition metav1.Condition) error {
+	if !c.hasCRDs() {
+		return errCRDsDisabled
+	}
+	svc := udpService.DeepCopy()
+	condition.ObservedGeneration = svc.Generation
+	meta.SetStatusCondition(&svc.Status.Conditions, condition)
+	svc.Status.ObservedGeneration = svc.Generation
+	if reflect.DeepEqual(svc.Status, udpService.Status) {
+		return nil
+	}
+	_, err := c.client.HAProxyIngressV1alpha1().UDPServices(svc.Namespace).UpdateStatus(c.ctx, svc, metav1.UpdateOptions{})
+	if k8serrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
 func (c *k8scache) GetService(defaultNamespace, serviceName string) (*api.Service, error) {
 	namespace, name, err := c.buildResourceName(defaultNamespace, "service", serviceName, c.dynamicConfig.CrossNamespaceServices)
 	if err != nil {
@@ -290,8 +717,136 @@ func (c *k8scache) GetConfigMap(configMapName string) (*api.ConfigMap, error) {
 	return c.listers.configMapLister.ConfigMaps(namespace).Get(name)
 }
 
-func (c *k8scache) GetEndpoints(service *api.Service) (*api.Endpoints, error) {
-	return c.listers.endpointLister.Endpoints(service.Namespace).Get(service.Name)
+func (c *k8scache) GetConfigMapData(defaultNamespace, configMapName string, track convtypes.TrackingTarget) (map[string]string, error) {
+	namespace, name, err := c.buildResourceName(defaultNamespace, "configmap", configMapName, c.dynamicConfig.CrossNamespaceConfigMaps)
+	if err != nil {
+		return nil, err
+	}
+	configMap, err := c.listers.configMapLister.ConfigMaps(namespace).Get(name)
+	if err != nil {
+		c.tracker.Track(true, track, convtypes.ConfigMapType, namespace+"/"+name)
+		return nil, err
+	}
+	c.tracker.Track(false, track, convtypes.ConfigMapType, namespace+"/"+name)
+	return configMap.Data, nil
+}
+
+// GetEndpointSlices returns the EndpointSlices of a service, sorted by name.
+// Endpoints objects are converted to EndpointSlices if the EndpointSlices API
+// is not enabled, so converters need to deal with just one of them.
+func (c *k8scache) GetEndpointSlices(service *api.Service) ([]*discoveryv1.EndpointSlice, error) {
+	if c.listers.endpointSliceLister == nil {
+		ep, err := c.listers.endpointLister.Endpoints(service.Namespace).Get(service.Name)
+		if err != nil {
+			return nil, err
+		}
+		return endpointsToSlices(ep), nil
+	}
+	selector := labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: service.Name})
+	slices, err := c.listers.endpointSliceLister.EndpointSlices(service.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(slices) == 0 {
+		return nil, fmt.Errorf("could not find endpoints for service '%s/%s'", service.Namespace, service.Name)
+	}
+	sort.Slice(slices, func(i, j int) bool {
+		return slices[i].Name < slices[j].Name
+	})
+	return slices, nil
+}
+
+func (c *k8scache) GetServiceImport(defaultNamespace, importName string) (*mcsv1alpha1.ServiceImport, error) {
+	if c.listers.serviceImportLister == nil {
+		return nil, fmt.Errorf("ServiceImport resources are not being watched, see --watch-service-imports")
+	}
+	namespace, name, err := c.buildResourceName(defaultNamespace, "serviceimport", importName, c.dynamicConfig.CrossNamespaceServices)
+	if err != nil {
+		return nil, err
+	}
+	return c.listers.serviceImportLister.Get(namespace, name)
+}
+
+func (c *k8scache) GetServiceImportSlices(svcImport *mcsv1alpha1.ServiceImport) ([]*discoveryv1.EndpointSlice, error) {
+	selector := labels.SelectorFromSet(labels.Set{mcsv1alpha1.LabelServiceName: svcImport.Name})
+	slices, err := c.listers.endpointSliceLister.EndpointSlices(svcImport.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(slices) == 0 {
+		return nil, fmt.Errorf("could not find endpoints for service import '%s/%s'", svcImport.Namespace, svcImport.Name)
+	}
+	sort.Slice(slices, func(i, j int) bool {
+		return slices[i].Name < slices[j].Name
+	})
+	return slices, nil
+}
+
+// endpointsServiceSlice returns an empty EndpointSlice that references the
+// service of an Endpoints object. Changed endpoints are only used to find
+// the services whose backends should be updated.
+func endpointsServiceSlice(ep *api.Endpoints) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ep.Namespace,
+			Name:      ep.Name,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: ep.Name},
+		},
+	}
+}
+
+// endpointsToSlices converts every subset of an Endpoints object to
+// EndpointSlices, one slice per address family.
+func endpointsToSlices(ep *api.Endpoints) []*discoveryv1.EndpointSlice {
+	var slices []*discoveryv1.EndpointSlice
+	for i := range ep.Subsets {
+		subset := &ep.Subsets[i]
+		ports := make([]discoveryv1.EndpointPort, len(subset.Ports))
+		for j := range subset.Ports {
+			port := &subset.Ports[j]
+			ports[j] = discoveryv1.EndpointPort{
+				Name:        &port.Name,
+				Protocol:    &port.Protocol,
+				Port:        &port.Port,
+				AppProtocol: port.AppProtocol,
+			}
+		}
+		byType := map[discoveryv1.AddressType]*discoveryv1.EndpointSlice{}
+		addEndpoint := func(addr *api.EndpointAddress, ready bool) {
+			addrType := discoveryv1.AddressTypeIPv4
+			if strings.Contains(addr.IP, ":") {
+				addrType = discoveryv1.AddressTypeIPv6
+			}
+			slice, found := byType[addrType]
+			if !found {
+				slice = &discoveryv1.EndpointSlice{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: ep.Namespace,
+						Name:      fmt.Sprintf("%s-%d-%s", ep.Name, i, strings.ToLower(string(addrType))),
+						Labels:    map[string]string{discoveryv1.LabelServiceName: ep.Name},
+					},
+					AddressType: addrType,
+					Ports:       ports,
+				}
+				byType[addrType] = slice
+				slices = append(slices, slice)
+			}
+			slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+				Addresses:  []string{addr.IP},
+				Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+				Hostname:   &addr.Hostname,
+				TargetRef:  addr.TargetRef,
+				NodeName:   addr.NodeName,
+			})
+		}
+		for j := range subset.Addresses {
+			addEndpoint(&subset.Addresses[j], true)
+		}
+		for j := range subset.NotReadyAddresses {
+			addEndpoint(&subset.NotReadyAddresses[j], false)
+		}
+	}
+	return slices
 }
 
 // GetTerminatingPods returns the pods that are terminating and belong
@@ -346,6 +901,41 @@ func (c *k8scache) GetPod(podName string) (*api.Pod, error) {
 	return c.client.CoreV1().Pods(namespace).Get(c.ctx, name, metav1.GetOptions{})
 }
 
+// GetControllerServices lists the services of the controller namespace that
+// select the controller pod, sorted by name.
+func (c *k8scache) GetControllerServices() ([]*api.Service, error) {
+	pod, err := c.GetPod(c.podNamespace + "/" + c.podName)
+	if err != nil {
+		return nil, err
+	}
+	list, err := c.listers.serviceLister.Services(c.podNamespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var services []*api.Service
+	for _, svc := range list {
+		if convutils.ServiceSelectsPod(svc, pod) {
+			services = append(services, svc)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+	return services, nil
+}
+
+func (c *k8scache) GetNode(nodeName string) (*api.Node, error) {
+	if c.listers.hasNodeLister {
+		return c.listers.nodeLister.Get(nodeName)
+	}
+	// A fallback if the controller isn't watching the whole cluster.
+	return c.client.CoreV1().Nodes().Get(c.ctx, nodeName, metav1.GetOptions{})
+}
+
+func (c *k8scache) GetPodName() string {
+	return c.podName
+}
+
 func (c *k8scache) GetPodNamespace() string {
 	return c.podNamespace
 }
@@ -511,11 +1101,58 @@ func (c *k8scache) GetDHSecretPath(defaultNamespace, secretName string) (file co
 	return file, nil
 }
 
+// GetPublicKeySecretPath copies the public key of a secret to a file, which
+// can be used by haproxy to verify the signature of JSON Web Tokens.
+func (c *k8scache) GetPublicKeySecretPath(defaultNamespace, secretName string, track convtypes.TrackingTarget) (file convtypes.File, err error) {
+	proto, content := getContentProtocol(secretName)
+	if proto == "file" {
+		if _, err := os.Stat(content); err != nil {
+			return file, err
+		}
+		return convtypes.File{
+			Filename: content,
+			SHA1Hash: "-",
+		}, nil
+	} else if proto != "secret" {
+		return file, fmt.Errorf("unsupported protocol: %s", proto)
+	}
+	key, err := c.GetSecretContent(defaultNamespace, content, publicKeyFilename, track)
+	if err != nil {
+		return file, err
+	}
+	namespace, name, _ := c.buildResourceName(defaultNamespace, "secret", content, true)
+	pem := fmt.Sprintf("%s_%s", namespace, name)
+	pemFileName, err := ssl.AddOrUpdatePublicKey(pem, key)
+	if err != nil {
+		return file, fmt.Errorf("error creating public key file '%s': %v", pem, err)
+	}
+	file = convtypes.File{
+		Filename: pemFileName,
+		SHA1Hash: cfile.SHA1(pemFileName),
+	}
+	return file, nil
+}
+
+// GetJWKSKeys returns the keys of a JSON Web Key Set converted to PEM files,
+// see jwksCache.
+func (c *k8scache) GetJWKSKeys(url string) ([]convtypes.JWKSKey, error) {
+	return c.jwks.getKeys(url)
+}
+
 func (c *k8scache) GetPasswdSecretContent(defaultNamespace, secretName string, track convtypes.TrackingTarget) ([]byte, error) {
 	proto, content := getContentProtocol(secretName)
 	if proto == "file" {
 		return ioutil.ReadFile(content)
-	} else if proto != "secret" {
+	}
+	return c.GetSecretContent(defaultNamespace, secretName, "auth", track)
+}
+
+// GetSecretContent reads the content of a key of a secret. Cross namespace
+// access follows the same configuration of the userlist secrets, since both
+// hold credentials.
+func (c *k8scache) GetSecretContent(defaultNamespace, secretName, keyName string, track convtypes.TrackingTarget) ([]byte, error) {
+	proto, content := getContentProtocol(secretName)
+	if proto != "secret" {
 		return nil, fmt.Errorf("unsupported protocol: %s", proto)
 	}
 	namespace, name, err := c.buildResourceName(defaultNamespace, "secret", content, c.dynamicConfig.CrossNamespaceSecretPasswd)
@@ -527,7 +1164,6 @@ func (c *k8scache) GetPasswdSecretContent(defaultNamespace, secretName string, t
 		c.tracker.Track(true, track, convtypes.SecretType, namespace+"/"+name)
 		return nil, err
 	}
-	keyName := "auth"
 	data, found := secret.Data[keyName]
 	if !found {
 		c.tracker.Track(true, track, convtypes.SecretType, namespace+"/"+name)
@@ -681,14 +1317,18 @@ func (c *k8scache) CreateOrUpdateConfigMap(cm *api.ConfigMap) (err error) {
 
 // implements ListerEvents
 func (c *k8scache) IsValidIngress(ing *networking.Ingress) bool {
+	if !c.inShard(ing.Namespace) {
+		return false
+	}
+
 	// check if ingress `hasAnn` and, if so, if it's valid `fromAnn` perspective
 	var hasAnn, fromAnn bool
 	var ann string
 	ann, hasAnn = ing.Annotations["kubernetes.io/ingress.class"]
 	if c.cfg.WatchIngressWithoutClass {
-		fromAnn = !hasAnn || ann == c.cfg.IngressClass
+		fromAnn = !hasAnn || c.hasIngressClassAnn(ann)
 	} else {
-		fromAnn = hasAnn && ann == c.cfg.IngressClass
+		fromAnn = hasAnn && c.hasIngressClassAnn(ann)
 	}
 
 	// check if ingress `hasClass` and, if so, if it's valid `fromClass` perspective
@@ -718,12 +1358,26 @@ func (c *k8scache) IsValidIngress(ing *networking.Ingress) bool {
 	return fromAnn
 }
 
+// hasIngressClassAnn is true if the value of the ingress class annotation is
+// one of the classes this controller listens to, see --ingress-class
+func (c *k8scache) hasIngressClassAnn(ann string) bool {
+	for _, class := range c.cfg.IngressClasses {
+		if ann == class {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *k8scache) IsValidIngressClass(ingressClass *networking.IngressClass) bool {
 	return ingressClass.Spec.Controller == c.cfg.ControllerName
 }
 
 // implements ListerEvents
 func (c *k8scache) IsValidGateway(gw *gateway.Gateway) bool {
+	if !c.inShard(gw.Namespace) {
+		return false
+	}
 	className := gw.Spec.GatewayClassName
 	gwClass, err := c.GetGatewayClass(className)
 	if err != nil {
@@ -741,14 +1395,27 @@ func (c *k8scache) IsValidGatewayClass(gwClass *gateway.GatewayClass) bool {
 	return gwClass.Spec.Controller == c.cfg.ControllerName
 }
 
-// implements ListerEvents
-func (c *k8scache) IsValidConfigMap(cm *api.ConfigMap) bool {
-	// IngressClass' Parameters can use ConfigMaps in the controller namespace
-	if cm.Namespace == c.podNamespace {
+// inShard is true if the resources of a namespace are managed by this
+// controller deployment, see namespaceShard.
+func (c *k8scache) inShard(namespace string) bool {
+	if c.shard == nil {
 		return true
 	}
-	key := fmt.Sprintf("%s/%s", cm.Namespace, cm.Name)
-	return key == c.globalConfigMapKey || key == c.tcpConfigMapKey
+	var ns *api.Namespace
+	if c.shard.selector != nil {
+		// a namespace not found doesn't match the selector
+		ns, _ = c.listers.namespaceLister.Get(namespace)
+	}
+	return c.shard.contains(namespace, ns)
+}
+
+// implements ListerEvents
+func (c *k8scache) IsValidConfigMap(cm *api.ConfigMap) bool {
+	// IngressClass' Parameters can use ConfigMaps in the controller namespace,
+	// and configuration keys like error-pages can use ConfigMaps from any
+	// namespace. Changes in ConfigMaps not referenced are filtered out by
+	// the tracker.
+	return true
 }
 
 // implements ListerEvents
@@ -806,6 +1473,40 @@ func (c *k8scache) Notify(old, cur interface{}) {
 				ch.BackendPoliciesDel = append(ch.BackendPoliciesDel, old.(*gateway.BackendPolicy))
 				ch.NeedFullSync = true
 			}
+		case *v1alpha1.TCPService:
+			if cur == nil {
+				ch.TCPServicesDel = append(ch.TCPServicesDel, old.(*v1alpha1.TCPService))
+			}
+		case *v1alpha1.UDPService:
+			if cur == nil {
+				ch.UDPServicesDel = append(ch.UDPServicesDel, old.(*v1alpha1.UDPService))
+			}
+		case *v1alpha1.IPList:
+			if cur == nil {
+				ch.IPListsDel = append(ch.IPListsDel, old.(*v1alpha1.IPList))
+			}
+		case *discoveryv1.EndpointSlice:
+			if cur == nil {
+				ch.EndpointsNew = append(ch.EndpointsNew, old.(*discoveryv1.EndpointSlice))
+			}
+		case *mcsv1alpha1.ServiceImport:
+			ch.NeedFullSync = true
+		case *v1alpha1.IngressClassParameters:
+			ch.NeedFullSync = true
+		case *v1alpha1.Host:
+			ch.NeedFullSync = true
+		case *v1alpha1.Backend:
+			ch.NeedFullSync = true
+		case *v1alpha1.Middleware:
+			ch.NeedFullSync = true
+		case *v1alpha1.AuthConfig:
+			ch.NeedFullSync = true
+		case *v1alpha1.RateLimit:
+			ch.NeedFullSync = true
+		case *v1alpha1.Global:
+			if old.(*v1alpha1.Global).Name == c.globalResourceName {
+				ch.NeedFullSync = true
+			}
 		case *api.Service:
 			if cur == nil {
 				ch.ServicesDel = append(ch.ServicesDel, old.(*api.Service))
@@ -894,8 +1595,49 @@ func (c *k8scache) Notify(old, cur interface{}) {
 				ch.BackendPoliciesUpd = append(ch.BackendPoliciesUpd, bp)
 			}
 			ch.NeedFullSync = true
+		case *v1alpha1.TCPService:
+			svc := cur.(*v1alpha1.TCPService)
+			if old == nil {
+				ch.TCPServicesAdd = append(ch.TCPServicesAdd, svc)
+			} else {
+				ch.TCPServicesUpd = append(ch.TCPServicesUpd, svc)
+			}
+		case *v1alpha1.UDPService:
+			svc := cur.(*v1alpha1.UDPService)
+			if old == nil {
+				ch.UDPServicesAdd = append(ch.UDPServicesAdd, svc)
+			} else {
+				ch.UDPServicesUpd = append(ch.UDPServicesUpd, svc)
+			}
+		case *v1alpha1.IPList:
+			ipList := cur.(*v1alpha1.IPList)
+			if old == nil {
+				ch.IPListsAdd = append(ch.IPListsAdd, ipList)
+			} else {
+				ch.IPListsUpd = append(ch.IPListsUpd, ipList)
+			}
 		case *api.Endpoints:
-			ch.EndpointsNew = append(ch.EndpointsNew, cur.(*api.Endpoints))
+			ch.EndpointsNew = append(ch.EndpointsNew, endpointsServiceSlice(cur.(*api.Endpoints)))
+		case *discoveryv1.EndpointSlice:
+			ch.EndpointsNew = append(ch.EndpointsNew, cur.(*discoveryv1.EndpointSlice))
+		case *mcsv1alpha1.ServiceImport:
+			ch.NeedFullSync = true
+		case *v1alpha1.IngressClassParameters:
+			ch.NeedFullSync = true
+		case *v1alpha1.Host:
+			ch.NeedFullSync = true
+		case *v1alpha1.Backend:
+			ch.NeedFullSync = true
+		case *v1alpha1.Middleware:
+			ch.NeedFullSync = true
+		case *v1alpha1.AuthConfig:
+			ch.NeedFullSync = true
+		case *v1alpha1.RateLimit:
+			ch.NeedFullSync = true
+		case *v1alpha1.Global:
+			if cur.(*v1alpha1.Global).Name == c.globalResourceName {
+				ch.NeedFullSync = true
+			}
 		case *api.Service:
 			svc := cur.(*api.Service)
 			if old == nil {
@@ -932,14 +1674,73 @@ func (c *k8scache) Notify(old, cur interface{}) {
 	if old == nil && cur == nil {
 		ch.NeedFullSync = true
 	}
-	if c.clear {
+	if c.syncQuietPeriod > 0 {
+		c.notifyAfterQuietPeriod()
+	} else if c.clear {
 		// Wait before notify, giving the time to receive
 		// all/most of the changes of a batch update
-		time.AfterFunc(c.waitBeforeUpdate, func() { c.updateQueue.Notify() })
+		c.afterFunc(c.waitBeforeUpdate, func() { c.updateQueue.Notify() })
 	}
 	c.clear = false
 }
 
+// syncTimer is the part of time.Timer used to schedule the notification
+// of the update queue
+type syncTimer interface {
+	Stop() bool
+}
+
+func afterFunc(d time.Duration, f func()) syncTimer {
+	return time.AfterFunc(d, f)
+}
+
+// notifyAfterQuietPeriod (re)schedules the update queue notification, so
+// it happens only after syncQuietPeriod without new change notifications.
+// syncMaxWait, counted from the first pending change, limits how long a
+// continuous stream of changes can postpone the update. Need to be called
+// with stateMutex locked.
+func (c *k8scache) notifyAfterQuietPeriod() {
+	now := c.now()
+	if c.clear {
+		c.syncFirstChange = now
+	}
+	wait := c.syncQuietPeriod
+	if remaining := c.syncFirstChange.Add(c.syncMaxWait).Sub(now); remaining < wait {
+		wait = remaining
+	}
+	if c.syncTimer != nil {
+		c.syncTimer.Stop()
+	}
+	c.syncTimer = c.afterFunc(wait, func() { c.updateQueue.Notify() })
+}
+
+// setSyncTimings changes how long the cache waits before notifying the
+// update queue, used by the runtime controller options. Changes already
+// scheduled are notified using the former timings.
+func (c *k8scache) setSyncTimings(waitBeforeUpdate, syncQuietPeriod, syncMaxWait time.Duration) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	c.waitBeforeUpdate = waitBeforeUpdate
+	c.syncQuietPeriod = syncQuietPeriod
+	c.syncMaxWait = syncMaxWait
+}
+
+// configMapData returns the most recent content of the global and the tcp
+// services ConfigMaps without changing the state of the changed objects.
+func (c *k8scache) configMapData() (global, tcp map[string]string) {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+	global = c.changed.GlobalConfigMapDataNew
+	if global == nil {
+		global = c.changed.GlobalConfigMapDataCur
+	}
+	tcp = c.changed.TCPConfigMapDataNew
+	if tcp == nil {
+		tcp = c.changed.TCPConfigMapDataCur
+	}
+	return global, tcp
+}
+
 // implements converters.types.Cache
 func (c *k8scache) SwapChangedObjects() *convtypes.ChangedObjects {
 	c.stateMutex.Lock()
@@ -1034,8 +1835,35 @@ func (c *k8scache) SwapChangedObjects() *convtypes.ChangedObjects {
 	for _, bp := range ch.BackendPoliciesAdd {
 		obj = append(obj, "add/backendPolicy:"+bp.Namespace+"/"+bp.Name)
 	}
+	for _, svc := range ch.TCPServicesDel {
+		obj = append(obj, "del/tcpService:"+svc.Namespace+"/"+svc.Name)
+	}
+	for _, svc := range ch.TCPServicesUpd {
+		obj = append(obj, "update/tcpService:"+svc.Namespace+"/"+svc.Name)
+	}
+	for _, svc := range ch.TCPServicesAdd {
+		obj = append(obj, "add/tcpService:"+svc.Namespace+"/"+svc.Name)
+	}
+	for _, svc := range ch.UDPServicesDel {
+		obj = append(obj, "del/udpService:"+svc.Namespace+"/"+svc.Name)
+	}
+	for _, svc := range ch.UDPServicesUpd {
+		obj = append(obj, "update/udpService:"+svc.Namespace+"/"+svc.Name)
+	}
+	for _, svc := range ch.UDPServicesAdd {
+		obj = append(obj, "add/udpService:"+svc.Namespace+"/"+svc.Name)
+	}
+	for _, ipList := range ch.IPListsDel {
+		obj = append(obj, "del/ipList:"+ipList.Name)
+	}
+	for _, ipList := range ch.IPListsUpd {
+		obj = append(obj, "update/ipList:"+ipList.Name)
+	}
+	for _, ipList := range ch.IPListsAdd {
+		obj = append(obj, "add/ipList:"+ipList.Name)
+	}
 	for _, ep := range ch.EndpointsNew {
-		obj = append(obj, "update/endpoint:"+ep.Namespace+"/"+ep.Name)
+		obj = append(obj, "update/endpoint:"+convutils.EndpointSliceService(ep))
 	}
 	for _, svc := range ch.ServicesDel {
 		obj = append(obj, "del/service:"+svc.Namespace+"/"+svc.Name)
@@ -17,7 +17,18 @@ limitations under the License.
 package controller
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress/controller"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
 
 func TestGetContentProtocol(t *testing.T) {
@@ -79,3 +90,252 @@ func TestGetContentProtocol(t *testing.T) {
 		}
 	}
 }
+
+func TestEndpointsToSlices(t *testing.T) {
+	ep := &api.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+		Subsets: []api.EndpointSubset{
+			{
+				Addresses: []api.EndpointAddress{
+					{IP: "172.17.0.11"},
+					{IP: "fd00::11"},
+				},
+				NotReadyAddresses: []api.EndpointAddress{
+					{IP: "172.17.0.12"},
+				},
+				Ports: []api.EndpointPort{{Name: "http", Port: 8080, Protocol: api.ProtocolTCP}},
+			},
+		},
+	}
+	slices := endpointsToSlices(ep)
+	if len(slices) != 2 {
+		t.Fatalf("expected 2 slices but was %d", len(slices))
+	}
+	summary := func(slice *discoveryv1.EndpointSlice) string {
+		var addrs []string
+		for _, e := range slice.Endpoints {
+			addrs = append(addrs, fmt.Sprintf("%s=%t", e.Addresses[0], *e.Conditions.Ready))
+		}
+		return fmt.Sprintf("%s %s %s:%d %v", slice.Name, slice.AddressType, *slice.Ports[0].Name, *slice.Ports[0].Port, addrs)
+	}
+	expected := []string{
+		"app-0-ipv4 IPv4 http:8080 [172.17.0.11=true 172.17.0.12=false]",
+		"app-0-ipv6 IPv6 http:8080 [fd00::11=true]",
+	}
+	for i, slice := range slices {
+		if actual := summary(slice); actual != expected[i] {
+			t.Errorf("slice differs on %d, expected '%s' but was '%s'", i, expected[i], actual)
+		}
+		if svc := slice.Labels[discoveryv1.LabelServiceName]; svc != "app" {
+			t.Errorf("service name differs on %d, expected 'app' but was '%s'", i, svc)
+		}
+	}
+}
+
+func TestIsValidIngressClassAnn(t *testing.T) {
+	testCases := []struct {
+		classes      []string
+		withoutClass bool
+		ann          *string
+		expected     bool
+	}{
+		// 0
+		{
+			classes:  []string{"haproxy"},
+			expected: false,
+		},
+		// 1
+		{
+			classes:      []string{"haproxy"},
+			withoutClass: true,
+			expected:     true,
+		},
+		// 2
+		{
+			classes:  []string{"haproxy"},
+			ann:      strPtr("haproxy"),
+			expected: true,
+		},
+		// 3
+		{
+			classes:  []string{"haproxy"},
+			ann:      strPtr("internal"),
+			expected: false,
+		},
+		// 4
+		{
+			classes:  []string{"haproxy", "internal"},
+			ann:      strPtr("internal"),
+			expected: true,
+		},
+		// 5
+		{
+			classes:      []string{"haproxy", "internal"},
+			withoutClass: true,
+			ann:          strPtr("nginx"),
+			expected:     false,
+		},
+	}
+	for i, test := range testCases {
+		c := &k8scache{
+			cfg: &controller.Configuration{
+				IngressClasses:           test.classes,
+				WatchIngressWithoutClass: test.withoutClass,
+			},
+		}
+		ing := &networking.Ingress{}
+		if test.ann != nil {
+			ing.Annotations = map[string]string{"kubernetes.io/ingress.class": *test.ann}
+		}
+		if actual := c.IsValidIngress(ing); actual != test.expected {
+			t.Errorf("%d: expected %t but was %t", i, test.expected, actual)
+		}
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+type syncTimerMock struct {
+	deadline time.Time
+	wait     time.Duration
+	f        func()
+	fired    bool
+	stopped  bool
+}
+
+func (t *syncTimerMock) Stop() bool {
+	if t.fired {
+		return false
+	}
+	t.stopped = true
+	return true
+}
+
+func (t *syncTimerMock) fire(now time.Time) {
+	if !t.fired && !t.stopped && !now.Before(t.deadline) {
+		t.fired = true
+		t.f()
+	}
+}
+
+type queueMock struct {
+	utils.Queue
+	notify int
+}
+
+func (q *queueMock) Notify() {
+	q.notify++
+}
+
+func TestSyncTimings(t *testing.T) {
+	type event struct {
+		// at is the time of the change notification since the first one
+		at time.Duration
+		// swap consumes the pending changes before this notification,
+		// like an update does
+		swap bool
+	}
+	testCases := []struct {
+		waitBeforeUpdate time.Duration
+		syncQuietPeriod  time.Duration
+		syncMaxWait      time.Duration
+		events           []event
+		expWaits         []time.Duration
+		expStopped       []bool
+		expNotify        int
+	}{
+		// 0 - quiet period disabled, notify once after wait-before-update
+		{
+			waitBeforeUpdate: time.Second,
+			syncMaxWait:      10 * time.Second,
+			events:           []event{{at: 0}, {at: 100 * time.Millisecond}, {at: 200 * time.Millisecond}},
+			expWaits:         []time.Duration{time.Second},
+			expStopped:       []bool{false},
+			expNotify:        1,
+		},
+		// 1 - quiet period disabled, a new notification after an update
+		{
+			waitBeforeUpdate: time.Second,
+			syncMaxWait:      10 * time.Second,
+			events:           []event{{at: 0}, {at: 100 * time.Millisecond}, {at: 2 * time.Second, swap: true}},
+			expWaits:         []time.Duration{time.Second, time.Second},
+			expStopped:       []bool{false, false},
+			expNotify:        2,
+		},
+		// 2 - every change restarts the quiet period
+		{
+			syncQuietPeriod: 500 * time.Millisecond,
+			syncMaxWait:     10 * time.Second,
+			events:          []event{{at: 0}, {at: 200 * time.Millisecond}, {at: 400 * time.Millisecond}},
+			expWaits:        []time.Duration{500 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond},
+			expStopped:      []bool{true, true, false},
+			expNotify:       1,
+		},
+		// 3 - a continuous stream of changes is capped by sync-max-wait
+		{
+			syncQuietPeriod: 500 * time.Millisecond,
+			syncMaxWait:     time.Second,
+			events:          []event{{at: 0}, {at: 300 * time.Millisecond}, {at: 600 * time.Millisecond}, {at: 900 * time.Millisecond}},
+			expWaits:        []time.Duration{500 * time.Millisecond, 500 * time.Millisecond, 400 * time.Millisecond, 100 * time.Millisecond},
+			expStopped:      []bool{true, true, true, false},
+			expNotify:       1,
+		},
+		// 4 - sync-max-wait counts from the first change after an update
+		{
+			syncQuietPeriod: 500 * time.Millisecond,
+			syncMaxWait:     time.Second,
+			events:          []event{{at: 0}, {at: 400 * time.Millisecond}, {at: 800 * time.Millisecond}, {at: 1100 * time.Millisecond, swap: true}, {at: 1400 * time.Millisecond}},
+			expWaits:        []time.Duration{500 * time.Millisecond, 500 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond},
+			expStopped:      []bool{true, true, false, true, false},
+			expNotify:       2,
+		},
+	}
+	start := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, test := range testCases {
+		var now time.Time
+		var timers []*syncTimerMock
+		queue := &queueMock{}
+		c := &k8scache{
+			updateQueue:      queue,
+			waitBeforeUpdate: test.waitBeforeUpdate,
+			syncQuietPeriod:  test.syncQuietPeriod,
+			syncMaxWait:      test.syncMaxWait,
+			now:              func() time.Time { return now },
+			afterFunc: func(d time.Duration, f func()) syncTimer {
+				timer := &syncTimerMock{deadline: now.Add(d), wait: d, f: f}
+				timers = append(timers, timer)
+				return timer
+			},
+			clear: true,
+		}
+		for _, ev := range test.events {
+			now = start.Add(ev.at)
+			for _, timer := range timers {
+				timer.fire(now)
+			}
+			if ev.swap {
+				c.SwapChangedObjects()
+			}
+			c.Notify(nil, nil)
+		}
+		now = now.Add(time.Hour)
+		var waits []time.Duration
+		var stopped []bool
+		for _, timer := range timers {
+			timer.fire(now)
+			waits = append(waits, timer.wait)
+			stopped = append(stopped, timer.stopped)
+		}
+		if !reflect.DeepEqual(waits, test.expWaits) {
+			t.Errorf("waits differ on %d - expected: %v, actual: %v", i, test.expWaits, waits)
+		}
+		if !reflect.DeepEqual(stopped, test.expStopped) {
+			t.Errorf("stopped timers differ on %d - expected: %v, actual: %v", i, test.expStopped, stopped)
+		}
+		if queue.notify != test.expNotify {
+			t.Errorf("notifications differ on %d - expected: %d, actual: %d", i, test.expNotify, queue.notify)
+		}
+	}
+}
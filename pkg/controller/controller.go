@@ -18,8 +18,14 @@ package controller
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -27,6 +33,7 @@ import (
 	api "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
+	gateway "sigs.k8s.io/gateway-api/apis/v1alpha1"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/acme"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress"
@@ -36,6 +43,7 @@ import (
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/tracker"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/logsink"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/version"
@@ -61,6 +69,51 @@ type HAProxyController struct {
 	reloadStrategy    *string
 	maxOldConfigFiles *int
 	validateConfig    *bool
+	rollbackOnFailure *bool
+	reloadFailInitial *time.Duration
+	reloadFailMax     *time.Duration
+	reloadFailCount   int
+	reloadFailTimer   *time.Timer
+	adminAPIPort      *int
+	adminAPITokenFile *string
+	webhookPort       *int
+	webhookCertFile   *string
+	webhookKeyFile    *string
+	dryRun            *bool
+	check             *bool
+	dryRunMutex       sync.Mutex
+	syncMutex         sync.Mutex
+	optionsConfigMap  *string
+	optionsMutex      sync.Mutex
+	defaultOptions    runtimeOptions
+	currentOptions    runtimeOptions
+	acmeCheckPeriodCh chan struct{}
+	drainTimeout      *time.Duration
+	socketHandoffDir  *string
+	drainCh           chan struct{}
+	readyCh           chan struct{}
+	reloadHistorySize *int
+	haproxyMode       *string
+	fleetMembers      *[]string
+	fleetTokenFile    *string
+	fleetCAFile       *string
+	fleetHealthTime   *time.Duration
+	geoipCheckPeriod  *time.Duration
+	jwksRefreshPeriod *time.Duration
+	hostMetricsPeriod *time.Duration
+	logFormat         *string
+	logSinkType       *string
+	logSinkEndpoint   *string
+	logSinkListen     *string
+	logSinkBatchSize  *int
+	logSinkBatchWait  *time.Duration
+	logSinkBufferSize *int
+	otlpEndpoint      *string
+	otlpInsecure      *bool
+	otlpSamplingRatio *float64
+	reloads           *reloadHistory
+	tracing           *tracing
+	logSink           logsink.Sink
 }
 
 // NewHAProxyController constructor
@@ -82,6 +135,12 @@ func (hc *HAProxyController) Info() *ingress.BackendInfo {
 func (hc *HAProxyController) Start() {
 	hc.controller = controller.NewIngressController(hc)
 	hc.configController()
+	if *hc.dryRun {
+		hc.runDryRun()
+	}
+	if *hc.check {
+		hc.runCheck()
+	}
 	hc.startServices()
 	hc.logger.Info("HAProxy Ingress successfully initialized")
 	//
@@ -96,20 +155,56 @@ func (hc *HAProxyController) configController() {
 	}
 	hc.cfg = hc.controller.GetConfig()
 	hc.stopCh = hc.controller.GetStopCh()
+	hc.drainCh = make(chan struct{})
+	hc.readyCh = make(chan struct{})
 	hc.controller.SetNewCtrl(hc)
-	hc.logger = &logger{depth: 1}
+	hc.logger = newLogger(*hc.logFormat)
 	hc.metrics = createMetrics(hc.cfg.BucketsResponseTime)
 	hc.ingressQueue = utils.NewRateLimitingQueue(hc.cfg.RateLimitUpdate, hc.syncIngress)
+	hc.reloads = newReloadHistory(hc.logger, hc.metrics, *hc.reloadHistorySize)
+	tracing, err := createTracing(*hc.otlpEndpoint, *hc.otlpInsecure, *hc.otlpSamplingRatio)
+	if err != nil {
+		glog.Fatalf("error configuring the OTLP exporter: %v", err)
+	}
+	hc.tracing = tracing
+	if *hc.logSinkType != "" {
+		hc.logSink, err = logsink.NewSink(logsink.Options{
+			Logger:     hc.logger.withComponent("logsink"),
+			Metrics:    hc.metrics,
+			Listen:     *hc.logSinkListen,
+			Type:       *hc.logSinkType,
+			Endpoint:   *hc.logSinkEndpoint,
+			BatchSize:  *hc.logSinkBatchSize,
+			BatchWait:  *hc.logSinkBatchWait,
+			BufferSize: *hc.logSinkBufferSize,
+		})
+		if err != nil {
+			glog.Fatalf("error configuring the log sink: %v", err)
+		}
+	}
 	hc.tracker = tracker.NewTracker()
 	hc.dynamicConfig = &convtypes.DynamicConfig{
 		StaticCrossNamespaceSecrets: hc.cfg.AllowCrossNamespace,
 	}
 	hc.cache = createCache(hc.logger, hc.controller, hc.tracker, hc.dynamicConfig, hc.ingressQueue)
+	hc.defaultOptions = runtimeOptions{
+		rateLimitUpdate:  hc.cfg.RateLimitUpdate,
+		waitBeforeUpdate: hc.cfg.WaitBeforeUpdate,
+		syncQuietPeriod:  hc.cfg.SyncQuietPeriod,
+		syncMaxWait:      hc.cfg.SyncMaxWait,
+		logLevel:         commandLineLogLevel(),
+		acmeCheckPeriod:  hc.cfg.AcmeCheckPeriod,
+	}
+	hc.currentOptions = hc.defaultOptions
+	if *hc.optionsConfigMap != "" {
+		hc.watchOptions(hc.cache.listers.configMapInformer)
+	}
 	var acmeSigner acme.Signer
 	if hc.cfg.AcmeServer {
 		electorID := fmt.Sprintf("%s-%s", hc.cfg.AcmeElectionID, hc.cfg.IngressClass)
-		hc.leaderelector = NewLeaderElector(electorID, hc.logger, hc.cache, hc)
-		acmeSigner = acme.NewSigner(hc.logger, hc.cache, hc.metrics)
+		hc.leaderelector = NewLeaderElector(hc.cfg, electorID, hc.logger, hc.cache, hc)
+		acmeSigner = acme.NewSigner(hc.logger.withComponent("acme"), hc.cache, hc.metrics)
+		hc.acmeCheckPeriodCh = make(chan struct{}, 1)
 		hc.acmeQueue = utils.NewFailureRateLimitingQueue(
 			hc.cfg.AcmeFailInitialDuration,
 			hc.cfg.AcmeFailMaxDuration,
@@ -119,27 +214,36 @@ func (hc *HAProxyController) configController() {
 	instanceOptions := haproxy.InstanceOptions{
 		HAProxyCfgDir:     "/etc/haproxy",
 		HAProxyMapsDir:    ingress.DefaultMapsDirectory,
+		HAProxyPidFile:    "/var/run/haproxy/haproxy.pid",
+		HandoffSocket:     hc.handoffSocket(),
 		BackendShards:     hc.cfg.BackendShards,
+		DrainTimeout:      *hc.drainTimeout,
+		Fleet:             hc.createFleetOptions(),
 		AcmeSigner:        acmeSigner,
 		AcmeQueue:         hc.acmeQueue,
 		LeaderElector:     hc.leaderelector,
+		MasterSocket:      hc.cfg.MasterSocket,
 		Metrics:           hc.metrics,
+		Process:           *hc.haproxyMode,
 		ReloadStrategy:    *hc.reloadStrategy,
 		MaxOldConfigFiles: *hc.maxOldConfigFiles,
 		SortEndpointsBy:   hc.cfg.SortEndpointsBy,
+		RollbackOnFailure: *hc.rollbackOnFailure,
 		StopCh:            hc.stopCh,
 		ValidateConfig:    *hc.validateConfig,
 	}
-	hc.instance = haproxy.CreateInstance(hc.logger, instanceOptions)
+	hc.instance = haproxy.CreateInstance(hc.logger.withComponent("instance"), instanceOptions)
 	if err := hc.instance.ParseTemplates(); err != nil {
 		glog.Fatalf("error creating HAProxy instance: %v", err)
 	}
 	hc.converterOptions = &convtypes.ConverterOptions{
-		Logger:           hc.logger,
+		Logger:           hc.logger.withComponent("converter"),
 		Cache:            hc.cache,
 		Tracker:          hc.tracker,
 		DynamicConfig:    hc.dynamicConfig,
 		MasterSocket:     hc.cfg.MasterSocket,
+		GlobalResource:   hc.cfg.GlobalResource,
+		HandoffSocket:    hc.handoffSocket(),
 		AnnotationPrefix: hc.cfg.AnnPrefix,
 		DefaultBackend:   hc.cfg.DefaultService,
 		DefaultCrtSecret: hc.cfg.DefaultSSLCertificate,
@@ -147,37 +251,137 @@ func (hc *HAProxyController) configController() {
 		FakeCAFile:       hc.createFakeCAFile(),
 		AcmeTrackTLSAnn:  hc.cfg.AcmeTrackTLSAnn,
 		HasGateway:       hc.cache.hasGateway(),
+		HasCRDs:          hc.cache.hasCRDs(),
+		AvailableCPUs:    utils.AvailableCPUs(),
+		SnippetChecker:   haproxy.NewSnippetChecker(),
+	}
+}
+
+// handoffSocket is the admin socket used to hand off the listening sockets
+// between haproxy instances of distinct controller pods, see --socket-handoff-dir
+func (hc *HAProxyController) handoffSocket() string {
+	if *hc.socketHandoffDir == "" {
+		return ""
+	}
+	return filepath.Join(*hc.socketHandoffDir, "haproxy.sock")
+}
+
+func (hc *HAProxyController) createFleetOptions() *haproxy.FleetOptions {
+	if len(*hc.fleetMembers) == 0 {
+		return nil
+	}
+	for _, member := range *hc.fleetMembers {
+		if !strings.HasPrefix(member, "https://") {
+			glog.Fatalf("external fleet member should use https: %s", member)
+		}
+	}
+	if *hc.fleetTokenFile == "" {
+		glog.Fatalf("--external-fleet-token-file is mandatory in the external fleet mode")
+	}
+	content, err := ioutil.ReadFile(*hc.fleetTokenFile)
+	if err != nil {
+		glog.Fatalf("error reading external fleet token: %v", err)
+	}
+	token := strings.TrimSpace(string(content))
+	var rootCAs *x509.CertPool
+	if *hc.fleetCAFile != "" {
+		ca, err := ioutil.ReadFile(*hc.fleetCAFile)
+		if err != nil {
+			glog.Fatalf("error reading external fleet CA: %v", err)
+		}
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(ca) {
+			glog.Fatalf("external fleet CA file %s does not have any PEM encoded certificate", *hc.fleetCAFile)
+		}
+	}
+	hc.logger.Info("external fleet mode, pushing configuration to %d member(s): %v", len(*hc.fleetMembers), *hc.fleetMembers)
+	return &haproxy.FleetOptions{
+		Members: *hc.fleetMembers,
+		Token:   token,
+		RootCAs: rootCAs,
+		Dirs: []string{
+			"/etc/haproxy",
+			ingress.DefaultCrtDirectory,
+			ingress.DefaultDHParamDirectory,
+			ingress.DefaultCACertsDirectory,
+			ingress.DefaultCrlDirectory,
+		},
+		HealthTimeout: *hc.fleetHealthTime,
 	}
 }
 
 func (hc *HAProxyController) startServices() {
 	hc.cache.RunAsync(hc.stopCh)
 	go hc.ingressQueue.Run()
+	if hc.logSink != nil {
+		// listening before haproxy starts, so its first logs aren't lost
+		if err := hc.logSink.Start(); err != nil {
+			hc.logger.Fatal("error starting the log sink: %v", err)
+		}
+	}
 	if hc.cfg.StatsCollectProcPeriod.Milliseconds() > 0 {
 		go wait.Until(func() {
 			hc.instance.CalcIdleMetric()
+			hc.instance.CalcOldProcsMetric()
+			hc.instance.CalcEjectionsMetric()
+			hc.instance.CalcBlockedUserAgentsMetric()
 		}, hc.cfg.StatsCollectProcPeriod, hc.stopCh)
 	}
+	if *hc.hostMetricsPeriod > 0 {
+		go wait.Until(hc.instance.CalcHostTrafficMetric, *hc.hostMetricsPeriod, hc.stopCh)
+	}
+	if *hc.geoipCheckPeriod > 0 {
+		go wait.Until(func() {
+			if hc.instance.GeoIPOutdated() {
+				hc.logger.Info("GeoIP database changed, scheduling an update")
+				hc.ingressQueue.Notify()
+			}
+		}, *hc.geoipCheckPeriod, hc.stopCh)
+	}
+	if *hc.jwksRefreshPeriod > 0 {
+		go wait.Until(func() {
+			if hc.cache.jwks.refresh() {
+				// backends using the changed key sets aren't tracked
+				hc.logger.Info("JWKS changed, scheduling a full sync")
+				hc.cache.Notify(nil, nil)
+			}
+		}, *hc.jwksRefreshPeriod, hc.stopCh)
+	}
 	if hc.leaderelector != nil {
 		go hc.leaderelector.Run(hc.stopCh)
 	}
 	if hc.cfg.AcmeServer {
 		// TODO deduplicate acme socket
-		server := acme.NewServer(hc.logger, "/var/run/haproxy/acme.sock", hc.cache)
+		server := acme.NewServer(hc.logger.withComponent("acme"), "/var/run/haproxy/acme.sock", hc.cache)
 		// TODO move goroutine from the server to the controller
 		if err := server.Listen(hc.stopCh); err != nil {
 			hc.logger.Fatal("error creating the acme server listener: %v", err)
 		}
 		go hc.acmeQueue.Run()
-		go wait.JitterUntil(func() {
-			_, _ = hc.instance.AcmeCheck("periodic check")
-		}, hc.cfg.AcmeCheckPeriod, 0, false, hc.stopCh)
+		go hc.acmePeriodicCheck()
+	}
+	if *hc.adminAPIPort > 0 {
+		token, err := ioutil.ReadFile(*hc.adminAPITokenFile)
+		if err != nil {
+			hc.logger.Fatal("error reading admin API token: %v", err)
+		}
+		if len(strings.TrimSpace(string(token))) == 0 {
+			hc.logger.Fatal("admin API token file is empty: %s", *hc.adminAPITokenFile)
+		}
+		adminAPI := newAdminAPI(hc.logger, strings.TrimSpace(string(token)), ingress.DefaultMapsDirectory, func() string {
+			return hc.instance.Config().Global().AdminSocket
+		}, hc.dump, hc.reloads.list)
+		adminAPI.Listen(*hc.adminAPIPort, hc.stopCh)
+	}
+	if *hc.webhookPort > 0 {
+		webhook := newAdmissionWebhook(hc.logger, *hc.webhookCertFile, *hc.webhookKeyFile, hc.validateIngress)
+		webhook.Listen(*hc.webhookPort, hc.stopCh)
 	}
 	hc.controller.StartAsync()
 }
 
 func (hc *HAProxyController) stopServices() {
-	hc.ingressQueue.ShutDown()
+	// ingressQueue and instance are stopped by Stop() before closing stopCh
 	if hc.acmeQueue != nil {
 		hc.acmeQueue.ShutDown()
 	}
@@ -229,13 +433,28 @@ func (hc *HAProxyController) OnNewLeader(identity string) {
 	hc.logger.Info("leader changed to %s", identity)
 }
 
-// Stop shutdown the controller process
+// Stop shutdown the controller process. Updates are stopped first, so a
+// reload cannot enable haproxy again. Haproxy and the controller start to
+// fail their health checks, giving --wait-before-shutdown to the load
+// balancers to deregister this instance, and the running requests have
+// up to --drain-timeout to finish before the workers are stopped.
 func (hc *HAProxyController) Stop() error {
+	hc.logger.Info("stopping haproxy updates")
+	hc.ingressQueue.ShutDown()
+	close(hc.drainCh)
+	hc.instance.Drain()
 	if hc.cfg.WaitBeforeShutdown > 0 {
 		waitBeforeShutdown := time.Duration(hc.cfg.WaitBeforeShutdown) * time.Second
 		glog.Infof("Waiting %v before stopping components", waitBeforeShutdown)
 		time.Sleep(waitBeforeShutdown)
 	}
+	hc.instance.Shutdown()
+	if hc.logSink != nil {
+		hc.logSink.Shutdown()
+	}
+	if err := hc.tracing.Shutdown(); err != nil {
+		hc.logger.Warn("error flushing the pending spans: %v", err)
+	}
 	err := hc.controller.Stop()
 	return err
 }
@@ -247,6 +466,14 @@ func (hc *HAProxyController) GetIngressList() ([]*networking.Ingress, error) {
 }
 
 // GetSecret ...
+// implements oldcontroller.NewCtrlIntf
+func (hc *HAProxyController) GetGatewayList() ([]*gateway.Gateway, error) {
+	if !hc.cache.hasGateway() {
+		return nil, nil
+	}
+	return hc.cache.GetGatewayList()
+}
+
 // implements oldcontroller.NewCtrlIntf
 func (hc *HAProxyController) GetSecret(name string) (*api.Secret, error) {
 	return hc.cache.GetSecret(name)
@@ -270,6 +497,31 @@ func (hc *HAProxyController) DefaultIngressClass() string {
 
 // Check health check implementation
 func (hc *HAProxyController) Check(_ *http.Request) error {
+	select {
+	case <-hc.drainCh:
+		return fmt.Errorf("controller is shutting down")
+	default:
+	}
+	return nil
+}
+
+// Ready implements the readiness check: the controller is ready after the
+// initial sync of the cache and the first successful haproxy update, and
+// until it starts to shut down.
+func (hc *HAProxyController) Ready() error {
+	select {
+	case <-hc.drainCh:
+		return fmt.Errorf("controller is shutting down")
+	default:
+	}
+	if hc.cache == nil || !hc.cache.listers.running {
+		return fmt.Errorf("waiting for the initial sync of the Kubernetes objects")
+	}
+	select {
+	case <-hc.readyCh:
+	default:
+		return fmt.Errorf("waiting for the first successful haproxy update")
+	}
 	return nil
 }
 
@@ -288,6 +540,70 @@ func (hc *HAProxyController) ConfigureFlags(flags *pflag.FlagSet) {
 		`Maximum old haproxy timestamped config files to allow before being cleaned up. A value <= 0 indicates a single non-timestamped config file will be used`)
 	hc.validateConfig = flags.Bool("validate-config", false,
 		`Define if the resulting configuration files should be validated when a dynamic update was applied. Default value is false, which means the validation will only happen when HAProxy need to be reloaded.`)
+	hc.rollbackOnFailure = flags.Bool("rollback-on-failure", false,
+		`Define if the configuration files of the last successful reload should be restored if the configuration validation or the haproxy reload fails. Failed updates are retried with an exponential backoff while haproxy is running the last known good configuration.`)
+	hc.reloadFailInitial = flags.Duration("reload-fail-initial-duration", 5*time.Second,
+		`The initial time to wait before retry a failed haproxy update. Only used if --rollback-on-failure is enabled.`)
+	hc.reloadFailMax = flags.Duration("reload-fail-max-duration", 5*time.Minute,
+		`The time between retries of failed haproxy updates will exponentially grow up to the max duration time.`)
+	hc.adminAPIPort = flags.Int("admin-api-port", 0,
+		`Port number of the admin API, an authenticated HTTP API that proxies some of the haproxy's runtime API commands. Default value is 0, which means the admin API is disabled.`)
+	hc.adminAPITokenFile = flags.String("admin-api-token-file", "",
+		`Path to a file with the bearer token used to authenticate the admin API requests. Mandatory if --admin-api-port is configured.`)
+	hc.webhookPort = flags.Int("admission-webhook-port", 0,
+		`Port number of the validating admission webhook of ingress resources, served over TLS on the /validate/ingress path. Ingress resources whose configuration would be ignored by the controller, e.g. invalid annotation values or snippets not allowed by the snippet policy, are denied. Default value is 0, which means the admission webhook is disabled.`)
+	hc.webhookCertFile = flags.String("admission-webhook-cert-file", "",
+		`Path to the PEM encoded certificate of the admission webhook server. Mandatory if --admission-webhook-port is configured.`)
+	hc.webhookKeyFile = flags.String("admission-webhook-key-file", "",
+		`Path to the PEM encoded private key of the admission webhook server. Mandatory if --admission-webhook-port is configured.`)
+	hc.dryRun = flags.Bool("dry-run", false,
+		`Parses all the Kubernetes objects, renders and validates the haproxy configuration files in a temporary directory, prints them in the standard output and exits, without starting or changing haproxy. Exits with status 1 if the configuration is invalid. The same output is also available in the /dry-run endpoint of the healthz port.`)
+	hc.check = flags.Bool("check", false,
+		`Parses all the Kubernetes objects, validates the resulting haproxy configuration, prints a summary of the warnings and the outcome of the validation and exits, without starting or changing haproxy. Exits with status 1 if the configuration is invalid, can be used as a preflight check of a deployment.`)
+	hc.reloadHistorySize = flags.Int("reload-history-size", 20,
+		`Number of the most recent haproxy reloads and their causes kept in memory and listed by the /api/v1/reloads endpoint of the admin API.`)
+	hc.haproxyMode = flags.String("haproxy-mode", "",
+		`How the controller relates with the haproxy process. Options are: embedded, haproxy is a child process of the controller; sidecar, haproxy runs in another container of the same pod and is managed via --master-socket; external, haproxy runs outside of the cluster and is managed via --external-fleet. Default value is inferred from --master-socket and --external-fleet.`)
+	hc.fleetMembers = flags.StringSlice("external-fleet", nil,
+		`Comma-separated list of base URLs of the fleet agents. Configures the external fleet mode, where the configuration files are pushed to haproxy instances running outside of the cluster.`)
+	hc.fleetTokenFile = flags.String("external-fleet-token-file", "",
+		`Path to a file with the bearer token used to authenticate the requests to the fleet agents. Mandatory in the external fleet mode.`)
+	hc.fleetCAFile = flags.String("external-fleet-ca-file", "",
+		`Path to a file with the PEM encoded CA certificates used to validate the certificate of the fleet agents. Default is to use the system's CAs.`)
+	hc.fleetHealthTime = flags.Duration("external-fleet-health-timeout", 30*time.Second,
+		`Maximum time to wait a fleet member to be healthy after a reload, before the reload is considered failed.`)
+	hc.geoipCheckPeriod = flags.Duration("geoip-check-period", time.Minute,
+		`Time between checks of changes in the GeoIP database configured in the geoip-database global option. A changed database is converted again and haproxy is reloaded. A value of 0 disables the check, and a changed database is only read in the next update.`)
+	hc.jwksRefreshPeriod = flags.Duration("jwks-refresh-period", time.Hour,
+		`Time between fetches of the JSON Web Key Sets configured in the jwt-jwks-url configuration key. Haproxy is reloaded if the keys changed. A value of 0 disables the refresh, and the key sets are only fetched when they are used for the first time.`)
+	hc.hostMetricsPeriod = flags.Duration("host-metrics-period", 0,
+		`Time between reads of the haproxy stats used to aggregate the traffic of the backends into per hostname metrics: requests, 5xx responses and response time, labeled with the namespace and name of the Ingress that declared the paths of the hostname. Default value is 0, which means the per hostname metrics are disabled.`)
+	hc.optionsConfigMap = flags.String("controller-options-configmap", "",
+		`Name of a ConfigMap, in the namespace/name format, with command-line options that should be changed without restarting the controller. Supported options are rate-limit-update, wait-before-update, sync-quiet-period, sync-max-wait, v and acme-check-period. Options missing in the ConfigMap, or if the ConfigMap does not exist, use the value of the command line.`)
+	hc.drainTimeout = flags.Duration("drain-timeout", 0,
+		`Maximum time to wait the running requests to finish when the controller is shutting down, after haproxy stops listening. Haproxy is hard-stopped if the timeout expires. Default value is 0, which means the controller does not wait. Only used if haproxy is embedded in the controller.`)
+	hc.socketHandoffDir = flags.String("socket-handoff-dir", "",
+		`Directory shared by the controller pods of the same node, e.g. a hostPath volume, where haproxy exposes its listening sockets. A new controller pod takes over the listening sockets of the haproxy of the previous pod on its first start, so no connection is refused or reset during a rolling update. Default value is empty, which means the sockets are bound on the first start. Only used if haproxy is embedded in the controller.`)
+	hc.logFormat = flags.String("log-format", logFormatText,
+		`Format of the log lines of the controller, the haproxy instance and the converters. Options are text and json. json writes one object per line with the level, timestamp, component, referenced Kubernetes object and haproxy update id.`)
+	hc.logSinkType = flags.String("log-sink-type", "",
+		`Type of the log store that receives the haproxy access logs shipped by the controller. Options are loki, elasticsearch and kafka. Default value is empty, which means the log sink is disabled.`)
+	hc.logSinkEndpoint = flags.String("log-sink-endpoint", "",
+		`Address of the log store configured in --log-sink-type: the base URL of Loki; the URL of Elasticsearch followed by the index name; or a comma-separated list of Kafka brokers followed by the topic name. Mandatory if --log-sink-type is configured.`)
+	hc.logSinkListen = flags.String("log-sink-listen", "unix@/var/run/haproxy/logsink.sock",
+		`Address where the log sink receives the haproxy access logs: unix@<path> for a unix socket, configured as the syslog-endpoint; or tcp@<host>:<port>, configured as a server of a log-ring.`)
+	hc.logSinkBatchSize = flags.Int("log-sink-batch-size", 500,
+		`Maximum number of access log records shipped in a single request to the log store.`)
+	hc.logSinkBatchWait = flags.Duration("log-sink-batch-wait", time.Second,
+		`Maximum time to wait for a batch to be filled before shipping it to the log store.`)
+	hc.logSinkBufferSize = flags.Int("log-sink-buffer-size", 10000,
+		`Maximum number of access log records waiting to be shipped. The log sink stops reading logs from haproxy while the buffer is full.`)
+	hc.otlpEndpoint = flags.String("otlp-endpoint", "",
+		`Host and port, in the host:port format, of an OTLP/HTTP collector that receives the OpenTelemetry spans of the haproxy updates. Every update is a span, with child spans for the converters, the configuration rendering and the haproxy reload. Default value is empty, which means tracing is disabled.`)
+	hc.otlpInsecure = flags.Bool("otlp-insecure", false,
+		`Use plain HTTP instead of HTTPS to connect to the OTLP collector configured in --otlp-endpoint.`)
+	hc.otlpSamplingRatio = flags.Float64("otlp-sampling-ratio", 1,
+		`Ratio of the haproxy updates that should be traced, from 0 to 1. Default value is 1, which means all the updates are traced.`)
 	ingressClass := flags.Lookup("ingress-class")
 	if ingressClass != nil {
 		ingressClass.Value.Set("haproxy")
@@ -300,6 +616,80 @@ func (hc *HAProxyController) OverrideFlags(flags *pflag.FlagSet) {
 	if !(*hc.reloadStrategy == "native" || *hc.reloadStrategy == "reusesocket" || *hc.reloadStrategy == "multibinder") {
 		glog.Fatalf("Unsupported reload strategy: %v", *hc.reloadStrategy)
 	}
+	if *hc.adminAPIPort > 0 && *hc.adminAPITokenFile == "" {
+		glog.Fatalf("--admin-api-token-file is mandatory if --admin-api-port is configured")
+	}
+	if *hc.webhookPort > 0 && (*hc.webhookCertFile == "" || *hc.webhookKeyFile == "") {
+		glog.Fatalf("--admission-webhook-cert-file and --admission-webhook-key-file are mandatory if --admission-webhook-port is configured")
+	}
+	if *hc.logFormat != logFormatText && *hc.logFormat != logFormatJSON {
+		glog.Fatalf("unsupported --log-format option: %s", *hc.logFormat)
+	}
+	if *hc.dryRun && *hc.check {
+		glog.Fatalf("--dry-run and --check cannot be used together")
+	}
+	if *hc.optionsConfigMap != "" && len(strings.Split(*hc.optionsConfigMap, "/")) != 2 {
+		glog.Fatalf("--controller-options-configmap should be in the namespace/name format: %s", *hc.optionsConfigMap)
+	}
+	var masterSocket string
+	if flag := flags.Lookup("master-socket"); flag != nil {
+		masterSocket = flag.Value.String()
+	}
+	hasFleet := len(*hc.fleetMembers) > 0
+	if hasFleet && masterSocket != "" {
+		glog.Fatalf("--external-fleet and --master-socket cannot be used together")
+	}
+	switch *hc.haproxyMode {
+	case "":
+		if hasFleet {
+			*hc.haproxyMode = haproxy.ProcessExternal
+		} else if masterSocket != "" {
+			*hc.haproxyMode = haproxy.ProcessSidecar
+		} else {
+			*hc.haproxyMode = haproxy.ProcessEmbedded
+		}
+	case haproxy.ProcessEmbedded:
+		if hasFleet || masterSocket != "" {
+			glog.Fatalf("--haproxy-mode=embedded cannot be used with --master-socket or --external-fleet")
+		}
+	case haproxy.ProcessSidecar:
+		if masterSocket == "" {
+			glog.Fatalf("--master-socket is mandatory if --haproxy-mode=sidecar")
+		}
+	case haproxy.ProcessExternal:
+		if !hasFleet {
+			glog.Fatalf("--external-fleet is mandatory if --haproxy-mode=external")
+		}
+	default:
+		glog.Fatalf("Unsupported haproxy mode: %v", *hc.haproxyMode)
+	}
+	if *hc.socketHandoffDir != "" {
+		if *hc.haproxyMode != haproxy.ProcessEmbedded {
+			glog.Fatalf("--socket-handoff-dir can only be used if haproxy is embedded in the controller")
+		}
+		if info, err := os.Stat(*hc.socketHandoffDir); err != nil || !info.IsDir() {
+			glog.Fatalf("--socket-handoff-dir should be an existing directory: %s", *hc.socketHandoffDir)
+		}
+	}
+	if *hc.reloadHistorySize < 0 {
+		glog.Fatalf("--reload-history-size cannot be negative: %d", *hc.reloadHistorySize)
+	}
+	if *hc.logSinkType != "" {
+		switch *hc.logSinkType {
+		case "loki", "elasticsearch", "kafka":
+		default:
+			glog.Fatalf("unsupported --log-sink-type option: %s", *hc.logSinkType)
+		}
+		if *hc.logSinkEndpoint == "" {
+			glog.Fatalf("--log-sink-endpoint is mandatory if --log-sink-type is configured")
+		}
+		if *hc.logSinkBatchSize <= 0 || *hc.logSinkBatchWait <= 0 || *hc.logSinkBufferSize <= 0 {
+			glog.Fatalf("--log-sink-batch-size, --log-sink-batch-wait and --log-sink-buffer-size should be greater than zero")
+		}
+	}
+	if *hc.otlpSamplingRatio < 0 || *hc.otlpSamplingRatio > 1 {
+		glog.Fatalf("--otlp-sampling-ratio should be between 0 and 1: %v", *hc.otlpSamplingRatio)
+	}
 }
 
 // SetConfig receives the ConfigMap the user has configured
@@ -313,15 +703,73 @@ func (hc *HAProxyController) syncIngress(item interface{}) {
 		return
 	}
 
+	hc.syncMutex.Lock()
+	defer hc.syncMutex.Unlock()
 	hc.updateCount++
+	hc.logger.setSyncID(hc.updateCount)
+	defer hc.logger.setSyncID(0)
 	hc.logger.Info("starting haproxy update id=%d", hc.updateCount)
-	timer := utils.NewTimer(hc.metrics.ControllerProcTime)
+	span := hc.tracing.startUpdate(hc.updateCount)
+	timer := utils.NewTimer(span.observer(hc.metrics.ControllerProcTime))
 
-	converters.NewConverter(timer, hc.instance.Config(), hc.converterOptions).Sync()
+	changed := converters.NewConverter(timer, hc.instance.Config(), hc.converterOptions).Sync()
 
 	//
 	// update proxy
 	//
 	hc.instance.Update(timer)
+	hc.reloads.track(hc.updateCount, changed, hc.instance.LastReload())
+	span.end(changed, hc.instance.LastReload())
+	hc.checkReady()
+	hc.checkDegraded()
 	hc.logger.Info("finish haproxy update id=%d: %s", hc.updateCount, timer.AsString("total"))
 }
+
+// checkReady flags the controller as ready after the first successful
+// haproxy reload. Haproxy is always reloaded in the first update, and
+// the first update only happens after the initial sync of the cache.
+func (hc *HAProxyController) checkReady() {
+	select {
+	case <-hc.readyCh:
+		return
+	default:
+	}
+	if reload := hc.instance.LastReload(); reload != nil && reload.Success {
+		hc.logger.Info("haproxy successfully updated, controller is ready")
+		close(hc.readyCh)
+	}
+}
+
+// checkDegraded schedules a new update, using an exponential backoff,
+// if haproxy is running the last known good configuration due to a
+// failed update. A single timer is used, so a burst of failed updates
+// schedules only one retry.
+func (hc *HAProxyController) checkDegraded() {
+	if !hc.instance.Degraded() {
+		if hc.reloadFailTimer != nil {
+			hc.reloadFailTimer.Stop()
+		}
+		if hc.reloadFailCount > 0 {
+			hc.cache.RecordControllerEvent(api.EventTypeNormal, "UpdateRecovered",
+				"haproxy successfully updated after %d failure(s)", hc.reloadFailCount)
+			hc.reloadFailCount = 0
+		}
+		return
+	}
+	wait := *hc.reloadFailInitial
+	for j := 0; j < hc.reloadFailCount && wait < *hc.reloadFailMax; j++ {
+		wait *= 2
+	}
+	if wait > *hc.reloadFailMax {
+		wait = *hc.reloadFailMax
+	}
+	hc.reloadFailCount++
+	hc.logger.Warn("haproxy update failed %d time(s), retrying in %v", hc.reloadFailCount, wait)
+	hc.cache.RecordControllerEvent(api.EventTypeWarning, "UpdateFailed",
+		"haproxy update failed, using the last known good configuration and retrying in %v", wait)
+	if hc.reloadFailTimer == nil {
+		hc.reloadFailTimer = time.AfterFunc(wait, func() { hc.ingressQueue.Notify() })
+	} else {
+		hc.reloadFailTimer.Reset(wait)
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+func TestReady(t *testing.T) {
+	testCases := []struct {
+		synced   bool
+		updated  bool
+		draining bool
+		expError string
+	}{
+		// 0
+		{
+			expError: "waiting for the initial sync of the Kubernetes objects",
+		},
+		// 1
+		{
+			synced:   true,
+			expError: "waiting for the first successful haproxy update",
+		},
+		// 2
+		{
+			synced:  true,
+			updated: true,
+		},
+		// 3
+		{
+			synced:   true,
+			updated:  true,
+			draining: true,
+			expError: "controller is shutting down",
+		},
+	}
+	for i, test := range testCases {
+		hc := &HAProxyController{
+			cache:   &k8scache{listers: &listers{running: test.synced}},
+			drainCh: make(chan struct{}),
+			readyCh: make(chan struct{}),
+		}
+		if test.updated {
+			close(hc.readyCh)
+		}
+		if test.draining {
+			close(hc.drainCh)
+		}
+		var errMsg string
+		if err := hc.Ready(); err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != test.expError {
+			t.Errorf("readiness differs on %d - expected: '%s' - actual: '%s'", i, test.expError, errMsg)
+		}
+	}
+}
+
+func TestCheckDegraded(t *testing.T) {
+	instance := &degradedInstanceMock{degraded: true}
+	queue := &notifyQueueMock{}
+	initial := 20 * time.Millisecond
+	max := 40 * time.Millisecond
+	hc := &HAProxyController{
+		logger:            newLogger(logFormatText),
+		cache:             &k8scache{},
+		instance:          instance,
+		ingressQueue:      queue,
+		reloadFailInitial: &initial,
+		reloadFailMax:     &max,
+	}
+	// a burst of failed updates schedules only one retry
+	for i := 0; i < 3; i++ {
+		hc.checkDegraded()
+	}
+	time.Sleep(200 * time.Millisecond)
+	if count := queue.count(); count != 1 {
+		t.Errorf("expected one retry after failed updates, found %d", count)
+	}
+	// a recovered instance cancels the scheduled retry
+	hc.checkDegraded()
+	instance.degraded = false
+	hc.checkDegraded()
+	time.Sleep(200 * time.Millisecond)
+	if count := queue.count(); count != 1 {
+		t.Errorf("expected no retry after recovering, found %d", count-1)
+	}
+	if hc.reloadFailCount != 0 {
+		t.Errorf("expected fail count reset after recovering, found %d", hc.reloadFailCount)
+	}
+}
+
+type degradedInstanceMock struct {
+	haproxy.Instance
+	degraded bool
+}
+
+func (i *degradedInstanceMock) Degraded() bool {
+	return i.degraded
+}
+
+type notifyQueueMock struct {
+	utils.Queue
+	mutex    sync.Mutex
+	notifies int
+}
+
+func (q *notifyQueueMock) Notify() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.notifies++
+}
+
+func (q *notifyQueueMock) count() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.notifies
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+// DryRun parses all the Kubernetes objects in a scratch configuration,
+// renders the configuration files in a temporary directory and validates
+// them. The configuration and the state of the running controller, as well
+// as the running haproxy, aren't changed.
+func (hc *HAProxyController) DryRun() (*ingress.DryRunResult, error) {
+	if !hc.cache.listers.running {
+		return nil, fmt.Errorf("object cache wasn't synced yet")
+	}
+	hc.dryRunMutex.Lock()
+	defer hc.dryRunMutex.Unlock()
+	dir, err := ioutil.TempDir("", "haproxy-dry-run-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	mapsDir := filepath.Join(dir, "maps")
+	if err := os.Mkdir(mapsDir, 0755); err != nil {
+		return nil, err
+	}
+	logger := &validationLogger{}
+	instance := haproxy.CreateInstance(logger, haproxy.InstanceOptions{
+		HAProxyCfgDir:   dir,
+		HAProxyMapsDir:  mapsDir,
+		BackendShards:   hc.cfg.BackendShards,
+		Process:         *hc.haproxyMode,
+		SortEndpointsBy: hc.cfg.SortEndpointsBy,
+	})
+	if err := instance.ParseTemplates(); err != nil {
+		return nil, err
+	}
+	dynamicConfig := *hc.dynamicConfig
+	cache := newValidationCache(hc.cache, logger, &dynamicConfig, nil)
+	options := *hc.converterOptions
+	options.Logger = logger
+	options.Cache = cache
+	options.Tracker = cache.tracker
+	options.DynamicConfig = &dynamicConfig
+	converters.NewConverter(utils.NewTimer(nil), instance.Config(), &options).Sync()
+	files, err := instance.DryRun()
+	if files == nil {
+		return nil, err
+	}
+	result := &ingress.DryRunResult{
+		Files:    files,
+		Messages: logger.messages,
+		Valid:    err == nil,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result, nil
+}
+
+// runDryRun waits the object cache to be synced, prints the outcome of
+// a dry run in the standard output and exits the controller.
+func (hc *HAProxyController) runDryRun() {
+	hc.cache.RunAsync(hc.stopCh)
+	result, err := hc.DryRun()
+	if err != nil {
+		hc.logger.Fatal("error running the dry run: %v", err)
+	}
+	fmt.Print(result.String())
+	if !result.Valid {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// runCheck waits the object cache to be synced, prints a summary of the
+// outcome of a dry run in the standard output and exits the controller.
+func (hc *HAProxyController) runCheck() {
+	hc.cache.RunAsync(hc.stopCh)
+	result, err := hc.DryRun()
+	if err != nil {
+		hc.logger.Fatal("error running the configuration check: %v", err)
+	}
+	fmt.Print(result.Summary())
+	if !result.Valid {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
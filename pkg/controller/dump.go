@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+// diagnostics is the internal state of the controller, sent by the dump
+// endpoint of the admin API
+type diagnostics struct {
+	Files   map[string]string              `json:"files,omitempty"`
+	Model   *configModel                   `json:"model,omitempty"`
+	Tracker map[string]map[string][]string `json:"tracker,omitempty"`
+}
+
+type configModel struct {
+	Global      *hatypes.Global       `json:"global"`
+	Frontend    *hatypes.Frontend     `json:"frontend"`
+	Hosts       []*hatypes.Host       `json:"hosts"`
+	Backends    []*hatypes.Backend    `json:"backends"`
+	TCPBackends []*hatypes.TCPBackend `json:"tcpBackends"`
+	TCPServices []*tcpServiceModel    `json:"tcpServices"`
+	Userlists   []*hatypes.Userlist   `json:"userlists"`
+}
+
+type tcpServiceModel struct {
+	Port        int                                `json:"port"`
+	Hosts       map[string]*hatypes.TCPServiceHost `json:"hosts"`
+	DefaultHost *hatypes.TCPServiceHost            `json:"defaultHost"`
+}
+
+// dump encodes the requested sections of the controller state. The sync
+// lock is held until the state is encoded, so the dump is consistent with
+// the last applied update. Passwords and secret keys of the model have the
+// `json:"-"` tag in the haproxy types, so they are never encoded.
+func (hc *HAProxyController) dump(sections map[string]bool) ([]byte, error) {
+	hc.syncMutex.Lock()
+	defer hc.syncMutex.Unlock()
+	diag := &diagnostics{}
+	if sections["files"] {
+		files, err := hc.instance.ConfigFiles()
+		if err != nil {
+			return nil, err
+		}
+		diag.Files = files
+	}
+	if sections["model"] {
+		config := hc.instance.Config()
+		model := &configModel{
+			Global:      config.Global(),
+			Frontend:    config.Frontend(),
+			Hosts:       config.Hosts().BuildSortedItems(),
+			Backends:    config.Backends().BuildSortedItems(),
+			TCPBackends: config.TCPBackends().BuildSortedItems(),
+			Userlists:   config.Userlists().BuildSortedItems(),
+		}
+		for _, tcpPort := range config.TCPServices().BuildSortedItems() {
+			model.TCPServices = append(model.TCPServices, &tcpServiceModel{
+				Port:        tcpPort.Port(),
+				Hosts:       tcpPort.Hosts(),
+				DefaultHost: tcpPort.DefaultHost(),
+			})
+		}
+		diag.Model = model
+	}
+	if sections["tracker"] {
+		diag.Tracker = hc.tracker.Dump()
+	}
+	return json.Marshal(diag)
+}
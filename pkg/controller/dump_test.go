@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestDumpModelSecrets(t *testing.T) {
+	instance := haproxy.CreateInstance(types_helper.NewLoggerMock(t), haproxy.InstanceOptions{})
+	config := instance.Config()
+	config.Global().Stats.Auth = "admin:stats-passwd"
+	config.Global().Cookie.Key = "cookie-key"
+	host := config.Hosts().AcquireHost("d1.local")
+	host.OIDC.ClientID = "client1"
+	host.OIDC.ClientSecret = "oidc-client-secret"
+	host.OIDC.CookieSecret = "oidc-cookie-secret"
+	backend := config.Backends().AcquireBackend("default", "app", "8080")
+	backend.Cookie.Name = "serverid"
+	config.Userlists().Replace("default_auth", []hatypes.User{{Name: "usr1", Passwd: "usr1-passwd"}}, nil)
+	hc := &HAProxyController{instance: instance}
+	out, err := hc.dump(map[string]bool{"model": true})
+	if err != nil {
+		t.Fatalf("error dumping model: %v", err)
+	}
+	dump := string(out)
+	for _, expected := range []string{"d1.local", "client1", "serverid", "usr1"} {
+		if !strings.Contains(dump, expected) {
+			t.Errorf("dump should have '%s': %s", expected, dump)
+		}
+	}
+	for _, secret := range []string{"stats-passwd", "oidc-client-secret", "oidc-cookie-secret", "cookie-key", "usr1-passwd"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("dump should not have '%s': %s", secret, dump)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+)
+
+// FleetAgentCommand is the name of the subcommand that runs the agent of
+// a member of an external fleet, see FleetAgent()
+const FleetAgentCommand = "fleet-agent"
+
+// FleetAgent runs the agent that receives the configuration files pushed
+// by a controller configured with --external-fleet, and reloads the haproxy
+// running in master-worker mode on the same host. FleetAgent returns the
+// exit code of the subcommand.
+func FleetAgent(args []string) int {
+	flags := pflag.NewFlagSet(FleetAgentCommand, pflag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: haproxy-ingress %s [options]\n\nOptions:\n%s", FleetAgentCommand, flags.FlagUsages())
+	}
+	var (
+		listen = flags.String("listen", ":10260",
+			`Address and port the agent listens to`)
+		tokenFile = flags.String("token-file", "",
+			`Path to a file with the bearer token the controller adds to the requests, see the controller's --external-fleet-token-file. Mandatory`)
+		certFile = flags.String("tls-cert-file", "",
+			`Path to the PEM encoded certificate of the agent. Mandatory`)
+		keyFile = flags.String("tls-key-file", "",
+			`Path to the PEM encoded private key of the agent. Mandatory`)
+		allowedDirs = flags.StringSlice("allowed-dirs", []string{
+			"/etc/haproxy",
+			ingress.DefaultCrtDirectory,
+			ingress.DefaultDHParamDirectory,
+			ingress.DefaultCACertsDirectory,
+			ingress.DefaultCrlDirectory,
+		}, `Comma-separated list of directories the pushed files can be written to, other files are refused`)
+		haproxyCfg = flags.String("haproxy-config", "/etc/haproxy",
+			`Configuration file or directory of haproxy, used to validate the configuration before reloading`)
+		masterSocket = flags.String("master-socket", "/var/run/haproxy/master.sock",
+			`Master socket of haproxy, used to reload haproxy and check its health`)
+		adminSocket = flags.String("admin-socket", "/var/run/haproxy/admin.sock",
+			`Admin socket of haproxy, used to send the commands of the dynamic updates`)
+		stagingDir = flags.String("staging-dir", "/var/lib/haproxy-fleet-agent/staging",
+			`Directory where the pushed configuration files are staged`)
+		backupDir = flags.String("backup-dir", "/var/lib/haproxy-fleet-agent/backup",
+			`Directory where the files replaced by the last reload are saved, used by rollbacks`)
+	)
+	if err := flags.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	// the agent writes files and reloads haproxy as requested
+	// by the controller, it should not be reachable without
+	// authentication or on an unencrypted connection
+	if *tokenFile == "" {
+		fmt.Fprintf(os.Stderr, "--token-file is mandatory\n")
+		return 2
+	}
+	if *certFile == "" || *keyFile == "" {
+		fmt.Fprintf(os.Stderr, "--tls-cert-file and --tls-key-file are mandatory\n")
+		return 2
+	}
+	// glog writes to the standard error if its flags weren't parsed
+	_ = flag.CommandLine.Parse(nil)
+	content, err := ioutil.ReadFile(*tokenFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading token: %v\n", err)
+		return 2
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		fmt.Fprintf(os.Stderr, "token file %s is empty\n", *tokenFile)
+		return 2
+	}
+	logger := newLogger(logFormatText).withComponent("fleet-agent")
+	agent := haproxy.NewFleetAgent(logger, &haproxy.FleetAgentOptions{
+		Token:        token,
+		Dirs:         *allowedDirs,
+		HAProxyCfg:   *haproxyCfg,
+		MasterSocket: *masterSocket,
+		AdminSocket:  *adminSocket,
+		StagingDir:   *stagingDir,
+		BackupDir:    *backupDir,
+	})
+	server := &http.Server{Addr: *listen, Handler: agent}
+	logger.Info("fleet agent listening on %s", *listen)
+	err = server.ListenAndServeTLS(*certFile, *keyFile)
+	logger.Error("error serving the fleet agent: %v", err)
+	return 1
+}
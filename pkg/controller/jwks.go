@@ -0,0 +1,261 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/net/ssl"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// maxJWKSSize is the largest JSON Web Key Set accepted
+const maxJWKSSize = 1024 * 1024
+
+// jwksRetryInterval is the minimum time between two attempts to fetch
+// a key set that could not be read yet
+const jwksRetryInterval = 10 * time.Second
+
+// jwksCache fetches the JSON Web Key Sets used to verify the signature of
+// JSON Web Tokens, and converts their keys to PEM files, which is the format
+// used by the haproxy's jwt_verify converter. A key set is fetched when it
+// is used for the first time, and fetched again on every refresh.
+type jwksCache struct {
+	logger types.Logger
+	client *http.Client
+	write  func(name string, key []byte) (string, error)
+	now    func() time.Time
+	mutex  sync.Mutex
+	sets   map[string]*jwksSet
+}
+
+type jwksSet struct {
+	hash    string
+	keys    []convtypes.JWKSKey
+	err     error
+	fetched time.Time
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func newJWKSCache(logger types.Logger) *jwksCache {
+	return &jwksCache{
+		logger: logger,
+		client: &http.Client{Timeout: 10 * time.Second},
+		write:  ssl.AddOrUpdatePublicKey,
+		now:    time.Now,
+		sets:   map[string]*jwksSet{},
+	}
+}
+
+// getKeys returns the keys of the key set published in url. A key set that
+// cannot be fetched is tried again on the next call, after jwksRetryInterval,
+// or on the next refresh.
+func (j *jwksCache) getKeys(url string) ([]convtypes.JWKSKey, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	set, found := j.sets[url]
+	if !found || (set.err != nil && j.now().Sub(set.fetched) >= jwksRetryInterval) {
+		set = j.fetch(url, "")
+		set.fetched = j.now()
+		j.sets[url] = set
+	}
+	return set.keys, set.err
+}
+
+// refresh fetches all the known key sets again, and returns true if any of
+// them changed. The last known keys of a key set are kept if it cannot be
+// fetched.
+func (j *jwksCache) refresh() bool {
+	j.mutex.Lock()
+	sets := make(map[string]*jwksSet, len(j.sets))
+	for url, set := range j.sets {
+		sets[url] = set
+	}
+	j.mutex.Unlock()
+	changed := false
+	for url, set := range sets {
+		newSet := j.fetch(url, set.hash)
+		if newSet == nil {
+			// not changed
+			continue
+		}
+		if newSet.err != nil {
+			if set.err == nil {
+				j.logger.Warn("error refreshing JWKS %s, using the last known keys: %v", url, newSet.err)
+			}
+			continue
+		}
+		j.mutex.Lock()
+		j.sets[url] = newSet
+		j.mutex.Unlock()
+		changed = true
+	}
+	return changed
+}
+
+// fetch reads and converts a key set, returns nil if its content
+// has the same hash of the last successful fetch
+func (j *jwksCache) fetch(url, hash string) *jwksSet {
+	body, err := j.download(url)
+	if err != nil {
+		return &jwksSet{err: err}
+	}
+	newHash := fmt.Sprintf("%x", sha1.Sum(body))
+	if newHash == hash {
+		return nil
+	}
+	keys, err := j.convert(url, body)
+	if err != nil {
+		return &jwksSet{err: err}
+	}
+	return &jwksSet{hash: newHash, keys: keys}
+}
+
+func (j *jwksCache) download(url string) ([]byte, error) {
+	resp, err := j.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+// convert writes the signing keys of a key set as PEM files, unsupported
+// keys are ignored
+func (j *jwksCache) convert(url string, body []byte) ([]convtypes.JWKSKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, fmt.Errorf("error parsing JWKS: %w", err)
+	}
+	var keys []convtypes.JWKSKey
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		pub, err := key.publicKey()
+		if err != nil {
+			j.logger.Warn("ignoring key '%s' of JWKS %s: %v", key.Kid, url, err)
+			continue
+		}
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			j.logger.Warn("ignoring key '%s' of JWKS %s: %v", key.Kid, url, err)
+			continue
+		}
+		content := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+		name := fmt.Sprintf("jwks_%x", sha1.Sum([]byte(url+"#"+key.Kid)))
+		filename, err := j.write(name, content)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, convtypes.JWKSKey{
+			KID: key.Kid,
+			File: convtypes.File{
+				Filename: filename,
+				SHA1Hash: fmt.Sprintf("%x", sha1.Sum(content)),
+			},
+		})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS does not have any supported signing key")
+	}
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := decodeJWKInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("invalid EC point")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+}
+
+func decodeJWKInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("missing key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
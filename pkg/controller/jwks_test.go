@@ -0,0 +1,297 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestJWKSGetKeys(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaJWK := fmt.Sprintf(`{"kty":"RSA","kid":"rsa1","use":"sig","n":"%s","e":"%s"}`,
+		jwkInt(rsaKey.N), jwkInt(big.NewInt(int64(rsaKey.E))))
+	ecJWK := fmt.Sprintf(`{"kty":"EC","kid":"ec1","crv":"P-256","x":"%s","y":"%s"}`,
+		jwkInt(ecKey.X), jwkInt(ecKey.Y))
+	testCases := []struct {
+		status   int
+		body     string
+		expKIDs  []string
+		expKeys  map[string]interface{}
+		expError string
+		logging  string
+	}{
+		// 0
+		{
+			body:    `{"keys":[` + rsaJWK + `,` + ecJWK + `]}`,
+			expKIDs: []string{"rsa1", "ec1"},
+			expKeys: map[string]interface{}{"rsa1": &rsaKey.PublicKey, "ec1": &ecKey.PublicKey},
+		},
+		// 1
+		{
+			body:    `{"keys":[{"kty":"RSA","kid":"enc1","use":"enc","n":"AQAB","e":"AQAB"},` + rsaJWK + `]}`,
+			expKIDs: []string{"rsa1"},
+			expKeys: map[string]interface{}{"rsa1": &rsaKey.PublicKey},
+		},
+		// 2
+		{
+			body:    `{"keys":[{"kty":"oct","kid":"hmac1","k":"c2VjcmV0"},{"kty":"EC","kid":"ec2","crv":"P-192","x":"AQ","y":"AQ"},` + ecJWK + `]}`,
+			expKIDs: []string{"ec1"},
+			expKeys: map[string]interface{}{"ec1": &ecKey.PublicKey},
+			logging: `
+WARN ignoring key 'hmac1' of JWKS {url}: unsupported key type: oct
+WARN ignoring key 'ec2' of JWKS {url}: unsupported curve: P-192`,
+		},
+		// 3
+		{
+			body:     `{"keys":[{"kty":"EC","kid":"ec3","crv":"P-256","x":"AQ","y":"AQ"}]}`,
+			expError: "JWKS does not have any supported signing key",
+			logging:  `WARN ignoring key 'ec3' of JWKS {url}: invalid EC point`,
+		},
+		// 4
+		{
+			body:     `{"keys":`,
+			expError: "error parsing JWKS: unexpected end of JSON input",
+		},
+		// 5
+		{
+			status:   http.StatusNotFound,
+			expError: "unexpected status code: 404",
+		},
+	}
+	for i, test := range testCases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.status != 0 {
+				w.WriteHeader(test.status)
+			}
+			_, _ = w.Write([]byte(test.body))
+		}))
+		logger := types_helper.NewLoggerMock(t)
+		j, files := newJWKSCacheMock(logger)
+		keys, err := j.getKeys(server.URL)
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != test.expError {
+			t.Errorf("error differs on %d - expected: %s - actual: %s", i, test.expError, errMsg)
+		}
+		var kids []string
+		for _, key := range keys {
+			kids = append(kids, key.KID)
+			pub, err := parsePublicKey(files[key.File.Filename])
+			if err != nil {
+				t.Errorf("error parsing key '%s' on %d: %v", key.KID, i, err)
+			} else if !reflect.DeepEqual(pub, test.expKeys[key.KID]) {
+				t.Errorf("key '%s' differs on %d", key.KID, i)
+			}
+		}
+		if !reflect.DeepEqual(kids, test.expKIDs) {
+			t.Errorf("kids differ on %d - expected: %v - actual: %v", i, test.expKIDs, kids)
+		}
+		logger.CompareLogging(strings.ReplaceAll(test.logging, "{url}", server.URL))
+		server.Close()
+	}
+}
+
+func TestJWKSRefresh(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwks := func(kid string) string {
+		return fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"%s","n":"%s","e":"%s"}]}`,
+			kid, jwkInt(rsaKey.N), jwkInt(big.NewInt(int64(rsaKey.E))))
+	}
+	var mutex sync.Mutex
+	status := http.StatusOK
+	body := jwks("key1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	update := func(newStatus int, newBody string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		status, body = newStatus, newBody
+	}
+	logger := types_helper.NewLoggerMock(t)
+	j, _ := newJWKSCacheMock(logger)
+	testCases := []struct {
+		status     int
+		body       string
+		expChanged bool
+		expKID     string
+		logging    string
+	}{
+		// 0
+		{
+			status: http.StatusOK,
+			body:   jwks("key1"),
+			expKID: "key1",
+		},
+		// 1
+		{
+			status:     http.StatusOK,
+			body:       jwks("key2"),
+			expChanged: true,
+			expKID:     "key2",
+		},
+		// 2
+		{
+			status:  http.StatusInternalServerError,
+			expKID:  "key2",
+			logging: `WARN error refreshing JWKS {url}, using the last known keys: unexpected status code: 500`,
+		},
+		// 3
+		{
+			status:     http.StatusOK,
+			body:       jwks("key3"),
+			expChanged: true,
+			expKID:     "key3",
+		},
+	}
+	if _, err := j.getKeys(server.URL); err != nil {
+		t.Errorf("error reading keys: %v", err)
+	}
+	for i, test := range testCases {
+		update(test.status, test.body)
+		changed := j.refresh()
+		if changed != test.expChanged {
+			t.Errorf("changed differs on %d - expected: %v - actual: %v", i, test.expChanged, changed)
+		}
+		keys, err := j.getKeys(server.URL)
+		if err != nil {
+			t.Errorf("error reading keys on %d: %v", i, err)
+		} else if len(keys) != 1 || keys[0].KID != test.expKID {
+			t.Errorf("keys differ on %d - expected: %s - actual: %v", i, test.expKID, keys)
+		}
+		logger.CompareLogging(strings.ReplaceAll(test.logging, "{url}", server.URL))
+	}
+}
+
+func TestJWKSRetry(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var mutex sync.Mutex
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			_, _ = fmt.Fprintf(w, `{"keys":[{"kty":"RSA","kid":"key1","n":"%s","e":"%s"}]}`,
+				jwkInt(rsaKey.N), jwkInt(big.NewInt(int64(rsaKey.E))))
+		}
+	}))
+	defer server.Close()
+	logger := types_helper.NewLoggerMock(t)
+	j, _ := newJWKSCacheMock(logger)
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	j.now = func() time.Time { return now }
+	testCases := []struct {
+		status   int
+		elapsed  time.Duration
+		expKID   string
+		expError string
+	}{
+		// 0
+		{
+			status:   http.StatusServiceUnavailable,
+			expError: "unexpected status code: 503",
+		},
+		// 1
+		{
+			status:   http.StatusOK,
+			elapsed:  5 * time.Second,
+			expError: "unexpected status code: 503",
+		},
+		// 2
+		{
+			status:  http.StatusOK,
+			elapsed: 5 * time.Second,
+			expKID:  "key1",
+		},
+		// 3
+		{
+			status:  http.StatusServiceUnavailable,
+			elapsed: time.Minute,
+			expKID:  "key1",
+		},
+	}
+	for i, test := range testCases {
+		mutex.Lock()
+		status = test.status
+		mutex.Unlock()
+		now = now.Add(test.elapsed)
+		keys, err := j.getKeys(server.URL)
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != test.expError {
+			t.Errorf("error differs on %d - expected: %s - actual: %s", i, test.expError, errMsg)
+		}
+		var kid string
+		if len(keys) > 0 {
+			kid = keys[0].KID
+		}
+		if kid != test.expKID {
+			t.Errorf("kid differs on %d - expected: %s - actual: %s", i, test.expKID, kid)
+		}
+		logger.CompareLogging("")
+	}
+}
+
+func newJWKSCacheMock(logger *types_helper.LoggerMock) (*jwksCache, map[string][]byte) {
+	files := map[string][]byte{}
+	j := newJWKSCache(logger)
+	j.write = func(name string, key []byte) (string, error) {
+		filename := "/var/haproxy/ssl/" + name + ".pem"
+		files[filename] = key
+		return filename, nil
+	}
+	return j, files
+}
+
+func jwkInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func parsePublicKey(content []byte) (interface{}, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
@@ -18,18 +18,13 @@ package controller
 
 import (
 	"context"
-	"os"
 	"time"
 
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress/controller"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 
-	api "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/leaderelection"
-	"k8s.io/client-go/tools/leaderelection/resourcelock"
-	"k8s.io/client-go/tools/record"
 )
 
 // LeaderSubscriber ...
@@ -45,24 +40,12 @@ type leaderelector struct {
 }
 
 // NewLeaderElector ...
-func NewLeaderElector(id string, logger *logger, cache *k8scache, subscriber LeaderSubscriber) types.LeaderElector {
-	hostname, _ := os.Hostname()
+func NewLeaderElector(cfg *controller.Configuration, id string, logger *logger, cache *k8scache, subscriber LeaderSubscriber) types.LeaderElector {
 	namespace, podname, err := cache.GetIngressPodName()
 	if err != nil {
 		logger.Fatal("error reading ingress controller pod: %v", err)
 	}
 
-	lock := &resourcelock.ConfigMapLock{
-		Client:        cache.client.CoreV1(),
-		ConfigMapMeta: metav1.ObjectMeta{Namespace: namespace, Name: id},
-		LockConfig: resourcelock.ResourceLockConfig{
-			Identity: podname,
-			EventRecorder: record.NewBroadcaster().NewRecorder(scheme.Scheme, api.EventSource{
-				Component: "haproxy-ingress-leader-elector",
-				Host:      hostname,
-			}),
-		},
-	}
 	callbacks := leaderelection.LeaderCallbacks{
 		OnStartedLeading: func(ctx context.Context) {
 			if subscriber != nil {
@@ -81,13 +64,7 @@ func NewLeaderElector(id string, logger *logger, cache *k8scache, subscriber Lea
 		},
 	}
 
-	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
-		Lock:          lock,
-		LeaseDuration: 30 * time.Second,
-		RenewDeadline: 15 * time.Second,
-		RetryPeriod:   10 * time.Second,
-		Callbacks:     callbacks,
-	})
+	le, err := controller.NewLeaderElector(cfg, id, namespace, podname, "haproxy-ingress-leader-elector", callbacks)
 	if err != nil {
 		logger.Fatal("error starting leader election: %v", err)
 	}
@@ -22,13 +22,17 @@ import (
 	"time"
 
 	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/informers"
 	informerscore "k8s.io/client-go/informers/core/v1"
 	informersnetworking "k8s.io/client-go/informers/networking/v1"
 	"k8s.io/client-go/kubernetes/fake"
 	listerscore "k8s.io/client-go/listers/core/v1"
+	listersdiscovery "k8s.io/client-go/listers/discovery/v1"
 	listersnetworking "k8s.io/client-go/listers/networking/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
@@ -37,6 +41,9 @@ import (
 	informersgatewayv1alpha1 "sigs.k8s.io/gateway-api/pkg/client/informers/externalversions/apis/v1alpha1"
 	listersgateway "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1alpha1"
 
+	haclient "github.com/jcmoraisjr/haproxy-ingress/pkg/api/client"
+	mcsv1alpha1 "github.com/jcmoraisjr/haproxy-ingress/pkg/api/mcs/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 )
 
@@ -68,12 +75,25 @@ type listers struct {
 	tcpRouteLister      listersgateway.TCPRouteLister
 	udpRouteLister      listersgateway.UDPRouteLister
 	backendPolicyLister listersgateway.BackendPolicyLister
+	tcpServiceLister    haclient.TCPServiceLister
+	udpServiceLister    haclient.UDPServiceLister
+	hostLister          haclient.HostLister
+	backendLister       haclient.BackendLister
+	middlewareLister    haclient.MiddlewareLister
+	authConfigLister    haclient.AuthConfigLister
+	ingClassParamLister haclient.IngressClassParametersLister
+	globalLister        haclient.GlobalLister
+	ipListLister        haclient.IPListLister
+	rateLimitLister     haclient.RateLimitLister
+	serviceImportLister haclient.ServiceImportLister
 	endpointLister      listerscore.EndpointsLister
+	endpointSliceLister listersdiscovery.EndpointSliceLister
 	serviceLister       listerscore.ServiceLister
 	secretLister        listerscore.SecretLister
 	configMapLister     listerscore.ConfigMapLister
 	podLister           listerscore.PodLister
 	nodeLister          listerscore.NodeLister
+	namespaceLister     listerscore.NamespaceLister
 	//
 	ingressInformer       cache.SharedInformer
 	ingressClassInformer  cache.SharedInformer
@@ -84,12 +104,32 @@ type listers struct {
 	tcpRouteInformer      cache.SharedInformer
 	udpRouteInformer      cache.SharedInformer
 	backendPolicyInformer cache.SharedInformer
-	endpointInformer      cache.SharedInformer
+	tcpServiceInformer    cache.SharedInformer
+	udpServiceInformer    cache.SharedInformer
+	hostInformer          cache.SharedInformer
+	backendInformer       cache.SharedInformer
+	middlewareInformer    cache.SharedInformer
+	authConfigInformer    cache.SharedInformer
+	ingClassParamInformer cache.SharedInformer
+	globalInformer        cache.SharedInformer
+	ipListInformer        cache.SharedInformer
+	rateLimitInformer     cache.SharedInformer
+	serviceImportInformer cache.SharedInformer
+	endpointInformer      cache.SharedInformer // either Endpoints or EndpointSlices informer
 	serviceInformer       cache.SharedInformer
 	secretInformer        cache.SharedInformer
 	configMapInformer     cache.SharedInformer
 	podInformer           cache.SharedInformer
 	nodeInformer          cache.SharedInformer
+	namespaceInformer     cache.SharedInformer
+}
+
+// watchSelectors are the label selectors of the resources watched by the
+// controller, a nil selector doesn't filter the resources.
+type watchSelectors struct {
+	ingress labels.Selector
+	service labels.Selector
+	secret  labels.Selector
 }
 
 func createListers(
@@ -98,25 +138,68 @@ func createListers(
 	recorder record.EventRecorder,
 	client types.Client,
 	watchGateway bool,
-	watchNamespace string,
+	watchCRDs bool,
+	watchServiceImports bool,
+	watchNamespaces []string,
+	ignoreNamespaces []string,
 	isolateNamespace bool,
+	selectors watchSelectors,
+	namespaceWatch bool,
 	podWatch bool,
+	endpointSlices bool,
 	resync time.Duration,
 ) *listers {
-	clusterWatch := watchNamespace == api.NamespaceAll
-	clusterOption := informers.WithTweakListOptions(nil)
-	namespaceOption := informers.WithNamespace(watchNamespace)
-	var ingressInformer, resourceInformer, localInformer informers.SharedInformerFactory
+	clusterWatch := len(watchNamespaces) == 0
+	ingressNamespaces := watchNamespaces
 	if clusterWatch {
-		ingressInformer = informers.NewSharedInformerFactoryWithOptions(client, resync, clusterOption)
-		resourceInformer = ingressInformer
-	} else if isolateNamespace {
-		ingressInformer = informers.NewSharedInformerFactoryWithOptions(client, resync, namespaceOption)
-		resourceInformer = ingressInformer
-	} else {
-		ingressInformer = informers.NewSharedInformerFactoryWithOptions(client, resync, namespaceOption)
-		resourceInformer = informers.NewSharedInformerFactoryWithOptions(client, resync, clusterOption)
+		ingressNamespaces = []string{api.NamespaceAll}
+	}
+	resourceNamespaces := []string{api.NamespaceAll}
+	if isolateNamespace {
+		resourceNamespaces = ingressNamespaces
+	}
+
+	// resources of ignored namespaces and resources filtered by label are
+	// filtered out by the API server. The list options of a factory are
+	// shared by all of its informers, so every namespace and label selector
+	// need their own informer factory
+	ignoreSelector := ignoredNamespacesSelector(ignoreNamespaces)
+	listOptions := func(namespace string, selector labels.Selector) func(*metav1.ListOptions) {
+		return func(opts *metav1.ListOptions) {
+			if namespace == api.NamespaceAll {
+				opts.FieldSelector = ignoreSelector
+			}
+			if selector != nil {
+				opts.LabelSelector = selector.String()
+			}
+		}
+	}
+	factories := map[string]informers.SharedInformerFactory{}
+	factory := func(namespace string, selector labels.Selector) informers.SharedInformerFactory {
+		key := namespace
+		if selector != nil {
+			key += "/" + selector.String()
+		}
+		f, found := factories[key]
+		if !found {
+			f = informers.NewSharedInformerFactoryWithOptions(client, resync,
+				informers.WithNamespace(namespace),
+				informers.WithTweakListOptions(listOptions(namespace, selector)),
+			)
+			factories[key] = f
+		}
+		return f
 	}
+	namespacedInformer := func(namespaces []string, selector labels.Selector, informerFor func(informers.SharedInformerFactory) cache.SharedIndexInformer) cache.SharedIndexInformer {
+		nsInformers := make([]cache.SharedIndexInformer, len(namespaces))
+		for i, ns := range namespaces {
+			nsInformers[i] = informerFor(factory(ns, selector))
+		}
+		return newMultiNamespaceInformer(namespaces, nsInformers)
+	}
+	// cluster scoped resources, despite of --watch-namespace
+	clusterInformer := informers.NewSharedInformerFactory(client, resync)
+	var localInformer informers.SharedInformerFactory
 	if !podWatch || !clusterWatch {
 		localInformer = informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
 	}
@@ -125,41 +208,128 @@ func createListers(
 		recorder: recorder,
 		logger:   logger,
 	}
-	l.createIngressLister(ingressInformer.Networking().V1().Ingresses())
-	l.createIngressClassLister(ingressInformer.Networking().V1().IngressClasses())
-	l.createEndpointLister(resourceInformer.Core().V1().Endpoints())
-	l.createServiceLister(resourceInformer.Core().V1().Services())
-	l.createSecretLister(resourceInformer.Core().V1().Secrets())
-	l.createConfigMapLister(resourceInformer.Core().V1().ConfigMaps())
+	l.createIngressLister(namespacedInformer(ingressNamespaces, selectors.ingress, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Networking().V1().Ingresses().Informer()
+	}))
+	l.createIngressClassLister(clusterInformer.Networking().V1().IngressClasses())
+	if endpointSlices {
+		l.createEndpointSliceLister(namespacedInformer(resourceNamespaces, nil, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Discovery().V1().EndpointSlices().Informer()
+		}))
+	} else {
+		l.createEndpointLister(namespacedInformer(resourceNamespaces, nil, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Core().V1().Endpoints().Informer()
+		}))
+	}
+	l.createServiceLister(namespacedInformer(resourceNamespaces, selectors.service, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Services().Informer()
+	}))
+	l.createSecretLister(namespacedInformer(resourceNamespaces, selectors.secret, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Secrets().Informer()
+	}))
+	l.createConfigMapLister(namespacedInformer(resourceNamespaces, nil, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().ConfigMaps().Informer()
+	}))
 	if podWatch {
-		l.createPodLister(ingressInformer.Core().V1().Pods())
+		l.createPodLister(namespacedInformer(ingressNamespaces, nil, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Core().V1().Pods().Informer()
+		}))
 		l.hasPodLister = true
 	} else {
-		l.createPodLister(localInformer.Core().V1().Pods())
+		l.createPodLister(localInformer.Core().V1().Pods().Informer())
 	}
 	if clusterWatch {
 		// ignoring --disable-node-list
-		l.createNodeLister(resourceInformer.Core().V1().Nodes())
+		l.createNodeLister(clusterInformer.Core().V1().Nodes())
 		l.hasNodeLister = true
 	} else {
 		l.createNodeLister(localInformer.Core().V1().Nodes())
 	}
+	if namespaceWatch {
+		l.createNamespaceLister(clusterInformer.Core().V1().Namespaces())
+	}
 
 	if watchGateway {
-		var option informersgateway.SharedInformerOption
-		if clusterWatch {
-			option = informersgateway.WithTweakListOptions(nil)
-		} else {
-			option = informersgateway.WithNamespace(watchNamespace)
+		gatewayFactories := make([]informersgateway.SharedInformerFactory, len(ingressNamespaces))
+		for i, ns := range ingressNamespaces {
+			gatewayFactories[i] = informersgateway.NewSharedInformerFactoryWithOptions(client, resync,
+				informersgateway.WithNamespace(ns),
+				informersgateway.WithTweakListOptions(listOptions(ns, nil)),
+			)
 		}
-		informer := informersgateway.NewSharedInformerFactoryWithOptions(client, resync, option)
-		l.createGatewayLister(informer.Networking().V1alpha1().Gateways())
-		l.createGatewayClassLister(informer.Networking().V1alpha1().GatewayClasses())
-		l.createHTTPRouteLister(informer.Networking().V1alpha1().HTTPRoutes())
-		l.createTLSRouteLister(informer.Networking().V1alpha1().TLSRoutes())
-		l.createTCPRouteLister(informer.Networking().V1alpha1().TCPRoutes())
-		l.createUDPRouteLister(informer.Networking().V1alpha1().UDPRoutes())
-		l.createBackendPolicyLister(informer.Networking().V1alpha1().BackendPolicies())
+		gatewayInformer := func(informerFor func(informersgateway.SharedInformerFactory) cache.SharedIndexInformer) cache.SharedIndexInformer {
+			nsInformers := make([]cache.SharedIndexInformer, len(gatewayFactories))
+			for i, f := range gatewayFactories {
+				nsInformers[i] = informerFor(f)
+			}
+			return newMultiNamespaceInformer(ingressNamespaces, nsInformers)
+		}
+		l.createGatewayLister(gatewayInformer(func(f informersgateway.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Networking().V1alpha1().Gateways().Informer()
+		}))
+		// GatewayClass is cluster scoped, despite of --watch-namespace
+		l.createGatewayClassLister(informersgateway.NewSharedInformerFactory(client, resync).Networking().V1alpha1().GatewayClasses())
+		l.createHTTPRouteLister(gatewayInformer(func(f informersgateway.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Networking().V1alpha1().HTTPRoutes().Informer()
+		}))
+		l.createTLSRouteLister(gatewayInformer(func(f informersgateway.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Networking().V1alpha1().TLSRoutes().Informer()
+		}))
+		l.createTCPRouteLister(gatewayInformer(func(f informersgateway.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Networking().V1alpha1().TCPRoutes().Informer()
+		}))
+		l.createUDPRouteLister(gatewayInformer(func(f informersgateway.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Networking().V1alpha1().UDPRoutes().Informer()
+		}))
+		l.createBackendPolicyLister(gatewayInformer(func(f informersgateway.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Networking().V1alpha1().BackendPolicies().Informer()
+		}))
+	}
+
+	if watchCRDs {
+		nsInformers := make([]cache.SharedIndexInformer, len(ingressNamespaces))
+		for i, ns := range ingressNamespaces {
+			nsInformers[i] = haclient.NewFilteredTCPServiceInformer(client, ns, resync, listOptions(ns, nil))
+		}
+		l.createTCPServiceLister(newMultiNamespaceInformer(ingressNamespaces, nsInformers))
+		udpInformers := make([]cache.SharedIndexInformer, len(ingressNamespaces))
+		for i, ns := range ingressNamespaces {
+			udpInformers[i] = haclient.NewFilteredUDPServiceInformer(client, ns, resync, listOptions(ns, nil))
+		}
+		l.createUDPServiceLister(newMultiNamespaceInformer(ingressNamespaces, udpInformers))
+		hostInformers := make([]cache.SharedIndexInformer, len(ingressNamespaces))
+		for i, ns := range ingressNamespaces {
+			hostInformers[i] = haclient.NewFilteredHostInformer(client, ns, resync, listOptions(ns, nil))
+		}
+		l.createHostLister(newMultiNamespaceInformer(ingressNamespaces, hostInformers))
+		backendInformers := make([]cache.SharedIndexInformer, len(ingressNamespaces))
+		for i, ns := range ingressNamespaces {
+			backendInformers[i] = haclient.NewFilteredBackendInformer(client, ns, resync, listOptions(ns, nil))
+		}
+		l.createBackendLister(newMultiNamespaceInformer(ingressNamespaces, backendInformers))
+		middlewareInformers := make([]cache.SharedIndexInformer, len(ingressNamespaces))
+		for i, ns := range ingressNamespaces {
+			middlewareInformers[i] = haclient.NewFilteredMiddlewareInformer(client, ns, resync, listOptions(ns, nil))
+		}
+		l.createMiddlewareLister(newMultiNamespaceInformer(ingressNamespaces, middlewareInformers))
+		authConfigInformers := make([]cache.SharedIndexInformer, len(ingressNamespaces))
+		for i, ns := range ingressNamespaces {
+			authConfigInformers[i] = haclient.NewFilteredAuthConfigInformer(client, ns, resync, listOptions(ns, nil))
+		}
+		l.createAuthConfigLister(newMultiNamespaceInformer(ingressNamespaces, authConfigInformers))
+		// IngressClassParameters, Global, IPList and RateLimit are cluster scoped, despite of --watch-namespace
+		l.createIngressClassParametersLister(haclient.NewIngressClassParametersInformer(client, resync))
+		l.createGlobalLister(haclient.NewGlobalInformer(client, resync))
+		l.createIPListLister(haclient.NewIPListInformer(client, resync))
+		l.createRateLimitLister(haclient.NewRateLimitInformer(client, resync))
+	}
+
+	if watchServiceImports {
+		nsInformers := make([]cache.SharedIndexInformer, len(ingressNamespaces))
+		for i, ns := range ingressNamespaces {
+			nsInformers[i] = haclient.NewFilteredServiceImportInformer(client, ns, resync, listOptions(ns, nil))
+		}
+		l.createServiceImportLister(newMultiNamespaceInformer(ingressNamespaces, nsInformers))
 	}
 
 	return l
@@ -199,6 +369,44 @@ func (l *listers) RunAsync(stopCh <-chan struct{}) {
 		}
 	}
 
+	if l.tcpServiceInformer != nil {
+		go l.tcpServiceInformer.Run(stopCh)
+		go l.udpServiceInformer.Run(stopCh)
+		go l.hostInformer.Run(stopCh)
+		go l.backendInformer.Run(stopCh)
+		go l.middlewareInformer.Run(stopCh)
+		go l.authConfigInformer.Run(stopCh)
+		go l.ingClassParamInformer.Run(stopCh)
+		go l.globalInformer.Run(stopCh)
+		go l.ipListInformer.Run(stopCh)
+		go l.rateLimitInformer.Run(stopCh)
+		if !cache.WaitForCacheSync(stopCh,
+			l.tcpServiceInformer.HasSynced,
+			l.udpServiceInformer.HasSynced,
+			l.hostInformer.HasSynced,
+			l.backendInformer.HasSynced,
+			l.middlewareInformer.HasSynced,
+			l.authConfigInformer.HasSynced,
+			l.ingClassParamInformer.HasSynced,
+			l.globalInformer.HasSynced,
+			l.ipListInformer.HasSynced,
+			l.rateLimitInformer.HasSynced,
+		) {
+			syncFailed()
+			return
+		}
+	}
+
+	if l.serviceImportInformer != nil {
+		go l.serviceImportInformer.Run(stopCh)
+		if !cache.WaitForCacheSync(stopCh,
+			l.serviceImportInformer.HasSynced,
+		) {
+			syncFailed()
+			return
+		}
+	}
+
 	// wait IngressClass lister initialize, ingress informers initialization depends on it
 	go l.ingressClassInformer.Run(stopCh)
 	ingClassSynced := cache.WaitForCacheSync(stopCh,
@@ -209,6 +417,17 @@ func (l *listers) RunAsync(stopCh <-chan struct{}) {
 		return
 	}
 
+	// Namespace labels are used by sharding, ingress informers initialization depends on it as well
+	if l.namespaceInformer != nil {
+		go l.namespaceInformer.Run(stopCh)
+		if !cache.WaitForCacheSync(stopCh,
+			l.namespaceInformer.HasSynced,
+		) {
+			syncFailed()
+			return
+		}
+	}
+
 	// initialize listers and informers
 	go l.ingressInformer.Run(stopCh)
 	go l.endpointInformer.Run(stopCh)
@@ -234,9 +453,9 @@ func (l *listers) RunAsync(stopCh <-chan struct{}) {
 	}
 }
 
-func (l *listers) createIngressLister(informer informersnetworking.IngressInformer) {
-	l.ingressLister = informer.Lister()
-	l.ingressInformer = informer.Informer()
+func (l *listers) createIngressLister(informer cache.SharedIndexInformer) {
+	l.ingressLister = listersnetworking.NewIngressLister(informer.GetIndexer())
+	l.ingressInformer = informer
 	l.ingressInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			ing := obj.(*networking.Ingress)
@@ -328,9 +547,9 @@ func (l *listers) createIngressClassLister(informer informersnetworking.IngressC
 	})
 }
 
-func (l *listers) createGatewayLister(informer informersgatewayv1alpha1.GatewayInformer) {
-	l.gatewayLister = informer.Lister()
-	l.gatewayInformer = informer.Informer()
+func (l *listers) createGatewayLister(informer cache.SharedIndexInformer) {
+	l.gatewayLister = listersgateway.NewGatewayLister(informer.GetIndexer())
+	l.gatewayInformer = informer
 	l.gatewayInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			gw := obj.(*gateway.Gateway)
@@ -405,9 +624,9 @@ func (l *listers) createGatewayClassLister(informer informersgatewayv1alpha1.Gat
 	})
 }
 
-func (l *listers) createHTTPRouteLister(informer informersgatewayv1alpha1.HTTPRouteInformer) {
-	l.httpRouteLister = informer.Lister()
-	l.httpRouteInformer = informer.Informer()
+func (l *listers) createHTTPRouteLister(informer cache.SharedIndexInformer) {
+	l.httpRouteLister = listersgateway.NewHTTPRouteLister(informer.GetIndexer())
+	l.httpRouteInformer = informer
 	l.httpRouteInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			l.events.Notify(nil, obj)
@@ -423,9 +642,9 @@ func (l *listers) createHTTPRouteLister(informer informersgatewayv1alpha1.HTTPRo
 	})
 }
 
-func (l *listers) createTLSRouteLister(informer informersgatewayv1alpha1.TLSRouteInformer) {
-	l.tlsRouteLister = informer.Lister()
-	l.tlsRouteInformer = informer.Informer()
+func (l *listers) createTLSRouteLister(informer cache.SharedIndexInformer) {
+	l.tlsRouteLister = listersgateway.NewTLSRouteLister(informer.GetIndexer())
+	l.tlsRouteInformer = informer
 	l.tlsRouteInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			l.events.Notify(nil, obj)
@@ -441,9 +660,9 @@ func (l *listers) createTLSRouteLister(informer informersgatewayv1alpha1.TLSRout
 	})
 }
 
-func (l *listers) createTCPRouteLister(informer informersgatewayv1alpha1.TCPRouteInformer) {
-	l.tcpRouteLister = informer.Lister()
-	l.tcpRouteInformer = informer.Informer()
+func (l *listers) createTCPRouteLister(informer cache.SharedIndexInformer) {
+	l.tcpRouteLister = listersgateway.NewTCPRouteLister(informer.GetIndexer())
+	l.tcpRouteInformer = informer
 	l.tcpRouteInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			l.events.Notify(nil, obj)
@@ -459,9 +678,9 @@ func (l *listers) createTCPRouteLister(informer informersgatewayv1alpha1.TCPRout
 	})
 }
 
-func (l *listers) createUDPRouteLister(informer informersgatewayv1alpha1.UDPRouteInformer) {
-	l.udpRouteLister = informer.Lister()
-	l.udpRouteInformer = informer.Informer()
+func (l *listers) createUDPRouteLister(informer cache.SharedIndexInformer) {
+	l.udpRouteLister = listersgateway.NewUDPRouteLister(informer.GetIndexer())
+	l.udpRouteInformer = informer
 	l.udpRouteInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			l.events.Notify(nil, obj)
@@ -477,9 +696,9 @@ func (l *listers) createUDPRouteLister(informer informersgatewayv1alpha1.UDPRout
 	})
 }
 
-func (l *listers) createBackendPolicyLister(informer informersgatewayv1alpha1.BackendPolicyInformer) {
-	l.backendPolicyLister = informer.Lister()
-	l.backendPolicyInformer = informer.Informer()
+func (l *listers) createBackendPolicyLister(informer cache.SharedIndexInformer) {
+	l.backendPolicyLister = listersgateway.NewBackendPolicyLister(informer.GetIndexer())
+	l.backendPolicyInformer = informer
 	l.backendPolicyInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			l.events.Notify(nil, obj)
@@ -495,9 +714,207 @@ func (l *listers) createBackendPolicyLister(informer informersgatewayv1alpha1.Ba
 	})
 }
 
-func (l *listers) createEndpointLister(informer informerscore.EndpointsInformer) {
-	l.endpointLister = informer.Lister()
-	l.endpointInformer = informer.Informer()
+func (l *listers) createTCPServiceLister(informer cache.SharedIndexInformer) {
+	l.tcpServiceLister = haclient.NewTCPServiceLister(informer.GetIndexer())
+	l.tcpServiceInformer = informer
+	l.tcpServiceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			l.events.Notify(nil, obj)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldSvc := old.(*v1alpha1.TCPService)
+			curSvc := cur.(*v1alpha1.TCPService)
+			// status updates made by the controller itself should not trigger a new sync
+			if !reflect.DeepEqual(oldSvc.Spec, curSvc.Spec) {
+				l.events.Notify(old, cur)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			svc, ok := obj.(*v1alpha1.TCPService)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					l.logger.Error("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				if svc, ok = tombstone.Obj.(*v1alpha1.TCPService); !ok {
+					l.logger.Error("Tombstone contained object that is not a TCPService: %#v", obj)
+					return
+				}
+			}
+			l.events.Notify(svc, nil)
+		},
+	})
+}
+
+func (l *listers) createUDPServiceLister(informer cache.SharedIndexInformer) {
+	l.udpServiceLister = haclient.NewUDPServiceLister(informer.GetIndexer())
+	l.udpServiceInformer = informer
+	l.udpServiceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			l.events.Notify(nil, obj)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldSvc := old.(*v1alpha1.UDPService)
+			curSvc := cur.(*v1alpha1.UDPService)
+			// status updates made by the controller itself should not trigger a new sync
+			if !reflect.DeepEqual(oldSvc.Spec, curSvc.Spec) {
+				l.events.Notify(old, cur)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			svc, ok := obj.(*v1alpha1.UDPService)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					l.logger.Error("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				if svc, ok = tombstone.Obj.(*v1alpha1.UDPService); !ok {
+					l.logger.Error("Tombstone contained object that is not an UDPService: %#v", obj)
+					return
+				}
+			}
+			l.events.Notify(svc, nil)
+		},
+	})
+}
+
+func (l *listers) createHostLister(informer cache.SharedIndexInformer) {
+	l.hostLister = haclient.NewHostLister(informer.GetIndexer())
+	l.hostInformer = informer
+	l.hostInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			l.events.Notify(nil, obj)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldHost := old.(*v1alpha1.Host)
+			curHost := cur.(*v1alpha1.Host)
+			// status updates made by the controller itself should not trigger a new sync
+			if !reflect.DeepEqual(oldHost.Spec, curHost.Spec) {
+				l.events.Notify(old, cur)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			host, ok := obj.(*v1alpha1.Host)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					l.logger.Error("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				if host, ok = tombstone.Obj.(*v1alpha1.Host); !ok {
+					l.logger.Error("Tombstone contained object that is not a Host: %#v", obj)
+					return
+				}
+			}
+			l.events.Notify(host, nil)
+		},
+	})
+}
+
+func (l *listers) createBackendLister(informer cache.SharedIndexInformer) {
+	l.backendLister = haclient.NewBackendLister(informer.GetIndexer())
+	l.backendInformer = informer
+	l.backendInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			l.events.Notify(nil, obj)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldBackend := old.(*v1alpha1.Backend)
+			curBackend := cur.(*v1alpha1.Backend)
+			// status updates made by the controller itself should not trigger a new sync
+			if !reflect.DeepEqual(oldBackend.Spec, curBackend.Spec) {
+				l.events.Notify(old, cur)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			backend, ok := obj.(*v1alpha1.Backend)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					l.logger.Error("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				if backend, ok = tombstone.Obj.(*v1alpha1.Backend); !ok {
+					l.logger.Error("Tombstone contained object that is not a Backend: %#v", obj)
+					return
+				}
+			}
+			l.events.Notify(backend, nil)
+		},
+	})
+}
+
+func (l *listers) createMiddlewareLister(informer cache.SharedIndexInformer) {
+	l.middlewareLister = haclient.NewMiddlewareLister(informer.GetIndexer())
+	l.middlewareInformer = informer
+	l.middlewareInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			l.events.Notify(nil, obj)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldMiddleware := old.(*v1alpha1.Middleware)
+			curMiddleware := cur.(*v1alpha1.Middleware)
+			// status updates made by the controller itself should not trigger a new sync
+			if !reflect.DeepEqual(oldMiddleware.Spec, curMiddleware.Spec) {
+				l.events.Notify(old, cur)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			middleware, ok := obj.(*v1alpha1.Middleware)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					l.logger.Error("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				if middleware, ok = tombstone.Obj.(*v1alpha1.Middleware); !ok {
+					l.logger.Error("Tombstone contained object that is not a Middleware: %#v", obj)
+					return
+				}
+			}
+			l.events.Notify(middleware, nil)
+		},
+	})
+}
+
+func (l *listers) createAuthConfigLister(informer cache.SharedIndexInformer) {
+	l.authConfigLister = haclient.NewAuthConfigLister(informer.GetIndexer())
+	l.authConfigInformer = informer
+	l.authConfigInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			l.events.Notify(nil, obj)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldAuthConfig := old.(*v1alpha1.AuthConfig)
+			curAuthConfig := cur.(*v1alpha1.AuthConfig)
+			// status updates made by the controller itself should not trigger a new sync
+			if !reflect.DeepEqual(oldAuthConfig.Spec, curAuthConfig.Spec) {
+				l.events.Notify(old, cur)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			authConfig, ok := obj.(*v1alpha1.AuthConfig)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					l.logger.Error("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				if authConfig, ok = tombstone.Obj.(*v1alpha1.AuthConfig); !ok {
+					l.logger.Error("Tombstone contained object that is not an AuthConfig: %#v", obj)
+					return
+				}
+			}
+			l.events.Notify(authConfig, nil)
+		},
+	})
+}
+
+func (l *listers) createEndpointLister(informer cache.SharedIndexInformer) {
+	l.endpointLister = listerscore.NewEndpointsLister(informer.GetIndexer())
+	l.endpointInformer = informer
 	l.endpointInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			l.events.Notify(nil, obj)
@@ -515,9 +932,206 @@ func (l *listers) createEndpointLister(informer informerscore.EndpointsInformer)
 	})
 }
 
-func (l *listers) createServiceLister(informer informerscore.ServiceInformer) {
-	l.serviceLister = informer.Lister()
-	l.serviceInformer = informer.Informer()
+func (l *listers) createIngressClassParametersLister(informer cache.SharedIndexInformer) {
+	l.ingClassParamLister = haclient.NewIngressClassParametersLister(informer.GetIndexer())
+	l.ingClassParamInformer = informer
+	l.ingClassParamInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			l.events.Notify(nil, obj)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldParams := old.(*v1alpha1.IngressClassParameters)
+			curParams := cur.(*v1alpha1.IngressClassParameters)
+			if !reflect.DeepEqual(oldParams.Spec, curParams.Spec) {
+				l.events.Notify(old, cur)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			params, ok := obj.(*v1alpha1.IngressClassParameters)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					l.logger.Error("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				if params, ok = tombstone.Obj.(*v1alpha1.IngressClassParameters); !ok {
+					l.logger.Error("Tombstone contained object that is not an IngressClassParameters: %#v", obj)
+					return
+				}
+			}
+			l.events.Notify(params, nil)
+		},
+	})
+}
+
+func (l *listers) createGlobalLister(informer cache.SharedIndexInformer) {
+	l.globalLister = haclient.NewGlobalLister(informer.GetIndexer())
+	l.globalInformer = informer
+	l.globalInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			l.events.Notify(nil, obj)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldGlobal := old.(*v1alpha1.Global)
+			curGlobal := cur.(*v1alpha1.Global)
+			// status updates, made by the controller itself, are ignored
+			if !reflect.DeepEqual(oldGlobal.Spec, curGlobal.Spec) {
+				l.events.Notify(old, cur)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			global, ok := obj.(*v1alpha1.Global)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					l.logger.Error("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				if global, ok = tombstone.Obj.(*v1alpha1.Global); !ok {
+					l.logger.Error("Tombstone contained object that is not a Global: %#v", obj)
+					return
+				}
+			}
+			l.events.Notify(global, nil)
+		},
+	})
+}
+
+func (l *listers) createIPListLister(informer cache.SharedIndexInformer) {
+	l.ipListLister = haclient.NewIPListLister(informer.GetIndexer())
+	l.ipListInformer = informer
+	l.ipListInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			l.events.Notify(nil, obj)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldIPList := old.(*v1alpha1.IPList)
+			curIPList := cur.(*v1alpha1.IPList)
+			// status updates, made by the controller itself, are ignored
+			if !reflect.DeepEqual(oldIPList.Spec, curIPList.Spec) {
+				l.events.Notify(old, cur)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			ipList, ok := obj.(*v1alpha1.IPList)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					l.logger.Error("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				if ipList, ok = tombstone.Obj.(*v1alpha1.IPList); !ok {
+					l.logger.Error("Tombstone contained object that is not a IPList: %#v", obj)
+					return
+				}
+			}
+			l.events.Notify(ipList, nil)
+		},
+	})
+}
+
+func (l *listers) createRateLimitLister(informer cache.SharedIndexInformer) {
+	l.rateLimitLister = haclient.NewRateLimitLister(informer.GetIndexer())
+	l.rateLimitInformer = informer
+	l.rateLimitInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			l.events.Notify(nil, obj)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldRateLimit := old.(*v1alpha1.RateLimit)
+			curRateLimit := cur.(*v1alpha1.RateLimit)
+			// status updates, made by the controller itself, are ignored
+			if !reflect.DeepEqual(oldRateLimit.Spec, curRateLimit.Spec) {
+				l.events.Notify(old, cur)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			rateLimit, ok := obj.(*v1alpha1.RateLimit)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					l.logger.Error("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				if rateLimit, ok = tombstone.Obj.(*v1alpha1.RateLimit); !ok {
+					l.logger.Error("Tombstone contained object that is not a RateLimit: %#v", obj)
+					return
+				}
+			}
+			l.events.Notify(rateLimit, nil)
+		},
+	})
+}
+
+func (l *listers) createServiceImportLister(informer cache.SharedIndexInformer) {
+	l.serviceImportLister = haclient.NewServiceImportLister(informer.GetIndexer())
+	l.serviceImportInformer = informer
+	l.serviceImportInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			l.events.Notify(nil, obj)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldImp := old.(*mcsv1alpha1.ServiceImport)
+			curImp := cur.(*mcsv1alpha1.ServiceImport)
+			if !reflect.DeepEqual(oldImp.Spec, curImp.Spec) || !reflect.DeepEqual(oldImp.Status, curImp.Status) {
+				l.events.Notify(old, cur)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			imp, ok := obj.(*mcsv1alpha1.ServiceImport)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					l.logger.Error("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				if imp, ok = tombstone.Obj.(*mcsv1alpha1.ServiceImport); !ok {
+					l.logger.Error("Tombstone contained object that is not a ServiceImport: %#v", obj)
+					return
+				}
+			}
+			l.events.Notify(imp, nil)
+		},
+	})
+}
+
+func (l *listers) createEndpointSliceLister(informer cache.SharedIndexInformer) {
+	l.endpointSliceLister = listersdiscovery.NewEndpointSliceLister(informer.GetIndexer())
+	l.endpointInformer = informer
+	l.endpointInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			l.events.Notify(nil, obj)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldEPS := old.(*discoveryv1.EndpointSlice)
+			curEPS := cur.(*discoveryv1.EndpointSlice)
+			if !reflect.DeepEqual(oldEPS.Endpoints, curEPS.Endpoints) || !reflect.DeepEqual(oldEPS.Ports, curEPS.Ports) {
+				l.events.Notify(oldEPS, curEPS)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			eps, ok := obj.(*discoveryv1.EndpointSlice)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					l.logger.Error("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				if eps, ok = tombstone.Obj.(*discoveryv1.EndpointSlice); !ok {
+					l.logger.Error("Tombstone contained object that is not an EndpointSlice: %#v", obj)
+					return
+				}
+			}
+			// a removed slice removes endpoints from a service
+			// which should be updated, unlike removed Endpoints
+			l.events.Notify(eps, nil)
+		},
+	})
+}
+
+func (l *listers) createServiceLister(informer cache.SharedIndexInformer) {
+	l.serviceLister = listerscore.NewServiceLister(informer.GetIndexer())
+	l.serviceInformer = informer
 	l.serviceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			l.events.Notify(nil, obj)
@@ -545,9 +1159,9 @@ func (l *listers) createServiceLister(informer informerscore.ServiceInformer) {
 	})
 }
 
-func (l *listers) createSecretLister(informer informerscore.SecretInformer) {
-	l.secretLister = informer.Lister()
-	l.secretInformer = informer.Informer()
+func (l *listers) createSecretLister(informer cache.SharedIndexInformer) {
+	l.secretLister = listerscore.NewSecretLister(informer.GetIndexer())
+	l.secretInformer = informer
 	l.secretInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			l.events.Notify(nil, obj)
@@ -575,9 +1189,9 @@ func (l *listers) createSecretLister(informer informerscore.SecretInformer) {
 	})
 }
 
-func (l *listers) createConfigMapLister(informer informerscore.ConfigMapInformer) {
-	l.configMapLister = informer.Lister()
-	l.configMapInformer = informer.Informer()
+func (l *listers) createConfigMapLister(informer cache.SharedIndexInformer) {
+	l.configMapLister = listerscore.NewConfigMapLister(informer.GetIndexer())
+	l.configMapInformer = informer
 	l.configMapInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			if l.events.IsValidConfigMap(obj.(*api.ConfigMap)) {
@@ -601,9 +1215,9 @@ func (l *listers) createConfigMapLister(informer informerscore.ConfigMapInformer
 	})
 }
 
-func (l *listers) createPodLister(informer informerscore.PodInformer) {
-	l.podLister = informer.Lister()
-	l.podInformer = informer.Informer()
+func (l *listers) createPodLister(informer cache.SharedIndexInformer) {
+	l.podLister = listerscore.NewPodLister(informer.GetIndexer())
+	l.podInformer = informer
 	l.podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		UpdateFunc: func(old, cur interface{}) {
 			oldPod := old.(*api.Pod)
@@ -622,3 +1236,19 @@ func (l *listers) createNodeLister(informer informerscore.NodeInformer) {
 	l.nodeLister = informer.Lister()
 	l.nodeInformer = informer.Informer()
 }
+
+func (l *listers) createNamespaceLister(informer informerscore.NamespaceInformer) {
+	l.namespaceLister = informer.Lister()
+	l.namespaceInformer = informer.Informer()
+	l.namespaceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, cur interface{}) {
+			oldNS := old.(*api.Namespace)
+			curNS := cur.(*api.Namespace)
+			if !reflect.DeepEqual(oldNS.Labels, curNS.Labels) {
+				// resources of this namespace might have moved to or from
+				// this shard, need to start a full resync
+				l.events.Notify(nil, nil)
+			}
+		},
+	})
+}
@@ -17,13 +17,83 @@ limitations under the License.
 package controller
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/golang/glog"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
 )
 
 type logger struct {
-	depth int
+	depth     int
+	component string
+	json      *jsonLogger
+}
+
+// jsonLogger is shared by all the components of a logger configured
+// with --log-format=json
+type jsonLogger struct {
+	mutex  sync.Mutex
+	out    io.Writer
+	now    func() time.Time
+	syncID int64
+}
+
+// jsonEntry is a log line of --log-format=json
+type jsonEntry struct {
+	Level     string         `json:"level"`
+	Timestamp string         `json:"ts"`
+	Component string         `json:"component,omitempty"`
+	Caller    string         `json:"caller,omitempty"`
+	Object    *jsonObjectRef `json:"object,omitempty"`
+	SyncID    int64          `json:"syncID,omitempty"`
+	Message   string         `json:"msg"`
+}
+
+type jsonObjectRef struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+func newLogger(format string) *logger {
+	l := &logger{depth: 1, component: "controller"}
+	if format == logFormatJSON {
+		l.json = &jsonLogger{
+			out: os.Stderr,
+			now: time.Now,
+		}
+	}
+	return l
+}
+
+// withComponent creates a logger of another component of the controller,
+// sharing the output and the sync id.
+func (l *logger) withComponent(component string) *logger {
+	c := *l
+	c.component = component
+	return &c
+}
+
+// setSyncID assigns the id of the running haproxy update to the next
+// structured log lines, 0 means no update is running.
+func (l *logger) setSyncID(id int) {
+	if l.json != nil {
+		atomic.StoreInt64(&l.json.syncID, int64(id))
+	}
 }
 
 func (l *logger) build(msg string, args []interface{}) string {
@@ -33,24 +103,72 @@ func (l *logger) build(msg string, args []interface{}) string {
 	return fmt.Sprintf(msg, args...)
 }
 
+func (l *logger) write(level string, msg string, args []interface{}) {
+	entry := jsonEntry{
+		Level:     level,
+		Timestamp: l.json.now().UTC().Format(time.RFC3339Nano),
+		Component: l.component,
+		SyncID:    atomic.LoadInt64(&l.json.syncID),
+		Message:   l.build(msg, args),
+	}
+	// write() is one level deeper than the glog's *Depth() functions
+	if _, file, line, ok := runtime.Caller(l.depth + 1); ok {
+		entry.Caller = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+	for _, arg := range args {
+		if ref, ok := arg.(types.ObjectRef); ok {
+			if kind, namespace, name := ref.ObjectRef(); kind != "" {
+				entry.Object = &jsonObjectRef{Kind: kind, Namespace: namespace, Name: name}
+				break
+			}
+		}
+	}
+	l.json.mutex.Lock()
+	defer l.json.mutex.Unlock()
+	enc := json.NewEncoder(l.json.out)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(entry)
+}
+
 func (l *logger) InfoV(v int, msg string, args ...interface{}) {
 	if glog.V(glog.Level(v)) {
+		if l.json != nil {
+			l.write("info", msg, args)
+			return
+		}
 		glog.InfoDepth(l.depth, l.build(msg, args))
 	}
 }
 
 func (l *logger) Info(msg string, args ...interface{}) {
+	if l.json != nil {
+		l.write("info", msg, args)
+		return
+	}
 	glog.InfoDepth(l.depth, l.build(msg, args))
 }
 
 func (l *logger) Warn(msg string, args ...interface{}) {
+	if l.json != nil {
+		l.write("warning", msg, args)
+		return
+	}
 	glog.WarningDepth(l.depth, l.build(msg, args))
 }
 
 func (l *logger) Error(msg string, args ...interface{}) {
+	if l.json != nil {
+		l.write("error", msg, args)
+		return
+	}
 	glog.ErrorDepth(l.depth, l.build(msg, args))
 }
 
 func (l *logger) Fatal(msg string, args ...interface{}) {
+	if l.json != nil {
+		l.write("fatal", msg, args)
+		glog.Flush()
+		os.Exit(255)
+	}
 	glog.FatalDepth(l.depth, l.build(msg, args))
 }
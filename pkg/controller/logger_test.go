@@ -0,0 +1,78 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
+)
+
+func TestLoggerJSON(t *testing.T) {
+	var nilSource *annotations.Source
+	source := &annotations.Source{Type: "ingress", Namespace: "default", Name: "echo"}
+	testCases := []struct {
+		component string
+		syncID    int
+		log       func(l *logger)
+		expected  string
+	}{
+		// 0
+		{
+			log: func(l *logger) {
+				l.Info("starting")
+			},
+			expected: `{"level":"info","ts":"2021-06-01T12:00:00Z","component":"controller","caller":"logger_test.go:40","msg":"starting"}`,
+		},
+		// 1
+		{
+			component: "converter",
+			syncID:    5,
+			log: func(l *logger) {
+				l.Warn("skipping backend config of %v: %v", source, "service not found")
+			},
+			expected: `{"level":"warning","ts":"2021-06-01T12:00:00Z","component":"converter","caller":"logger_test.go:49","object":{"kind":"ingress","namespace":"default","name":"echo"},"syncID":5,"msg":"skipping backend config of ingress 'default/echo': service not found"}`,
+		},
+		// 2
+		{
+			component: "instance",
+			log: func(l *logger) {
+				l.Error("error on %v: %s", nilSource, "\"quoted\"")
+			},
+			expected: `{"level":"error","ts":"2021-06-01T12:00:00Z","component":"instance","caller":"logger_test.go:57","msg":"error on <nil>: \"quoted\""}`,
+		},
+	}
+	for i, test := range testCases {
+		out := &bytes.Buffer{}
+		l := newLogger(logFormatJSON)
+		l.json.out = out
+		l.json.now = func() time.Time {
+			return time.Date(2021, 6, 1, 9, 0, 0, 0, time.FixedZone("-03", -3*60*60))
+		}
+		if test.component != "" {
+			l = l.withComponent(test.component)
+		}
+		l.setSyncID(test.syncID)
+		test.log(l)
+		if actual := strings.TrimSuffix(out.String(), "\n"); actual != test.expected {
+			t.Errorf("%d: expected\n%s\nbut was\n%s", i, test.expected, actual)
+		}
+	}
+}
@@ -28,8 +28,18 @@ type metrics struct {
 	ctlProcTimeSum     *prometheus.CounterVec
 	ctlProcCount       *prometheus.CounterVec
 	procSecondsCounter *prometheus.CounterVec
+	oldProcsGauge      *prometheus.GaugeVec
+	ejectionsGauge     *prometheus.GaugeVec
+	blockedUAGauge     *prometheus.GaugeVec
+	hostRequests       *prometheus.CounterVec
+	hostErrors         *prometheus.CounterVec
+	hostResponseTime   *prometheus.HistogramVec
+	logSinkRecords     *prometheus.CounterVec
+	logSinkFailures    *prometheus.CounterVec
 	updatesCounter     *prometheus.CounterVec
+	reloadCauseCounter *prometheus.CounterVec
 	updateSuccessGauge *prometheus.GaugeVec
+	degradedGauge      *prometheus.GaugeVec
 	certExpireGauge    *prometheus.GaugeVec
 	certSigningCounter *prometheus.CounterVec
 	lastTrack          time.Time
@@ -71,6 +81,71 @@ func createMetrics(bucketsResponseTime []float64) *metrics {
 			},
 			[]string{},
 		),
+		oldProcsGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "haproxy_old_processes",
+				Help:      "Number of old haproxy processes still running after a reload, waiting for long lived connections to finish.",
+			},
+			[]string{},
+		),
+		ejectionsGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "backend_ejections",
+				Help:      "Number of times the servers of a backend with outlier detection were marked as down since the last haproxy reload.",
+			},
+			[]string{"backend"},
+		),
+		blockedUAGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "blocked_user_agents",
+				Help:      "Number of requests blocked by a user-agent pattern since the last haproxy reload.",
+			},
+			[]string{"pattern"},
+		),
+		hostRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "host_requests_total",
+				Help:      "Cumulative number of HTTP requests of a hostname, by the namespace and name of the resource that declared its paths.",
+			},
+			[]string{"host", "namespace", "ingress"},
+		),
+		hostErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "host_errors_total",
+				Help:      "Cumulative number of HTTP 5xx responses of a hostname, by the namespace and name of the resource that declared its paths.",
+			},
+			[]string{"host", "namespace", "ingress"},
+		),
+		hostResponseTime: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "host_response_time_seconds",
+				Help:      "Average total time of the most recent HTTP requests of a hostname, sampled on every read of the haproxy stats.",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"host", "namespace", "ingress"},
+		),
+		logSinkRecords: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "log_sink_records_total",
+				Help:      "Cumulative number of access log records shipped by the log sink.",
+			},
+			[]string{},
+		),
+		logSinkFailures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "log_sink_failures_total",
+				Help:      "Cumulative number of failed attempts to ship a batch of access log records.",
+			},
+			[]string{},
+		),
 		updatesCounter: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
@@ -79,6 +154,14 @@ func createMetrics(bucketsResponseTime []float64) *metrics {
 			},
 			[]string{"status"},
 		),
+		reloadCauseCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "reload_cause_total",
+				Help:      "Cumulative number of haproxy reloads by the changed part of the configuration and the kind of the changed object.",
+			},
+			[]string{"reason", "object"},
+		),
 		updateSuccessGauge: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -87,6 +170,14 @@ func createMetrics(bucketsResponseTime []float64) *metrics {
 			},
 			[]string{},
 		),
+		degradedGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "degraded",
+				Help:      "Whether haproxy is running the last known good configuration due to a failed update.",
+			},
+			[]string{},
+		),
 		certExpireGauge: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -108,8 +199,18 @@ func createMetrics(bucketsResponseTime []float64) *metrics {
 	prometheus.MustRegister(metrics.ctlProcTimeSum)
 	prometheus.MustRegister(metrics.ctlProcCount)
 	prometheus.MustRegister(metrics.procSecondsCounter)
+	prometheus.MustRegister(metrics.oldProcsGauge)
+	prometheus.MustRegister(metrics.ejectionsGauge)
+	prometheus.MustRegister(metrics.blockedUAGauge)
+	prometheus.MustRegister(metrics.hostRequests)
+	prometheus.MustRegister(metrics.hostErrors)
+	prometheus.MustRegister(metrics.hostResponseTime)
+	prometheus.MustRegister(metrics.logSinkRecords)
+	prometheus.MustRegister(metrics.logSinkFailures)
 	prometheus.MustRegister(metrics.updatesCounter)
+	prometheus.MustRegister(metrics.reloadCauseCounter)
 	prometheus.MustRegister(metrics.updateSuccessGauge)
+	prometheus.MustRegister(metrics.degradedGauge)
 	prometheus.MustRegister(metrics.certExpireGauge)
 	prometheus.MustRegister(metrics.certSigningCounter)
 	return metrics
@@ -127,6 +228,10 @@ func (m *metrics) HAProxySetSSLCertResponseTime(duration time.Duration) {
 	m.responseTime.WithLabelValues("set_ssl_cert").Observe(duration.Seconds())
 }
 
+func (m *metrics) HAProxySetACLResponseTime(duration time.Duration) {
+	m.responseTime.WithLabelValues("set_acl").Observe(duration.Seconds())
+}
+
 func (m *metrics) ControllerProcTime(task string, duration time.Duration) {
 	m.ctlProcTimeSum.WithLabelValues(task).Add(duration.Seconds())
 	m.ctlProcCount.WithLabelValues(task).Inc()
@@ -143,10 +248,57 @@ func (m *metrics) AddIdleFactor(idle int) {
 	m.procSecondsCounter.WithLabelValues().Add(float64(100-idle) * totalTime / 100)
 }
 
+func (m *metrics) SetOldProcs(count int) {
+	m.oldProcsGauge.WithLabelValues().Set(float64(count))
+}
+
+func (m *metrics) SetBackendEjections(backend string, count int) {
+	m.ejectionsGauge.WithLabelValues(backend).Set(float64(count))
+}
+
+func (m *metrics) ClearBackendEjections() {
+	m.ejectionsGauge.Reset()
+}
+
+func (m *metrics) SetBlockedUserAgents(pattern string, count int) {
+	m.blockedUAGauge.WithLabelValues(pattern).Set(float64(count))
+}
+
+func (m *metrics) ClearBlockedUserAgents() {
+	m.blockedUAGauge.Reset()
+}
+
+func (m *metrics) AddHostTraffic(hostname, namespace, name string, requests, errors int) {
+	m.hostRequests.WithLabelValues(hostname, namespace, name).Add(float64(requests))
+	m.hostErrors.WithLabelValues(hostname, namespace, name).Add(float64(errors))
+}
+
+func (m *metrics) ObserveHostResponseTime(hostname, namespace, name string, duration time.Duration) {
+	m.hostResponseTime.WithLabelValues(hostname, namespace, name).Observe(duration.Seconds())
+}
+
+func (m *metrics) DeleteHostTraffic(hostname, namespace, name string) {
+	m.hostRequests.DeleteLabelValues(hostname, namespace, name)
+	m.hostErrors.DeleteLabelValues(hostname, namespace, name)
+	m.hostResponseTime.DeleteLabelValues(hostname, namespace, name)
+}
+
+func (m *metrics) AddLogSinkRecords(count int) {
+	m.logSinkRecords.WithLabelValues().Add(float64(count))
+}
+
+func (m *metrics) IncLogSinkFailure() {
+	m.logSinkFailures.WithLabelValues().Inc()
+}
+
 func (m *metrics) IncUpdateNoop() {
 	m.updatesCounter.WithLabelValues("noop").Inc()
 }
 
+func (m *metrics) IncReloadCause(reason, object string) {
+	m.reloadCauseCounter.WithLabelValues(reason, object).Inc()
+}
+
 func (m *metrics) IncUpdateDynamic() {
 	m.updatesCounter.WithLabelValues("dynamic").Inc()
 }
@@ -160,6 +312,11 @@ func (m *metrics) UpdateSuccessful(success bool) {
 	m.updateSuccessGauge.WithLabelValues().Set(value[success])
 }
 
+func (m *metrics) SetDegraded(degraded bool) {
+	value := map[bool]float64{false: 0, true: 1}
+	m.degradedGauge.WithLabelValues().Set(value[degraded])
+}
+
 func (m *metrics) SetCertExpireDate(domain, cn string, notAfter *time.Time) {
 	if notAfter == nil {
 		m.certExpireGauge.DeleteLabelValues(domain, cn)
@@ -0,0 +1,250 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// ignoredNamespacesSelector returns the field selector that filters out the
+// resources of the ignored namespaces, see --ignore-namespaces
+func ignoredNamespacesSelector(namespaces []string) string {
+	selectors := make([]string, len(namespaces))
+	for i, ns := range namespaces {
+		selectors[i] = "metadata.namespace!=" + ns
+	}
+	return strings.Join(selectors, ",")
+}
+
+// newMultiNamespaceInformer merges the informers of the same resource from
+// distinct namespaces. client-go's informers watch either one or all the
+// namespaces, this informer allows to watch a list of namespaces, see
+// --watch-namespaces
+func newMultiNamespaceInformer(namespaces []string, informers []cache.SharedIndexInformer) cache.SharedIndexInformer {
+	if len(informers) == 1 {
+		return informers[0]
+	}
+	indexers := make(map[string]cache.Indexer, len(informers))
+	for i, informer := range informers {
+		indexers[namespaces[i]] = informer.GetIndexer()
+	}
+	return &multiNamespaceInformer{
+		informers: informers,
+		indexer: &multiNamespaceIndexer{
+			namespaces: namespaces,
+			indexers:   indexers,
+		},
+	}
+}
+
+type multiNamespaceInformer struct {
+	informers []cache.SharedIndexInformer
+	indexer   *multiNamespaceIndexer
+}
+
+func (i *multiNamespaceInformer) AddEventHandler(handler cache.ResourceEventHandler) {
+	for _, informer := range i.informers {
+		informer.AddEventHandler(handler)
+	}
+}
+
+func (i *multiNamespaceInformer) AddEventHandlerWithResyncPeriod(handler cache.ResourceEventHandler, resyncPeriod time.Duration) {
+	for _, informer := range i.informers {
+		informer.AddEventHandlerWithResyncPeriod(handler, resyncPeriod)
+	}
+}
+
+func (i *multiNamespaceInformer) GetStore() cache.Store {
+	return i.indexer
+}
+
+func (i *multiNamespaceInformer) GetController() cache.Controller {
+	// there is one controller per namespace
+	return nil
+}
+
+func (i *multiNamespaceInformer) Run(stopCh <-chan struct{}) {
+	for _, informer := range i.informers {
+		go informer.Run(stopCh)
+	}
+	<-stopCh
+}
+
+func (i *multiNamespaceInformer) HasSynced() bool {
+	for _, informer := range i.informers {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+func (i *multiNamespaceInformer) LastSyncResourceVersion() string {
+	// resource versions of distinct watches cannot be compared
+	return ""
+}
+
+func (i *multiNamespaceInformer) SetWatchErrorHandler(handler cache.WatchErrorHandler) error {
+	for _, informer := range i.informers {
+		if err := informer.SetWatchErrorHandler(handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *multiNamespaceInformer) AddIndexers(indexers cache.Indexers) error {
+	return i.indexer.AddIndexers(indexers)
+}
+
+func (i *multiNamespaceInformer) GetIndexer() cache.Indexer {
+	return i.indexer
+}
+
+// multiNamespaceIndexer is a read only view of the indexers of the informers
+// of a multiNamespaceInformer. Changes are made by the informers.
+type multiNamespaceIndexer struct {
+	namespaces []string
+	indexers   map[string]cache.Indexer
+}
+
+var errReadOnlyIndexer = fmt.Errorf("multi namespace indexer is read only")
+
+func (i *multiNamespaceIndexer) Add(obj interface{}) error {
+	return errReadOnlyIndexer
+}
+
+func (i *multiNamespaceIndexer) Update(obj interface{}) error {
+	return errReadOnlyIndexer
+}
+
+func (i *multiNamespaceIndexer) Delete(obj interface{}) error {
+	return errReadOnlyIndexer
+}
+
+func (i *multiNamespaceIndexer) Replace(list []interface{}, resourceVersion string) error {
+	return errReadOnlyIndexer
+}
+
+func (i *multiNamespaceIndexer) Resync() error {
+	return errReadOnlyIndexer
+}
+
+func (i *multiNamespaceIndexer) List() []interface{} {
+	var list []interface{}
+	for _, ns := range i.namespaces {
+		list = append(list, i.indexers[ns].List()...)
+	}
+	return list
+}
+
+func (i *multiNamespaceIndexer) ListKeys() []string {
+	var keys []string
+	for _, ns := range i.namespaces {
+		keys = append(keys, i.indexers[ns].ListKeys()...)
+	}
+	return keys
+}
+
+func (i *multiNamespaceIndexer) Get(obj interface{}) (item interface{}, exists bool, err error) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	return i.GetByKey(key)
+}
+
+func (i *multiNamespaceIndexer) GetByKey(key string) (item interface{}, exists bool, err error) {
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	indexer, found := i.indexers[namespace]
+	if !found {
+		return nil, false, nil
+	}
+	return indexer.GetByKey(key)
+}
+
+func (i *multiNamespaceIndexer) Index(indexName string, obj interface{}) ([]interface{}, error) {
+	var list []interface{}
+	for _, ns := range i.namespaces {
+		items, err := i.indexers[ns].Index(indexName, obj)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, items...)
+	}
+	return list, nil
+}
+
+func (i *multiNamespaceIndexer) IndexKeys(indexName, indexedValue string) ([]string, error) {
+	var keys []string
+	for _, ns := range i.namespaces {
+		items, err := i.indexers[ns].IndexKeys(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, items...)
+	}
+	return keys, nil
+}
+
+func (i *multiNamespaceIndexer) ListIndexFuncValues(indexName string) []string {
+	values := map[string]bool{}
+	for _, ns := range i.namespaces {
+		for _, value := range i.indexers[ns].ListIndexFuncValues(indexName) {
+			values[value] = true
+		}
+	}
+	list := make([]string, 0, len(values))
+	for value := range values {
+		list = append(list, value)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (i *multiNamespaceIndexer) ByIndex(indexName, indexedValue string) ([]interface{}, error) {
+	var list []interface{}
+	for _, ns := range i.namespaces {
+		items, err := i.indexers[ns].ByIndex(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, items...)
+	}
+	return list, nil
+}
+
+func (i *multiNamespaceIndexer) GetIndexers() cache.Indexers {
+	return i.indexers[i.namespaces[0]].GetIndexers()
+}
+
+func (i *multiNamespaceIndexer) AddIndexers(newIndexers cache.Indexers) error {
+	for _, ns := range i.namespaces {
+		if err := i.indexers[ns].AddIndexers(newIndexers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	listerscore "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestIgnoredNamespacesSelector(t *testing.T) {
+	testCases := []struct {
+		namespaces []string
+		expected   string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			namespaces: []string{"kube-system"},
+			expected:   "metadata.namespace!=kube-system",
+		},
+		// 2
+		{
+			namespaces: []string{"kube-system", "monitoring"},
+			expected:   "metadata.namespace!=kube-system,metadata.namespace!=monitoring",
+		},
+	}
+	for i, test := range testCases {
+		if actual := ignoredNamespacesSelector(test.namespaces); actual != test.expected {
+			t.Errorf("%d: expected '%s' but was '%s'", i, test.expected, actual)
+		}
+	}
+}
+
+func TestMultiNamespaceIndexer(t *testing.T) {
+	svc := func(namespace, name string) *api.Service {
+		return &api.Service{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	}
+	namespaces := []string{"ns1", "ns2"}
+	informers := make([]cache.SharedIndexInformer, len(namespaces))
+	for i := range namespaces {
+		informers[i] = cache.NewSharedIndexInformer(nil, &api.Service{}, 0, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	}
+	_ = informers[0].GetIndexer().Add(svc("ns1", "app1"))
+	_ = informers[0].GetIndexer().Add(svc("ns1", "app2"))
+	_ = informers[1].GetIndexer().Add(svc("ns2", "app1"))
+	informer := newMultiNamespaceInformer(namespaces, informers)
+	lister := listerscore.NewServiceLister(informer.GetIndexer())
+
+	names := func(services []*api.Service) []string {
+		list := make([]string, len(services))
+		for i, s := range services {
+			list[i] = s.Namespace + "/" + s.Name
+		}
+		sort.Strings(list)
+		return list
+	}
+
+	all, _ := lister.List(labels.Everything())
+	if expected, actual := []string{"ns1/app1", "ns1/app2", "ns2/app1"}, names(all); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("list: expected %v but was %v", expected, actual)
+	}
+	ns2, _ := lister.Services("ns2").List(labels.Everything())
+	if expected, actual := []string{"ns2/app1"}, names(ns2); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("list ns2: expected %v but was %v", expected, actual)
+	}
+	if s, err := lister.Services("ns1").Get("app2"); err != nil || s.Name != "app2" {
+		t.Errorf("get ns1/app2: expected to be found, err: %v", err)
+	}
+	if _, err := lister.Services("ns3").Get("app1"); err == nil {
+		t.Errorf("get ns3/app1: expected not found")
+	}
+	if err := informer.GetIndexer().Add(svc("ns1", "app3")); err == nil {
+		t.Errorf("add: expected read only indexer")
+	}
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// runtimeOptions are the command-line options that can be changed without
+// restarting the controller, see --controller-options-configmap
+type runtimeOptions struct {
+	rateLimitUpdate  float32
+	waitBeforeUpdate time.Duration
+	syncQuietPeriod  time.Duration
+	syncMaxWait      time.Duration
+	logLevel         int
+	acmeCheckPeriod  time.Duration
+}
+
+const (
+	optRateLimitUpdate  = "rate-limit-update"
+	optWaitBeforeUpdate = "wait-before-update"
+	optSyncQuietPeriod  = "sync-quiet-period"
+	optSyncMaxWait      = "sync-max-wait"
+	optLogLevel         = "v"
+	optAcmeCheckPeriod  = "acme-check-period"
+)
+
+func (o *runtimeOptions) values() map[string]string {
+	return map[string]string{
+		optRateLimitUpdate:  strconv.FormatFloat(float64(o.rateLimitUpdate), 'f', -1, 32),
+		optWaitBeforeUpdate: o.waitBeforeUpdate.String(),
+		optSyncQuietPeriod:  o.syncQuietPeriod.String(),
+		optSyncMaxWait:      o.syncMaxWait.String(),
+		optLogLevel:         strconv.Itoa(o.logLevel),
+		optAcmeCheckPeriod:  o.acmeCheckPeriod.String(),
+	}
+}
+
+// parseRuntimeOptions reads the options declared in the data of the options
+// ConfigMap. Options not declared, or declared with an invalid value, use
+// the value of the defaults, which are the values of the command line.
+func parseRuntimeOptions(logger types.Logger, defaults runtimeOptions, data map[string]string) runtimeOptions {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	opts := defaults
+	for _, key := range keys {
+		value := data[key]
+		var err error
+		switch key {
+		case optRateLimitUpdate:
+			var rate float64
+			if rate, err = strconv.ParseFloat(value, 32); err == nil {
+				if rate < 0 {
+					err = fmt.Errorf("rate should not be negative: %s", value)
+				} else {
+					opts.rateLimitUpdate = float32(rate)
+				}
+			}
+		case optWaitBeforeUpdate:
+			err = parseOptionDuration(value, 0, &opts.waitBeforeUpdate)
+		case optSyncQuietPeriod:
+			err = parseOptionDuration(value, 0, &opts.syncQuietPeriod)
+		case optSyncMaxWait:
+			err = parseOptionDuration(value, time.Millisecond, &opts.syncMaxWait)
+		case optLogLevel:
+			var level int
+			if level, err = strconv.Atoi(value); err == nil {
+				if level < 0 {
+					err = fmt.Errorf("level should not be negative: %s", value)
+				} else {
+					opts.logLevel = level
+				}
+			}
+		case optAcmeCheckPeriod:
+			err = parseOptionDuration(value, time.Second, &opts.acmeCheckPeriod)
+		default:
+			logger.Warn("ignoring controller option '%s': option is not supported or cannot be changed at runtime", key)
+		}
+		if err != nil {
+			logger.Warn("ignoring invalid value of controller option '%s': %v", key, err)
+		}
+	}
+	return opts
+}
+
+func parseOptionDuration(value string, min time.Duration, duration *time.Duration) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if d < min {
+		return fmt.Errorf("duration should not be lower than %s: %s", min.String(), value)
+	}
+	*duration = d
+	return nil
+}
+
+// watchOptions applies the options ConfigMap when it is created, changed or
+// removed. A removed ConfigMap restores the values of the command line.
+func (hc *HAProxyController) watchOptions(informer cache.SharedInformer) {
+	key := *hc.optionsConfigMap
+	handle := func(obj interface{}, removed bool) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		cm, ok := obj.(*api.ConfigMap)
+		if !ok || cm.Namespace+"/"+cm.Name != key {
+			return
+		}
+		var data map[string]string
+		if !removed {
+			data = cm.Data
+		}
+		hc.applyOptions(parseRuntimeOptions(hc.logger, hc.defaultOptions, data))
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			handle(obj, false)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			handle(cur, false)
+		},
+		DeleteFunc: func(obj interface{}) {
+			handle(obj, true)
+		},
+	})
+}
+
+// applyOptions changes the components that use the runtime options. Only
+// changed options are applied.
+func (hc *HAProxyController) applyOptions(opts runtimeOptions) {
+	hc.optionsMutex.Lock()
+	defer hc.optionsMutex.Unlock()
+	cur := hc.currentOptions
+	if opts == cur {
+		return
+	}
+	curValues := cur.values()
+	newValues := opts.values()
+	keys := make([]string, 0, len(newValues))
+	for key := range newValues {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if curValues[key] != newValues[key] {
+			hc.logger.Info("changing controller option '%s' from %s to %s", key, curValues[key], newValues[key])
+		}
+	}
+	if opts.rateLimitUpdate != cur.rateLimitUpdate {
+		hc.ingressQueue.SetRate(opts.rateLimitUpdate)
+	}
+	if opts.waitBeforeUpdate != cur.waitBeforeUpdate || opts.syncQuietPeriod != cur.syncQuietPeriod || opts.syncMaxWait != cur.syncMaxWait {
+		hc.cache.setSyncTimings(opts.waitBeforeUpdate, opts.syncQuietPeriod, opts.syncMaxWait)
+	}
+	if opts.logLevel != cur.logLevel {
+		if err := flag.Set("v", strconv.Itoa(opts.logLevel)); err != nil {
+			hc.logger.Warn("error changing log level: %v", err)
+		}
+	}
+	if opts.acmeCheckPeriod != cur.acmeCheckPeriod {
+		select {
+		case hc.acmeCheckPeriodCh <- struct{}{}:
+		default:
+		}
+	}
+	hc.currentOptions = opts
+}
+
+func (hc *HAProxyController) acmeCheckPeriod() time.Duration {
+	hc.optionsMutex.Lock()
+	defer hc.optionsMutex.Unlock()
+	return hc.currentOptions.acmeCheckPeriod
+}
+
+// acmePeriodicCheck checks the acme certificates, waiting acme-check-period
+// between the checks. A changed period is applied in the current wait.
+func (hc *HAProxyController) acmePeriodicCheck() {
+	for {
+		_, _ = hc.instance.AcmeCheck("periodic check")
+		last := time.Now()
+		for waiting := true; waiting; {
+			timer := time.NewTimer(time.Until(last.Add(hc.acmeCheckPeriod())))
+			select {
+			case <-hc.stopCh:
+				timer.Stop()
+				return
+			case <-hc.acmeCheckPeriodCh:
+				timer.Stop()
+			case <-timer.C:
+				waiting = false
+			}
+		}
+	}
+}
+
+// commandLineLogLevel reads the verbosity level of the glog's -v option.
+func commandLineLogLevel() int {
+	if v := flag.Lookup("v"); v != nil {
+		if level, err := strconv.Atoi(v.Value.String()); err == nil {
+			return level
+		}
+	}
+	return 0
+}
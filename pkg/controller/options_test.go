@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestParseRuntimeOptions(t *testing.T) {
+	defaults := runtimeOptions{
+		rateLimitUpdate:  0.5,
+		waitBeforeUpdate: 200 * time.Millisecond,
+		syncMaxWait:      10 * time.Second,
+		logLevel:         2,
+		acmeCheckPeriod:  24 * time.Hour,
+	}
+	testCases := []struct {
+		data     map[string]string
+		expected func(o *runtimeOptions)
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			data: map[string]string{
+				"rate-limit-update":  "2",
+				"wait-before-update": "1s",
+				"sync-quiet-period":  "5s",
+				"sync-max-wait":      "1m",
+				"v":                  "4",
+				"acme-check-period":  "1h",
+			},
+			expected: func(o *runtimeOptions) {
+				o.rateLimitUpdate = 2
+				o.waitBeforeUpdate = time.Second
+				o.syncQuietPeriod = 5 * time.Second
+				o.syncMaxWait = time.Minute
+				o.logLevel = 4
+				o.acmeCheckPeriod = time.Hour
+			},
+		},
+		// 2
+		{
+			data: map[string]string{
+				"rate-limit-update": "0",
+				"v":                 "0",
+			},
+			expected: func(o *runtimeOptions) {
+				o.rateLimitUpdate = 0
+				o.logLevel = 0
+			},
+		},
+		// 3
+		{
+			data: map[string]string{
+				"rate-limit-update": "-1",
+				"v":                 "high",
+				"sync-max-wait":     "0s",
+				"acme-check-period": "1d",
+			},
+			logging: `
+WARN ignoring invalid value of controller option 'acme-check-period': time: unknown unit "d" in duration "1d"
+WARN ignoring invalid value of controller option 'rate-limit-update': rate should not be negative: -1
+WARN ignoring invalid value of controller option 'sync-max-wait': duration should not be lower than 1ms: 0s
+WARN ignoring invalid value of controller option 'v': strconv.Atoi: parsing "high": invalid syntax`,
+		},
+		// 4
+		{
+			data: map[string]string{
+				"watch-namespace":    "default",
+				"wait-before-update": "500ms",
+			},
+			expected: func(o *runtimeOptions) {
+				o.waitBeforeUpdate = 500 * time.Millisecond
+			},
+			logging: `WARN ignoring controller option 'watch-namespace': option is not supported or cannot be changed at runtime`,
+		},
+	}
+	for i, test := range testCases {
+		logger := types_helper.NewLoggerMock(t)
+		expected := defaults
+		if test.expected != nil {
+			test.expected(&expected)
+		}
+		opts := parseRuntimeOptions(logger, defaults, test.data)
+		if opts != expected {
+			t.Errorf("options differ on %d - expected: %+v - actual: %+v", i, expected, opts)
+		}
+		logger.CompareLogging(test.logging)
+	}
+}
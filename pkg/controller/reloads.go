@@ -0,0 +1,139 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// maxReloadObjects limits the number of changed objects and attributes
+// stored in a reload event, a full sync or a burst of changes might list
+// thousands
+const maxReloadObjects = 100
+
+// reloadHistory tracks the causes of the haproxy reloads: logs them,
+// updates the reload cause counter and stores the most recent ones
+// in a ring buffer
+type reloadHistory struct {
+	logger  types.Logger
+	metrics types.Metrics
+	mutex   sync.Mutex
+	events  []ingress.ReloadEvent
+	next    int
+	full    bool
+}
+
+func newReloadHistory(logger types.Logger, metrics types.Metrics, size int) *reloadHistory {
+	return &reloadHistory{
+		logger:  logger,
+		metrics: metrics,
+		events:  make([]ingress.ReloadEvent, size),
+	}
+}
+
+// track registers the reload made by the update `id`, if any
+func (h *reloadHistory) track(id int, changed *convtypes.ChangedObjects, reload *haproxy.ReloadStatus) {
+	if reload == nil {
+		return
+	}
+	event := ingress.ReloadEvent{
+		ID:         id,
+		Time:       time.Now(),
+		Reasons:    reload.Reasons,
+		Attributes: reload.Attributes,
+		Success:    reload.Success,
+	}
+	if changed != nil {
+		event.FullSync = changed.NeedFullSync
+		event.Objects = changed.Objects
+	}
+	// kinds are computed before truncating, so all of them are counted
+	kinds := objectKinds(event.Objects)
+	if len(event.Objects) > maxReloadObjects {
+		event.Objects = event.Objects[:maxReloadObjects]
+	}
+	if len(event.Attributes) > maxReloadObjects {
+		event.Attributes = event.Attributes[:maxReloadObjects]
+	}
+	h.logger.Info("haproxy reload cause id=%d: config changes %v; changed attributes %v; changed objects %v", id, event.Reasons, event.Attributes, event.Objects)
+	for _, reason := range event.Reasons {
+		for _, kind := range kinds {
+			h.metrics.IncReloadCause(reason, kind)
+		}
+	}
+	if len(h.events) == 0 {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.events[h.next] = event
+	h.next++
+	if h.next == len(h.events) {
+		h.next = 0
+		h.full = true
+	}
+}
+
+// list returns the stored reload events, the most recent first
+func (h *reloadHistory) list() []ingress.ReloadEvent {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	count := h.next
+	if h.full {
+		count = len(h.events)
+	}
+	events := make([]ingress.ReloadEvent, 0, count)
+	for i := 1; i <= count; i++ {
+		events = append(events, h.events[(h.next-i+len(h.events))%len(h.events)])
+	}
+	return events
+}
+
+// objectKinds returns the distinct kinds of a list of changed objects,
+// e.g. `ingress` from `update/ingress:default/echo`. `none` is returned
+// if the list is empty, which happens on the first sync and when the
+// reload was triggered by the controller itself.
+func objectKinds(objects []string) []string {
+	if len(objects) == 0 {
+		return []string{"none"}
+	}
+	kindMap := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		kind := obj
+		if i := strings.Index(kind, "/"); i >= 0 {
+			kind = kind[i+1:]
+		}
+		if i := strings.Index(kind, ":"); i >= 0 {
+			kind = kind[:i]
+		}
+		kindMap[kind] = true
+	}
+	kinds := make([]string, 0, len(kindMap))
+	for kind := range kindMap {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestReloadHistory(t *testing.T) {
+	type reload struct {
+		objects []string
+		status  *haproxy.ReloadStatus
+	}
+	testCases := []struct {
+		size      int
+		reloads   []reload
+		expIDs    []int
+		expCauses []string
+		logging   string
+	}{
+		// 0
+		{
+			size:   2,
+			expIDs: []int{},
+		},
+		// 1
+		{
+			size: 2,
+			reloads: []reload{
+				{objects: []string{"update/ingress:default/echo"}},
+			},
+			expIDs: []int{},
+		},
+		// 2
+		{
+			size: 2,
+			reloads: []reload{
+				{
+					objects: []string{"update/ingress:default/echo", "update/endpoint:default/echo"},
+					status:  &haproxy.ReloadStatus{Reasons: []string{"backends"}, Attributes: []string{"backend:default_echo_8080/Cookie"}, Success: true},
+				},
+			},
+			expIDs:    []int{1},
+			expCauses: []string{"backends/endpoint", "backends/ingress"},
+			logging: `
+INFO haproxy reload cause id=1: config changes [backends]; changed attributes [backend:default_echo_8080/Cookie]; changed objects [update/ingress:default/echo update/endpoint:default/echo]`,
+		},
+		// 3
+		{
+			size: 2,
+			reloads: []reload{
+				{status: &haproxy.ReloadStatus{Reasons: []string{"initial"}, Success: true}},
+				{objects: []string{"update/global"}, status: &haproxy.ReloadStatus{Reasons: []string{"global"}}},
+				{objects: []string{"update/endpoint:default/echo"}},
+				{objects: []string{"del/secret:default/tls"}, status: &haproxy.ReloadStatus{Reasons: []string{"hosts"}, Success: true}},
+			},
+			expIDs: []int{4, 2},
+			logging: `
+INFO haproxy reload cause id=1: config changes [initial]; changed attributes []; changed objects []
+INFO haproxy reload cause id=2: config changes [global]; changed attributes []; changed objects [update/global]
+INFO haproxy reload cause id=4: config changes [hosts]; changed attributes []; changed objects [del/secret:default/tls]`,
+		},
+		// 4
+		{
+			size: 0,
+			reloads: []reload{
+				{objects: []string{"update/global"}, status: &haproxy.ReloadStatus{Reasons: []string{"global"}}},
+			},
+			expIDs: []int{},
+			logging: `
+INFO haproxy reload cause id=1: config changes [global]; changed attributes []; changed objects [update/global]`,
+		},
+	}
+	for i, test := range testCases {
+		logger := types_helper.NewLoggerMock(t)
+		metrics := &reloadMetricsMock{}
+		h := newReloadHistory(logger, metrics, test.size)
+		for j, r := range test.reloads {
+			h.track(j+1, &convtypes.ChangedObjects{Objects: r.objects}, r.status)
+		}
+		ids := []int{}
+		for _, event := range h.list() {
+			ids = append(ids, event.ID)
+		}
+		if !reflect.DeepEqual(ids, test.expIDs) {
+			t.Errorf("ids differ on %d - expected: %v - actual: %v", i, test.expIDs, ids)
+		}
+		if test.expCauses != nil && !reflect.DeepEqual(metrics.causes, test.expCauses) {
+			t.Errorf("causes differ on %d - expected: %v - actual: %v", i, test.expCauses, metrics.causes)
+		}
+		logger.CompareLogging(test.logging)
+	}
+}
+
+func TestReloadHistoryTruncate(t *testing.T) {
+	var objects, attrs []string
+	for i := 0; i < maxReloadObjects; i++ {
+		objects = append(objects, fmt.Sprintf("update/endpoint:default/app%d", i))
+		attrs = append(attrs, fmt.Sprintf("backend:default_app%d_8080/Endpoints", i))
+	}
+	objects = append(objects, "update/secret:default/tls")
+	attrs = append(attrs, "host:d1.local/TLS")
+	metrics := &reloadMetricsMock{}
+	h := newReloadHistory(&types_helper.LoggerMock{}, metrics, 1)
+	h.track(1, &convtypes.ChangedObjects{Objects: objects}, &haproxy.ReloadStatus{Reasons: []string{"hosts"}, Attributes: attrs})
+	event := h.list()[0]
+	if len(event.Objects) != maxReloadObjects || len(event.Attributes) != maxReloadObjects {
+		t.Errorf("expected %d objects and attributes, found %d and %d", maxReloadObjects, len(event.Objects), len(event.Attributes))
+	}
+	expCauses := []string{"hosts/endpoint", "hosts/secret"}
+	if !reflect.DeepEqual(metrics.causes, expCauses) {
+		t.Errorf("causes differ - expected: %v - actual: %v", expCauses, metrics.causes)
+	}
+}
+
+type reloadMetricsMock struct {
+	types_helper.MetricsMock
+	causes []string
+}
+
+func (m *reloadMetricsMock) IncReloadCause(reason, object string) {
+	m.causes = append(m.causes, reason+"/"+object)
+}
+
+func TestObjectKinds(t *testing.T) {
+	testCases := []struct {
+		objects  []string
+		expected []string
+	}{
+		// 0
+		{
+			expected: []string{"none"},
+		},
+		// 1
+		{
+			objects:  []string{"update/global"},
+			expected: []string{"global"},
+		},
+		// 2
+		{
+			objects:  []string{"update/service:default/echo", "add/ingress:default/echo", "update/endpoint:default/echo", "del/ingress:default/app"},
+			expected: []string{"endpoint", "ingress", "service"},
+		},
+	}
+	for i, test := range testCases {
+		kinds := objectKinds(test.objects)
+		if !reflect.DeepEqual(kinds, test.expected) {
+			t.Errorf("kinds differ on %d - expected: %v - actual: %v", i, test.expected, kinds)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	gatewayv1alpha1 "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/typed/apis/v1alpha1"
+
+	haclient "github.com/jcmoraisjr/haproxy-ingress/pkg/api/client"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress/controller"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/tracker"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+// RenderCommand is the name of the subcommand that renders the haproxy
+// configuration from resources read from files, see Render()
+const RenderCommand = "render"
+
+// Render reads Kubernetes resources from YAML or JSON files, parses them
+// the same way a running controller would do, and prints the rendered
+// configuration files, the warnings found while parsing the resources and
+// the outcome of the haproxy validation. The API server isn't used. Render
+// returns the exit code of the subcommand.
+func Render(args []string) int {
+	flags := pflag.NewFlagSet(RenderCommand, pflag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: haproxy-ingress %s [options] <file-or-directory>...\n\nOptions:\n%s", RenderCommand, flags.FlagUsages())
+	}
+	var (
+		ingressClass = flags.String("ingress-class", "",
+			`Comma-separated list of ingress classes rendered by the controller`)
+		controllerClass = flags.String("controller-class", "",
+			`Alternative controller name this controller should listen to, see the controller's --controller-class`)
+		watchIngressWithoutClass = flags.Bool("watch-ingress-without-class", false,
+			`Defines if ingress resources without any class reference should also be rendered`)
+		configMap = flags.String("configmap", "",
+			`Name of the ConfigMap, in the form namespace/name, with the global configuration`)
+		defaultSvc = flags.String("default-backend-service", "",
+			`Service used as the default backend, in the form namespace/name`)
+		defSSLCertificate = flags.String("default-ssl-certificate", "",
+			`Secret used as the default certificate, in the form namespace/name. Default is to use a self-signed certificate`)
+		annPrefix = flags.String("annotations-prefix", "haproxy-ingress.github.io,ingress.kubernetes.io",
+			`Comma-separated list of the annotations prefix`)
+		allowCrossNamespace = flags.Bool("allow-cross-namespace", false,
+			`Defines if the ingress resources can reference secrets from another namespace`)
+		sortEndpointsBy = flags.String("sort-endpoints-by", "endpoint",
+			`Defines how to sort the endpoints of the backends: ep, endpoint, ip, name or random`)
+		outputDir = flags.String("output-dir", "",
+			`Directory where the rendered files should be written. Default is to print them in the standard output`)
+		validate = flags.Bool("validate", true,
+			`Defines if the rendered configuration should be validated by the haproxy binary found in the PATH`)
+		failOnWarnings = flags.Bool("fail-on-warnings", false,
+			`Defines if warnings found while parsing the resources should fail the render`)
+	)
+	if err := flags.Parse(args); err != nil {
+		if err == pflag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	// glog writes to the standard error if its flags weren't parsed
+	_ = flag.CommandLine.Parse(nil)
+	objects, messages, err := readRenderInput(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading resources: %v\n", err)
+		return 2
+	}
+	ingressClasses := utils.Split(*ingressClass, ",")
+	var className string
+	if len(ingressClasses) > 0 {
+		className = ingressClasses[0]
+	}
+	controllerName := "haproxy-ingress.github.io/controller"
+	if *controllerClass != "" {
+		controllerName += "/" + strings.TrimLeft(*controllerClass, "/")
+	}
+	cfg := &controller.Configuration{
+		Client:                   &renderClient{Clientset: k8sfake.NewSimpleClientset(objects...)},
+		IngressClass:             className,
+		IngressClasses:           ingressClasses,
+		ControllerName:           controllerName,
+		WatchIngressWithoutClass: *watchIngressWithoutClass,
+		ConfigMapName:            *configMap,
+		DefaultService:           *defaultSvc,
+		DefaultSSLCertificate:    *defSSLCertificate,
+		AnnPrefix:                utils.Split(*annPrefix, ","),
+		AllowCrossNamespace:      *allowCrossNamespace,
+		DisablePodList:           true,
+		EnableEndpointSlicesAPI:  hasEndpointSlices(objects),
+		SortEndpointsBy:          strings.ToLower(*sortEndpointsBy),
+	}
+	result, err := renderConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error rendering the configuration: %v\n", err)
+		return 2
+	}
+	result.Messages = append(messages, result.Messages...)
+	if !*validate {
+		// the validation error, if any, is ignored
+		result.Valid = true
+		result.Error = ""
+	}
+	if *outputDir != "" {
+		if err := writeRenderFiles(*outputDir, result.Files); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing the configuration files: %v\n", err)
+			return 2
+		}
+		result.Files = nil
+	}
+	fmt.Print(result.String())
+	if !result.Valid || (*failOnWarnings && len(result.Messages) > 0) {
+		return 1
+	}
+	return 0
+}
+
+// renderConfig configures just enough of a controller to run a dry run
+// against the resources stored in the client of the configuration.
+func renderConfig(cfg *controller.Configuration) (*ingress.DryRunResult, error) {
+	// certificates and other generated files are written in a temporary
+	// directory, so the render doesn't need the directories of the image
+	dir, err := ioutil.TempDir("", "haproxy-render-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	for _, d := range []*string{
+		&ingress.DefaultCrtDirectory,
+		&ingress.DefaultDHParamDirectory,
+		&ingress.DefaultCACertsDirectory,
+		&ingress.DefaultCrlDirectory,
+		&ingress.DefaultMapsDirectory,
+	} {
+		*d = filepath.Join(dir, filepath.Base(*d))
+		if err := os.Mkdir(*d, 0755); err != nil {
+			return nil, err
+		}
+	}
+	mode := haproxy.ProcessEmbedded
+	hc := &HAProxyController{
+		cfg:         cfg,
+		logger:      newLogger(logFormatText),
+		tracker:     tracker.NewTracker(),
+		stopCh:      make(chan struct{}),
+		haproxyMode: &mode,
+	}
+	defer close(hc.stopCh)
+	hc.controller = controller.NewOfflineController(cfg)
+	hc.controller.SetNewCtrl(hc)
+	hc.dynamicConfig = &convtypes.DynamicConfig{
+		StaticCrossNamespaceSecrets: cfg.AllowCrossNamespace,
+	}
+	// the queue is never started, the resources don't change
+	hc.ingressQueue = utils.NewQueue(func(item interface{}) {})
+	hc.cache = createCache(hc.logger, hc.controller, hc.tracker, hc.dynamicConfig, hc.ingressQueue)
+	hc.converterOptions = &convtypes.ConverterOptions{
+		Logger:           hc.logger,
+		Cache:            hc.cache,
+		Tracker:          hc.tracker,
+		DynamicConfig:    hc.dynamicConfig,
+		AnnotationPrefix: cfg.AnnPrefix,
+		DefaultBackend:   cfg.DefaultService,
+		DefaultCrtSecret: cfg.DefaultSSLCertificate,
+		FakeCrtFile:      hc.createFakeCrtFile(),
+		FakeCAFile:       hc.createFakeCAFile(),
+		AvailableCPUs:    utils.AvailableCPUs(),
+		SnippetChecker:   haproxy.NewSnippetChecker(),
+	}
+	hc.cache.RunAsync(hc.stopCh)
+	return hc.DryRun()
+}
+
+// renderClient is the client of the offline render. Only the core resources
+// are supported, Gateway API and custom resources are not.
+type renderClient struct {
+	*k8sfake.Clientset
+}
+
+func (c *renderClient) NetworkingV1alpha1() gatewayv1alpha1.NetworkingV1alpha1Interface {
+	return nil
+}
+
+func (c *renderClient) HAProxyIngressV1alpha1() haclient.HAProxyIngressV1alpha1Interface {
+	return nil
+}
+
+func (c *renderClient) MulticlusterV1alpha1() haclient.MulticlusterV1alpha1Interface {
+	return nil
+}
+
+// readRenderInput reads the resources declared in the files, and in the
+// files of the directories, of the input list. Lists are expanded, and
+// resources of unsupported kinds are reported and ignored.
+func readRenderInput(input []string) (objects []runtime.Object, messages []string, err error) {
+	var files []string
+	for _, path := range input {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			switch strings.ToLower(filepath.Ext(file)) {
+			case ".yaml", ".yml", ".json":
+				if !info.IsDir() {
+					files = append(files, file)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		fileObjects, fileMessages, err := decodeRenderInput(content)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading '%s': %w", file, err)
+		}
+		objects = append(objects, fileObjects...)
+		for _, msg := range fileMessages {
+			messages = append(messages, fmt.Sprintf("%s in '%s'", msg, file))
+		}
+	}
+	return objects, messages, nil
+}
+
+func decodeRenderInput(content []byte) (objects []runtime.Object, messages []string, err error) {
+	reader := yamlutil.NewYAMLReader(bufio.NewReader(bytes.NewReader(content)))
+	decoder := scheme.Codecs.UniversalDeserializer()
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		data, err := yamlutil.ToJSON(doc)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(bytes.TrimSpace(data)) == 0 || string(data) == "null" {
+			// empty document, e.g. only comments
+			continue
+		}
+		obj, gvk, err := decoder.Decode(data, nil, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if list, ok := obj.(*api.List); ok {
+			for _, item := range list.Items {
+				itemObjects, itemMessages, err := decodeRenderInput(item.Raw)
+				if err != nil {
+					return nil, nil, err
+				}
+				objects = append(objects, itemObjects...)
+				messages = append(messages, itemMessages...)
+			}
+			continue
+		}
+		namespaced := true
+		switch res := obj.(type) {
+		case *networking.IngressClass, *api.Namespace:
+			namespaced = false
+		case *networking.Ingress, *api.Service, *api.Endpoints, *discoveryv1.EndpointSlice, *api.ConfigMap:
+		case *api.Secret:
+			// stringData is merged by the API server, the fake client doesn't do that
+			if len(res.StringData) > 0 && res.Data == nil {
+				res.Data = map[string][]byte{}
+			}
+			for key, value := range res.StringData {
+				res.Data[key] = []byte(value)
+			}
+			res.StringData = nil
+		default:
+			messages = append(messages, fmt.Sprintf("ignoring unsupported resource %s", gvk.Kind))
+			continue
+		}
+		if objMeta, err := meta.Accessor(obj); err == nil && namespaced && objMeta.GetNamespace() == "" {
+			objMeta.SetNamespace(api.NamespaceDefault)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, messages, nil
+}
+
+func hasEndpointSlices(objects []runtime.Object) bool {
+	for _, obj := range objects {
+		if _, ok := obj.(*discoveryv1.EndpointSlice); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func writeRenderFiles(dir string, files map[string]string) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+func TestDecodeRenderInput(t *testing.T) {
+	testCases := []struct {
+		input       string
+		expObjects  []string
+		expMessages []string
+		expData     map[string]string
+		expErr      bool
+	}{
+		// 0
+		{
+			input: `
+# only comments
+---
+`,
+		},
+		// 1
+		{
+			input: `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: app
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  namespace: ns1
+`,
+			expObjects: []string{"Ingress default/app", "Service ns1/app"},
+		},
+		// 2
+		{
+			input: `
+apiVersion: networking.k8s.io/v1
+kind: IngressClass
+metadata:
+  name: haproxy
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: ns1
+`,
+			expObjects: []string{"IngressClass /haproxy", "Namespace /ns1"},
+		},
+		// 3
+		{
+			input: `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: config
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: app
+`,
+			expObjects:  []string{"ConfigMap default/config"},
+			expMessages: []string{"ignoring unsupported resource Deployment"},
+		},
+		// 4
+		{
+			input: `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: users
+data:
+  auth: YWRtaW4=
+stringData:
+  realm: local
+`,
+			expObjects: []string{"Secret default/users"},
+			expData:    map[string]string{"auth": "admin", "realm": "local"},
+		},
+		// 5
+		{
+			input: `
+kind: Ingress
+metadata:
+  name: app
+`,
+			expErr: true,
+		},
+	}
+	for i, test := range testCases {
+		objects, messages, err := decodeRenderInput([]byte(test.input))
+		if (err != nil) != test.expErr {
+			t.Errorf("%d: expected error %t but was: %v", i, test.expErr, err)
+			continue
+		}
+		var names []string
+		for _, obj := range objects {
+			objMeta, _ := meta.Accessor(obj)
+			kind := reflect.TypeOf(obj).Elem().Name()
+			names = append(names, fmt.Sprintf("%s %s/%s", kind, objMeta.GetNamespace(), objMeta.GetName()))
+			if secret, ok := obj.(*api.Secret); ok {
+				data := map[string]string{}
+				for key, value := range secret.Data {
+					data[key] = string(value)
+				}
+				if !reflect.DeepEqual(data, test.expData) {
+					t.Errorf("%d: expected secret data %v but was %v", i, test.expData, data)
+				}
+			}
+		}
+		if !reflect.DeepEqual(names, test.expObjects) {
+			t.Errorf("%d: expected objects %v but was %v", i, test.expObjects, names)
+		}
+		if !reflect.DeepEqual(messages, test.expMessages) {
+			t.Errorf("%d: expected messages %v but was %v", i, test.expMessages, messages)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"hash/fnv"
+
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// namespaceShard filters the namespaces whose resources are managed by a
+// controller deployment, see --shard-count, --shard-index and
+// --shard-namespace-selector. Both filters must match if both are configured.
+type namespaceShard struct {
+	count    int
+	index    int
+	selector labels.Selector
+}
+
+func newNamespaceShard(count, index int, selector labels.Selector) *namespaceShard {
+	if count <= 1 && selector == nil {
+		return nil
+	}
+	return &namespaceShard{
+		count:    count,
+		index:    index,
+		selector: selector,
+	}
+}
+
+// contains is true if the namespace belongs to this shard. ns is the
+// Namespace resource and is only used if a selector is configured, a
+// missing Namespace doesn't match the selector.
+func (s *namespaceShard) contains(namespace string, ns *api.Namespace) bool {
+	if s == nil {
+		return true
+	}
+	if s.count > 1 && shardOf(namespace, s.count) != s.index {
+		return false
+	}
+	if s.selector != nil {
+		return ns != nil && s.selector.Matches(labels.Set(ns.Labels))
+	}
+	return true
+}
+
+// shardOf returns the shard of a namespace, in the range [0, count). All the
+// controller deployments must agree on the algorithm, so it cannot change.
+func shardOf(namespace string, count int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(namespace))
+	return int(h.Sum32() % uint32(count))
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestNamespaceShard(t *testing.T) {
+	selector, _ := labels.Parse("shard=internal")
+	ns := func(labels map[string]string) *api.Namespace {
+		return &api.Namespace{ObjectMeta: metav1.ObjectMeta{Labels: labels}}
+	}
+	testCases := []struct {
+		count     int
+		index     int
+		selector  labels.Selector
+		namespace string
+		ns        *api.Namespace
+		expected  bool
+	}{
+		// 0
+		{
+			namespace: "default",
+			expected:  true,
+		},
+		// 1
+		{
+			count:     1,
+			namespace: "default",
+			expected:  true,
+		},
+		// 2
+		{
+			count:     4,
+			index:     shardOf("default", 4),
+			namespace: "default",
+			expected:  true,
+		},
+		// 3
+		{
+			count:     4,
+			index:     (shardOf("default", 4) + 1) % 4,
+			namespace: "default",
+			expected:  false,
+		},
+		// 4
+		{
+			selector:  selector,
+			namespace: "default",
+			expected:  false,
+		},
+		// 5
+		{
+			selector:  selector,
+			namespace: "default",
+			ns:        ns(map[string]string{"shard": "external"}),
+			expected:  false,
+		},
+		// 6
+		{
+			selector:  selector,
+			namespace: "default",
+			ns:        ns(map[string]string{"shard": "internal"}),
+			expected:  true,
+		},
+		// 7
+		{
+			count:     4,
+			index:     (shardOf("default", 4) + 1) % 4,
+			selector:  selector,
+			namespace: "default",
+			ns:        ns(map[string]string{"shard": "internal"}),
+			expected:  false,
+		},
+	}
+	for i, test := range testCases {
+		shard := newNamespaceShard(test.count, test.index, test.selector)
+		if test.count <= 1 && test.selector == nil && shard != nil {
+			t.Errorf("%d: expected sharding disabled", i)
+		}
+		if actual := shard.contains(test.namespace, test.ns); actual != test.expected {
+			t.Errorf("%d: expected %t but was %t", i, test.expected, actual)
+		}
+	}
+}
+
+func TestShardOf(t *testing.T) {
+	// every controller deployment, of any version, must agree on the shard of a namespace
+	testCases := []struct {
+		namespace string
+		count     int
+		expected  int
+	}{
+		{namespace: "", count: 3, expected: 1},
+		{namespace: "default", count: 3, expected: 0},
+		{namespace: "kube-system", count: 3, expected: 2},
+	}
+	for i, test := range testCases {
+		if actual := shardOf(test.namespace, test.count); actual != test.expected {
+			t.Errorf("%d: expected %d but was %d", i, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/version"
+)
+
+const tracerName = "github.com/jcmoraisjr/haproxy-ingress"
+
+// tracing creates the OpenTelemetry spans of the haproxy updates. Every
+// update is a root span, and every step measured by the update timer,
+// e.g. the converters, the template rendering and the haproxy reload,
+// is a child span of the update.
+type tracing struct {
+	tracer   trace.Tracer
+	shutdown func(ctx context.Context) error
+}
+
+// createTracing configures an OTLP/HTTP exporter that sends the spans to
+// endpoint, a host:port pair. An empty endpoint disables tracing and
+// configures a tracer that does not record anything.
+func createTracing(endpoint string, insecure bool, samplingRatio float64) (*tracing, error) {
+	if endpoint == "" {
+		return newTracing(trace.NewNoopTracerProvider(), nil), nil
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	attrs := []attribute.KeyValue{
+		semconv.ServiceNameKey.String("haproxy-ingress"),
+		semconv.ServiceVersionKey.String(version.RELEASE),
+	}
+	if podName := os.Getenv("POD_NAME"); podName != "" {
+		attrs = append(attrs, semconv.K8SPodNameKey.String(podName))
+	}
+	if podNamespace := os.Getenv("POD_NAMESPACE"); podNamespace != "" {
+		attrs = append(attrs, semconv.K8SNamespaceNameKey.String(podNamespace))
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attrs...)),
+		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(samplingRatio)),
+	)
+	return newTracing(provider, provider.Shutdown), nil
+}
+
+func newTracing(provider trace.TracerProvider, shutdown func(ctx context.Context) error) *tracing {
+	return &tracing{
+		tracer:   provider.Tracer(tracerName, trace.WithInstrumentationVersion(version.RELEASE)),
+		shutdown: shutdown,
+	}
+}
+
+// Shutdown flushes the pending spans and stops the exporter
+func (t *tracing) Shutdown() error {
+	if t.shutdown == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return t.shutdown(ctx)
+}
+
+// startUpdate starts the root span of the haproxy update `id`
+func (t *tracing) startUpdate(id int) *updateSpan {
+	ctx, span := t.tracer.Start(context.Background(), "syncIngress",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.Int("haproxy.update.id", id)),
+	)
+	return &updateSpan{
+		tracer: t.tracer,
+		ctx:    ctx,
+		span:   span,
+	}
+}
+
+type updateSpan struct {
+	tracer trace.Tracer
+	ctx    context.Context
+	span   trace.Span
+}
+
+// observer wraps a timer observer, adding a child span for every tick of
+// the timer. The span starts on the previous tick and ends on this one.
+func (s *updateSpan) observer(next func(task string, duration time.Duration)) func(task string, duration time.Duration) {
+	return func(task string, duration time.Duration) {
+		now := time.Now()
+		_, span := s.tracer.Start(s.ctx, task, trace.WithTimestamp(now.Add(-duration)))
+		span.End(trace.WithTimestamp(now))
+		if next != nil {
+			next(task, duration)
+		}
+	}
+}
+
+// end adds the outcome of the update and ends the root span
+func (s *updateSpan) end(changed *convtypes.ChangedObjects, reload *haproxy.ReloadStatus) {
+	if changed != nil {
+		s.span.SetAttributes(
+			attribute.Bool("haproxy.update.full_sync", changed.NeedFullSync),
+			attribute.Int("haproxy.update.changed_objects", len(changed.Objects)),
+		)
+	}
+	s.span.SetAttributes(attribute.Bool("haproxy.reload", reload != nil))
+	if reload != nil {
+		s.span.SetAttributes(attribute.StringSlice("haproxy.reload.reasons", reload.Reasons))
+		if !reload.Success {
+			s.span.SetStatus(codes.Error, "haproxy reload failed")
+		}
+	}
+	s.span.End()
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+func TestTracing(t *testing.T) {
+	testCases := []struct {
+		ticks     []string
+		changed   *convtypes.ChangedObjects
+		reload    *haproxy.ReloadStatus
+		expSpans  []string
+		expReload bool
+		expStatus codes.Code
+	}{
+		// 0
+		{
+			ticks:    []string{"parse_ingress", "write_maps"},
+			changed:  &convtypes.ChangedObjects{Objects: []string{"update/ingress:default/echo"}},
+			expSpans: []string{"parse_ingress", "write_maps", "syncIngress"},
+		},
+		// 1
+		{
+			ticks:     []string{"parse_ingress", "write_config", "reload_haproxy"},
+			changed:   &convtypes.ChangedObjects{NeedFullSync: true},
+			reload:    &haproxy.ReloadStatus{Reasons: []string{"global"}, Success: true},
+			expSpans:  []string{"parse_ingress", "write_config", "reload_haproxy", "syncIngress"},
+			expReload: true,
+		},
+		// 2
+		{
+			ticks:     []string{"reload_haproxy"},
+			reload:    &haproxy.ReloadStatus{Reasons: []string{"hosts"}},
+			expSpans:  []string{"reload_haproxy", "syncIngress"},
+			expReload: true,
+			expStatus: codes.Error,
+		},
+	}
+	for i, test := range testCases {
+		recorder := tracetest.NewSpanRecorder()
+		tracing := newTracing(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), nil)
+		var observed []string
+		span := tracing.startUpdate(i + 1)
+		timer := utils.NewTimer(span.observer(func(task string, duration time.Duration) {
+			observed = append(observed, task)
+		}))
+		for _, tick := range test.ticks {
+			timer.Tick(tick)
+		}
+		span.end(test.changed, test.reload)
+
+		var spans []string
+		var root sdktrace.ReadOnlySpan
+		for _, s := range recorder.Ended() {
+			spans = append(spans, s.Name())
+			if s.Name() == "syncIngress" {
+				root = s
+			} else if s.Parent().SpanID() != span.span.SpanContext().SpanID() {
+				t.Errorf("%d: span %s is not a child of the update span", i, s.Name())
+			}
+		}
+		if !reflect.DeepEqual(spans, test.expSpans) {
+			t.Errorf("%d: expected spans %v, but was %v", i, test.expSpans, spans)
+		}
+		if !reflect.DeepEqual(observed, test.ticks) {
+			t.Errorf("%d: expected observed ticks %v, but was %v", i, test.ticks, observed)
+		}
+		var reload bool
+		for _, attr := range root.Attributes() {
+			if attr.Key == "haproxy.reload" {
+				reload = attr.Value.AsBool()
+			}
+		}
+		if reload != test.expReload {
+			t.Errorf("%d: expected reload attribute %v, but was %v", i, test.expReload, reload)
+		}
+		if status := root.Status().Code; status != test.expStatus {
+			t.Errorf("%d: expected status %v, but was %v", i, test.expStatus, status)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	admission "k8s.io/api/admission/v1"
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mcsv1alpha1 "github.com/jcmoraisjr/haproxy-ingress/pkg/api/mcs/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/tracker"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+// admissionWebhook is a validating admission webhook of ingress resources.
+// Ingress resources are parsed the same way the controller does, and the
+// ones whose configuration would be ignored by the controller are denied.
+type admissionWebhook struct {
+	logger   types.Logger
+	certFile string
+	keyFile  string
+	validate func(ing *networking.Ingress) (denied, warnings []string)
+	mutex    sync.Mutex
+	handlers *http.ServeMux
+}
+
+func newAdmissionWebhook(logger types.Logger, certFile, keyFile string, validate func(ing *networking.Ingress) (denied, warnings []string)) *admissionWebhook {
+	webhook := &admissionWebhook{
+		logger:   logger,
+		certFile: certFile,
+		keyFile:  keyFile,
+		validate: validate,
+		handlers: http.NewServeMux(),
+	}
+	webhook.handlers.HandleFunc("/validate/ingress", webhook.handleIngress)
+	return webhook
+}
+
+func (w *admissionWebhook) Listen(port int, stopCh chan struct{}) {
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: w.handlers,
+	}
+	go func() {
+		<-stopCh
+		_ = server.Close()
+	}()
+	go func() {
+		if err := server.ListenAndServeTLS(w.certFile, w.keyFile); err != nil && err != http.ErrServerClosed {
+			w.logger.Error("error listening admission webhook on port %d: %v", port, err)
+		}
+	}()
+	w.logger.Info("admission webhook listening on port %d", port)
+}
+
+func (w *admissionWebhook) handleIngress(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	review := &admission.AdmissionReview{}
+	if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+		http.Error(rw, fmt.Sprintf("error decoding admission review: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(rw, "missing admission request", http.StatusBadRequest)
+		return
+	}
+	review.Response = w.reviewIngress(review.Request)
+	review.Request = nil
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(review); err != nil {
+		w.logger.Warn("admission webhook: error encoding response: %v", err)
+	}
+}
+
+func (w *admissionWebhook) reviewIngress(req *admission.AdmissionRequest) *admission.AdmissionResponse {
+	resp := &admission.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: true,
+	}
+	if req.Kind.Group != networking.GroupName || req.Kind.Kind != "Ingress" || req.Operation == admission.Delete {
+		return resp
+	}
+	ing := &networking.Ingress{}
+	if err := json.Unmarshal(req.Object.Raw, ing); err != nil {
+		resp.Allowed = false
+		resp.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusBadRequest,
+			Reason:  metav1.StatusReasonBadRequest,
+			Message: fmt.Sprintf("error decoding ingress: %v", err),
+		}
+		return resp
+	}
+	if ing.Namespace == "" {
+		ing.Namespace = req.Namespace
+	}
+	if ing.Name == "" {
+		ing.Name = req.Name
+	}
+	// parsing is cpu bound, a burst of requests shouldn't compete with the controller
+	denied, warnings := w.validateLocked(ing)
+	resp.Warnings = warnings
+	if len(denied) > 0 {
+		w.logger.InfoV(2, "admission webhook: denying ingress '%s/%s': %s", ing.Namespace, ing.Name, strings.Join(denied, "; "))
+		resp.Allowed = false
+		resp.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusUnprocessableEntity,
+			Reason:  metav1.StatusReasonInvalid,
+			Message: "invalid haproxy-ingress configuration: " + strings.Join(denied, "; "),
+		}
+	}
+	return resp
+}
+
+func (w *admissionWebhook) validateLocked(ing *networking.Ingress) (denied, warnings []string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.validate(ing)
+}
+
+// validateIngress parses an ingress resource in a scratch configuration,
+// without interfering in the configuration and the state of the running
+// controller. Warnings and errors logged about the ingress deny it, except
+// the ones caused by missing or invalid resources it references: they can
+// be created or fixed later, so they are reported as warnings instead.
+func (hc *HAProxyController) validateIngress(ing *networking.Ingress) (denied, warnings []string) {
+	if !hc.cache.IsValidIngress(ing) {
+		return nil, nil
+	}
+	logger := &validationLogger{source: fmt.Sprintf("ingress '%s/%s'", ing.Namespace, ing.Name)}
+	dynamicConfig := *hc.dynamicConfig
+	cache := newValidationCache(hc.cache, logger, &dynamicConfig, ing)
+	options := *hc.converterOptions
+	options.Logger = logger
+	options.Cache = cache
+	options.Tracker = cache.tracker
+	options.DynamicConfig = &dynamicConfig
+	options.HasGateway = false
+	options.HasCRDs = false
+	haproxyConfig := haproxy.CreateInstance(logger, haproxy.InstanceOptions{}).Config()
+	converters.NewConverter(utils.NewTimer(nil), haproxyConfig, &options).Sync()
+	for _, msg := range logger.messages {
+		if cache.isLookupError(msg) {
+			warnings = append(warnings, msg)
+		} else {
+			denied = append(denied, msg)
+		}
+	}
+	return denied, warnings
+}
+
+// validationLogger collects the warnings and errors that reference the
+// resource being validated. An empty source collects all the messages.
+type validationLogger struct {
+	source   string
+	messages []string
+}
+
+func (l *validationLogger) add(msg string, args []interface{}) {
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	if strings.Contains(msg, l.source) {
+		l.messages = append(l.messages, msg)
+	}
+}
+
+func (l *validationLogger) InfoV(v int, msg string, args ...interface{}) {}
+
+func (l *validationLogger) Info(msg string, args ...interface{}) {}
+
+func (l *validationLogger) Warn(msg string, args ...interface{}) {
+	l.add(msg, args)
+}
+
+func (l *validationLogger) Error(msg string, args ...interface{}) {
+	l.add(msg, args)
+}
+
+func (l *validationLogger) Fatal(msg string, args ...interface{}) {
+	l.add(msg, args)
+}
+
+// validationCache reads the same listers of the controller, but has its own
+// tracker and state, doesn't change any Kubernetes object, and exposes only
+// the ingress being validated, or all of them if ingress is nil. Errors
+// reading the referenced resources are stored, so the messages they cause
+// can be distinguished from configuration errors.
+type validationCache struct {
+	*k8scache
+	ingress      *networking.Ingress
+	globalConfig map[string]string
+	tcpConfig    map[string]string
+	lookupErrors []string
+}
+
+func newValidationCache(c *k8scache, logger types.Logger, dynamicConfig *convtypes.DynamicConfig, ing *networking.Ingress) *validationCache {
+	globalConfig, tcpConfig := c.configMapData()
+	return &validationCache{
+		k8scache: &k8scache{
+			ctx:                    c.ctx,
+			client:                 c.client,
+			logger:                 logger,
+			listers:                c.listers,
+			controller:             c.controller,
+			cfg:                    c.cfg,
+			tracker:                tracker.NewTracker(),
+			dynamicConfig:          dynamicConfig,
+			podName:                c.podName,
+			podNamespace:           c.podNamespace,
+			globalConfigMapKey:     c.globalConfigMapKey,
+			tcpConfigMapKey:        c.tcpConfigMapKey,
+			acmeSecretKeyName:      c.acmeSecretKeyName,
+			acmeTokenConfigmapName: c.acmeTokenConfigmapName,
+			jwks:                   c.jwks,
+		},
+		ingress:      ing,
+		globalConfig: globalConfig,
+		tcpConfig:    tcpConfig,
+	}
+}
+
+func (c *validationCache) lookupError(err error) error {
+	if err != nil {
+		c.lookupErrors = append(c.lookupErrors, err.Error())
+	}
+	return err
+}
+
+func (c *validationCache) isLookupError(msg string) bool {
+	for _, err := range c.lookupErrors {
+		if strings.Contains(msg, err) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *validationCache) GetIngress(ingressName string) (*networking.Ingress, error) {
+	if c.ingress != nil && ingressName == c.ingress.Namespace+"/"+c.ingress.Name {
+		return c.ingress, nil
+	}
+	return c.k8scache.GetIngress(ingressName)
+}
+
+func (c *validationCache) GetIngressList() ([]*networking.Ingress, error) {
+	if c.ingress == nil {
+		return c.k8scache.GetIngressList()
+	}
+	return []*networking.Ingress{c.ingress}, nil
+}
+
+func (c *validationCache) GetService(defaultNamespace, serviceName string) (*api.Service, error) {
+	svc, err := c.k8scache.GetService(defaultNamespace, serviceName)
+	return svc, c.lookupError(err)
+}
+
+func (c *validationCache) GetServiceImport(defaultNamespace, importName string) (*mcsv1alpha1.ServiceImport, error) {
+	svcImport, err := c.k8scache.GetServiceImport(defaultNamespace, importName)
+	return svcImport, c.lookupError(err)
+}
+
+func (c *validationCache) GetConfigMapData(defaultNamespace, configMapName string, track convtypes.TrackingTarget) (map[string]string, error) {
+	data, err := c.k8scache.GetConfigMapData(defaultNamespace, configMapName, track)
+	return data, c.lookupError(err)
+}
+
+func (c *validationCache) GetTLSSecretPath(defaultNamespace, secretName string, track convtypes.TrackingTarget) (convtypes.CrtFile, error) {
+	file, err := c.k8scache.GetTLSSecretPath(defaultNamespace, secretName, track)
+	return file, c.lookupError(err)
+}
+
+func (c *validationCache) GetCASecretPath(defaultNamespace, secretName string, track convtypes.TrackingTarget) (ca, crl convtypes.File, err error) {
+	ca, crl, err = c.k8scache.GetCASecretPath(defaultNamespace, secretName, track)
+	return ca, crl, c.lookupError(err)
+}
+
+func (c *validationCache) GetDHSecretPath(defaultNamespace, secretName string) (convtypes.File, error) {
+	file, err := c.k8scache.GetDHSecretPath(defaultNamespace, secretName)
+	return file, c.lookupError(err)
+}
+
+func (c *validationCache) GetPublicKeySecretPath(defaultNamespace, secretName string, track convtypes.TrackingTarget) (convtypes.File, error) {
+	file, err := c.k8scache.GetPublicKeySecretPath(defaultNamespace, secretName, track)
+	return file, c.lookupError(err)
+}
+
+func (c *validationCache) GetJWKSKeys(url string) ([]convtypes.JWKSKey, error) {
+	keys, err := c.k8scache.GetJWKSKeys(url)
+	return keys, c.lookupError(err)
+}
+
+func (c *validationCache) GetPasswdSecretContent(defaultNamespace, secretName string, track convtypes.TrackingTarget) ([]byte, error) {
+	content, err := c.k8scache.GetPasswdSecretContent(defaultNamespace, secretName, track)
+	return content, c.lookupError(err)
+}
+
+func (c *validationCache) GetSecretContent(defaultNamespace, secretName, keyName string, track convtypes.TrackingTarget) ([]byte, error) {
+	content, err := c.k8scache.GetSecretContent(defaultNamespace, secretName, keyName, track)
+	return content, c.lookupError(err)
+}
+
+func (c *validationCache) UpdateGlobalStatus(global *v1alpha1.Global, condition metav1.Condition) error {
+	return nil
+}
+
+func (c *validationCache) UpdateHostStatus(host *v1alpha1.Host, condition metav1.Condition) error {
+	return nil
+}
+
+func (c *validationCache) UpdateBackendStatus(backend *v1alpha1.Backend, condition metav1.Condition) error {
+	return nil
+}
+
+func (c *validationCache) UpdateMiddlewareStatus(middleware *v1alpha1.Middleware, condition metav1.Condition) error {
+	return nil
+}
+
+func (c *validationCache) UpdateAuthConfigStatus(authConfig *v1alpha1.AuthConfig, condition metav1.Condition) error {
+	return nil
+}
+
+func (c *validationCache) UpdateIPListStatus(ipList *v1alpha1.IPList, condition metav1.Condition) error {
+	return nil
+}
+
+func (c *validationCache) UpdateRateLimitStatus(rateLimit *v1alpha1.RateLimit, condition metav1.Condition) error {
+	return nil
+}
+
+func (c *validationCache) UpdateTCPServiceStatus(tcpService *v1alpha1.TCPService, condition metav1.Condition) error {
+	return nil
+}
+
+func (c *validationCache) UpdateUDPServiceStatus(udpService *v1alpha1.UDPService, condition metav1.Condition) error {
+	return nil
+}
+
+// RecordEvent does nothing, the resource being validated might not be
+// persisted yet, and the same messages are logged and collected.
+func (c *validationCache) RecordEvent(kind, namespace, name, eventtype, reason, message string) {
+}
+
+func (c *validationCache) SwapChangedObjects() *convtypes.ChangedObjects {
+	return &convtypes.ChangedObjects{
+		GlobalConfigMapDataCur: c.globalConfig,
+		TCPConfigMapDataCur:    c.tcpConfig,
+		NeedFullSync:           true,
+	}
+}
@@ -0,0 +1,324 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	admission "k8s.io/api/admission/v1"
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	listerscore "k8s.io/client-go/listers/core/v1"
+	listersnetworking "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress/controller"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestAdmissionWebhook(t *testing.T) {
+	const review = `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"1234","kind":{"group":"%s","version":"v1","kind":"%s"},"operation":"%s","namespace":"default","name":"ing1","object":%s}}`
+	const ingress = `{"metadata":{"name":"ing1","annotations":{"haproxy-ingress.github.io/balance-algorithm":"invalid"}}}`
+	testCases := []struct {
+		method      string
+		body        string
+		denied      []string
+		warnings    []string
+		expCode     int
+		expValidate string
+		expAllowed  bool
+		expMessage  string
+		expWarnings []string
+		expBody     string
+	}{
+		// 0
+		{
+			method:  http.MethodGet,
+			expCode: http.StatusMethodNotAllowed,
+			expBody: "method not allowed",
+		},
+		// 1
+		{
+			method:  http.MethodPost,
+			body:    `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview"}`,
+			expCode: http.StatusBadRequest,
+			expBody: "missing admission request",
+		},
+		// 2
+		{
+			method:      http.MethodPost,
+			body:        fmt.Sprintf(review, "networking.k8s.io", "Ingress", "CREATE", ingress),
+			expCode:     http.StatusOK,
+			expValidate: "default/ing1",
+			expAllowed:  true,
+		},
+		// 3
+		{
+			method:      http.MethodPost,
+			body:        fmt.Sprintf(review, "networking.k8s.io", "Ingress", "UPDATE", ingress),
+			denied:      []string{"ignoring invalid balance algorithm on ingress 'default/ing1': invalid"},
+			expCode:     http.StatusOK,
+			expValidate: "default/ing1",
+			expMessage:  "invalid haproxy-ingress configuration: ignoring invalid balance algorithm on ingress 'default/ing1': invalid",
+		},
+		// 4
+		{
+			method:      http.MethodPost,
+			body:        fmt.Sprintf(review, "networking.k8s.io", "Ingress", "CREATE", ingress),
+			warnings:    []string{"skipping backend config of ingress 'default/ing1': service not found: 'default/app'"},
+			expCode:     http.StatusOK,
+			expValidate: "default/ing1",
+			expAllowed:  true,
+			expWarnings: []string{"skipping backend config of ingress 'default/ing1': service not found: 'default/app'"},
+		},
+		// 5
+		{
+			method:     http.MethodPost,
+			body:       fmt.Sprintf(review, "networking.k8s.io", "Ingress", "DELETE", `{}`),
+			denied:     []string{"should not be called"},
+			expCode:    http.StatusOK,
+			expAllowed: true,
+		},
+		// 6
+		{
+			method:     http.MethodPost,
+			body:       fmt.Sprintf(review, "", "Service", "CREATE", `{}`),
+			denied:     []string{"should not be called"},
+			expCode:    http.StatusOK,
+			expAllowed: true,
+		},
+		// 7
+		{
+			method:     http.MethodPost,
+			body:       fmt.Sprintf(review, "networking.k8s.io", "Ingress", "CREATE", `"invalid"`),
+			expCode:    http.StatusOK,
+			expMessage: "error decoding ingress: json: cannot unmarshal string into Go value of type v1.Ingress",
+		},
+	}
+	for i, test := range testCases {
+		logger := types_helper.NewLoggerMock(t)
+		var validated string
+		webhook := newAdmissionWebhook(logger, "", "", func(ing *networking.Ingress) (denied, warnings []string) {
+			validated = ing.Namespace + "/" + ing.Name
+			return test.denied, test.warnings
+		})
+		req := httptest.NewRequest(test.method, "/validate/ingress", strings.NewReader(test.body))
+		w := httptest.NewRecorder()
+		webhook.handlers.ServeHTTP(w, req)
+		if w.Code != test.expCode {
+			t.Errorf("status code differs on %d - expected: %d - actual: %d", i, test.expCode, w.Code)
+		}
+		if validated != test.expValidate {
+			t.Errorf("validated ingress differs on %d - expected: '%s' - actual: '%s'", i, test.expValidate, validated)
+		}
+		if w.Code != http.StatusOK {
+			if body := strings.TrimSpace(w.Body.String()); body != test.expBody {
+				t.Errorf("body differs on %d - expected: '%s' - actual: '%s'", i, test.expBody, body)
+			}
+			continue
+		}
+		resp := admission.AdmissionReview{}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Errorf("error decoding response on %d: %v", i, err)
+			continue
+		}
+		if resp.Request != nil || resp.Response == nil || resp.Response.UID != "1234" {
+			t.Errorf("invalid admission review on %d: %s", i, w.Body.String())
+			continue
+		}
+		if resp.Response.Allowed != test.expAllowed {
+			t.Errorf("allowed differs on %d - expected: %t - actual: %t", i, test.expAllowed, resp.Response.Allowed)
+		}
+		var message string
+		if resp.Response.Result != nil {
+			message = resp.Response.Result.Message
+		}
+		if message != test.expMessage {
+			t.Errorf("message differs on %d - expected: '%s' - actual: '%s'", i, test.expMessage, message)
+		}
+		if !reflect.DeepEqual(resp.Response.Warnings, test.expWarnings) {
+			t.Errorf("warnings differ on %d - expected: %v - actual: %v", i, test.expWarnings, resp.Response.Warnings)
+		}
+	}
+}
+
+func TestAdmissionWebhookPanic(t *testing.T) {
+	const review = `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"1234","kind":{"group":"networking.k8s.io","version":"v1","kind":"Ingress"},"operation":"CREATE","namespace":"default","name":"ing1","object":{}}}`
+	var calls int
+	webhook := newAdmissionWebhook(types_helper.NewLoggerMock(t), "", "", func(ing *networking.Ingress) (denied, warnings []string) {
+		calls++
+		if calls == 1 {
+			panic("validation failed")
+		}
+		return nil, nil
+	})
+	review1 := func() (code int, panicked bool) {
+		defer func() {
+			panicked = recover() != nil
+		}()
+		w := httptest.NewRecorder()
+		webhook.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/validate/ingress", strings.NewReader(review)))
+		return w.Code, false
+	}
+	if _, panicked := review1(); !panicked {
+		t.Errorf("expected a panic on the first review")
+	}
+	done := make(chan int)
+	go func() {
+		code, _ := review1()
+		done <- code
+	}()
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Errorf("status code differs - expected: %d - actual: %d", http.StatusOK, code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("review is blocked after a panic")
+	}
+}
+
+func TestValidationLogger(t *testing.T) {
+	logger := &validationLogger{source: "ingress 'default/ing1'"}
+	logger.Info("starting sync")
+	logger.Warn("ignoring invalid balance algorithm on %v: %s", "ingress 'default/ing1'", "invalid")
+	logger.Warn("ignoring invalid balance algorithm on %v: %s", "ingress 'default/ing2'", "invalid")
+	logger.Error("error reading default service: %v", "service not found")
+	logger.Error("skipping backend config of %v: %v", "ingress 'default/ing1'", "service not found")
+	expected := []string{
+		"ignoring invalid balance algorithm on ingress 'default/ing1': invalid",
+		"skipping backend config of ingress 'default/ing1': service not found",
+	}
+	if !reflect.DeepEqual(logger.messages, expected) {
+		t.Errorf("messages differ - expected: %v - actual: %v", expected, logger.messages)
+	}
+	cache := &validationCache{}
+	_ = cache.lookupError(nil)
+	_ = cache.lookupError(fmt.Errorf("service not found"))
+	for _, msg := range expected {
+		if cache.isLookupError(msg) != strings.Contains(msg, "service not found") {
+			t.Errorf("unexpected lookup error classification: %s", msg)
+		}
+	}
+}
+
+func TestValidateIngressJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"key1","n":"%s","e":"%s"}]}`,
+		jwkInt(rsaKey.N), jwkInt(big.NewInt(int64(rsaKey.E))))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jwks.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(jwks))
+	}))
+	defer server.Close()
+	testCases := []struct {
+		path        string
+		expDenied   []string
+		expWarnings []string
+	}{
+		// 0
+		{
+			path: "/jwks.json",
+		},
+		// 1
+		{
+			path:        "/notfound.json",
+			expWarnings: []string{"error reading JWKS on ingress 'default/ing1', requests will be denied until the keys are read: unexpected status code: 404"},
+		},
+	}
+	for i, test := range testCases {
+		logger := types_helper.NewLoggerMock(t)
+		hc := newValidateIngressMock(logger)
+		jwksCache, _ := newJWKSCacheMock(logger)
+		hc.cache.jwks = jwksCache
+		ing := &networking.Ingress{}
+		ing.Namespace = "default"
+		ing.Name = "ing1"
+		ing.Annotations = map[string]string{
+			"haproxy-ingress.github.io/jwt-jwks-url": server.URL + test.path,
+		}
+		ing.Spec.DefaultBackend = &networking.IngressBackend{
+			Service: &networking.IngressServiceBackend{
+				Name: "app",
+				Port: networking.ServiceBackendPort{Number: 8080},
+			},
+		}
+		denied, warnings := hc.validateIngress(ing)
+		if !reflect.DeepEqual(denied, test.expDenied) {
+			t.Errorf("denied differs on %d - expected: %v - actual: %v", i, test.expDenied, denied)
+		}
+		if !reflect.DeepEqual(warnings, test.expWarnings) {
+			t.Errorf("warnings differ on %d - expected: %v - actual: %v", i, test.expWarnings, warnings)
+		}
+		logger.CompareLogging("")
+	}
+}
+
+func newValidateIngressMock(logger *types_helper.LoggerMock) *HAProxyController {
+	newIndexer := func() cache.Indexer {
+		return cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	}
+	services := newIndexer()
+	_ = services.Add(&api.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: api.ServiceSpec{
+			Ports: []api.ServicePort{{Port: 8080, TargetPort: intstr.FromInt(8080)}},
+		},
+	})
+	dynamicConfig := &convtypes.DynamicConfig{}
+	hc := &HAProxyController{
+		cache: &k8scache{
+			logger: logger,
+			cfg: &controller.Configuration{
+				AnnPrefix:                []string{"haproxy-ingress.github.io"},
+				WatchIngressWithoutClass: true,
+			},
+			listers: &listers{
+				ingressLister:   listersnetworking.NewIngressLister(newIndexer()),
+				serviceLister:   listerscore.NewServiceLister(services),
+				endpointLister:  listerscore.NewEndpointsLister(newIndexer()),
+				secretLister:    listerscore.NewSecretLister(newIndexer()),
+				configMapLister: listerscore.NewConfigMapLister(newIndexer()),
+				podLister:       listerscore.NewPodLister(newIndexer()),
+			},
+			dynamicConfig: dynamicConfig,
+		},
+		dynamicConfig: dynamicConfig,
+	}
+	hc.converterOptions = &convtypes.ConverterOptions{
+		AnnotationPrefix: []string{"haproxy-ingress.github.io"},
+		FakeCrtFile:      convtypes.CrtFile{Filename: "/tmp/fake.pem"},
+		FakeCAFile:       convtypes.CrtFile{Filename: "/tmp/fakeca.pem"},
+	}
+	return hc
+}
@@ -19,9 +19,12 @@ package configmap
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
+	api "k8s.io/api/core/v1"
+
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	convutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
@@ -30,7 +33,7 @@ import (
 
 // TCPServicesConverter ...
 type TCPServicesConverter interface {
-	Sync()
+	Sync(full bool)
 }
 
 // NewTCPServicesConverter ...
@@ -52,9 +55,7 @@ type tcpSvcConverter struct {
 
 var regexValidTime = regexp.MustCompile(`^[0-9]+(us|ms|s|m|h|d)$`)
 
-func (c *tcpSvcConverter) Sync() {
-	c.haproxy.TCPBackends().RemoveAll()
-
+func (c *tcpSvcConverter) Sync(full bool) {
 	// map[key]value is:
 	// - key   => port to expose
 	// - value => <service-name>:<port>:[<PROXY>]:[<PROXY[-<V1|V2>]]:<secret-name-cert>:check-interval:<secret-name-ca>
@@ -69,77 +70,148 @@ func (c *tcpSvcConverter) Sync() {
 	if tcpservices == nil {
 		tcpservices = c.changed.TCPConfigMapDataCur
 	}
+	if full {
+		c.haproxy.TCPBackends().RemoveAll()
+		for k, v := range tcpservices {
+			c.syncService(k, v)
+		}
+		return
+	}
+	dirtyPorts := c.dirtyPorts(tcpservices)
+	for _, k := range dirtyPorts {
+		if publicport, err := strconv.Atoi(k); err == nil {
+			c.haproxy.TCPBackends().Remove(publicport)
+		}
+	}
+	for _, k := range dirtyPorts {
+		if v, found := tcpservices[k]; found {
+			c.syncService(k, v)
+		}
+	}
+	if len(dirtyPorts) > 0 {
+		c.logger.InfoV(2, "syncing %d TCP service(s) from configmap", len(dirtyPorts))
+	}
+}
+
+// dirtyPorts lists the public ports whose configuration changed, or whose
+// service, endpoints or secrets were changed since the last sync. Public
+// ports removed from the configmap are also listed, so they can be removed
+// from the model.
+func (c *tcpSvcConverter) dirtyPorts(tcpservices map[string]string) []string {
+	dirtyObjs := map[string]bool{}
+	for _, svc := range c.changed.ServicesDel {
+		dirtyObjs[svc.Namespace+"/"+svc.Name] = true
+	}
+	for _, svc := range c.changed.ServicesUpd {
+		dirtyObjs[svc.Namespace+"/"+svc.Name] = true
+	}
+	for _, svc := range c.changed.ServicesAdd {
+		dirtyObjs[svc.Namespace+"/"+svc.Name] = true
+	}
+	for _, ep := range c.changed.EndpointsNew {
+		dirtyObjs[convutils.EndpointSliceService(ep)] = true
+	}
+	for _, secret := range c.changed.SecretsDel {
+		dirtyObjs[secret.Namespace+"/"+secret.Name] = true
+	}
+	for _, secret := range c.changed.SecretsUpd {
+		dirtyObjs[secret.Namespace+"/"+secret.Name] = true
+	}
+	for _, secret := range c.changed.SecretsAdd {
+		dirtyObjs[secret.Namespace+"/"+secret.Name] = true
+	}
+	var dirtyPorts []string
+	if c.changed.TCPConfigMapDataNew != nil {
+		for k := range c.changed.TCPConfigMapDataCur {
+			if _, found := tcpservices[k]; !found {
+				dirtyPorts = append(dirtyPorts, k)
+			}
+		}
+	}
 	for k, v := range tcpservices {
-		publicport, err := strconv.Atoi(k)
-		if err != nil {
-			c.logger.Warn("skipping invalid public listening port of TCP service: %s", k)
+		if c.changed.TCPConfigMapDataNew != nil && c.changed.TCPConfigMapDataCur[k] != v {
+			dirtyPorts = append(dirtyPorts, k)
 			continue
 		}
 		svc := c.parseService(v)
-		if svc.name == "" {
-			c.logger.Warn("skipping empty TCP service name on public port %d", publicport)
-			continue
+		if dirtyObjs[svc.name] || dirtyObjs[svc.secretTLS] || dirtyObjs[svc.secretCA] {
+			dirtyPorts = append(dirtyPorts, k)
 		}
-		service, err := c.cache.GetService("", svc.name)
+	}
+	sort.Strings(dirtyPorts)
+	return dirtyPorts
+}
+
+func (c *tcpSvcConverter) syncService(k, v string) {
+	publicport, err := strconv.Atoi(k)
+	if err != nil {
+		c.logger.Warn("skipping invalid public listening port of TCP service: %s", k)
+		return
+	}
+	svc := c.parseService(v)
+	if svc.name == "" {
+		c.logger.Warn("skipping empty TCP service name on public port %d", publicport)
+		return
+	}
+	service, err := c.cache.GetService("", svc.name)
+	if err != nil {
+		c.logger.Warn("skipping TCP service on public port %d: %v", publicport, err)
+		return
+	}
+	svcport := convutils.FindServicePort(service, svc.port)
+	if svcport == nil {
+		c.logger.Warn("skipping TCP service on public port %d: port not found: %s:%s", publicport, svc.name, svc.port)
+		return
+	}
+	addrs, _, err := convutils.CreateEndpoints(c.cache, service, svcport)
+	if err != nil {
+		c.logger.Warn("skipping TCP service on public port %d: %v", svc.port, err)
+		return
+	}
+	var crtfile convtypes.CrtFile
+	if svc.secretTLS != "" {
+		crtfile, err = c.cache.GetTLSSecretPath("", svc.secretTLS, convtypes.TrackingTarget{})
 		if err != nil {
 			c.logger.Warn("skipping TCP service on public port %d: %v", publicport, err)
-			continue
+			return
 		}
-		svcport := convutils.FindServicePort(service, svc.port)
-		if svcport == nil {
-			c.logger.Warn("skipping TCP service on public port %d: port not found: %s:%s", publicport, svc.name, svc.port)
-			continue
-		}
-		addrs, _, err := convutils.CreateEndpoints(c.cache, service, svcport)
+	}
+	var cafile, crlfile convtypes.File
+	if svc.secretCA != "" {
+		cafile, crlfile, err = c.cache.GetCASecretPath("", svc.secretCA, convtypes.TrackingTarget{})
 		if err != nil {
-			c.logger.Warn("skipping TCP service on public port %d: %v", svc.port, err)
-			continue
-		}
-		var crtfile convtypes.CrtFile
-		if svc.secretTLS != "" {
-			crtfile, err = c.cache.GetTLSSecretPath("", svc.secretTLS, convtypes.TrackingTarget{})
-			if err != nil {
-				c.logger.Warn("skipping TCP service on public port %d: %v", publicport, err)
-				continue
-			}
-		}
-		var cafile, crlfile convtypes.File
-		if svc.secretCA != "" {
-			cafile, crlfile, err = c.cache.GetCASecretPath("", svc.secretCA, convtypes.TrackingTarget{})
-			if err != nil {
-				c.logger.Warn("skipping TCP service on public port %d: %v", publicport, err)
-				continue
-			}
-		}
-		checkInterval := "2s"
-		if svc.checkInt != "" {
-			if svc.checkInt == "-" {
-				checkInterval = ""
-			} else if regexValidTime.MatchString(svc.checkInt) {
-				checkInterval = svc.checkInt
-			} else {
-				c.logger.Warn(
-					"using default check interval '%s' due to an invalid time config on TCP service %d: %s",
-					checkInterval, publicport, svc.checkInt)
-			}
-		}
-		servicename := fmt.Sprintf("%s_%s", service.Namespace, service.Name)
-		backend := c.haproxy.TCPBackends().Acquire(servicename, publicport)
-		for _, addr := range addrs {
-			backend.AddEndpoint(addr.IP, addr.Port)
+			c.logger.Warn("skipping TCP service on public port %d: %v", publicport, err)
+			return
 		}
-		backend.ProxyProt.Decode = strings.ToLower(svc.inProxy) == "proxy"
-		backend.CheckInterval = checkInterval
-		switch strings.ToLower(svc.outProxy) {
-		case "proxy", "proxy-v2":
-			backend.ProxyProt.EncodeVersion = "v2"
-		case "proxy-v1":
-			backend.ProxyProt.EncodeVersion = "v1"
+	}
+	checkInterval := "2s"
+	if svc.checkInt != "" {
+		if svc.checkInt == "-" {
+			checkInterval = ""
+		} else if regexValidTime.MatchString(svc.checkInt) {
+			checkInterval = svc.checkInt
+		} else {
+			c.logger.Warn(
+				"using default check interval '%s' due to an invalid time config on TCP service %d: %s",
+				checkInterval, publicport, svc.checkInt)
 		}
-		backend.SSL.Filename = crtfile.Filename
-		backend.SSL.CAFilename = cafile.Filename
-		backend.SSL.CRLFilename = crlfile.Filename
 	}
+	servicename := fmt.Sprintf("%s_%s", service.Namespace, service.Name)
+	backend := c.haproxy.TCPBackends().Acquire(servicename, publicport)
+	for _, addr := range addrs {
+		backend.AddEndpoint(addr.IP, addr.Port)
+	}
+	backend.ProxyProt.Decode = strings.ToLower(svc.inProxy) == "proxy"
+	backend.CheckInterval = checkInterval
+	switch strings.ToLower(svc.outProxy) {
+	case "proxy", "proxy-v2":
+		backend.ProxyProt.EncodeVersion = "v2"
+	case "proxy-v1":
+		backend.ProxyProt.EncodeVersion = "v1"
+	}
+	backend.SSL.Filename = crtfile.Filename
+	backend.SSL.CAFilename = cafile.Filename
+	backend.SSL.CRLFilename = crlfile.Filename
 }
 
 type tcpSvc struct {
@@ -164,8 +236,18 @@ func (c *tcpSvcConverter) parseService(service string) *tcpSvc {
 		port:      svc[1],
 		inProxy:   svc[2],
 		outProxy:  svc[3],
-		secretTLS: svc[4],
+		secretTLS: secretName(svc[4]),
 		checkInt:  svc[5],
-		secretCA:  svc[6],
+		secretCA:  secretName(svc[6]),
+	}
+}
+
+// secretName adds the default namespace to a secret name without namespace,
+// so it is read from, and compared with the changed secrets, using the same
+// namespace/name notation. Filenames and empty names are left untouched.
+func secretName(name string) string {
+	if name == "" || strings.HasPrefix(name, "file://") || strings.Contains(name, "/") {
+		return name
 	}
+	return api.NamespaceDefault + "/" + name
 }
@@ -21,6 +21,9 @@ import (
 	"strings"
 	"testing"
 
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/tracker"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
@@ -302,8 +305,255 @@ func TestTCPSvcSync(t *testing.T) {
 			Cache:  c.cache,
 		}, c.haproxy, &types.ChangedObjects{
 			TCPConfigMapDataNew: test.services,
-		}).Sync()
+		}).Sync(true)
+		backends := c.haproxy.TCPBackends().BuildSortedItems()
+		for _, b := range backends {
+			for _, ep := range b.Endpoints {
+				ep.Target = ""
+			}
+		}
+		if !reflect.DeepEqual(backends, test.expected) {
+			t.Errorf("backend differs on %d -- expected: %+v -- actual: %+v", i, test.expected, backends)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestTCPSvcSyncPartial(t *testing.T) {
+	testCases := []struct {
+		svcmock     map[string]string
+		secretMock  map[string]string
+		servicesCur map[string]string
+		servicesNew map[string]string
+		epUpd       map[string]string
+		secretUpd   []string
+		expected    []*hatypes.TCPBackend
+		expSynced   []int
+		logging     string
+	}{
+		// 0
+		{
+			svcmock: map[string]string{
+				"default/pg:5432":     "172.17.0.101",
+				"default/sendmail:25": "172.17.0.201",
+			},
+			servicesCur: map[string]string{
+				"15432": "default/pg:5432",
+				"10025": "default/sendmail:25",
+			},
+			epUpd: map[string]string{
+				"default/pg:5432": "172.17.0.102",
+			},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 15432,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.102", Port: 5432},
+					},
+					CheckInterval: "2s",
+				},
+				{
+					Name: "default_sendmail",
+					Port: 10025,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.201", Port: 25},
+					},
+					CheckInterval: "2s",
+				},
+			},
+			expSynced: []int{15432},
+			logging:   `INFO-V(2) syncing 1 TCP service(s) from configmap`,
+		},
+		// 1
+		{
+			svcmock: map[string]string{
+				"default/pg:5432":     "172.17.0.101",
+				"default/sendmail:25": "172.17.0.201",
+			},
+			servicesCur: map[string]string{
+				"15432": "default/pg:5432",
+				"10025": "default/sendmail:25",
+			},
+			servicesNew: map[string]string{
+				"15432": "default/pg:5432::::-",
+			},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 15432,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					},
+				},
+			},
+			expSynced: []int{15432},
+			logging:   `INFO-V(2) syncing 2 TCP service(s) from configmap`,
+		},
+		// 2
+		{
+			svcmock: map[string]string{
+				"default/pg:5432": "172.17.0.101",
+			},
+			servicesCur: map[string]string{
+				"15432": "default/pg:5432",
+			},
+			epUpd: map[string]string{
+				"default/other:8080": "172.17.0.151",
+			},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 15432,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					},
+					CheckInterval: "2s",
+				},
+			},
+		},
+		// 3
+		{
+			svcmock: map[string]string{
+				"default/pg:5432":     "172.17.0.101",
+				"default/sendmail:25": "172.17.0.201",
+			},
+			servicesCur: map[string]string{
+				"15432": "default/pg:5432",
+			},
+			servicesNew: map[string]string{
+				"15432": "default/pg:5432",
+				"10025": "default/sendmail:25",
+			},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 15432,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					},
+					CheckInterval: "2s",
+				},
+				{
+					Name: "default_sendmail",
+					Port: 10025,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.201", Port: 25},
+					},
+					CheckInterval: "2s",
+				},
+			},
+			expSynced: []int{10025},
+			logging:   `INFO-V(2) syncing 1 TCP service(s) from configmap`,
+		},
+		// 4
+		{
+			svcmock: map[string]string{
+				"default/pg:5432":     "172.17.0.101",
+				"default/sendmail:25": "172.17.0.201",
+			},
+			secretMock: map[string]string{"default/crt": "/var/haproxy/ssl/crt.pem"},
+			servicesCur: map[string]string{
+				"15432": "default/pg:5432:::crt",
+				"10025": "default/sendmail:25",
+			},
+			secretUpd: []string{"default/crt"},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 15432,
+					SSL:  hatypes.TCPSSL{Filename: "/var/haproxy/ssl/crt.pem"},
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					},
+					CheckInterval: "2s",
+				},
+				{
+					Name: "default_sendmail",
+					Port: 10025,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.201", Port: 25},
+					},
+					CheckInterval: "2s",
+				},
+			},
+			expSynced: []int{15432},
+			logging:   `INFO-V(2) syncing 1 TCP service(s) from configmap`,
+		},
+		// 5
+		{
+			svcmock: map[string]string{
+				"default/pg:5432": "172.17.0.101",
+			},
+			secretMock: map[string]string{"default/crt": "/var/haproxy/ssl/crt.pem"},
+			servicesCur: map[string]string{
+				"15432": "default/pg:5432:::crt",
+			},
+			secretUpd: []string{"other/crt"},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 15432,
+					SSL:  hatypes.TCPSSL{Filename: "/var/haproxy/ssl/crt.pem"},
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					},
+					CheckInterval: "2s",
+				},
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.SecretTLSPath = test.secretMock
+		for svckey, endpoinds := range test.svcmock {
+			svcport := strings.Split(svckey, ":")
+			svc, ep := conv_helper.CreateService(svcport[0], svcport[1], endpoinds)
+			c.cache.SvcList = append(c.cache.SvcList, svc)
+			c.cache.EpList[svcport[0]] = ep
+		}
+		options := &types.ConverterOptions{
+			Logger: c.logger,
+			Cache:  c.cache,
+		}
+		NewTCPServicesConverter(options, c.haproxy, &types.ChangedObjects{
+			TCPConfigMapDataNew: test.servicesCur,
+		}).Sync(true)
+		c.haproxy.Commit()
+		before := map[int]*hatypes.TCPBackend{}
+		for _, b := range c.haproxy.TCPBackends().BuildSortedItems() {
+			before[b.Port] = b
+		}
+		changed := &types.ChangedObjects{
+			TCPConfigMapDataCur: test.servicesCur,
+			TCPConfigMapDataNew: test.servicesNew,
+		}
+		for svckey, endpoints := range test.epUpd {
+			svcport := strings.Split(svckey, ":")
+			_, ep := conv_helper.CreateService(svcport[0], svcport[1], endpoints)
+			c.cache.EpList[svcport[0]] = ep
+			changed.EndpointsNew = append(changed.EndpointsNew, ep)
+		}
+		for _, secret := range test.secretUpd {
+			nsname := strings.Split(secret, "/")
+			changed.SecretsUpd = append(changed.SecretsUpd, &api.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: nsname[0], Name: nsname[1]},
+			})
+		}
+		NewTCPServicesConverter(options, c.haproxy, changed).Sync(false)
 		backends := c.haproxy.TCPBackends().BuildSortedItems()
+		// backends of unchanged ports should be left alone, a distinct
+		// instance means the port was removed and converted again
+		var synced []int
+		for _, b := range backends {
+			if before[b.Port] != b {
+				synced = append(synced, b.Port)
+			}
+		}
+		if !reflect.DeepEqual(synced, test.expSynced) {
+			t.Errorf("synced ports differ on %d -- expected: %v -- actual: %v", i, test.expSynced, synced)
+		}
 		for _, b := range backends {
 			for _, ep := range b.Endpoints {
 				ep.Target = ""
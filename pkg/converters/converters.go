@@ -18,6 +18,7 @@ package converters
 
 import (
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/configmap"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/crd"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/gateway"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
@@ -27,7 +28,7 @@ import (
 
 // Config ...
 type Config interface {
-	Sync()
+	Sync() *convtypes.ChangedObjects
 }
 
 // NewConverter ...
@@ -45,14 +46,31 @@ type converters struct {
 	options *convtypes.ConverterOptions
 }
 
-func (c *converters) Sync() {
+func (c *converters) Sync() *convtypes.ChangedObjects {
 	changed := c.options.Cache.SwapChangedObjects()
-	ingressConverter := ingress.NewIngressConverter(c.options, c.haproxy, changed)
+	var resourceConfigs convtypes.ResourceConfigs
+	if c.options.HasCRDs {
+		// merges the Global resource keys into the global config,
+		// so it should run before the other converters are created
+		crd.NewGlobalConverter(c.options, changed).Sync()
+		resourceConfigs.Hosts = crd.NewHostConverter(c.options, changed).Sync()
+		resourceConfigs.Backends = crd.NewBackendConverter(c.options, changed).Sync()
+		resourceConfigs.Middlewares = crd.NewMiddlewareConverter(c.options, changed).Sync()
+		resourceConfigs.AuthConfigs = crd.NewAuthConfigConverter(c.options, changed).Sync()
+		resourceConfigs.RateLimits = crd.NewRateLimitConverter(c.options, changed).Sync()
+		crd.NewIPListConverter(c.options, changed).Sync()
+		c.timer.Tick("parse_crds")
+	}
+	ingressConverter := ingress.NewIngressConverter(c.options, c.haproxy, changed, resourceConfigs)
 	gatewayConverter := gateway.NewGatewayConverter(c.options, c.haproxy, changed, ingressConverter)
+	tcpServiceConverter := crd.NewTCPServiceConverter(c.options, c.haproxy, changed)
+	udpServiceConverter := crd.NewUDPServiceConverter(c.options, c.haproxy, changed)
 
 	needFullSync := changed.NeedFullSync ||
 		gatewayConverter.NeedFullSync() ||
-		ingressConverter.NeedFullSync()
+		ingressConverter.NeedFullSync() ||
+		(c.options.HasCRDs && tcpServiceConverter.NeedFullSync()) ||
+		(c.options.HasCRDs && udpServiceConverter.NeedFullSync())
 	if needFullSync {
 		c.haproxy.Clear()
 	}
@@ -82,10 +100,23 @@ func (c *converters) Sync() {
 	//
 	// configmap converters
 	//
-	if needFullSync || changed.TCPConfigMapDataNew != nil {
-		tcpSvcConverter := configmap.NewTCPServicesConverter(c.options, c.haproxy, changed)
-		tcpSvcConverter.Sync()
-		c.timer.Tick("parse_tcp_svc")
+	// tcp services converter runs on every update, and not only when its
+	// ConfigMap changes: a partial sync converts only the ports whose entry,
+	// target service, endpoints or secrets changed, the other ones are left
+	// untouched in the haproxy model
+	tcpSvcConverter := configmap.NewTCPServicesConverter(c.options, c.haproxy, changed)
+	tcpSvcConverter.Sync(needFullSync)
+	c.timer.Tick("parse_tcp_svc")
+
+	//
+	// custom resource converters
+	//
+	if c.options.HasCRDs {
+		tcpServiceConverter.Sync(needFullSync)
+		c.timer.Tick("parse_tcp_svc_crd")
+		udpServiceConverter.Sync(needFullSync)
+		c.timer.Tick("parse_udp_svc_crd")
 	}
 
+	return changed
 }
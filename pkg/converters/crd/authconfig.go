@@ -0,0 +1,153 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// AuthConfigConverter ...
+type AuthConfigConverter interface {
+	Sync() map[string]*convtypes.ResourceConfig
+}
+
+// NewAuthConfigConverter ...
+func NewAuthConfigConverter(options *convtypes.ConverterOptions, changed *convtypes.ChangedObjects) AuthConfigConverter {
+	return &authConfigConverter{
+		logger:  options.Logger,
+		cache:   options.Cache,
+		changed: changed,
+	}
+}
+
+type authConfigConverter struct {
+	logger  types.Logger
+	cache   convtypes.Cache
+	changed *convtypes.ChangedObjects
+}
+
+// header names copied from and to the auth service accept `*` as a wildcard
+var regexAuthHeaderName = regexp.MustCompile(`^[A-Za-z0-9_.*-]+$`)
+
+// Sync builds the configuration of the AuthConfig resources, indexed by
+// their namespace and name. Changes in AuthConfig resources start a full
+// sync, which is when their status are updated.
+func (c *authConfigConverter) Sync() map[string]*convtypes.ResourceConfig {
+	authConfigs, err := c.cache.GetAuthConfigList()
+	if err != nil {
+		c.logger.Warn("error reading AuthConfig list: %v", err)
+		return nil
+	}
+	sort.Slice(authConfigs, func(i, j int) bool {
+		return authConfigs[i].Namespace+"/"+authConfigs[i].Name < authConfigs[j].Namespace+"/"+authConfigs[j].Name
+	})
+	configs := make(map[string]*convtypes.ResourceConfig, len(authConfigs))
+	for _, authConfig := range authConfigs {
+		name := authConfig.Namespace + "/" + authConfig.Name
+		config, errs := authConfigConfig(&authConfig.Spec)
+		configs[name] = &convtypes.ResourceConfig{
+			Namespace: authConfig.Namespace,
+			Name:      authConfig.Name,
+			Config:    config,
+		}
+		if !c.changed.NeedFullSync {
+			continue
+		}
+		var condition metav1.Condition
+		if len(errs) > 0 {
+			condition = newCondition(v1alpha1.ReasonInvalid, "ignoring invalid fields: "+strings.Join(errs, "; "))
+			c.logger.Warn("AuthConfig %s: %s", name, condition.Message)
+		} else {
+			condition = newCondition(v1alpha1.ReasonAccepted, "AuthConfig configuration is valid")
+		}
+		if err := c.cache.UpdateAuthConfigStatus(authConfig, condition); err != nil {
+			c.logger.Warn("error updating status of AuthConfig %s: %v", name, err)
+		}
+	}
+	return configs
+}
+
+// authConfigConfig converts the spec of an AuthConfig resource to the
+// auth-* and oidc-* configuration keys. A whole section is ignored if one
+// of its required fields is missing, other invalid values are skipped and
+// reported in errs.
+func authConfigConfig(spec *v1alpha1.AuthConfigSpec) (config map[string]string, errs []string) {
+	b := &configBuilder{config: map[string]string{}}
+	if basic := spec.Basic; basic != nil {
+		if basic.SecretName == "" {
+			b.invalid("basic.secretName", "field is required")
+		} else {
+			b.add(ingtypes.BackAuthSecret, basic.SecretName)
+			b.add(ingtypes.BackAuthRealm, basic.Realm)
+			b.add(ingtypes.BackAuthGroups, strings.Join(basic.Groups, ","))
+		}
+	}
+	if external := spec.External; external != nil {
+		if external.URL == "" {
+			b.invalid("external.url", "field is required")
+		} else {
+			b.add(ingtypes.BackAuthURL, external.URL)
+			b.add(ingtypes.BackAuthSignin, external.Signin)
+			b.add(ingtypes.BackAuthMethod, external.Method)
+			if external.ForwardHeaders != nil {
+				b.add(ingtypes.BackAuthForwardHeaders, strconv.FormatBool(*external.ForwardHeaders))
+			}
+			if headers := external.Headers; headers != nil {
+				b.addAuthHeaderNames(ingtypes.BackAuthHeadersRequest, "external.headers.request", headers.Request)
+				b.addAuthHeaderNames(ingtypes.BackAuthHeadersSucceed, "external.headers.succeed", headers.Succeed)
+				b.addAuthHeaderNames(ingtypes.BackAuthHeadersFail, "external.headers.fail", headers.Fail)
+			}
+		}
+	}
+	if oidc := spec.OIDC; oidc != nil {
+		if oidc.Issuer == "" || oidc.SecretName == "" {
+			b.invalid("oidc", "issuer and secretName fields are required")
+		} else {
+			b.add(ingtypes.HostOIDCIssuer, oidc.Issuer)
+			b.add(ingtypes.HostOIDCSecret, oidc.SecretName)
+			b.add(ingtypes.HostOIDCScopes, strings.Join(oidc.Scopes, ","))
+			b.add(ingtypes.HostOIDCCallbackPath, oidc.CallbackPath)
+		}
+	}
+	return b.config, b.errs
+}
+
+// addAuthHeaderNames adds a comma-separated list of header names or
+// patterns. Invalid names are skipped.
+func (b *configBuilder) addAuthHeaderNames(key, field string, names []string) {
+	var valid []string
+	for _, name := range names {
+		if !regexAuthHeaderName.MatchString(name) {
+			b.invalid(field, "invalid header name: '%s'", name)
+		} else {
+			valid = append(valid, name)
+		}
+	}
+	if len(valid) > 0 {
+		b.config[key] = strings.Join(valid, ",")
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+func TestAuthConfigSync(t *testing.T) {
+	forward := true
+	testCases := []struct {
+		authConfigs []string
+		specs       map[string]v1alpha1.AuthConfigSpec
+		partial     bool
+		expected    map[string]*types.ResourceConfig
+		status      map[string]string
+		logging     string
+	}{
+		// 0
+		{
+			expected: map[string]*types.ResourceConfig{},
+		},
+		// 1
+		{
+			authConfigs: []string{"default/basic", "default/sso"},
+			specs: map[string]v1alpha1.AuthConfigSpec{
+				"default/basic": {
+					Basic: &v1alpha1.AuthConfigBasic{
+						SecretName: "users",
+						Realm:      "admin area",
+						Groups:     []string{"admin", "ops"},
+					},
+				},
+				"default/sso": {
+					External: &v1alpha1.AuthConfigExternal{
+						URL:            "http://oauth2-proxy.auth.svc:4180/oauth2/auth",
+						Signin:         "https://auth.example.com/oauth2/start",
+						Method:         "GET",
+						ForwardHeaders: &forward,
+						Headers: &v1alpha1.AuthConfigHeaders{
+							Request: []string{"Cookie"},
+							Succeed: []string{"X-Auth-Request-*"},
+						},
+					},
+					OIDC: &v1alpha1.AuthConfigOIDC{
+						Issuer:     "https://accounts.example.com",
+						SecretName: "oidc-client",
+						Scopes:     []string{"email", "profile"},
+					},
+				},
+			},
+			expected: map[string]*types.ResourceConfig{
+				"default/basic": {
+					Namespace: "default",
+					Name:      "basic",
+					Config: map[string]string{
+						"auth-secret": "users",
+						"auth-realm":  "admin area",
+						"auth-groups": "admin,ops",
+					},
+				},
+				"default/sso": {
+					Namespace: "default",
+					Name:      "sso",
+					Config: map[string]string{
+						"auth-url":             "http://oauth2-proxy.auth.svc:4180/oauth2/auth",
+						"auth-signin":          "https://auth.example.com/oauth2/start",
+						"auth-method":          "GET",
+						"auth-forward-headers": "true",
+						"auth-headers-request": "Cookie",
+						"auth-headers-succeed": "X-Auth-Request-*",
+						"oidc-issuer":          "https://accounts.example.com",
+						"oidc-secret":          "oidc-client",
+						"oidc-scopes":          "email,profile",
+					},
+				},
+			},
+			status: map[string]string{
+				"default/basic": "True/Accepted: AuthConfig configuration is valid",
+				"default/sso":   "True/Accepted: AuthConfig configuration is valid",
+			},
+		},
+		// 2
+		{
+			authConfigs: []string{"default/invalid"},
+			specs: map[string]v1alpha1.AuthConfigSpec{
+				"default/invalid": {
+					Basic: &v1alpha1.AuthConfigBasic{Realm: "admin area"},
+					External: &v1alpha1.AuthConfigExternal{
+						URL: "http://auth.default.svc/auth",
+						Headers: &v1alpha1.AuthConfigHeaders{
+							Fail: []string{"X-Error", "X Reason"},
+						},
+					},
+					OIDC: &v1alpha1.AuthConfigOIDC{Issuer: "https://accounts.example.com"},
+				},
+			},
+			expected: map[string]*types.ResourceConfig{
+				"default/invalid": {
+					Namespace: "default",
+					Name:      "invalid",
+					Config: map[string]string{
+						"auth-url":          "http://auth.default.svc/auth",
+						"auth-headers-fail": "X-Error",
+					},
+				},
+			},
+			status: map[string]string{
+				"default/invalid": "False/Invalid: ignoring invalid fields: spec.basic.secretName: field is required; spec.external.headers.fail: invalid header name: 'X Reason'; spec.oidc: issuer and secretName fields are required",
+			},
+			logging: `WARN AuthConfig default/invalid: ignoring invalid fields: spec.basic.secretName: field is required; spec.external.headers.fail: invalid header name: 'X Reason'; spec.oidc: issuer and secretName fields are required`,
+		},
+		// 3
+		{
+			authConfigs: []string{"default/basic"},
+			specs: map[string]v1alpha1.AuthConfigSpec{
+				"default/basic": {Basic: &v1alpha1.AuthConfigBasic{SecretName: "users"}},
+			},
+			partial: true,
+			expected: map[string]*types.ResourceConfig{
+				"default/basic": {
+					Namespace: "default",
+					Name:      "basic",
+					Config:    map[string]string{"auth-secret": "users"},
+				},
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		for _, name := range test.authConfigs {
+			ns := strings.Split(name, "/")
+			c.cache.AuthList = append(c.cache.AuthList, &v1alpha1.AuthConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: ns[0],
+					Name:      ns[1],
+				},
+				Spec: test.specs[name],
+			})
+		}
+		changed := &types.ChangedObjects{
+			NeedFullSync: !test.partial,
+		}
+		configs := NewAuthConfigConverter(&types.ConverterOptions{
+			Logger: c.logger,
+			Cache:  c.cache,
+		}, changed).Sync()
+		if !reflect.DeepEqual(configs, test.expected) {
+			t.Errorf("config differs on %d -- expected: %+v -- actual: %+v", i, test.expected, configs)
+		}
+		status := map[string]string{}
+		for name, cond := range c.cache.AuthStatus {
+			status[name] = string(cond.Status) + "/" + cond.Reason + ": " + cond.Message
+		}
+		if test.status == nil {
+			test.status = map[string]string{}
+		}
+		if !reflect.DeepEqual(status, test.status) {
+			t.Errorf("status differs on %d -- expected: %+v -- actual: %+v", i, test.status, status)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// BackendConverter ...
+type BackendConverter interface {
+	Sync() map[string]*convtypes.ResourceConfig
+}
+
+// NewBackendConverter ...
+func NewBackendConverter(options *convtypes.ConverterOptions, changed *convtypes.ChangedObjects) BackendConverter {
+	return &backendConverter{
+		logger:  options.Logger,
+		cache:   options.Cache,
+		changed: changed,
+	}
+}
+
+type backendConverter struct {
+	logger  types.Logger
+	cache   convtypes.Cache
+	changed *convtypes.ChangedObjects
+}
+
+// Sync builds the configuration of the services selected by Backend
+// resources, indexed by the namespace and name of the service. The oldest
+// resource owns a service, the others are reported as conflicting. Changes
+// in Backend resources start a full sync, which is when their status are
+// updated.
+func (c *backendConverter) Sync() map[string]*convtypes.ResourceConfig {
+	backends, err := c.cache.GetBackendList()
+	if err != nil {
+		c.logger.Warn("error reading Backend list: %v", err)
+		return nil
+	}
+	sort.Slice(backends, func(i, j int) bool {
+		return olderThan(backends[i], backends[j])
+	})
+	configs := make(map[string]*convtypes.ResourceConfig, len(backends))
+	for _, backend := range backends {
+		name := backend.Namespace + "/" + backend.Name
+		serviceName := backend.Spec.ServiceName
+		service := backend.Namespace + "/" + serviceName
+		var condition metav1.Condition
+		if owner, conflict := configs[service]; conflict {
+			condition = newCondition(v1alpha1.ReasonConflict, fmt.Sprintf("service '%s' is already configured by Backend %s/%s", serviceName, owner.Namespace, owner.Name))
+		} else if serviceName == "" {
+			condition = newCondition(v1alpha1.ReasonInvalid, "spec.serviceName: missing service name")
+		} else {
+			config, errs := backendConfig(&backend.Spec)
+			configs[service] = &convtypes.ResourceConfig{
+				Namespace: backend.Namespace,
+				Name:      backend.Name,
+				Config:    config,
+			}
+			if len(errs) > 0 {
+				condition = newCondition(v1alpha1.ReasonInvalid, "ignoring invalid fields: "+strings.Join(errs, "; "))
+			} else {
+				condition = newCondition(v1alpha1.ReasonAccepted, fmt.Sprintf("Backend configuration successfully applied to service '%s'", serviceName))
+			}
+		}
+		if !c.changed.NeedFullSync {
+			continue
+		}
+		if condition.Reason != v1alpha1.ReasonAccepted {
+			c.logger.Warn("Backend %s: %s", name, condition.Message)
+		}
+		if err := c.cache.UpdateBackendStatus(backend, condition); err != nil {
+			c.logger.Warn("error updating status of Backend %s: %v", name, err)
+		}
+	}
+	return configs
+}
+
+// backendConfig converts the spec of a Backend resource to configuration
+// keys. Typed fields have precedence over the Config field, typed fields
+// with invalid values are not added and are reported in errs.
+func backendConfig(spec *v1alpha1.BackendSpec) (config map[string]string, errs []string) {
+	b := &configBuilder{config: make(map[string]string, len(spec.Config))}
+	for key, value := range spec.Config {
+		b.config[key] = value
+	}
+	b.add(ingtypes.BackBalanceAlgorithm, spec.BalanceAlgorithm)
+	if check := spec.HealthCheck; check != nil {
+		b.add(ingtypes.BackHealthCheckURI, check.URI)
+		b.add(ingtypes.BackHealthCheckMethod, check.Method)
+		b.add(ingtypes.BackHealthCheckHost, check.Host)
+		b.add(ingtypes.BackHealthCheckExpect, check.Expect)
+		b.addNumber(ingtypes.BackHealthCheckPort, "healthCheck.port", check.Port, 1, 65535)
+		b.addTime(ingtypes.BackHealthCheckInterval, "healthCheck.interval", check.Interval)
+		b.addTime(ingtypes.BackHealthCheckTimeout, "healthCheck.timeout", check.Timeout)
+		b.addNumber(ingtypes.BackHealthCheckRiseCount, "healthCheck.riseCount", check.RiseCount, 1, 0)
+		b.addNumber(ingtypes.BackHealthCheckFallCount, "healthCheck.fallCount", check.FallCount, 1, 0)
+	}
+	if timeouts := spec.Timeouts; timeouts != nil {
+		b.addTime(ingtypes.BackTimeoutConnect, "timeouts.connect", timeouts.Connect)
+		b.addTime(ingtypes.BackTimeoutQueue, "timeouts.queue", timeouts.Queue)
+		b.addTime(ingtypes.BackTimeoutServer, "timeouts.server", timeouts.Server)
+		b.addTime(ingtypes.BackTimeoutServerFin, "timeouts.serverFin", timeouts.ServerFin)
+		b.addTime(ingtypes.BackTimeoutTunnel, "timeouts.tunnel", timeouts.Tunnel)
+	}
+	if limits := spec.Limits; limits != nil {
+		b.addNumber(ingtypes.BackMaxconnServer, "limits.maxConnServer", limits.MaxConnServer, 0, 0)
+		b.addNumber(ingtypes.BackMaxQueueServer, "limits.maxQueueServer", limits.MaxQueueServer, 0, 0)
+	}
+	return b.config, b.errs
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+func TestBackendSync(t *testing.T) {
+	number := func(n int32) *int32 { return &n }
+	testCases := []struct {
+		backends []string
+		specs    map[string]v1alpha1.BackendSpec
+		partial  bool
+		expected map[string]*types.ResourceConfig
+		status   map[string]string
+		logging  string
+	}{
+		// 0
+		{
+			expected: map[string]*types.ResourceConfig{},
+		},
+		// 1
+		{
+			backends: []string{"default/echo"},
+			specs: map[string]v1alpha1.BackendSpec{
+				"default/echo": {
+					ServiceName:      "echo",
+					BalanceAlgorithm: "leastconn",
+					HealthCheck: &v1alpha1.BackendHealthCheck{
+						URI:       "/health",
+						Interval:  "5s",
+						FallCount: number(3),
+					},
+					Timeouts: &v1alpha1.BackendTimeouts{
+						Connect: "2s",
+						Server:  "1m",
+					},
+					Limits: &v1alpha1.BackendLimits{
+						MaxConnServer: number(0),
+					},
+					Config: map[string]string{
+						"balance-algorithm": "roundrobin",
+						"initial-weight":    "50",
+					},
+				},
+			},
+			expected: map[string]*types.ResourceConfig{
+				"default/echo": {
+					Namespace: "default",
+					Name:      "echo",
+					Config: map[string]string{
+						"balance-algorithm":       "leastconn",
+						"health-check-fall-count": "3",
+						"health-check-interval":   "5s",
+						"health-check-uri":        "/health",
+						"initial-weight":          "50",
+						"maxconn-server":          "0",
+						"timeout-connect":         "2s",
+						"timeout-server":          "1m",
+					},
+				},
+			},
+			status: map[string]string{
+				"default/echo": "True/Accepted: Backend configuration successfully applied to service 'echo'",
+			},
+		},
+		// 2
+		{
+			backends: []string{"default/echo2", "default/echo1", "other/echo", "default/none"},
+			specs: map[string]v1alpha1.BackendSpec{
+				"default/echo1": {ServiceName: "echo", BalanceAlgorithm: "first"},
+				"default/echo2": {ServiceName: "echo", BalanceAlgorithm: "leastconn"},
+				"other/echo":    {ServiceName: "echo", BalanceAlgorithm: "roundrobin"},
+				"default/none":  {},
+			},
+			expected: map[string]*types.ResourceConfig{
+				"default/echo": {
+					Namespace: "default",
+					Name:      "echo2",
+					Config:    map[string]string{"balance-algorithm": "leastconn"},
+				},
+				"other/echo": {
+					Namespace: "other",
+					Name:      "echo",
+					Config:    map[string]string{"balance-algorithm": "roundrobin"},
+				},
+			},
+			status: map[string]string{
+				"default/echo1": "False/Conflict: service 'echo' is already configured by Backend default/echo2",
+				"default/echo2": "True/Accepted: Backend configuration successfully applied to service 'echo'",
+				"default/none":  "False/Invalid: spec.serviceName: missing service name",
+				"other/echo":    "True/Accepted: Backend configuration successfully applied to service 'echo'",
+			},
+			logging: `
+WARN Backend default/echo1: service 'echo' is already configured by Backend default/echo2
+WARN Backend default/none: spec.serviceName: missing service name`,
+		},
+		// 3
+		{
+			backends: []string{"default/echo"},
+			specs: map[string]v1alpha1.BackendSpec{
+				"default/echo": {
+					ServiceName: "echo",
+					HealthCheck: &v1alpha1.BackendHealthCheck{
+						Port:     number(70000),
+						Interval: "5 seconds",
+					},
+					Timeouts: &v1alpha1.BackendTimeouts{
+						Queue: "10s",
+					},
+				},
+			},
+			expected: map[string]*types.ResourceConfig{
+				"default/echo": {
+					Namespace: "default",
+					Name:      "echo",
+					Config:    map[string]string{"timeout-queue": "10s"},
+				},
+			},
+			status: map[string]string{
+				"default/echo": "False/Invalid: ignoring invalid fields: spec.healthCheck.port: should not be greater than 65535: 70000; spec.healthCheck.interval: invalid time format: 5 seconds",
+			},
+			logging: `WARN Backend default/echo: ignoring invalid fields: spec.healthCheck.port: should not be greater than 65535: 70000; spec.healthCheck.interval: invalid time format: 5 seconds`,
+		},
+		// 4
+		{
+			backends: []string{"default/echo"},
+			specs: map[string]v1alpha1.BackendSpec{
+				"default/echo": {ServiceName: "echo", BalanceAlgorithm: "leastconn"},
+			},
+			partial: true,
+			expected: map[string]*types.ResourceConfig{
+				"default/echo": {
+					Namespace: "default",
+					Name:      "echo",
+					Config:    map[string]string{"balance-algorithm": "leastconn"},
+				},
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		// backends are created in the declared order
+		timestamp := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+		for _, name := range test.backends {
+			ns := strings.Split(name, "/")
+			c.cache.BackendList = append(c.cache.BackendList, &v1alpha1.Backend{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:         ns[0],
+					Name:              ns[1],
+					CreationTimestamp: metav1.NewTime(timestamp),
+				},
+				Spec: test.specs[name],
+			})
+			timestamp = timestamp.Add(time.Second)
+		}
+		changed := &types.ChangedObjects{
+			NeedFullSync: !test.partial,
+		}
+		configs := NewBackendConverter(&types.ConverterOptions{
+			Logger: c.logger,
+			Cache:  c.cache,
+		}, changed).Sync()
+		if !reflect.DeepEqual(configs, test.expected) {
+			t.Errorf("config differs on %d -- expected: %+v -- actual: %+v", i, test.expected, configs)
+		}
+		status := map[string]string{}
+		for name, cond := range c.cache.BackendStatus {
+			status[name] = string(cond.Status) + "/" + cond.Reason + ": " + cond.Message
+		}
+		if test.status == nil {
+			test.status = map[string]string{}
+		}
+		if !reflect.DeepEqual(status, test.status) {
+			t.Errorf("status differs on %d -- expected: %+v -- actual: %+v", i, test.status, status)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// GlobalConverter ...
+type GlobalConverter interface {
+	Sync()
+}
+
+// NewGlobalConverter ...
+func NewGlobalConverter(options *convtypes.ConverterOptions, changed *convtypes.ChangedObjects) GlobalConverter {
+	return &globalConverter{
+		logger:  options.Logger,
+		cache:   options.Cache,
+		name:    options.GlobalResource,
+		changed: changed,
+	}
+}
+
+type globalConverter struct {
+	logger  types.Logger
+	cache   convtypes.Cache
+	name    string
+	changed *convtypes.ChangedObjects
+}
+
+// Sync merges the configuration keys of the Global resource over the keys
+// of the global ConfigMap, so both can be used while migrating from the
+// ConfigMap. It must run before the ingress converter reads the global
+// config. Changes in the Global resource start a full sync, which is when
+// its status is updated.
+func (c *globalConverter) Sync() {
+	if c.name == "" {
+		return
+	}
+	global, err := c.cache.GetGlobal(c.name)
+	if err != nil {
+		c.logger.Warn("error reading Global resource '%s': %v", c.name, err)
+		return
+	}
+	config, errs := globalConfig(&global.Spec)
+	if c.changed.NeedFullSync {
+		var condition metav1.Condition
+		if len(errs) > 0 {
+			message := "ignoring invalid fields: " + strings.Join(errs, "; ")
+			c.logger.Warn("Global %s: %s", c.name, message)
+			condition = newCondition(v1alpha1.ReasonInvalid, message)
+		} else {
+			condition = newCondition(v1alpha1.ReasonAccepted, "Global configuration successfully applied")
+		}
+		if err := c.cache.UpdateGlobalStatus(global, condition); err != nil {
+			c.logger.Warn("error updating status of Global %s: %v", c.name, err)
+		}
+	}
+	c.changed.GlobalConfigMapDataCur = mergeConfig(c.changed.GlobalConfigMapDataCur, config)
+	if c.changed.GlobalConfigMapDataNew != nil {
+		c.changed.GlobalConfigMapDataNew = mergeConfig(c.changed.GlobalConfigMapDataNew, config)
+	}
+}
+
+func newCondition(reason, message string) metav1.Condition {
+	status := metav1.ConditionFalse
+	if reason == v1alpha1.ReasonAccepted {
+		status = metav1.ConditionTrue
+	}
+	return metav1.Condition{
+		Type:    v1alpha1.ConditionAccepted,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+// mergeConfig returns a new map, the ConfigMap data shouldn't be changed
+// since it is also the current state of the cache.
+func mergeConfig(configMap, global map[string]string) map[string]string {
+	config := make(map[string]string, len(configMap)+len(global))
+	for key, value := range configMap {
+		config[key] = value
+	}
+	for key, value := range global {
+		config[key] = value
+	}
+	return config
+}
+
+// globalConfig converts the spec of a Global resource to configuration keys.
+// Typed fields have precedence over the Config field, typed fields with
+// invalid values are not added and are reported in errs.
+func globalConfig(spec *v1alpha1.GlobalSpec) (config map[string]string, errs []string) {
+	b := &configBuilder{config: make(map[string]string, len(spec.Config))}
+	for key, value := range spec.Config {
+		b.config[key] = value
+	}
+	if ports := spec.Ports; ports != nil {
+		b.addNumber(ingtypes.GlobalHTTPPort, "ports.http", ports.HTTP, 1, 65535)
+		b.addNumber(ingtypes.GlobalHTTPSPort, "ports.https", ports.HTTPS, 1, 65535)
+		b.addNumber(ingtypes.GlobalHealthzPort, "ports.healthz", ports.Healthz, 1, 65535)
+		b.addNumber(ingtypes.GlobalPrometheusPort, "ports.prometheus", ports.Prometheus, 1, 65535)
+		b.addNumber(ingtypes.GlobalStatsPort, "ports.stats", ports.Stats, 1, 65535)
+	}
+	if process := spec.Process; process != nil {
+		b.addNumber(ingtypes.GlobalMaxConnections, "process.maxConnections", process.MaxConnections, 1, 0)
+		b.addNumber(ingtypes.GlobalNbthread, "process.nbthread", process.Nbthread, 1, 0)
+		b.addTime(ingtypes.GlobalTimeoutStop, "process.timeoutStop", process.TimeoutStop)
+	}
+	if timeouts := spec.Timeouts; timeouts != nil {
+		b.addTime(ingtypes.GlobalTimeoutClient, "timeouts.client", timeouts.Client)
+		b.addTime(ingtypes.GlobalTimeoutClientFin, "timeouts.clientFin", timeouts.ClientFin)
+		b.addTime(ingtypes.BackTimeoutConnect, "timeouts.connect", timeouts.Connect)
+		b.addTime(ingtypes.BackTimeoutHTTPRequest, "timeouts.httpRequest", timeouts.HTTPRequest)
+		b.addTime(ingtypes.BackTimeoutKeepAlive, "timeouts.keepAlive", timeouts.KeepAlive)
+		b.addTime(ingtypes.BackTimeoutQueue, "timeouts.queue", timeouts.Queue)
+		b.addTime(ingtypes.BackTimeoutServer, "timeouts.server", timeouts.Server)
+		b.addTime(ingtypes.BackTimeoutServerFin, "timeouts.serverFin", timeouts.ServerFin)
+		b.addTime(ingtypes.BackTimeoutTunnel, "timeouts.tunnel", timeouts.Tunnel)
+	}
+	if tls := spec.TLS; tls != nil {
+		b.add(ingtypes.HostSSLCiphers, tls.Ciphers)
+		b.add(ingtypes.HostSSLCipherSuites, tls.CipherSuites)
+		b.add(ingtypes.GlobalSSLOptions, tls.Options)
+		b.addNumber(ingtypes.GlobalSSLDHDefaultMaxSize, "tls.dhDefaultMaxSize", tls.DHDefaultMaxSize, 1024, 0)
+		if tls.Redirect != nil {
+			b.config[ingtypes.BackSSLRedirect] = strconv.FormatBool(*tls.Redirect)
+		}
+		if code := tls.RedirectCode; code != nil {
+			b.addEnum(ingtypes.GlobalSSLRedirectCode, "tls.redirectCode", strconv.Itoa(int(*code)), "301", "302", "303", "307", "308")
+		}
+	}
+	if syslog := spec.Syslog; syslog != nil {
+		b.add(ingtypes.GlobalSyslogEndpoint, syslog.Endpoint)
+		b.addEnum(ingtypes.GlobalSyslogFormat, "syslog.format", syslog.Format, "rfc5424", "rfc3164", "raw")
+		b.addNumber(ingtypes.GlobalSyslogLength, "syslog.length", syslog.Length, 1, 0)
+		b.add(ingtypes.GlobalSyslogTag, syslog.Tag)
+	}
+	return b.config, b.errs
+}
+
+type configBuilder struct {
+	config map[string]string
+	errs   []string
+}
+
+func (b *configBuilder) add(key, value string) {
+	if value != "" {
+		b.config[key] = value
+	}
+}
+
+func (b *configBuilder) invalid(field, format string, args ...interface{}) {
+	b.errs = append(b.errs, fmt.Sprintf("spec.%s: %s", field, fmt.Sprintf(format, args...)))
+}
+
+// addNumber adds a number between min and max, max is not checked if zero
+func (b *configBuilder) addNumber(key, field string, value *int32, min, max int32) {
+	if value == nil {
+		return
+	}
+	v := *value
+	switch {
+	case v < min:
+		b.invalid(field, "should not be lower than %d: %d", min, v)
+	case max > 0 && v > max:
+		b.invalid(field, "should not be greater than %d: %d", max, v)
+	default:
+		b.config[key] = strconv.Itoa(int(v))
+	}
+}
+
+func (b *configBuilder) addTime(key, field, value string) {
+	if value == "" {
+		return
+	}
+	if !regexValidTime.MatchString(value) {
+		b.invalid(field, "invalid time format: %s", value)
+		return
+	}
+	b.config[key] = value
+}
+
+// addCIDRList adds a comma-separated list of IPs or CIDRs, the list is not
+// added if any of its items is invalid
+func (b *configBuilder) addCIDRList(key, field string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	for _, value := range values {
+		if net.ParseIP(value) == nil {
+			if _, _, err := net.ParseCIDR(value); err != nil {
+				b.invalid(field, "invalid IP or CIDR: %s", value)
+				return
+			}
+		}
+	}
+	b.config[key] = strings.Join(values, ",")
+}
+
+func (b *configBuilder) addEnum(key, field, value string, values ...string) {
+	if value == "" {
+		return
+	}
+	for _, v := range values {
+		if v == value {
+			b.config[key] = value
+			return
+		}
+	}
+	b.invalid(field, "unsupported value '%s', should be one of %s", value, strings.Join(values, ", "))
+}
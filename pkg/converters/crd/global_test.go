@@ -0,0 +1,181 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+func TestGlobalSync(t *testing.T) {
+	port := func(p int32) *int32 { return &p }
+	redirect := false
+	testCases := []struct {
+		name      string
+		spec      *v1alpha1.GlobalSpec
+		configmap map[string]string
+		partial   bool
+		expected  map[string]string
+		status    map[string]string
+		logging   string
+	}{
+		// 0
+		{
+			configmap: map[string]string{"max-connections": "1000"},
+			expected:  map[string]string{"max-connections": "1000"},
+		},
+		// 1
+		{
+			name:      "none",
+			configmap: map[string]string{"max-connections": "1000"},
+			expected:  map[string]string{"max-connections": "1000"},
+			logging:   `WARN error reading Global resource 'none': Global not found: none`,
+		},
+		// 2
+		{
+			name: "default",
+			spec: &v1alpha1.GlobalSpec{
+				Ports: &v1alpha1.GlobalPorts{
+					HTTP:  port(8080),
+					HTTPS: port(8443),
+				},
+				Process: &v1alpha1.GlobalProcess{
+					MaxConnections: port(5000),
+				},
+				Timeouts: &v1alpha1.GlobalTimeouts{
+					Client: "1m",
+					Server: "2m",
+				},
+				TLS: &v1alpha1.GlobalTLS{
+					Redirect:     &redirect,
+					RedirectCode: port(308),
+				},
+				Syslog: &v1alpha1.GlobalSyslog{
+					Endpoint: "172.17.0.10:514",
+					Format:   "rfc3164",
+				},
+				Config: map[string]string{
+					"http-port":    "80",
+					"use-htx":      "true",
+					"timeout-stop": "10s",
+				},
+			},
+			configmap: map[string]string{
+				"max-connections": "1000",
+				"timeout-client":  "50s",
+				"nbthread":        "2",
+			},
+			expected: map[string]string{
+				"http-port":         "8080",
+				"https-port":        "8443",
+				"max-connections":   "5000",
+				"nbthread":          "2",
+				"ssl-redirect":      "false",
+				"ssl-redirect-code": "308",
+				"syslog-endpoint":   "172.17.0.10:514",
+				"syslog-format":     "rfc3164",
+				"timeout-client":    "1m",
+				"timeout-server":    "2m",
+				"timeout-stop":      "10s",
+				"use-htx":           "true",
+			},
+			status: map[string]string{
+				"default": "True/Accepted: Global configuration successfully applied",
+			},
+		},
+		// 3
+		{
+			name: "default",
+			spec: &v1alpha1.GlobalSpec{
+				Ports: &v1alpha1.GlobalPorts{
+					HTTP:  port(0),
+					HTTPS: port(70000),
+				},
+				Timeouts: &v1alpha1.GlobalTimeouts{
+					Connect: "5 seconds",
+					Queue:   "10s",
+				},
+				TLS: &v1alpha1.GlobalTLS{
+					RedirectCode: port(304),
+				},
+			},
+			configmap: map[string]string{
+				"http-port": "8080",
+			},
+			expected: map[string]string{
+				"http-port":     "8080",
+				"timeout-queue": "10s",
+			},
+			status: map[string]string{
+				"default": "False/Invalid: ignoring invalid fields: spec.ports.http: should not be lower than 1: 0; spec.ports.https: should not be greater than 65535: 70000; spec.timeouts.connect: invalid time format: 5 seconds; spec.tls.redirectCode: unsupported value '304', should be one of 301, 302, 303, 307, 308",
+			},
+			logging: `WARN Global default: ignoring invalid fields: spec.ports.http: should not be lower than 1: 0; spec.ports.https: should not be greater than 65535: 70000; spec.timeouts.connect: invalid time format: 5 seconds; spec.tls.redirectCode: unsupported value '304', should be one of 301, 302, 303, 307, 308`,
+		},
+		// 4
+		{
+			name: "default",
+			spec: &v1alpha1.GlobalSpec{
+				Syslog: &v1alpha1.GlobalSyslog{
+					Format: "json",
+				},
+			},
+			partial:  true,
+			expected: map[string]string{},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		if test.spec != nil {
+			c.cache.GlobalList = []*v1alpha1.Global{{
+				ObjectMeta: metav1.ObjectMeta{Name: test.name},
+				Spec:       *test.spec,
+			}}
+		}
+		changed := &types.ChangedObjects{
+			GlobalConfigMapDataCur: test.configmap,
+			NeedFullSync:           !test.partial,
+		}
+		NewGlobalConverter(&types.ConverterOptions{
+			Logger:         c.logger,
+			Cache:          c.cache,
+			GlobalResource: test.name,
+		}, changed).Sync()
+		if !reflect.DeepEqual(changed.GlobalConfigMapDataCur, test.expected) {
+			t.Errorf("config differs on %d -- expected: %+v -- actual: %+v", i, test.expected, changed.GlobalConfigMapDataCur)
+		}
+		if changed.GlobalConfigMapDataNew != nil {
+			t.Errorf("new config should not be created on %d", i)
+		}
+		status := map[string]string{}
+		for name, cond := range c.cache.GlobalStatus {
+			status[name] = string(cond.Status) + "/" + cond.Reason + ": " + cond.Message
+		}
+		if test.status == nil {
+			test.status = map[string]string{}
+		}
+		if !reflect.DeepEqual(status, test.status) {
+			t.Errorf("status differs on %d -- expected: %+v -- actual: %+v", i, test.status, status)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
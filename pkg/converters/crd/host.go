@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// HostConverter ...
+type HostConverter interface {
+	Sync() map[string]*convtypes.ResourceConfig
+}
+
+// NewHostConverter ...
+func NewHostConverter(options *convtypes.ConverterOptions, changed *convtypes.ChangedObjects) HostConverter {
+	return &hostConverter{
+		logger:  options.Logger,
+		cache:   options.Cache,
+		changed: changed,
+	}
+}
+
+type hostConverter struct {
+	logger  types.Logger
+	cache   convtypes.Cache
+	changed *convtypes.ChangedObjects
+}
+
+// Sync builds the configuration of the hostnames declared in Host resources,
+// indexed by the hostname. The oldest resource owns a hostname, the others
+// are reported as conflicting. Changes in Host resources start a full sync,
+// which is when their status are updated.
+func (c *hostConverter) Sync() map[string]*convtypes.ResourceConfig {
+	hosts, err := c.cache.GetHostList()
+	if err != nil {
+		c.logger.Warn("error reading Host list: %v", err)
+		return nil
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return olderThan(hosts[i], hosts[j])
+	})
+	configs := make(map[string]*convtypes.ResourceConfig, len(hosts))
+	for _, host := range hosts {
+		name := host.Namespace + "/" + host.Name
+		hostname := host.Spec.Hostname
+		var condition metav1.Condition
+		if owner, conflict := configs[hostname]; conflict {
+			condition = newCondition(v1alpha1.ReasonConflict, fmt.Sprintf("hostname '%s' is already configured by Host %s/%s", hostname, owner.Namespace, owner.Name))
+		} else if hostname == "" {
+			condition = newCondition(v1alpha1.ReasonInvalid, "spec.hostname: missing hostname")
+		} else {
+			config, errs := hostConfig(&host.Spec)
+			configs[hostname] = &convtypes.ResourceConfig{
+				Namespace: host.Namespace,
+				Name:      host.Name,
+				Config:    config,
+			}
+			if len(errs) > 0 {
+				condition = newCondition(v1alpha1.ReasonInvalid, "ignoring invalid fields: "+strings.Join(errs, "; "))
+			} else {
+				condition = newCondition(v1alpha1.ReasonAccepted, fmt.Sprintf("Host configuration successfully applied to '%s'", hostname))
+			}
+		}
+		if !c.changed.NeedFullSync {
+			continue
+		}
+		if condition.Reason != v1alpha1.ReasonAccepted {
+			c.logger.Warn("Host %s: %s", name, condition.Message)
+		}
+		if err := c.cache.UpdateHostStatus(host, condition); err != nil {
+			c.logger.Warn("error updating status of Host %s: %v", name, err)
+		}
+	}
+	return configs
+}
+
+// olderThan is true if o1 was created before o2, the namespace and name
+// are compared if both were created at the same time.
+func olderThan(o1, o2 metav1.Object) bool {
+	t1 := o1.GetCreationTimestamp()
+	t2 := o2.GetCreationTimestamp()
+	if !t1.Equal(&t2) {
+		return t1.Before(&t2)
+	}
+	return o1.GetNamespace()+"/"+o1.GetName() < o2.GetNamespace()+"/"+o2.GetName()
+}
+
+// hostConfig converts the spec of a Host resource to configuration keys.
+// Typed fields have precedence over the Config field, typed fields with
+// invalid values are not added and are reported in errs.
+func hostConfig(spec *v1alpha1.HostSpec) (config map[string]string, errs []string) {
+	b := &configBuilder{config: make(map[string]string, len(spec.Config))}
+	for key, value := range spec.Config {
+		b.config[key] = value
+	}
+	b.add(ingtypes.HostFrontend, spec.Frontend)
+	if tls := spec.TLS; tls != nil {
+		b.add(ingtypes.HostSSLCiphers, tls.Ciphers)
+		b.add(ingtypes.HostSSLCipherSuites, tls.CipherSuites)
+		b.add(ingtypes.HostSSLOptionsHost, tls.Options)
+		b.add(ingtypes.HostTLSALPN, tls.ALPN)
+		if tls.Redirect != nil {
+			b.config[ingtypes.BackSSLRedirect] = strconv.FormatBool(*tls.Redirect)
+		}
+	}
+	b.addAuth(spec.Auth)
+	b.addRateLimit(spec.RateLimit)
+	return b.config, b.errs
+}
+
+// addAuth adds the authentication keys, used by Host and Middleware
+func (b *configBuilder) addAuth(auth *v1alpha1.HostAuth) {
+	if auth == nil {
+		return
+	}
+	if basic := auth.Basic; basic != nil {
+		b.add(ingtypes.BackAuthSecret, basic.SecretName)
+		b.add(ingtypes.BackAuthRealm, basic.Realm)
+	}
+	if external := auth.External; external != nil {
+		b.add(ingtypes.BackAuthURL, external.URL)
+		b.add(ingtypes.BackAuthSignin, external.Signin)
+		b.add(ingtypes.BackAuthMethod, external.Method)
+	}
+	b.add(ingtypes.BackAuthConfig, auth.ConfigName)
+}
+
+// addRateLimit adds the rate limit keys, used by Host and Middleware
+func (b *configBuilder) addRateLimit(limit *v1alpha1.HostRateLimit) {
+	if limit == nil {
+		return
+	}
+	b.addNumber(ingtypes.BackLimitRPS, "rateLimit.rps", limit.RPS, 1, 0)
+	b.addNumber(ingtypes.BackLimitConnections, "rateLimit.connections", limit.Connections, 1, 0)
+	b.addCIDRList(ingtypes.BackLimitWhitelist, "rateLimit.allowList", limit.AllowList)
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+func TestHostSync(t *testing.T) {
+	number := func(n int32) *int32 { return &n }
+	redirect := true
+	testCases := []struct {
+		hosts    []string
+		specs    map[string]v1alpha1.HostSpec
+		partial  bool
+		expected map[string]*types.ResourceConfig
+		status   map[string]string
+		logging  string
+	}{
+		// 0
+		{
+			expected: map[string]*types.ResourceConfig{},
+		},
+		// 1
+		{
+			hosts: []string{"default/echo"},
+			specs: map[string]v1alpha1.HostSpec{
+				"default/echo": {
+					Hostname: "echo.example.com",
+					Frontend: "internal",
+					TLS: &v1alpha1.HostTLS{
+						Options:  "ssl-min-ver TLSv1.2",
+						Redirect: &redirect,
+					},
+					Auth: &v1alpha1.HostAuth{
+						Basic: &v1alpha1.HostAuthBasic{
+							SecretName: "users",
+							Realm:      "echo",
+						},
+					},
+					RateLimit: &v1alpha1.HostRateLimit{
+						RPS:       number(10),
+						AllowList: []string{"10.0.0.0/8", "192.168.1.10"},
+					},
+					Config: map[string]string{
+						"frontend":       "external",
+						"maxconn-server": "10",
+					},
+				},
+			},
+			expected: map[string]*types.ResourceConfig{
+				"echo.example.com": {
+					Namespace: "default",
+					Name:      "echo",
+					Config: map[string]string{
+						"auth-realm":       "echo",
+						"auth-secret":      "users",
+						"frontend":         "internal",
+						"limit-rps":        "10",
+						"limit-whitelist":  "10.0.0.0/8,192.168.1.10",
+						"maxconn-server":   "10",
+						"ssl-options-host": "ssl-min-ver TLSv1.2",
+						"ssl-redirect":     "true",
+					},
+				},
+			},
+			status: map[string]string{
+				"default/echo": "True/Accepted: Host configuration successfully applied to 'echo.example.com'",
+			},
+		},
+		// 2
+		{
+			hosts: []string{"ns2/echo", "ns1/echo", "ns1/other"},
+			specs: map[string]v1alpha1.HostSpec{
+				"ns1/echo":  {Hostname: "echo.example.com", Frontend: "internal"},
+				"ns2/echo":  {Hostname: "echo.example.com", Frontend: "external"},
+				"ns1/other": {},
+			},
+			expected: map[string]*types.ResourceConfig{
+				"echo.example.com": {
+					Namespace: "ns2",
+					Name:      "echo",
+					Config:    map[string]string{"frontend": "external"},
+				},
+			},
+			status: map[string]string{
+				"ns1/echo":  "False/Conflict: hostname 'echo.example.com' is already configured by Host ns2/echo",
+				"ns1/other": "False/Invalid: spec.hostname: missing hostname",
+				"ns2/echo":  "True/Accepted: Host configuration successfully applied to 'echo.example.com'",
+			},
+			logging: `
+WARN Host ns1/echo: hostname 'echo.example.com' is already configured by Host ns2/echo
+WARN Host ns1/other: spec.hostname: missing hostname`,
+		},
+		// 3
+		{
+			hosts: []string{"default/echo"},
+			specs: map[string]v1alpha1.HostSpec{
+				"default/echo": {
+					Hostname: "echo.example.com",
+					RateLimit: &v1alpha1.HostRateLimit{
+						RPS:         number(0),
+						Connections: number(20),
+						AllowList:   []string{"10.0.0.0/8", "10.0.0.0/33"},
+					},
+				},
+			},
+			expected: map[string]*types.ResourceConfig{
+				"echo.example.com": {
+					Namespace: "default",
+					Name:      "echo",
+					Config:    map[string]string{"limit-connections": "20"},
+				},
+			},
+			status: map[string]string{
+				"default/echo": "False/Invalid: ignoring invalid fields: spec.rateLimit.rps: should not be lower than 1: 0; spec.rateLimit.allowList: invalid IP or CIDR: 10.0.0.0/33",
+			},
+			logging: `WARN Host default/echo: ignoring invalid fields: spec.rateLimit.rps: should not be lower than 1: 0; spec.rateLimit.allowList: invalid IP or CIDR: 10.0.0.0/33`,
+		},
+		// 4
+		{
+			hosts: []string{"default/echo"},
+			specs: map[string]v1alpha1.HostSpec{
+				"default/echo": {Hostname: "echo.example.com", Frontend: "internal"},
+			},
+			partial: true,
+			expected: map[string]*types.ResourceConfig{
+				"echo.example.com": {
+					Namespace: "default",
+					Name:      "echo",
+					Config:    map[string]string{"frontend": "internal"},
+				},
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		// hosts are created in the declared order
+		timestamp := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+		for _, name := range test.hosts {
+			ns := strings.Split(name, "/")
+			c.cache.HostList = append(c.cache.HostList, &v1alpha1.Host{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:         ns[0],
+					Name:              ns[1],
+					CreationTimestamp: metav1.NewTime(timestamp),
+				},
+				Spec: test.specs[name],
+			})
+			timestamp = timestamp.Add(time.Second)
+		}
+		changed := &types.ChangedObjects{
+			NeedFullSync: !test.partial,
+		}
+		configs := NewHostConverter(&types.ConverterOptions{
+			Logger: c.logger,
+			Cache:  c.cache,
+		}, changed).Sync()
+		if !reflect.DeepEqual(configs, test.expected) {
+			t.Errorf("config differs on %d -- expected: %+v -- actual: %+v", i, test.expected, configs)
+		}
+		status := map[string]string{}
+		for name, cond := range c.cache.HostStatus {
+			status[name] = string(cond.Status) + "/" + cond.Reason + ": " + cond.Message
+		}
+		if test.status == nil {
+			test.status = map[string]string{}
+		}
+		if !reflect.DeepEqual(status, test.status) {
+			t.Errorf("status differs on %d -- expected: %+v -- actual: %+v", i, test.status, status)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	convutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/utils"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// IPListConverter ...
+type IPListConverter interface {
+	Sync()
+}
+
+// NewIPListConverter ...
+func NewIPListConverter(options *convtypes.ConverterOptions, changed *convtypes.ChangedObjects) IPListConverter {
+	return &ipListConverter{
+		logger:  options.Logger,
+		cache:   options.Cache,
+		changed: changed,
+	}
+}
+
+type ipListConverter struct {
+	logger  types.Logger
+	cache   convtypes.Cache
+	changed *convtypes.ChangedObjects
+}
+
+// Sync updates the status of the IPList resources. The entries themselves
+// are read by the allowlist and denylist configuration keys, which track
+// the lists, so changes in IPList resources do not need a full sync. The
+// status of all the lists are updated on full syncs, otherwise only the
+// added and updated ones.
+func (c *ipListConverter) Sync() {
+	var ipLists []*v1alpha1.IPList
+	if c.changed.NeedFullSync {
+		var err error
+		ipLists, err = c.cache.GetIPListList()
+		if err != nil {
+			c.logger.Warn("error reading IPList list: %v", err)
+			return
+		}
+	} else {
+		ipLists = append(ipLists, c.changed.IPListsAdd...)
+		ipLists = append(ipLists, c.changed.IPListsUpd...)
+	}
+	sort.Slice(ipLists, func(i, j int) bool {
+		return ipLists[i].Name < ipLists[j].Name
+	})
+	for _, ipList := range ipLists {
+		_, invalid := convutils.ParseIPList(ipList.Spec.Entries)
+		var condition metav1.Condition
+		if len(invalid) > 0 {
+			condition = newCondition(v1alpha1.ReasonInvalid, "ignoring invalid IPs or CIDRs: "+strings.Join(invalid, ", "))
+			c.logger.Warn("IPList %s: %s", ipList.Name, condition.Message)
+		} else {
+			condition = newCondition(v1alpha1.ReasonAccepted, "IPList configuration is valid")
+		}
+		if err := c.cache.UpdateIPListStatus(ipList, condition); err != nil {
+			c.logger.Warn("error updating status of IPList %s: %v", ipList.Name, err)
+		}
+	}
+}
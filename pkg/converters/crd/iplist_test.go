@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+func TestIPListSync(t *testing.T) {
+	testCases := []struct {
+		entries map[string][]string
+		partial bool
+		updated []string
+		status  map[string]string
+		logging string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			entries: map[string][]string{
+				"office":   {"10.0.0.0/8", "192.168.0.0/16", "10.0.0.0/8"},
+				"partners": {"203.0.113.10", "2001:db8::/32"},
+			},
+			status: map[string]string{
+				"office":   "True/Accepted: IPList configuration is valid",
+				"partners": "True/Accepted: IPList configuration is valid",
+			},
+		},
+		// 2
+		{
+			entries: map[string][]string{
+				"bad": {"10.0.0.0/48", "192.168.1.101", "!10.1.1.1"},
+			},
+			status: map[string]string{
+				"bad": "False/Invalid: ignoring invalid IPs or CIDRs: 10.0.0.0/48, !10.1.1.1",
+			},
+			logging: `WARN IPList bad: ignoring invalid IPs or CIDRs: 10.0.0.0/48, !10.1.1.1`,
+		},
+		// 3
+		{
+			entries: map[string][]string{
+				"office":   {"10.0.0.0/8"},
+				"partners": {"203.0.113.10"},
+			},
+			partial: true,
+			updated: []string{"partners"},
+			status: map[string]string{
+				"partners": "True/Accepted: IPList configuration is valid",
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		changed := &types.ChangedObjects{
+			NeedFullSync: !test.partial,
+		}
+		for name, entries := range test.entries {
+			ipList := &v1alpha1.IPList{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec:       v1alpha1.IPListSpec{Entries: entries},
+			}
+			c.cache.IPLists = append(c.cache.IPLists, ipList)
+			for _, updated := range test.updated {
+				if updated == name {
+					changed.IPListsUpd = append(changed.IPListsUpd, ipList)
+				}
+			}
+		}
+		NewIPListConverter(&types.ConverterOptions{
+			Logger: c.logger,
+			Cache:  c.cache,
+		}, changed).Sync()
+		status := map[string]string{}
+		for name, cond := range c.cache.IPListStatus {
+			status[name] = string(cond.Status) + "/" + cond.Reason + ": " + cond.Message
+		}
+		if test.status == nil {
+			test.status = map[string]string{}
+		}
+		if !reflect.DeepEqual(status, test.status) {
+			t.Errorf("status differs on %d -- expected: %+v -- actual: %+v", i, test.status, status)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// MiddlewareConverter ...
+type MiddlewareConverter interface {
+	Sync() map[string]*convtypes.ResourceConfig
+}
+
+// NewMiddlewareConverter ...
+func NewMiddlewareConverter(options *convtypes.ConverterOptions, changed *convtypes.ChangedObjects) MiddlewareConverter {
+	return &middlewareConverter{
+		logger:  options.Logger,
+		cache:   options.Cache,
+		changed: changed,
+	}
+}
+
+type middlewareConverter struct {
+	logger  types.Logger
+	cache   convtypes.Cache
+	changed *convtypes.ChangedObjects
+}
+
+var regexHeaderName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Sync builds the configuration of the Middleware resources, indexed by
+// their namespace and name. The configuration of a Middleware already has
+// the configuration of its chained Middlewares merged. Changes in Middleware
+// resources start a full sync, which is when their status are updated.
+func (c *middlewareConverter) Sync() map[string]*convtypes.ResourceConfig {
+	middlewares, err := c.cache.GetMiddlewareList()
+	if err != nil {
+		c.logger.Warn("error reading Middleware list: %v", err)
+		return nil
+	}
+	sort.Slice(middlewares, func(i, j int) bool {
+		return middlewares[i].Namespace+"/"+middlewares[i].Name < middlewares[j].Namespace+"/"+middlewares[j].Name
+	})
+	resolver := &middlewareResolver{
+		middlewares: make(map[string]*v1alpha1.Middleware, len(middlewares)),
+		configs:     make(map[string]map[string]string, len(middlewares)),
+	}
+	errs := make(map[string][]string, len(middlewares))
+	for _, middleware := range middlewares {
+		name := middleware.Namespace + "/" + middleware.Name
+		resolver.middlewares[name] = middleware
+		resolver.configs[name], errs[name] = middlewareConfig(&middleware.Spec)
+	}
+	configs := make(map[string]*convtypes.ResourceConfig, len(middlewares))
+	for _, middleware := range middlewares {
+		name := middleware.Namespace + "/" + middleware.Name
+		config, chainErrs := resolver.resolve(middleware)
+		configs[name] = &convtypes.ResourceConfig{
+			Namespace: middleware.Namespace,
+			Name:      middleware.Name,
+			Config:    config,
+		}
+		if !c.changed.NeedFullSync {
+			continue
+		}
+		var condition metav1.Condition
+		if invalid := append(errs[name], chainErrs...); len(invalid) > 0 {
+			condition = newCondition(v1alpha1.ReasonInvalid, "ignoring invalid fields: "+strings.Join(invalid, "; "))
+			c.logger.Warn("Middleware %s: %s", name, condition.Message)
+		} else {
+			condition = newCondition(v1alpha1.ReasonAccepted, "Middleware configuration is valid")
+		}
+		if err := c.cache.UpdateMiddlewareStatus(middleware, condition); err != nil {
+			c.logger.Warn("error updating status of Middleware %s: %v", name, err)
+		}
+	}
+	return configs
+}
+
+// middlewareResolver merges the configuration of the chained Middlewares.
+type middlewareResolver struct {
+	middlewares map[string]*v1alpha1.Middleware
+	configs     map[string]map[string]string
+}
+
+// resolve merges the configuration of a Middleware with the configuration of
+// its chain, walking the chain depth first. A key already configured has
+// precedence. Missing and circular references are skipped and reported.
+func (r *middlewareResolver) resolve(middleware *v1alpha1.Middleware) (config map[string]string, errs []string) {
+	config = make(map[string]string)
+	visited := map[string]bool{}
+	var walk func(name string, path []string)
+	walk = func(name string, path []string) {
+		visited[name] = true
+		for key, value := range r.configs[name] {
+			if _, found := config[key]; !found {
+				config[key] = value
+			}
+		}
+		for _, ref := range r.middlewares[name].Spec.Middlewares {
+			refName := middleware.Namespace + "/" + ref
+			refPath := append(path[:len(path):len(path)], ref)
+			if _, found := r.middlewares[refName]; !found {
+				errs = append(errs, fmt.Sprintf("spec.middlewares: Middleware '%s' not found: %s", ref, strings.Join(refPath, " -> ")))
+			} else if inPath(refPath[:len(refPath)-1], ref) {
+				errs = append(errs, fmt.Sprintf("spec.middlewares: circular reference: %s", strings.Join(refPath, " -> ")))
+			} else if !visited[refName] {
+				walk(refName, refPath)
+			}
+		}
+	}
+	walk(middleware.Namespace+"/"+middleware.Name, []string{middleware.Name})
+	return config, errs
+}
+
+func inPath(path []string, name string) bool {
+	for _, p := range path {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// middlewareConfig converts the spec of a Middleware resource to
+// configuration keys, its chain is not resolved. Typed fields have
+// precedence over the Config field, typed fields with invalid values are
+// not added and are reported in errs.
+func middlewareConfig(spec *v1alpha1.MiddlewareSpec) (config map[string]string, errs []string) {
+	b := &configBuilder{config: make(map[string]string, len(spec.Config))}
+	for key, value := range spec.Config {
+		b.config[key] = value
+	}
+	b.addRateLimit(spec.RateLimit)
+	if headers := spec.Headers; headers != nil {
+		if request := headers.Request; request != nil {
+			b.addHeaders(ingtypes.BackRequestHeadersSet, "headers.request.set", request.Set)
+			b.addHeaders(ingtypes.BackRequestHeadersAdd, "headers.request.add", request.Add)
+			b.addHeaderNames(ingtypes.BackRequestHeadersDel, "headers.request.remove", request.Remove)
+		}
+		if response := headers.Response; response != nil {
+			b.addHeaders(ingtypes.BackResponseHeadersSet, "headers.response.set", response.Set)
+			b.addHeaders(ingtypes.BackResponseHeadersAdd, "headers.response.add", response.Add)
+			b.addHeaderNames(ingtypes.BackResponseHeadersDel, "headers.response.remove", response.Remove)
+		}
+	}
+	b.addAuth(spec.Auth)
+	if rewrite := spec.Rewrite; rewrite != nil {
+		b.add(ingtypes.BackRewriteTarget, rewrite.Target)
+	}
+	return b.config, b.errs
+}
+
+// addHeaders adds a multi-line list of headers, one `name: value` per line.
+// Headers with an invalid name or value are skipped.
+func (b *configBuilder) addHeaders(key, field string, headers []v1alpha1.MiddlewareHeader) {
+	var lines []string
+	for _, header := range headers {
+		if !regexHeaderName.MatchString(header.Name) {
+			b.invalid(field, "invalid header name: '%s'", header.Name)
+		} else if strings.ContainsAny(header.Value, "\r\n") {
+			b.invalid(field, "header value should not have line breaks: '%s'", header.Name)
+		} else {
+			lines = append(lines, header.Name+": "+header.Value)
+		}
+	}
+	if len(lines) > 0 {
+		b.config[key] = strings.Join(lines, "\n")
+	}
+}
+
+// addHeaderNames adds a comma-separated list of header names. Invalid names
+// are skipped.
+func (b *configBuilder) addHeaderNames(key, field string, names []string) {
+	var valid []string
+	for _, name := range names {
+		if !regexHeaderName.MatchString(name) {
+			b.invalid(field, "invalid header name: '%s'", name)
+		} else {
+			valid = append(valid, name)
+		}
+	}
+	if len(valid) > 0 {
+		b.config[key] = strings.Join(valid, ",")
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+func TestMiddlewareSync(t *testing.T) {
+	number := func(n int32) *int32 { return &n }
+	testCases := []struct {
+		middlewares []string
+		specs       map[string]v1alpha1.MiddlewareSpec
+		partial     bool
+		expected    map[string]*types.ResourceConfig
+		status      map[string]string
+		logging     string
+	}{
+		// 0
+		{
+			expected: map[string]*types.ResourceConfig{},
+		},
+		// 1
+		{
+			middlewares: []string{"default/mw"},
+			specs: map[string]v1alpha1.MiddlewareSpec{
+				"default/mw": {
+					RateLimit: &v1alpha1.HostRateLimit{
+						RPS:       number(20),
+						AllowList: []string{"10.0.0.0/8"},
+					},
+					Headers: &v1alpha1.MiddlewareHeaders{
+						Request: &v1alpha1.MiddlewareHeaderActions{
+							Set: []v1alpha1.MiddlewareHeader{
+								{Name: "X-Env", Value: "prod"},
+								{Name: "X-Team", Value: "web"},
+							},
+							Remove: []string{"X-Debug"},
+						},
+						Response: &v1alpha1.MiddlewareHeaderActions{
+							Add:    []v1alpha1.MiddlewareHeader{{Name: "Cache-Control", Value: "no-store"}},
+							Remove: []string{"Server", "X-Powered-By"},
+						},
+					},
+					Auth: &v1alpha1.HostAuth{
+						Basic: &v1alpha1.HostAuthBasic{SecretName: "users"},
+					},
+					Rewrite: &v1alpha1.MiddlewareRewrite{Target: "/"},
+					Config: map[string]string{
+						"limit-rps":   "10",
+						"cors-enable": "true",
+					},
+				},
+			},
+			expected: map[string]*types.ResourceConfig{
+				"default/mw": {
+					Namespace: "default",
+					Name:      "mw",
+					Config: map[string]string{
+						"auth-secret":          "users",
+						"cors-enable":          "true",
+						"limit-rps":            "20",
+						"limit-whitelist":      "10.0.0.0/8",
+						"request-headers-del":  "X-Debug",
+						"request-headers-set":  "X-Env: prod\nX-Team: web",
+						"response-headers-add": "Cache-Control: no-store",
+						"response-headers-del": "Server,X-Powered-By",
+						"rewrite-target":       "/",
+					},
+				},
+			},
+			status: map[string]string{
+				"default/mw": "True/Accepted: Middleware configuration is valid",
+			},
+		},
+		// 2
+		{
+			middlewares: []string{"default/a", "default/b", "default/c", "other/d"},
+			specs: map[string]v1alpha1.MiddlewareSpec{
+				"default/a": {Middlewares: []string{"b", "c"}, Config: map[string]string{"timeout-server": "1m"}},
+				"default/b": {Middlewares: []string{"c"}, Config: map[string]string{"timeout-server": "2m", "cors-enable": "true"}},
+				"default/c": {Config: map[string]string{"timeout-server": "3m", "hsts": "false"}},
+				"other/d":   {Middlewares: []string{"b"}, Config: map[string]string{"hsts": "true"}},
+			},
+			expected: map[string]*types.ResourceConfig{
+				"default/a": {
+					Namespace: "default",
+					Name:      "a",
+					Config:    map[string]string{"timeout-server": "1m", "cors-enable": "true", "hsts": "false"},
+				},
+				"default/b": {
+					Namespace: "default",
+					Name:      "b",
+					Config:    map[string]string{"timeout-server": "2m", "cors-enable": "true", "hsts": "false"},
+				},
+				"default/c": {
+					Namespace: "default",
+					Name:      "c",
+					Config:    map[string]string{"timeout-server": "3m", "hsts": "false"},
+				},
+				"other/d": {
+					Namespace: "other",
+					Name:      "d",
+					Config:    map[string]string{"hsts": "true"},
+				},
+			},
+			status: map[string]string{
+				"default/a": "True/Accepted: Middleware configuration is valid",
+				"default/b": "True/Accepted: Middleware configuration is valid",
+				"default/c": "True/Accepted: Middleware configuration is valid",
+				"other/d":   "False/Invalid: ignoring invalid fields: spec.middlewares: Middleware 'b' not found: d -> b",
+			},
+			logging: `WARN Middleware other/d: ignoring invalid fields: spec.middlewares: Middleware 'b' not found: d -> b`,
+		},
+		// 3
+		{
+			middlewares: []string{"default/a", "default/b"},
+			specs: map[string]v1alpha1.MiddlewareSpec{
+				"default/a": {
+					Middlewares: []string{"b"},
+					Headers: &v1alpha1.MiddlewareHeaders{
+						Request: &v1alpha1.MiddlewareHeaderActions{
+							Set: []v1alpha1.MiddlewareHeader{
+								{Name: "X Env", Value: "prod"},
+								{Name: "X-Team", Value: "web\nX-Admin: true"},
+								{Name: "X-App", Value: "echo"},
+							},
+						},
+					},
+				},
+				"default/b": {Middlewares: []string{"a"}, Config: map[string]string{"hsts": "false"}},
+			},
+			expected: map[string]*types.ResourceConfig{
+				"default/a": {
+					Namespace: "default",
+					Name:      "a",
+					Config:    map[string]string{"request-headers-set": "X-App: echo", "hsts": "false"},
+				},
+				"default/b": {
+					Namespace: "default",
+					Name:      "b",
+					Config:    map[string]string{"request-headers-set": "X-App: echo", "hsts": "false"},
+				},
+			},
+			status: map[string]string{
+				"default/a": "False/Invalid: ignoring invalid fields: spec.headers.request.set: invalid header name: 'X Env'; spec.headers.request.set: header value should not have line breaks: 'X-Team'; spec.middlewares: circular reference: a -> b -> a",
+				"default/b": "False/Invalid: ignoring invalid fields: spec.middlewares: circular reference: b -> a -> b",
+			},
+			logging: `
+WARN Middleware default/a: ignoring invalid fields: spec.headers.request.set: invalid header name: 'X Env'; spec.headers.request.set: header value should not have line breaks: 'X-Team'; spec.middlewares: circular reference: a -> b -> a
+WARN Middleware default/b: ignoring invalid fields: spec.middlewares: circular reference: b -> a -> b`,
+		},
+		// 4
+		{
+			middlewares: []string{"default/mw"},
+			specs: map[string]v1alpha1.MiddlewareSpec{
+				"default/mw": {Rewrite: &v1alpha1.MiddlewareRewrite{Target: "/app"}},
+			},
+			partial: true,
+			expected: map[string]*types.ResourceConfig{
+				"default/mw": {
+					Namespace: "default",
+					Name:      "mw",
+					Config:    map[string]string{"rewrite-target": "/app"},
+				},
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		for _, name := range test.middlewares {
+			ns := strings.Split(name, "/")
+			c.cache.MwList = append(c.cache.MwList, &v1alpha1.Middleware{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: ns[0],
+					Name:      ns[1],
+				},
+				Spec: test.specs[name],
+			})
+		}
+		changed := &types.ChangedObjects{
+			NeedFullSync: !test.partial,
+		}
+		configs := NewMiddlewareConverter(&types.ConverterOptions{
+			Logger: c.logger,
+			Cache:  c.cache,
+		}, changed).Sync()
+		if !reflect.DeepEqual(configs, test.expected) {
+			t.Errorf("config differs on %d -- expected: %+v -- actual: %+v", i, test.expected, configs)
+		}
+		status := map[string]string{}
+		for name, cond := range c.cache.MwStatus {
+			status[name] = string(cond.Status) + "/" + cond.Reason + ": " + cond.Message
+		}
+		if test.status == nil {
+			test.status = map[string]string{}
+		}
+		if !reflect.DeepEqual(status, test.status) {
+			t.Errorf("status differs on %d -- expected: %+v -- actual: %+v", i, test.status, status)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
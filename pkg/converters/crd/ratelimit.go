@@ -0,0 +1,205 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// RateLimitConverter ...
+type RateLimitConverter interface {
+	Sync() map[string]*hatypes.RateLimit
+}
+
+// NewRateLimitConverter ...
+func NewRateLimitConverter(options *convtypes.ConverterOptions, changed *convtypes.ChangedObjects) RateLimitConverter {
+	return &rateLimitConverter{
+		logger:  options.Logger,
+		cache:   options.Cache,
+		changed: changed,
+	}
+}
+
+type rateLimitConverter struct {
+	logger  types.Logger
+	cache   convtypes.Cache
+	changed *convtypes.ChangedObjects
+}
+
+// Sync builds the rate limits of the RateLimit resources, indexed by their
+// name. RateLimits with an invalid rate are not added, invalid optional
+// fields are ignored. Changes in RateLimit resources start a full sync,
+// which is when their status are updated.
+func (c *rateLimitConverter) Sync() map[string]*hatypes.RateLimit {
+	rateLimits, err := c.cache.GetRateLimitList()
+	if err != nil {
+		c.logger.Warn("error reading RateLimit list: %v", err)
+		return nil
+	}
+	sort.Slice(rateLimits, func(i, j int) bool {
+		return rateLimits[i].Name < rateLimits[j].Name
+	})
+	limits := make(map[string]*hatypes.RateLimit, len(rateLimits))
+	for _, rateLimit := range rateLimits {
+		limit, errs := rateLimitConfig(rateLimit.Name, &rateLimit.Spec)
+		if limit != nil {
+			limits[rateLimit.Name] = limit
+		}
+		if !c.changed.NeedFullSync {
+			continue
+		}
+		var condition metav1.Condition
+		if limit == nil {
+			condition = newCondition(v1alpha1.ReasonInvalid, "rate limit not applied: "+strings.Join(errs, "; "))
+			c.logger.Warn("RateLimit %s: %s", rateLimit.Name, condition.Message)
+		} else if len(errs) > 0 {
+			condition = newCondition(v1alpha1.ReasonInvalid, "ignoring invalid fields: "+strings.Join(errs, "; "))
+			c.logger.Warn("RateLimit %s: %s", rateLimit.Name, condition.Message)
+		} else {
+			condition = newCondition(v1alpha1.ReasonAccepted, "RateLimit configuration is valid")
+		}
+		if err := c.cache.UpdateRateLimitStatus(rateLimit, condition); err != nil {
+			c.logger.Warn("error updating status of RateLimit %s: %v", rateLimit.Name, err)
+		}
+	}
+	return limits
+}
+
+var regexDenyStatus = regexp.MustCompile(`^(200|40[0-578]|41[03]|42[59]|50[0-4])$`)
+
+// minRateLimitExpire is the minimum time a counter without new requests is
+// kept in the stick table.
+const minRateLimitExpire = 60 * 1000
+
+// rateLimitConfig converts the spec of a RateLimit resource. limit is nil if
+// the rate is invalid. Burst extends the period, and the number of requests,
+// so the sustained rate is kept and up to burst requests above the rate are
+// accepted at once.
+func rateLimitConfig(name string, spec *v1alpha1.RateLimitSpec) (limit *hatypes.RateLimit, errs []string) {
+	b := &configBuilder{}
+	requests := int64(spec.Rate.Requests)
+	if requests < 1 {
+		b.invalid("rate.requests", "should not be lower than 1: %d", requests)
+	}
+	period := int64(1000)
+	if spec.Rate.Period != "" {
+		var err error
+		if period, err = parseMillis(spec.Rate.Period); err != nil {
+			b.invalid("rate.period", "%v", err)
+		}
+	}
+	if len(b.errs) > 0 {
+		return nil, b.errs
+	}
+	if burst := spec.Burst; burst != nil {
+		if *burst < 0 {
+			b.invalid("burst", "should not be lower than 0: %d", *burst)
+		} else if *burst > 0 {
+			period = period * (requests + int64(*burst)) / requests
+			requests += int64(*burst)
+		}
+	}
+	expire := period
+	if expire < minRateLimitExpire {
+		expire = minRateLimitExpire
+	}
+	limit = &hatypes.RateLimit{
+		Name:       name,
+		Requests:   int(requests),
+		Period:     formatMillis(period),
+		Expire:     formatMillis(expire),
+		DenyStatus: 429,
+	}
+	if key := spec.Key; key != nil && key.Header != "" {
+		if regexHeaderName.MatchString(key.Header) {
+			limit.Header = key.Header
+		} else {
+			b.invalid("key.header", "invalid header name: '%s'", key.Header)
+		}
+	}
+	if response := spec.Response; response != nil {
+		if status := response.Status; status != nil {
+			if regexDenyStatus.MatchString(strconv.Itoa(int(*status))) {
+				limit.DenyStatus = int(*status)
+			} else {
+				b.invalid("response.status", "unsupported status code: %d", *status)
+			}
+		}
+		for _, header := range response.Headers {
+			if !regexHeaderName.MatchString(header.Name) {
+				b.invalid("response.headers", "invalid header name: '%s'", header.Name)
+			} else if strings.ContainsAny(header.Value, "\r\n") {
+				b.invalid("response.headers", "header value should not have line breaks: '%s'", header.Name)
+			} else {
+				// values are used literally
+				value := strings.ReplaceAll(header.Value, "%", "%%")
+				value = "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+				limit.DenyHeaders = append(limit.DenyHeaders, hatypes.BackendHeader{
+					Name:  header.Name,
+					Value: value,
+				})
+			}
+		}
+	}
+	return limit, b.errs
+}
+
+var timeUnitMillis = map[string]int64{
+	"ms": 1,
+	"s":  1000,
+	"m":  60 * 1000,
+	"h":  60 * 60 * 1000,
+	"d":  24 * 60 * 60 * 1000,
+}
+
+// parseMillis parses a time in the HAProxy format, in milliseconds
+func parseMillis(value string) (int64, error) {
+	match := regexValidTime.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("invalid time format: %s", value)
+	}
+	unit, found := timeUnitMillis[match[1]]
+	if !found {
+		return 0, fmt.Errorf("time should not be lower than 1ms: %s", value)
+	}
+	number, err := strconv.ParseInt(strings.TrimSuffix(value, match[1]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time format: %s", value)
+	}
+	if number < 1 {
+		return 0, fmt.Errorf("time should not be lower than 1ms: %s", value)
+	}
+	return number * unit, nil
+}
+
+func formatMillis(millis int64) string {
+	if millis%1000 == 0 {
+		return strconv.FormatInt(millis/1000, 10) + "s"
+	}
+	return strconv.FormatInt(millis, 10) + "ms"
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+func TestRateLimitSync(t *testing.T) {
+	number := func(n int32) *int32 { return &n }
+	testCases := []struct {
+		specs    map[string]v1alpha1.RateLimitSpec
+		partial  bool
+		expected map[string]*hatypes.RateLimit
+		status   map[string]string
+		logging  string
+	}{
+		// 0
+		{
+			expected: map[string]*hatypes.RateLimit{},
+		},
+		// 1
+		{
+			specs: map[string]v1alpha1.RateLimitSpec{
+				"api": {Rate: v1alpha1.RateLimitRate{Requests: 10}},
+			},
+			expected: map[string]*hatypes.RateLimit{
+				"api": {Name: "api", Requests: 10, Period: "1s", Expire: "60s", DenyStatus: 429},
+			},
+			status: map[string]string{
+				"api": "True/Accepted: RateLimit configuration is valid",
+			},
+		},
+		// 2
+		{
+			specs: map[string]v1alpha1.RateLimitSpec{
+				"login": {
+					Key:   &v1alpha1.RateLimitKey{Header: "X-User"},
+					Rate:  v1alpha1.RateLimitRate{Requests: 5, Period: "1m"},
+					Burst: number(5),
+					Response: &v1alpha1.RateLimitResponse{
+						Status: number(403),
+						Headers: []v1alpha1.MiddlewareHeader{
+							{Name: "Retry-After", Value: "60"},
+							{Name: "X-Reason", Value: "50% it's over"},
+						},
+					},
+				},
+				"web": {Rate: v1alpha1.RateLimitRate{Requests: 3, Period: "500ms"}, Burst: number(1)},
+			},
+			expected: map[string]*hatypes.RateLimit{
+				"login": {
+					Name:       "login",
+					Header:     "X-User",
+					Requests:   10,
+					Period:     "120s",
+					Expire:     "120s",
+					DenyStatus: 403,
+					DenyHeaders: []hatypes.BackendHeader{
+						{Name: "Retry-After", Value: "'60'"},
+						{Name: "X-Reason", Value: `'50%% it'\''s over'`},
+					},
+				},
+				"web": {Name: "web", Requests: 4, Period: "666ms", Expire: "60s", DenyStatus: 429},
+			},
+			status: map[string]string{
+				"login": "True/Accepted: RateLimit configuration is valid",
+				"web":   "True/Accepted: RateLimit configuration is valid",
+			},
+		},
+		// 3
+		{
+			specs: map[string]v1alpha1.RateLimitSpec{
+				"bad":  {Rate: v1alpha1.RateLimitRate{Requests: 0, Period: "1h"}},
+				"slow": {Rate: v1alpha1.RateLimitRate{Requests: 10, Period: "10us"}},
+				"opt": {
+					Key:      &v1alpha1.RateLimitKey{Header: "X User"},
+					Rate:     v1alpha1.RateLimitRate{Requests: 10, Period: "10s"},
+					Burst:    number(-1),
+					Response: &v1alpha1.RateLimitResponse{Status: number(302)},
+				},
+			},
+			expected: map[string]*hatypes.RateLimit{
+				"opt": {Name: "opt", Requests: 10, Period: "10s", Expire: "60s", DenyStatus: 429},
+			},
+			status: map[string]string{
+				"bad":  "False/Invalid: rate limit not applied: spec.rate.requests: should not be lower than 1: 0",
+				"opt":  "False/Invalid: ignoring invalid fields: spec.burst: should not be lower than 0: -1; spec.key.header: invalid header name: 'X User'; spec.response.status: unsupported status code: 302",
+				"slow": "False/Invalid: rate limit not applied: spec.rate.period: time should not be lower than 1ms: 10us",
+			},
+			logging: `
+WARN RateLimit bad: rate limit not applied: spec.rate.requests: should not be lower than 1: 0
+WARN RateLimit opt: ignoring invalid fields: spec.burst: should not be lower than 0: -1; spec.key.header: invalid header name: 'X User'; spec.response.status: unsupported status code: 302
+WARN RateLimit slow: rate limit not applied: spec.rate.period: time should not be lower than 1ms: 10us`,
+		},
+		// 4
+		{
+			specs: map[string]v1alpha1.RateLimitSpec{
+				"api": {Rate: v1alpha1.RateLimitRate{Requests: 0}},
+			},
+			partial:  true,
+			expected: map[string]*hatypes.RateLimit{},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		for name, spec := range test.specs {
+			c.cache.RLimitList = append(c.cache.RLimitList, &v1alpha1.RateLimit{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec:       spec,
+			})
+		}
+		changed := &types.ChangedObjects{
+			NeedFullSync: !test.partial,
+		}
+		limits := NewRateLimitConverter(&types.ConverterOptions{
+			Logger: c.logger,
+			Cache:  c.cache,
+		}, changed).Sync()
+		if !reflect.DeepEqual(limits, test.expected) {
+			t.Errorf("rate limits differ on %d -- expected: %+v -- actual: %+v", i, test.expected, limits)
+		}
+		status := map[string]string{}
+		for name, cond := range c.cache.RLimitStatus {
+			status[name] = string(cond.Status) + "/" + cond.Reason + ": " + cond.Message
+		}
+		if test.status == nil {
+			test.status = map[string]string{}
+		}
+		if !reflect.DeepEqual(status, test.status) {
+			t.Errorf("status differs on %d -- expected: %+v -- actual: %+v", i, test.status, status)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	convutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/utils"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// TCPServiceConverter ...
+type TCPServiceConverter interface {
+	NeedFullSync() bool
+	Sync(full bool)
+}
+
+// NewTCPServiceConverter ...
+func NewTCPServiceConverter(options *convtypes.ConverterOptions, haproxy haproxy.Config, changed *convtypes.ChangedObjects) TCPServiceConverter {
+	return &tcpServiceConverter{
+		logger:  options.Logger,
+		cache:   options.Cache,
+		haproxy: haproxy,
+		changed: changed,
+	}
+}
+
+type tcpServiceConverter struct {
+	logger  types.Logger
+	cache   convtypes.Cache
+	haproxy haproxy.Config
+	changed *convtypes.ChangedObjects
+}
+
+var regexValidTime = regexp.MustCompile(`^[0-9]+(us|ms|s|m|h|d)$`)
+
+// NeedFullSync is true if a TCPService changed, since the conflicts of
+// public ports need to be recalculated, or if the tcp-services configmap
+// changed, since its ports have precedence over the TCPService resources.
+func (c *tcpServiceConverter) NeedFullSync() bool {
+	ch := c.changed
+	tcpServicesChanged := len(ch.TCPServicesDel)+len(ch.TCPServicesUpd)+len(ch.TCPServicesAdd) > 0
+	tcpConfigMapChanged := ch.TCPConfigMapDataNew != nil && !reflect.DeepEqual(ch.TCPConfigMapDataCur, ch.TCPConfigMapDataNew)
+	return tcpServicesChanged || tcpConfigMapChanged
+}
+
+func (c *tcpServiceConverter) Sync(full bool) {
+	tcpServices, err := c.cache.GetTCPServiceList()
+	if err != nil {
+		c.logger.Warn("error reading TCPService list: %v", err)
+		return
+	}
+	sort.Slice(tcpServices, func(i, j int) bool {
+		svc1 := tcpServices[i]
+		svc2 := tcpServices[j]
+		if !svc1.CreationTimestamp.Equal(&svc2.CreationTimestamp) {
+			return svc1.CreationTimestamp.Before(&svc2.CreationTimestamp)
+		}
+		return svc1.Namespace+"/"+svc1.Name < svc2.Namespace+"/"+svc2.Name
+	})
+	var dirty map[string]bool
+	if !full {
+		dirty = c.dirtyTCPServices(tcpServices)
+		if len(dirty) == 0 {
+			return
+		}
+	}
+	configMapPorts := c.configMapPorts()
+	owners := map[int32]string{}
+	var count int
+	for _, svc := range tcpServices {
+		name := svc.Namespace + "/" + svc.Name
+		port := svc.Spec.Port
+		owner, conflict := owners[port]
+		if !conflict {
+			owners[port] = name
+		}
+		if !full && !dirty[name] {
+			continue
+		}
+		count++
+		var condition metav1.Condition
+		switch {
+		case configMapPorts[port]:
+			condition = c.newCondition(v1alpha1.ReasonConflict, "port %d is already used by the tcp-services configmap", port)
+		case conflict:
+			condition = c.newCondition(v1alpha1.ReasonConflict, "port %d is already used by TCPService %s", port, owner)
+		default:
+			c.haproxy.TCPBackends().Remove(int(port))
+			if err := c.syncTCPService(svc); err != nil {
+				condition = c.newCondition(v1alpha1.ReasonInvalid, "%v", err)
+			} else {
+				condition = c.newCondition(v1alpha1.ReasonAccepted, "TCPService successfully added on port %d", port)
+			}
+		}
+		if condition.Reason != v1alpha1.ReasonAccepted {
+			c.logger.Warn("skipping TCPService %s: %s", name, condition.Message)
+		}
+		if err := c.cache.UpdateTCPServiceStatus(svc, condition); err != nil {
+			c.logger.Warn("error updating status of TCPService %s: %v", name, err)
+		}
+	}
+	if !full && count > 0 {
+		c.logger.InfoV(2, "syncing %d TCP service(s) from TCPService resources", count)
+	}
+}
+
+func (c *tcpServiceConverter) newCondition(reason, format string, args ...interface{}) metav1.Condition {
+	return newCondition(reason, fmt.Sprintf(format, args...))
+}
+
+// dirtyTCPServices lists the TCPService resources whose target service,
+// endpoints or secrets were changed since the last sync. Changes in the
+// TCPService resources themselves are handled by a full sync.
+func (c *tcpServiceConverter) dirtyTCPServices(tcpServices []*v1alpha1.TCPService) map[string]bool {
+	dirtyObjs := map[string]bool{}
+	for _, svc := range c.changed.ServicesDel {
+		dirtyObjs[svc.Namespace+"/"+svc.Name] = true
+	}
+	for _, svc := range c.changed.ServicesUpd {
+		dirtyObjs[svc.Namespace+"/"+svc.Name] = true
+	}
+	for _, svc := range c.changed.ServicesAdd {
+		dirtyObjs[svc.Namespace+"/"+svc.Name] = true
+	}
+	for _, ep := range c.changed.EndpointsNew {
+		dirtyObjs[convutils.EndpointSliceService(ep)] = true
+	}
+	for _, secret := range c.changed.SecretsDel {
+		dirtyObjs[secret.Namespace+"/"+secret.Name] = true
+	}
+	for _, secret := range c.changed.SecretsUpd {
+		dirtyObjs[secret.Namespace+"/"+secret.Name] = true
+	}
+	for _, secret := range c.changed.SecretsAdd {
+		dirtyObjs[secret.Namespace+"/"+secret.Name] = true
+	}
+	if len(dirtyObjs) == 0 {
+		return nil
+	}
+	dirty := map[string]bool{}
+	for _, svc := range tcpServices {
+		refs := []string{svc.Spec.Backend.Name}
+		if tls := svc.Spec.TLS; tls != nil {
+			refs = append(refs, tls.SecretName, tls.CASecretName)
+		}
+		for _, ref := range refs {
+			if ref != "" && dirtyObjs[svc.Namespace+"/"+ref] {
+				dirty[svc.Namespace+"/"+svc.Name] = true
+			}
+		}
+	}
+	return dirty
+}
+
+func (c *tcpServiceConverter) configMapPorts() map[int32]bool {
+	tcpservices := c.changed.TCPConfigMapDataNew
+	if tcpservices == nil {
+		tcpservices = c.changed.TCPConfigMapDataCur
+	}
+	ports := make(map[int32]bool, len(tcpservices))
+	for k := range tcpservices {
+		if port, err := strconv.Atoi(k); err == nil {
+			ports[int32(port)] = true
+		}
+	}
+	return ports
+}
+
+func (c *tcpServiceConverter) syncTCPService(svc *v1alpha1.TCPService) error {
+	spec := &svc.Spec
+	if spec.Port <= 0 || spec.Port > 65535 {
+		return fmt.Errorf("invalid port number: %d", spec.Port)
+	}
+	service, err := c.cache.GetService(svc.Namespace, spec.Backend.Name)
+	if err != nil {
+		return err
+	}
+	svcport := convutils.FindServicePort(service, spec.Backend.Port.String())
+	if svcport == nil {
+		return fmt.Errorf("port not found: %s:%s", spec.Backend.Name, spec.Backend.Port.String())
+	}
+	addrs, _, err := convutils.CreateEndpoints(c.cache, service, svcport)
+	if err != nil {
+		return err
+	}
+	checkInterval := "2s"
+	if spec.CheckInterval == "-" {
+		checkInterval = ""
+	} else if spec.CheckInterval != "" {
+		if !regexValidTime.MatchString(spec.CheckInterval) {
+			return fmt.Errorf("invalid check interval: %s", spec.CheckInterval)
+		}
+		checkInterval = spec.CheckInterval
+	}
+	checkRules, err := convutils.ParseTCPCheckSequence(spec.CheckSequence)
+	if err != nil {
+		return err
+	}
+	var proxyProt hatypes.TCPProxyProt
+	if pp := spec.ProxyProtocol; pp != nil {
+		proxyProt.Decode = pp.Accept
+		switch strings.ToLower(pp.Send) {
+		case "":
+		case "v1", "v2":
+			proxyProt.EncodeVersion = strings.ToLower(pp.Send)
+		default:
+			return fmt.Errorf("invalid proxy protocol version: %s", pp.Send)
+		}
+	}
+	for _, src := range spec.AllowList {
+		if _, _, err := net.ParseCIDR(src); err != nil && net.ParseIP(src) == nil {
+			return fmt.Errorf("invalid IP or CIDR on allow list: %s", src)
+		}
+	}
+	var ssl hatypes.TCPSSL
+	if tls := spec.TLS; tls != nil {
+		crtfile, err := c.cache.GetTLSSecretPath(svc.Namespace, tls.SecretName, convtypes.TrackingTarget{})
+		if err != nil {
+			return err
+		}
+		ssl.Filename = crtfile.Filename
+		if tls.CASecretName != "" {
+			cafile, crlfile, err := c.cache.GetCASecretPath(svc.Namespace, tls.CASecretName, convtypes.TrackingTarget{})
+			if err != nil {
+				return err
+			}
+			ssl.CAFilename = cafile.Filename
+			ssl.CRLFilename = crlfile.Filename
+		}
+	}
+	servicename := fmt.Sprintf("%s_%s", service.Namespace, service.Name)
+	backend := c.haproxy.TCPBackends().Acquire(servicename, int(spec.Port))
+	for _, addr := range addrs {
+		backend.AddEndpoint(addr.IP, addr.Port)
+	}
+	backend.AllowList = spec.AllowList
+	backend.CheckInterval = checkInterval
+	backend.CheckRules = checkRules
+	backend.ProxyProt = proxyProt
+	backend.SSL = ssl
+	return nil
+}
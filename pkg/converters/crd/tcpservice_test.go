@@ -0,0 +1,451 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/tracker"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestTCPServiceSync(t *testing.T) {
+	testCases := []struct {
+		svcmock        map[string]string
+		secretCertMock map[string]string
+		secretCAMock   map[string]string
+		configmap      map[string]string
+		tcpsvcs        map[string]v1alpha1.TCPServiceSpec
+		expected       []*hatypes.TCPBackend
+		status         map[string]string
+		logging        string
+	}{
+		// 0
+		{
+			tcpsvcs: map[string]v1alpha1.TCPServiceSpec{},
+		},
+		// 1
+		{
+			svcmock: map[string]string{"default/pg:5432": "172.17.0.101"},
+			tcpsvcs: map[string]v1alpha1.TCPServiceSpec{
+				"default/pg": {Port: 15432, Backend: backend("pg", 5432)},
+			},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 15432,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					},
+					CheckInterval: "2s",
+				},
+			},
+			status: map[string]string{
+				"default/pg": "True/Accepted: TCPService successfully added on port 15432",
+			},
+		},
+		// 2
+		{
+			tcpsvcs: map[string]v1alpha1.TCPServiceSpec{
+				"default/pg": {Port: 15432, Backend: backend("pg", 5432)},
+			},
+			status: map[string]string{
+				"default/pg": "False/Invalid: service not found: 'pg'",
+			},
+			logging: `WARN skipping TCPService default/pg: service not found: 'pg'`,
+		},
+		// 3
+		{
+			svcmock: map[string]string{"default/pg:5432": "172.17.0.101"},
+			tcpsvcs: map[string]v1alpha1.TCPServiceSpec{
+				"default/pg": {Port: 15432, Backend: backend("pg", 15432)},
+			},
+			status: map[string]string{
+				"default/pg": "False/Invalid: port not found: pg:15432",
+			},
+			logging: `WARN skipping TCPService default/pg: port not found: pg:15432`,
+		},
+		// 4
+		{
+			svcmock: map[string]string{
+				"default/pg:5432":  "172.17.0.101",
+				"default/pg2:5432": "172.17.0.102",
+			},
+			tcpsvcs: map[string]v1alpha1.TCPServiceSpec{
+				"default/pg":  {Port: 15432, Backend: backend("pg", 5432)},
+				"default/pg2": {Port: 15432, Backend: backend("pg2", 5432)},
+			},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 15432,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					},
+					CheckInterval: "2s",
+				},
+			},
+			status: map[string]string{
+				"default/pg":  "True/Accepted: TCPService successfully added on port 15432",
+				"default/pg2": "False/Conflict: port 15432 is already used by TCPService default/pg",
+			},
+			logging: `WARN skipping TCPService default/pg2: port 15432 is already used by TCPService default/pg`,
+		},
+		// 5
+		{
+			svcmock:   map[string]string{"default/pg:5432": "172.17.0.101"},
+			configmap: map[string]string{"15432": "default/other:5432"},
+			tcpsvcs: map[string]v1alpha1.TCPServiceSpec{
+				"default/pg": {Port: 15432, Backend: backend("pg", 5432)},
+			},
+			status: map[string]string{
+				"default/pg": "False/Conflict: port 15432 is already used by the tcp-services configmap",
+			},
+			logging: `WARN skipping TCPService default/pg: port 15432 is already used by the tcp-services configmap`,
+		},
+		// 6
+		{
+			svcmock: map[string]string{"default/pg:5432": "172.17.0.101"},
+			tcpsvcs: map[string]v1alpha1.TCPServiceSpec{
+				"default/pg": {
+					Port:          15432,
+					Backend:       backend("pg", 5432),
+					ProxyProtocol: &v1alpha1.TCPServiceProxyProtocol{Accept: true, Send: "v2"},
+					AllowList:     []string{"10.0.0.0/8", "192.168.0.1"},
+					CheckInterval: "-",
+				},
+			},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 15432,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					},
+					AllowList: []string{"10.0.0.0/8", "192.168.0.1"},
+					ProxyProt: hatypes.TCPProxyProt{Decode: true, EncodeVersion: "v2"},
+				},
+			},
+			status: map[string]string{
+				"default/pg": "True/Accepted: TCPService successfully added on port 15432",
+			},
+		},
+		// 7
+		{
+			svcmock: map[string]string{"default/pg:5432": "172.17.0.101"},
+			tcpsvcs: map[string]v1alpha1.TCPServiceSpec{
+				"default/pg1": {Port: 15432, Backend: backend("pg", 5432), AllowList: []string{"10.0.0.0/33"}},
+				"default/pg2": {Port: 15433, Backend: backend("pg", 5432), CheckInterval: "2z"},
+				"default/pg3": {Port: 15434, Backend: backend("pg", 5432), ProxyProtocol: &v1alpha1.TCPServiceProxyProtocol{Send: "v3"}},
+				"default/pg4": {Port: 0, Backend: backend("pg", 5432)},
+			},
+			status: map[string]string{
+				"default/pg1": "False/Invalid: invalid IP or CIDR on allow list: 10.0.0.0/33",
+				"default/pg2": "False/Invalid: invalid check interval: 2z",
+				"default/pg3": "False/Invalid: invalid proxy protocol version: v3",
+				"default/pg4": "False/Invalid: invalid port number: 0",
+			},
+			logging: `
+WARN skipping TCPService default/pg1: invalid IP or CIDR on allow list: 10.0.0.0/33
+WARN skipping TCPService default/pg2: invalid check interval: 2z
+WARN skipping TCPService default/pg3: invalid proxy protocol version: v3
+WARN skipping TCPService default/pg4: invalid port number: 0`,
+		},
+		// 8
+		{
+			svcmock:        map[string]string{"default/pg:5432": "172.17.0.101"},
+			secretCertMock: map[string]string{"default/pg-tls": "/var/haproxy/ssl/crt.pem"},
+			secretCAMock:   map[string]string{"default/pg-ca": "/var/haproxy/ssl/ca.pem"},
+			tcpsvcs: map[string]v1alpha1.TCPServiceSpec{
+				"default/pg": {
+					Port:    15432,
+					Backend: backend("pg", 5432),
+					TLS:     &v1alpha1.TCPServiceTLS{SecretName: "pg-tls", CASecretName: "pg-ca"},
+				},
+			},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 15432,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					},
+					CheckInterval: "2s",
+					SSL: hatypes.TCPSSL{
+						Filename:   "/var/haproxy/ssl/crt.pem",
+						CAFilename: "/var/haproxy/ssl/ca.pem",
+					},
+				},
+			},
+			status: map[string]string{
+				"default/pg": "True/Accepted: TCPService successfully added on port 15432",
+			},
+		},
+		// 9
+		{
+			svcmock: map[string]string{"default/pg:5432": "172.17.0.101"},
+			tcpsvcs: map[string]v1alpha1.TCPServiceSpec{
+				"default/pg": {
+					Port:    15432,
+					Backend: backend("pg", 5432),
+					TLS:     &v1alpha1.TCPServiceTLS{SecretName: "pg-tls"},
+				},
+			},
+			status: map[string]string{
+				"default/pg": "False/Invalid: secret not found: 'default/pg-tls'",
+			},
+			logging: `WARN skipping TCPService default/pg: secret not found: 'default/pg-tls'`,
+		},
+		// 10
+		{
+			svcmock: map[string]string{"default/redis:6379": "172.17.0.101"},
+			tcpsvcs: map[string]v1alpha1.TCPServiceSpec{
+				"default/redis": {
+					Port:          16379,
+					Backend:       backend("redis", 6379),
+					CheckSequence: []string{`send PING\r\n`, "expect string +PONG"},
+				},
+			},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_redis",
+					Port: 16379,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 6379},
+					},
+					CheckInterval: "2s",
+					CheckRules: []*hatypes.TCPCheckRule{
+						{Action: "send", Value: `PING\r\n`},
+						{Action: "expect", Match: "string", Value: "+PONG"},
+					},
+				},
+			},
+			status: map[string]string{
+				"default/redis": "True/Accepted: TCPService successfully added on port 16379",
+			},
+		},
+		// 11
+		{
+			svcmock: map[string]string{"default/redis:6379": "172.17.0.101"},
+			tcpsvcs: map[string]v1alpha1.TCPServiceSpec{
+				"default/redis": {
+					Port:          16379,
+					Backend:       backend("redis", 6379),
+					CheckSequence: []string{"connect", "expect string +PONG"},
+				},
+			},
+			status: map[string]string{
+				"default/redis": "False/Invalid: unsupported tcp check action 'connect': connect",
+			},
+			logging: `WARN skipping TCPService default/redis: unsupported tcp check action 'connect': connect`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.createServices(test.svcmock)
+		c.createTCPServices(test.tcpsvcs)
+		c.cache.SecretTLSPath = test.secretCertMock
+		c.cache.SecretCAPath = test.secretCAMock
+		NewTCPServiceConverter(&types.ConverterOptions{
+			Logger: c.logger,
+			Cache:  c.cache,
+		}, c.haproxy, &types.ChangedObjects{
+			TCPConfigMapDataNew: test.configmap,
+		}).Sync(true)
+		c.compareBackends(i, test.expected)
+		c.compareStatus(i, test.status)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestTCPServiceSyncPartial(t *testing.T) {
+	testCases := []struct {
+		svcmock  map[string]string
+		tcpsvcs  map[string]v1alpha1.TCPServiceSpec
+		epUpd    map[string]string
+		expected []*hatypes.TCPBackend
+		logging  string
+	}{
+		// 0
+		{
+			svcmock: map[string]string{
+				"default/pg:5432":     "172.17.0.101",
+				"default/sendmail:25": "172.17.0.201",
+			},
+			tcpsvcs: map[string]v1alpha1.TCPServiceSpec{
+				"default/pg":       {Port: 15432, Backend: backend("pg", 5432)},
+				"default/sendmail": {Port: 10025, Backend: backend("sendmail", 25)},
+			},
+			epUpd: map[string]string{
+				"default/pg:5432": "172.17.0.102",
+			},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 15432,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.102", Port: 5432},
+					},
+					CheckInterval: "2s",
+				},
+				{
+					Name: "default_sendmail",
+					Port: 10025,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.201", Port: 25},
+					},
+					CheckInterval: "2s",
+				},
+			},
+			logging: `INFO-V(2) syncing 1 TCP service(s) from TCPService resources`,
+		},
+		// 1
+		{
+			svcmock: map[string]string{
+				"default/pg:5432": "172.17.0.101",
+			},
+			tcpsvcs: map[string]v1alpha1.TCPServiceSpec{
+				"default/pg": {Port: 15432, Backend: backend("pg", 5432)},
+			},
+			epUpd: map[string]string{
+				"default/other:8080": "172.17.0.151",
+			},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 15432,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					},
+					CheckInterval: "2s",
+				},
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.createServices(test.svcmock)
+		c.createTCPServices(test.tcpsvcs)
+		options := &types.ConverterOptions{
+			Logger: c.logger,
+			Cache:  c.cache,
+		}
+		NewTCPServiceConverter(options, c.haproxy, &types.ChangedObjects{}).Sync(true)
+		c.haproxy.Commit()
+		changed := &types.ChangedObjects{}
+		for svckey, endpoints := range test.epUpd {
+			svcport := strings.Split(svckey, ":")
+			_, ep := conv_helper.CreateService(svcport[0], svcport[1], endpoints)
+			c.cache.EpList[svcport[0]] = ep
+			changed.EndpointsNew = append(changed.EndpointsNew, ep)
+		}
+		NewTCPServiceConverter(options, c.haproxy, changed).Sync(false)
+		c.compareBackends(i, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+type testConfig struct {
+	t       *testing.T
+	haproxy haproxy.Config
+	logger  *types_helper.LoggerMock
+	cache   *conv_helper.CacheMock
+}
+
+func setup(t *testing.T) *testConfig {
+	logger := types_helper.NewLoggerMock(t)
+	tracker := tracker.NewTracker()
+	c := &testConfig{
+		t:       t,
+		logger:  logger,
+		cache:   conv_helper.NewCacheMock(tracker),
+		haproxy: haproxy.CreateInstance(logger, haproxy.InstanceOptions{}).Config(),
+	}
+	return c
+}
+
+func (c *testConfig) teardown() {
+	c.logger.CompareLogging("")
+}
+
+func backend(name string, port int) v1alpha1.TCPServiceBackend {
+	return v1alpha1.TCPServiceBackend{Name: name, Port: intstr.FromInt(port)}
+}
+
+func (c *testConfig) createServices(svcmock map[string]string) {
+	for svckey, endpoints := range svcmock {
+		svcport := strings.Split(svckey, ":")
+		svc, ep := conv_helper.CreateService(svcport[0], svcport[1], endpoints)
+		c.cache.SvcList = append(c.cache.SvcList, svc)
+		c.cache.EpList[svcport[0]] = ep
+	}
+}
+
+// createTCPServices adds the resources with the same creation timestamp,
+// so the ordering falls back to the namespace and name
+func (c *testConfig) createTCPServices(tcpsvcs map[string]v1alpha1.TCPServiceSpec) {
+	created := metav1.NewTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	for name, spec := range tcpsvcs {
+		nsname := strings.Split(name, "/")
+		c.cache.TCPSvcList = append(c.cache.TCPSvcList, &v1alpha1.TCPService{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         nsname[0],
+				Name:              nsname[1],
+				CreationTimestamp: created,
+			},
+			Spec: spec,
+		})
+	}
+}
+
+func (c *testConfig) compareBackends(i int, expected []*hatypes.TCPBackend) {
+	backends := c.haproxy.TCPBackends().BuildSortedItems()
+	for _, b := range backends {
+		for _, ep := range b.Endpoints {
+			ep.Target = ""
+		}
+	}
+	if !reflect.DeepEqual(backends, expected) {
+		c.t.Errorf("backend differs on %d -- expected: %+v -- actual: %+v", i, expected, backends)
+	}
+}
+
+func (c *testConfig) compareStatus(i int, expected map[string]string) {
+	status := map[string]string{}
+	for name, cond := range c.cache.TCPSvcStatus {
+		status[name] = string(cond.Status) + "/" + cond.Reason + ": " + cond.Message
+	}
+	if expected == nil {
+		expected = map[string]string{}
+	}
+	if !reflect.DeepEqual(status, expected) {
+		c.t.Errorf("status differs on %d -- expected: %+v -- actual: %+v", i, expected, status)
+	}
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	convutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/utils"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// UDPServiceConverter ...
+type UDPServiceConverter interface {
+	NeedFullSync() bool
+	Sync(full bool)
+}
+
+// NewUDPServiceConverter ...
+func NewUDPServiceConverter(options *convtypes.ConverterOptions, haproxy haproxy.Config, changed *convtypes.ChangedObjects) UDPServiceConverter {
+	return &udpServiceConverter{
+		logger:  options.Logger,
+		cache:   options.Cache,
+		haproxy: haproxy,
+		changed: changed,
+	}
+}
+
+type udpServiceConverter struct {
+	logger  types.Logger
+	cache   convtypes.Cache
+	haproxy haproxy.Config
+	changed *convtypes.ChangedObjects
+}
+
+var regexDgramBind = regexp.MustCompile(`^(([0-9.]+|\[[0-9A-Fa-f:.]+\])?:)?([0-9]{1,5})$`)
+
+// NeedFullSync is true if an UDPService changed, since the conflicts of
+// public ports need to be recalculated.
+func (c *udpServiceConverter) NeedFullSync() bool {
+	ch := c.changed
+	return len(ch.UDPServicesDel)+len(ch.UDPServicesUpd)+len(ch.UDPServicesAdd) > 0
+}
+
+// Sync builds the log-forward sections of the UDPService resources. haproxy
+// only forwards syslog messages over UDP, so UDP services are log forwarders
+// that load balance the messages between the endpoints of the target
+// service. The sections are part of the global config, so all the services
+// are rebuilt whenever one of them is dirty, and the status of the dirty
+// ones are updated.
+func (c *udpServiceConverter) Sync(full bool) {
+	udpServices, err := c.cache.GetUDPServiceList()
+	if err != nil {
+		c.logger.Warn("error reading UDPService list: %v", err)
+		return
+	}
+	sort.Slice(udpServices, func(i, j int) bool {
+		svc1 := udpServices[i]
+		svc2 := udpServices[j]
+		if !svc1.CreationTimestamp.Equal(&svc2.CreationTimestamp) {
+			return svc1.CreationTimestamp.Before(&svc2.CreationTimestamp)
+		}
+		return svc1.Namespace+"/"+svc1.Name < svc2.Namespace+"/"+svc2.Name
+	})
+	var dirty map[string]bool
+	if !full {
+		dirty = c.dirtyUDPServices(udpServices)
+		if len(dirty) == 0 {
+			return
+		}
+	}
+	global := c.haproxy.Global()
+	logForwardPorts := c.logForwardPorts()
+	owners := map[int32]string{}
+	var services []*hatypes.UDPService
+	var count int
+	for _, svc := range udpServices {
+		name := svc.Namespace + "/" + svc.Name
+		port := svc.Spec.Port
+		owner, conflict := owners[port]
+		if !conflict {
+			owners[port] = name
+		}
+		var condition metav1.Condition
+		switch {
+		case logForwardPorts[port]:
+			condition = c.newCondition(v1alpha1.ReasonConflict, "port %d is already used by the log-forward configuration", port)
+		case conflict:
+			condition = c.newCondition(v1alpha1.ReasonConflict, "port %d is already used by UDPService %s", port, owner)
+		default:
+			if udpService, err := c.syncUDPService(svc, global.Bind.TCPBindIP); err != nil {
+				condition = c.newCondition(v1alpha1.ReasonInvalid, "%v", err)
+			} else {
+				services = append(services, udpService)
+				condition = c.newCondition(v1alpha1.ReasonAccepted, "UDPService successfully added on port %d", port)
+			}
+		}
+		if !full && !dirty[name] {
+			continue
+		}
+		count++
+		if condition.Reason != v1alpha1.ReasonAccepted {
+			c.logger.Warn("skipping UDPService %s: %s", name, condition.Message)
+		}
+		if err := c.cache.UpdateUDPServiceStatus(svc, condition); err != nil {
+			c.logger.Warn("error updating status of UDPService %s: %v", name, err)
+		}
+	}
+	global.UDPServices = services
+	if !full && count > 0 {
+		c.logger.InfoV(2, "syncing %d UDP service(s) from UDPService resources", count)
+	}
+}
+
+func (c *udpServiceConverter) newCondition(reason, format string, args ...interface{}) metav1.Condition {
+	return newCondition(reason, fmt.Sprintf(format, args...))
+}
+
+// dirtyUDPServices lists the UDPService resources whose target service or
+// endpoints were changed since the last sync. Changes in the UDPService
+// resources themselves are handled by a full sync.
+func (c *udpServiceConverter) dirtyUDPServices(udpServices []*v1alpha1.UDPService) map[string]bool {
+	dirtyObjs := map[string]bool{}
+	for _, svc := range c.changed.ServicesDel {
+		dirtyObjs[svc.Namespace+"/"+svc.Name] = true
+	}
+	for _, svc := range c.changed.ServicesUpd {
+		dirtyObjs[svc.Namespace+"/"+svc.Name] = true
+	}
+	for _, svc := range c.changed.ServicesAdd {
+		dirtyObjs[svc.Namespace+"/"+svc.Name] = true
+	}
+	for _, ep := range c.changed.EndpointsNew {
+		dirtyObjs[convutils.EndpointSliceService(ep)] = true
+	}
+	if len(dirtyObjs) == 0 {
+		return nil
+	}
+	dirty := map[string]bool{}
+	for _, svc := range udpServices {
+		if dirtyObjs[svc.Namespace+"/"+svc.Spec.Backend.Name] {
+			dirty[svc.Namespace+"/"+svc.Name] = true
+		}
+	}
+	return dirty
+}
+
+// logForwardPorts lists the UDP ports used by the log-forward global
+// configuration key, they have precedence over the UDPService resources.
+func (c *udpServiceConverter) logForwardPorts() map[int32]bool {
+	ports := map[int32]bool{}
+	for _, fwd := range c.haproxy.Global().LogForward {
+		for _, bind := range fwd.DgramBinds {
+			if i := strings.LastIndex(bind, ":"); i >= 0 {
+				if port, err := strconv.Atoi(bind[i+1:]); err == nil {
+					ports[int32(port)] = true
+				}
+			}
+		}
+	}
+	return ports
+}
+
+func (c *udpServiceConverter) syncUDPService(svc *v1alpha1.UDPService, bindIP string) (*hatypes.UDPService, error) {
+	spec := &svc.Spec
+	if spec.Port <= 0 || spec.Port > 65535 {
+		return nil, fmt.Errorf("invalid port number: %d", spec.Port)
+	}
+	dgramBinds := []string{fmt.Sprintf("udp@%s:%d", bindIP, spec.Port)}
+	for _, bind := range spec.DgramBinds {
+		match := regexDgramBind.FindStringSubmatch(bind)
+		if match == nil {
+			return nil, fmt.Errorf("invalid dgram bind: %s", bind)
+		}
+		addr := match[2]
+		if match[1] == "" {
+			addr = bindIP
+		}
+		dgramBinds = append(dgramBinds, "udp@"+addr+":"+match[3])
+	}
+	service, err := c.cache.GetService(svc.Namespace, spec.Backend.Name)
+	if err != nil {
+		return nil, err
+	}
+	svcport := convutils.FindServicePort(service, spec.Backend.Port.String())
+	if svcport == nil {
+		return nil, fmt.Errorf("port not found: %s:%s", spec.Backend.Name, spec.Backend.Port.String())
+	}
+	if svcport.Protocol != api.ProtocolUDP {
+		return nil, fmt.Errorf("port %s:%s does not use the UDP protocol", spec.Backend.Name, spec.Backend.Port.String())
+	}
+	addrs, _, err := convutils.CreateEndpoints(c.cache, service, svcport)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("service %s does not have ready endpoints", spec.Backend.Name)
+	}
+	targets := make([]string, len(addrs))
+	for i, addr := range addrs {
+		targets[i] = "udp@" + net.JoinHostPort(addr.IP, strconv.Itoa(addr.Port))
+	}
+	sort.Strings(targets)
+	if len(targets) > 1 {
+		// each endpoint receives one of every len(targets) messages
+		for i := range targets {
+			targets[i] += fmt.Sprintf(" sample %d:%d", i+1, len(targets))
+		}
+	}
+	return &hatypes.UDPService{
+		Name:       fmt.Sprintf("%s_%s", svc.Namespace, svc.Name),
+		Port:       int(spec.Port),
+		DgramBinds: dgramBinds,
+		Targets:    targets,
+	}, nil
+}
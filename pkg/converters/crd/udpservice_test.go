@@ -0,0 +1,277 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
+	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+func TestUDPServiceSync(t *testing.T) {
+	syslog := v1alpha1.UDPServiceBackend{Name: "syslog", Port: intstr.FromInt(514)}
+	testCases := []struct {
+		svcmock    map[string]string
+		tcpPorts   bool
+		logForward []*hatypes.LogForward
+		udpsvcs    map[string]v1alpha1.UDPServiceSpec
+		expected   []*hatypes.UDPService
+		status     map[string]string
+		logging    string
+	}{
+		// 0
+		{
+			udpsvcs: map[string]v1alpha1.UDPServiceSpec{},
+		},
+		// 1
+		{
+			svcmock: map[string]string{"default/syslog:514": "172.17.0.102,172.17.0.101"},
+			udpsvcs: map[string]v1alpha1.UDPServiceSpec{
+				"default/syslog": {Port: 1514, Backend: syslog, DgramBinds: []string{"127.0.0.1:2514", "3514"}},
+			},
+			expected: []*hatypes.UDPService{
+				{
+					Name:       "default_syslog",
+					Port:       1514,
+					DgramBinds: []string{"udp@:1514", "udp@127.0.0.1:2514", "udp@:3514"},
+					Targets:    []string{"udp@172.17.0.101:514 sample 1:2", "udp@172.17.0.102:514 sample 2:2"},
+				},
+			},
+			status: map[string]string{
+				"default/syslog": "True/Accepted: UDPService successfully added on port 1514",
+			},
+		},
+		// 2
+		{
+			udpsvcs: map[string]v1alpha1.UDPServiceSpec{
+				"default/syslog": {Port: 1514, Backend: syslog},
+			},
+			status: map[string]string{
+				"default/syslog": "False/Invalid: service not found: 'syslog'",
+			},
+			logging: `WARN skipping UDPService default/syslog: service not found: 'syslog'`,
+		},
+		// 3
+		{
+			svcmock:  map[string]string{"default/syslog:514": "172.17.0.101"},
+			tcpPorts: true,
+			udpsvcs: map[string]v1alpha1.UDPServiceSpec{
+				"default/syslog": {Port: 1514, Backend: syslog},
+			},
+			status: map[string]string{
+				"default/syslog": "False/Invalid: port syslog:514 does not use the UDP protocol",
+			},
+			logging: `WARN skipping UDPService default/syslog: port syslog:514 does not use the UDP protocol`,
+		},
+		// 4
+		{
+			svcmock: map[string]string{"default/syslog:514": "172.17.0.101"},
+			udpsvcs: map[string]v1alpha1.UDPServiceSpec{
+				"default/syslog1": {Port: 1514, Backend: syslog},
+				"default/syslog2": {Port: 1514, Backend: syslog},
+				"default/syslog3": {Port: 2514, Backend: syslog, DgramBinds: []string{"udp:3514"}},
+			},
+			expected: []*hatypes.UDPService{
+				{
+					Name:       "default_syslog1",
+					Port:       1514,
+					DgramBinds: []string{"udp@:1514"},
+					Targets:    []string{"udp@172.17.0.101:514"},
+				},
+			},
+			status: map[string]string{
+				"default/syslog1": "True/Accepted: UDPService successfully added on port 1514",
+				"default/syslog2": "False/Conflict: port 1514 is already used by UDPService default/syslog1",
+				"default/syslog3": "False/Invalid: invalid dgram bind: udp:3514",
+			},
+			logging: `
+WARN skipping UDPService default/syslog2: port 1514 is already used by UDPService default/syslog1
+WARN skipping UDPService default/syslog3: invalid dgram bind: udp:3514`,
+		},
+		// 5
+		{
+			svcmock: map[string]string{"default/syslog:514": "172.17.0.101"},
+			logForward: []*hatypes.LogForward{
+				{Name: "syslog", DgramBinds: []string{"udp@:1514"}, Targets: []string{"udp@10.0.0.10:514"}},
+			},
+			udpsvcs: map[string]v1alpha1.UDPServiceSpec{
+				"default/syslog": {Port: 1514, Backend: syslog},
+			},
+			status: map[string]string{
+				"default/syslog": "False/Conflict: port 1514 is already used by the log-forward configuration",
+			},
+			logging: `WARN skipping UDPService default/syslog: port 1514 is already used by the log-forward configuration`,
+		},
+		// 6
+		{
+			svcmock: map[string]string{"default/syslog:514": ""},
+			udpsvcs: map[string]v1alpha1.UDPServiceSpec{
+				"default/syslog": {Port: 1514, Backend: syslog},
+			},
+			status: map[string]string{
+				"default/syslog": "False/Invalid: service syslog does not have ready endpoints",
+			},
+			logging: `WARN skipping UDPService default/syslog: service syslog does not have ready endpoints`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.createServices(test.svcmock)
+		if !test.tcpPorts {
+			c.setUDPPorts()
+		}
+		c.createUDPServices(test.udpsvcs)
+		c.haproxy.Global().LogForward = test.logForward
+		NewUDPServiceConverter(&types.ConverterOptions{
+			Logger: c.logger,
+			Cache:  c.cache,
+		}, c.haproxy, &types.ChangedObjects{}).Sync(true)
+		c.compareUDPServices(i, test.expected)
+		c.compareUDPStatus(i, test.status)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestUDPServiceSyncPartial(t *testing.T) {
+	syslog := v1alpha1.UDPServiceBackend{Name: "syslog", Port: intstr.FromInt(514)}
+	testCases := []struct {
+		udpsvcs  map[string]v1alpha1.UDPServiceSpec
+		epUpd    map[string]string
+		expected []*hatypes.UDPService
+		status   map[string]string
+	}{
+		// 0
+		{
+			udpsvcs: map[string]v1alpha1.UDPServiceSpec{
+				"default/syslog": {Port: 1514, Backend: syslog},
+			},
+			epUpd: map[string]string{
+				"default/syslog:514": "172.17.0.111",
+			},
+			expected: []*hatypes.UDPService{
+				{
+					Name:       "default_syslog",
+					Port:       1514,
+					DgramBinds: []string{"udp@:1514"},
+					Targets:    []string{"udp@172.17.0.111:514"},
+				},
+			},
+			status: map[string]string{
+				"default/syslog": "True/Accepted: UDPService successfully added on port 1514",
+			},
+		},
+		// 1
+		{
+			udpsvcs: map[string]v1alpha1.UDPServiceSpec{
+				"default/syslog": {Port: 1514, Backend: syslog},
+			},
+			epUpd: map[string]string{
+				"default/other:8080": "172.17.0.151",
+			},
+			expected: []*hatypes.UDPService{
+				{
+					Name:       "default_syslog",
+					Port:       1514,
+					DgramBinds: []string{"udp@:1514"},
+					Targets:    []string{"udp@172.17.0.101:514"},
+				},
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.createServices(map[string]string{"default/syslog:514": "172.17.0.101"})
+		c.setUDPPorts()
+		c.createUDPServices(test.udpsvcs)
+		options := &types.ConverterOptions{
+			Logger: c.logger,
+			Cache:  c.cache,
+		}
+		NewUDPServiceConverter(options, c.haproxy, &types.ChangedObjects{}).Sync(true)
+		c.cache.UDPSvcStatus = map[string]metav1.Condition{}
+		changed := &types.ChangedObjects{}
+		for svckey, endpoints := range test.epUpd {
+			svcport := strings.Split(svckey, ":")
+			_, ep := conv_helper.CreateService(svcport[0], svcport[1], endpoints)
+			c.cache.EpList[svcport[0]] = ep
+			changed.EndpointsNew = append(changed.EndpointsNew, ep)
+		}
+		NewUDPServiceConverter(options, c.haproxy, changed).Sync(false)
+		c.compareUDPServices(i, test.expected)
+		c.compareUDPStatus(i, test.status)
+		if len(test.status) > 0 {
+			c.logger.CompareLogging(`INFO-V(2) syncing 1 UDP service(s) from UDPService resources`)
+		}
+		c.teardown()
+	}
+}
+
+func (c *testConfig) setUDPPorts() {
+	for _, svc := range c.cache.SvcList {
+		for i := range svc.Spec.Ports {
+			svc.Spec.Ports[i].Protocol = api.ProtocolUDP
+		}
+	}
+}
+
+// createUDPServices adds the resources with the same creation timestamp,
+// so the ordering falls back to the namespace and name
+func (c *testConfig) createUDPServices(udpsvcs map[string]v1alpha1.UDPServiceSpec) {
+	created := metav1.NewTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	for name, spec := range udpsvcs {
+		nsname := strings.Split(name, "/")
+		c.cache.UDPSvcList = append(c.cache.UDPSvcList, &v1alpha1.UDPService{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         nsname[0],
+				Name:              nsname[1],
+				CreationTimestamp: created,
+			},
+			Spec: spec,
+		})
+	}
+}
+
+func (c *testConfig) compareUDPServices(i int, expected []*hatypes.UDPService) {
+	services := c.haproxy.Global().UDPServices
+	if !reflect.DeepEqual(services, expected) {
+		c.t.Errorf("udp services differs on %d -- expected: %+v -- actual: %+v", i, expected, services)
+	}
+}
+
+func (c *testConfig) compareUDPStatus(i int, expected map[string]string) {
+	status := map[string]string{}
+	for name, cond := range c.cache.UDPSvcStatus {
+		status[name] = string(cond.Status) + "/" + cond.Reason + ": " + cond.Message
+	}
+	if expected == nil {
+		expected = map[string]string{}
+	}
+	if !reflect.DeepEqual(status, expected) {
+		c.t.Errorf("status differs on %d -- expected: %+v -- actual: %+v", i, expected, status)
+	}
+}
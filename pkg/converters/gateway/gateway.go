@@ -22,8 +22,10 @@ import (
 	"strings"
 
 	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	gatewayv1alpha1 "sigs.k8s.io/gateway-api/apis/v1alpha1"
 
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	convutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
@@ -81,10 +83,10 @@ func (c *converter) NeedFullSync() bool {
 		}
 		return serviceList
 	}
-	ep2names := func(endpoints []*api.Endpoints) []string {
+	ep2names := func(endpoints []*discoveryv1.EndpointSlice) []string {
 		epList := make([]string, len(endpoints))
 		for i, ep := range endpoints {
-			epList[i] = ep.Namespace + "/" + ep.Name
+			epList[i] = convutils.EndpointSliceService(ep)
 		}
 		return epList
 	}
@@ -134,6 +136,14 @@ func (s *Source) String() string {
 	return fmt.Sprintf("%s '%s/%s'", s.kind, s.namespace, s.name)
 }
 
+// ObjectRef ...
+func (s *Source) ObjectRef() (kind, namespace, name string) {
+	if s == nil {
+		return "", "", ""
+	}
+	return s.kind, s.namespace, s.name
+}
+
 func (c *converter) syncGateway(gateway *gatewayv1alpha1.Gateway) {
 	// TODO implement gateway.Spec.Addresses
 	group := "networking.x-k8s.io"
@@ -193,7 +203,7 @@ func (c *converter) createHTTPRoutes(source *Source, httpListeners []*gatewayv1a
 				name:      route.Name,
 			}
 			for index, rule := range route.Spec.Rules {
-				// TODO implement rule.Filters
+				middlewares := c.readMiddlewares(routeSource, rule.Filters)
 				backend, services := c.createBackend(routeSource, fmt.Sprintf("_rule%d", index), rule.ForwardTo)
 				if backend != nil {
 					passthrough := listener.TLS != nil && listener.TLS.Mode != nil && *listener.TLS.Mode == gatewayv1alpha1.TLSModePassthrough
@@ -204,7 +214,7 @@ func (c *converter) createHTTPRoutes(source *Source, httpListeners []*gatewayv1a
 					hosts, pathLinks := c.createHTTPHosts(routeSource, hostnames, rule.Matches, backend)
 					c.applyCertRef(source, routeSource, hosts, listener, route)
 					if c.ann != nil {
-						c.ann.ReadAnnotations(backend, services, pathLinks)
+						c.ann.ReadAnnotations(backend, services, pathLinks, middlewares)
 					}
 				}
 			}
@@ -212,6 +222,22 @@ func (c *converter) createHTTPRoutes(source *Source, httpListeners []*gatewayv1a
 	}
 }
 
+// readMiddlewares reads the names of the Middleware resources referenced by
+// ExtensionRef filters, other filters are not implemented yet.
+func (c *converter) readMiddlewares(source *Source, filters []gatewayv1alpha1.HTTPRouteFilter) []string {
+	var middlewares []string
+	for _, filter := range filters {
+		ref := filter.ExtensionRef
+		if filter.Type == gatewayv1alpha1.HTTPRouteFilterExtensionRef && ref != nil && ref.Group == v1alpha1.GroupName && ref.Kind == "Middleware" {
+			middlewares = append(middlewares, ref.Name)
+		} else {
+			// TODO implement the other filters
+			c.logger.Warn("ignoring unsupported filter type '%s' on %s", filter.Type, source)
+		}
+	}
+	return middlewares
+}
+
 func (c *converter) createBackend(source *Source, index string, forwardTo []gatewayv1alpha1.HTTPRouteForwardTo) (*hatypes.Backend, []*api.Service) {
 	if habackend := c.haproxy.Backends().FindBackend(source.namespace, source.name, index); habackend != nil {
 		return habackend, nil
@@ -317,6 +343,12 @@ func (c *converter) createHTTPHosts(source *Source, hostnames []gatewayv1alpha1.
 		if haMatch == "" {
 			haMatch = hatypes.MatchPrefix
 		}
+		if haMatch == hatypes.MatchRegex {
+			if err := convutils.ValidateRegexPath(path); err != nil {
+				c.logger.Warn("skipping path '%s' on %s: %v", path, source, err)
+				continue
+			}
+		}
 		for _, hostname := range hostnames {
 			hstr := string(hostname)
 			if hstr == "" || hstr == "*" {
@@ -324,7 +356,7 @@ func (c *converter) createHTTPHosts(source *Source, hostnames []gatewayv1alpha1.
 			}
 			h := c.haproxy.Hosts().AcquireHost(hstr)
 			h.TLS.UseDefaultCrt = false
-			h.AddPath(backend, path, haMatch)
+			h.AddPath(backend, path, haMatch).Source = hatypes.PathSource{Namespace: source.namespace, Name: source.name}
 			handlePassthrough(path, h, backend)
 			hosts = append(hosts, h)
 			pathLinks = append(pathLinks, hatypes.CreatePathLink(hstr, path, haMatch))
@@ -18,11 +18,13 @@ package gateway
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/kylelemons/godebug/diff"
 	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	gateway "sigs.k8s.io/gateway-api/apis/v1alpha1"
 	"sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/scheme"
@@ -350,6 +352,34 @@ paths:
   - ip: 172.17.0.11
     port: 8080
     weight: 128
+`,
+		},
+		{
+			id: "match-path-types-2",
+			config: func(c *testConfig) {
+				c.createGateway1("default/web", "gateway=web")
+				route := c.createHTTPRoute2("default/web", "gateway=web", "echoserver:8080", "/app[0-9]+,/app(")
+				c.createService1("default/echoserver", "8080", "172.17.0.11")
+				g := gateway.PathMatchRegularExpression
+				route.Spec.Rules[0].Matches[0].Path.Type = &g
+				route.Spec.Rules[0].Matches[1].Path.Type = &g
+			},
+			expDefaultHost: `
+hostname: <default>
+paths:
+- path: /app[0-9]+
+  match: regex
+  backend: default_web__rule0
+`,
+			expBackends: `
+- id: default_web__rule0
+  endpoints:
+  - ip: 172.17.0.11
+    port: 8080
+    weight: 128
+`,
+			expLogging: `
+WARN skipping path '/app(' on HTTPRoute 'default/web': invalid regex: error parsing regexp: missing closing ): ` + "`/app(`" + `
 `,
 		},
 		{
@@ -1237,6 +1267,58 @@ func runTestSync(t *testing.T, testCases []testCaseSync) {
 	}
 }
 
+func TestReadMiddlewares(t *testing.T) {
+	extensionRef := func(group, kind, name string) gateway.HTTPRouteFilter {
+		return gateway.HTTPRouteFilter{
+			Type: gateway.HTTPRouteFilterExtensionRef,
+			ExtensionRef: &gateway.LocalObjectReference{
+				Group: group,
+				Kind:  kind,
+				Name:  name,
+			},
+		}
+	}
+	testCases := []struct {
+		filters  []gateway.HTTPRouteFilter
+		expected []string
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			filters: []gateway.HTTPRouteFilter{
+				extensionRef("haproxy-ingress.github.io", "Middleware", "auth"),
+				extensionRef("haproxy-ingress.github.io", "Middleware", "headers"),
+			},
+			expected: []string{"auth", "headers"},
+		},
+		// 2
+		{
+			filters: []gateway.HTTPRouteFilter{
+				{Type: gateway.HTTPRouteFilterRequestHeaderModifier},
+				extensionRef("example.com", "Middleware", "other"),
+				extensionRef("haproxy-ingress.github.io", "Middleware", "limits"),
+			},
+			expected: []string{"limits"},
+			logging: `
+WARN ignoring unsupported filter type 'RequestHeaderModifier' on HTTPRoute 'default/web'
+WARN ignoring unsupported filter type 'ExtensionRef' on HTTPRoute 'default/web'`,
+		},
+	}
+	source := &Source{kind: "HTTPRoute", namespace: "default", name: "web"}
+	for i, test := range testCases {
+		c := setup(t)
+		conv := c.createConverter().(*converter)
+		middlewares := conv.readMiddlewares(source, test.filters)
+		if !reflect.DeepEqual(middlewares, test.expected) {
+			t.Errorf("middlewares differ on %d -- expected: %v -- actual: %v", i, test.expected, middlewares)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 type testConfig struct {
 	t       *testing.T
 	cache   *conv_helper.CacheMock
@@ -1284,7 +1366,7 @@ func (c *testConfig) createSecret1(secretName string) *api.Secret {
 	return conv_helper.CreateSecret(secretName)
 }
 
-func (c *testConfig) createService1(name, port, ip string) (*api.Service, *api.Endpoints) {
+func (c *testConfig) createService1(name, port, ip string) (*api.Service, *discoveryv1.EndpointSlice) {
 	svc, ep := conv_helper.CreateService(name, port, ip)
 	c.cache.SvcList = append(c.cache.SvcList, svc)
 	c.cache.EpList[name] = ep
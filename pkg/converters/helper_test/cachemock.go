@@ -23,9 +23,14 @@ import (
 	"time"
 
 	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	gateway "sigs.k8s.io/gateway-api/apis/v1alpha1"
 
+	mcsv1alpha1 "github.com/jcmoraisjr/haproxy-ingress/pkg/api/mcs/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 )
 
@@ -38,29 +43,64 @@ type CacheMock struct {
 	Changed       *convtypes.ChangedObjects
 	IngList       []*networking.Ingress
 	IngClassList  []*networking.IngressClass
+	IngClassPars  []*v1alpha1.IngressClassParameters
 	SvcList       []*api.Service
 	GwList        []*gateway.Gateway
 	GwClassList   []*gateway.GatewayClass
 	HTTPRouteList []*gateway.HTTPRoute
-	EpList        map[string]*api.Endpoints
+	TCPSvcList    []*v1alpha1.TCPService
+	TCPSvcStatus  map[string]metav1.Condition
+	UDPSvcList    []*v1alpha1.UDPService
+	UDPSvcStatus  map[string]metav1.Condition
+	GlobalList    []*v1alpha1.Global
+	GlobalStatus  map[string]metav1.Condition
+	HostList      []*v1alpha1.Host
+	HostStatus    map[string]metav1.Condition
+	BackendList   []*v1alpha1.Backend
+	BackendStatus map[string]metav1.Condition
+	MwList        []*v1alpha1.Middleware
+	MwStatus      map[string]metav1.Condition
+	AuthList      []*v1alpha1.AuthConfig
+	AuthStatus    map[string]metav1.Condition
+	IPLists       []*v1alpha1.IPList
+	IPListStatus  map[string]metav1.Condition
+	RLimitList    []*v1alpha1.RateLimit
+	RLimitStatus  map[string]metav1.Condition
+	EpList        map[string]*discoveryv1.EndpointSlice
+	EpSliceList   map[string][]*discoveryv1.EndpointSlice
+	SvcImpList    []*mcsv1alpha1.ServiceImport
+	SvcImpSlices  map[string][]*discoveryv1.EndpointSlice
 	ConfigMapList map[string]*api.ConfigMap
 	TermPodList   map[string][]*api.Pod
 	PodList       map[string]*api.Pod
+	NodeList      map[string]*api.Node
 	SecretTLSPath map[string]string
 	SecretCAPath  map[string]string
 	SecretCRLPath map[string]string
 	SecretDHPath  map[string]string
+	SecretPKPath  map[string]string
+	JWKS          map[string][]convtypes.JWKSKey
 	SecretContent SecretContent
+	Events        []string
 }
 
 // NewCacheMock ...
 func NewCacheMock(tracker convtypes.Tracker) *CacheMock {
 	return &CacheMock{
-		tracker:     tracker,
-		Changed:     &convtypes.ChangedObjects{},
-		SvcList:     []*api.Service{},
-		EpList:      map[string]*api.Endpoints{},
-		TermPodList: map[string][]*api.Pod{},
+		tracker:       tracker,
+		Changed:       &convtypes.ChangedObjects{},
+		SvcList:       []*api.Service{},
+		EpList:        map[string]*discoveryv1.EndpointSlice{},
+		TermPodList:   map[string][]*api.Pod{},
+		TCPSvcStatus:  map[string]metav1.Condition{},
+		UDPSvcStatus:  map[string]metav1.Condition{},
+		GlobalStatus:  map[string]metav1.Condition{},
+		HostStatus:    map[string]metav1.Condition{},
+		BackendStatus: map[string]metav1.Condition{},
+		MwStatus:      map[string]metav1.Condition{},
+		AuthStatus:    map[string]metav1.Condition{},
+		IPListStatus:  map[string]metav1.Condition{},
+		RLimitStatus:  map[string]metav1.Condition{},
 		SecretTLSPath: map[string]string{
 			"system/ingress-default": "/tls/tls-default.pem",
 		},
@@ -99,6 +139,32 @@ func (c *CacheMock) GetIngressClass(className string) (*networking.IngressClass,
 	return nil, fmt.Errorf("IngressClass not found: %s", className)
 }
 
+// GetIngressClassParameters ...
+func (c *CacheMock) GetIngressClassParameters(name string) (*v1alpha1.IngressClassParameters, error) {
+	for _, params := range c.IngClassPars {
+		if params.Name == name {
+			return params, nil
+		}
+	}
+	return nil, fmt.Errorf("IngressClassParameters not found: %s", name)
+}
+
+// GetGlobal ...
+func (c *CacheMock) GetGlobal(name string) (*v1alpha1.Global, error) {
+	for _, global := range c.GlobalList {
+		if global.Name == name {
+			return global, nil
+		}
+	}
+	return nil, fmt.Errorf("Global not found: %s", name)
+}
+
+// UpdateGlobalStatus ...
+func (c *CacheMock) UpdateGlobalStatus(global *v1alpha1.Global, condition metav1.Condition) error {
+	c.GlobalStatus[global.Name] = condition
+	return nil
+}
+
 // GetGateway ...
 func (c *CacheMock) GetGateway(gatewayName string) (*gateway.Gateway, error) {
 	return nil, nil
@@ -131,6 +197,106 @@ func (c *CacheMock) GetHTTPRouteList(namespace string, match map[string]string)
 	return routes, nil
 }
 
+// GetHostList ...
+func (c *CacheMock) GetHostList() ([]*v1alpha1.Host, error) {
+	return c.HostList, nil
+}
+
+// UpdateHostStatus ...
+func (c *CacheMock) UpdateHostStatus(host *v1alpha1.Host, condition metav1.Condition) error {
+	c.HostStatus[host.Namespace+"/"+host.Name] = condition
+	return nil
+}
+
+// GetBackendList ...
+func (c *CacheMock) GetBackendList() ([]*v1alpha1.Backend, error) {
+	return c.BackendList, nil
+}
+
+// UpdateBackendStatus ...
+func (c *CacheMock) UpdateBackendStatus(backend *v1alpha1.Backend, condition metav1.Condition) error {
+	c.BackendStatus[backend.Namespace+"/"+backend.Name] = condition
+	return nil
+}
+
+// GetMiddlewareList ...
+func (c *CacheMock) GetMiddlewareList() ([]*v1alpha1.Middleware, error) {
+	return c.MwList, nil
+}
+
+// UpdateMiddlewareStatus ...
+func (c *CacheMock) UpdateMiddlewareStatus(middleware *v1alpha1.Middleware, condition metav1.Condition) error {
+	c.MwStatus[middleware.Namespace+"/"+middleware.Name] = condition
+	return nil
+}
+
+// GetAuthConfigList ...
+func (c *CacheMock) GetAuthConfigList() ([]*v1alpha1.AuthConfig, error) {
+	return c.AuthList, nil
+}
+
+// UpdateAuthConfigStatus ...
+func (c *CacheMock) UpdateAuthConfigStatus(authConfig *v1alpha1.AuthConfig, condition metav1.Condition) error {
+	c.AuthStatus[authConfig.Namespace+"/"+authConfig.Name] = condition
+	return nil
+}
+
+// GetIPListList ...
+func (c *CacheMock) GetIPListList() ([]*v1alpha1.IPList, error) {
+	return c.IPLists, nil
+}
+
+// GetIPList ...
+func (c *CacheMock) GetIPList(name string, track convtypes.TrackingTarget) (*v1alpha1.IPList, error) {
+	for _, ipList := range c.IPLists {
+		if ipList.Name == name {
+			c.tracker.Track(false, track, convtypes.IPListType, name)
+			return ipList, nil
+		}
+	}
+	c.tracker.Track(true, track, convtypes.IPListType, name)
+	return nil, fmt.Errorf("iplist not found: '%s'", name)
+}
+
+// UpdateIPListStatus ...
+func (c *CacheMock) UpdateIPListStatus(ipList *v1alpha1.IPList, condition metav1.Condition) error {
+	c.IPListStatus[ipList.Name] = condition
+	return nil
+}
+
+// GetRateLimitList ...
+func (c *CacheMock) GetRateLimitList() ([]*v1alpha1.RateLimit, error) {
+	return c.RLimitList, nil
+}
+
+// UpdateRateLimitStatus ...
+func (c *CacheMock) UpdateRateLimitStatus(rateLimit *v1alpha1.RateLimit, condition metav1.Condition) error {
+	c.RLimitStatus[rateLimit.Name] = condition
+	return nil
+}
+
+// GetTCPServiceList ...
+func (c *CacheMock) GetTCPServiceList() ([]*v1alpha1.TCPService, error) {
+	return c.TCPSvcList, nil
+}
+
+// UpdateTCPServiceStatus ...
+func (c *CacheMock) UpdateTCPServiceStatus(tcpService *v1alpha1.TCPService, condition metav1.Condition) error {
+	c.TCPSvcStatus[tcpService.Namespace+"/"+tcpService.Name] = condition
+	return nil
+}
+
+// GetUDPServiceList ...
+func (c *CacheMock) GetUDPServiceList() ([]*v1alpha1.UDPService, error) {
+	return c.UDPSvcList, nil
+}
+
+// UpdateUDPServiceStatus ...
+func (c *CacheMock) UpdateUDPServiceStatus(udpService *v1alpha1.UDPService, condition metav1.Condition) error {
+	c.UDPSvcStatus[udpService.Namespace+"/"+udpService.Name] = condition
+	return nil
+}
+
 // GetService ...
 func (c *CacheMock) GetService(defaultNamespace, serviceName string) (*api.Service, error) {
 	fullname := c.buildResourceName(defaultNamespace, serviceName)
@@ -145,15 +311,38 @@ func (c *CacheMock) GetService(defaultNamespace, serviceName string) (*api.Servi
 	return nil, fmt.Errorf("service not found: '%s'", serviceName)
 }
 
-// GetEndpoints ...
-func (c *CacheMock) GetEndpoints(service *api.Service) (*api.Endpoints, error) {
+// GetEndpointSlices ...
+func (c *CacheMock) GetEndpointSlices(service *api.Service) ([]*discoveryv1.EndpointSlice, error) {
 	serviceName := service.Namespace + "/" + service.Name
+	if slices, found := c.EpSliceList[serviceName]; found {
+		return slices, nil
+	}
 	if ep, found := c.EpList[serviceName]; found {
-		return ep, nil
+		return []*discoveryv1.EndpointSlice{ep}, nil
 	}
 	return nil, fmt.Errorf("could not find endpoints for service '%s'", serviceName)
 }
 
+// GetServiceImport ...
+func (c *CacheMock) GetServiceImport(defaultNamespace, importName string) (*mcsv1alpha1.ServiceImport, error) {
+	fullname := c.buildResourceName(defaultNamespace, importName)
+	for _, imp := range c.SvcImpList {
+		if imp.Namespace+"/"+imp.Name == fullname {
+			return imp, nil
+		}
+	}
+	return nil, fmt.Errorf("service import not found: '%s'", importName)
+}
+
+// GetServiceImportSlices ...
+func (c *CacheMock) GetServiceImportSlices(svcImport *mcsv1alpha1.ServiceImport) ([]*discoveryv1.EndpointSlice, error) {
+	importName := svcImport.Namespace + "/" + svcImport.Name
+	if slices, found := c.SvcImpSlices[importName]; found {
+		return slices, nil
+	}
+	return nil, fmt.Errorf("could not find endpoints for service import '%s'", importName)
+}
+
 // GetConfigMap ...
 func (c *CacheMock) GetConfigMap(configMapName string) (*api.ConfigMap, error) {
 	if configMap, found := c.ConfigMapList[configMapName]; found {
@@ -162,6 +351,17 @@ func (c *CacheMock) GetConfigMap(configMapName string) (*api.ConfigMap, error) {
 	return nil, fmt.Errorf("configmap not found: %s", configMapName)
 }
 
+// GetConfigMapData ...
+func (c *CacheMock) GetConfigMapData(defaultNamespace, configMapName string, track convtypes.TrackingTarget) (map[string]string, error) {
+	fullname := c.buildResourceName(defaultNamespace, configMapName)
+	if configMap, found := c.ConfigMapList[fullname]; found {
+		c.tracker.Track(false, track, convtypes.ConfigMapType, fullname)
+		return configMap.Data, nil
+	}
+	c.tracker.Track(true, track, convtypes.ConfigMapType, fullname)
+	return nil, fmt.Errorf("configmap not found: '%s'", fullname)
+}
+
 // GetTerminatingPods ...
 func (c *CacheMock) GetTerminatingPods(service *api.Service, track convtypes.TrackingTarget) ([]*api.Pod, error) {
 	serviceName := service.Namespace + "/" + service.Name
@@ -179,6 +379,35 @@ func (c *CacheMock) GetPod(podName string) (*api.Pod, error) {
 	return nil, fmt.Errorf("pod not found: '%s'", podName)
 }
 
+// GetControllerServices ...
+func (c *CacheMock) GetControllerServices() ([]*api.Service, error) {
+	pod, err := c.GetPod(c.GetPodNamespace() + "/" + c.GetPodName())
+	if err != nil {
+		return nil, err
+	}
+	var services []*api.Service
+	for _, svc := range c.SvcList {
+		selector := svc.Spec.Selector
+		if svc.Namespace == pod.Namespace && len(selector) > 0 && labels.SelectorFromSet(selector).Matches(labels.Set(pod.Labels)) {
+			services = append(services, svc)
+		}
+	}
+	return services, nil
+}
+
+// GetNode ...
+func (c *CacheMock) GetNode(nodeName string) (*api.Node, error) {
+	if node, found := c.NodeList[nodeName]; found {
+		return node, nil
+	}
+	return nil, fmt.Errorf("node not found: '%s'", nodeName)
+}
+
+// GetPodName ...
+func (c *CacheMock) GetPodName() string {
+	return "ingress-controller-0"
+}
+
 // GetPodNamespace ...
 func (c *CacheMock) GetPodNamespace() string {
 	return "ingress-controller"
@@ -234,11 +463,37 @@ func (c *CacheMock) GetDHSecretPath(defaultNamespace, secretName string) (convty
 	return convtypes.File{}, fmt.Errorf("secret not found: '%s'", fullname)
 }
 
+// GetPublicKeySecretPath ...
+func (c *CacheMock) GetPublicKeySecretPath(defaultNamespace, secretName string, track convtypes.TrackingTarget) (convtypes.File, error) {
+	fullname := c.buildResourceName(defaultNamespace, secretName)
+	if path, found := c.SecretPKPath[fullname]; found {
+		c.tracker.Track(false, track, convtypes.SecretType, fullname)
+		return convtypes.File{
+			Filename: path,
+			SHA1Hash: fmt.Sprintf("%x", sha1.Sum([]byte(path))),
+		}, nil
+	}
+	c.tracker.Track(true, track, convtypes.SecretType, fullname)
+	return convtypes.File{}, fmt.Errorf("secret not found: '%s'", fullname)
+}
+
+// GetJWKSKeys ...
+func (c *CacheMock) GetJWKSKeys(url string) ([]convtypes.JWKSKey, error) {
+	if keys, found := c.JWKS[url]; found {
+		return keys, nil
+	}
+	return nil, fmt.Errorf("JWKS not found: '%s'", url)
+}
+
 // GetPasswdSecretContent ...
 func (c *CacheMock) GetPasswdSecretContent(defaultNamespace, secretName string, track convtypes.TrackingTarget) ([]byte, error) {
+	return c.GetSecretContent(defaultNamespace, secretName, "auth", track)
+}
+
+// GetSecretContent ...
+func (c *CacheMock) GetSecretContent(defaultNamespace, secretName, keyName string, track convtypes.TrackingTarget) ([]byte, error) {
 	fullname := c.buildResourceName(defaultNamespace, secretName)
 	if content, found := c.SecretContent[fullname]; found {
-		keyName := "auth"
 		if val, found := content[keyName]; found {
 			c.tracker.Track(false, track, convtypes.SecretType, fullname)
 			return val, nil
@@ -250,6 +505,11 @@ func (c *CacheMock) GetPasswdSecretContent(defaultNamespace, secretName string,
 	return nil, fmt.Errorf("secret not found: '%s'", fullname)
 }
 
+// RecordEvent ...
+func (c *CacheMock) RecordEvent(kind, namespace, name, eventtype, reason, message string) {
+	c.Events = append(c.Events, fmt.Sprintf("%s %s/%s %s %s: %s", kind, namespace, name, eventtype, reason, message))
+}
+
 // SwapChangedObjects ...
 func (c *CacheMock) SwapChangedObjects() *convtypes.ChangedObjects {
 	changed := c.Changed
@@ -298,7 +558,7 @@ func (c *CacheMock) SwapChangedObjects() *convtypes.ChangedObjects {
 	}
 	// update c.EpList based on notifications
 	for _, ep := range changed.EndpointsNew {
-		c.EpList[ep.Namespace+"/"+ep.Name] = ep
+		c.EpList[ep.Namespace+"/"+ep.Labels[discoveryv1.LabelServiceName]] = ep
 	}
 	c.SvcList = c.SvcList[:len(c.SvcList)-len(changed.ServicesDel)]
 	for _, svcAdd := range changed.ServicesAdd {
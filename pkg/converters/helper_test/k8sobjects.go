@@ -20,12 +20,13 @@ import (
 	"strings"
 
 	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 )
 
 // CreateService ...
-func CreateService(name, port, endpoints string) (*api.Service, *api.Endpoints) {
+func CreateService(name, port, endpoints string) (*api.Service, *discoveryv1.EndpointSlice) {
 	sname := strings.Split(name, "/") // namespace/name of the service
 	sport := strings.Split(port, ":") // numeric-port -or- name:numeric-port -or- name:numeric-port:named-port
 	if len(sport) < 2 {
@@ -47,19 +48,20 @@ spec:
     targetPort: ` + sport[2]).(*api.Service)
 
 	ep := CreateObject(`
-apiVersion: v1
-kind: Endpoints
+apiVersion: discovery.k8s.io/v1
+kind: EndpointSlice
 metadata:
-  name: ` + sname[1] + `
+  name: ` + sname[1] + `-xxxxx
   namespace: ` + sname[0] + `
-subsets:
-- addresses: []
-  ports:
-  - name: ` + sport[0] + `
-    port: ` + sport[1] + `
-    protocol: TCP`).(*api.Endpoints)
+  labels:
+    kubernetes.io/service-name: ` + sname[1] + `
+addressType: IPv4
+endpoints: []
+ports:
+- name: ` + sport[0] + `
+  port: ` + sport[1] + `
+  protocol: TCP`).(*discoveryv1.EndpointSlice)
 
-	addr := []api.EndpointAddress{}
 	for _, e := range strings.Split(endpoints, ",") {
 		if e != "" {
 			target := &api.ObjectReference{
@@ -67,10 +69,14 @@ subsets:
 				Name:      sname[1] + "-xxxxx",
 				Namespace: sname[0],
 			}
-			addr = append(addr, api.EndpointAddress{IP: e, TargetRef: target})
+			ready := true
+			ep.Endpoints = append(ep.Endpoints, discoveryv1.Endpoint{
+				Addresses:  []string{e},
+				Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+				TargetRef:  target,
+			})
 		}
 	}
-	ep.Subsets[0].Addresses = addr
 
 	return svc, ep
 }
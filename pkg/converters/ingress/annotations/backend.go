@@ -19,10 +19,13 @@ package annotations
 import (
 	"fmt"
 	"net"
+	"net/url"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	ingutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/utils"
@@ -32,11 +35,108 @@ import (
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
 
+var abTestKeyRegex = regexp.MustCompile(`^(cookie|header):([A-Za-z0-9_.-]+)$`)
+
+// abTestBuckets is the number of buckets the hash of the client identifier is
+// distributed to. Every variant receives a range of buckets proportional to its
+// percentage, which is split between the endpoints of the variant.
+const abTestBuckets = 10000
+
+func (c *updater) buildBackendABTest(d *backData) {
+	split := d.mapper.Get(ingtypes.BackABTestSplit)
+	if split.Value == "" {
+		return
+	}
+	if d.backend.ModeTCP {
+		c.logger.Warn("ignoring A/B test on %v: backend is not in http mode", split.Source)
+		return
+	}
+	key := d.mapper.Get(ingtypes.BackABTestKey)
+	keyMatch := abTestKeyRegex.FindStringSubmatch(key.Value)
+	if keyMatch == nil {
+		c.logger.Warn("ignoring A/B test on %v: invalid ab-test-key: %s", key.Source, key.Value)
+		return
+	}
+	type abVariant struct {
+		labelName  string
+		labelValue string
+		percent    int
+		endpoints  []*hatypes.Endpoint
+	}
+	var variants []*abVariant
+	var total int
+	for _, item := range utils.Split(split.Value, ",") {
+		v := strings.Split(item, "=")
+		if len(v) != 3 || !validLabelPairRegex.MatchString(v[0]+"="+v[1]) {
+			c.logger.Warn("ignoring A/B test on %v: invalid variant, expected '<label>=<value>=<percent>': %s", split.Source, item)
+			return
+		}
+		percent, err := strconv.Atoi(v[2])
+		if err != nil || percent < 0 || percent > 100 {
+			c.logger.Warn("ignoring A/B test on %v: invalid percentage: %s", split.Source, item)
+			return
+		}
+		total += percent
+		variants = append(variants, &abVariant{labelName: v[0], labelValue: v[1], percent: percent})
+	}
+	if total != 100 {
+		c.logger.Warn("ignoring A/B test on %v: sum of the percentages should be 100, found %d", split.Source, total)
+		return
+	}
+	for _, ep := range d.backend.Endpoints {
+		if !ep.Enabled || ep.Weight == 0 {
+			continue
+		}
+		pod, err := c.cache.GetPod(ep.TargetRef)
+		if err != nil {
+			if ep.TargetRef == "" {
+				err = fmt.Errorf("endpoint does not reference a pod")
+			}
+			c.logger.Warn("endpoint '%s:%d' on backend '%s' was removed from A/B test: %v", ep.IP, ep.Port, d.backend.ID, err)
+			continue
+		}
+		for _, v := range variants {
+			if pod.Labels[v.labelName] == v.labelValue {
+				v.endpoints = append(v.endpoints, ep)
+				break
+			}
+		}
+	}
+	var start int
+	for _, v := range variants {
+		size := v.percent * abTestBuckets / 100
+		count := len(v.endpoints)
+		if count == 0 && size > 0 {
+			c.logger.InfoV(3, "A/B test label '%s=%s' on %v does not reference any endpoint", v.labelName, v.labelValue, split.Source)
+		}
+		for i, ep := range v.endpoints {
+			low := start + size*i/count
+			high := start + size*(i+1)/count - 1
+			if high >= low {
+				ep.ABTestRange = fmt.Sprintf("%d:%d", low, high)
+			}
+		}
+		start += size
+	}
+	fetch := "req.cook"
+	if keyMatch[1] == "header" {
+		fetch = "req.hdr"
+	}
+	d.backend.ABTest = hatypes.ABTestConfig{
+		Buckets: abTestBuckets,
+		Fetch:   fetch + "(" + keyMatch[2] + ")",
+	}
+}
+
 func (c *updater) buildBackendAffinity(d *backData) {
 	affinity := d.mapper.Get(ingtypes.BackAffinity)
 	if affinity.Source == nil {
 		return
 	}
+	if affinity.Value == "stick-table" {
+		c.buildBackendAffinityStick(d)
+		return
+	}
 	if affinity.Value != "cookie" {
 		c.logger.Error("unsupported affinity type on %v: %s", affinity.Source, affinity.Value)
 		return
@@ -69,9 +169,10 @@ func (c *updater) buildBackendAffinity(d *backData) {
 	}
 	d.backend.Cookie.Keywords = keywordsValue
 	d.backend.Cookie.Dynamic = d.mapper.Get(ingtypes.BackSessionCookieDynamic).Bool()
+	d.backend.Cookie.Hash = d.mapper.Get(ingtypes.BackSessionCookieHash).Bool()
 	d.backend.Cookie.Preserve = d.mapper.Get(ingtypes.BackSessionCookiePreserve).Bool()
-	d.backend.Cookie.SameSite = d.mapper.Get(ingtypes.BackSessionCookieSameSite).Bool()
 	d.backend.Cookie.Shared = d.mapper.Get(ingtypes.BackSessionCookieShared).Bool()
+	c.buildBackendAffinityCookieAttrs(d, keywordsValue)
 
 	cookieStrategy := d.mapper.Get(ingtypes.BackSessionCookieValue)
 	switch cookieStrategy.Value {
@@ -87,6 +188,109 @@ func (c *updater) buildBackendAffinity(d *backData) {
 	}
 }
 
+var (
+	cookiePathRegex   = regexp.MustCompile(`^/[A-Za-z0-9._~!$&()*+=:@%/-]*$`)
+	cookieMaxAgeRegex = regexp.MustCompile(`^([0-9]+)([smhd]?)$`)
+)
+
+func (c *updater) buildBackendAffinityCookieAttrs(d *backData, keywords string) {
+	hasKeyword := func(keyword string) bool {
+		for _, k := range strings.Fields(keywords) {
+			if k == keyword {
+				return true
+			}
+		}
+		return false
+	}
+	cookie := &d.backend.Cookie
+	sameSite := d.mapper.Get(ingtypes.BackSessionCookieSameSite)
+	switch strings.ToLower(sameSite.Value) {
+	case "", "false":
+	case "true", "none":
+		// SameSite=None is refused by browsers without the Secure attribute
+		cookie.SameSite = "None"
+	case "lax":
+		cookie.SameSite = "Lax"
+	case "strict":
+		cookie.SameSite = "Strict"
+	default:
+		c.logger.Warn("ignoring invalid session-cookie-same-site on %v: %s", sameSite.Source, sameSite.Value)
+	}
+	secure := d.mapper.Get(ingtypes.BackSessionCookieSecure).Bool() || cookie.SameSite == "None"
+	cookie.Secure = secure && !hasKeyword("secure")
+	cookie.HTTPOnly = d.mapper.Get(ingtypes.BackSessionCookieHTTPOnly).Bool() && !hasKeyword("httponly")
+	if path := d.mapper.Get(ingtypes.BackSessionCookiePath); path.Value != "" {
+		if cookiePathRegex.MatchString(path.Value) {
+			cookie.Path = path.Value
+		} else {
+			c.logger.Warn("ignoring invalid session-cookie-path on %v: %s", path.Source, path.Value)
+		}
+	}
+	if maxAge := d.mapper.Get(ingtypes.BackSessionCookieMaxAge); maxAge.Value != "" {
+		if m := cookieMaxAgeRegex.FindStringSubmatch(maxAge.Value); m != nil {
+			seconds, _ := strconv.Atoi(m[1])
+			switch m[2] {
+			case "m":
+				seconds *= 60
+			case "h":
+				seconds *= 3600
+			case "d":
+				seconds *= 86400
+			}
+			cookie.MaxAge = seconds
+		} else {
+			c.logger.Warn("ignoring invalid session-cookie-max-age on %v: %s", maxAge.Source, maxAge.Value)
+		}
+	}
+}
+
+var (
+	stickNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	stickSizeRegex = regexp.MustCompile(`^[0-9]+[kmg]?$`)
+)
+
+func (c *updater) buildBackendAffinityStick(d *backData) {
+	key := d.mapper.Get(ingtypes.BackSessionStickKey)
+	keyValue := strings.SplitN(key.Value, ":", 2)
+	var name string
+	if len(keyValue) == 2 && stickNameRegex.MatchString(keyValue[1]) {
+		name = keyValue[1]
+	}
+	stick := hatypes.StickConfig{Type: "string"}
+	switch {
+	case key.Value == "src":
+		stick.Match = "src"
+		stick.Type = "ip"
+	case keyValue[0] == "cookie" && name != "":
+		stick.Match = "req.cook(" + name + ")"
+		stick.Store = "res.cook(" + name + ")"
+	case keyValue[0] == "header" && name != "":
+		stick.Match = "req.hdr(" + name + ")"
+	case keyValue[0] == "url-param" && name != "":
+		stick.Match = "url_param(" + name + ")"
+	default:
+		c.logger.Warn("ignoring stick-table affinity on %v: invalid session-stick-key: %s", key.Source, key.Value)
+		return
+	}
+	if d.backend.ModeTCP && stick.Match != "src" {
+		c.logger.Warn("ignoring stick-table affinity on %v: only 'src' can be used on a TCP backend", key.Source)
+		return
+	}
+	expire := d.mapper.Get(ingtypes.BackSessionStickExpire)
+	stick.Expire = c.validateTime(expire)
+	if stick.Expire == "" {
+		stick.Expire = "30m"
+	}
+	size := d.mapper.Get(ingtypes.BackSessionStickSize)
+	if stickSizeRegex.MatchString(size.Value) {
+		stick.Size = size.Value
+	} else {
+		c.logger.Warn("ignoring invalid session-stick-size on %v: %s", size.Source, size.Value)
+		stick.Size = "100k"
+	}
+	d.backend.Stick = stick
+}
+
 var authRequestSanitizeHeaderRegex = regexp.MustCompile(`[^a-zA-Z0-9]`)
 var authRequestSrcIsVar = regexp.MustCompile(`^(proc|sess|txn|req|res)\.`)
 
@@ -121,9 +325,10 @@ func globToLuaPattern(s string) string {
 var (
 	lookupHost func(host string) (addrs []string, err error) = net.LookupHost
 
-	validURLRegex    = regexp.MustCompile(`^[^"' ]*$`)
-	validMethodRegex = regexp.MustCompile(`^([A-Za-z]+|\*)$`)
-	authHeaderRegex  = regexp.MustCompile(`^[A-Za-z0-9-]+(:[^:'" ]+)?$`)
+	validURLRegex       = regexp.MustCompile(`^[^"' ]*$`)
+	validMethodRegex    = regexp.MustCompile(`^([A-Za-z]+|\*)$`)
+	authHeaderRegex     = regexp.MustCompile(`^[A-Za-z0-9-]+(:[^:'" ]+)?$`)
+	rewriteCaptureRegex = regexp.MustCompile(`\$([1-9])`)
 )
 
 func (c *updater) buildBackendAuthExternal(d *backData) {
@@ -197,17 +402,11 @@ func (c *updater) buildBackendAuthExternal(d *backData) {
 			continue
 		}
 		// TODO track
-		authBackendName, err := c.haproxy.Frontend().AcquireAuthBackendName(backend.BackendID())
+		authBackendName, err := c.acquireAuthBackendName(backend.BackendID())
 		if err != nil {
-			// clean up and try again
-			used := c.haproxy.Backends().BuildUsedAuthBackends()
-			c.haproxy.Frontend().RemoveAuthBackendExcept(used)
-			authBackendName, err = c.haproxy.Frontend().AcquireAuthBackendName(backend.BackendID())
-			if err != nil {
-				// TODO remove backend if not used elsewhere
-				c.logger.Warn("ignoring auth URL on %v: %v", url.Source, err)
-				continue
-			}
+			// TODO remove backend if not used elsewhere
+			c.logger.Warn("ignoring auth URL on %v: %v", url.Source, err)
+			continue
 		}
 
 		m := config.Get(ingtypes.BackAuthMethod)
@@ -252,6 +451,7 @@ func (c *updater) buildBackendAuthExternal(d *backData) {
 
 		path.AuthExternal.AuthBackendName = authBackendName
 		path.AuthExternal.AuthPath = urlPath
+		path.AuthExternal.ForwardHeaders = config.Get(ingtypes.BackAuthForwardHeaders).Bool()
 		path.AuthExternal.Method = method
 		path.AuthExternal.HeadersRequest = hdrRequest
 		path.AuthExternal.HeadersSucceed = hdrSucceed
@@ -284,6 +484,7 @@ func (c *updater) buildBackendAuthHTTP(d *backData) {
 			)
 			if err != nil {
 				c.logger.Error("error reading basic authentication on %v: %v", authSecret.Source, err)
+				authSecret.Source.RecordWarning(c.cache, "InvalidSecret", fmt.Sprintf("basic authentication was ignored: %v", err))
 				continue
 			}
 			userstr := string(userb)
@@ -291,7 +492,24 @@ func (c *updater) buildBackendAuthHTTP(d *backData) {
 			for _, err := range errs {
 				c.logger.Warn("ignoring malformed usr/passwd on secret '%s', declared on %v: %v", secretName, authSecret.Source, err)
 			}
-			userlist = c.haproxy.Userlists().Replace(listName, users)
+			var groups []hatypes.UserGroup
+			// groups are optional, a missing key means that the userlist has no groups
+			groupb, err := c.cache.GetSecretContent(
+				authSecret.Source.Namespace,
+				authSecret.Value,
+				"groups",
+				convtypes.TrackingTarget{
+					Backend:  d.backend.BackendID(),
+					Userlist: listName,
+				},
+			)
+			if err == nil {
+				groups, errs = extractUserGroups(users, string(groupb))
+				for _, err := range errs {
+					c.logger.Warn("ignoring malformed group on secret '%s', declared on %v: %v", secretName, authSecret.Source, err)
+				}
+			}
+			userlist = c.haproxy.Userlists().Replace(listName, users, groups)
 			if len(users) == 0 {
 				c.logger.Warn("userlist on %v for basic authentication is empty", authSecret.Source)
 			}
@@ -310,16 +528,36 @@ func (c *updater) buildBackendAuthHTTP(d *backData) {
 		} else if authRealm.Value != "" {
 			realm = authRealm.Value
 		}
+		var groups []string
+		authGroups := config.Get(ingtypes.BackAuthGroups)
+		for _, group := range strings.FieldsFunc(authGroups.Value, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		}) {
+			if !userGroupRegex.MatchString(group) {
+				c.logger.Warn("ignoring invalid group name on %v: %s", authGroups.Source, group)
+				continue
+			}
+			if !userlist.HasGroup(group) {
+				// a missing group doesn't match any user, so it's safe to keep it
+				c.logger.Warn("group '%s' declared on %v was not found on secret '%s'", group, authGroups.Source, secretName)
+			}
+			groups = append(groups, group)
+		}
 		path.AuthHTTP.UserlistName = userlist.Name
 		path.AuthHTTP.Realm = realm
+		path.AuthHTTP.Groups = groups
 	}
 }
 
+var userGroupRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
 func extractUserlist(source, secret, users string) ([]hatypes.User, []error) {
 	var userlist []hatypes.User
 	var err []error
+	dupUsers := map[string]bool{}
 	for i, usr := range strings.Split(users, "\n") {
-		if usr == "" {
+		usr = strings.TrimSpace(usr)
+		if usr == "" || usr[0] == '#' {
 			continue
 		}
 		sep := strings.Index(usr, ":")
@@ -336,6 +574,10 @@ func extractUserlist(source, secret, users string) ([]hatypes.User, []error) {
 			err = append(err, fmt.Errorf("missing password of user '%s' line %d", username, i+1))
 			continue
 		}
+		if dupUsers[username] {
+			err = append(err, fmt.Errorf("duplicated user '%s' line %d", username, i+1))
+			continue
+		}
 		var user hatypes.User
 		if string(usr[sep+1]) == ":" {
 			// usr::pwd
@@ -346,17 +588,201 @@ func extractUserlist(source, secret, users string) ([]hatypes.User, []error) {
 			}
 		} else {
 			// usr:pwd
+			passwd := usr[sep+1:]
+			if !isCryptHash(passwd) {
+				err = append(err, fmt.Errorf("unsupported password hash of user '%s' line %d", username, i+1))
+				continue
+			}
 			user = hatypes.User{
 				Name:      username,
-				Passwd:    usr[sep+1:],
+				Passwd:    passwd,
 				Encrypted: true,
 			}
 		}
+		dupUsers[username] = true
 		userlist = append(userlist, user)
 	}
 	return userlist, err
 }
 
+// isCryptHash returns false if hash uses an htpasswd format that crypt(3)
+// cannot verify: Apache's MD5 ($apr1$) and the base64 encoded SHA-1 ones.
+// bcrypt, SHA-256, SHA-512, MD5 and DES crypt hashes are accepted.
+func isCryptHash(hash string) bool {
+	return !strings.HasPrefix(hash, "$apr1$") &&
+		!strings.HasPrefix(hash, "{SHA}") &&
+		!strings.HasPrefix(hash, "{SSHA}")
+}
+
+// extractUserGroups parses an htgroup like content, one group per line
+// in the format `<group>: <user1> <user2> ...`. Users should be declared
+// in the userlist.
+func extractUserGroups(users []hatypes.User, groups string) ([]hatypes.UserGroup, []error) {
+	var usergroups []hatypes.UserGroup
+	var err []error
+	userMap := make(map[string]bool, len(users))
+	for _, user := range users {
+		userMap[user.Name] = true
+	}
+	dupGroups := map[string]bool{}
+	for i, line := range strings.Split(groups, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		sep := strings.Index(line, ":")
+		if sep == -1 {
+			err = append(err, fmt.Errorf("missing colon after group name line %d", i+1))
+			continue
+		}
+		name := strings.TrimSpace(line[:sep])
+		if !userGroupRegex.MatchString(name) {
+			err = append(err, fmt.Errorf("invalid group name '%s' line %d", name, i+1))
+			continue
+		}
+		if dupGroups[name] {
+			err = append(err, fmt.Errorf("duplicated group '%s' line %d", name, i+1))
+			continue
+		}
+		dupGroups[name] = true
+		group := hatypes.UserGroup{Name: name}
+		for _, user := range strings.FieldsFunc(line[sep+1:], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		}) {
+			if !userMap[user] {
+				err = append(err, fmt.Errorf("user '%s' of group '%s' not found line %d", user, name, i+1))
+				continue
+			}
+			group.Users = append(group.Users, user)
+		}
+		usergroups = append(usergroups, group)
+	}
+	return usergroups, err
+}
+
+var (
+	hashHeaderRegex   = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	hashURLParamRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+func (c *updater) buildBackendBalanceHash(d *backData) {
+	hashKey := d.mapper.Get(ingtypes.BackBalanceHashKey)
+	factor := d.mapper.Get(ingtypes.BackBalanceHashFactor)
+	if hashKey.Value == "" {
+		if factor.Value != "" {
+			c.logger.Warn("ignoring balance-hash-balance-factor on %v: balance-hash-key is not configured", factor.Source)
+		}
+		return
+	}
+	keyValue := strings.SplitN(hashKey.Value, ":", 2)
+	var algorithm string
+	switch keyValue[0] {
+	case "header":
+		if len(keyValue) == 2 && hashHeaderRegex.MatchString(keyValue[1]) {
+			algorithm = "hdr(" + keyValue[1] + ")"
+		}
+	case "url-param":
+		if len(keyValue) == 2 && hashURLParamRegex.MatchString(keyValue[1]) {
+			algorithm = "url_param " + keyValue[1]
+		}
+	case "path":
+		if len(keyValue) == 1 {
+			algorithm = "uri path-only"
+		}
+	case "uri":
+		if len(keyValue) == 1 {
+			algorithm = "uri"
+		}
+	}
+	if algorithm == "" {
+		c.logger.Warn("ignoring invalid balance-hash-key on %v: %s", hashKey.Source, hashKey.Value)
+		return
+	}
+	if d.backend.ModeTCP {
+		c.logger.Warn("ignoring balance-hash-key on %v: cannot be used on a TCP backend", hashKey.Source)
+		return
+	}
+	d.backend.BalanceAlgorithm = algorithm
+	d.backend.HashBalance.Type = "consistent"
+	if factor.Value != "" {
+		if value, err := strconv.Atoi(factor.Value); err == nil && (value == 0 || value > 100) {
+			d.backend.HashBalance.BalanceFactor = value
+		} else {
+			c.logger.Warn("ignoring invalid balance-hash-balance-factor on %v, should be 0 or greater than 100: %s", factor.Source, factor.Value)
+		}
+	}
+}
+
+var blockUserAgentRegex = regexp.MustCompile(`^[^\s"'#\\,(){}]+$`)
+
+func (c *updater) buildBackendBlockUserAgents(d *backData) {
+	if d.backend.ModeTCP {
+		return
+	}
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link)
+		patternsCfg := config.Get(ingtypes.BackBlockUserAgents)
+		configMapCfg := config.Get(ingtypes.BackBlockUserAgentsCM)
+		var patterns []string
+		dupPatterns := map[string]bool{}
+		addPatterns := func(source interface{}, content string) {
+			for _, line := range utils.LineToSlice(content) {
+				if i := strings.Index(line, "#"); i >= 0 {
+					line = line[:i]
+				}
+				for _, pattern := range strings.FieldsFunc(line, func(r rune) bool {
+					return r == ',' || r == ' ' || r == '\t' || r == '\r'
+				}) {
+					if !blockUserAgentRegex.MatchString(pattern) {
+						c.logger.Warn("skipping invalid user-agent pattern on %v: %s", source, pattern)
+						continue
+					}
+					if !dupPatterns[pattern] {
+						dupPatterns[pattern] = true
+						patterns = append(patterns, pattern)
+					}
+				}
+			}
+		}
+		addPatterns(patternsCfg.Source, patternsCfg.Value)
+		if configMapCfg.Value != "" {
+			namespace := c.cache.GetPodNamespace()
+			if configMapCfg.Source != nil {
+				namespace = configMapCfg.Source.Namespace
+			}
+			name := configMapCfg.Value
+			if !strings.Contains(name, "/") {
+				name = namespace + "/" + name
+			}
+			data, err := c.cache.GetConfigMapData(namespace, configMapCfg.Value, convtypes.TrackingTarget{Backend: d.backend.BackendID()})
+			if err != nil {
+				c.logger.Warn("ignoring user-agent list on %v: %v", configMapCfg.Source, err)
+			}
+			keys := make([]string, 0, len(data))
+			for key := range data {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				addPatterns(fmt.Sprintf("configmap '%s' key '%s'", name, key), data[key])
+			}
+		}
+		if len(patterns) == 0 {
+			continue
+		}
+		actionCfg := config.Get(ingtypes.BackBlockUserAgentsAction)
+		action := actionCfg.Value
+		if action != "deny" && action != "tarpit" {
+			c.logger.Warn("ignoring invalid user-agent block action on %v: %s", actionCfg.Source, action)
+			action = "deny"
+		}
+		path.BlockUA = hatypes.BlockUserAgents{
+			Action:   action,
+			Patterns: patterns,
+		}
+	}
+}
+
 func (c *updater) buildBackendBlueGreenBalance(d *backData) {
 	balance := d.mapper.Get(ingtypes.BackBlueGreenBalance)
 	if balance.Source == nil || balance.Value == "" {
@@ -458,7 +884,80 @@ func (c *updater) buildBackendBlueGreenBalance(d *backData) {
 const validLabelRegexStr = "([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]"
 const bluegreenSeparator = ":"
 
-var validNamePairRegex = regexp.MustCompile(`^` + validLabelRegexStr + bluegreenSeparator + validLabelRegexStr + `$`)
+var (
+	validNamePairRegex    = regexp.MustCompile(`^` + validLabelRegexStr + bluegreenSeparator + validLabelRegexStr + `$`)
+	validLabelPairRegex   = regexp.MustCompile(`^` + validLabelRegexStr + `=` + validLabelRegexStr + `$`)
+	validCanaryNameRegex  = regexp.MustCompile(`^` + validLabelRegexStr + `$`)
+	validCanaryValueRegex = regexp.MustCompile(`^[^\s"'#\\]+$`)
+)
+
+func (c *updater) buildBackendBlueGreenCanary(d *backData) {
+	canary := d.mapper.Get(ingtypes.BackBlueGreenCanary)
+	if canary.Value == "" {
+		return
+	}
+	if !validLabelPairRegex.MatchString(canary.Value) {
+		c.logger.Error("invalid LabelName=LabelValue pair on %s: %s", canary.Source, canary.Value)
+		return
+	}
+	readNameValue := func(key string) (name, value string, ok bool) {
+		config := d.mapper.Get(key)
+		if config.Value == "" {
+			return "", "", true
+		}
+		nameValue := strings.SplitN(config.Value, bluegreenSeparator, 2)
+		if len(nameValue) != 2 || !validCanaryNameRegex.MatchString(nameValue[0]) || !validCanaryValueRegex.MatchString(nameValue[1]) {
+			c.logger.Error("invalid Name:Value pair on %s: %s", config.Source, config.Value)
+			return "", "", false
+		}
+		return nameValue[0], nameValue[1], true
+	}
+	cookieName, cookieValue, okCookie := readNameValue(ingtypes.BackBlueGreenCanaryCookie)
+	headerName, headerValue, okHeader := readNameValue(ingtypes.BackBlueGreenCanaryHeader)
+	if !okCookie || !okHeader {
+		return
+	}
+	if cookieName == "" && headerName == "" {
+		c.logger.Warn("ignoring blue/green canary on %s: missing canary cookie or header", canary.Source)
+		return
+	}
+	var regex bool
+	switch match := d.mapper.Get(ingtypes.BackBlueGreenCanaryMatch); match.Value {
+	case "regex":
+		regex = true
+	case "exact":
+	default:
+		c.logger.Warn("unsupported blue/green canary match '%s' on %s, falling back to 'exact'", match.Value, match.Source)
+	}
+	label := strings.Split(canary.Value, "=")
+	var count int
+	for _, ep := range d.backend.Endpoints {
+		if !ep.Enabled {
+			continue
+		}
+		if pod, err := c.cache.GetPod(ep.TargetRef); err == nil {
+			if pod.Labels[label[0]] == label[1] {
+				ep.Canary = true
+				count++
+			}
+		} else {
+			if ep.TargetRef == "" {
+				err = fmt.Errorf("endpoint does not reference a pod")
+			}
+			c.logger.Warn("endpoint '%s:%d' on backend '%s' was removed from blue/green canary: %v", ep.IP, ep.Port, d.backend.ID, err)
+		}
+	}
+	if count == 0 {
+		c.logger.InfoV(3, "blue/green canary label '%s' on %v does not reference any endpoint", canary.Value, canary.Source)
+	}
+	d.backend.BlueGreen.Canary = hatypes.BlueGreenCanary{
+		CookieName:  cookieName,
+		CookieValue: cookieValue,
+		HeaderName:  headerName,
+		HeaderValue: headerValue,
+		Regex:       regex,
+	}
+}
 
 func (c *updater) buildBackendBlueGreenSelector(d *backData) {
 	cookie := d.mapper.Get(ingtypes.BackBlueGreenCookie)
@@ -526,6 +1025,98 @@ func (c *updater) buildBackendBodySize(d *backData) {
 	}
 }
 
+func (c *updater) buildBackendCache(d *backData) {
+	if d.backend.ModeTCP {
+		return
+	}
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link)
+		if !config.Get(ingtypes.BackCache).Bool() {
+			continue
+		}
+		totalCfg := config.Get(ingtypes.BackCacheTotalMaxSize)
+		total, err := utils.SizeSuffixToInt64(totalCfg.Value)
+		// haproxy configures the total size in megabytes, up to 4095
+		const mb = 1024 * 1024
+		totalMB := (total + mb - 1) / mb
+		if err != nil || totalMB < 1 || totalMB > 4095 {
+			c.logger.Warn("ignoring cache on %v due to invalid total max size: %s", totalCfg.Source, totalCfg.Value)
+			continue
+		}
+		maxAgeCfg := config.Get(ingtypes.BackCacheMaxAge)
+		maxAge, err := time.ParseDuration(maxAgeCfg.Value)
+		if err != nil || maxAge < time.Second {
+			c.logger.Warn("ignoring cache on %v due to invalid max age: %s", maxAgeCfg.Source, maxAgeCfg.Value)
+			continue
+		}
+		var maxObject int64
+		if maxObjectCfg := config.Get(ingtypes.BackCacheMaxObjectSize); maxObjectCfg.Value != "" {
+			maxObject, err = utils.SizeSuffixToInt64(maxObjectCfg.Value)
+			if err != nil || maxObject < 1 || maxObject > totalMB*mb/2 {
+				c.logger.Warn("ignoring invalid cache max object size on %v: %s", maxObjectCfg.Source, maxObjectCfg.Value)
+				maxObject = 0
+			}
+		}
+		headerCfg := config.Get(ingtypes.BackCacheStatusHeader)
+		header := headerCfg.Value
+		if header != "" && !httpHeaderNameRegex.MatchString(header) {
+			c.logger.Warn("ignoring invalid cache status header name on %v: %s", headerCfg.Source, header)
+			header = ""
+		}
+		path.Cache = hatypes.Cache{
+			MaxAge:        int(maxAge / time.Second),
+			MaxObjectSize: maxObject,
+			StatusHeader:  header,
+			TotalMaxSize:  int(totalMB),
+		}
+	}
+}
+
+var (
+	compressionAlgos     = map[string]bool{"deflate": true, "gzip": true, "identity": true, "raw-deflate": true}
+	compressionTypeRegex = regexp.MustCompile(`^[a-z0-9.+-]+/[a-z0-9.+*-]+$`)
+)
+
+func (c *updater) buildBackendCompression(d *backData) {
+	if d.backend.ModeTCP || !d.mapper.Get(ingtypes.BackCompression).Bool() {
+		return
+	}
+	algoCfg := d.mapper.Get(ingtypes.BackCompressionAlgo)
+	var algos []string
+	for _, algo := range strings.Fields(strings.ReplaceAll(strings.ToLower(algoCfg.Value), ",", " ")) {
+		if !compressionAlgos[algo] {
+			c.logger.Warn("ignoring unsupported compression algorithm on %v: %s", algoCfg.Source, algo)
+			continue
+		}
+		algos = append(algos, algo)
+	}
+	if len(algos) == 0 {
+		c.logger.Warn("ignoring compression on %v: no supported algorithm was configured", algoCfg.Source)
+		return
+	}
+	typesCfg := d.mapper.Get(ingtypes.BackCompressionTypes)
+	var types []string
+	for _, t := range strings.Fields(strings.ReplaceAll(strings.ToLower(typesCfg.Value), ",", " ")) {
+		if !compressionTypeRegex.MatchString(t) {
+			c.logger.Warn("ignoring invalid compression type on %v: %s", typesCfg.Source, t)
+			continue
+		}
+		types = append(types, t)
+	}
+	var minSize int64
+	if minSizeCfg := d.mapper.Get(ingtypes.BackCompressionMinSize); minSizeCfg.Value != "" {
+		value, err := utils.SizeSuffixToInt64(minSizeCfg.Value)
+		if err != nil || value < 0 {
+			c.logger.Warn("ignoring invalid compression min size on %v: %s", minSizeCfg.Source, minSizeCfg.Value)
+		} else {
+			minSize = value
+		}
+	}
+	d.backend.Compression.Algos = algos
+	d.backend.Compression.MinSize = minSize
+	d.backend.Compression.Types = types
+}
+
 func (c *updater) buildBackendCors(d *backData) {
 	for _, path := range d.backend.Paths {
 		config := d.mapper.GetConfig(path.Link)
@@ -544,6 +1135,55 @@ func (c *updater) buildBackendCors(d *backData) {
 	}
 }
 
+// buildBackendCustomConfig reads the backend snippet, restricted by the snippet
+// policy of the namespace. If snippet check is enabled, an invalid snippet is
+// ignored and the resource that declares it receives an event, so a single
+// misconfigured resource doesn't prevent haproxy to reload.
+func (c *updater) buildBackendCustomConfig(d *backData) {
+	snippet := c.validateSnippet(d.mapper, ingtypes.BackConfigBackend, "backend")
+	config := d.mapper.Get(ingtypes.BackConfigBackend)
+	checker := c.options.SnippetChecker
+	if len(snippet) == 0 || checker == nil || !d.mapper.Get(ingtypes.GlobalConfigSnippetCheck).Bool() {
+		d.backend.CustomConfig = snippet
+		return
+	}
+	if err := checker.CheckBackend(snippet); err != nil {
+		c.logger.Warn("ignoring config-backend on %v: invalid configuration snippet: %v", config.Source, err)
+		config.Source.RecordWarning(c.cache, "InvalidSnippet", fmt.Sprintf("config-backend was ignored: %v", err))
+		return
+	}
+	d.backend.CustomConfig = snippet
+}
+
+func (c *updater) buildBackendDenyMethods(d *backData) {
+	if d.backend.ModeTCP {
+		return
+	}
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link)
+		methodsCfg := config.Get(ingtypes.BackDenyMethods)
+		if methodsCfg.Value == "" {
+			continue
+		}
+		methods, err := ingutils.ParseMethods(methodsCfg.Value)
+		if err != nil {
+			c.logger.Warn("ignoring deny methods on %v: %v", methodsCfg.Source, err)
+			continue
+		}
+		statusCfg := config.Get(ingtypes.BackDenyMethodsStatus)
+		status := 405
+		if denyStatusRegex.MatchString(statusCfg.Value) {
+			status = statusCfg.Int()
+		} else {
+			c.logger.Warn("ignoring invalid deny status code on %v, using 405 instead: %s", statusCfg.Source, statusCfg.Value)
+		}
+		path.DenyMethods = hatypes.DenyMethods{
+			Methods: methods,
+			Status:  status,
+		}
+	}
+}
+
 func (c *updater) buildBackendDNS(d *backData) {
 	resolverName := d.mapper.Get(ingtypes.BackUseResolver).Value
 	if resolverName == "" {
@@ -579,25 +1219,152 @@ func (c *updater) buildBackendAgentCheck(d *backData) {
 	d.backend.AgentCheck.Send = d.mapper.Get(ingtypes.BackAgentCheckSend).Value
 }
 
-func (c *updater) buildBackendHealthCheck(d *backData) {
-	d.backend.HealthCheck.Addr = d.mapper.Get(ingtypes.BackHealthCheckAddr).Value
-	d.backend.HealthCheck.FallCount = d.mapper.Get(ingtypes.BackHealthCheckFallCount).Int()
-	interval := d.mapper.Get(ingtypes.BackHealthCheckInterval)
-	if interval.Value == "" {
-		interval = d.mapper.Get(ingtypes.BackBackendCheckInterval)
-	}
-	d.backend.HealthCheck.Interval = c.validateTime(interval)
-	d.backend.HealthCheck.Port = d.mapper.Get(ingtypes.BackHealthCheckPort).Int()
-	d.backend.HealthCheck.RiseCount = d.mapper.Get(ingtypes.BackHealthCheckRiseCount).Int()
-	d.backend.HealthCheck.URI = d.mapper.Get(ingtypes.BackHealthCheckURI).Value
+// status codes supported by haproxy's errorfile
+var errorPageCodes = map[int]bool{
+	200: true, 400: true, 401: true, 403: true, 404: true, 405: true, 407: true, 408: true, 410: true,
+	413: true, 425: true, 429: true, 500: true, 501: true, 502: true, 503: true, 504: true,
 }
 
-func (c *updater) buildBackendHeaders(d *backData) {
-	headers := d.mapper.Get(ingtypes.BackHeaders)
-	if headers.Value == "" {
+// the whole response should fit in the buffer reserved for rewrites
+const errorPageMaxSize = 7168
+
+func (c *updater) buildBackendErrorPages(d *backData) {
+	config := d.mapper.Get(ingtypes.BackErrorPages)
+	if config.Value == "" {
 		return
 	}
-	for _, header := range utils.LineToSlice(headers.Value) {
+	if d.backend.ModeTCP {
+		c.logger.Warn("ignoring error-pages on %v: cannot be used on a TCP backend", config.Source)
+		return
+	}
+	namespace := c.cache.GetPodNamespace()
+	if config.Source != nil {
+		namespace = config.Source.Namespace
+	}
+	data, err := c.cache.GetConfigMapData(namespace, config.Value, convtypes.TrackingTarget{Backend: d.backend.BackendID()})
+	if err != nil {
+		c.logger.Warn("ignoring error-pages on %v: %v", config.Source, err)
+		return
+	}
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pages []*hatypes.ErrorPage
+	for _, key := range keys {
+		code, err := strconv.Atoi(key)
+		if err != nil || !errorPageCodes[code] {
+			c.logger.Warn("ignoring error page '%s' on %v: unsupported status code", key, config.Source)
+			continue
+		}
+		content := data[key]
+		if len(content) > errorPageMaxSize {
+			c.logger.Warn("ignoring error page '%s' on %v: content is larger than %d bytes", key, config.Source, errorPageMaxSize)
+			continue
+		}
+		pages = append(pages, &hatypes.ErrorPage{
+			Code:    code,
+			Content: content,
+		})
+	}
+	d.backend.ErrorPages = pages
+}
+
+func (c *updater) buildBackendHealthCheck(d *backData) {
+	d.backend.HealthCheck.Addr = d.mapper.Get(ingtypes.BackHealthCheckAddr).Value
+	d.backend.HealthCheck.FallCount = d.mapper.Get(ingtypes.BackHealthCheckFallCount).Int()
+	interval := d.mapper.Get(ingtypes.BackHealthCheckInterval)
+	if interval.Value == "" {
+		interval = d.mapper.Get(ingtypes.BackBackendCheckInterval)
+	}
+	d.backend.HealthCheck.Interval = c.validateTime(interval)
+	d.backend.HealthCheck.Port = d.mapper.Get(ingtypes.BackHealthCheckPort).Int()
+	d.backend.HealthCheck.RiseCount = d.mapper.Get(ingtypes.BackHealthCheckRiseCount).Int()
+	if timeout := d.mapper.Get(ingtypes.BackHealthCheckTimeout); timeout.Value != "" {
+		d.backend.HealthCheck.Timeout = c.validateTime(timeout)
+	}
+	uri := d.mapper.Get(ingtypes.BackHealthCheckURI)
+	method := d.mapper.Get(ingtypes.BackHealthCheckMethod)
+	host := d.mapper.Get(ingtypes.BackHealthCheckHost)
+	headers := d.mapper.Get(ingtypes.BackHealthCheckHeaders)
+	expect := d.mapper.Get(ingtypes.BackHealthCheckExpect)
+	if tcpSequence := d.mapper.Get(ingtypes.BackHealthCheckTCPSequence); tcpSequence.Value != "" {
+		rules, err := convutils.ParseTCPCheckSequence(utils.LineToSlice(tcpSequence.Value))
+		if err != nil {
+			c.logger.Warn("ignoring health-check-tcp-sequence on %v: %v", tcpSequence.Source, err)
+		} else if uri.Value != "" {
+			c.logger.Warn("ignoring health-check-tcp-sequence on %v: health-check-uri is also configured", tcpSequence.Source)
+		} else {
+			d.backend.HealthCheck.TCPCheck = rules
+		}
+	}
+	if uri.Value == "" {
+		for _, cfg := range []*ConfigValue{method, host, headers, expect} {
+			if cfg.Value != "" && cfg.Source != nil {
+				c.logger.Warn("ignoring http health check options on %v: health-check-uri is not configured", cfg.Source)
+				break
+			}
+		}
+		return
+	}
+	d.backend.HealthCheck.URI = uri.Value
+	if method.Value != "" {
+		if healthCheckMethodRegex.MatchString(method.Value) {
+			d.backend.HealthCheck.Method = strings.ToUpper(method.Value)
+		} else {
+			c.logger.Warn("ignoring invalid health-check-method on %v: %s", method.Source, method.Value)
+		}
+	}
+	if host.Value != "" {
+		if healthCheckHostRegex.MatchString(host.Value) {
+			d.backend.HealthCheck.Host = host.Value
+		} else {
+			c.logger.Warn("ignoring invalid health-check-host on %v: %s", host.Source, host.Value)
+		}
+	}
+	for _, header := range utils.LineToSlice(headers.Value) {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		idx := strings.Index(header, ":")
+		if idx <= 0 {
+			c.logger.Warn("ignoring health check header without name or value on %v: %s", headers.Source, header)
+			continue
+		}
+		name := strings.TrimSpace(header[:idx])
+		value := strings.TrimSpace(header[idx+1:])
+		if !httpHeaderNameRegex.MatchString(name) || value == "" || strings.ContainsAny(value, "\"\\") {
+			c.logger.Warn("ignoring invalid health check header on %v: %s", headers.Source, header)
+			continue
+		}
+		d.backend.HealthCheck.Headers = append(d.backend.HealthCheck.Headers, &hatypes.BackendHeader{
+			Name:  name,
+			Value: value,
+		})
+	}
+	if expect.Value != "" {
+		if healthCheckExpectRegex.MatchString(expect.Value) {
+			d.backend.HealthCheck.Expect = strings.Join(strings.Fields(expect.Value), " ")
+		} else {
+			c.logger.Warn("ignoring invalid health-check-expect on %v: %s", expect.Source, expect.Value)
+		}
+	}
+}
+
+var (
+	healthCheckMethodRegex = regexp.MustCompile(`^[A-Za-z]+$`)
+	healthCheckHostRegex   = regexp.MustCompile(`^[A-Za-z0-9_.-]+(:[0-9]+)?$`)
+	healthCheckExpectRegex = regexp.MustCompile(`^\s*(!\s*)?(status\s+[0-9]{3}(-[0-9]{3})?(,[0-9]{3}(-[0-9]{3})?)*|(rstatus|string|rstring)\s+[^\s"'\\]+)\s*$`)
+)
+
+func (c *updater) buildBackendHeaders(d *backData) {
+	headers := d.mapper.Get(ingtypes.BackHeaders)
+	if headers.Value == "" {
+		return
+	}
+	for _, header := range utils.LineToSlice(headers.Value) {
 		header = strings.TrimSpace(header)
 		if header == "" {
 			continue
@@ -629,10 +1396,377 @@ func (c *updater) buildBackendHSTS(d *backData) {
 	}
 }
 
+var httpHeaderNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+func (c *updater) buildBackendHTTPHeaders(d *backData) {
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link)
+		path.HTTPHeaders = hatypes.HTTPHeaders{
+			AfterResponseAdd: c.readHTTPHeaderValues(config.Get(ingtypes.BackAfterResponseHdrsAdd)),
+			AfterResponseDel: c.readHTTPHeaderNames(config.Get(ingtypes.BackAfterResponseHdrsDel)),
+			AfterResponseSet: c.readHTTPHeaderValues(config.Get(ingtypes.BackAfterResponseHdrsSet)),
+			RequestAdd:       c.readHTTPHeaderValues(config.Get(ingtypes.BackRequestHeadersAdd)),
+			RequestDel:       c.readHTTPHeaderNames(config.Get(ingtypes.BackRequestHeadersDel)),
+			RequestSet:       c.readHTTPHeaderValues(config.Get(ingtypes.BackRequestHeadersSet)),
+			ResponseAdd:      c.readHTTPHeaderValues(config.Get(ingtypes.BackResponseHeadersAdd)),
+			ResponseDel:      c.readHTTPHeaderNames(config.Get(ingtypes.BackResponseHeadersDel)),
+			ResponseSet:      c.readHTTPHeaderValues(config.Get(ingtypes.BackResponseHeadersSet)),
+		}
+	}
+}
+
+func (c *updater) readHTTPHeaderNames(names *ConfigValue) []string {
+	var headers []string
+	for _, name := range utils.Split(names.Value, ",") {
+		if name == "" {
+			continue
+		}
+		if !httpHeaderNameRegex.MatchString(name) {
+			c.logger.Warn("ignoring invalid header name on %v: %s", names.Source, name)
+			continue
+		}
+		headers = append(headers, name)
+	}
+	return headers
+}
+
+// readHTTPHeaderValues parses a multi-line list of headers, in the same
+// format of the `headers` configuration key. Values are used literally, so
+// they are single quoted and the log-format char is escaped.
+func (c *updater) readHTTPHeaderValues(values *ConfigValue) []hatypes.BackendHeader {
+	var headers []hatypes.BackendHeader
+	for _, header := range utils.LineToSlice(values.Value) {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		idx := strings.IndexAny(header, ": ")
+		if idx <= 0 {
+			c.logger.Warn("ignored missing header name or value on %v: %s", values.Source, header)
+			continue
+		}
+		name := strings.TrimRight(header[:idx], ":")
+		if !httpHeaderNameRegex.MatchString(name) {
+			c.logger.Warn("ignoring invalid header name on %v: %s", values.Source, name)
+			continue
+		}
+		value := strings.TrimSpace(header[idx+1:])
+		value = strings.ReplaceAll(value, "%", "%%")
+		value = "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+		headers = append(headers, hatypes.BackendHeader{
+			Name:  name,
+			Value: value,
+		})
+	}
+	return headers
+}
+
+var (
+	jwtAlgorithmRegex = regexp.MustCompile(`^(RS|ES|PS)(256|384|512)$`)
+	jwtClaimRegex     = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	jwtHeaderRegex    = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	jwtValueRegex     = regexp.MustCompile(`^[^\s"'\\]+$`)
+)
+
+func (c *updater) buildBackendJWT(d *backData) {
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link)
+		keySecret := config.Get(ingtypes.BackJWTKeySecret)
+		jwksURL := config.Get(ingtypes.BackJWTJWKSURL)
+		hasKeySecret := keySecret.Source != nil && keySecret.Value != ""
+		hasJWKSURL := jwksURL.Source != nil && jwksURL.Value != ""
+		if !hasKeySecret && !hasJWKSURL {
+			continue
+		}
+		source := keySecret.Source
+		if !hasKeySecret {
+			source = jwksURL.Source
+		}
+		if hasKeySecret && hasJWKSURL {
+			c.logger.Warn("ignoring JWT validation on %v: jwt-key-secret and jwt-jwks-url cannot be used together", source)
+			continue
+		}
+		if d.backend.ModeTCP {
+			c.logger.Warn("ignoring JWT validation on %v: backend is in tcp mode", source)
+			continue
+		}
+		alg := config.Get(ingtypes.BackJWTAlgorithm)
+		if !jwtAlgorithmRegex.MatchString(alg.Value) {
+			c.logger.Warn("ignoring JWT validation on %v: unsupported algorithm: %s", alg.Source, alg.Value)
+			continue
+		}
+		issuer := config.Get(ingtypes.BackJWTIssuer)
+		if issuer.Value != "" && !jwtValueRegex.MatchString(issuer.Value) {
+			c.logger.Warn("ignoring JWT validation on %v: invalid issuer: %s", issuer.Source, issuer.Value)
+			continue
+		}
+		audience := config.Get(ingtypes.BackJWTAudience)
+		if audience.Value != "" && !jwtValueRegex.MatchString(audience.Value) {
+			c.logger.Warn("ignoring JWT validation on %v: invalid audience: %s", audience.Source, audience.Value)
+			continue
+		}
+		jwt := hatypes.JWT{
+			Algorithm: alg.Value,
+			Audience:  audience.Value,
+			Issuer:    issuer.Value,
+		}
+		if hasKeySecret {
+			keyFile, err := c.cache.GetPublicKeySecretPath(
+				keySecret.Source.Namespace,
+				keySecret.Value,
+				convtypes.TrackingTarget{Backend: d.backend.BackendID()},
+			)
+			if err != nil {
+				c.logger.Error("error reading JWT public key on %v: %v", keySecret.Source, err)
+				keySecret.Source.RecordWarning(c.cache, "InvalidSecret", fmt.Sprintf("JWT validation was ignored: %v", err))
+				continue
+			}
+			jwt.KeyFilename = keyFile.Filename
+			jwt.KeyHash = keyFile.SHA1Hash
+		} else {
+			if u, err := url.Parse(jwksURL.Value); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				c.logger.Warn("ignoring JWT validation on %v: invalid JWKS URL: %s", jwksURL.Source, jwksURL.Value)
+				continue
+			}
+			// a key set that cannot be read denies all the requests,
+			// it is read again on the next refresh
+			keys, err := c.cache.GetJWKSKeys(jwksURL.Value)
+			if err != nil {
+				c.logger.Error("error reading JWKS on %v, requests will be denied until the keys are read: %v", jwksURL.Source, err)
+			}
+			for _, key := range keys {
+				if key.KID != "" && !jwtValueRegex.MatchString(key.KID) {
+					c.logger.Warn("ignoring key of the JWKS on %v: invalid kid: %s", jwksURL.Source, key.KID)
+					continue
+				}
+				jwt.JWKS = append(jwt.JWKS, hatypes.JWTKey{
+					KID:      key.KID,
+					Filename: key.File.Filename,
+					Hash:     key.File.SHA1Hash,
+				})
+			}
+			jwt.JWKSURL = jwksURL.Value
+		}
+		var claimHeaders []hatypes.BackendHeader
+		claims := config.Get(ingtypes.BackJWTClaimsHeaders)
+		for _, claimHeader := range utils.Split(claims.Value, ",") {
+			if claimHeader == "" {
+				continue
+			}
+			idx := strings.Index(claimHeader, ":")
+			if idx <= 0 || !jwtClaimRegex.MatchString(claimHeader[:idx]) || !jwtHeaderRegex.MatchString(claimHeader[idx+1:]) {
+				c.logger.Warn("ignoring invalid claim to header mapping on %v: %s", claims.Source, claimHeader)
+				continue
+			}
+			claimHeaders = append(claimHeaders, hatypes.BackendHeader{
+				Name:  claimHeader[idx+1:],
+				Value: claimHeader[:idx],
+			})
+		}
+		jwt.ClaimHeaders = claimHeaders
+		path.JWT = jwt
+	}
+}
+
+var (
+	// HAProxy only accepts these status codes on deny_status
+	denyStatusRegex  = regexp.MustCompile(`^(200|40[0-578]|41[03]|42[59]|50[0-4])$`)
+	limitHeaderRegex = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+)
+
 func (c *updater) buildBackendLimit(d *backData) {
 	d.backend.Limit.RPS = d.mapper.Get(ingtypes.BackLimitRPS).Int()
 	d.backend.Limit.Connections = d.mapper.Get(ingtypes.BackLimitConnections).Int()
 	d.backend.Limit.Whitelist = c.splitCIDR(d.mapper.Get(ingtypes.BackLimitWhitelist))
+	denyStatus := d.mapper.Get(ingtypes.BackLimitDenyStatus)
+	if !denyStatusRegex.MatchString(denyStatus.Value) {
+		c.logger.Warn("ignoring invalid deny status code on %v, using 429 instead: %s", denyStatus.Source, denyStatus.Value)
+		d.backend.Limit.DenyStatus = 429
+	} else {
+		d.backend.Limit.DenyStatus = denyStatus.Int()
+	}
+	reqRPS := d.mapper.Get(ingtypes.BackLimitReqRPS)
+	header := d.mapper.Get(ingtypes.BackLimitHeader)
+	if d.backend.ModeTCP {
+		if reqRPS.Value != "" {
+			c.logger.Warn("ignoring '%s' on %v: backend is in tcp mode", ingtypes.BackLimitReqRPS, reqRPS.Source)
+		}
+		if header.Value != "" {
+			c.logger.Warn("ignoring '%s' on %v: backend is in tcp mode", ingtypes.BackLimitHeader, header.Source)
+		}
+		return
+	}
+	d.backend.Limit.ReqRPS = reqRPS.Int()
+	if header.Value != "" {
+		if limitHeaderRegex.MatchString(header.Value) {
+			d.backend.Limit.Header = header.Value
+		} else {
+			c.logger.Warn("ignoring invalid header name on %v, using the source IP instead: %s", header.Source, header.Value)
+		}
+	}
+}
+
+var (
+	luaActionPhaseRegex = regexp.MustCompile(`^http-(request|response)$`)
+	luaArgRegex         = regexp.MustCompile(`^[^\s"'#\\]+$`)
+)
+
+func (c *updater) buildBackendLua(d *backData) {
+	if d.backend.ModeTCP {
+		return
+	}
+	actions := map[string]bool{}
+	fetches := map[string]bool{}
+	for _, script := range c.haproxy.Global().LuaScripts {
+		for _, action := range script.Actions {
+			actions[action] = true
+		}
+		for _, fetch := range script.Fetches {
+			fetches[fetch] = true
+		}
+	}
+	validArgs := func(args []string) bool {
+		for _, arg := range args {
+			if !luaArgRegex.MatchString(arg) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link)
+		var lua hatypes.PathLua
+		actionsCfg := config.Get(ingtypes.BackLuaActions)
+		for _, line := range utils.LineToSlice(actionsCfg.Value) {
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			if len(fields) < 2 || !luaActionPhaseRegex.MatchString(fields[0]) || !validArgs(fields[2:]) {
+				c.logger.Warn("ignoring invalid lua action on %v: %s", actionsCfg.Source, line)
+				continue
+			}
+			if !actions[fields[1]] {
+				c.logger.Warn("ignoring lua action on %v: action not registered by lua scripts: %s", actionsCfg.Source, fields[1])
+				continue
+			}
+			lua.Actions = append(lua.Actions, hatypes.LuaAction{
+				Phase: fields[0],
+				Name:  fields[1],
+				Args:  fields[2:],
+			})
+		}
+		headersCfg := config.Get(ingtypes.BackLuaFetchHeaders)
+		for _, line := range utils.LineToSlice(headersCfg.Value) {
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			if len(fields) < 2 || !httpHeaderNameRegex.MatchString(fields[0]) || !validArgs(fields[2:]) {
+				c.logger.Warn("ignoring invalid lua fetch header on %v: %s", headersCfg.Source, line)
+				continue
+			}
+			if !fetches[fields[1]] {
+				c.logger.Warn("ignoring lua fetch header on %v: sample fetch not registered by lua scripts: %s", headersCfg.Source, fields[1])
+				continue
+			}
+			lua.Headers = append(lua.Headers, hatypes.LuaHeader{
+				Name:  fields[0],
+				Fetch: fields[1],
+				Args:  fields[2:],
+			})
+		}
+		path.Lua = lua
+	}
+}
+
+func (c *updater) buildBackendMaintenance(d *backData) {
+	if d.backend.ModeTCP {
+		return
+	}
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link)
+		maintenance := config.Get(ingtypes.BackMaintenance)
+		if !maintenance.Bool() {
+			continue
+		}
+		var redirectURL string
+		if redirect := config.Get(ingtypes.BackMaintenanceRedirect); redirect.Value != "" {
+			validPrefix := strings.HasPrefix(redirect.Value, "/") ||
+				strings.HasPrefix(redirect.Value, "http://") ||
+				strings.HasPrefix(redirect.Value, "https://")
+			if validPrefix && validURLRegex.MatchString(redirect.Value) {
+				redirectURL = redirect.Value
+			} else {
+				c.logger.Warn("ignoring invalid maintenance-redirect on %v, using the 503 response instead: %s", redirect.Source, redirect.Value)
+			}
+		}
+		path.Maintenance = hatypes.Maintenance{
+			AllowList:   c.splitCIDR(config.Get(ingtypes.BackMaintenanceAllowlist)),
+			Enabled:     true,
+			RedirectURL: redirectURL,
+		}
+	}
+}
+
+func (c *updater) buildBackendMirror(d *backData) {
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link)
+		url := config.Get(ingtypes.BackMirrorURL)
+		if url.Source == nil || url.Value == "" {
+			continue
+		}
+		external := c.haproxy.Global().External
+		if external.IsExternal() && !external.HasLua {
+			c.logger.Warn("traffic mirroring on %v needs Lua json module, install lua-json4 and enable 'external-has-lua' global config", url.Source)
+			return
+		}
+		p := config.Get(ingtypes.BackMirrorPercentage)
+		percentage, err := strconv.Atoi(p.Value)
+		if err != nil || percentage < 0 || percentage > 100 {
+			c.logger.Warn("ignoring mirror-url on %v: invalid percentage: %s", p.Source, p.Value)
+			continue
+		}
+		if percentage == 0 {
+			continue
+		}
+		urlProto, urlHost, urlPort, _, err := ingutils.ParseURL(url.Value)
+		if err != nil {
+			c.logger.Warn("ignoring mirror URL on %v: %v", url.Source, err)
+			continue
+		}
+		if urlProto != "service" && urlProto != "svc" {
+			c.logger.Warn("ignoring mirror URL with an invalid protocol on %v: %s", url.Source, urlProto)
+			continue
+		}
+		if urlPort == "" {
+			c.logger.Warn("skipping mirror-url on %v: missing service port: %s", url.Source, url.Value)
+			continue
+		}
+		ssvc := strings.Split(urlHost, "/")
+		namespace := url.Source.Namespace
+		name := ssvc[0]
+		if len(ssvc) == 2 {
+			namespace = ssvc[0]
+			name = ssvc[1]
+		}
+		backend := c.haproxy.Backends().FindBackend(namespace, name, urlPort)
+		if backend == nil {
+			// warn already logged when ingress parser tried to acquire the backend
+			continue
+		}
+		if backend.ID == d.backend.ID {
+			c.logger.Warn("ignoring mirror URL on %v: mirror and path use the same service: %s", url.Source, url.Value)
+			continue
+		}
+		authBackendName, err := c.acquireAuthBackendName(backend.BackendID())
+		if err != nil {
+			c.logger.Warn("ignoring mirror URL on %v: %v", url.Source, err)
+			continue
+		}
+		path.Mirror.AuthBackendName = authBackendName
+		path.Mirror.Percentage = percentage
+	}
 }
 
 func (c *updater) buildBackendOAuth(d *backData) {
@@ -693,6 +1827,95 @@ func (c *updater) buildBackendOAuth(d *backData) {
 	}
 }
 
+func (c *updater) buildBackendOutlier(d *backData) {
+	observe := d.mapper.Get(ingtypes.BackOutlierObserve)
+	if observe.Value == "" {
+		return
+	}
+	if observe.Value != "layer4" && observe.Value != "layer7" {
+		c.logger.Warn("ignoring invalid outlier-observe on %v: %s", observe.Source, observe.Value)
+		return
+	}
+	if observe.Value == "layer7" && d.backend.ModeTCP {
+		c.logger.Warn("ignoring outlier-observe on %v: layer7 cannot be used on a TCP backend", observe.Source)
+		return
+	}
+	hc := d.backend.HealthCheck
+	if hc.Interval == "" && hc.Port == 0 && hc.Addr == "" && hc.RiseCount == 0 && hc.FallCount == 0 {
+		c.logger.Warn("ignoring outlier-observe on %v: health check is disabled", observe.Source)
+		return
+	}
+	errorLimit := d.mapper.Get(ingtypes.BackOutlierErrorLimit)
+	limit := errorLimit.Int()
+	if limit <= 0 {
+		c.logger.Warn("invalid outlier-error-limit on %v: %s, using 10 instead", errorLimit.Source, errorLimit.Value)
+		limit = 10
+	}
+	onError := d.mapper.Get(ingtypes.BackOutlierOnError)
+	action := onError.Value
+	switch action {
+	case "fastinter", "fail-check", "sudden-death", "mark-down":
+	default:
+		c.logger.Warn("invalid outlier-on-error on %v: %s, using mark-down instead", onError.Source, onError.Value)
+		action = "mark-down"
+	}
+	d.backend.Server.Observe = observe.Value
+	d.backend.Server.ErrorLimit = limit
+	d.backend.Server.OnError = action
+}
+
+func (c *updater) buildBackendPodWeight(d *backData) {
+	annotation := d.mapper.Get(ingtypes.BackPodWeightAnnotation)
+	if annotation.Value == "" {
+		return
+	}
+	if balance := d.mapper.Get(ingtypes.BackBlueGreenBalance); balance.Value != "" {
+		c.logger.Warn("ignoring pod-weight-annotation on %v: blue/green balance is configured", annotation.Source)
+		return
+	}
+	for _, ep := range d.backend.Endpoints {
+		if ep.Weight == 0 || ep.TargetRef == "" {
+			// draining or not a pod, weight cannot be changed
+			continue
+		}
+		pod, err := c.cache.GetPod(ep.TargetRef)
+		if err != nil {
+			c.logger.Warn("cannot read weight of endpoint '%s:%d' on %v: %v", ep.IP, ep.Port, annotation.Source, err)
+			continue
+		}
+		value, found := pod.Annotations[annotation.Value]
+		if !found {
+			continue
+		}
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 0 || weight > 256 {
+			c.logger.Warn("ignoring invalid weight '%s' on annotation '%s' of pod '%s'", value, annotation.Value, ep.TargetRef)
+			continue
+		}
+		ep.Weight = weight
+	}
+}
+
+// acquireAuthBackendName allocates a local port to the auth proxy of the
+// backend, removing the ports of the auth backends no longer in use if the
+// port range is exhausted.
+func (c *updater) acquireAuthBackendName(backend hatypes.BackendID) (string, error) {
+	frontend := c.haproxy.Frontend()
+	authBackendName, err := frontend.AcquireAuthBackendName(backend)
+	if err != nil {
+		// clean up and try again
+		used := c.haproxy.Backends().BuildUsedAuthBackends()
+		for _, host := range c.haproxy.Hosts().Items() {
+			if host.OIDC.AuthBackendName != "" {
+				used[host.OIDC.AuthBackendName] = true
+			}
+		}
+		frontend.RemoveAuthBackendExcept(used)
+		authBackendName, err = frontend.AcquireAuthBackendName(backend)
+	}
+	return authBackendName, err
+}
+
 func (c *updater) findBackend(namespace, uriPrefix string) *hatypes.HostBackend {
 	for _, host := range c.haproxy.Hosts().Items() {
 		for _, path := range host.Paths {
@@ -706,6 +1929,54 @@ func (c *updater) findBackend(namespace, uriPrefix string) *hatypes.HostBackend
 
 var validDomainRegex = regexp.MustCompile(`^([A-Za-z0-9-]{1,63}\.)+[A-Za-z]{2,6}$`)
 
+var (
+	fcgiParamNameRegex  = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	fcgiParamValueRegex = regexp.MustCompile(`^[^\s"'#]+$`)
+	fcgiPathRegex       = regexp.MustCompile(`^/[^\s"'#]*$`)
+)
+
+// buildBackendFastCGI reads the fcgi-app configuration of a backend using
+// the fcgi protocol, returns false if the configuration is not valid.
+func (c *updater) buildBackendFastCGI(d *backData, proto *ConfigValue) bool {
+	docroot := d.mapper.Get(ingtypes.BackFastCGIDocroot)
+	if docroot.Value == "" {
+		c.logger.Warn("ignoring fcgi protocol on %v: missing fcgi-docroot", proto.Source)
+		return false
+	}
+	if !fcgiPathRegex.MatchString(docroot.Value) {
+		c.logger.Warn("ignoring fcgi protocol on %v: invalid fcgi-docroot: %s", docroot.Source, docroot.Value)
+		return false
+	}
+	index := d.mapper.Get(ingtypes.BackFastCGIIndex)
+	if index.Value != "" && !fcgiParamValueRegex.MatchString(index.Value) {
+		c.logger.Warn("ignoring invalid fcgi-index on %v: %s", index.Source, index.Value)
+		index = &ConfigValue{}
+	}
+	params := d.mapper.Get(ingtypes.BackFastCGIParams)
+	var fcgiParams []*hatypes.FastCGIParam
+	for _, param := range utils.LineToSlice(params.Value) {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+		nameValue := strings.Fields(param)
+		if len(nameValue) != 2 || !fcgiParamNameRegex.MatchString(nameValue[0]) || !fcgiParamValueRegex.MatchString(nameValue[1]) {
+			c.logger.Warn("ignoring invalid fcgi param on %v: %s", params.Source, param)
+			continue
+		}
+		fcgiParams = append(fcgiParams, &hatypes.FastCGIParam{
+			Name:  nameValue[0],
+			Value: nameValue[1],
+		})
+	}
+	d.backend.FastCGI = hatypes.FastCGIApp{
+		Docroot: docroot.Value,
+		Index:   index.Value,
+		Params:  fcgiParams,
+	}
+	return true
+}
+
 func (c *updater) buildBackendProtocol(d *backData) {
 	proto := d.mapper.Get(ingtypes.BackBackendProtocol)
 	var protocol string
@@ -723,6 +1994,12 @@ func (c *updater) buildBackendProtocol(d *backData) {
 	case "h2-ssl", "grpcs":
 		protocol = "h2"
 		secure = true
+	case "fcgi":
+		if !c.buildBackendFastCGI(d, proto) {
+			return
+		}
+		protocol = "fcgi"
+		secure = false
 	default:
 		c.logger.Warn("ignoring invalid backend protocol on %v: %s", proto.Source, proto.Value)
 		return
@@ -771,39 +2048,209 @@ func (c *updater) buildBackendProtocol(d *backData) {
 		} else {
 			c.logger.Warn("skipping invalid domain (verify-hostname) on %v: %s", host.Source, host.Value)
 		}
-	}
-	if ca := d.mapper.Get(ingtypes.BackSecureVerifyCASecret); ca.Value != "" {
-		if caFile, crlFile, err := c.cache.GetCASecretPath(
-			ca.Source.Namespace,
-			ca.Value,
-			convtypes.TrackingTarget{Backend: d.backend.BackendID()},
-		); err == nil {
-			d.backend.Server.CAFilename = caFile.Filename
-			d.backend.Server.CAHash = caFile.SHA1Hash
-			d.backend.Server.CRLFilename = crlFile.Filename
-			d.backend.Server.CRLHash = crlFile.SHA1Hash
-		} else {
-			c.logger.Warn("skipping CA on %v: %v", ca.Source, err)
+	}
+	if ca := d.mapper.Get(ingtypes.BackSecureVerifyCASecret); ca.Value != "" {
+		if caFile, crlFile, err := c.cache.GetCASecretPath(
+			ca.Source.Namespace,
+			ca.Value,
+			convtypes.TrackingTarget{Backend: d.backend.BackendID()},
+		); err == nil {
+			d.backend.Server.CAFilename = caFile.Filename
+			d.backend.Server.CAHash = caFile.SHA1Hash
+			d.backend.Server.CRLFilename = crlFile.Filename
+			d.backend.Server.CRLHash = crlFile.SHA1Hash
+		} else {
+			c.logger.Warn("skipping CA on %v: %v", ca.Source, err)
+		}
+	}
+}
+
+// buildBackendGRPCWeb should be called after buildBackendProtocol,
+// the translated requests need a gRPC server, so the h2 protocol.
+func (c *updater) buildBackendGRPCWeb(d *backData) {
+	grpcWeb := d.mapper.Get(ingtypes.BackGRPCWeb)
+	if !grpcWeb.Bool() {
+		return
+	}
+	if d.backend.ModeTCP {
+		c.logger.Warn("ignoring gRPC-Web on %v: backend is in tcp mode", grpcWeb.Source)
+		return
+	}
+	if d.backend.Server.Protocol != "h2" {
+		c.logger.Warn("ignoring gRPC-Web on %v: backend protocol should be h2 or h2-ssl", grpcWeb.Source)
+		return
+	}
+	d.backend.GRPCWeb = true
+}
+
+var (
+	proxyV2Options = map[string]bool{
+		"authority":  true,
+		"cert-cn":    true,
+		"cert-key":   true,
+		"cert-sig":   true,
+		"crc32c":     true,
+		"ssl":        true,
+		"ssl-cipher": true,
+		"unique-id":  true,
+	}
+	proxyV2TLVFormatRegex = regexp.MustCompile(`^[^\s"'#]+$`)
+)
+
+const (
+	// custom TLV types, see PP2_TYPE_MIN_CUSTOM and PP2_TYPE_MAX_CUSTOM on proxy-protocol.txt
+	proxyV2TLVMinCustom = 0xE0
+	proxyV2TLVMaxCustom = 0xEF
+)
+
+func (c *updater) buildBackendProxyProtocol(d *backData) {
+	cfg := d.mapper.Get(ingtypes.BackProxyProtocol)
+	if cfg.Source == nil {
+		return
+	}
+	switch cfg.Value {
+	case "v1":
+		d.backend.Server.SendProxy = "send-proxy"
+	case "v2":
+		d.backend.Server.SendProxy = "send-proxy-v2"
+	case "v2-ssl":
+		d.backend.Server.SendProxy = "send-proxy-v2-ssl"
+	case "v2-ssl-cn":
+		d.backend.Server.SendProxy = "send-proxy-v2-ssl-cn"
+	default:
+		c.logger.Warn("ignoring invalid proxy protocol version on %v: %s", cfg.Source, cfg.Value)
+	}
+	options := d.mapper.Get(ingtypes.BackProxyProtocolV2Options)
+	tlvs := d.mapper.Get(ingtypes.BackProxyProtocolV2TLVs)
+	if options.Value == "" && tlvs.Value == "" {
+		return
+	}
+	if !strings.HasPrefix(d.backend.Server.SendProxy, "send-proxy-v2") {
+		c.logger.Warn("ignoring proxy protocol v2 options and TLVs on %v: proxy protocol v2 is not configured", cfg.Source)
+		return
+	}
+	for _, opt := range strings.Split(options.Value, ",") {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+		if !proxyV2Options[opt] {
+			c.logger.Warn("ignoring invalid proxy protocol v2 option on %v: %s", options.Source, opt)
+			continue
+		}
+		d.backend.Server.ProxyV2.Options = append(d.backend.Server.ProxyV2.Options, opt)
+	}
+	for _, tlv := range utils.LineToSlice(tlvs.Value) {
+		tlv = strings.TrimSpace(tlv)
+		if tlv == "" {
+			continue
+		}
+		idFormat := strings.Fields(tlv)
+		if len(idFormat) != 2 {
+			c.logger.Warn("ignoring invalid proxy protocol v2 TLV on %v: %s", tlvs.Source, tlv)
+			continue
+		}
+		id, err := strconv.ParseInt(idFormat[0], 0, 0)
+		if err != nil || id < proxyV2TLVMinCustom || id > proxyV2TLVMaxCustom {
+			c.logger.Warn("ignoring proxy protocol v2 TLV on %v: type should be between 0xE0 and 0xEF: %s", tlvs.Source, idFormat[0])
+			continue
+		}
+		format := idFormat[1]
+		if !proxyV2TLVFormatRegex.MatchString(format) {
+			c.logger.Warn("ignoring proxy protocol v2 TLV on %v: invalid format: %s", tlvs.Source, format)
+			continue
+		}
+		format = strings.ReplaceAll(format, "%[service]", d.backend.Name)
+		format = strings.ReplaceAll(format, "%[namespace]", d.backend.Namespace)
+		d.backend.Server.ProxyV2.TLVs = append(d.backend.Server.ProxyV2.TLVs, &hatypes.ProxyV2TLV{
+			ID:     int(id),
+			Format: format,
+		})
+	}
+}
+
+func (c *updater) buildBackendRateLimit(d *backData) {
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link)
+		name := config.Get(ingtypes.BackRateLimit)
+		if name.Source == nil || name.Value == "" {
+			continue
+		}
+		if d.backend.ModeTCP {
+			c.logger.Warn("ignoring rate limit on %v: backend is using TCP mode", name.Source)
+			continue
+		}
+		rateLimit, found := c.rateLimits[name.Value]
+		if !found {
+			c.logger.Warn("ignoring rate limit on %v: RateLimit '%s' not found", name.Source, name.Value)
+			continue
 		}
+		path.RateLimit = *rateLimit
 	}
 }
 
-func (c *updater) buildBackendProxyProtocol(d *backData) {
-	cfg := d.mapper.Get(ingtypes.BackProxyProtocol)
-	if cfg.Source == nil {
-		return
+var retryOnConditions = map[string]bool{
+	"none":                 true,
+	"conn-failure":         true,
+	"empty-response":       true,
+	"junk-response":        true,
+	"response-timeout":     true,
+	"0rtt-rejected":        true,
+	"all-retryable-errors": true,
+	"404":                  true,
+	"408":                  true,
+	"425":                  true,
+	"500":                  true,
+	"501":                  true,
+	"502":                  true,
+	"503":                  true,
+	"504":                  true,
+}
+
+func (c *updater) buildBackendRetry(d *backData) {
+	if retries := d.mapper.Get(ingtypes.BackRetries); retries.Value != "" {
+		if value, err := strconv.Atoi(retries.Value); err == nil && value >= 0 {
+			d.backend.Retry.Retries = strconv.Itoa(value)
+		} else {
+			c.logger.Warn("ignoring invalid number of retries on %v: %s", retries.Source, retries.Value)
+		}
 	}
-	switch cfg.Value {
-	case "v1":
-		d.backend.Server.SendProxy = "send-proxy"
-	case "v2":
-		d.backend.Server.SendProxy = "send-proxy-v2"
-	case "v2-ssl":
-		d.backend.Server.SendProxy = "send-proxy-v2-ssl"
-	case "v2-ssl-cn":
-		d.backend.Server.SendProxy = "send-proxy-v2-ssl-cn"
-	default:
-		c.logger.Warn("ignoring invalid proxy protocol version on %v: %s", cfg.Source, cfg.Value)
+	if retryOn := d.mapper.Get(ingtypes.BackRetryOn); retryOn.Value != "" {
+		var conditions []string
+		for _, cond := range strings.Split(retryOn.Value, ",") {
+			cond = strings.ToLower(strings.TrimSpace(cond))
+			if cond == "" {
+				continue
+			}
+			if !retryOnConditions[cond] {
+				c.logger.Warn("ignoring invalid retry-on condition on %v: %s", retryOn.Source, cond)
+				continue
+			}
+			conditions = append(conditions, cond)
+		}
+		if len(conditions) > 1 {
+			for _, cond := range conditions {
+				if cond == "none" {
+					c.logger.Warn("ignoring retry-on on %v: 'none' cannot be used with other conditions", retryOn.Source)
+					conditions = nil
+					break
+				}
+			}
+		}
+		d.backend.Retry.RetryOn = conditions
+	}
+	if redispatch := d.mapper.Get(ingtypes.BackRetryRedispatch); redispatch.Value != "" {
+		switch value := strings.ToLower(redispatch.Value); value {
+		case "true", "false":
+			d.backend.Retry.Redispatch = value
+		default:
+			if interval, err := strconv.Atoi(value); err == nil && interval != 0 {
+				d.backend.Retry.Redispatch = "true"
+				d.backend.Retry.RedispatchInterval = interval
+			} else {
+				c.logger.Warn("ignoring invalid retry-redispatch on %v: %s", redispatch.Source, redispatch.Value)
+			}
+		}
 	}
 }
 
@@ -820,12 +2267,249 @@ func (c *updater) buildBackendRewriteURL(d *backData) {
 				rewrite.Source, rewrite.Value)
 			continue
 		}
-		path.RewriteURL = rewrite.Value
+		rewriteURL := rewrite.Value
+		if refs := rewriteCaptureRegex.FindAllStringSubmatch(rewriteURL, -1); len(refs) > 0 {
+			if path.Match() != hatypes.MatchRegex {
+				c.logger.Warn(
+					"ignoring rewrite-target with capture group references on %v: path '%s' should use regex path type",
+					rewrite.Source, path.Path())
+				continue
+			}
+			if !c.checkRewriteCaptureRefs(rewrite, path, refs) {
+				continue
+			}
+			rewriteURL = rewriteCaptureRegex.ReplaceAllString(rewriteURL, `\$1`)
+		}
+		path.RewriteURL = rewriteURL
+	}
+}
+
+// checkRewriteCaptureRefs validates that the capture groups referenced by a
+// rewrite target exist in the regex of the path. Regex that Go doesn't
+// understand, eg PCRE only syntax, are left for haproxy to validate.
+func (c *updater) checkRewriteCaptureRefs(rewrite *ConfigValue, path *hatypes.BackendPath, refs [][]string) bool {
+	re, err := regexp.Compile(path.Path())
+	if err != nil {
+		return true
+	}
+	for _, ref := range refs {
+		if group, _ := strconv.Atoi(ref[1]); group > re.NumSubexp() {
+			c.logger.Warn(
+				"ignoring rewrite-target on %v: capture group $%d not found in path '%s'",
+				rewrite.Source, group, path.Path())
+			return false
+		}
 	}
+	return true
 }
 
 var epNamingRegex = regexp.MustCompile(`^(seq(uence)?|pod|ip)$`)
 
+func (c *updater) buildBackendRoutes(d *backData) {
+	// header routes have precedence over query routes, which have precedence over method routes
+	d.backend.Routes = append(d.backend.Routes, c.readRoutes(d, ingtypes.BackHeaderRoutes, hatypes.RouteMatchHeader)...)
+	d.backend.Routes = append(d.backend.Routes, c.readRoutes(d, ingtypes.BackQueryRoutes, hatypes.RouteMatchQuery)...)
+	d.backend.Routes = append(d.backend.Routes, c.readRoutes(d, ingtypes.BackMethodRoutes, hatypes.RouteMatchMethod)...)
+}
+
+// readRoutes reads the alternate backends declared in the key, one route per
+// line. Backends of the alternate services are pre-built by the ingress
+// parser, so a missing service or port is already logged there.
+func (c *updater) readRoutes(d *backData, key string, match hatypes.RouteMatchType) []*hatypes.BackendRoute {
+	config := d.mapper.Get(key)
+	if config.Source == nil || config.Value == "" {
+		return nil
+	}
+	if d.backend.ModeTCP {
+		c.logger.Warn("ignoring %s on %v: cannot be used on a TCP backend", key, config.Source)
+		return nil
+	}
+	var routes []*hatypes.BackendRoute
+	for _, line := range utils.LineToSlice(config.Value) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var route *ingutils.Route
+		var err error
+		if match == hatypes.RouteMatchMethod {
+			route, err = ingutils.ParseMethodRoute(line)
+		} else {
+			route, err = ingutils.ParseRoute(line)
+		}
+		if err != nil {
+			c.logger.Warn("ignoring %s on %v: %v", key, config.Source, err)
+			continue
+		}
+		namespace := config.Source.Namespace
+		svc, err := c.cache.GetService(namespace, route.Service)
+		if err != nil {
+			continue
+		}
+		port := convutils.FindServicePort(svc, route.Port)
+		if port == nil {
+			continue
+		}
+		backend := c.haproxy.Backends().FindBackend(namespace, route.Service, port.TargetPort.String())
+		if backend == nil {
+			c.logger.Warn("ignoring %s on %v: backend of service '%s' was not found", key, config.Source, route.Service)
+			continue
+		}
+		if backend.ID == d.backend.ID {
+			c.logger.Warn("ignoring %s on %v: route and backend use the same service: %s", key, config.Source, route.Service)
+			continue
+		}
+		routes = append(routes, &hatypes.BackendRoute{
+			Type:    match,
+			Name:    route.Name,
+			Regex:   route.Regex,
+			Values:  route.Values,
+			Backend: backend.ID,
+		})
+	}
+	return routes
+}
+
+var (
+	secHeadersFrameOptionsRegex   = regexp.MustCompile(`^(?i)(deny|sameorigin)$`)
+	secHeadersReferrerPolicyRegex = regexp.MustCompile(`^(no-referrer|no-referrer-when-downgrade|origin|origin-when-cross-origin|same-origin|strict-origin|strict-origin-when-cross-origin|unsafe-url)$`)
+	secHeadersCSPRegex            = regexp.MustCompile(`^[^"\\]+$`)
+)
+
+// securityHeaders is the parsed state of the security-headers configuration
+// key, before being applied to the backend path.
+type securityHeaders struct {
+	enabled bool
+	headers hatypes.SecurityHeaders
+	hsts    hatypes.HSTS
+}
+
+func (c *updater) buildBackendSecurityHeaders(d *backData) {
+	if d.backend.ModeTCP {
+		return
+	}
+	// vetted defaults, overridden one option at a time by the global config
+	// and then by the resource annotations
+	globalSec := securityHeaders{
+		enabled: true,
+		headers: hatypes.SecurityHeaders{
+			ContentTypeOptions: "nosniff",
+			FrameOptions:       "DENY",
+			ReferrerPolicy:     "strict-origin-when-cross-origin",
+		},
+		hsts: hatypes.HSTS{
+			Enabled: true,
+			MaxAge:  31536000,
+		},
+	}
+	globalValue := d.mapper.annDefaults[ingtypes.BackSecurityHeaders]
+	c.readSecurityHeaders(&globalSec, "global config", globalValue)
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link).Get(ingtypes.BackSecurityHeaders)
+		if config.Value == "" && globalValue == "" {
+			continue
+		}
+		sec := globalSec
+		if config.Source != nil {
+			c.readSecurityHeaders(&sec, config.Source, config.Value)
+		}
+		if !sec.enabled {
+			continue
+		}
+		path.SecHeaders = sec.headers
+		path.HSTS = sec.hsts
+	}
+}
+
+// readSecurityHeaders parses a multi-line list of `<option>: <value>` pairs
+// of the security-headers configuration key, overriding the options of sec.
+func (c *updater) readSecurityHeaders(sec *securityHeaders, source interface{}, value string) {
+	for _, line := range utils.LineToSlice(value) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		idx := strings.IndexAny(line, ": ")
+		if idx <= 0 {
+			c.logger.Warn("ignoring security header option on %v: missing option name or value: %s", source, line)
+			continue
+		}
+		option := strings.ToLower(strings.TrimRight(line[:idx], ":"))
+		optValue := strings.TrimSpace(line[idx+1:])
+		off := strings.ToLower(optValue) == "off"
+		invalid := func() {
+			c.logger.Warn("ignoring security header option '%s' on %v: invalid value: %s", option, source, optValue)
+		}
+		switch option {
+		case "enabled":
+			if enabled, err := strconv.ParseBool(optValue); err == nil {
+				sec.enabled = enabled
+			} else {
+				invalid()
+			}
+		case "content-security-policy":
+			if off {
+				sec.headers.ContentSecurityPolicy = ""
+			} else if secHeadersCSPRegex.MatchString(optValue) {
+				value := strings.ReplaceAll(optValue, "%", "%%")
+				sec.headers.ContentSecurityPolicy = "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+			} else {
+				invalid()
+			}
+		case "referrer-policy":
+			if off {
+				sec.headers.ReferrerPolicy = ""
+			} else if secHeadersReferrerPolicyRegex.MatchString(optValue) {
+				sec.headers.ReferrerPolicy = optValue
+			} else {
+				invalid()
+			}
+		case "x-content-type-options":
+			if off {
+				sec.headers.ContentTypeOptions = ""
+			} else if optValue == "nosniff" {
+				sec.headers.ContentTypeOptions = optValue
+			} else {
+				invalid()
+			}
+		case "x-frame-options":
+			if off {
+				sec.headers.FrameOptions = ""
+			} else if secHeadersFrameOptionsRegex.MatchString(optValue) {
+				sec.headers.FrameOptions = strings.ToUpper(optValue)
+			} else {
+				invalid()
+			}
+		case "hsts":
+			if enabled, err := strconv.ParseBool(optValue); err == nil {
+				sec.hsts.Enabled = enabled
+			} else {
+				invalid()
+			}
+		case "hsts-max-age":
+			if maxAge, err := strconv.Atoi(optValue); err == nil && maxAge >= 0 {
+				sec.hsts.MaxAge = maxAge
+			} else {
+				invalid()
+			}
+		case "hsts-include-subdomains":
+			if subdomains, err := strconv.ParseBool(optValue); err == nil {
+				sec.hsts.Subdomains = subdomains
+			} else {
+				invalid()
+			}
+		case "hsts-preload":
+			if preload, err := strconv.ParseBool(optValue); err == nil {
+				sec.hsts.Preload = preload
+			} else {
+				invalid()
+			}
+		default:
+			c.logger.Warn("ignoring unsupported security header option on %v: %s", source, option)
+		}
+	}
+}
+
 func (c *updater) buildBackendServerNaming(d *backData) {
 	// Only warning here. d.backend.EpNaming should be updated before backend.AcquireEndpoint()
 	naming := d.mapper.Get(ingtypes.BackBackendServerNaming)
@@ -834,6 +2518,69 @@ func (c *updater) buildBackendServerNaming(d *backData) {
 	}
 }
 
+func (c *updater) buildBackendServiceImportWeight(d *backData) {
+	config := d.mapper.Get(ingtypes.BackServiceImportWeight)
+	if config.Value == "" {
+		return
+	}
+	if !strings.HasSuffix(d.backend.Name, convutils.ServiceImportSuffix) {
+		c.logger.Warn("ignoring cluster weight on %v: backend is not a ServiceImport", config.Source)
+		return
+	}
+	weights := map[string]*convutils.WeightCluster{}
+	var clusters []string
+	for _, weight := range utils.Split(config.Value, ",") {
+		cw := strings.Split(weight, "=")
+		w, err := strconv.Atoi(strings.TrimSpace(cw[len(cw)-1]))
+		if len(cw) != 2 || err != nil {
+			c.logger.Warn("ignoring invalid cluster weight on %v: %s", config.Source, weight)
+			continue
+		}
+		if w < 0 {
+			c.logger.Warn("invalid weight '%d' on %v, using '0' instead", w, config.Source)
+			w = 0
+		}
+		if w > 256 {
+			c.logger.Warn("invalid weight '%d' on %v, using '256' instead", w, config.Source)
+			w = 256
+		}
+		cluster := strings.TrimSpace(cw[0])
+		if _, found := weights[cluster]; !found {
+			clusters = append(clusters, cluster)
+		}
+		weights[cluster] = &convutils.WeightCluster{Weight: w}
+	}
+	if len(weights) == 0 {
+		return
+	}
+	for _, ep := range d.backend.Endpoints {
+		if ep.Weight == 0 {
+			// draining endpoint, remove from the cluster weight calc
+			continue
+		}
+		if cl, found := weights[ep.Cluster]; found {
+			cl.Length++
+		} else {
+			// cluster not listed, remove new traffic without remove from the balancer
+			ep.Weight = 0
+		}
+	}
+	cl := make([]*convutils.WeightCluster, len(clusters))
+	for i, cluster := range clusters {
+		cl[i] = weights[cluster]
+		if cl[i].Length == 0 {
+			c.logger.InfoV(3, "cluster '%s' on %v does not have any endpoint", cluster, config.Source)
+		}
+	}
+	initialWeight := d.mapper.Get(ingtypes.BackInitialWeight).Int()
+	convutils.RebalanceWeight(cl, initialWeight)
+	for _, ep := range d.backend.Endpoints {
+		if cl, found := weights[ep.Cluster]; found && ep.Weight > 0 {
+			ep.Weight = cl.Weight
+		}
+	}
+}
+
 var listAddrs func(ifname string) []net.Addr = func(ifname string) []net.Addr {
 	intf, _ := net.InterfaceByName(ifname)
 	if intf == nil {
@@ -843,6 +2590,14 @@ var listAddrs func(ifname string) []net.Addr = func(ifname string) []net.Addr {
 	return addrs
 }
 
+func (c *updater) buildBackendSlowStart(d *backData) {
+	slowStart := d.mapper.Get(ingtypes.BackSlowStart)
+	if slowStart.Value == "" {
+		return
+	}
+	d.backend.Server.SlowStart = c.validateTime(slowStart)
+}
+
 func (c *updater) buildBackendSourceAddressIntf(d *backData) {
 	sources := d.mapper.Get(ingtypes.BackSourceAddressIntf).Value
 	if sources == "" {
@@ -877,6 +2632,24 @@ func (c *updater) buildBackendSourceAddressIntf(d *backData) {
 	d.backend.SourceIPs = sourceIPs
 }
 
+func (c *updater) buildBackendSPOE(d *backData) {
+	config := d.mapper.Get(ingtypes.BackSPOEBackendAgents)
+	if config.Value == "" {
+		return
+	}
+	agents := map[string]bool{}
+	for _, agent := range c.haproxy.Global().SPOE.Agents {
+		agents[agent.Name] = true
+	}
+	for _, name := range utils.Split(config.Value, ",") {
+		if !agents[name] {
+			c.logger.Warn("ignoring unknown spoe agent on %v: %s", config.Source, name)
+			continue
+		}
+		d.backend.SPOEAgents = append(d.backend.SPOEAgents, name)
+	}
+}
+
 func (c *updater) buildBackendSSL(d *backData) {
 	d.backend.TLS.AddCertHeader = d.mapper.Get(ingtypes.BackAuthTLSCertHeader).Bool()
 	d.backend.TLS.FingerprintLower = d.mapper.Get(ingtypes.BackSSLFingerprintLower).Bool()
@@ -918,17 +2691,176 @@ func (c *updater) buildBackendTimeout(d *backData) {
 	if cfg := d.mapper.Get(ingtypes.BackTimeoutQueue); cfg.Source != nil {
 		d.backend.Timeout.Queue = c.validateTime(cfg)
 	}
-	if cfg := d.mapper.Get(ingtypes.BackTimeoutServer); cfg.Source != nil {
-		d.backend.Timeout.Server = c.validateTime(cfg)
-	}
 	if cfg := d.mapper.Get(ingtypes.BackTimeoutServerFin); cfg.Source != nil {
 		d.backend.Timeout.ServerFin = c.validateTime(cfg)
 	}
-	if cfg := d.mapper.Get(ingtypes.BackTimeoutTunnel); cfg.Source != nil {
-		d.backend.Timeout.Tunnel = c.validateTime(cfg)
+	if d.backend.ModeTCP || len(d.backend.Paths) == 0 {
+		if cfg := d.mapper.Get(ingtypes.BackTimeoutServer); cfg.Source != nil {
+			d.backend.Timeout.Server = c.validateTime(cfg)
+		}
+		if cfg := d.mapper.Get(ingtypes.BackTimeoutTunnel); cfg.Source != nil {
+			d.backend.Timeout.Tunnel = c.validateTime(cfg)
+		}
+		return
+	}
+	var server, tunnel []string
+	d.backend.Timeout.Server, server = c.readPathTimeout(d, ingtypes.BackTimeoutServer)
+	d.backend.Timeout.Tunnel, tunnel = c.readPathTimeout(d, ingtypes.BackTimeoutTunnel)
+	for i, path := range d.backend.Paths {
+		path.Timeout.Server = server[i]
+		path.Timeout.Tunnel = tunnel[i]
+	}
+}
+
+const topologyZoneLabel = "topology.kubernetes.io/zone"
+
+func (c *updater) buildBackendTopology(d *backData) {
+	mode := d.mapper.Get(ingtypes.BackTopologyAwareRouting)
+	if mode.Value == "" || mode.Value == "false" {
+		return
+	}
+	if mode.Value != "weight" && mode.Value != "backup" {
+		c.logger.Warn("ignoring invalid topology aware routing mode on %v: %s", mode.Source, mode.Value)
+		return
+	}
+	remoteWeight := d.mapper.Get(ingtypes.BackTopologyRemoteWeight)
+	percent, err := strconv.Atoi(remoteWeight.Value)
+	if mode.Value == "weight" && (err != nil || percent < 1 || percent > 100) {
+		c.logger.Warn("ignoring invalid topology remote weight on %v: %s", remoteWeight.Source, remoteWeight.Value)
+		return
+	}
+	localZone, err := c.localZone()
+	if err != nil {
+		c.logger.Warn("ignoring topology aware routing on %v: cannot read the zone of the controller: %v", mode.Source, err)
+		return
+	}
+	var local, remote []*hatypes.Endpoint
+	for _, ep := range d.backend.Endpoints {
+		if ep.IsEmpty() {
+			continue
+		}
+		var isLocal bool
+		if ep.ForZones != "" {
+			// topology hints of the EndpointSlice have precedence over the zone of the endpoint
+			for _, zone := range strings.Split(ep.ForZones, ",") {
+				isLocal = isLocal || zone == localZone
+			}
+		} else {
+			zone := ep.Zone
+			if zone == "" {
+				if ep.TargetRef == "" {
+					// not a pod, the zone cannot be found
+					continue
+				}
+				zone, err = c.podZone(ep.TargetRef)
+				if err != nil {
+					c.logger.Warn("cannot read zone of endpoint '%s:%d' on %v: %v", ep.IP, ep.Port, mode.Source, err)
+					continue
+				}
+			}
+			isLocal = zone == localZone
+		}
+		if isLocal {
+			local = append(local, ep)
+		} else {
+			remote = append(remote, ep)
+		}
+	}
+	if len(local) == 0 || len(remote) == 0 {
+		// all endpoints in the same zone, or none of them, nothing to change
+		return
+	}
+	switch mode.Value {
+	case "weight":
+		for _, ep := range remote {
+			if ep.Weight > 0 {
+				ep.Weight = ep.Weight * percent / 100
+				if ep.Weight == 0 {
+					ep.Weight = 1
+				}
+			}
+		}
+	case "backup":
+		for _, ep := range remote {
+			ep.Backup = true
+		}
+		d.backend.AllBackups = true
+	}
+}
+
+// localZone returns the zone of the node where the controller is running.
+func (c *updater) localZone() (string, error) {
+	if c.zones == nil {
+		c.zones = map[string]string{}
+	}
+	// the empty key caches the zone of the controller itself
+	if zone, found := c.zones[""]; found {
+		return zone, nil
+	}
+	zone, err := c.podZone(c.cache.GetPodNamespace() + "/" + c.cache.GetPodName())
+	if err != nil {
+		return "", err
+	}
+	if zone == "" {
+		return "", fmt.Errorf("node has no '%s' label", topologyZoneLabel)
+	}
+	c.zones[""] = zone
+	return zone, nil
+}
+
+// podZone returns the zone of the node where a pod is running, zones are
+// cached by node name along the lifetime of the updater.
+func (c *updater) podZone(podName string) (string, error) {
+	pod, err := c.cache.GetPod(podName)
+	if err != nil {
+		return "", err
+	}
+	nodeName := pod.Spec.NodeName
+	if nodeName == "" {
+		return "", fmt.Errorf("pod '%s' is not scheduled", podName)
+	}
+	if c.zones == nil {
+		c.zones = map[string]string{}
+	}
+	if zone, found := c.zones[nodeName]; found {
+		return zone, nil
+	}
+	node, err := c.cache.GetNode(nodeName)
+	if err != nil {
+		return "", err
+	}
+	zone := node.Labels[topologyZoneLabel]
+	c.zones[nodeName] = zone
+	return zone, nil
+}
+
+// readPathTimeout reads a path scoped timeout. The value of the first path that
+// declares the key is used as the backend timeout, and the returned slice has
+// the timeout of every path that should overwrite the backend one.
+func (c *updater) readPathTimeout(d *backData, key string) (backend string, paths []string) {
+	values := make([]string, len(d.backend.Paths))
+	var declared bool
+	for i, path := range d.backend.Paths {
+		cfg := d.mapper.GetConfig(path.Link).Get(key)
+		values[i] = c.validateTime(cfg)
+		if !declared && cfg.Source != nil && values[i] != "" {
+			backend = values[i]
+			declared = true
+		}
+	}
+	paths = make([]string, len(values))
+	if declared {
+		for i, value := range values {
+			if value != backend {
+				paths[i] = value
+			}
+		}
 	}
+	return backend, paths
 }
 
+var wafRulesetRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
 func (c *updater) buildBackendWAF(d *backData) {
 	for _, path := range d.backend.Paths {
 		config := d.mapper.GetConfig(path.Link)
@@ -937,7 +2869,7 @@ func (c *updater) buildBackendWAF(d *backData) {
 		if module == "" {
 			continue
 		}
-		if module != "modsecurity" {
+		if module != "modsecurity" && module != "coraza" {
 			c.logger.Warn("ignoring invalid WAF module on %s: %s", waf.Source, module)
 			continue
 		}
@@ -950,13 +2882,22 @@ func (c *updater) buildBackendWAF(d *backData) {
 		path.WAF.Module = module
 		path.WAF.Mode = mode
 	}
+	if d.backend.HasCoraza() {
+		ruleset := d.mapper.Get(ingtypes.BackWAFRuleset)
+		if !wafRulesetRegex.MatchString(ruleset.Value) {
+			c.logger.Warn("ignoring invalid WAF ruleset on %v: %s", ruleset.Source, ruleset.Value)
+			d.backend.WAFRuleset = "default"
+		} else {
+			d.backend.WAFRuleset = ruleset.Value
+		}
+	}
 }
 
 func (c *updater) buildBackendWhitelistHTTP(d *backData) {
 	if !d.backend.ModeTCP {
 		for _, path := range d.backend.Paths {
 			config := d.mapper.GetConfig(path.Link)
-			path.AllowedIPHTTP, path.DeniedIPHTTP = c.readAccessConfig(config)
+			path.AllowedIPHTTP, path.DeniedIPHTTP = c.readAccessConfig(d, config)
 		}
 	}
 }
@@ -965,14 +2906,14 @@ func (c *updater) buildBackendWhitelistTCP(d *backData) {
 	if !d.backend.ModeTCP {
 		return
 	}
-	d.backend.AllowedIPTCP, d.backend.DeniedIPTCP = c.readAccessConfig(d.mapper)
+	d.backend.AllowedIPTCP, d.backend.DeniedIPTCP = c.readAccessConfig(d, d.mapper)
 }
 
 type configValueGetter interface {
 	Get(key string) *ConfigValue
 }
 
-func (c *updater) readAccessConfig(config configValueGetter) (allowed, denied hatypes.AccessConfig) {
+func (c *updater) readAccessConfig(d *backData, config configValueGetter) (allowed, denied hatypes.AccessConfig) {
 	allowcfg := config.Get(ingtypes.BackAllowlistSourceRange)
 	denycfg := config.Get(ingtypes.BackDenylistSourceRange)
 	whitecfg := config.Get(ingtypes.BackWhitelistSourceRange)
@@ -984,5 +2925,131 @@ func (c *updater) readAccessConfig(config configValueGetter) (allowed, denied ha
 	}
 	allowed.Rule, allowed.Exception = c.splitDualCIDR(allowcfg)
 	denied.Rule, denied.Exception = c.splitDualCIDR(denycfg)
+	allowed.SourceList = c.readSourceList(d, config.Get(ingtypes.BackAllowlistConfigMap), config.Get(ingtypes.BackAllowlistIPList))
+	denied.SourceList = c.readSourceList(d, config.Get(ingtypes.BackDenylistConfigMap), config.Get(ingtypes.BackDenylistIPList))
+	allowed.Countries = c.readCountries(config.Get(ingtypes.BackAllowlistCountries))
+	denied.Countries = c.readCountries(config.Get(ingtypes.BackDenylistCountries))
 	return allowed, denied
 }
+
+var countryCodeRegex = regexp.MustCompile(`^[A-Z]{2}$`)
+
+func (c *updater) readCountries(config *ConfigValue) []string {
+	if config.Value == "" {
+		return nil
+	}
+	if c.haproxy.Global().GeoIP.Database == "" {
+		c.logger.Warn("ignoring country list on %v: GeoIP database was not configured", config.Source)
+		return nil
+	}
+	var countries []string
+	for _, country := range utils.Split(config.Value, ",") {
+		if country == "" {
+			continue
+		}
+		country = strings.ToUpper(country)
+		if !countryCodeRegex.MatchString(country) {
+			c.logger.Warn("skipping invalid country code on %v: %s", config.Source, country)
+			continue
+		}
+		countries = append(countries, country)
+	}
+	return countries
+}
+
+// readSourceList reads a list of IPs and CIDRs from a ConfigMap and from a
+// comma-separated list of IPList resources, adds the merged content to the
+// backend and returns its name. Every value of the ConfigMap is read,
+// entries are separated by commas, spaces or line breaks, and `#` starts a
+// comment up to the end of the line.
+func (c *updater) readSourceList(d *backData, cmConfig, ipListConfig *ConfigValue) string {
+	var names []string
+	var cmNamespace, cmName string
+	if cmConfig.Value != "" {
+		cmNamespace = c.cache.GetPodNamespace()
+		if cmConfig.Source != nil {
+			cmNamespace = cmConfig.Source.Namespace
+		}
+		cmName = cmConfig.Value
+		if !strings.Contains(cmName, "/") {
+			cmName = cmNamespace + "/" + cmName
+		}
+		names = append(names, cmName)
+	}
+	var ipListNames []string
+	for _, ipListName := range utils.Split(ipListConfig.Value, ",") {
+		if ipListName != "" {
+			ipListNames = append(ipListNames, ipListName)
+		}
+	}
+	if len(ipListNames) > 0 {
+		// colon is not allowed in resource names, so the name does not
+		// conflict with the ConfigMap based ones
+		names = append(names, "iplist:"+strings.Join(ipListNames, ","))
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	name := strings.Join(names, "+")
+	if d.backend.FindSourceList(name) != nil {
+		return name
+	}
+	var found bool
+	entries := []string{}
+	dupEntries := map[string]bool{}
+	addEntry := func(entry string) {
+		if !dupEntries[entry] {
+			dupEntries[entry] = true
+			entries = append(entries, entry)
+		}
+	}
+	if cmName != "" {
+		data, err := c.cache.GetConfigMapData(cmNamespace, cmConfig.Value, convtypes.TrackingTarget{Backend: d.backend.BackendID()})
+		if err != nil {
+			c.logger.Warn("ignoring source list on %v: %v", cmConfig.Source, err)
+		} else {
+			found = true
+			keys := make([]string, 0, len(data))
+			for key := range data {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				for _, line := range utils.LineToSlice(data[key]) {
+					if i := strings.Index(line, "#"); i >= 0 {
+						line = line[:i]
+					}
+					for _, entry := range strings.FieldsFunc(line, func(r rune) bool {
+						return r == ',' || r == ' ' || r == '\t' || r == '\r'
+					}) {
+						if net.ParseIP(entry) == nil {
+							if _, _, err := net.ParseCIDR(entry); err != nil {
+								c.logger.Warn("skipping invalid IP or cidr on configmap '%s' key '%s': %s", cmName, key, entry)
+								continue
+							}
+						}
+						addEntry(entry)
+					}
+				}
+			}
+		}
+	}
+	for _, ipListName := range ipListNames {
+		ipList, err := c.cache.GetIPList(ipListName, convtypes.TrackingTarget{Backend: d.backend.BackendID()})
+		if err != nil {
+			c.logger.Warn("ignoring IPList on %v: %v", ipListConfig.Source, err)
+			continue
+		}
+		found = true
+		// invalid entries are reported in the status of the IPList
+		valid, _ := convutils.ParseIPList(ipList.Spec.Entries)
+		for _, entry := range valid {
+			addEntry(entry)
+		}
+	}
+	if !found {
+		return ""
+	}
+	d.backend.AddSourceList(name, entries)
+	return name
+}
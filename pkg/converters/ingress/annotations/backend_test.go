@@ -26,8 +26,10 @@ import (
 	api "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
 	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 )
 
@@ -158,6 +160,65 @@ func TestAffinity(t *testing.T) {
 			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Dynamic: false, Keywords: "indirect nocache httponly"},
 			expLogging: "WARN invalid session-cookie-value-strategy 'err' on ingress 'default/ing1', using 'server-name' instead",
 		},
+		// 13
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieSameSite: "true",
+			},
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", SameSite: "None", Secure: true},
+			expLogging: "",
+		},
+		// 14
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieKeywords: "indirect nocache",
+				ingtypes.BackSessionCookieSameSite: "lax",
+				ingtypes.BackSessionCookieSecure:   "true",
+				ingtypes.BackSessionCookieHTTPOnly: "true",
+				ingtypes.BackSessionCookiePath:     "/app",
+				ingtypes.BackSessionCookieMaxAge:   "2h",
+			},
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache", SameSite: "Lax", Secure: true, HTTPOnly: true, Path: "/app", MaxAge: 7200},
+			expLogging: "",
+		},
+		// 15
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieKeywords: "indirect secure httponly",
+				ingtypes.BackSessionCookieSameSite: "none",
+				ingtypes.BackSessionCookieHTTPOnly: "true",
+				ingtypes.BackSessionCookieMaxAge:   "3600",
+			},
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect secure httponly", SameSite: "None", MaxAge: 3600},
+			expLogging: "",
+		},
+		// 16
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieSameSite: "relaxed",
+				ingtypes.BackSessionCookiePath:     "/app; Domain=evil",
+				ingtypes.BackSessionCookieMaxAge:   "1y",
+			},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly"},
+			expLogging: `
+WARN ignoring invalid session-cookie-same-site on ingress 'default/ing1': relaxed
+WARN ignoring invalid session-cookie-path on ingress 'default/ing1': /app; Domain=evil
+WARN ignoring invalid session-cookie-max-age on ingress 'default/ing1': 1y`,
+		},
+		// 17
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:             "cookie",
+				ingtypes.BackSessionCookieDynamic: "false",
+				ingtypes.BackSessionCookieHash:    "true",
+			},
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Hash: true, Keywords: "indirect nocache httponly"},
+			expLogging: "",
+		},
 	}
 
 	source := &Source{
@@ -176,6 +237,109 @@ func TestAffinity(t *testing.T) {
 	}
 }
 
+func TestAffinityStick(t *testing.T) {
+	testCase := []struct {
+		ann      map[string]string
+		modeTCP  bool
+		expected hatypes.StickConfig
+		logging  string
+	}{
+		// 0
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity: "stick-table",
+			},
+			expected: hatypes.StickConfig{Expire: "30m", Match: "src", Size: "100k", Type: "ip"},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:        "stick-table",
+				ingtypes.BackSessionStickKey: "cookie:JSESSIONID",
+			},
+			expected: hatypes.StickConfig{Expire: "30m", Match: "req.cook(JSESSIONID)", Size: "100k", Store: "res.cook(JSESSIONID)", Type: "string"},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:           "stick-table",
+				ingtypes.BackSessionStickKey:    "header:X-Session",
+				ingtypes.BackSessionStickExpire: "2h",
+				ingtypes.BackSessionStickSize:   "1m",
+			},
+			expected: hatypes.StickConfig{Expire: "2h", Match: "req.hdr(X-Session)", Size: "1m", Type: "string"},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:        "stick-table",
+				ingtypes.BackSessionStickKey: "url-param:sid",
+			},
+			expected: hatypes.StickConfig{Expire: "30m", Match: "url_param(sid)", Size: "100k", Type: "string"},
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:        "stick-table",
+				ingtypes.BackSessionStickKey: "cookie:a(b)",
+			},
+			logging: `WARN ignoring stick-table affinity on ingress 'default/ing1': invalid session-stick-key: cookie:a(b)`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:        "stick-table",
+				ingtypes.BackSessionStickKey: "body",
+			},
+			logging: `WARN ignoring stick-table affinity on ingress 'default/ing1': invalid session-stick-key: body`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:           "stick-table",
+				ingtypes.BackSessionStickExpire: "1y",
+				ingtypes.BackSessionStickSize:   "10x",
+			},
+			expected: hatypes.StickConfig{Expire: "30m", Match: "src", Size: "100k", Type: "ip"},
+			logging: `
+WARN ignoring invalid time format on ingress 'default/ing1': 1y
+WARN ignoring invalid session-stick-size on ingress 'default/ing1': 10x`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity: "stick-table",
+			},
+			modeTCP:  true,
+			expected: hatypes.StickConfig{Expire: "30m", Match: "src", Size: "100k", Type: "ip"},
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:        "stick-table",
+				ingtypes.BackSessionStickKey: "header:X-Session",
+			},
+			modeTCP: true,
+			logging: `WARN ignoring stick-table affinity on ingress 'default/ing1': only 'src' can be used on a TCP backend`,
+		},
+	}
+	annDefault := map[string]string{
+		ingtypes.BackSessionStickExpire: "30m",
+		ingtypes.BackSessionStickKey:    "src",
+		ingtypes.BackSessionStickSize:   "100k",
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCase {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, annDefault)
+		d.backend.ModeTCP = test.modeTCP
+		c.createUpdater().buildBackendAffinity(d)
+		c.compareObjects("stick affinity", i, d.backend.Stick, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestGlobToLuaPattern(t *testing.T) {
 	testCases := []struct {
 		input    string
@@ -258,6 +422,7 @@ func TestAuthExternal(t *testing.T) {
 		hdrReq     string
 		hdrSucceed string
 		hdrFail    string
+		fwdHeaders string
 		isExternal bool
 		hasLua     bool
 		expBack    hatypes.AuthExternal
@@ -513,6 +678,17 @@ func TestAuthExternal(t *testing.T) {
 			expIP:   []string{"10.0.0.2:80"},
 			logging: `WARN invalid request method '**' on ingress 'default/ing1', using GET instead`,
 		},
+		// 27
+		{
+			url:        "http://app1.local",
+			fwdHeaders: "true",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+				ForwardHeaders:  true,
+			},
+			expIP: []string{"10.0.0.2:80"},
+		},
 	}
 	source := &Source{
 		Namespace: "default",
@@ -558,6 +734,9 @@ func TestAuthExternal(t *testing.T) {
 		if test.hdrFail != "" {
 			ann["/"][ingtypes.BackAuthHeadersFail] = test.hdrFail
 		}
+		if test.fwdHeaders != "" {
+			ann["/"][ingtypes.BackAuthForwardHeaders] = test.fwdHeaders
+		}
 		defaults := map[string]string{
 			ingtypes.BackAuthHeadersRequest: "*",
 			ingtypes.BackAuthHeadersSucceed: "*",
@@ -728,6 +907,119 @@ usr2::clearpwd2`)}},
 				},
 			},
 		},
+		// 9
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "basicpwd",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {"auth": []byte(`
+# htpasswd file
+usr1:$2y$05$wHyd9MA6ozfBXfXuPr0b7OVY0qy8Vxg2Awd.ZJzUK.JOpVnaN3Mxa
+usr2:$6$rounds=5000$salt$hash
+usr3:$apr1$salt$hash
+usr4:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=
+usr1:$5$salt$hash`)}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "$2y$05$wHyd9MA6ozfBXfXuPr0b7OVY0qy8Vxg2Awd.ZJzUK.JOpVnaN3Mxa", Encrypted: true},
+				{Name: "usr2", Passwd: "$6$rounds=5000$salt$hash", Encrypted: true},
+			}}},
+			expLogging: `
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': unsupported password hash of user 'usr3' line 5
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': unsupported password hash of user 'usr4' line 6
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': duplicated user 'usr1' line 7`,
+		},
+		// 10
+		{
+			paths: []string{"/", "/admin", "/ops"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "basicpwd",
+				},
+				"/admin": {
+					ingtypes.BackAuthSecret: "basicpwd",
+					ingtypes.BackAuthGroups: "admin",
+				},
+				"/ops": {
+					ingtypes.BackAuthSecret: "basicpwd",
+					ingtypes.BackAuthGroups: "admin, ops",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {
+				"auth": []byte(`
+usr1:encpwd1
+usr2:encpwd2`),
+				"groups": []byte(`
+ops: usr2
+admin: usr1 usr2`),
+			}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd",
+				Users: []hatypes.User{
+					{Name: "usr1", Passwd: "encpwd1", Encrypted: true},
+					{Name: "usr2", Passwd: "encpwd2", Encrypted: true},
+				},
+				Groups: []hatypes.UserGroup{
+					{Name: "admin", Users: []string{"usr1", "usr2"}},
+					{Name: "ops", Users: []string{"usr2"}},
+				},
+			}},
+			expConfig: map[string]hatypes.AuthHTTP{
+				"/": {
+					UserlistName: "default_basicpwd",
+					Realm:        "localhost",
+				},
+				"/admin": {
+					UserlistName: "default_basicpwd",
+					Realm:        "localhost",
+					Groups:       []string{"admin"},
+				},
+				"/ops": {
+					UserlistName: "default_basicpwd",
+					Realm:        "localhost",
+					Groups:       []string{"admin", "ops"},
+				},
+			},
+		},
+		// 11
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "basicpwd",
+					ingtypes.BackAuthGroups: "dev,'ops',admin",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {
+				"auth": []byte(`usr1:encpwd1`),
+				"groups": []byte(`
+admin: usr1 usr2
+admin: usr1
+no group
+'dev': usr1`),
+			}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd",
+				Users: []hatypes.User{
+					{Name: "usr1", Passwd: "encpwd1", Encrypted: true},
+				},
+				Groups: []hatypes.UserGroup{
+					{Name: "admin", Users: []string{"usr1"}},
+				},
+			}},
+			expConfig: map[string]hatypes.AuthHTTP{
+				"/": {
+					UserlistName: "default_basicpwd",
+					Realm:        "localhost",
+					Groups:       []string{"dev", "admin"},
+				},
+			},
+			expLogging: `
+WARN ignoring malformed group on secret 'default/basicpwd', declared on ingress 'default/ing1': user 'usr2' of group 'admin' not found line 2
+WARN ignoring malformed group on secret 'default/basicpwd', declared on ingress 'default/ing1': duplicated group 'admin' line 3
+WARN ignoring malformed group on secret 'default/basicpwd', declared on ingress 'default/ing1': missing colon after group name line 4
+WARN ignoring malformed group on secret 'default/basicpwd', declared on ingress 'default/ing1': invalid group name ''dev'' line 5
+WARN group 'dev' declared on ingress 'default/ing1' was not found on secret 'default/basicpwd'
+WARN ignoring invalid group name on ingress 'default/ing1': 'ops'`,
+		},
 	}
 
 	for i, test := range testCase {
@@ -758,109 +1050,333 @@ usr2::clearpwd2`)}},
 	}
 }
 
-func TestBlueGreen(t *testing.T) {
-	buildPod := func(labels string) *api.Pod {
-		l := make(map[string]string)
-		for _, label := range strings.Split(labels, ",") {
-			kv := strings.Split(label, "=")
-			l[kv[0]] = kv[1]
-		}
-		return &api.Pod{
-			ObjectMeta: meta.ObjectMeta{
-				Name:      "pod1",
-				Namespace: "default",
-				Labels:    l,
-			},
-		}
-	}
-	buildAnn := func(bal, mode string) map[string]string {
-		ann := map[string]string{}
-		ann[ingtypes.BackBlueGreenBalance] = bal
-		if mode != "" {
-			ann[ingtypes.BackBlueGreenMode] = mode
-		}
-		return ann
-	}
-	buildEndpoints := func(targets string) []*hatypes.Endpoint {
-		ep := []*hatypes.Endpoint{}
-		if targets != "" {
-			for _, targetRef := range strings.Split(targets, ",") {
-				targetWeight := strings.Split(targetRef, "=")
-				target := targetRef
-				weight := 100
-				if len(targetWeight) == 2 {
-					target = targetWeight[0]
-					if w, err := strconv.ParseInt(targetWeight[1], 10, 0); err == nil {
-						weight = int(w)
-					}
-				}
-				ep = append(ep, &hatypes.Endpoint{
-					Enabled:   true,
-					IP:        "172.17.0.11",
-					Port:      8080,
-					Weight:    weight,
-					TargetRef: target,
-				})
-			}
-		}
-		return ep
-	}
-	pods := map[string]*api.Pod{
-		"pod0101-01": buildPod("app=d01,v=1"),
-		"pod0101-02": buildPod("app=d01,v=1"),
-		"pod0102-01": buildPod("app=d01,v=2"),
-		"pod0102-02": buildPod("app=d01,v=2"),
-		"pod0102-03": buildPod("app=d01,v=2"),
-		"pod0102-04": buildPod("app=d01,v=2"),
-		"pod0103-01": buildPod("app=d01,v=3"),
-		"pod0103-02": buildPod("app=d01,x=3"),
-		"pod0103-03": buildPod("app=d01"),
+func TestBalanceHash(t *testing.T) {
+	type hashBalance struct {
+		algorithm string
+		hash      hatypes.HashBalanceConfig
 	}
 	testCase := []struct {
-		ann        map[string]string
-		endpoints  []*hatypes.Endpoint
-		expConfig  *hatypes.BlueGreenConfig
-		expLabels  []string
-		expWeights []int
-		expLogging string
+		ann      map[string]string
+		modeTCP  bool
+		expected hashBalance
+		logging  string
 	}{
-		//
-		// Balance test cases
-		//
 		// 0
 		{
-			ann:        buildAnn("", ""),
-			endpoints:  buildEndpoints("pod0101-01"),
-			expWeights: []int{100},
-			expLogging: "",
+			expected: hashBalance{algorithm: "roundrobin"},
 		},
 		// 1
 		{
-			ann:        buildAnn("", "err"),
-			endpoints:  buildEndpoints("pod0101-01"),
-			expWeights: []int{100},
-			expLogging: "",
+			ann: map[string]string{
+				ingtypes.BackBalanceHashKey: "header:X-Image-Id",
+			},
+			expected: hashBalance{algorithm: "hdr(X-Image-Id)", hash: hatypes.HashBalanceConfig{Type: "consistent"}},
 		},
 		// 2
 		{
-			ann:        buildAnn("err", ""),
-			endpoints:  buildEndpoints("pod0101-01"),
-			expWeights: []int{100},
-			expLogging: "ERROR blue/green config on ingress 'default/ing1' has an invalid weight format: err",
+			ann: map[string]string{
+				ingtypes.BackBalanceHashKey: "url-param:img",
+			},
+			expected: hashBalance{algorithm: "url_param img", hash: hatypes.HashBalanceConfig{Type: "consistent"}},
 		},
 		// 3
 		{
-			ann:        buildAnn("v=1=xx", ""),
-			endpoints:  buildEndpoints("pod0101-01"),
-			expWeights: []int{100},
-			expLogging: "ERROR blue/green config on ingress 'default/ing1' has an invalid weight value: strconv.ParseInt: parsing \"xx\": invalid syntax",
+			ann: map[string]string{
+				ingtypes.BackBalanceHashKey: "path",
+			},
+			expected: hashBalance{algorithm: "uri path-only", hash: hatypes.HashBalanceConfig{Type: "consistent"}},
 		},
 		// 4
 		{
-			ann:        buildAnn("v=1=1.5", ""),
-			endpoints:  buildEndpoints("pod0101-01"),
-			expWeights: []int{100},
-			expLogging: "ERROR blue/green config on ingress 'default/ing1' has an invalid weight value: strconv.ParseInt: parsing \"1.5\": invalid syntax",
+			ann: map[string]string{
+				ingtypes.BackBalanceHashKey:    "uri",
+				ingtypes.BackBalanceHashFactor: "150",
+			},
+			expected: hashBalance{algorithm: "uri", hash: hatypes.HashBalanceConfig{Type: "consistent", BalanceFactor: 150}},
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackBalanceHashKey: "header:",
+			},
+			expected: hashBalance{algorithm: "roundrobin"},
+			logging:  `WARN ignoring invalid balance-hash-key on ingress 'default/ing1': header:`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackBalanceHashKey: "url-param:a b",
+			},
+			expected: hashBalance{algorithm: "roundrobin"},
+			logging:  `WARN ignoring invalid balance-hash-key on ingress 'default/ing1': url-param:a b`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackBalanceHashKey: "path:/app",
+			},
+			expected: hashBalance{algorithm: "roundrobin"},
+			logging:  `WARN ignoring invalid balance-hash-key on ingress 'default/ing1': path:/app`,
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.BackBalanceHashKey:    "path",
+				ingtypes.BackBalanceHashFactor: "100",
+			},
+			expected: hashBalance{algorithm: "uri path-only", hash: hatypes.HashBalanceConfig{Type: "consistent"}},
+			logging:  `WARN ignoring invalid balance-hash-balance-factor on ingress 'default/ing1', should be 0 or greater than 100: 100`,
+		},
+		// 9
+		{
+			ann: map[string]string{
+				ingtypes.BackBalanceHashFactor: "150",
+			},
+			expected: hashBalance{algorithm: "roundrobin"},
+			logging:  `WARN ignoring balance-hash-balance-factor on ingress 'default/ing1': balance-hash-key is not configured`,
+		},
+		// 10
+		{
+			ann: map[string]string{
+				ingtypes.BackBalanceHashKey: "header:X-Image-Id",
+			},
+			modeTCP:  true,
+			expected: hashBalance{algorithm: "roundrobin"},
+			logging:  `WARN ignoring balance-hash-key on ingress 'default/ing1': cannot be used on a TCP backend`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCase {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		d.backend.BalanceAlgorithm = "roundrobin"
+		d.backend.ModeTCP = test.modeTCP
+		c.createUpdater().buildBackendBalanceHash(d)
+		actual := hashBalance{algorithm: d.backend.BalanceAlgorithm, hash: d.backend.HashBalance}
+		c.compareObjects("balance hash", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestBlockUserAgents(t *testing.T) {
+	configMaps := map[string]*api.ConfigMap{
+		"default/bots": {
+			Data: map[string]string{
+				"crawlers": `
+# seo crawlers
+AhrefsBot, SemrushBot
+MJ12bot # majestic
+`,
+				"tools": "curl python-requests 'wget'",
+			},
+		},
+	}
+	testCases := []struct {
+		paths    []string
+		annPaths map[string]map[string]string
+		modeTCP  bool
+		expected map[string]hatypes.BlockUserAgents
+		logging  string
+	}{
+		// 0
+		{
+			paths: []string{"/"},
+		},
+		// 1
+		{
+			paths: []string{"/", "/app"},
+			annPaths: map[string]map[string]string{
+				"/app": {
+					ingtypes.BackBlockUserAgents: "BadBot,curl",
+				},
+			},
+			expected: map[string]hatypes.BlockUserAgents{
+				"/app": {Action: "deny", Patterns: []string{"BadBot", "curl"}},
+			},
+		},
+		// 2
+		{
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackBlockUserAgents:       "curl\nBadBot",
+					ingtypes.BackBlockUserAgentsAction: "tarpit",
+					ingtypes.BackBlockUserAgentsCM:     "bots",
+				},
+			},
+			expected: map[string]hatypes.BlockUserAgents{
+				"/": {Action: "tarpit", Patterns: []string{"curl", "BadBot", "AhrefsBot", "SemrushBot", "MJ12bot", "python-requests"}},
+			},
+			logging: `WARN skipping invalid user-agent pattern on configmap 'default/bots' key 'tools': 'wget'`,
+		},
+		// 3
+		{
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackBlockUserAgents:       "Bad(Bot)",
+					ingtypes.BackBlockUserAgentsAction: "drop",
+					ingtypes.BackBlockUserAgentsCM:     "notfound",
+				},
+			},
+			logging: `
+WARN skipping invalid user-agent pattern on ingress 'default/ing1': Bad(Bot)
+WARN ignoring user-agent list on ingress 'default/ing1': configmap not found: 'default/notfound'`,
+		},
+		// 4
+		{
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackBlockUserAgents:       "BadBot",
+					ingtypes.BackBlockUserAgentsAction: "drop",
+				},
+			},
+			expected: map[string]hatypes.BlockUserAgents{
+				"/": {Action: "deny", Patterns: []string{"BadBot"}},
+			},
+			logging: `WARN ignoring invalid user-agent block action on ingress 'default/ing1': drop`,
+		},
+		// 5
+		{
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackBlockUserAgents: "BadBot",
+				},
+			},
+			modeTCP: true,
+		},
+	}
+	annDefault := map[string]string{
+		ingtypes.BackBlockUserAgentsAction: "deny",
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.ConfigMapList = configMaps
+		d := c.createBackendMappingData("default/app", source, annDefault, test.annPaths, test.paths)
+		d.backend.ModeTCP = test.modeTCP
+		c.createUpdater().buildBackendBlockUserAgents(d)
+		actual := map[string]hatypes.BlockUserAgents{}
+		for _, path := range d.backend.Paths {
+			if path.BlockUA.Patterns != nil {
+				actual[path.Path()] = path.BlockUA
+			}
+		}
+		if test.expected == nil {
+			test.expected = map[string]hatypes.BlockUserAgents{}
+		}
+		c.compareObjects("block user agents", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestBlueGreen(t *testing.T) {
+	buildPod := func(labels string) *api.Pod {
+		l := make(map[string]string)
+		for _, label := range strings.Split(labels, ",") {
+			kv := strings.Split(label, "=")
+			l[kv[0]] = kv[1]
+		}
+		return &api.Pod{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      "pod1",
+				Namespace: "default",
+				Labels:    l,
+			},
+		}
+	}
+	buildAnn := func(bal, mode string) map[string]string {
+		ann := map[string]string{}
+		ann[ingtypes.BackBlueGreenBalance] = bal
+		if mode != "" {
+			ann[ingtypes.BackBlueGreenMode] = mode
+		}
+		return ann
+	}
+	buildEndpoints := func(targets string) []*hatypes.Endpoint {
+		ep := []*hatypes.Endpoint{}
+		if targets != "" {
+			for _, targetRef := range strings.Split(targets, ",") {
+				targetWeight := strings.Split(targetRef, "=")
+				target := targetRef
+				weight := 100
+				if len(targetWeight) == 2 {
+					target = targetWeight[0]
+					if w, err := strconv.ParseInt(targetWeight[1], 10, 0); err == nil {
+						weight = int(w)
+					}
+				}
+				ep = append(ep, &hatypes.Endpoint{
+					Enabled:   true,
+					IP:        "172.17.0.11",
+					Port:      8080,
+					Weight:    weight,
+					TargetRef: target,
+				})
+			}
+		}
+		return ep
+	}
+	pods := map[string]*api.Pod{
+		"pod0101-01": buildPod("app=d01,v=1"),
+		"pod0101-02": buildPod("app=d01,v=1"),
+		"pod0102-01": buildPod("app=d01,v=2"),
+		"pod0102-02": buildPod("app=d01,v=2"),
+		"pod0102-03": buildPod("app=d01,v=2"),
+		"pod0102-04": buildPod("app=d01,v=2"),
+		"pod0103-01": buildPod("app=d01,v=3"),
+		"pod0103-02": buildPod("app=d01,x=3"),
+		"pod0103-03": buildPod("app=d01"),
+	}
+	testCase := []struct {
+		ann        map[string]string
+		endpoints  []*hatypes.Endpoint
+		expConfig  *hatypes.BlueGreenConfig
+		expLabels  []string
+		expWeights []int
+		expLogging string
+	}{
+		//
+		// Balance test cases
+		//
+		// 0
+		{
+			ann:        buildAnn("", ""),
+			endpoints:  buildEndpoints("pod0101-01"),
+			expWeights: []int{100},
+			expLogging: "",
+		},
+		// 1
+		{
+			ann:        buildAnn("", "err"),
+			endpoints:  buildEndpoints("pod0101-01"),
+			expWeights: []int{100},
+			expLogging: "",
+		},
+		// 2
+		{
+			ann:        buildAnn("err", ""),
+			endpoints:  buildEndpoints("pod0101-01"),
+			expWeights: []int{100},
+			expLogging: "ERROR blue/green config on ingress 'default/ing1' has an invalid weight format: err",
+		},
+		// 3
+		{
+			ann:        buildAnn("v=1=xx", ""),
+			endpoints:  buildEndpoints("pod0101-01"),
+			expWeights: []int{100},
+			expLogging: "ERROR blue/green config on ingress 'default/ing1' has an invalid weight value: strconv.ParseInt: parsing \"xx\": invalid syntax",
+		},
+		// 4
+		{
+			ann:        buildAnn("v=1=1.5", ""),
+			endpoints:  buildEndpoints("pod0101-01"),
+			expWeights: []int{100},
+			expLogging: "ERROR blue/green config on ingress 'default/ing1' has an invalid weight value: strconv.ParseInt: parsing \"1.5\": invalid syntax",
 		},
 		// 5
 		{
@@ -1159,51 +1675,311 @@ INFO-V(3) blue/green balance label 'v=3' on ingress 'default/ing1' does not refe
 	}
 }
 
-func TestBodySize(t *testing.T) {
+func TestABTest(t *testing.T) {
+	buildPod := func(name, version string) *api.Pod {
+		return &api.Pod{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{"app": "d01", "v": version},
+			},
+		}
+	}
+	pods := map[string]*api.Pod{
+		"pod01": buildPod("pod01", "1"),
+		"pod02": buildPod("pod02", "1"),
+		"pod03": buildPod("pod03", "2"),
+	}
 	testCases := []struct {
-		source     Source
-		annDefault map[string]string
-		ann        map[string]map[string]string
-		paths      []string
-		expected   map[string]int64
-		logging    string
+		ann        map[string]string
+		modeTCP    bool
+		expConfig  hatypes.ABTestConfig
+		expRanges  []string
+		expLogging string
 	}{
 		// 0
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackProxyBodySize: "10",
-				},
-			},
-			expected: map[string]int64{
-				"/": 10,
-			},
+			ann:       map[string]string{},
+			expRanges: []string{"", "", ""},
 		},
 		// 1
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackProxyBodySize: "10k",
-				},
-				"/app": {
-					ingtypes.BackProxyBodySize: "10m",
-				},
-				"/sub": {
-					ingtypes.BackProxyBodySize: "10g",
-				},
-			},
-			expected: map[string]int64{
-				"/":    10240,
-				"/app": 10485760,
-				"/sub": 10737418240,
+			ann: map[string]string{
+				ingtypes.BackABTestKey:   "cookie:uid",
+				ingtypes.BackABTestSplit: "v=1=90,v=2=10",
 			},
+			expConfig: hatypes.ABTestConfig{Buckets: 10000, Fetch: "req.cook(uid)"},
+			expRanges: []string{"0:4499", "4500:8999", "9000:9999"},
 		},
 		// 2
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackProxyBodySize: "unlimited",
-				},
+			ann: map[string]string{
+				ingtypes.BackABTestKey:   "header:X-User",
+				ingtypes.BackABTestSplit: "v=2=50,v=3=50",
+			},
+			expConfig:  hatypes.ABTestConfig{Buckets: 10000, Fetch: "req.hdr(X-User)"},
+			expRanges:  []string{"", "", "0:4999"},
+			expLogging: `INFO-V(3) A/B test label 'v=3' on ingress 'default/ing1' does not reference any endpoint`,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackABTestKey:   "src",
+				ingtypes.BackABTestSplit: "v=1=90,v=2=10",
+			},
+			expRanges:  []string{"", "", ""},
+			expLogging: `WARN ignoring A/B test on ingress 'default/ing1': invalid ab-test-key: src`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackABTestKey:   "cookie:uid",
+				ingtypes.BackABTestSplit: "v=1=90,v=2",
+			},
+			expRanges:  []string{"", "", ""},
+			expLogging: `WARN ignoring A/B test on ingress 'default/ing1': invalid variant, expected '<label>=<value>=<percent>': v=2`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackABTestKey:   "cookie:uid",
+				ingtypes.BackABTestSplit: "v=1=90,v=2=20",
+			},
+			expRanges:  []string{"", "", ""},
+			expLogging: `WARN ignoring A/B test on ingress 'default/ing1': sum of the percentages should be 100, found 110`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackABTestKey:   "cookie:uid",
+				ingtypes.BackABTestSplit: "v=1=-10,v=2=110",
+			},
+			expRanges:  []string{"", "", ""},
+			expLogging: `WARN ignoring A/B test on ingress 'default/ing1': invalid percentage: v=1=-10`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackABTestKey:   "cookie:uid",
+				ingtypes.BackABTestSplit: "v=1=90,v=2=10",
+			},
+			modeTCP:    true,
+			expRanges:  []string{"", "", ""},
+			expLogging: `WARN ignoring A/B test on ingress 'default/ing1': backend is not in http mode`,
+		},
+	}
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.PodList = pods
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		d.backend.ModeTCP = test.modeTCP
+		for _, pod := range []string{"pod01", "pod02", "pod03"} {
+			d.backend.Endpoints = append(d.backend.Endpoints, &hatypes.Endpoint{
+				Enabled:   true,
+				IP:        "172.17.0.11",
+				Port:      8080,
+				Weight:    100,
+				TargetRef: pod,
+			})
+		}
+		c.createUpdater().buildBackendABTest(d)
+		ranges := make([]string, len(d.backend.Endpoints))
+		for j, ep := range d.backend.Endpoints {
+			ranges[j] = ep.ABTestRange
+		}
+		c.compareObjects("ab test config", i, d.backend.ABTest, test.expConfig)
+		c.compareObjects("ab test ranges", i, ranges, test.expRanges)
+		c.logger.CompareLogging(test.expLogging)
+		c.teardown()
+	}
+}
+
+func TestBlueGreenCanary(t *testing.T) {
+	buildPod := func(name, version string) *api.Pod {
+		return &api.Pod{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{"app": "d01", "v": version},
+			},
+		}
+	}
+	pods := map[string]*api.Pod{
+		"pod01": buildPod("pod01", "1"),
+		"pod02": buildPod("pod02", "1"),
+		"pod03": buildPod("pod03", "2"),
+	}
+	testCases := []struct {
+		ann        map[string]string
+		expConfig  hatypes.BlueGreenCanary
+		expCanary  []bool
+		expLogging string
+	}{
+		// 0
+		{
+			ann:       map[string]string{},
+			expCanary: []bool{false, false, false},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenCanary:       "v=2",
+				ingtypes.BackBlueGreenCanaryHeader: "X-Canary:always",
+			},
+			expConfig: hatypes.BlueGreenCanary{
+				HeaderName:  "X-Canary",
+				HeaderValue: "always",
+			},
+			expCanary: []bool{false, false, true},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenCanary:       "v=1",
+				ingtypes.BackBlueGreenCanaryCookie: "canary:^(qa|dev):.*",
+				ingtypes.BackBlueGreenCanaryMatch:  "regex",
+			},
+			expConfig: hatypes.BlueGreenCanary{
+				CookieName:  "canary",
+				CookieValue: "^(qa|dev):.*",
+				Regex:       true,
+			},
+			expCanary: []bool{true, true, false},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenCanary:       "v=2",
+				ingtypes.BackBlueGreenCanaryHeader: "X-Canary:always",
+				ingtypes.BackBlueGreenCanaryMatch:  "prefix",
+			},
+			expConfig: hatypes.BlueGreenCanary{
+				HeaderName:  "X-Canary",
+				HeaderValue: "always",
+			},
+			expCanary:  []bool{false, false, true},
+			expLogging: `WARN unsupported blue/green canary match 'prefix' on ingress 'default/ing1', falling back to 'exact'`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenCanary: "v=2",
+			},
+			expCanary:  []bool{false, false, false},
+			expLogging: `WARN ignoring blue/green canary on ingress 'default/ing1': missing canary cookie or header`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenCanary:       "v:2",
+				ingtypes.BackBlueGreenCanaryHeader: "X-Canary:always",
+			},
+			expCanary:  []bool{false, false, false},
+			expLogging: `ERROR invalid LabelName=LabelValue pair on ingress 'default/ing1': v:2`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenCanary:       "v=2",
+				ingtypes.BackBlueGreenCanaryHeader: "X-Canary:always on",
+			},
+			expCanary:  []bool{false, false, false},
+			expLogging: `ERROR invalid Name:Value pair on ingress 'default/ing1': X-Canary:always on`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenCanary:       "v=3",
+				ingtypes.BackBlueGreenCanaryHeader: "X-Canary:always",
+			},
+			expConfig: hatypes.BlueGreenCanary{
+				HeaderName:  "X-Canary",
+				HeaderValue: "always",
+			},
+			expCanary:  []bool{false, false, false},
+			expLogging: `INFO-V(3) blue/green canary label 'v=3' on ingress 'default/ing1' does not reference any endpoint`,
+		},
+	}
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.PodList = pods
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{ingtypes.BackBlueGreenCanaryMatch: "exact"})
+		for _, pod := range []string{"pod01", "pod02", "pod03"} {
+			d.backend.Endpoints = append(d.backend.Endpoints, &hatypes.Endpoint{
+				Enabled:   true,
+				IP:        "172.17.0.11",
+				Port:      8080,
+				Weight:    100,
+				TargetRef: pod,
+			})
+		}
+		c.createUpdater().buildBackendBlueGreenCanary(d)
+		canary := make([]bool, len(d.backend.Endpoints))
+		for j, ep := range d.backend.Endpoints {
+			canary[j] = ep.Canary
+		}
+		c.compareObjects("blue/green canary config", i, d.backend.BlueGreen.Canary, test.expConfig)
+		c.compareObjects("blue/green canary endpoints", i, canary, test.expCanary)
+		c.logger.CompareLogging(test.expLogging)
+		c.teardown()
+	}
+}
+
+func TestBodySize(t *testing.T) {
+	testCases := []struct {
+		source     Source
+		annDefault map[string]string
+		ann        map[string]map[string]string
+		paths      []string
+		expected   map[string]int64
+		logging    string
+	}{
+		// 0
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackProxyBodySize: "10",
+				},
+			},
+			expected: map[string]int64{
+				"/": 10,
+			},
+		},
+		// 1
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackProxyBodySize: "10k",
+				},
+				"/app": {
+					ingtypes.BackProxyBodySize: "10m",
+				},
+				"/sub": {
+					ingtypes.BackProxyBodySize: "10g",
+				},
+			},
+			expected: map[string]int64{
+				"/":    10240,
+				"/app": 10485760,
+				"/sub": 10737418240,
+			},
+		},
+		// 2
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackProxyBodySize: "unlimited",
+				},
 			},
 			expected: map[string]int64{
 				"/": 0,
@@ -1259,581 +2035,2350 @@ const (
 
 var corsDefaultOrigin = []string{"*"}
 
-func TestCors(t *testing.T) {
+func TestCache(t *testing.T) {
+	annDefault := map[string]string{
+		ingtypes.BackCacheMaxAge:       "60s",
+		ingtypes.BackCacheTotalMaxSize: "64m",
+	}
 	testCases := []struct {
 		paths    []string
-		ann      map[string]map[string]string
-		expected map[string]hatypes.Cors
+		annPaths map[string]map[string]string
+		modeTCP  bool
+		expected map[string]hatypes.Cache
 		logging  string
 	}{
 		// 0
 		{
 			paths: []string{"/"},
-			expected: map[string]hatypes.Cors{
-				"/": {},
-			},
 		},
 		// 1
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackCorsEnable: "true",
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
+				"/static": {
+					ingtypes.BackCache: "true",
 				},
 			},
-			expected: map[string]hatypes.Cors{
-				"/": {
-					Enabled:          true,
-					AllowCredentials: false,
-					AllowHeaders:     corsDefaultHeaders,
-					AllowMethods:     corsDefaultMethods,
-					AllowOrigin:      corsDefaultOrigin,
-					ExposeHeaders:    "",
-					MaxAge:           corsDefaultMaxAge,
-				},
+			expected: map[string]hatypes.Cache{
+				"/static": {MaxAge: 60, TotalMaxSize: 64},
 			},
 		},
 		// 2
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackCorsEnable: "false",
-				},
-				"/sub": {
-					ingtypes.BackCorsEnable: "true",
+			annPaths: map[string]map[string]string{
+				"/static": {
+					ingtypes.BackCache:              "true",
+					ingtypes.BackCacheMaxAge:        "5m",
+					ingtypes.BackCacheMaxObjectSize: "1m",
+					ingtypes.BackCacheStatusHeader:  "X-Cache-Status",
+					ingtypes.BackCacheTotalMaxSize:  "1500k",
 				},
 			},
-			expected: map[string]hatypes.Cors{
-				"/": {},
-				"/sub": {
-					Enabled:          true,
-					AllowCredentials: false,
-					AllowHeaders:     corsDefaultHeaders,
-					AllowMethods:     corsDefaultMethods,
-					AllowOrigin:      corsDefaultOrigin,
-					ExposeHeaders:    "",
-					MaxAge:           corsDefaultMaxAge,
-				},
+			expected: map[string]hatypes.Cache{
+				"/static": {MaxAge: 300, MaxObjectSize: 1048576, StatusHeader: "X-Cache-Status", TotalMaxSize: 2},
 			},
 		},
 		// 3
 		{
-			ann: map[string]map[string]string{
+			annPaths: map[string]map[string]string{
 				"/": {
-					ingtypes.BackCorsEnable:      "true",
-					ingtypes.BackCorsAllowOrigin: "http://d1.local,https://d2.local,https://d3.local",
+					ingtypes.BackCache:             "true",
+					ingtypes.BackCacheTotalMaxSize: "5g",
 				},
-			},
-			expected: map[string]hatypes.Cors{
-				"/": {
-					Enabled:          true,
-					AllowCredentials: false,
-					AllowHeaders:     corsDefaultHeaders,
-					AllowMethods:     corsDefaultMethods,
-					AllowOrigin:      []string{"http://d1.local", "https://d2.local", "https://d3.local"},
-					ExposeHeaders:    "",
-					MaxAge:           corsDefaultMaxAge,
+				"/app": {
+					ingtypes.BackCache:       "true",
+					ingtypes.BackCacheMaxAge: "10",
 				},
 			},
+			logging: `
+WARN ignoring cache on ingress 'default/ing1' due to invalid total max size: 5g
+WARN ignoring cache on ingress 'default/ing1' due to invalid max age: 10`,
 		},
 		// 4
 		{
-			ann: map[string]map[string]string{
+			annPaths: map[string]map[string]string{
 				"/": {
-					ingtypes.BackCorsEnable:      "true",
-					ingtypes.BackCorsAllowOrigin: "https://d1.local,https://d2.local,invalid",
+					ingtypes.BackCache:              "true",
+					ingtypes.BackCacheMaxObjectSize: "33m",
+					ingtypes.BackCacheStatusHeader:  "X Cache",
 				},
 			},
-			expected: map[string]hatypes.Cors{
+			expected: map[string]hatypes.Cache{
+				"/": {MaxAge: 60, TotalMaxSize: 64},
+			},
+			logging: `
+WARN ignoring invalid cache max object size on ingress 'default/ing1': 33m
+WARN ignoring invalid cache status header name on ingress 'default/ing1': X Cache`,
+		},
+		// 5
+		{
+			annPaths: map[string]map[string]string{
 				"/": {
-					Enabled:          true,
-					AllowCredentials: false,
-					AllowHeaders:     corsDefaultHeaders,
-					AllowMethods:     corsDefaultMethods,
-					AllowOrigin:      corsDefaultOrigin,
-					ExposeHeaders:    "",
-					MaxAge:           corsDefaultMaxAge,
+					ingtypes.BackCache: "true",
 				},
 			},
-			logging: `WARN ignoring invalid cors origin on ingress 'default/ing': invalid`,
+			modeTCP: true,
 		},
 	}
-	annDefault := map[string]string{
-		ingtypes.BackCorsAllowHeaders: corsDefaultHeaders,
-		ingtypes.BackCorsAllowMethods: corsDefaultMethods,
-		ingtypes.BackCorsAllowOrigin:  strings.Join(corsDefaultOrigin, ","),
-		ingtypes.BackCorsMaxAge:       strconv.Itoa(corsDefaultMaxAge),
-	}
-	source := &Source{
-		Namespace: "default",
-		Name:      "ing",
-		Type:      "ingress",
-	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
 	for i, test := range testCases {
 		c := setup(t)
-		d := c.createBackendMappingData("default/app", source, annDefault, test.ann, test.paths)
-		c.createUpdater().buildBackendCors(d)
-		actual := map[string]hatypes.Cors{}
+		d := c.createBackendMappingData("default/app", source, annDefault, test.annPaths, test.paths)
+		d.backend.ModeTCP = test.modeTCP
+		c.createUpdater().buildBackendCache(d)
+		actual := map[string]hatypes.Cache{}
 		for _, path := range d.backend.Paths {
-			actual[path.Path()] = path.Cors
+			if path.Cache != (hatypes.Cache{}) {
+				actual[path.Path()] = path.Cache
+			}
 		}
-		c.compareObjects("cors", i, actual, test.expected)
+		if test.expected == nil {
+			test.expected = map[string]hatypes.Cache{}
+		}
+		c.compareObjects("cache", i, actual, test.expected)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
 }
 
-func TestHeaders(t *testing.T) {
+func TestCompression(t *testing.T) {
 	testCases := []struct {
-		headers  string
-		expected []*hatypes.BackendHeader
+		ann      map[string]string
+		modeTCP  bool
+		expected hatypes.Compression
 		logging  string
 	}{
 		// 0
 		{
-			headers: `invalid`,
-			logging: `WARN ignored missing header name or value on ingress 'ing1/app': invalid`,
+			ann: map[string]string{
+				ingtypes.BackCompressionAlgo: "gzip",
+			},
 		},
 		// 1
 		{
-			headers: `key value`,
-			expected: []*hatypes.BackendHeader{
-				{Name: "key", Value: "value"},
+			ann: map[string]string{
+				ingtypes.BackCompression:      "true",
+				ingtypes.BackCompressionAlgo:  "gzip",
+				ingtypes.BackCompressionTypes: "text/html text/plain",
+			},
+			expected: hatypes.Compression{
+				Algos: []string{"gzip"},
+				Types: []string{"text/html", "text/plain"},
 			},
 		},
 		// 2
 		{
-			headers: `name: content`,
-			expected: []*hatypes.BackendHeader{
-				{Name: "name", Value: "content"},
+			ann: map[string]string{
+				ingtypes.BackCompression:        "true",
+				ingtypes.BackCompressionAlgo:    "br,GZIP, deflate",
+				ingtypes.BackCompressionMinSize: "1k",
+				ingtypes.BackCompressionTypes:   "text/html,application/json, text html",
 			},
+			expected: hatypes.Compression{
+				Algos:   []string{"gzip", "deflate"},
+				MinSize: 1024,
+				Types:   []string{"text/html", "application/json"},
+			},
+			logging: `
+WARN ignoring unsupported compression algorithm on ingress 'default/ing1': br
+WARN ignoring invalid compression type on ingress 'default/ing1': text
+WARN ignoring invalid compression type on ingress 'default/ing1': html`,
 		},
 		// 3
 		{
-			headers: `k:v`,
-			expected: []*hatypes.BackendHeader{
-				{Name: "k", Value: "v"},
+			ann: map[string]string{
+				ingtypes.BackCompression:     "true",
+				ingtypes.BackCompressionAlgo: "br",
 			},
+			logging: `
+WARN ignoring unsupported compression algorithm on ingress 'default/ing1': br
+WARN ignoring compression on ingress 'default/ing1': no supported algorithm was configured`,
 		},
 		// 4
 		{
-			headers: `host: %[service].%[namespace].svc.cluster.local`,
-			expected: []*hatypes.BackendHeader{
-				{Name: "host", Value: "app.default.svc.cluster.local"},
+			ann: map[string]string{
+				ingtypes.BackCompression:        "true",
+				ingtypes.BackCompressionAlgo:    "gzip",
+				ingtypes.BackCompressionMinSize: "1x",
+			},
+			expected: hatypes.Compression{
+				Algos: []string{"gzip"},
 			},
+			logging: `WARN ignoring invalid compression min size on ingress 'default/ing1': 1x`,
 		},
 		// 5
 		{
-			headers: `
-k8snamespace: %[namespace]
-k8sservice: %[service]
-host: %[service].%[namespace].svc.cluster.local
-`,
-			expected: []*hatypes.BackendHeader{
-				{Name: "k8snamespace", Value: "default"},
-				{Name: "k8sservice", Value: "app"},
-				{Name: "host", Value: "app.default.svc.cluster.local"},
+			ann: map[string]string{
+				ingtypes.BackCompression:     "true",
+				ingtypes.BackCompressionAlgo: "gzip",
 			},
+			modeTCP: true,
 		},
 	}
-	source := &Source{
-		Namespace: "ing1",
-		Name:      "app",
-		Type:      "ingress",
-	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
 	for i, test := range testCases {
 		c := setup(t)
-		ann := map[string]map[string]string{
-			"/": {ingtypes.BackHeaders: test.headers},
-		}
-		d := c.createBackendMappingData("default/app", source, map[string]string{}, ann, []string{"/"})
-		c.createUpdater().buildBackendHeaders(d)
-		c.compareObjects("headers", i, d.backend.Headers, test.expected)
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		d.backend.ModeTCP = test.modeTCP
+		c.createUpdater().buildBackendCompression(d)
+		c.compareObjects("compression", i, d.backend.Compression, test.expected)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
 }
 
-func TestHSTS(t *testing.T) {
+func TestCors(t *testing.T) {
 	testCases := []struct {
-		paths      []string
-		source     Source
-		annDefault map[string]string
-		ann        map[string]map[string]string
-		expected   map[string]hatypes.HSTS
-		logging    string
+		paths    []string
+		ann      map[string]map[string]string
+		expected map[string]hatypes.Cors
+		logging  string
 	}{
 		// 0
 		{
-			paths: []string{"/", "/url"},
-			annDefault: map[string]string{
-				ingtypes.BackHSTS:       "true",
-				ingtypes.BackHSTSMaxAge: "15768000",
+			paths: []string{"/"},
+			expected: map[string]hatypes.Cors{
+				"/": {},
 			},
+		},
+		// 1
+		{
 			ann: map[string]map[string]string{
-				"/": {},
-				"/url": {
-					ingtypes.BackHSTSMaxAge:  "50",
-					ingtypes.BackHSTSPreload: "true",
+				"/": {
+					ingtypes.BackCorsEnable: "true",
 				},
 			},
-			expected: map[string]hatypes.HSTS{
+			expected: map[string]hatypes.Cors{
 				"/": {
-					Enabled:    true,
-					MaxAge:     15768000,
-					Subdomains: false,
-					Preload:    false,
+					Enabled:          true,
+					AllowCredentials: false,
+					AllowHeaders:     corsDefaultHeaders,
+					AllowMethods:     corsDefaultMethods,
+					AllowOrigin:      corsDefaultOrigin,
+					ExposeHeaders:    "",
+					MaxAge:           corsDefaultMaxAge,
 				},
-				"/url": {
-					Enabled:    true,
-					MaxAge:     50,
-					Subdomains: false,
-					Preload:    true,
+			},
+		},
+		// 2
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCorsEnable: "false",
+				},
+				"/sub": {
+					ingtypes.BackCorsEnable: "true",
+				},
+			},
+			expected: map[string]hatypes.Cors{
+				"/": {},
+				"/sub": {
+					Enabled:          true,
+					AllowCredentials: false,
+					AllowHeaders:     corsDefaultHeaders,
+					AllowMethods:     corsDefaultMethods,
+					AllowOrigin:      corsDefaultOrigin,
+					ExposeHeaders:    "",
+					MaxAge:           corsDefaultMaxAge,
 				},
 			},
 		},
-		// 1
+		// 3
 		{
-			paths: []string{"/"},
-			annDefault: map[string]string{
-				ingtypes.BackHSTS:       "true",
-				ingtypes.BackHSTSMaxAge: "15768000",
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCorsEnable:      "true",
+					ingtypes.BackCorsAllowOrigin: "http://d1.local,https://d2.local,https://d3.local",
+				},
+			},
+			expected: map[string]hatypes.Cors{
+				"/": {
+					Enabled:          true,
+					AllowCredentials: false,
+					AllowHeaders:     corsDefaultHeaders,
+					AllowMethods:     corsDefaultMethods,
+					AllowOrigin:      []string{"http://d1.local", "https://d2.local", "https://d3.local"},
+					ExposeHeaders:    "",
+					MaxAge:           corsDefaultMaxAge,
+				},
 			},
+		},
+		// 4
+		{
 			ann: map[string]map[string]string{
 				"/": {
-					ingtypes.BackHSTSMaxAge:            "50",
-					ingtypes.BackHSTSPreload:           "not-valid-bool",
-					ingtypes.BackHSTSIncludeSubdomains: "true",
+					ingtypes.BackCorsEnable:      "true",
+					ingtypes.BackCorsAllowOrigin: "https://d1.local,https://d2.local,invalid",
 				},
 			},
-			expected: map[string]hatypes.HSTS{
+			expected: map[string]hatypes.Cors{
 				"/": {
-					Enabled:    true,
-					MaxAge:     50,
-					Subdomains: true,
-					Preload:    false,
+					Enabled:          true,
+					AllowCredentials: false,
+					AllowHeaders:     corsDefaultHeaders,
+					AllowMethods:     corsDefaultMethods,
+					AllowOrigin:      corsDefaultOrigin,
+					ExposeHeaders:    "",
+					MaxAge:           corsDefaultMaxAge,
 				},
 			},
-			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
-			logging: `WARN ignoring invalid bool expression on ingress 'default/ing1' key 'hsts-preload': not-valid-bool`,
+			logging: `WARN ignoring invalid cors origin on ingress 'default/ing': invalid`,
+		},
+	}
+	annDefault := map[string]string{
+		ingtypes.BackCorsAllowHeaders: corsDefaultHeaders,
+		ingtypes.BackCorsAllowMethods: corsDefaultMethods,
+		ingtypes.BackCorsAllowOrigin:  strings.Join(corsDefaultOrigin, ","),
+		ingtypes.BackCorsMaxAge:       strconv.Itoa(corsDefaultMaxAge),
+	}
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing",
+		Type:      "ingress",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", source, annDefault, test.ann, test.paths)
+		c.createUpdater().buildBackendCors(d)
+		actual := map[string]hatypes.Cors{}
+		for _, path := range d.backend.Paths {
+			actual[path.Path()] = path.Cors
+		}
+		c.compareObjects("cors", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+type snippetCheckerMock struct{}
+
+func (s *snippetCheckerMock) CheckBackend(snippet []string) error {
+	for _, line := range snippet {
+		if strings.HasPrefix(line, "invalid") {
+			return fmt.Errorf("unknown keyword '%s'", line)
+		}
+	}
+	return nil
+}
+
+func TestCustomConfig(t *testing.T) {
+	testCases := []struct {
+		ann       map[string]string
+		check     bool
+		expected  []string
+		expEvents []string
+		logging   string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackConfigBackend: "invalid keyword\ntimeout server 1m",
+			},
+			expected: []string{"invalid keyword", "timeout server 1m"},
 		},
 		// 2
 		{
-			paths: []string{"/"},
-			expected: map[string]hatypes.HSTS{
-				"/": {},
+			ann: map[string]string{
+				ingtypes.BackConfigBackend: "http-request set-header X-Id 1\ntimeout server 1m",
+			},
+			check:    true,
+			expected: []string{"http-request set-header X-Id 1", "timeout server 1m"},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackConfigBackend: "invalid keyword\ntimeout server 1m",
 			},
+			check:     true,
+			expEvents: []string{"ingress default/ing1 Warning InvalidSnippet: config-backend was ignored: unknown keyword 'invalid keyword'"},
+			logging:   `WARN ignoring config-backend on ingress 'default/ing1': invalid configuration snippet: unknown keyword 'invalid keyword'`,
 		},
 	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
 	for i, test := range testCases {
 		c := setup(t)
-		d := c.createBackendMappingData("default/app", &test.source, test.annDefault, test.ann, test.paths)
 		u := c.createUpdater()
-		u.buildBackendHSTS(d)
-		actual := map[string]hatypes.HSTS{}
-		for _, path := range d.backend.Paths {
-			actual[path.Path()] = path.HSTS
-		}
-		c.compareObjects("hsts", i, actual, test.expected)
+		u.options.SnippetChecker = &snippetCheckerMock{}
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{
+			ingtypes.GlobalConfigSnippetCheck: strconv.FormatBool(test.check),
+		})
+		u.buildBackendCustomConfig(d)
+		c.compareObjects("custom config", i, d.backend.CustomConfig, test.expected)
+		c.compareObjects("events", i, c.cache.Events, test.expEvents)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
 }
 
-func TestOAuth(t *testing.T) {
+func TestDenyMethods(t *testing.T) {
 	testCases := []struct {
-		ann      map[string]map[string]string
-		external bool
-		haslua   bool
-		backend  string
-		authExp  map[string]hatypes.AuthExternal
+		paths    []string
+		annPaths map[string]map[string]string
+		modeTCP  bool
+		expected map[string]hatypes.DenyMethods
 		logging  string
 	}{
 		// 0
 		{
-			ann:     map[string]map[string]string{},
-			authExp: map[string]hatypes.AuthExternal{},
+			paths: []string{"/"},
 		},
 		// 1
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackOAuth: "none",
+			paths: []string{"/", "/api"},
+			annPaths: map[string]map[string]string{
+				"/api": {
+					ingtypes.BackDenyMethods: "TRACE,delete",
 				},
 			},
-			authExp: map[string]hatypes.AuthExternal{
-				"/": {},
+			expected: map[string]hatypes.DenyMethods{
+				"/api": {Methods: []string{"TRACE", "DELETE"}, Status: 405},
 			},
-			logging: "WARN ignoring invalid oauth implementation 'none' on ingress 'default/ing1'",
 		},
 		// 2
 		{
-			ann: map[string]map[string]string{
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
 				"/": {
-					ingtypes.BackOAuth: "oauth2_proxy",
+					ingtypes.BackDenyMethods:       "TRACE, TRACE PUT",
+					ingtypes.BackDenyMethodsStatus: "403",
 				},
 			},
-			authExp: map[string]hatypes.AuthExternal{
-				"/": {},
+			expected: map[string]hatypes.DenyMethods{
+				"/": {Methods: []string{"TRACE", "PUT"}, Status: 403},
 			},
-			logging: "ERROR path '/oauth2' was not found on namespace 'default'",
 		},
 		// 3
 		{
-			ann: map[string]map[string]string{
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
 				"/": {
-					ingtypes.BackOAuth: "oauth2_proxy",
+					ingtypes.BackDenyMethods:       "TRACE",
+					ingtypes.BackDenyMethodsStatus: "418",
 				},
 			},
-			backend: "default:back:/oauth2",
-			authExp: map[string]hatypes.AuthExternal{
-				"/": {
-					AllowedPath:     "/oauth2/",
-					AuthBackendName: "default_back_8080",
-					AuthPath:        "/oauth2/auth",
-					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
-				},
+			expected: map[string]hatypes.DenyMethods{
+				"/": {Methods: []string{"TRACE"}, Status: 405},
 			},
+			logging: `WARN ignoring invalid deny status code on ingress 'default/ing1', using 405 instead: 418`,
 		},
 		// 4
 		{
-			ann: map[string]map[string]string{
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
 				"/": {
-					ingtypes.BackOAuth:          "oauth2_proxy",
-					ingtypes.BackOAuthURIPrefix: "/auth",
+					ingtypes.BackDenyMethods: "TRACE,M-SEARCH",
+				},
+			},
+			logging: `WARN ignoring deny methods on ingress 'default/ing1': invalid HTTP method: M-SEARCH`,
+		},
+		// 5
+		{
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackDenyMethods: "TRACE",
+				},
+			},
+			modeTCP: true,
+		},
+	}
+	annDefault := map[string]string{
+		ingtypes.BackDenyMethodsStatus: "405",
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", source, annDefault, test.annPaths, test.paths)
+		d.backend.ModeTCP = test.modeTCP
+		c.createUpdater().buildBackendDenyMethods(d)
+		actual := map[string]hatypes.DenyMethods{}
+		for _, path := range d.backend.Paths {
+			if path.DenyMethods.Methods != nil {
+				actual[path.Path()] = path.DenyMethods
+			}
+		}
+		if test.expected == nil {
+			test.expected = map[string]hatypes.DenyMethods{}
+		}
+		c.compareObjects("deny methods", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestErrorPages(t *testing.T) {
+	configMaps := map[string]*api.ConfigMap{
+		"default/errors": {
+			Data: map[string]string{
+				"503": "<html>unavailable</html>",
+				"404": "<html>not found</html>",
+			},
+		},
+		"default/invalid": {
+			Data: map[string]string{
+				"418":     "<html>teapot</html>",
+				"502":     "<html>bad gateway</html>",
+				"default": "<html>error</html>",
+				"504":     strings.Repeat("-", 8000),
+			},
+		},
+		"ingress-controller/errors": {
+			Data: map[string]string{
+				"502": "<html>controller</html>",
+			},
+		},
+	}
+	testCases := []struct {
+		ann        map[string]string
+		annDefault map[string]string
+		modeTCP    bool
+		expected   []*hatypes.ErrorPage
+		logging    string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackErrorPages: "errors",
+			},
+			expected: []*hatypes.ErrorPage{
+				{Code: 404, Content: "<html>not found</html>"},
+				{Code: 503, Content: "<html>unavailable</html>"},
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackErrorPages: "invalid",
+			},
+			expected: []*hatypes.ErrorPage{
+				{Code: 502, Content: "<html>bad gateway</html>"},
+			},
+			logging: `
+WARN ignoring error page '418' on ingress 'default/ing1': unsupported status code
+WARN ignoring error page '504' on ingress 'default/ing1': content is larger than 7168 bytes
+WARN ignoring error page 'default' on ingress 'default/ing1': unsupported status code`,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackErrorPages: "notfound",
+			},
+			logging: `WARN ignoring error-pages on ingress 'default/ing1': configmap not found: 'default/notfound'`,
+		},
+		// 4
+		{
+			annDefault: map[string]string{
+				ingtypes.BackErrorPages: "errors",
+			},
+			expected: []*hatypes.ErrorPage{
+				{Code: 502, Content: "<html>controller</html>"},
+			},
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackErrorPages: "errors",
+			},
+			modeTCP: true,
+			logging: `WARN ignoring error-pages on ingress 'default/ing1': cannot be used on a TCP backend`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.ConfigMapList = configMaps
+		d := c.createBackendData("default/app", source, test.ann, test.annDefault)
+		d.backend.ModeTCP = test.modeTCP
+		c.createUpdater().buildBackendErrorPages(d)
+		c.compareObjects("error pages", i, d.backend.ErrorPages, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	testCases := []struct {
+		headers  string
+		expected []*hatypes.BackendHeader
+		logging  string
+	}{
+		// 0
+		{
+			headers: `invalid`,
+			logging: `WARN ignored missing header name or value on ingress 'ing1/app': invalid`,
+		},
+		// 1
+		{
+			headers: `key value`,
+			expected: []*hatypes.BackendHeader{
+				{Name: "key", Value: "value"},
+			},
+		},
+		// 2
+		{
+			headers: `name: content`,
+			expected: []*hatypes.BackendHeader{
+				{Name: "name", Value: "content"},
+			},
+		},
+		// 3
+		{
+			headers: `k:v`,
+			expected: []*hatypes.BackendHeader{
+				{Name: "k", Value: "v"},
+			},
+		},
+		// 4
+		{
+			headers: `host: %[service].%[namespace].svc.cluster.local`,
+			expected: []*hatypes.BackendHeader{
+				{Name: "host", Value: "app.default.svc.cluster.local"},
+			},
+		},
+		// 5
+		{
+			headers: `
+k8snamespace: %[namespace]
+k8sservice: %[service]
+host: %[service].%[namespace].svc.cluster.local
+`,
+			expected: []*hatypes.BackendHeader{
+				{Name: "k8snamespace", Value: "default"},
+				{Name: "k8sservice", Value: "app"},
+				{Name: "host", Value: "app.default.svc.cluster.local"},
+			},
+		},
+	}
+	source := &Source{
+		Namespace: "ing1",
+		Name:      "app",
+		Type:      "ingress",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		ann := map[string]map[string]string{
+			"/": {ingtypes.BackHeaders: test.headers},
+		}
+		d := c.createBackendMappingData("default/app", source, map[string]string{}, ann, []string{"/"})
+		c.createUpdater().buildBackendHeaders(d)
+		c.compareObjects("headers", i, d.backend.Headers, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	testCase := []struct {
+		ann      map[string]string
+		expected hatypes.HealthCheck
+		logging  string
+	}{
+		// 0
+		{
+			expected: hatypes.HealthCheck{Interval: "2s"},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckURI:       "/check",
+				ingtypes.BackHealthCheckPort:      "8080",
+				ingtypes.BackHealthCheckRiseCount: "1",
+				ingtypes.BackHealthCheckFallCount: "5",
+			},
+			expected: hatypes.HealthCheck{URI: "/check", Port: 8080, Interval: "2s", RiseCount: 1, FallCount: 5},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckURI:     "/check",
+				ingtypes.BackHealthCheckMethod:  "head",
+				ingtypes.BackHealthCheckHost:    "app.local",
+				ingtypes.BackHealthCheckExpect:  "status  200-299,304",
+				ingtypes.BackHealthCheckTimeout: "1s",
+				ingtypes.BackHealthCheckHeaders: `
+X-Check: true
+Authorization: Bearer 0123`,
+			},
+			expected: hatypes.HealthCheck{
+				URI:      "/check",
+				Method:   "HEAD",
+				Host:     "app.local",
+				Expect:   "status 200-299,304",
+				Interval: "2s",
+				Timeout:  "1s",
+				Headers: []*hatypes.BackendHeader{
+					{Name: "X-Check", Value: "true"},
+					{Name: "Authorization", Value: "Bearer 0123"},
+				},
+			},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckURI:    "/check",
+				ingtypes.BackHealthCheckExpect: "! rstring ^fail",
+			},
+			expected: hatypes.HealthCheck{URI: "/check", Interval: "2s", Expect: "! rstring ^fail"},
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckURI:     "/check",
+				ingtypes.BackHealthCheckMethod:  "GET /",
+				ingtypes.BackHealthCheckHost:    "app local",
+				ingtypes.BackHealthCheckExpect:  "status ok",
+				ingtypes.BackHealthCheckTimeout: "1z",
+				ingtypes.BackHealthCheckHeaders: `
+X-Check
+X-Invalid: "true"
+X Invalid: true`,
+			},
+			expected: hatypes.HealthCheck{URI: "/check", Interval: "2s"},
+			logging: `
+WARN ignoring invalid time format on ingress 'default/ing1': 1z
+WARN ignoring invalid health-check-method on ingress 'default/ing1': GET /
+WARN ignoring invalid health-check-host on ingress 'default/ing1': app local
+WARN ignoring health check header without name or value on ingress 'default/ing1': X-Check
+WARN ignoring invalid health check header on ingress 'default/ing1': X-Invalid: "true"
+WARN ignoring invalid health check header on ingress 'default/ing1': X Invalid: true
+WARN ignoring invalid health-check-expect on ingress 'default/ing1': status ok`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckHost:    "app.local",
+				ingtypes.BackHealthCheckTimeout: "1s",
+			},
+			expected: hatypes.HealthCheck{Interval: "2s", Timeout: "1s"},
+			logging:  `WARN ignoring http health check options on ingress 'default/ing1': health-check-uri is not configured`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckTCPSequence: `
+send PING\r\n
+expect string +PONG`,
+			},
+			expected: hatypes.HealthCheck{Interval: "2s", TCPCheck: []*hatypes.TCPCheckRule{
+				{Action: "send", Value: `PING\r\n`},
+				{Action: "expect", Match: "string", Value: "+PONG"},
+			}},
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckTCPSequence: "expect status 200",
+			},
+			expected: hatypes.HealthCheck{Interval: "2s"},
+			logging:  `WARN ignoring health-check-tcp-sequence on ingress 'default/ing1': invalid tcp check expect match 'status': expect status 200`,
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckURI:         "/check",
+				ingtypes.BackHealthCheckTCPSequence: "send PING",
+			},
+			expected: hatypes.HealthCheck{Interval: "2s", URI: "/check"},
+			logging:  `WARN ignoring health-check-tcp-sequence on ingress 'default/ing1': health-check-uri is also configured`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	annDefault := map[string]string{
+		ingtypes.BackHealthCheckInterval: "2s",
+	}
+	for i, test := range testCase {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, annDefault)
+		c.createUpdater().buildBackendHealthCheck(d)
+		c.compareObjects("health check", i, d.backend.HealthCheck, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	testCases := []struct {
+		paths      []string
+		source     Source
+		annDefault map[string]string
+		ann        map[string]map[string]string
+		expected   map[string]hatypes.HSTS
+		logging    string
+	}{
+		// 0
+		{
+			paths: []string{"/", "/url"},
+			annDefault: map[string]string{
+				ingtypes.BackHSTS:       "true",
+				ingtypes.BackHSTSMaxAge: "15768000",
+			},
+			ann: map[string]map[string]string{
+				"/": {},
+				"/url": {
+					ingtypes.BackHSTSMaxAge:  "50",
+					ingtypes.BackHSTSPreload: "true",
+				},
+			},
+			expected: map[string]hatypes.HSTS{
+				"/": {
+					Enabled:    true,
+					MaxAge:     15768000,
+					Subdomains: false,
+					Preload:    false,
+				},
+				"/url": {
+					Enabled:    true,
+					MaxAge:     50,
+					Subdomains: false,
+					Preload:    true,
+				},
+			},
+		},
+		// 1
+		{
+			paths: []string{"/"},
+			annDefault: map[string]string{
+				ingtypes.BackHSTS:       "true",
+				ingtypes.BackHSTSMaxAge: "15768000",
+			},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackHSTSMaxAge:            "50",
+					ingtypes.BackHSTSPreload:           "not-valid-bool",
+					ingtypes.BackHSTSIncludeSubdomains: "true",
+				},
+			},
+			expected: map[string]hatypes.HSTS{
+				"/": {
+					Enabled:    true,
+					MaxAge:     50,
+					Subdomains: true,
+					Preload:    false,
+				},
+			},
+			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			logging: `WARN ignoring invalid bool expression on ingress 'default/ing1' key 'hsts-preload': not-valid-bool`,
+		},
+		// 2
+		{
+			paths: []string{"/"},
+			expected: map[string]hatypes.HSTS{
+				"/": {},
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", &test.source, test.annDefault, test.ann, test.paths)
+		u := c.createUpdater()
+		u.buildBackendHSTS(d)
+		actual := map[string]hatypes.HSTS{}
+		for _, path := range d.backend.Paths {
+			actual[path.Path()] = path.HSTS
+		}
+		c.compareObjects("hsts", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestHTTPHeaders(t *testing.T) {
+	testCases := []struct {
+		paths    []string
+		ann      map[string]map[string]string
+		expected map[string]hatypes.HTTPHeaders
+		logging  string
+	}{
+		// 0
+		{
+			paths: []string{"/"},
+			expected: map[string]hatypes.HTTPHeaders{
+				"/": {},
+			},
+		},
+		// 1
+		{
+			paths: []string{"/", "/app"},
+			ann: map[string]map[string]string{
+				"/": {},
+				"/app": {
+					ingtypes.BackRequestHeadersAdd:  "X-Tenant: app",
+					ingtypes.BackRequestHeadersDel:  "X-Debug, Cookie2",
+					ingtypes.BackRequestHeadersSet:  "X-Env prod\nX-Literal: %[src] ${HOME}",
+					ingtypes.BackResponseHeadersAdd: "Link: </style.css>; rel=preload",
+					ingtypes.BackResponseHeadersDel: "Server,X-Powered-By",
+					ingtypes.BackResponseHeadersSet: "Cache-Control: no-store\nX-Msg: it's done",
+				},
+			},
+			expected: map[string]hatypes.HTTPHeaders{
+				"/": {},
+				"/app": {
+					RequestAdd: []hatypes.BackendHeader{
+						{Name: "X-Tenant", Value: "'app'"},
+					},
+					RequestDel: []string{"X-Debug", "Cookie2"},
+					RequestSet: []hatypes.BackendHeader{
+						{Name: "X-Env", Value: "'prod'"},
+						{Name: "X-Literal", Value: "'%%[src] ${HOME}'"},
+					},
+					ResponseAdd: []hatypes.BackendHeader{
+						{Name: "Link", Value: "'</style.css>; rel=preload'"},
+					},
+					ResponseDel: []string{"Server", "X-Powered-By"},
+					ResponseSet: []hatypes.BackendHeader{
+						{Name: "Cache-Control", Value: "'no-store'"},
+						{Name: "X-Msg", Value: `'it'\''s done'`},
+					},
+				},
+			},
+		},
+		// 2
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackRequestHeadersDel:  "X Debug,X-Trace",
+					ingtypes.BackResponseHeadersSet: "X-Frame-Options\nX{Id}: 10\nX-Id: 20",
+				},
+			},
+			expected: map[string]hatypes.HTTPHeaders{
+				"/": {
+					RequestDel: []string{"X-Trace"},
+					ResponseSet: []hatypes.BackendHeader{
+						{Name: "X-Id", Value: "'20'"},
+					},
+				},
+			},
+			logging: `
+WARN ignoring invalid header name on ingress 'default/ing1': X Debug
+WARN ignored missing header name or value on ingress 'default/ing1': X-Frame-Options
+WARN ignoring invalid header name on ingress 'default/ing1': X{Id}`,
+		},
+		// 3
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAfterResponseHdrsAdd: "X-Served-By: edge",
+					ingtypes.BackAfterResponseHdrsDel: "Server, X{Id}",
+					ingtypes.BackAfterResponseHdrsSet: "Cache-Control: no-store",
+				},
+			},
+			expected: map[string]hatypes.HTTPHeaders{
+				"/": {
+					AfterResponseAdd: []hatypes.BackendHeader{
+						{Name: "X-Served-By", Value: "'edge'"},
+					},
+					AfterResponseDel: []string{"Server"},
+					AfterResponseSet: []hatypes.BackendHeader{
+						{Name: "Cache-Control", Value: "'no-store'"},
+					},
+				},
+			},
+			logging: `
+WARN ignoring invalid header name on ingress 'default/ing1': X{Id}`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", source, map[string]string{}, test.ann, test.paths)
+		c.createUpdater().buildBackendHTTPHeaders(d)
+		actual := map[string]hatypes.HTTPHeaders{}
+		for _, path := range d.backend.Paths {
+			actual[path.Path()] = path.HTTPHeaders
+		}
+		c.compareObjects("http headers", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestJWT(t *testing.T) {
+	testCases := []struct {
+		paths    []string
+		ann      map[string]map[string]string
+		expected map[string]hatypes.JWT
+		logging  string
+	}{
+		// 0
+		{
+			paths: []string{"/"},
+			expected: map[string]hatypes.JWT{
+				"/": {},
+			},
+		},
+		// 1
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackJWTKeySecret: "notfound",
+				},
+			},
+			expected: map[string]hatypes.JWT{
+				"/": {},
+			},
+			logging: `ERROR error reading JWT public key on ingress 'default/ing1': secret not found: 'default/notfound'`,
+		},
+		// 2
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackJWTKeySecret: "jwt",
+					ingtypes.BackJWTAlgorithm: "HS256",
+				},
+			},
+			expected: map[string]hatypes.JWT{
+				"/": {},
+			},
+			logging: `WARN ignoring JWT validation on ingress 'default/ing1': unsupported algorithm: HS256`,
+		},
+		// 3
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackJWTKeySecret: "jwt",
+					ingtypes.BackJWTIssuer:    "my issuer",
+				},
+			},
+			expected: map[string]hatypes.JWT{
+				"/": {},
+			},
+			logging: `WARN ignoring JWT validation on ingress 'default/ing1': invalid issuer: my issuer`,
+		},
+		// 4
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackJWTKeySecret: "jwt",
+					ingtypes.BackJWTAudience:  "'app'",
+				},
+			},
+			expected: map[string]hatypes.JWT{
+				"/": {},
+			},
+			logging: `WARN ignoring JWT validation on ingress 'default/ing1': invalid audience: 'app'`,
+		},
+		// 5
+		{
+			paths: []string{"/", "/app"},
+			ann: map[string]map[string]string{
+				"/": {},
+				"/app": {
+					ingtypes.BackJWTKeySecret: "jwt",
+					ingtypes.BackJWTAlgorithm: "ES256",
+					ingtypes.BackJWTIssuer:    "https://auth.local/",
+					ingtypes.BackJWTAudience:  "app",
+				},
+			},
+			expected: map[string]hatypes.JWT{
+				"/": {},
+				"/app": {
+					Algorithm:   "ES256",
+					Audience:    "app",
+					Issuer:      "https://auth.local/",
+					KeyFilename: "/var/haproxy/ssl/jwt.pem",
+					KeyHash:     "710cc0bbdd80ee9cce737a1388cdab287461f218",
+				},
+			},
+		},
+		// 6
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackJWTKeySecret:     "jwt",
+					ingtypes.BackJWTClaimsHeaders: "sub:X-User, email:X-Email,,groups,:X-Empty,realm_access.roles:X-Roles,name:X User",
+				},
+			},
+			expected: map[string]hatypes.JWT{
+				"/": {
+					Algorithm: "RS256",
+					ClaimHeaders: []hatypes.BackendHeader{
+						{Name: "X-User", Value: "sub"},
+						{Name: "X-Email", Value: "email"},
+						{Name: "X-Roles", Value: "realm_access.roles"},
+					},
+					KeyFilename: "/var/haproxy/ssl/jwt.pem",
+					KeyHash:     "710cc0bbdd80ee9cce737a1388cdab287461f218",
+				},
+			},
+			logging: `
+WARN ignoring invalid claim to header mapping on ingress 'default/ing1': groups
+WARN ignoring invalid claim to header mapping on ingress 'default/ing1': :X-Empty
+WARN ignoring invalid claim to header mapping on ingress 'default/ing1': name:X User`,
+		},
+		// 7
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackJWTKeySecret: "jwt",
+					ingtypes.BackJWTJWKSURL:   "https://auth.local/jwks.json",
+				},
+			},
+			expected: map[string]hatypes.JWT{
+				"/": {},
+			},
+			logging: `WARN ignoring JWT validation on ingress 'default/ing1': jwt-key-secret and jwt-jwks-url cannot be used together`,
+		},
+		// 8
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackJWTJWKSURL: "auth.local/jwks.json",
+				},
+			},
+			expected: map[string]hatypes.JWT{
+				"/": {},
+			},
+			logging: `WARN ignoring JWT validation on ingress 'default/ing1': invalid JWKS URL: auth.local/jwks.json`,
+		},
+		// 9
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackJWTJWKSURL: "https://auth.local/notfound.json",
+				},
+			},
+			expected: map[string]hatypes.JWT{
+				"/": {
+					Algorithm: "RS256",
+					JWKSURL:   "https://auth.local/notfound.json",
+				},
+			},
+			logging: `ERROR error reading JWKS on ingress 'default/ing1', requests will be denied until the keys are read: JWKS not found: 'https://auth.local/notfound.json'`,
+		},
+		// 10
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackJWTJWKSURL:  "https://auth.local/jwks.json",
+					ingtypes.BackJWTAudience: "app",
+				},
+			},
+			expected: map[string]hatypes.JWT{
+				"/": {
+					Algorithm: "RS256",
+					Audience:  "app",
+					JWKS: []hatypes.JWTKey{
+						{KID: "key1", Filename: "/var/haproxy/ssl/jwks_1.pem", Hash: "1"},
+						{Filename: "/var/haproxy/ssl/jwks_3.pem", Hash: "3"},
+					},
+					JWKSURL: "https://auth.local/jwks.json",
+				},
+			},
+			logging: `WARN ignoring key of the JWKS on ingress 'default/ing1': invalid kid: key 2`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	annDefault := map[string]string{
+		ingtypes.BackJWTAlgorithm: "RS256",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.SecretPKPath = map[string]string{
+			"default/jwt": "/var/haproxy/ssl/jwt.pem",
+		}
+		c.cache.JWKS = map[string][]convtypes.JWKSKey{
+			"https://auth.local/jwks.json": {
+				{KID: "key1", File: convtypes.File{Filename: "/var/haproxy/ssl/jwks_1.pem", SHA1Hash: "1"}},
+				{KID: "key 2", File: convtypes.File{Filename: "/var/haproxy/ssl/jwks_2.pem", SHA1Hash: "2"}},
+				{File: convtypes.File{Filename: "/var/haproxy/ssl/jwks_3.pem", SHA1Hash: "3"}},
+			},
+		}
+		d := c.createBackendMappingData("default/app", source, annDefault, test.ann, test.paths)
+		c.createUpdater().buildBackendJWT(d)
+		actual := map[string]hatypes.JWT{}
+		for _, path := range d.backend.Paths {
+			actual[path.Path()] = path.JWT
+		}
+		c.compareObjects("jwt", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestLimit(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		modeTCP  bool
+		expected hatypes.BackendLimit
+		logging  string
+	}{
+		// 0
+		{
+			expected: hatypes.BackendLimit{
+				DenyStatus: 429,
+			},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackLimitConnections: "10",
+				ingtypes.BackLimitRPS:         "20",
+				ingtypes.BackLimitReqRPS:      "30",
+				ingtypes.BackLimitWhitelist:   "10.0.0.0/8,192.168.0.1",
+			},
+			expected: hatypes.BackendLimit{
+				Connections: 10,
+				DenyStatus:  429,
+				ReqRPS:      30,
+				RPS:         20,
+				Whitelist:   []string{"10.0.0.0/8", "192.168.0.1"},
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackLimitDenyStatus: "503",
+				ingtypes.BackLimitHeader:     "X-Api-Key",
+				ingtypes.BackLimitReqRPS:     "30",
+			},
+			expected: hatypes.BackendLimit{
+				DenyStatus: 503,
+				Header:     "X-Api-Key",
+				ReqRPS:     30,
+			},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackLimitDenyStatus: "302",
+				ingtypes.BackLimitHeader:     "X Api Key",
+				ingtypes.BackLimitReqRPS:     "30",
+			},
+			expected: hatypes.BackendLimit{
+				DenyStatus: 429,
+				ReqRPS:     30,
+			},
+			logging: `
+WARN ignoring invalid deny status code on ingress 'default/ing1', using 429 instead: 302
+WARN ignoring invalid header name on ingress 'default/ing1', using the source IP instead: X Api Key`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackLimitConnections: "10",
+				ingtypes.BackLimitHeader:      "X-Api-Key",
+				ingtypes.BackLimitReqRPS:      "30",
+			},
+			modeTCP: true,
+			expected: hatypes.BackendLimit{
+				Connections: 10,
+				DenyStatus:  429,
+			},
+			logging: `
+WARN ignoring 'limit-req-rps' on ingress 'default/ing1': backend is in tcp mode
+WARN ignoring 'limit-header' on ingress 'default/ing1': backend is in tcp mode`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	annDefault := map[string]string{
+		ingtypes.BackLimitDenyStatus: "429",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, annDefault)
+		d.backend.ModeTCP = test.modeTCP
+		c.createUpdater().buildBackendLimit(d)
+		c.compareObjects("limit", i, d.backend.Limit, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestLua(t *testing.T) {
+	scripts := []*hatypes.LuaScript{
+		{Name: "auth.lua", Actions: []string{"check_token"}},
+		{Name: "headers.lua", Actions: []string{"add_tier"}, Fetches: []string{"client_tier"}},
+	}
+	testCases := []struct {
+		annPaths map[string]map[string]string
+		modeTCP  bool
+		expected map[string]hatypes.PathLua
+		logging  string
+	}{
+		// 0
+		{
+			annPaths: map[string]map[string]string{
+				"/": {},
+			},
+		},
+		// 1
+		{
+			annPaths: map[string]map[string]string{
+				"/": {},
+				"/api": {
+					ingtypes.BackLuaActions: `
+http-request check_token Authorization
+http-response add_tier
+`,
+					ingtypes.BackLuaFetchHeaders: `
+X-Tier client_tier
+X-Tier-Level client_tier level 1
+`,
+				},
+			},
+			expected: map[string]hatypes.PathLua{
+				"/api": {
+					Actions: []hatypes.LuaAction{
+						{Phase: "http-request", Name: "check_token", Args: []string{"Authorization"}},
+						{Phase: "http-response", Name: "add_tier", Args: []string{}},
+					},
+					Headers: []hatypes.LuaHeader{
+						{Name: "X-Tier", Fetch: "client_tier", Args: []string{}},
+						{Name: "X-Tier-Level", Fetch: "client_tier", Args: []string{"level", "1"}},
+					},
+				},
+			},
+		},
+		// 2
+		{
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackLuaActions: `
+tcp-request check_token
+http-request check_token "Authorization"
+http-request mirror
+`,
+					ingtypes.BackLuaFetchHeaders: `
+X-Tier
+X-Tier client_tier 'level'
+X-Tier other_fetch
+`,
+				},
+			},
+			logging: `
+WARN ignoring invalid lua action on ingress 'default/ing1': tcp-request check_token
+WARN ignoring invalid lua action on ingress 'default/ing1': http-request check_token "Authorization"
+WARN ignoring lua action on ingress 'default/ing1': action not registered by lua scripts: mirror
+WARN ignoring invalid lua fetch header on ingress 'default/ing1': X-Tier
+WARN ignoring invalid lua fetch header on ingress 'default/ing1': X-Tier client_tier 'level'
+WARN ignoring lua fetch header on ingress 'default/ing1': sample fetch not registered by lua scripts: other_fetch`,
+		},
+		// 3
+		{
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackLuaActions: "http-request check_token",
+				},
+			},
+			modeTCP: true,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.haproxy.Global().LuaScripts = scripts
+		d := c.createBackendMappingData("default/app", source, map[string]string{}, test.annPaths, []string{})
+		d.backend.ModeTCP = test.modeTCP
+		c.createUpdater().buildBackendLua(d)
+		actual := map[string]hatypes.PathLua{}
+		for _, path := range d.backend.Paths {
+			if path.Lua.Actions != nil || path.Lua.Headers != nil {
+				actual[path.Path()] = path.Lua
+			}
+		}
+		if test.expected == nil {
+			test.expected = map[string]hatypes.PathLua{}
+		}
+		c.compareObjects("lua", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	testCase := []struct {
+		paths    []string
+		ann      map[string]map[string]string
+		expected map[string]hatypes.Maintenance
+		logging  string
+	}{
+		// 0
+		{
+			paths: []string{"/"},
+			expected: map[string]hatypes.Maintenance{
+				"/": {},
+			},
+		},
+		// 1
+		{
+			paths: []string{"/", "/api"},
+			ann: map[string]map[string]string{
+				"/api": {
+					ingtypes.BackMaintenance: "true",
+				},
+			},
+			expected: map[string]hatypes.Maintenance{
+				"/":    {},
+				"/api": {Enabled: true},
+			},
+		},
+		// 2
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackMaintenance:          "true",
+					ingtypes.BackMaintenanceAllowlist: "10.0.0.0/8, 192.168.1.10,invalid",
+				},
+			},
+			expected: map[string]hatypes.Maintenance{
+				"/": {Enabled: true, AllowList: []string{"10.0.0.0/8", "192.168.1.10"}},
+			},
+			logging: `WARN skipping invalid IP or cidr on ingress 'default/ing1': invalid`,
+		},
+		// 3
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackMaintenance:         "true",
+					ingtypes.BackMaintenanceRedirect: "https://status.local/",
+				},
+			},
+			expected: map[string]hatypes.Maintenance{
+				"/": {Enabled: true, RedirectURL: "https://status.local/"},
+			},
+		},
+		// 4
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackMaintenance:         "true",
+					ingtypes.BackMaintenanceRedirect: "status.local",
+				},
+			},
+			expected: map[string]hatypes.Maintenance{
+				"/": {Enabled: true},
+			},
+			logging: `WARN ignoring invalid maintenance-redirect on ingress 'default/ing1', using the 503 response instead: status.local`,
+		},
+		// 5
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackMaintenance:         "false",
+					ingtypes.BackMaintenanceRedirect: "/maintenance.html",
+				},
+			},
+			expected: map[string]hatypes.Maintenance{
+				"/": {},
+			},
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCase {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", source, map[string]string{}, test.ann, test.paths)
+		c.createUpdater().buildBackendMaintenance(d)
+		actual := map[string]hatypes.Maintenance{}
+		for _, path := range d.backend.Paths {
+			actual[path.Path()] = path.Maintenance
+		}
+		c.compareObjects("maintenance", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestMirror(t *testing.T) {
+	testCase := []struct {
+		url        string
+		percentage string
+		isExternal bool
+		hasLua     bool
+		expMirror  hatypes.Mirror
+		expBackend string
+		logging    string
+	}{
+		// 0
+		{
+			url: "",
+		},
+		// 1
+		{
+			url:     "mirrorservice:8080",
+			logging: `WARN ignoring mirror URL on ingress 'default/ing1': invalid URL syntax: mirrorservice:8080`,
+		},
+		// 2
+		{
+			url:     "http://10.0.0.1:8080",
+			logging: `WARN ignoring mirror URL with an invalid protocol on ingress 'default/ing1': http`,
+		},
+		// 3
+		{
+			url:     "svc://mirrorservice",
+			logging: `WARN skipping mirror-url on ingress 'default/ing1': missing service port: svc://mirrorservice`,
+		},
+		// 4
+		{
+			url: "svc://notfound:8080",
+		},
+		// 5
+		{
+			url:     "svc://app:8080",
+			logging: `WARN ignoring mirror URL on ingress 'default/ing1': mirror and path use the same service: svc://app:8080`,
+		},
+		// 6
+		{
+			url:        "svc://mirrorservice:8080",
+			isExternal: true,
+			logging:    `WARN traffic mirroring on ingress 'default/ing1' needs Lua json module, install lua-json4 and enable 'external-has-lua' global config`,
+		},
+		// 7
+		{
+			url:        "svc://mirrorservice:8080",
+			isExternal: true,
+			hasLua:     true,
+			expMirror:  hatypes.Mirror{AuthBackendName: "_auth_4001", Percentage: 100},
+			expBackend: "default_mirrorservice_8080",
+		},
+		// 8
+		{
+			url:        "svc://mirrorservice:8080",
+			expMirror:  hatypes.Mirror{AuthBackendName: "_auth_4001", Percentage: 100},
+			expBackend: "default_mirrorservice_8080",
+		},
+		// 9
+		{
+			url:        "svc://default/mirrorservice:8080",
+			percentage: "10",
+			expMirror:  hatypes.Mirror{AuthBackendName: "_auth_4001", Percentage: 10},
+			expBackend: "default_mirrorservice_8080",
+		},
+		// 10
+		{
+			url:        "svc://mirrorservice:8080",
+			percentage: "0",
+		},
+		// 11
+		{
+			url:        "svc://mirrorservice:8080",
+			percentage: "101",
+			logging:    `WARN ignoring mirror-url on ingress 'default/ing1': invalid percentage: 101`,
+		},
+		// 12
+		{
+			url:        "svc://mirrorservice:8080",
+			percentage: "half",
+			logging:    `WARN ignoring mirror-url on ingress 'default/ing1': invalid percentage: half`,
+		},
+	}
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
+	for i, test := range testCase {
+		c := setup(t)
+		u := c.createUpdater()
+		c.haproxy.Frontend().AuthProxy.RangeStart = 4001
+		c.haproxy.Frontend().AuthProxy.RangeEnd = 4009
+		if test.isExternal {
+			c.haproxy.Global().External.MasterSocket = "/socket"
+		}
+		c.haproxy.Global().External.HasLua = test.hasLua
+		c.haproxy.Backends().AcquireBackend("default", "app", "8080")
+		c.haproxy.Backends().AcquireBackend("default", "mirrorservice", "8080")
+		ann := map[string]map[string]string{
+			"/": {
+				ingtypes.BackMirrorURL: test.url,
+			},
+		}
+		if test.percentage != "" {
+			ann["/"][ingtypes.BackMirrorPercentage] = test.percentage
+		}
+		defaults := map[string]string{
+			ingtypes.BackMirrorPercentage: "100",
+		}
+		d := c.createBackendMappingData("default/app", source, defaults, ann, []string{"/"})
+		u.buildBackendMirror(d)
+		var backend string
+		if bindList := c.haproxy.Frontend().AuthProxy.BindList; len(bindList) > 0 {
+			backend = bindList[0].Backend.String()
+		}
+		c.compareObjects("mirror", i, d.backend.Paths[0].Mirror, test.expMirror)
+		c.compareObjects("mirror backend", i, backend, test.expBackend)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestOAuth(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]map[string]string
+		external bool
+		haslua   bool
+		backend  string
+		authExp  map[string]hatypes.AuthExternal
+		logging  string
+	}{
+		// 0
+		{
+			ann:     map[string]map[string]string{},
+			authExp: map[string]hatypes.AuthExternal{},
+		},
+		// 1
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth: "none",
+				},
+			},
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {},
+			},
+			logging: "WARN ignoring invalid oauth implementation 'none' on ingress 'default/ing1'",
+		},
+		// 2
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth: "oauth2_proxy",
+				},
+			},
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {},
+			},
+			logging: "ERROR path '/oauth2' was not found on namespace 'default'",
+		},
+		// 3
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth: "oauth2_proxy",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+			},
+		},
+		// 4
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:          "oauth2_proxy",
+					ingtypes.BackOAuthURIPrefix: "/auth",
 				},
 			},
 			backend: "default:back:/auth",
 			authExp: map[string]hatypes.AuthExternal{
 				"/": {
-					AllowedPath:     "/auth/",
+					AllowedPath:     "/auth/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/auth/auth",
+					RedirectOnFail:  "/auth/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+			},
+		},
+		// 5
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthHeaders: "X-Auth-New:req.var_from_lua",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-New": "req.var_from_lua"},
+				},
+			},
+		},
+		// 6
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthHeaders: "space before:attr",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{},
+				},
+			},
+			logging: "WARN invalid header format 'space before:attr' on ingress 'default/ing1'",
+		},
+		// 7
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthHeaders: "x-header:x- invalid",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
 					AuthBackendName: "default_back_8080",
-					AuthPath:        "/auth/auth",
-					RedirectOnFail:  "/auth/start?rd=%[path]",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{},
+				},
+			},
+			logging: "WARN invalid header format 'x-header:x- invalid' on ingress 'default/ing1'",
+		},
+		// 8
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthHeaders: "more:colons:unsupported",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{},
+				},
+			},
+			logging: "WARN invalid header format 'more:colons:unsupported' on ingress 'default/ing1'",
+		},
+		// 9
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthHeaders: ",,X-Auth-Request-Email,,X-Auth-New:x-header-from-auth,,,X-Auth-Other:req.other",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars: map[string]string{
+						"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email",
+						"X-Auth-New":           "req.auth_response_header.x_header_from_auth",
+						"X-Auth-Other":         "req.other",
+					},
+				},
+			},
+		},
+		// 10
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth: "oauth2_proxy",
+				},
+				"/app": {
+					ingtypes.BackOAuth: "oauth2_proxy",
+				},
+			},
+			external: true,
+			backend:  "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/":    {},
+				"/app": {},
+			},
+			logging: "WARN oauth2_proxy on ingress 'default/ing1' needs Lua json module, install lua-json4 and enable 'external-has-lua' global config",
+		},
+		// 11
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth: "oauth2_proxy",
+				},
+				"/app": {
+					ingtypes.BackOAuth: "oauth2_proxy",
+				},
+			},
+			external: true,
+			haslua:   true,
+			backend:  "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+				"/app": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+			},
+		},
+		// 12
+		{
+			ann: map[string]map[string]string{
+				"/": {},
+				"/app": {
+					ingtypes.BackOAuth: "oauth2_proxy",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {},
+				"/app": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
 					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
 				},
 			},
 		},
-		// 5
+		// 13
+		{
+			ann: map[string]map[string]string{
+				"/": {},
+				"/app": {
+					ingtypes.BackAuthURL: "http://10.0.0.2:8000",
+					ingtypes.BackOAuth:   "oauth2_proxy",
+				},
+			},
+			authExp: map[string]hatypes.AuthExternal{
+				"/":    {},
+				"/app": {},
+			},
+			logging: `WARN ignoring oauth configuration on ingress 'default/ing1': auth-url was configured and has precedence`,
+		},
+	}
+
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
+	annDefault := map[string]string{ingtypes.BackOAuthHeaders: "X-Auth-Request-Email"}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", source, annDefault, test.ann, []string{})
+		if test.external {
+			c.haproxy.Global().External.MasterSocket = "/tmp/master.sock"
+		}
+		c.haproxy.Global().External.HasLua = test.haslua
+		if test.backend != "" {
+			b := strings.Split(test.backend, ":")
+			backend := c.haproxy.Backends().AcquireBackend(b[0], b[1], "8080")
+			c.haproxy.Hosts().AcquireHost("app.local").AddPath(backend, b[2], hatypes.MatchBegin)
+		}
+		c.createUpdater().buildBackendOAuth(d)
+		actual := map[string]hatypes.AuthExternal{}
+		for _, path := range d.backend.Paths {
+			actual[path.Path()] = path.AuthExternal
+		}
+		for k, v := range test.authExp {
+			if v.AuthBackendName != "" {
+				v.HeadersRequest = []string{".*"}
+				v.HeadersSucceed = []string{"-"}
+				v.HeadersFail = []string{"-"}
+				v.Method = "HEAD"
+				test.authExp[k] = v
+			}
+		}
+		c.compareObjects("oauth", i, actual, test.authExp)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestOutlier(t *testing.T) {
+	testCase := []struct {
+		ann      map[string]string
+		modeTCP  bool
+		noCheck  bool
+		expected hatypes.ServerConfig
+		logging  string
+	}{
+		// 0
+		{
+			expected: hatypes.ServerConfig{},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackOutlierObserve: "layer7",
+			},
+			expected: hatypes.ServerConfig{Observe: "layer7", ErrorLimit: 10, OnError: "mark-down"},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackOutlierObserve:    "layer4",
+				ingtypes.BackOutlierErrorLimit: "5",
+				ingtypes.BackOutlierOnError:    "sudden-death",
+			},
+			expected: hatypes.ServerConfig{Observe: "layer4", ErrorLimit: 5, OnError: "sudden-death"},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackOutlierObserve: "layer8",
+			},
+			expected: hatypes.ServerConfig{},
+			logging:  `WARN ignoring invalid outlier-observe on ingress 'default/ing1': layer8`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackOutlierObserve: "layer7",
+			},
+			modeTCP:  true,
+			expected: hatypes.ServerConfig{},
+			logging:  `WARN ignoring outlier-observe on ingress 'default/ing1': layer7 cannot be used on a TCP backend`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackOutlierObserve: "layer4",
+			},
+			modeTCP:  true,
+			expected: hatypes.ServerConfig{Observe: "layer4", ErrorLimit: 10, OnError: "mark-down"},
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackOutlierObserve: "layer7",
+			},
+			noCheck:  true,
+			expected: hatypes.ServerConfig{},
+			logging:  `WARN ignoring outlier-observe on ingress 'default/ing1': health check is disabled`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackOutlierObserve:    "layer7",
+				ingtypes.BackOutlierErrorLimit: "0",
+				ingtypes.BackOutlierOnError:    "eject",
+			},
+			expected: hatypes.ServerConfig{Observe: "layer7", ErrorLimit: 10, OnError: "mark-down"},
+			logging: `
+WARN invalid outlier-error-limit on ingress 'default/ing1': 0, using 10 instead
+WARN invalid outlier-on-error on ingress 'default/ing1': eject, using mark-down instead`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	annDefault := map[string]string{
+		ingtypes.BackOutlierErrorLimit: "10",
+		ingtypes.BackOutlierOnError:    "mark-down",
+	}
+	for i, test := range testCase {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, annDefault)
+		d.backend.ModeTCP = test.modeTCP
+		if !test.noCheck {
+			d.backend.HealthCheck.Interval = "2s"
+		}
+		c.createUpdater().buildBackendOutlier(d)
+		c.compareObjects("outlier", i, d.backend.Server, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestPodWeight(t *testing.T) {
+	buildPod := func(name, weight string) *api.Pod {
+		pod := &api.Pod{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+		}
+		if weight != "" {
+			pod.Annotations = map[string]string{"example.com/weight": weight}
+		}
+		return pod
+	}
+	pods := map[string]*api.Pod{
+		"default/pod01": buildPod("pod01", "10"),
+		"default/pod02": buildPod("pod02", "4"),
+		"default/pod03": buildPod("pod03", ""),
+		"default/pod04": buildPod("pod04", "300"),
+		"default/pod05": buildPod("pod05", "heavy"),
+	}
+	testCases := []struct {
+		ann        map[string]string
+		targets    []string
+		weights    []int
+		expWeights []int
+		logging    string
+	}{
+		// 0
+		{
+			targets:    []string{"default/pod01", "default/pod02"},
+			expWeights: []int{1, 1},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackPodWeightAnnotation: "example.com/weight",
+			},
+			targets:    []string{"default/pod01", "default/pod02", "default/pod03", ""},
+			expWeights: []int{10, 4, 1, 1},
+		},
+		// 2
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackOAuth:        "oauth2_proxy",
-					ingtypes.BackOAuthHeaders: "X-Auth-New:req.var_from_lua",
-				},
+			ann: map[string]string{
+				ingtypes.BackPodWeightAnnotation: "example.com/weight",
 			},
-			backend: "default:back:/oauth2",
-			authExp: map[string]hatypes.AuthExternal{
-				"/": {
-					AllowedPath:     "/oauth2/",
-					AuthBackendName: "default_back_8080",
-					AuthPath:        "/oauth2/auth",
-					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars:     map[string]string{"X-Auth-New": "req.var_from_lua"},
-				},
+			targets:    []string{"default/pod01", "default/pod02"},
+			weights:    []int{0, 1},
+			expWeights: []int{0, 4},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackPodWeightAnnotation: "example.com/weight",
 			},
+			targets:    []string{"default/pod04", "default/pod05", "default/pod06"},
+			expWeights: []int{1, 1, 1},
+			logging: `
+WARN ignoring invalid weight '300' on annotation 'example.com/weight' of pod 'default/pod04'
+WARN ignoring invalid weight 'heavy' on annotation 'example.com/weight' of pod 'default/pod05'
+WARN cannot read weight of endpoint '172.17.0.13:8080' on ingress 'default/ing1': pod not found: 'default/pod06'`,
 		},
-		// 6
+		// 4
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackOAuth:        "oauth2_proxy",
-					ingtypes.BackOAuthHeaders: "space before:attr",
-				},
+			ann: map[string]string{
+				ingtypes.BackPodWeightAnnotation: "example.com/weight",
+				ingtypes.BackBlueGreenBalance:    "v=1=50,v=2=50",
 			},
-			backend: "default:back:/oauth2",
-			authExp: map[string]hatypes.AuthExternal{
-				"/": {
-					AllowedPath:     "/oauth2/",
-					AuthBackendName: "default_back_8080",
-					AuthPath:        "/oauth2/auth",
-					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars:     map[string]string{},
-				},
+			targets:    []string{"default/pod01"},
+			expWeights: []int{1},
+			logging:    `WARN ignoring pod-weight-annotation on ingress 'default/ing1': blue/green balance is configured`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.PodList = pods
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		d.backend.Server.InitialWeight = 1
+		for j, target := range test.targets {
+			ep := d.backend.AcquireEndpoint(fmt.Sprintf("172.17.0.%d", 11+j), 8080, target)
+			if test.weights != nil {
+				ep.Weight = test.weights[j]
+			}
+		}
+		c.createUpdater().buildBackendPodWeight(d)
+		weights := make([]int, len(d.backend.Endpoints))
+		for j, ep := range d.backend.Endpoints {
+			weights[j] = ep.Weight
+		}
+		c.compareObjects("pod weight", i, weights, test.expWeights)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestProxyProtocol(t *testing.T) {
+	testCase := []struct {
+		ann      map[string]string
+		expected hatypes.ServerConfig
+		logging  string
+	}{
+		// 0
+		{
+			ann: map[string]string{
+				ingtypes.BackProxyProtocol: "v1",
 			},
-			logging: "WARN invalid header format 'space before:attr' on ingress 'default/ing1'",
+			expected: hatypes.ServerConfig{SendProxy: "send-proxy"},
 		},
-		// 7
+		// 1
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackOAuth:        "oauth2_proxy",
-					ingtypes.BackOAuthHeaders: "x-header:x- invalid",
-				},
+			ann: map[string]string{
+				ingtypes.BackProxyProtocol: "v3",
 			},
-			backend: "default:back:/oauth2",
-			authExp: map[string]hatypes.AuthExternal{
-				"/": {
-					AllowedPath:     "/oauth2/",
-					AuthBackendName: "default_back_8080",
-					AuthPath:        "/oauth2/auth",
-					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars:     map[string]string{},
-				},
+			expected: hatypes.ServerConfig{},
+			logging:  `WARN ignoring invalid proxy protocol version on ingress 'default/ing1': v3`,
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackProxyProtocol:          "v1",
+				ingtypes.BackProxyProtocolV2Options: "ssl",
 			},
-			logging: "WARN invalid header format 'x-header:x- invalid' on ingress 'default/ing1'",
+			expected: hatypes.ServerConfig{SendProxy: "send-proxy"},
+			logging:  `WARN ignoring proxy protocol v2 options and TLVs on ingress 'default/ing1': proxy protocol v2 is not configured`,
 		},
-		// 8
+		// 3
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackOAuth:        "oauth2_proxy",
-					ingtypes.BackOAuthHeaders: "more:colons:unsupported",
-				},
+			ann: map[string]string{
+				ingtypes.BackProxyProtocol:          "v2-ssl",
+				ingtypes.BackProxyProtocolV2Options: "ssl, unique-id,invalid",
 			},
-			backend: "default:back:/oauth2",
-			authExp: map[string]hatypes.AuthExternal{
-				"/": {
-					AllowedPath:     "/oauth2/",
-					AuthBackendName: "default_back_8080",
-					AuthPath:        "/oauth2/auth",
-					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars:     map[string]string{},
+			expected: hatypes.ServerConfig{
+				SendProxy: "send-proxy-v2-ssl",
+				ProxyV2: hatypes.ProxyV2Config{
+					Options: []string{"ssl", "unique-id"},
 				},
 			},
-			logging: "WARN invalid header format 'more:colons:unsupported' on ingress 'default/ing1'",
+			logging: `WARN ignoring invalid proxy protocol v2 option on ingress 'default/ing1': invalid`,
 		},
-		// 9
+		// 4
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackOAuth:        "oauth2_proxy",
-					ingtypes.BackOAuthHeaders: ",,X-Auth-Request-Email,,X-Auth-New:x-header-from-auth,,,X-Auth-Other:req.other",
-				},
+			ann: map[string]string{
+				ingtypes.BackProxyProtocol: "v2",
+				ingtypes.BackProxyProtocolV2TLVs: `
+0xE0 %[namespace]/%[service]
+225 %[req.hdr(host)]
+0xE2
+0x05 authority
+0xEF invalid"format
+`,
 			},
-			backend: "default:back:/oauth2",
-			authExp: map[string]hatypes.AuthExternal{
-				"/": {
-					AllowedPath:     "/oauth2/",
-					AuthBackendName: "default_back_8080",
-					AuthPath:        "/oauth2/auth",
-					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars: map[string]string{
-						"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email",
-						"X-Auth-New":           "req.auth_response_header.x_header_from_auth",
-						"X-Auth-Other":         "req.other",
+			expected: hatypes.ServerConfig{
+				SendProxy: "send-proxy-v2",
+				ProxyV2: hatypes.ProxyV2Config{
+					TLVs: []*hatypes.ProxyV2TLV{
+						{ID: 0xE0, Format: "default/app"},
+						{ID: 0xE1, Format: "%[req.hdr(host)]"},
 					},
 				},
 			},
+			logging: `
+WARN ignoring invalid proxy protocol v2 TLV on ingress 'default/ing1': 0xE2
+WARN ignoring proxy protocol v2 TLV on ingress 'default/ing1': type should be between 0xE0 and 0xEF: 0x05
+WARN ignoring proxy protocol v2 TLV on ingress 'default/ing1': invalid format: invalid"format`,
 		},
-		// 10
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCase {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		c.createUpdater().buildBackendProxyProtocol(d)
+		c.compareObjects("proxy protocol", i, d.backend.Server, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	rateLimits := map[string]*hatypes.RateLimit{
+		"api":   {Name: "api", Requests: 10, Period: "1s", Expire: "60s", DenyStatus: 429},
+		"login": {Name: "login", Header: "X-User", Requests: 5, Period: "1m", Expire: "1m", DenyStatus: 403},
+	}
+	testCases := []struct {
+		annPaths map[string]map[string]string
+		modeTCP  bool
+		expected map[string]string
+		logging  string
+	}{
+		// 0
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackOAuth: "oauth2_proxy",
-				},
-				"/app": {
-					ingtypes.BackOAuth: "oauth2_proxy",
-				},
+			annPaths: map[string]map[string]string{
+				"/": {},
 			},
-			external: true,
-			backend:  "default:back:/oauth2",
-			authExp: map[string]hatypes.AuthExternal{
-				"/":    {},
-				"/app": {},
+		},
+		// 1
+		{
+			annPaths: map[string]map[string]string{
+				"/":      {},
+				"/api":   {ingtypes.BackRateLimit: "api"},
+				"/login": {ingtypes.BackRateLimit: "login"},
+			},
+			expected: map[string]string{
+				"/api":   "api",
+				"/login": "login",
 			},
-			logging: "WARN oauth2_proxy on ingress 'default/ing1' needs Lua json module, install lua-json4 and enable 'external-has-lua' global config",
 		},
-		// 11
+		// 2
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackOAuth: "oauth2_proxy",
-				},
-				"/app": {
-					ingtypes.BackOAuth: "oauth2_proxy",
-				},
+			annPaths: map[string]map[string]string{
+				"/": {ingtypes.BackRateLimit: "web"},
 			},
-			external: true,
-			haslua:   true,
-			backend:  "default:back:/oauth2",
-			authExp: map[string]hatypes.AuthExternal{
-				"/": {
-					AllowedPath:     "/oauth2/",
-					AuthBackendName: "default_back_8080",
-					AuthPath:        "/oauth2/auth",
-					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
-				},
-				"/app": {
-					AllowedPath:     "/oauth2/",
-					AuthBackendName: "default_back_8080",
-					AuthPath:        "/oauth2/auth",
-					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
-				},
+			logging: `WARN ignoring rate limit on ingress 'default/ing1': RateLimit 'web' not found`,
+		},
+		// 3
+		{
+			annPaths: map[string]map[string]string{
+				"/": {ingtypes.BackRateLimit: "api"},
+			},
+			modeTCP: true,
+			logging: `WARN ignoring rate limit on ingress 'default/ing1': backend is using TCP mode`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", source, map[string]string{}, test.annPaths, nil)
+		d.backend.ModeTCP = test.modeTCP
+		u := c.createUpdater()
+		u.rateLimits = rateLimits
+		u.buildBackendRateLimit(d)
+		actual := map[string]string{}
+		for _, path := range d.backend.Paths {
+			if path.RateLimit.Name != "" {
+				actual[path.Path()] = path.RateLimit.Name
+			}
+		}
+		if test.expected == nil {
+			test.expected = map[string]string{}
+		}
+		c.compareObjects("rate limit", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestRetry(t *testing.T) {
+	testCase := []struct {
+		ann        map[string]string
+		annDefault map[string]string
+		expected   hatypes.BackendRetry
+		logging    string
+	}{
+		// 0
+		{
+			expected: hatypes.BackendRetry{},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackRetries: "2",
+			},
+			expected: hatypes.BackendRetry{Retries: "2"},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackRetries: "0",
+			},
+			expected: hatypes.BackendRetry{Retries: "0"},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackRetries: "-1",
+			},
+			expected: hatypes.BackendRetry{},
+			logging:  `WARN ignoring invalid number of retries on ingress 'default/ing1': -1`,
+		},
+		// 4
+		{
+			annDefault: map[string]string{
+				ingtypes.BackRetries: "5",
+			},
+			expected: hatypes.BackendRetry{Retries: "5"},
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackRetryOn: "conn-failure, Empty-Response,503",
+			},
+			expected: hatypes.BackendRetry{RetryOn: []string{"conn-failure", "empty-response", "503"}},
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackRetryOn: "conn-failure,200,502",
+			},
+			expected: hatypes.BackendRetry{RetryOn: []string{"conn-failure", "502"}},
+			logging:  `WARN ignoring invalid retry-on condition on ingress 'default/ing1': 200`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackRetryOn: "none,502",
+			},
+			expected: hatypes.BackendRetry{},
+			logging:  `WARN ignoring retry-on on ingress 'default/ing1': 'none' cannot be used with other conditions`,
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.BackRetryOn: "none",
 			},
+			expected: hatypes.BackendRetry{RetryOn: []string{"none"}},
 		},
-		// 12
+		// 9
 		{
-			ann: map[string]map[string]string{
-				"/": {},
-				"/app": {
-					ingtypes.BackOAuth: "oauth2_proxy",
-				},
+			ann: map[string]string{
+				ingtypes.BackRetryRedispatch: "false",
 			},
-			backend: "default:back:/oauth2",
-			authExp: map[string]hatypes.AuthExternal{
-				"/": {},
-				"/app": {
-					AllowedPath:     "/oauth2/",
-					AuthBackendName: "default_back_8080",
-					AuthPath:        "/oauth2/auth",
-					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
-				},
+			expected: hatypes.BackendRetry{Redispatch: "false"},
+		},
+		// 10
+		{
+			ann: map[string]string{
+				ingtypes.BackRetryRedispatch: "True",
+			},
+			expected: hatypes.BackendRetry{Redispatch: "true"},
+		},
+		// 11
+		{
+			ann: map[string]string{
+				ingtypes.BackRetryRedispatch: "-2",
+			},
+			expected: hatypes.BackendRetry{Redispatch: "true", RedispatchInterval: -2},
+		},
+		// 12
+		{
+			ann: map[string]string{
+				ingtypes.BackRetryRedispatch: "0",
 			},
+			expected: hatypes.BackendRetry{},
+			logging:  `WARN ignoring invalid retry-redispatch on ingress 'default/ing1': 0`,
 		},
 		// 13
 		{
-			ann: map[string]map[string]string{
-				"/": {},
-				"/app": {
-					ingtypes.BackAuthURL: "http://10.0.0.2:8000",
-					ingtypes.BackOAuth:   "oauth2_proxy",
-				},
+			ann: map[string]string{
+				ingtypes.BackRetries:         "3",
+				ingtypes.BackRetryOn:         "all-retryable-errors",
+				ingtypes.BackRetryRedispatch: "1",
 			},
-			authExp: map[string]hatypes.AuthExternal{
-				"/":    {},
-				"/app": {},
+			expected: hatypes.BackendRetry{
+				Redispatch:         "true",
+				RedispatchInterval: 1,
+				Retries:            "3",
+				RetryOn:            []string{"all-retryable-errors"},
 			},
-			logging: `WARN ignoring oauth configuration on ingress 'default/ing1': auth-url was configured and has precedence`,
 		},
 	}
-
-	source := &Source{
-		Namespace: "default",
-		Name:      "ing1",
-		Type:      "ingress",
-	}
-	annDefault := map[string]string{ingtypes.BackOAuthHeaders: "X-Auth-Request-Email"}
-	for i, test := range testCases {
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCase {
 		c := setup(t)
-		d := c.createBackendMappingData("default/app", source, annDefault, test.ann, []string{})
-		if test.external {
-			c.haproxy.Global().External.MasterSocket = "/tmp/master.sock"
-		}
-		c.haproxy.Global().External.HasLua = test.haslua
-		if test.backend != "" {
-			b := strings.Split(test.backend, ":")
-			backend := c.haproxy.Backends().AcquireBackend(b[0], b[1], "8080")
-			c.haproxy.Hosts().AcquireHost("app.local").AddPath(backend, b[2], hatypes.MatchBegin)
-		}
-		c.createUpdater().buildBackendOAuth(d)
-		actual := map[string]hatypes.AuthExternal{}
-		for _, path := range d.backend.Paths {
-			actual[path.Path()] = path.AuthExternal
-		}
-		for k, v := range test.authExp {
-			if v.AuthBackendName != "" {
-				v.HeadersRequest = []string{".*"}
-				v.HeadersSucceed = []string{"-"}
-				v.HeadersFail = []string{"-"}
-				v.Method = "HEAD"
-				test.authExp[k] = v
-			}
-		}
-		c.compareObjects("oauth", i, actual, test.authExp)
+		d := c.createBackendData("default/app", source, test.ann, test.annDefault)
+		c.createUpdater().buildBackendRetry(d)
+		c.compareObjects("retry", i, d.backend.Retry, test.expected)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
@@ -1842,6 +4387,8 @@ func TestOAuth(t *testing.T) {
 func TestRewriteURL(t *testing.T) {
 	testCases := []struct {
 		source   Source
+		path     string
+		match    hatypes.MatchType
 		input    string
 		expected string
 		logging  string
@@ -1867,6 +4414,44 @@ func TestRewriteURL(t *testing.T) {
 			input:    `/app`,
 			expected: `/app`,
 		},
+		// 3
+		{
+			source: Source{
+				Namespace: "default",
+				Name:      "app1",
+				Type:      "ingress",
+			},
+			input:    `/app/$1`,
+			expected: ``,
+			logging:  `WARN ignoring rewrite-target with capture group references on ingress 'default/app1': path '/' should use regex path type`,
+		},
+		// 4
+		{
+			path:     `/api/v([0-9]+)/(.*)`,
+			match:    hatypes.MatchRegex,
+			input:    `/v$1/$2`,
+			expected: `/v\1/\2`,
+		},
+		// 5
+		{
+			source: Source{
+				Namespace: "default",
+				Name:      "app1",
+				Type:      "ingress",
+			},
+			path:     `/api/(.*)`,
+			match:    hatypes.MatchRegex,
+			input:    `/$1/$2`,
+			expected: ``,
+			logging:  `WARN ignoring rewrite-target on ingress 'default/app1': capture group $2 not found in path '/api/(.*)'`,
+		},
+		// 6
+		{
+			path:     `/api/.*`,
+			match:    hatypes.MatchRegex,
+			input:    `/app`,
+			expected: `/app`,
+		},
 	}
 
 	for i, test := range testCases {
@@ -1875,9 +4460,13 @@ func TestRewriteURL(t *testing.T) {
 		if test.input != "" {
 			ann = map[string]string{ingtypes.BackRewriteTarget: test.input}
 		}
+		if test.path == "" {
+			test.path = "/"
+			test.match = hatypes.MatchBegin
+		}
 		d := c.createBackendData("default/app", &test.source, map[string]string{}, map[string]string{})
-		d.backend.AddBackendPath(hatypes.CreatePathLink("d1.local", "/", hatypes.MatchBegin))
-		d.mapper.AddAnnotations(&test.source, hatypes.CreatePathLink("d1.local", "/", hatypes.MatchBegin), ann)
+		d.backend.AddBackendPath(hatypes.CreatePathLink("d1.local", test.path, test.match))
+		d.mapper.AddAnnotations(&test.source, hatypes.CreatePathLink("d1.local", test.path, test.match), ann)
 		c.createUpdater().buildBackendRewriteURL(d)
 		actual := d.backend.Paths[0].RewriteURL
 		c.compareObjects("rewrite", i, actual, test.expected)
@@ -1886,6 +4475,267 @@ func TestRewriteURL(t *testing.T) {
 	}
 }
 
+func TestRoutes(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		modeTCP  bool
+		expected []*hatypes.BackendRoute
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackQueryRoutes: `
+# api versions
+version=beta,preview app-beta:http
+version~^v2 app-v2:8080
+`,
+			},
+			expected: []*hatypes.BackendRoute{
+				{Type: hatypes.RouteMatchQuery, Name: "version", Values: []string{"beta", "preview"}, Backend: "default_app-beta_8080"},
+				{Type: hatypes.RouteMatchQuery, Name: "version", Regex: true, Values: []string{"^v2"}, Backend: "default_app-v2_8080"},
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackQueryRoutes: `
+version beta app-beta:8080
+version=beta app-beta
+version=beta app:8080
+version=beta app-none:8080
+version=beta app-nobackend:8080
+version=beta app-beta:9000
+version=beta app-v2:8080
+`,
+			},
+			expected: []*hatypes.BackendRoute{
+				{Type: hatypes.RouteMatchQuery, Name: "version", Values: []string{"beta"}, Backend: "default_app-v2_8080"},
+			},
+			logging: `
+WARN ignoring query-routes on ingress 'default/ing1': invalid route syntax, expected '<name>=<value> <service>:<port>': version beta app-beta:8080
+WARN ignoring query-routes on ingress 'default/ing1': invalid service name or missing port, expected '<service>:<port>': app-beta
+WARN ignoring query-routes on ingress 'default/ing1': route and backend use the same service: app
+WARN ignoring query-routes on ingress 'default/ing1': backend of service 'app-nobackend' was not found
+`,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackHeaderRoutes: `
+X-Tenant=acme app-v2:http
+X-Env~^preview- app-beta:http
+`,
+				ingtypes.BackQueryRoutes: "version=beta app-beta:http",
+			},
+			expected: []*hatypes.BackendRoute{
+				{Type: hatypes.RouteMatchHeader, Name: "X-Tenant", Values: []string{"acme"}, Backend: "default_app-v2_8080"},
+				{Type: hatypes.RouteMatchHeader, Name: "X-Env", Regex: true, Values: []string{"^preview-"}, Backend: "default_app-beta_8080"},
+				{Type: hatypes.RouteMatchQuery, Name: "version", Values: []string{"beta"}, Backend: "default_app-beta_8080"},
+			},
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackHeaderRoutes: "X-Tenant=acme app-v2:http",
+			},
+			modeTCP: true,
+			logging: `WARN ignoring header-routes on ingress 'default/ing1': cannot be used on a TCP backend`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackQueryRoutes: "version=beta app-beta:http",
+			},
+			modeTCP: true,
+			logging: `WARN ignoring query-routes on ingress 'default/ing1': cannot be used on a TCP backend`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackMethodRoutes: `
+post,PUT,DELETE app-v2:http
+version=beta app-beta:http
+GET,M-SEARCH app-beta:http
+`,
+				ingtypes.BackQueryRoutes: "version=beta app-beta:http",
+			},
+			expected: []*hatypes.BackendRoute{
+				{Type: hatypes.RouteMatchQuery, Name: "version", Values: []string{"beta"}, Backend: "default_app-beta_8080"},
+				{Type: hatypes.RouteMatchMethod, Values: []string{"POST", "PUT", "DELETE"}, Backend: "default_app-v2_8080"},
+			},
+			logging: `
+WARN ignoring method-routes on ingress 'default/ing1': invalid HTTP method: version=beta
+WARN ignoring method-routes on ingress 'default/ing1': invalid HTTP method: M-SEARCH
+`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		for _, svcName := range []string{"default/app", "default/app-beta", "default/app-v2", "default/app-nobackend"} {
+			svc, _ := conv_helper.CreateService(svcName, "http:8080", "")
+			c.cache.SvcList = append(c.cache.SvcList, svc)
+		}
+		c.haproxy.Backends().AcquireBackend("default", "app", "8080")
+		c.haproxy.Backends().AcquireBackend("default", "app-beta", "8080")
+		c.haproxy.Backends().AcquireBackend("default", "app-v2", "8080")
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		d.backend.ModeTCP = test.modeTCP
+		c.createUpdater().buildBackendRoutes(d)
+		c.compareObjects("routes", i, d.backend.Routes, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	hstsDefault := hatypes.HSTS{Enabled: true, MaxAge: 15768000}
+	secDefault := hatypes.SecurityHeaders{
+		ContentTypeOptions: "nosniff",
+		FrameOptions:       "DENY",
+		ReferrerPolicy:     "strict-origin-when-cross-origin",
+	}
+	hstsBundle := hatypes.HSTS{Enabled: true, MaxAge: 31536000}
+	testCases := []struct {
+		global      string
+		annPaths    map[string]map[string]string
+		modeTCP     bool
+		expSecurity map[string]hatypes.SecurityHeaders
+		expHSTS     map[string]hatypes.HSTS
+		logging     string
+	}{
+		// 0
+		{
+			expSecurity: map[string]hatypes.SecurityHeaders{"/": {}, "/app": {}},
+			expHSTS:     map[string]hatypes.HSTS{"/": hstsDefault, "/app": hstsDefault},
+		},
+		// 1
+		{
+			annPaths: map[string]map[string]string{
+				"/app": {ingtypes.BackSecurityHeaders: "enabled: true"},
+			},
+			expSecurity: map[string]hatypes.SecurityHeaders{"/": {}, "/app": secDefault},
+			expHSTS:     map[string]hatypes.HSTS{"/": hstsDefault, "/app": hstsBundle},
+		},
+		// 2
+		{
+			global: `
+x-frame-options: sameorigin
+content-security-policy: default-src 'self'
+hsts-include-subdomains: true
+`,
+			annPaths: map[string]map[string]string{
+				"/app": {ingtypes.BackSecurityHeaders: `
+# app specific
+referrer-policy no-referrer
+content-security-policy: off
+hsts-max-age: 600
+`},
+			},
+			expSecurity: map[string]hatypes.SecurityHeaders{
+				"/": {
+					ContentSecurityPolicy: `'default-src '\''self'\'''`,
+					ContentTypeOptions:    "nosniff",
+					FrameOptions:          "SAMEORIGIN",
+					ReferrerPolicy:        "strict-origin-when-cross-origin",
+				},
+				"/app": {
+					ContentTypeOptions: "nosniff",
+					FrameOptions:       "SAMEORIGIN",
+					ReferrerPolicy:     "no-referrer",
+				},
+			},
+			expHSTS: map[string]hatypes.HSTS{
+				"/":    {Enabled: true, MaxAge: 31536000, Subdomains: true},
+				"/app": {Enabled: true, MaxAge: 600, Subdomains: true},
+			},
+		},
+		// 3
+		{
+			global: "x-content-type-options: off",
+			annPaths: map[string]map[string]string{
+				"/app": {ingtypes.BackSecurityHeaders: "enabled: false"},
+			},
+			expSecurity: map[string]hatypes.SecurityHeaders{
+				"/":    {FrameOptions: "DENY", ReferrerPolicy: "strict-origin-when-cross-origin"},
+				"/app": {},
+			},
+			expHSTS: map[string]hatypes.HSTS{"/": hstsBundle, "/app": hstsDefault},
+		},
+		// 4
+		{
+			annPaths: map[string]map[string]string{
+				"/": {ingtypes.BackSecurityHeaders: `
+x-frame-options: allow-from example.com
+referrer-policy: everywhere
+x-content-type-options: sniff
+content-security-policy: default-src "self"
+hsts: maybe
+hsts-max-age: -1
+x-xss-protection: 1
+missing-value
+`},
+			},
+			expSecurity: map[string]hatypes.SecurityHeaders{"/": secDefault, "/app": {}},
+			expHSTS:     map[string]hatypes.HSTS{"/": hstsBundle, "/app": hstsDefault},
+			logging: `
+WARN ignoring security header option 'x-frame-options' on ingress 'default/ing1': invalid value: allow-from example.com
+WARN ignoring security header option 'referrer-policy' on ingress 'default/ing1': invalid value: everywhere
+WARN ignoring security header option 'x-content-type-options' on ingress 'default/ing1': invalid value: sniff
+WARN ignoring security header option 'content-security-policy' on ingress 'default/ing1': invalid value: default-src "self"
+WARN ignoring security header option 'hsts' on ingress 'default/ing1': invalid value: maybe
+WARN ignoring security header option 'hsts-max-age' on ingress 'default/ing1': invalid value: -1
+WARN ignoring unsupported security header option on ingress 'default/ing1': x-xss-protection
+WARN ignoring security header option on ingress 'default/ing1': missing option name or value: missing-value`,
+		},
+		// 5
+		{
+			global:      "x-frame-options: none",
+			expSecurity: map[string]hatypes.SecurityHeaders{"/": secDefault, "/app": secDefault},
+			expHSTS:     map[string]hatypes.HSTS{"/": hstsBundle, "/app": hstsBundle},
+			logging:     `WARN ignoring security header option 'x-frame-options' on global config: invalid value: none`,
+		},
+		// 6
+		{
+			annPaths: map[string]map[string]string{
+				"/": {ingtypes.BackSecurityHeaders: "enabled: true"},
+			},
+			modeTCP:     true,
+			expSecurity: map[string]hatypes.SecurityHeaders{"/": {}, "/app": {}},
+			expHSTS:     map[string]hatypes.HSTS{"/": hstsDefault, "/app": hstsDefault},
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		annDefault := map[string]string{
+			ingtypes.BackHSTS:       "true",
+			ingtypes.BackHSTSMaxAge: "15768000",
+		}
+		if test.global != "" {
+			annDefault[ingtypes.BackSecurityHeaders] = test.global
+		}
+		d := c.createBackendMappingData("default/app", source, annDefault, test.annPaths, []string{"/", "/app"})
+		d.backend.ModeTCP = test.modeTCP
+		u := c.createUpdater()
+		u.buildBackendHSTS(d)
+		u.buildBackendSecurityHeaders(d)
+		actualSecurity := map[string]hatypes.SecurityHeaders{}
+		actualHSTS := map[string]hatypes.HSTS{}
+		for _, path := range d.backend.Paths {
+			actualSecurity[path.Path()] = path.SecHeaders
+			actualHSTS[path.Path()] = path.HSTS
+		}
+		c.compareObjects("security headers", i, actualSecurity, test.expSecurity)
+		c.compareObjects("hsts", i, actualHSTS, test.expHSTS)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestBackendServerNaming(t *testing.T) {
 	testCases := []struct {
 		source  Source
@@ -1910,19 +4760,113 @@ func TestBackendServerNaming(t *testing.T) {
 			naming:  "sequences",
 			logging: "WARN ignoring invalid naming type 'sequences' on ingress 'default/ing1', using 'seq' instead",
 		},
-		// 3
+		// 3
+		{
+			naming: "pod",
+		},
+		// 4
+		{
+			naming: "ip",
+		},
+	}
+	for _, test := range testCases {
+		c := setup(t)
+		d := c.createBackendData("default/app", &test.source, map[string]string{ingtypes.BackBackendServerNaming: test.naming}, map[string]string{})
+		c.createUpdater().buildBackendServerNaming(d)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestServiceImportWeight(t *testing.T) {
+	testCases := []struct {
+		svcName    string
+		weight     string
+		clusters   []string
+		weights    []int
+		expWeights []int
+		logging    string
+	}{
+		// 0
+		{
+			clusters:   []string{"cluster-a", "cluster-a", "cluster-b"},
+			expWeights: []int{1, 1, 1},
+		},
+		// 1
+		{
+			weight:     "cluster-a=1,cluster-b=1",
+			clusters:   []string{"cluster-a", "cluster-a", "cluster-b"},
+			expWeights: []int{1, 1, 2},
+		},
+		// 2
+		{
+			weight:     "cluster-a=2, cluster-b=1",
+			clusters:   []string{"cluster-a", "cluster-a", "cluster-b"},
+			expWeights: []int{1, 1, 1},
+		},
+		// 3
+		{
+			weight:     "cluster-a=1",
+			clusters:   []string{"cluster-a", "cluster-b"},
+			expWeights: []int{1, 0},
+		},
+		// 4
+		{
+			weight:     "cluster-a=1,cluster-b=3",
+			clusters:   []string{"cluster-a", "cluster-b", "cluster-b"},
+			weights:    []int{1, 0, 1},
+			expWeights: []int{1, 0, 3},
+		},
+		// 5
+		{
+			weight:     "cluster-a=1,cluster-b,cluster-c=x,cluster-d=1",
+			clusters:   []string{"cluster-a", "cluster-b", "cluster-d"},
+			expWeights: []int{1, 0, 1},
+			logging: `
+WARN ignoring invalid cluster weight on ingress 'default/ing1': cluster-b
+WARN ignoring invalid cluster weight on ingress 'default/ing1': cluster-c=x`,
+		},
+		// 6
 		{
-			naming: "pod",
+			weight:     "cluster-a=300,cluster-b=-1",
+			clusters:   []string{"cluster-a", "cluster-b"},
+			expWeights: []int{1, 0},
+			logging: `
+WARN invalid weight '300' on ingress 'default/ing1', using '256' instead
+WARN invalid weight '-1' on ingress 'default/ing1', using '0' instead`,
 		},
-		// 4
+		// 7
 		{
-			naming: "ip",
+			svcName:    "default/app",
+			weight:     "cluster-a=1",
+			clusters:   []string{"", ""},
+			expWeights: []int{1, 1},
+			logging:    `WARN ignoring cluster weight on ingress 'default/ing1': backend is not a ServiceImport`,
 		},
 	}
-	for _, test := range testCases {
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
 		c := setup(t)
-		d := c.createBackendData("default/app", &test.source, map[string]string{ingtypes.BackBackendServerNaming: test.naming}, map[string]string{})
-		c.createUpdater().buildBackendServerNaming(d)
+		svcName := test.svcName
+		if svcName == "" {
+			svcName = "default/app.clusterset"
+		}
+		ann := map[string]string{ingtypes.BackServiceImportWeight: test.weight}
+		d := c.createBackendData(svcName, source, ann, map[string]string{ingtypes.BackInitialWeight: "1"})
+		d.backend.Server.InitialWeight = 1
+		for j, cluster := range test.clusters {
+			ep := d.backend.AcquireEndpoint(fmt.Sprintf("172.17.0.%d", 11+j), 8080, "")
+			ep.Cluster = cluster
+			if test.weights != nil {
+				ep.Weight = test.weights[j]
+			}
+		}
+		c.createUpdater().buildBackendServiceImportWeight(d)
+		weights := make([]int, len(d.backend.Endpoints))
+		for j, ep := range d.backend.Endpoints {
+			weights[j] = ep.Weight
+		}
+		c.compareObjects("cluster weight", i, weights, test.expWeights)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
@@ -2267,6 +5211,191 @@ func (a addr) String() string {
 	return a.ip
 }
 
+func TestFastCGI(t *testing.T) {
+	testCase := []struct {
+		ann         map[string]string
+		expProtocol string
+		expFastCGI  hatypes.FastCGIApp
+		logging     string
+	}{
+		// 0
+		{
+			ann: map[string]string{
+				ingtypes.BackBackendProtocol: "fcgi",
+			},
+			expProtocol: "",
+			logging:     `WARN ignoring fcgi protocol on ingress 'default/ing1': missing fcgi-docroot`,
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackBackendProtocol: "fcgi",
+				ingtypes.BackFastCGIDocroot:  "var/www",
+			},
+			expProtocol: "",
+			logging:     `WARN ignoring fcgi protocol on ingress 'default/ing1': invalid fcgi-docroot: var/www`,
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackBackendProtocol: "FCGI",
+				ingtypes.BackFastCGIDocroot:  "/var/www/html",
+			},
+			expProtocol: "fcgi",
+			expFastCGI:  hatypes.FastCGIApp{Docroot: "/var/www/html"},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackBackendProtocol: "fcgi",
+				ingtypes.BackFastCGIDocroot:  "/var/www/html",
+				ingtypes.BackFastCGIIndex:    "index.php",
+				ingtypes.BackFastCGIParams: `
+SCRIPT_FILENAME /var/www/html/index.php
+HTTP_PROXY
+REDIRECT_STATUS 200
+`,
+			},
+			expProtocol: "fcgi",
+			expFastCGI: hatypes.FastCGIApp{
+				Docroot: "/var/www/html",
+				Index:   "index.php",
+				Params: []*hatypes.FastCGIParam{
+					{Name: "SCRIPT_FILENAME", Value: "/var/www/html/index.php"},
+					{Name: "REDIRECT_STATUS", Value: "200"},
+				},
+			},
+			logging: `WARN ignoring invalid fcgi param on ingress 'default/ing1': HTTP_PROXY`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackBackendProtocol: "fcgi",
+				ingtypes.BackFastCGIDocroot:  "/var/www/html",
+				ingtypes.BackFastCGIIndex:    "index.php\"",
+			},
+			expProtocol: "fcgi",
+			expFastCGI:  hatypes.FastCGIApp{Docroot: "/var/www/html"},
+			logging:     `WARN ignoring invalid fcgi-index on ingress 'default/ing1': index.php"`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCase {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		c.createUpdater().buildBackendProtocol(d)
+		c.compareObjects("protocol", i, d.backend.Server.Protocol, test.expProtocol)
+		c.compareObjects("fcgi", i, d.backend.FastCGI, test.expFastCGI)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestGRPCWeb(t *testing.T) {
+	testCase := []struct {
+		ann      map[string]string
+		modeTCP  bool
+		expected bool
+		logging  string
+	}{
+		// 0
+		{
+			ann: map[string]string{
+				ingtypes.BackBackendProtocol: "grpc",
+			},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackGRPCWeb: "true",
+			},
+			logging: `WARN ignoring gRPC-Web on ingress 'default/ing1': backend protocol should be h2 or h2-ssl`,
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackBackendProtocol: "grpc",
+				ingtypes.BackGRPCWeb:         "true",
+			},
+			modeTCP: true,
+			logging: `WARN ignoring gRPC-Web on ingress 'default/ing1': backend is in tcp mode`,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackBackendProtocol: "grpc",
+				ingtypes.BackGRPCWeb:         "true",
+			},
+			expected: true,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackBackendProtocol: "h2-ssl",
+				ingtypes.BackGRPCWeb:         "true",
+			},
+			expected: true,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCase {
+		c := setup(t)
+		c.haproxy.Global().UseHTX = true
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		d.backend.ModeTCP = test.modeTCP
+		u := c.createUpdater()
+		u.buildBackendProtocol(d)
+		u.buildBackendGRPCWeb(d)
+		c.compareObjects("grpc-web", i, d.backend.GRPCWeb, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestSlowStart(t *testing.T) {
+	testCase := []struct {
+		ann      map[string]string
+		expected string
+		logging  string
+	}{
+		// 0
+		{
+			expected: "",
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackSlowStart: "30s",
+			},
+			expected: "30s",
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackSlowStart: "1m",
+			},
+			expected: "1m",
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackSlowStart: "fast",
+			},
+			expected: "",
+			logging:  `WARN ignoring invalid time format on ingress 'default/ing1': fast`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCase {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		c.createUpdater().buildBackendSlowStart(d)
+		c.compareObjects("slow start", i, d.backend.Server.SlowStart, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestSourceAddrIntf(t *testing.T) {
 	ip2 := addr{"192.168.0.2/24"}
 	ip3 := addr{"192.168.0.3/24"}
@@ -2436,6 +5565,7 @@ func TestTimeout(t *testing.T) {
 		paths      []string
 		source     Source
 		expected   hatypes.BackendTimeoutConfig
+		expPaths   map[string]hatypes.PathTimeoutConfig
 		logging    string
 	}{
 		// 0
@@ -2468,12 +5598,262 @@ func TestTimeout(t *testing.T) {
 			// use only if declared as svc/ing annotation, otherwise defaults to HAProxy's defaults section
 			expected: hatypes.BackendTimeoutConfig{},
 		},
+		// 3
+		{
+			annDefault: map[string]string{
+				"timeout-server": "50s",
+				"timeout-tunnel": "1h",
+			},
+			ann: map[string]map[string]string{
+				"/": {
+					"timeout-server": "10s",
+				},
+				"/app": {
+					"timeout-server": "10s",
+				},
+			},
+			expected: hatypes.BackendTimeoutConfig{
+				Server: "10s",
+			},
+		},
+		// 4
+		{
+			annDefault: map[string]string{
+				"timeout-server": "50s",
+				"timeout-tunnel": "1h",
+			},
+			ann: map[string]map[string]string{
+				"/": {
+					"timeout-server": "10s",
+				},
+				"/upload": {
+					"timeout-server": "5m",
+					"timeout-tunnel": "2h",
+				},
+			},
+			paths: []string{"/api"},
+			expected: hatypes.BackendTimeoutConfig{
+				Server: "10s",
+				Tunnel: "2h",
+			},
+			expPaths: map[string]hatypes.PathTimeoutConfig{
+				"/":       {Tunnel: "1h"},
+				"/api":    {Server: "50s", Tunnel: "1h"},
+				"/upload": {Server: "5m"},
+			},
+		},
 	}
 	for i, test := range testCase {
 		c := setup(t)
 		d := c.createBackendMappingData("default/app", &test.source, test.annDefault, test.ann, test.paths)
 		c.createUpdater().buildBackendTimeout(d)
+		actualPaths := map[string]hatypes.PathTimeoutConfig{}
+		for _, path := range d.backend.Paths {
+			if path.Timeout != (hatypes.PathTimeoutConfig{}) {
+				actualPaths[path.Path()] = path.Timeout
+			}
+		}
+		if test.expPaths == nil {
+			test.expPaths = map[string]hatypes.PathTimeoutConfig{}
+		}
 		c.compareObjects("backend timeout", i, d.backend.Timeout, test.expected)
+		c.compareObjects("path timeout", i, actualPaths, test.expPaths)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestTopology(t *testing.T) {
+	buildPod := func(namespace, name, nodeName string) *api.Pod {
+		return &api.Pod{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: api.PodSpec{
+				NodeName: nodeName,
+			},
+		}
+	}
+	buildNode := func(name, zone string) *api.Node {
+		node := &api.Node{
+			ObjectMeta: meta.ObjectMeta{
+				Name: name,
+			},
+		}
+		if zone != "" {
+			node.Labels = map[string]string{"topology.kubernetes.io/zone": zone}
+		}
+		return node
+	}
+	pods := map[string]*api.Pod{
+		"ingress-controller/ingress-controller-0": buildPod("ingress-controller", "ingress-controller-0", "node1"),
+		"default/pod01": buildPod("default", "pod01", "node1"),
+		"default/pod02": buildPod("default", "pod02", "node2"),
+		"default/pod03": buildPod("default", "pod03", "node3"),
+		"default/pod04": buildPod("default", "pod04", ""),
+	}
+	nodes := map[string]*api.Node{
+		"node1": buildNode("node1", "zone-a"),
+		"node2": buildNode("node2", "zone-b"),
+		"node3": buildNode("node3", ""),
+	}
+	testCases := []struct {
+		ann           map[string]string
+		pods          map[string]*api.Pod
+		targets       []string
+		zones         []string
+		hints         []string
+		expWeights    []int
+		expBackups    []bool
+		expAllBackups bool
+		logging       string
+	}{
+		// 0
+		{
+			targets:    []string{"default/pod01", "default/pod02"},
+			expWeights: []int{100, 100},
+			expBackups: []bool{false, false},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackTopologyAwareRouting: "weight",
+			},
+			targets:    []string{"default/pod01", "default/pod02", "default/pod03", ""},
+			expWeights: []int{100, 10, 10, 100},
+			expBackups: []bool{false, false, false, false},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackTopologyAwareRouting: "weight",
+				ingtypes.BackTopologyRemoteWeight: "50",
+			},
+			targets:    []string{"default/pod01", "default/pod02"},
+			expWeights: []int{100, 50},
+			expBackups: []bool{false, false},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackTopologyAwareRouting: "backup",
+			},
+			targets:       []string{"default/pod01", "default/pod02", "default/pod03"},
+			expWeights:    []int{100, 100, 100},
+			expBackups:    []bool{false, true, true},
+			expAllBackups: true,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackTopologyAwareRouting: "backup",
+			},
+			targets:    []string{"default/pod02", "default/pod03"},
+			expWeights: []int{100, 100},
+			expBackups: []bool{false, false},
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackTopologyAwareRouting: "zone",
+			},
+			targets:    []string{"default/pod01", "default/pod02"},
+			expWeights: []int{100, 100},
+			expBackups: []bool{false, false},
+			logging:    `WARN ignoring invalid topology aware routing mode on ingress 'default/ing1': zone`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackTopologyAwareRouting: "weight",
+				ingtypes.BackTopologyRemoteWeight: "0",
+			},
+			targets:    []string{"default/pod01", "default/pod02"},
+			expWeights: []int{100, 100},
+			expBackups: []bool{false, false},
+			logging:    `WARN ignoring invalid topology remote weight on ingress 'default/ing1': 0`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackTopologyAwareRouting: "backup",
+			},
+			targets:    []string{"default/pod01", "default/pod04", "default/pod05"},
+			expWeights: []int{100, 100, 100},
+			expBackups: []bool{false, false, false},
+			logging: `
+WARN cannot read zone of endpoint '172.17.0.12:8080' on ingress 'default/ing1': pod 'default/pod04' is not scheduled
+WARN cannot read zone of endpoint '172.17.0.13:8080' on ingress 'default/ing1': pod not found: 'default/pod05'`,
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.BackTopologyAwareRouting: "backup",
+			},
+			pods: map[string]*api.Pod{
+				"default/pod01": pods["default/pod01"],
+			},
+			targets:    []string{"default/pod01"},
+			expWeights: []int{100},
+			expBackups: []bool{false},
+			logging:    `WARN ignoring topology aware routing on ingress 'default/ing1': cannot read the zone of the controller: pod not found: 'ingress-controller/ingress-controller-0'`,
+		},
+		// 9
+		{
+			ann: map[string]string{
+				ingtypes.BackTopologyAwareRouting: "backup",
+			},
+			targets:       []string{"default/pod05", "default/pod06"},
+			zones:         []string{"zone-a", "zone-b"},
+			expWeights:    []int{100, 100},
+			expBackups:    []bool{false, true},
+			expAllBackups: true,
+		},
+		// 10
+		{
+			ann: map[string]string{
+				ingtypes.BackTopologyAwareRouting: "backup",
+			},
+			targets:       []string{"default/pod01", "default/pod02", "default/pod03"},
+			hints:         []string{"zone-b", "zone-a,zone-b", ""},
+			expWeights:    []int{100, 100, 100},
+			expBackups:    []bool{true, false, true},
+			expAllBackups: true,
+		},
+	}
+	annDefault := map[string]string{
+		ingtypes.BackTopologyRemoteWeight: "10",
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.PodList = pods
+		if test.pods != nil {
+			c.cache.PodList = test.pods
+		}
+		c.cache.NodeList = nodes
+		d := c.createBackendData("default/app", source, test.ann, annDefault)
+		d.backend.Server.InitialWeight = 100
+		for j, target := range test.targets {
+			ep := d.backend.AcquireEndpoint(fmt.Sprintf("172.17.0.%d", 11+j), 8080, target)
+			if j < len(test.zones) {
+				ep.Zone = test.zones[j]
+			}
+			if j < len(test.hints) {
+				ep.ForZones = test.hints[j]
+			}
+		}
+		c.createUpdater().buildBackendTopology(d)
+		weights := make([]int, len(d.backend.Endpoints))
+		backups := make([]bool, len(d.backend.Endpoints))
+		for j, ep := range d.backend.Endpoints {
+			weights[j] = ep.Weight
+			backups[j] = ep.Backup
+		}
+		c.compareObjects("topology weights", i, weights, test.expWeights)
+		c.compareObjects("topology backups", i, backups, test.expBackups)
+		c.compareObjects("topology allbackups", i, d.backend.AllBackups, test.expAllBackups)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
@@ -2481,10 +5861,12 @@ func TestTimeout(t *testing.T) {
 
 func TestWAF(t *testing.T) {
 	testCase := []struct {
-		waf      string
-		wafmode  string
-		expected hatypes.WAF
-		logging  string
+		waf        string
+		wafmode    string
+		ruleset    string
+		expected   hatypes.WAF
+		expRuleset string
+		logging    string
 	}{
 		// 0
 		{},
@@ -2535,6 +5917,46 @@ func TestWAF(t *testing.T) {
 			},
 			logging: "",
 		},
+		// 6
+		{
+			waf:     "coraza",
+			wafmode: "detect",
+			expected: hatypes.WAF{
+				Module: "coraza",
+				Mode:   "detect",
+			},
+			expRuleset: "default",
+		},
+		// 7
+		{
+			waf:     "coraza",
+			ruleset: "crs-strict",
+			expected: hatypes.WAF{
+				Module: "coraza",
+				Mode:   "deny",
+			},
+			expRuleset: "crs-strict",
+		},
+		// 8
+		{
+			waf:     "coraza",
+			ruleset: "crs strict",
+			expected: hatypes.WAF{
+				Module: "coraza",
+				Mode:   "deny",
+			},
+			expRuleset: "default",
+			logging:    "WARN ignoring invalid WAF ruleset on ingress 'default/ing1': crs strict",
+		},
+		// 9
+		{
+			waf:     "modsecurity",
+			ruleset: "crs-strict",
+			expected: hatypes.WAF{
+				Module: "modsecurity",
+				Mode:   "deny",
+			},
+		},
 	}
 	source := &Source{
 		Namespace: "default",
@@ -2553,10 +5975,18 @@ func TestWAF(t *testing.T) {
 		if test.wafmode != "" {
 			ann["/"][ingtypes.BackWAFMode] = test.wafmode
 		}
-		d := c.createBackendMappingData("default/app", source, map[string]string{ingtypes.BackWAFMode: "deny"}, ann, []string{})
+		if test.ruleset != "" {
+			ann["/"][ingtypes.BackWAFRuleset] = test.ruleset
+		}
+		annDefault := map[string]string{
+			ingtypes.BackWAFMode:    "deny",
+			ingtypes.BackWAFRuleset: "default",
+		}
+		d := c.createBackendMappingData("default/app", source, annDefault, ann, []string{})
 		c.createUpdater().buildBackendWAF(d)
 		actual := d.backend.Paths[0].WAF
 		c.compareObjects("WAF", i, actual, test.expected)
+		c.compareObjects("WAF ruleset", i, d.backend.WAFRuleset, test.expRuleset)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
@@ -2799,3 +6229,404 @@ WARN skipping invalid IP or cidr on ingress 'default/ing1': 192.168.0/16`,
 		c.teardown()
 	}
 }
+
+func TestSourceList(t *testing.T) {
+	configMaps := map[string]*api.ConfigMap{
+		"default/office": {
+			Data: map[string]string{
+				"network": "10.0.0.0/8,192.168.0.0/16",
+				"vpn": `
+# vpn gateways
+172.17.0.10 172.17.0.11 # primary
+172.17.0.12
+10.0.0.0/8
+`,
+			},
+		},
+		"default/invalid": {
+			Data: map[string]string{
+				"ips": "10.0.0.0/48,192.168.1.101,!10.1.1.1",
+			},
+		},
+		"ingress-controller/blocked": {
+			Data: map[string]string{
+				"ips": "172.16.0.0/12",
+			},
+		},
+	}
+	testCases := []struct {
+		paths      []string
+		annDefault map[string]string
+		annPaths   map[string]map[string]string
+		modeTCP    bool
+		expAllow   map[string]string
+		expDeny    map[string]string
+		expLists   []*hatypes.SourceList
+		logging    string
+	}{
+		// 0
+		{
+			paths: []string{"/"},
+		},
+		// 1
+		{
+			paths: []string{"/", "/app"},
+			annPaths: map[string]map[string]string{
+				"/app": {
+					ingtypes.BackAllowlistConfigMap: "office",
+				},
+			},
+			expAllow: map[string]string{
+				"/app": "default/office",
+			},
+			expLists: []*hatypes.SourceList{
+				{Name: "default/office", Entries: []string{"10.0.0.0/8", "192.168.0.0/16", "172.17.0.10", "172.17.0.11", "172.17.0.12"}},
+			},
+		},
+		// 2
+		{
+			paths: []string{"/", "/app"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackDenylistConfigMap: "default/office",
+				},
+				"/app": {
+					ingtypes.BackAllowlistConfigMap: "office",
+				},
+			},
+			expAllow: map[string]string{
+				"/app": "default/office",
+			},
+			expDeny: map[string]string{
+				"/": "default/office",
+			},
+			expLists: []*hatypes.SourceList{
+				{Name: "default/office", Entries: []string{"10.0.0.0/8", "192.168.0.0/16", "172.17.0.10", "172.17.0.11", "172.17.0.12"}},
+			},
+		},
+		// 3
+		{
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAllowlistConfigMap: "invalid",
+				},
+			},
+			expAllow: map[string]string{
+				"/": "default/invalid",
+			},
+			expLists: []*hatypes.SourceList{
+				{Name: "default/invalid", Entries: []string{"192.168.1.101"}},
+			},
+			logging: `
+WARN skipping invalid IP or cidr on configmap 'default/invalid' key 'ips': 10.0.0.0/48
+WARN skipping invalid IP or cidr on configmap 'default/invalid' key 'ips': !10.1.1.1`,
+		},
+		// 4
+		{
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAllowlistConfigMap: "notfound",
+				},
+			},
+			logging: `WARN ignoring source list on ingress 'default/ing1': configmap not found: 'default/notfound'`,
+		},
+		// 5
+		{
+			paths: []string{"/"},
+			annDefault: map[string]string{
+				ingtypes.BackDenylistConfigMap: "blocked",
+			},
+			expDeny: map[string]string{
+				"/": "ingress-controller/blocked",
+			},
+			expLists: []*hatypes.SourceList{
+				{Name: "ingress-controller/blocked", Entries: []string{"172.16.0.0/12"}},
+			},
+		},
+		// 6
+		{
+			paths: []string{"/"},
+			annDefault: map[string]string{
+				ingtypes.BackDenylistConfigMap: "blocked",
+			},
+			modeTCP: true,
+			expDeny: map[string]string{
+				"tcp": "ingress-controller/blocked",
+			},
+			expLists: []*hatypes.SourceList{
+				{Name: "ingress-controller/blocked", Entries: []string{"172.16.0.0/12"}},
+			},
+		},
+		// 7
+		{
+			paths: []string{"/", "/app"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAllowlistIPList: "partners",
+				},
+				"/app": {
+					ingtypes.BackAllowlistIPList: "office, partners",
+				},
+			},
+			expAllow: map[string]string{
+				"/":    "iplist:partners",
+				"/app": "iplist:office,partners",
+			},
+			expLists: []*hatypes.SourceList{
+				{Name: "iplist:office,partners", Entries: []string{"192.168.0.0/16", "10.0.0.0/8", "203.0.113.0/24"}},
+				{Name: "iplist:partners", Entries: []string{"203.0.113.0/24", "10.0.0.0/8"}},
+			},
+		},
+		// 8
+		{
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackDenylistConfigMap: "blocked",
+					ingtypes.BackDenylistIPList:    "office",
+				},
+			},
+			expDeny: map[string]string{
+				"/": "default/blocked+iplist:office",
+			},
+			expLists: []*hatypes.SourceList{
+				{Name: "default/blocked+iplist:office", Entries: []string{"192.168.0.0/16", "10.0.0.0/8"}},
+			},
+			logging: `WARN ignoring source list on ingress 'default/ing1': configmap not found: 'default/blocked'`,
+		},
+		// 9
+		{
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAllowlistIPList: "notfound",
+				},
+			},
+			logging: `WARN ignoring IPList on ingress 'default/ing1': iplist not found: 'notfound'`,
+		},
+		// 10
+		{
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAllowlistIPList: "bad",
+				},
+			},
+			expAllow: map[string]string{
+				"/": "iplist:bad",
+			},
+			expLists: []*hatypes.SourceList{
+				{Name: "iplist:bad", Entries: []string{"192.168.1.101"}},
+			},
+		},
+	}
+	ipLists := []*v1alpha1.IPList{
+		{
+			ObjectMeta: meta.ObjectMeta{Name: "office"},
+			Spec:       v1alpha1.IPListSpec{Entries: []string{"192.168.0.0/16", "10.0.0.0/8", "192.168.0.0/16"}},
+		},
+		{
+			ObjectMeta: meta.ObjectMeta{Name: "partners"},
+			Spec:       v1alpha1.IPListSpec{Entries: []string{"203.0.113.0/24", "10.0.0.0/8"}},
+		},
+		{
+			ObjectMeta: meta.ObjectMeta{Name: "bad"},
+			Spec:       v1alpha1.IPListSpec{Entries: []string{"10.0.0.0/48", "192.168.1.101"}},
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.ConfigMapList = configMaps
+		c.cache.IPLists = ipLists
+		d := c.createBackendMappingData("default/app", source, test.annDefault, test.annPaths, test.paths)
+		d.backend.ModeTCP = test.modeTCP
+		u := c.createUpdater()
+		u.buildBackendWhitelistHTTP(d)
+		u.buildBackendWhitelistTCP(d)
+		actualAllow := map[string]string{}
+		actualDeny := map[string]string{}
+		for _, path := range d.backend.Paths {
+			if path.AllowedIPHTTP.SourceList != "" {
+				actualAllow[path.Path()] = path.AllowedIPHTTP.SourceList
+			}
+			if path.DeniedIPHTTP.SourceList != "" {
+				actualDeny[path.Path()] = path.DeniedIPHTTP.SourceList
+			}
+		}
+		if d.backend.AllowedIPTCP.SourceList != "" {
+			actualAllow["tcp"] = d.backend.AllowedIPTCP.SourceList
+		}
+		if d.backend.DeniedIPTCP.SourceList != "" {
+			actualDeny["tcp"] = d.backend.DeniedIPTCP.SourceList
+		}
+		if test.expAllow == nil {
+			test.expAllow = map[string]string{}
+		}
+		if test.expDeny == nil {
+			test.expDeny = map[string]string{}
+		}
+		c.compareObjects("source list allow", i, actualAllow, test.expAllow)
+		c.compareObjects("source list deny", i, actualDeny, test.expDeny)
+		c.compareObjects("source lists", i, d.backend.SourceLists, test.expLists)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestCountries(t *testing.T) {
+	testCases := []struct {
+		database   string
+		paths      []string
+		annDefault map[string]string
+		annPaths   map[string]map[string]string
+		modeTCP    bool
+		expAllow   map[string][]string
+		expDeny    map[string][]string
+		logging    string
+	}{
+		// 0
+		{
+			database: "/var/lib/geoip",
+			paths:    []string{"/"},
+		},
+		// 1
+		{
+			database: "/var/lib/geoip",
+			paths:    []string{"/", "/app"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackDenylistCountries: "cn, ru",
+				},
+				"/app": {
+					ingtypes.BackAllowlistCountries: "BR,US,",
+				},
+			},
+			expAllow: map[string][]string{
+				"/app": {"BR", "US"},
+			},
+			expDeny: map[string][]string{
+				"/": {"CN", "RU"},
+			},
+		},
+		// 2
+		{
+			database: "/var/lib/geoip",
+			paths:    []string{"/"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAllowlistCountries: "BR,BRA,U1",
+				},
+			},
+			expAllow: map[string][]string{
+				"/": {"BR"},
+			},
+			logging: `
+WARN skipping invalid country code on ingress 'default/ing1': BRA
+WARN skipping invalid country code on ingress 'default/ing1': U1`,
+		},
+		// 3
+		{
+			paths: []string{"/"},
+			annPaths: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAllowlistCountries: "BR",
+				},
+			},
+			logging: `WARN ignoring country list on ingress 'default/ing1': GeoIP database was not configured`,
+		},
+		// 4
+		{
+			database: "/var/lib/geoip",
+			paths:    []string{"/"},
+			annDefault: map[string]string{
+				ingtypes.BackDenylistCountries: "CN",
+			},
+			modeTCP: true,
+			expDeny: map[string][]string{
+				"tcp": {"CN"},
+			},
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.haproxy.Global().GeoIP.Database = test.database
+		d := c.createBackendMappingData("default/app", source, test.annDefault, test.annPaths, test.paths)
+		d.backend.ModeTCP = test.modeTCP
+		u := c.createUpdater()
+		u.buildBackendWhitelistHTTP(d)
+		u.buildBackendWhitelistTCP(d)
+		actualAllow := map[string][]string{}
+		actualDeny := map[string][]string{}
+		for _, path := range d.backend.Paths {
+			if path.AllowedIPHTTP.Countries != nil {
+				actualAllow[path.Path()] = path.AllowedIPHTTP.Countries
+			}
+			if path.DeniedIPHTTP.Countries != nil {
+				actualDeny[path.Path()] = path.DeniedIPHTTP.Countries
+			}
+		}
+		if d.backend.AllowedIPTCP.Countries != nil {
+			actualAllow["tcp"] = d.backend.AllowedIPTCP.Countries
+		}
+		if d.backend.DeniedIPTCP.Countries != nil {
+			actualDeny["tcp"] = d.backend.DeniedIPTCP.Countries
+		}
+		if test.expAllow == nil {
+			test.expAllow = map[string][]string{}
+		}
+		if test.expDeny == nil {
+			test.expDeny = map[string][]string{}
+		}
+		c.compareObjects("countries allow", i, actualAllow, test.expAllow)
+		c.compareObjects("countries deny", i, actualDeny, test.expDeny)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestSPOEBackendAgents(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		expected []string
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackSPOEBackendAgents: "iprep",
+			},
+			expected: []string{"iprep"},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackSPOEBackendAgents: "iprep, audit",
+			},
+			expected: []string{"iprep", "audit"},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackSPOEBackendAgents: "iprep,modsecurity",
+			},
+			expected: []string{"iprep"},
+			logging:  `WARN ignoring unknown spoe agent on ingress 'default/ing1': modsecurity`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.haproxy.Global().SPOE.Agents = []*hatypes.SPOEAgent{{Name: "audit"}, {Name: "iprep"}}
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		c.createUpdater().buildBackendSPOE(d)
+		c.compareObjects("spoe agents", i, d.backend.SPOEAgents, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
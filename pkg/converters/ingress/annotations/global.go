@@ -18,11 +18,15 @@ package annotations
 
 import (
 	"fmt"
+	"net"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	api "k8s.io/api/core/v1"
+
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
@@ -118,6 +122,79 @@ func (c *updater) buildGlobalPathTypeOrder(d *globalData) {
 	d.global.MatchOrder = order
 }
 
+var peerNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+func (c *updater) buildGlobalPeers(d *globalData) {
+	port := d.mapper.Get(ingtypes.GlobalPeersPort).Int()
+	if port == 0 {
+		return
+	}
+	if port < 0 || port > 65535 {
+		c.logger.Warn("ignoring invalid peers port: %d", port)
+		return
+	}
+	localPeer := c.cache.GetPodName()
+	if !peerNameRegex.MatchString(localPeer) {
+		c.logger.Warn("ignoring peers configuration, invalid local peer name: '%s'", localPeer)
+		return
+	}
+	d.global.Peers.LocalPeer = localPeer
+	d.global.Peers.Port = port
+	var service *api.Service
+	if serviceName := d.mapper.Get(ingtypes.GlobalPeersService).Value; serviceName != "" {
+		var err error
+		service, err = c.cache.GetService(c.cache.GetPodNamespace(), serviceName)
+		if err != nil {
+			c.logger.Warn("ignoring peers service: %v", err)
+			return
+		}
+	} else {
+		// auto discovery, any service selecting the controller pod has
+		// the other replicas as its endpoints
+		services, err := c.cache.GetControllerServices()
+		if err != nil {
+			c.logger.Warn("ignoring peers auto discovery: %v", err)
+			return
+		}
+		if len(services) == 0 {
+			return
+		}
+		service = services[0]
+	}
+	slices, err := c.cache.GetEndpointSlices(service)
+	if err != nil {
+		c.logger.Warn("ignoring peers service: %v", err)
+		return
+	}
+	peers := map[string]string{}
+	for _, slice := range slices {
+		for _, ep := range slice.Endpoints {
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready || len(ep.Addresses) == 0 {
+				continue
+			}
+			ip := ep.Addresses[0]
+			name := ip
+			if ep.TargetRef != nil && ep.TargetRef.Kind == "Pod" {
+				name = ep.TargetRef.Name
+			}
+			if name != localPeer && peerNameRegex.MatchString(name) {
+				peers[name] = net.JoinHostPort(ip, strconv.Itoa(port))
+			}
+		}
+	}
+	names := make([]string, 0, len(peers))
+	for name := range peers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d.global.Peers.Servers = append(d.global.Peers.Servers, &hatypes.PeersServer{
+			Name:     name,
+			Endpoint: peers[name],
+		})
+	}
+}
+
 func (c *updater) buildGlobalProc(d *globalData) {
 	balance := d.mapper.Get(ingtypes.GlobalNbprocBalance).Int()
 	if balance < 1 {
@@ -140,7 +217,15 @@ func (c *updater) buildGlobalProc(d *globalData) {
 		ssl = 0
 	}
 	procs := balance + ssl
-	threads := d.mapper.Get(ingtypes.GlobalNbthread).Int()
+	autoThreads := d.mapper.Get(ingtypes.GlobalNbthread).Value == "auto"
+	var threads int
+	if autoThreads {
+		// the CPU quota doesn't reserve specific cores, auto threads aren't
+		// pinned to the CPUs unless cpu-map is also configured
+		threads = c.options.AvailableCPUs
+	} else {
+		threads = d.mapper.Get(ingtypes.GlobalNbthread).Int()
+	}
 	if threads < 1 {
 		c.logger.Warn("invalid value of nbthread configmap option (%v), using 1", threads)
 		threads = 1
@@ -161,7 +246,7 @@ func (c *updater) buildGlobalProc(d *globalData) {
 	cpumap := ""
 	if useCPUMap {
 		cpumap = d.mapper.Get(ingtypes.GlobalCPUMap).Value
-		if cpumap == "" {
+		if cpumap == "" && !autoThreads {
 			if threads > 1 {
 				if procs == 1 {
 					cpumap = fmt.Sprintf("auto:1/1-%v 0-%v", threads, threads-1)
@@ -180,13 +265,40 @@ func (c *updater) buildGlobalProc(d *globalData) {
 	d.global.Procs.CPUMap = cpumap
 }
 
+var (
+	prometheusScopes     = []string{"global", "frontend", "backend", "server"}
+	prometheusScopeRegex = regexp.MustCompile(`^(global|frontend|backend|server)$`)
+)
+
+func (c *updater) buildGlobalPrometheus(d *globalData) {
+	d.global.Prometheus.BindIP = d.mapper.Get(ingtypes.GlobalBindIPAddrPrometheus).Value
+	d.global.Prometheus.Port = d.mapper.Get(ingtypes.GlobalPrometheusPort).Int()
+	serverMetrics := d.mapper.Get(ingtypes.GlobalPrometheusServerMetrics).Bool()
+	configured := map[string]bool{}
+	for _, scope := range utils.Split(d.mapper.Get(ingtypes.GlobalPrometheusScope).Value, ",") {
+		if !prometheusScopeRegex.MatchString(scope) {
+			c.logger.Warn("ignoring invalid prometheus scope: %s", scope)
+			continue
+		}
+		configured[scope] = true
+	}
+	if len(configured) == 0 && serverMetrics {
+		// no filter, the exporter exports all the scopes
+		return
+	}
+	var scopes []string
+	for _, scope := range prometheusScopes {
+		if (len(configured) == 0 || configured[scope]) && (serverMetrics || scope != "server") {
+			scopes = append(scopes, scope)
+		}
+	}
+	d.global.Prometheus.Scopes = scopes
+}
+
 func (c *updater) buildGlobalStats(d *globalData) {
 	// healthz
 	d.global.Healthz.BindIP = d.mapper.Get(ingtypes.GlobalBindIPAddrHealthz).Value
 	d.global.Healthz.Port = d.mapper.Get(ingtypes.GlobalHealthzPort).Int()
-	// prometheus
-	d.global.Prometheus.BindIP = d.mapper.Get(ingtypes.GlobalBindIPAddrPrometheus).Value
-	d.global.Prometheus.Port = d.mapper.Get(ingtypes.GlobalPrometheusPort).Int()
 	// stats
 	d.global.Stats.AcceptProxy = d.mapper.Get(ingtypes.GlobalStatsProxyProtocol).Bool()
 	d.global.Stats.Auth = d.mapper.Get(ingtypes.GlobalStatsAuth).Value
@@ -203,7 +315,12 @@ func (c *updater) buildGlobalStats(d *globalData) {
 }
 
 func (c *updater) buildGlobalSyslog(d *globalData) {
-	d.global.Syslog.Endpoint = d.mapper.Get(ingtypes.GlobalSyslogEndpoint).Value
+	endpoint := d.mapper.Get(ingtypes.GlobalSyslogEndpoint).Value
+	if strings.HasPrefix(endpoint, "ring@") && d.global.FindLogRing(endpoint[5:]) == nil {
+		c.logger.Warn("ignoring syslog endpoint '%s': log ring was not declared", endpoint)
+		endpoint = ""
+	}
+	d.global.Syslog.Endpoint = endpoint
 	d.global.Syslog.Format = d.mapper.Get(ingtypes.GlobalSyslogFormat).Value
 	d.global.Syslog.Length = d.mapper.Get(ingtypes.GlobalSyslogLength).Int()
 	d.global.Syslog.Tag = d.mapper.Get(ingtypes.GlobalSyslogTag).Value
@@ -212,12 +329,180 @@ func (c *updater) buildGlobalSyslog(d *globalData) {
 	d.global.Syslog.HTTPLogFormat = d.mapper.Get(ingtypes.GlobalHTTPLogFormat).Value
 	d.global.Syslog.HTTPSLogFormat = d.mapper.Get(ingtypes.GlobalHTTPSLogFormat).Value
 	d.global.Syslog.TCPLogFormat = d.mapper.Get(ingtypes.GlobalTCPLogFormat).Value
+	//
+	for _, line := range utils.LineToSlice(d.mapper.Get(ingtypes.GlobalExtraFrontendsSyslog).Value) {
+		if line == "" {
+			continue
+		}
+		if err := c.parseExtraFrontendSyslog(d, line); err != nil {
+			c.logger.Warn("ignoring extra frontend syslog '%s': %v", line, err)
+		}
+	}
+}
+
+// parseExtraFrontendSyslog parses a `<name>=<endpoint>[=<http-log-format>]` line
+func (c *updater) parseExtraFrontendSyslog(d *globalData, line string) error {
+	syslogData := strings.SplitN(line, "=", 3)
+	if len(syslogData) < 2 {
+		return fmt.Errorf("expected name, syslog endpoint and optional log format separated by '='")
+	}
+	for i := range syslogData {
+		syslogData[i] = strings.TrimSpace(syslogData[i])
+	}
+	name, endpoint := syslogData[0], syslogData[1]
+	var logFormat string
+	if len(syslogData) == 3 {
+		logFormat = syslogData[2]
+	}
+	extra := c.haproxy.Frontend().FindExtra(name)
+	if extra == nil {
+		return fmt.Errorf("extra frontend '%s' was not declared", name)
+	}
+	if extra.SyslogEndpoint != "" || extra.HTTPLogFormat != "" {
+		return fmt.Errorf("syslog of extra frontend '%s' was already declared", name)
+	}
+	if endpoint == "" && logFormat == "" {
+		return fmt.Errorf("missing syslog endpoint and log format")
+	}
+	if strings.ContainsAny(endpoint, " \t\"'#") {
+		return fmt.Errorf("invalid syslog endpoint: %s", endpoint)
+	}
+	if strings.HasPrefix(endpoint, "ring@") && d.global.FindLogRing(endpoint[5:]) == nil {
+		return fmt.Errorf("log ring was not declared: %s", endpoint[5:])
+	}
+	if endpoint == "" && d.global.Syslog.Endpoint == "" {
+		return fmt.Errorf("log format requires a syslog endpoint")
+	}
+	extra.SyslogEndpoint = endpoint
+	extra.HTTPLogFormat = logFormat
+	return nil
+}
+
+var (
+	logForwardNameRegex   = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	logForwardBindRegex   = regexp.MustCompile(`^((udp|tcp):)?(([0-9.]+|\[[0-9A-Fa-f:.]+\])?:)?([0-9]{1,5})$`)
+	logForwardTargetRegex = regexp.MustCompile(`^[^"' ]+$`)
+)
+
+func (c *updater) buildGlobalLogForward(d *globalData) {
+	logForward := d.mapper.Get(ingtypes.GlobalLogForward).Value
+	for _, line := range utils.LineToSlice(logForward) {
+		if line == "" {
+			continue
+		}
+		fwd, err := parseLogForward(line, d.global.Bind.TCPBindIP)
+		if err != nil {
+			c.logger.Warn("ignoring log forward '%s': %v", line, err)
+			continue
+		}
+		d.global.LogForward = append(d.global.LogForward, fwd)
+	}
+}
+
+// parseLogForward parses a `<name>=<bind>[,<bind>...]=<target>[,<target>...]` line
+func parseLogForward(line, bindIP string) (*hatypes.LogForward, error) {
+	fwdData := strings.Split(line, "=")
+	if len(fwdData) != 3 {
+		return nil, fmt.Errorf("expected name, binds and targets separated by '='")
+	}
+	if !logForwardNameRegex.MatchString(fwdData[0]) {
+		return nil, fmt.Errorf("invalid name: %s", fwdData[0])
+	}
+	fwd := &hatypes.LogForward{Name: fwdData[0]}
+	for _, bind := range utils.Split(fwdData[1], ",") {
+		if bind == "" {
+			continue
+		}
+		match := logForwardBindRegex.FindStringSubmatch(bind)
+		if match == nil {
+			return nil, fmt.Errorf("invalid bind: %s", bind)
+		}
+		addr := match[3]
+		if addr == "" {
+			// port only, listen to the same IP of the TCP services
+			addr = bindIP + ":"
+		}
+		addr += match[5]
+		if match[2] == "tcp" {
+			fwd.Binds = append(fwd.Binds, addr)
+		} else {
+			fwd.DgramBinds = append(fwd.DgramBinds, "udp@"+addr)
+		}
+	}
+	for _, target := range utils.Split(fwdData[2], ",") {
+		if target == "" {
+			continue
+		}
+		if !logForwardTargetRegex.MatchString(target) {
+			return nil, fmt.Errorf("invalid target: %s", target)
+		}
+		fwd.Targets = append(fwd.Targets, target)
+	}
+	if len(fwd.Binds)+len(fwd.DgramBinds) == 0 {
+		return nil, fmt.Errorf("missing bind")
+	}
+	if len(fwd.Targets) == 0 {
+		return nil, fmt.Errorf("missing target")
+	}
+	return fwd, nil
+}
+
+var logRingServerRegex = regexp.MustCompile(`^([A-Za-z0-9.-]+|\[[0-9A-Fa-f:.]+\]):[0-9]{1,5}$`)
+
+func (c *updater) buildGlobalLogRing(d *globalData) {
+	logRing := d.mapper.Get(ingtypes.GlobalLogRing).Value
+	size := d.mapper.Get(ingtypes.GlobalLogRingSize).Int()
+	for _, line := range utils.LineToSlice(logRing) {
+		if line == "" {
+			continue
+		}
+		ring, err := parseLogRing(line)
+		if err != nil {
+			c.logger.Warn("ignoring log ring '%s': %v", line, err)
+			continue
+		}
+		if d.global.FindLogRing(ring.Name) != nil {
+			c.logger.Warn("ignoring log ring '%s': name '%s' is already in use", line, ring.Name)
+			continue
+		}
+		ring.Size = size
+		d.global.LogRings = append(d.global.LogRings, ring)
+	}
+}
+
+// parseLogRing parses a `<name>=<server>[,<server>...]` line
+func parseLogRing(line string) (*hatypes.LogRing, error) {
+	ringData := strings.Split(line, "=")
+	if len(ringData) != 2 {
+		return nil, fmt.Errorf("expected name and servers separated by '='")
+	}
+	if !logForwardNameRegex.MatchString(ringData[0]) {
+		return nil, fmt.Errorf("invalid name: %s", ringData[0])
+	}
+	ring := &hatypes.LogRing{Name: ringData[0]}
+	for _, server := range utils.Split(ringData[1], ",") {
+		if server == "" {
+			continue
+		}
+		if !logRingServerRegex.MatchString(server) {
+			return nil, fmt.Errorf("invalid server: %s", server)
+		}
+		ring.Servers = append(ring.Servers, &hatypes.LogRingServer{
+			Name:     fmt.Sprintf("srv%02d", len(ring.Servers)+1),
+			Endpoint: server,
+		})
+	}
+	if len(ring.Servers) == 0 {
+		return nil, fmt.Errorf("missing server")
+	}
+	return ring, nil
 }
 
 func (c *updater) buildGlobalTimeout(d *globalData) {
 	d.global.Timeout.Client = c.validateTime(d.mapper.Get(ingtypes.GlobalTimeoutClient))
 	d.global.Timeout.ClientFin = c.validateTime(d.mapper.Get(ingtypes.GlobalTimeoutClientFin))
 	d.global.Timeout.Connect = c.validateTime(d.mapper.Get(ingtypes.BackTimeoutConnect))
+	d.global.Timeout.Grace = c.validateTime(d.mapper.Get(ingtypes.GlobalTimeoutGrace))
 	d.global.Timeout.HTTPRequest = c.validateTime(d.mapper.Get(ingtypes.BackTimeoutHTTPRequest))
 	d.global.Timeout.KeepAlive = c.validateTime(d.mapper.Get(ingtypes.BackTimeoutKeepAlive))
 	d.global.Timeout.Queue = c.validateTime(d.mapper.Get(ingtypes.BackTimeoutQueue))
@@ -249,6 +534,21 @@ func (c *updater) buildSecurity(d *globalData) {
 	d.global.Security.UseChroot = d.mapper.Get(ingtypes.GlobalUseChroot).Bool()
 }
 
+func (c *updater) buildGlobalTracing(d *globalData) {
+	d.global.Tracing.TraceContext = d.mapper.Get(ingtypes.GlobalTraceContext).Bool()
+	format := d.mapper.Get(ingtypes.GlobalUniqueIDFormat).Value
+	if format == "" {
+		return
+	}
+	header := d.mapper.Get(ingtypes.GlobalUniqueIDHeader).Value
+	if !httpHeaderNameRegex.MatchString(header) {
+		c.logger.Warn("ignoring unique-id-format, invalid unique-id-header name: %s", header)
+		return
+	}
+	d.global.Tracing.UniqueIDFormat = format
+	d.global.Tracing.UniqueIDHeader = header
+}
+
 func (c *updater) buildGlobalSSL(d *globalData) {
 	ssl := &d.global.SSL
 	ssl.ALPN = d.mapper.Get(ingtypes.HostTLSALPN).Value
@@ -292,6 +592,101 @@ func (c *updater) buildGlobalHTTPStoHTTP(d *globalData) {
 	d.global.Bind.FrontingSockID = 10011
 }
 
+func (c *updater) buildGlobalHTTP3(d *globalData) {
+	if !d.mapper.Get(ingtypes.GlobalHTTP3).Bool() {
+		return
+	}
+	httpsPort := d.mapper.Get(ingtypes.GlobalHTTPSPort).Int()
+	if bindQUIC := d.mapper.Get(ingtypes.GlobalBindQUIC).Value; bindQUIC != "" {
+		d.global.HTTP3.Bind = bindQUIC
+	} else {
+		ip := d.mapper.Get(ingtypes.GlobalBindIPAddrHTTP).Value
+		family := "quic4"
+		if strings.Contains(ip, ":") {
+			family = "quic6"
+			ip = "[" + strings.Trim(ip, "[]") + "]"
+		}
+		d.global.HTTP3.Bind = fmt.Sprintf("%s@%s:%d", family, ip, httpsPort)
+	}
+	d.global.HTTP3.Enabled = true
+	d.global.HTTP3.AltSvcMaxAge = d.mapper.Get(ingtypes.GlobalHTTP3AltSvcMaxAge).Int()
+	d.global.HTTP3.AltSvcPort = d.mapper.Get(ingtypes.GlobalHTTP3AltSvcPort).Int()
+	if d.global.HTTP3.AltSvcPort == 0 {
+		d.global.HTTP3.AltSvcPort = httpsPort
+	}
+	d.global.HTTP3.ConnTxBuffersLimit = d.mapper.Get(ingtypes.GlobalHTTP3ConnTxBuffersLimit).Int()
+	d.global.HTTP3.MaxIdleTimeout = c.validateTime(d.mapper.Get(ingtypes.GlobalHTTP3MaxIdleTimeout))
+	d.global.HTTP3.MaxStreamsBidi = d.mapper.Get(ingtypes.GlobalHTTP3MaxStreamsBidi).Int()
+}
+
+var (
+	luaScriptNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+\.lua$`)
+	luaRegisterRegex   = regexp.MustCompile(`core\.register_(action|fetches)\s*\(\s*["']([A-Za-z0-9_.-]+)["']`)
+)
+
+// buildGlobalLuaScripts reads custom Lua scripts from a ConfigMap. Scripts are
+// loaded in the order of their keys, and the actions and sample fetches they
+// register are used to validate the references from the backends.
+func (c *updater) buildGlobalLuaScripts(d *globalData) {
+	name := d.mapper.Get(ingtypes.GlobalLuaScripts).Value
+	if name == "" {
+		return
+	}
+	data, err := c.cache.GetConfigMapData(c.cache.GetPodNamespace(), name, convtypes.TrackingTarget{})
+	if err != nil {
+		c.logger.Warn("ignoring lua scripts: %v", err)
+		return
+	}
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !luaScriptNameRegex.MatchString(key) {
+			c.logger.Warn("ignoring lua script '%s' on configmap '%s': name should end with .lua", key, name)
+			continue
+		}
+		script := &hatypes.LuaScript{
+			Name:    key,
+			Content: data[key],
+		}
+		for _, match := range luaRegisterRegex.FindAllStringSubmatch(script.Content, -1) {
+			if match[1] == "action" {
+				script.Actions = append(script.Actions, match[2])
+			} else {
+				script.Fetches = append(script.Fetches, match[2])
+			}
+		}
+		d.global.LuaScripts = append(d.global.LuaScripts, script)
+	}
+}
+
+var cookieKeyRegex = regexp.MustCompile(`^[A-Za-z0-9+/=_.:-]+$`)
+
+// buildGlobalCookieKey reads the key used to create dynamic cookies and to
+// hash the server identifier of the affinity cookies. A key stored in a
+// secret has precedence, and a change in the secret leads to a full sync,
+// so the key can be rotated without changing the global config.
+func (c *updater) buildGlobalCookieKey(d *globalData) {
+	d.global.Cookie.Key = d.mapper.Get(ingtypes.GlobalCookieKey).Value
+	name := d.mapper.Get(ingtypes.GlobalCookieKeySecret).Value
+	if name == "" {
+		return
+	}
+	content, err := c.cache.GetSecretContent(c.cache.GetPodNamespace(), name, "cookie-key", convtypes.TrackingTarget{})
+	if err != nil {
+		c.logger.Warn("ignoring cookie key secret: %v", err)
+		return
+	}
+	key := strings.TrimSpace(string(content))
+	if !cookieKeyRegex.MatchString(key) {
+		c.logger.Warn("ignoring cookie key secret '%s': key should be a non empty sequence of letters, digits or any of '+/=_.:-'", name)
+		return
+	}
+	d.global.Cookie.Key = key
+}
+
 func (c *updater) buildGlobalModSecurity(d *globalData) {
 	d.global.ModSecurity.Endpoints = utils.Split(d.mapper.Get(ingtypes.GlobalModsecurityEndpoints).Value, ",")
 	d.global.ModSecurity.Timeout.Connect = c.validateTime(d.mapper.Get(ingtypes.GlobalModsecurityTimeoutConnect))
@@ -299,6 +694,132 @@ func (c *updater) buildGlobalModSecurity(d *globalData) {
 	d.global.ModSecurity.Timeout.Idle = c.validateTime(d.mapper.Get(ingtypes.GlobalModsecurityTimeoutIdle))
 	d.global.ModSecurity.Timeout.Processing = c.validateTime(d.mapper.Get(ingtypes.GlobalModsecurityTimeoutProcessing))
 	d.global.ModSecurity.Timeout.Server = c.validateTime(d.mapper.Get(ingtypes.GlobalModsecurityTimeoutServer))
+	d.global.Coraza.Endpoints = utils.Split(d.mapper.Get(ingtypes.GlobalCorazaEndpoints).Value, ",")
+	d.global.Coraza.Timeout = d.global.ModSecurity.Timeout
+}
+
+var (
+	spoeAgentNameRegex   = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	spoeMessageNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	spoeEventRegex       = regexp.MustCompile(`^on-(client-session|server-session|frontend-tcp-request|backend-tcp-request|tcp-response|frontend-http-request|backend-http-request|http-response)$`)
+	spoeArgRegex         = regexp.MustCompile(`^[^\s"'#\\]+$`)
+	spoeEndpointRegex    = regexp.MustCompile(`^([A-Za-z0-9.-]+|\[[0-9A-Fa-f:.]+\]):[0-9]{1,5}$`)
+)
+
+func (c *updater) buildGlobalSPOE(d *globalData) {
+	messages := map[string]*hatypes.SPOEMessage{}
+	for _, line := range utils.LineToSlice(d.mapper.Get(ingtypes.GlobalSPOEMessages).Value) {
+		if line == "" {
+			continue
+		}
+		msg, err := parseSPOEMessage(line)
+		if err == nil && messages[msg.Name] != nil {
+			err = fmt.Errorf("duplicated name: %s", msg.Name)
+		}
+		if err != nil {
+			c.logger.Warn("ignoring spoe message '%s': %v", line, err)
+			continue
+		}
+		messages[msg.Name] = msg
+	}
+	agents := map[string]bool{}
+	for _, line := range utils.LineToSlice(d.mapper.Get(ingtypes.GlobalSPOEAgents).Value) {
+		if line == "" {
+			continue
+		}
+		agent, err := parseSPOEAgent(line, messages)
+		if err == nil && agents[agent.Name] {
+			err = fmt.Errorf("duplicated name: %s", agent.Name)
+		}
+		if err != nil {
+			c.logger.Warn("ignoring spoe agent '%s': %v", line, err)
+			continue
+		}
+		agents[agent.Name] = true
+		d.global.SPOE.Agents = append(d.global.SPOE.Agents, agent)
+	}
+	for _, name := range utils.Split(d.mapper.Get(ingtypes.GlobalSPOEFrontendAgents).Value, ",") {
+		if !agents[name] {
+			c.logger.Warn("ignoring unknown spoe agent on frontends: %s", name)
+			continue
+		}
+		d.global.SPOE.FrontendAgents = append(d.global.SPOE.FrontendAgents, name)
+	}
+	if len(d.global.SPOE.Agents) > 0 {
+		d.global.SPOE.Timeout.Connect = c.validateTime(d.mapper.Get(ingtypes.GlobalSPOETimeoutConnect))
+		d.global.SPOE.Timeout.Hello = c.validateTime(d.mapper.Get(ingtypes.GlobalSPOETimeoutHello))
+		d.global.SPOE.Timeout.Idle = c.validateTime(d.mapper.Get(ingtypes.GlobalSPOETimeoutIdle))
+		d.global.SPOE.Timeout.Processing = c.validateTime(d.mapper.Get(ingtypes.GlobalSPOETimeoutProcessing))
+		d.global.SPOE.Timeout.Server = c.validateTime(d.mapper.Get(ingtypes.GlobalSPOETimeoutServer))
+	}
+}
+
+// parseSPOEMessage parses a `<name>=<event>[=<arg> [<arg>...]]` line
+func parseSPOEMessage(line string) (*hatypes.SPOEMessage, error) {
+	msgData := strings.SplitN(line, "=", 3)
+	if len(msgData) < 2 {
+		return nil, fmt.Errorf("expected name, event and args separated by '='")
+	}
+	name := strings.TrimSpace(msgData[0])
+	if !spoeMessageNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid name: %s", name)
+	}
+	event := strings.TrimSpace(msgData[1])
+	if !spoeEventRegex.MatchString(event) {
+		return nil, fmt.Errorf("invalid event: %s", event)
+	}
+	msg := &hatypes.SPOEMessage{Name: name, Event: event}
+	if len(msgData) == 3 {
+		for _, arg := range strings.Fields(msgData[2]) {
+			if !spoeArgRegex.MatchString(arg) {
+				return nil, fmt.Errorf("invalid arg: %s", arg)
+			}
+			msg.Args = append(msg.Args, arg)
+		}
+	}
+	return msg, nil
+}
+
+// parseSPOEAgent parses a `<name>=<endpoint>[,<endpoint>...]=<message>[,<message>...]` line
+func parseSPOEAgent(line string, messages map[string]*hatypes.SPOEMessage) (*hatypes.SPOEAgent, error) {
+	agentData := strings.Split(line, "=")
+	if len(agentData) != 3 {
+		return nil, fmt.Errorf("expected name, endpoints and messages separated by '='")
+	}
+	name := strings.TrimSpace(agentData[0])
+	if !spoeAgentNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid name: %s", name)
+	}
+	if name == "modsecurity" {
+		return nil, fmt.Errorf("name is reserved: %s", name)
+	}
+	agent := &hatypes.SPOEAgent{Name: name}
+	for _, endpoint := range utils.Split(agentData[1], ",") {
+		if endpoint == "" {
+			continue
+		}
+		if !spoeEndpointRegex.MatchString(endpoint) {
+			return nil, fmt.Errorf("invalid endpoint: %s", endpoint)
+		}
+		agent.Endpoints = append(agent.Endpoints, endpoint)
+	}
+	for _, msgName := range utils.Split(agentData[2], ",") {
+		if msgName == "" {
+			continue
+		}
+		msg, found := messages[msgName]
+		if !found {
+			return nil, fmt.Errorf("message not found: %s", msgName)
+		}
+		agent.Messages = append(agent.Messages, msg)
+	}
+	if len(agent.Endpoints) == 0 {
+		return nil, fmt.Errorf("missing endpoint")
+	}
+	if len(agent.Messages) == 0 {
+		return nil, fmt.Errorf("missing message")
+	}
+	return agent, nil
 }
 
 func (c *updater) buildGlobalDNS(d *globalData) {
@@ -319,6 +840,8 @@ func (c *updater) buildGlobalDNS(d *globalData) {
 			c.logger.Warn("ignoring misconfigured resolver: %s", resolver)
 			continue
 		}
+		// a resolver without nameservers uses the nameservers of the
+		// controller pod, see parse-resolv-conf in the template
 		dnsResolver := &hatypes.DNSResolver{
 			Name:                resolverData[0],
 			AcceptedPayloadSize: payloadSize,
@@ -359,6 +882,76 @@ func (c *updater) buildGlobalDynamic(d *globalData) {
 	// Services
 	c.options.DynamicConfig.CrossNamespaceServices =
 		c.validateAllowDeny(d, ingtypes.GlobalCrossNamespaceServices)
+
+	// ConfigMaps
+	c.options.DynamicConfig.CrossNamespaceConfigMaps =
+		c.validateAllowDeny(d, ingtypes.GlobalCrossNamespaceConfigMaps)
+}
+
+var (
+	extraFrontendNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	extraFrontendBindRegex = regexp.MustCompile(`^[^"'#]*$`)
+)
+
+func (c *updater) buildGlobalExtraFrontends(d *globalData) {
+	var extras []*hatypes.ExtraFrontend
+	for _, line := range utils.LineToSlice(d.mapper.Get(ingtypes.GlobalExtraFrontends).Value) {
+		if line == "" {
+			continue
+		}
+		extra, err := c.parseExtraFrontend(line)
+		if err != nil {
+			c.logger.Warn("ignoring extra frontend '%s': %v", line, err)
+			continue
+		}
+		var duplicated bool
+		for _, e := range extras {
+			duplicated = duplicated || e.Name == extra.Name
+		}
+		if duplicated {
+			c.logger.Warn("ignoring extra frontend '%s': name '%s' is already in use", line, extra.Name)
+			continue
+		}
+		extras = append(extras, extra)
+	}
+	c.haproxy.Frontend().Extras = extras
+}
+
+// parseExtraFrontend parses a `<name>=<http-bind>=<https-bind>[=<default-crt-secret>]` line
+func (c *updater) parseExtraFrontend(line string) (*hatypes.ExtraFrontend, error) {
+	frontData := strings.Split(line, "=")
+	if len(frontData) < 3 || len(frontData) > 4 {
+		return nil, fmt.Errorf("expected name, http bind, https bind and optional default certificate separated by '='")
+	}
+	for i := range frontData {
+		frontData[i] = strings.TrimSpace(frontData[i])
+	}
+	if !extraFrontendNameRegex.MatchString(frontData[0]) {
+		return nil, fmt.Errorf("invalid name: %s", frontData[0])
+	}
+	extra := &hatypes.ExtraFrontend{
+		Name:      frontData[0],
+		HTTPBind:  frontData[1],
+		HTTPSBind: frontData[2],
+	}
+	if extra.HTTPBind == "" && extra.HTTPSBind == "" {
+		return nil, fmt.Errorf("missing bind")
+	}
+	for _, bind := range []string{extra.HTTPBind, extra.HTTPSBind} {
+		if !extraFrontendBindRegex.MatchString(bind) {
+			return nil, fmt.Errorf("invalid bind: %s", bind)
+		}
+	}
+	if len(frontData) == 4 && frontData[3] != "" {
+		crtFile, err := c.cache.GetTLSSecretPath("", frontData[3], convtypes.TrackingTarget{})
+		if err != nil {
+			c.logger.Warn("using default certificate on extra frontend '%s': %v", extra.Name, err)
+		} else {
+			extra.DefaultCrtFile = crtFile.Filename
+			extra.DefaultCrtHash = crtFile.SHA1Hash
+		}
+	}
+	return extra, nil
 }
 
 var forwardRegex = regexp.MustCompile(`^(add|update|ignore|ifmissing)$`)
@@ -374,6 +967,26 @@ func (c *updater) buildGlobalForwardFor(d *globalData) {
 	}
 }
 
+func (c *updater) buildGlobalGeoIP(d *globalData) {
+	database := d.mapper.Get(ingtypes.GlobalGeoIPDatabase).Value
+	if database == "" {
+		return
+	}
+	format := d.mapper.Get(ingtypes.GlobalGeoIPDatabaseFormat).Value
+	if format != "maxmind" && format != "ip2location" {
+		c.logger.Warn("ignoring GeoIP database, unsupported format: %s", format)
+		return
+	}
+	header := d.mapper.Get(ingtypes.GlobalGeoIPCountryHeader).Value
+	if header != "" && !httpHeaderNameRegex.MatchString(header) {
+		c.logger.Warn("ignoring invalid GeoIP country header name: %s", header)
+		header = ""
+	}
+	d.global.GeoIP.CountryHeader = header
+	d.global.GeoIP.Database = database
+	d.global.GeoIP.Format = format
+}
+
 func (c *updater) buildGlobalCustomConfig(d *globalData) {
 	d.global.CustomConfig = utils.LineToSlice(d.mapper.Get(ingtypes.GlobalConfigGlobal).Value)
 	d.global.CustomDefaults = utils.LineToSlice(d.mapper.Get(ingtypes.GlobalConfigDefaults).Value)
@@ -20,9 +20,13 @@ import (
 	"reflect"
 	"testing"
 
+	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestAuthProxy(t *testing.T) {
@@ -149,6 +153,94 @@ func TestBind(t *testing.T) {
 	}
 }
 
+func TestHTTP3(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		expected hatypes.HTTP3Config
+		logging  string
+	}{
+		// 0
+		{
+			ann:      map[string]string{},
+			expected: hatypes.HTTP3Config{},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.GlobalHTTP3: "true",
+			},
+			expected: hatypes.HTTP3Config{
+				Enabled:      true,
+				Bind:         "quic4@*:443",
+				AltSvcMaxAge: 86400,
+				AltSvcPort:   443,
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.GlobalHTTP3:          "true",
+				ingtypes.GlobalBindIPAddrHTTP: "::",
+				ingtypes.GlobalHTTPSPort:      "8443",
+			},
+			expected: hatypes.HTTP3Config{
+				Enabled:      true,
+				Bind:         "quic6@[::]:8443",
+				AltSvcMaxAge: 86400,
+				AltSvcPort:   8443,
+			},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.GlobalHTTP3:                   "true",
+				ingtypes.GlobalBindQUIC:                "quic4@10.0.0.1:443",
+				ingtypes.GlobalHTTPSPort:               "8443",
+				ingtypes.GlobalHTTP3AltSvcMaxAge:       "0",
+				ingtypes.GlobalHTTP3AltSvcPort:         "443",
+				ingtypes.GlobalHTTP3ConnTxBuffersLimit: "20",
+				ingtypes.GlobalHTTP3MaxIdleTimeout:     "30s",
+				ingtypes.GlobalHTTP3MaxStreamsBidi:     "50",
+			},
+			expected: hatypes.HTTP3Config{
+				Enabled:            true,
+				Bind:               "quic4@10.0.0.1:443",
+				AltSvcPort:         443,
+				ConnTxBuffersLimit: 20,
+				MaxIdleTimeout:     "30s",
+				MaxStreamsBidi:     50,
+			},
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.GlobalHTTP3:               "true",
+				ingtypes.GlobalHTTP3MaxIdleTimeout: "30",
+			},
+			expected: hatypes.HTTP3Config{
+				Enabled:      true,
+				Bind:         "quic4@*:443",
+				AltSvcMaxAge: 86400,
+				AltSvcPort:   443,
+			},
+			logging: `WARN ignoring invalid time format on global/default config: 30`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(map[string]string{
+			ingtypes.GlobalHTTPSPort:         "443",
+			ingtypes.GlobalBindIPAddrHTTP:    "*",
+			ingtypes.GlobalHTTP3AltSvcMaxAge: "86400",
+		})
+		d.mapper.AddAnnotations(nil, hatypes.CreatePathLink("-", "-", hatypes.MatchBegin), test.ann)
+		c.createUpdater().buildGlobalHTTP3(d)
+		c.compareObjects("http3", i, d.global.HTTP3, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestCustomConfigProxy(t *testing.T) {
 	testCases := []struct {
 		config   string
@@ -207,6 +299,132 @@ proxy_1
 	}
 }
 
+func TestLuaScripts(t *testing.T) {
+	configMaps := map[string]*api.ConfigMap{
+		"ingress-controller/lua": {
+			Data: map[string]string{
+				"20-headers.lua": `
+core.register_fetches("client_tier", function(txn) return "gold" end)
+core.register_action('add_tier', { "http-req" }, function(txn) end, 0)
+`,
+				"10-auth.lua": `core.register_action( "check_token", { "http-req" }, function(txn, header) end, 1)`,
+				"README.md":   `# scripts`,
+			},
+		},
+	}
+	testCases := []struct {
+		conf     map[string]string
+		expected []*hatypes.LuaScript
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			conf: map[string]string{
+				ingtypes.GlobalLuaScripts: "lua",
+			},
+			expected: []*hatypes.LuaScript{
+				{
+					Name:    "10-auth.lua",
+					Content: `core.register_action( "check_token", { "http-req" }, function(txn, header) end, 1)`,
+					Actions: []string{"check_token"},
+				},
+				{
+					Name: "20-headers.lua",
+					Content: `
+core.register_fetches("client_tier", function(txn) return "gold" end)
+core.register_action('add_tier', { "http-req" }, function(txn) end, 0)
+`,
+					Actions: []string{"add_tier"},
+					Fetches: []string{"client_tier"},
+				},
+			},
+			logging: `WARN ignoring lua script 'README.md' on configmap 'lua': name should end with .lua`,
+		},
+		// 2
+		{
+			conf: map[string]string{
+				ingtypes.GlobalLuaScripts: "notfound",
+			},
+			logging: `WARN ignoring lua scripts: configmap not found: 'ingress-controller/notfound'`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.ConfigMapList = configMaps
+		d := c.createGlobalData(test.conf)
+		c.createUpdater().buildGlobalLuaScripts(d)
+		c.compareObjects("lua scripts", i, d.global.LuaScripts, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestCookieKey(t *testing.T) {
+	secrets := conv_helper.SecretContent{
+		"ingress-controller/cookie": {"cookie-key": []byte("s3cr3t\n")},
+		"ingress-controller/empty":  {"cookie-key": []byte("\n")},
+		"default/cookie":            {"cookie-key": []byte("rotated-key")},
+	}
+	testCases := []struct {
+		conf     map[string]string
+		expected string
+		logging  string
+	}{
+		// 0
+		{
+			conf: map[string]string{
+				ingtypes.GlobalCookieKey: "Ingress",
+			},
+			expected: "Ingress",
+		},
+		// 1
+		{
+			conf: map[string]string{
+				ingtypes.GlobalCookieKey:       "Ingress",
+				ingtypes.GlobalCookieKeySecret: "cookie",
+			},
+			expected: "s3cr3t",
+		},
+		// 2
+		{
+			conf: map[string]string{
+				ingtypes.GlobalCookieKey:       "Ingress",
+				ingtypes.GlobalCookieKeySecret: "default/cookie",
+			},
+			expected: "rotated-key",
+		},
+		// 3
+		{
+			conf: map[string]string{
+				ingtypes.GlobalCookieKey:       "Ingress",
+				ingtypes.GlobalCookieKeySecret: "empty",
+			},
+			expected: "Ingress",
+			logging:  `WARN ignoring cookie key secret 'empty': key should be a non empty sequence of letters, digits or any of '+/=_.:-'`,
+		},
+		// 4
+		{
+			conf: map[string]string{
+				ingtypes.GlobalCookieKey:       "Ingress",
+				ingtypes.GlobalCookieKeySecret: "notfound",
+			},
+			expected: "Ingress",
+			logging:  `WARN ignoring cookie key secret: secret not found: 'ingress-controller/notfound'`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.SecretContent = secrets
+		d := c.createGlobalData(test.conf)
+		c.createUpdater().buildGlobalCookieKey(d)
+		c.compareObjects("cookie key", i, d.global.Cookie.Key, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestModSecurity(t *testing.T) {
 	testCases := []struct {
 		endpoints string
@@ -237,6 +455,126 @@ func TestModSecurity(t *testing.T) {
 	}
 }
 
+func TestSPOEAgents(t *testing.T) {
+	msgReq := &hatypes.SPOEMessage{Name: "check-req", Event: "on-backend-http-request", Args: []string{"path", "ip=src"}}
+	msgRes := &hatypes.SPOEMessage{Name: "check-res", Event: "on-http-response"}
+	timeout := hatypes.ModSecurityTimeoutConfig{
+		Connect:    "5s",
+		Hello:      "100ms",
+		Idle:       "30s",
+		Processing: "1s",
+		Server:     "5s",
+	}
+	testCases := []struct {
+		config   map[string]string
+		expected hatypes.SPOEConfig
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			config: map[string]string{
+				ingtypes.GlobalSPOEMessages: "check-req=on-backend-http-request=path ip=src",
+				ingtypes.GlobalSPOEAgents:   "iprep=10.0.0.11:12345=check-req",
+			},
+			expected: hatypes.SPOEConfig{
+				Agents: []*hatypes.SPOEAgent{
+					{Name: "iprep", Endpoints: []string{"10.0.0.11:12345"}, Messages: []*hatypes.SPOEMessage{msgReq}},
+				},
+				Timeout: timeout,
+			},
+		},
+		// 2
+		{
+			config: map[string]string{
+				ingtypes.GlobalSPOEMessages: `
+check-req=on-backend-http-request=path ip=src
+check-res=on-http-response
+`,
+				ingtypes.GlobalSPOEAgents: `
+iprep=10.0.0.11:12345, 10.0.0.12:12345=check-req,check-res
+audit=agent.local:12345=check-res
+`,
+				ingtypes.GlobalSPOEFrontendAgents: "audit",
+			},
+			expected: hatypes.SPOEConfig{
+				Agents: []*hatypes.SPOEAgent{
+					{Name: "iprep", Endpoints: []string{"10.0.0.11:12345", "10.0.0.12:12345"}, Messages: []*hatypes.SPOEMessage{msgReq, msgRes}},
+					{Name: "audit", Endpoints: []string{"agent.local:12345"}, Messages: []*hatypes.SPOEMessage{msgRes}},
+				},
+				FrontendAgents: []string{"audit"},
+				Timeout:        timeout,
+			},
+		},
+		// 3
+		{
+			config: map[string]string{
+				ingtypes.GlobalSPOEMessages: `
+check-req
+check-req=on-request
+check-req=on-backend-http-request=path"
+check res=on-http-response
+check-res=on-http-response
+check-res=on-tcp-response
+`,
+				ingtypes.GlobalSPOEAgents: `
+iprep=10.0.0.11:12345
+ip-rep=10.0.0.11:12345=check-res
+modsecurity=10.0.0.11:12345=check-res
+iprep=10.0.0.11=check-res
+iprep=10.0.0.11:12345=check-req
+iprep==check-res
+iprep=10.0.0.11:12345=
+iprep=10.0.0.11:12345=check-res
+iprep=10.0.0.12:12345=check-res
+`,
+				ingtypes.GlobalSPOEFrontendAgents: "audit,iprep",
+			},
+			expected: hatypes.SPOEConfig{
+				Agents: []*hatypes.SPOEAgent{
+					{Name: "iprep", Endpoints: []string{"10.0.0.11:12345"}, Messages: []*hatypes.SPOEMessage{msgRes}},
+				},
+				FrontendAgents: []string{"iprep"},
+				Timeout:        timeout,
+			},
+			logging: `
+WARN ignoring spoe message 'check-req': expected name, event and args separated by '='
+WARN ignoring spoe message 'check-req=on-request': invalid event: on-request
+WARN ignoring spoe message 'check-req=on-backend-http-request=path"': invalid arg: path"
+WARN ignoring spoe message 'check res=on-http-response': invalid name: check res
+WARN ignoring spoe message 'check-res=on-tcp-response': duplicated name: check-res
+WARN ignoring spoe agent 'iprep=10.0.0.11:12345': expected name, endpoints and messages separated by '='
+WARN ignoring spoe agent 'ip-rep=10.0.0.11:12345=check-res': invalid name: ip-rep
+WARN ignoring spoe agent 'modsecurity=10.0.0.11:12345=check-res': name is reserved: modsecurity
+WARN ignoring spoe agent 'iprep=10.0.0.11=check-res': invalid endpoint: 10.0.0.11
+WARN ignoring spoe agent 'iprep=10.0.0.11:12345=check-req': message not found: check-req
+WARN ignoring spoe agent 'iprep==check-res': missing endpoint
+WARN ignoring spoe agent 'iprep=10.0.0.11:12345=': missing message
+WARN ignoring spoe agent 'iprep=10.0.0.12:12345=check-res': duplicated name: iprep
+WARN ignoring unknown spoe agent on frontends: audit`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		config := map[string]string{
+			ingtypes.GlobalSPOETimeoutConnect:    "5s",
+			ingtypes.GlobalSPOETimeoutHello:      "100ms",
+			ingtypes.GlobalSPOETimeoutIdle:       "30s",
+			ingtypes.GlobalSPOETimeoutProcessing: "1s",
+			ingtypes.GlobalSPOETimeoutServer:     "5s",
+		}
+		for key, value := range test.config {
+			config[key] = value
+		}
+		d := c.createGlobalData(config)
+		c.createUpdater().buildGlobalSPOE(d)
+		c.compareObjects("spoe", i, d.global.SPOE, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestDNS(t *testing.T) {
 	testCases := []struct {
 		config   map[string]string
@@ -327,6 +665,21 @@ k8s3=10.0.1.31:10053,10.0.1.32:10053,10.0.1.33:10053,
 				},
 			},
 		},
+		// 3
+		{
+			config: map[string]string{
+				ingtypes.GlobalDNSClusterDomain: "cluster.local",
+				ingtypes.GlobalDNSResolvers:     "kube-dns=",
+			},
+			expected: hatypes.DNSConfig{
+				ClusterDomain: "cluster.local",
+				Resolvers: []*hatypes.DNSResolver{
+					{
+						Name: "kube-dns",
+					},
+				},
+			},
+		},
 	}
 	for i, test := range testCases {
 		c := setup(t)
@@ -338,131 +691,487 @@ k8s3=10.0.1.31:10053,10.0.1.32:10053,10.0.1.33:10053,
 	}
 }
 
-func TestDynamic(t *testing.T) {
+func TestLogForward(t *testing.T) {
 	testCases := []struct {
-		config        map[string]string
-		staticSecrets bool
-		expected      convtypes.DynamicConfig
-		logging       string
+		config   map[string]string
+		expected []*hatypes.LogForward
+		logging  string
 	}{
 		// 0
 		{
-			config: map[string]string{
-				ingtypes.GlobalCrossNamespaceSecretsCA: "error",
-			},
-			logging: `
-WARN ignoring invalid value 'error' on global 'cross-namespace-secrets-ca', using 'deny'
-`,
+			config: map[string]string{},
 		},
 		// 1
 		{
 			config: map[string]string{
-				ingtypes.GlobalCrossNamespaceSecretsCA:  "allow",
-				ingtypes.GlobalCrossNamespaceSecretsCrt: "fail",
+				ingtypes.GlobalLogForward: "syslog=514",
 			},
-			expected: convtypes.DynamicConfig{
-				CrossNamespaceSecretCA: true,
-			},
-			logging: `
-WARN ignoring invalid value 'fail' on global 'cross-namespace-secrets-crt', using 'deny'
-`,
+			logging: `WARN ignoring log forward 'syslog=514': expected name, binds and targets separated by '='`,
 		},
 		// 2
 		{
 			config: map[string]string{
-				ingtypes.GlobalCrossNamespaceSecretsCA:  "deny",
-				ingtypes.GlobalCrossNamespaceSecretsCrt: "allow",
-			},
-			expected: convtypes.DynamicConfig{
-				CrossNamespaceSecretCertificate: true,
+				ingtypes.GlobalLogForward: "sys log=514=udp@10.0.0.10:514",
 			},
+			logging: `WARN ignoring log forward 'sys log=514=udp@10.0.0.10:514': invalid name: sys log`,
 		},
 		// 3
 		{
 			config: map[string]string{
-				ingtypes.GlobalCrossNamespaceSecretsCA:     "allow",
-				ingtypes.GlobalCrossNamespaceSecretsCrt:    "allow",
-				ingtypes.GlobalCrossNamespaceSecretsPasswd: "allow",
-				ingtypes.GlobalCrossNamespaceServices:      "allow",
-			},
-			expected: convtypes.DynamicConfig{
-				CrossNamespaceSecretCA:          true,
-				CrossNamespaceSecretCertificate: true,
-				CrossNamespaceSecretPasswd:      true,
-				CrossNamespaceServices:          true,
+				ingtypes.GlobalLogForward: "syslog=sctp:514=udp@10.0.0.10:514",
 			},
+			logging: `WARN ignoring log forward 'syslog=sctp:514=udp@10.0.0.10:514': invalid bind: sctp:514`,
 		},
 		// 4
 		{
 			config: map[string]string{
-				ingtypes.GlobalCrossNamespaceSecretsCA: "allow",
+				ingtypes.GlobalLogForward: "syslog=514=",
 			},
-			staticSecrets: true,
-			expected: convtypes.DynamicConfig{
-				CrossNamespaceSecretCA:          true,
-				CrossNamespaceSecretCertificate: true,
-				CrossNamespaceSecretPasswd:      true,
-				StaticCrossNamespaceSecrets:     true,
+			logging: `WARN ignoring log forward 'syslog=514=': missing target`,
+		},
+		// 5
+		{
+			config: map[string]string{
+				ingtypes.GlobalLogForward: "syslog==udp@10.0.0.10:514",
+			},
+			logging: `WARN ignoring log forward 'syslog==udp@10.0.0.10:514': missing bind`,
+		},
+		// 6
+		{
+			config: map[string]string{
+				ingtypes.GlobalBindIPAddrTCP: "10.0.0.1",
+				ingtypes.GlobalLogForward: `
+syslog=514,tcp:601=udp@10.0.0.10:514,tcp@10.0.0.11:601
+
+audit=udp:127.0.0.1:1514,tcp:[::1]:1601,=udp@10.0.0.12:514,
+`,
+			},
+			expected: []*hatypes.LogForward{
+				{
+					Name:       "syslog",
+					DgramBinds: []string{"udp@10.0.0.1:514"},
+					Binds:      []string{"10.0.0.1:601"},
+					Targets:    []string{"udp@10.0.0.10:514", "tcp@10.0.0.11:601"},
+				},
+				{
+					Name:       "audit",
+					DgramBinds: []string{"udp@127.0.0.1:1514"},
+					Binds:      []string{"[::1]:1601"},
+					Targets:    []string{"udp@10.0.0.12:514"},
+				},
 			},
 		},
 	}
 	for i, test := range testCases {
 		c := setup(t)
 		d := c.createGlobalData(test.config)
-		u := c.createUpdater()
-		u.options.DynamicConfig.StaticCrossNamespaceSecrets = test.staticSecrets
-		u.buildGlobalDynamic(d)
-		if !reflect.DeepEqual(*u.options.DynamicConfig, test.expected) {
-			c.compareObjects("dynamic", i, *u.options.DynamicConfig, test.expected)
-		}
+		c.createUpdater().buildGlobalBind(d)
+		c.createUpdater().buildGlobalLogForward(d)
+		c.compareObjects("log forward", i, d.global.LogForward, test.expected)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
 }
 
-func TestForwardFor(t *testing.T) {
+func TestLogRing(t *testing.T) {
 	testCases := []struct {
-		conf     string
-		expected string
+		config   map[string]string
+		expected []*hatypes.LogRing
+		syslog   string
 		logging  string
 	}{
 		// 0
 		{
-			conf:     "",
-			expected: "add",
-			logging:  "",
+			config: map[string]string{},
 		},
 		// 1
 		{
-			conf:     "non",
-			expected: "add",
-			logging:  "WARN Invalid forwardfor value option on configmap: 'non'. Using 'add' instead",
+			config: map[string]string{
+				ingtypes.GlobalLogRing: "logs",
+			},
+			logging: `WARN ignoring log ring 'logs': expected name and servers separated by '='`,
 		},
 		// 2
 		{
-			conf:     "add",
-			expected: "add",
-			logging:  "",
+			config: map[string]string{
+				ingtypes.GlobalLogRing: "my logs=10.0.0.10:6514",
+			},
+			logging: `WARN ignoring log ring 'my logs=10.0.0.10:6514': invalid name: my logs`,
 		},
 		// 3
 		{
-			conf:     "ignore",
-			expected: "ignore",
-			logging:  "",
+			config: map[string]string{
+				ingtypes.GlobalLogRing: "logs=10.0.0.10",
+			},
+			logging: `WARN ignoring log ring 'logs=10.0.0.10': invalid server: 10.0.0.10`,
 		},
 		// 4
 		{
-			conf:     "ifmissing",
-			expected: "ifmissing",
-			logging:  "",
+			config: map[string]string{
+				ingtypes.GlobalLogRing: "logs=,",
+			},
+			logging: `WARN ignoring log ring 'logs=,': missing server`,
 		},
 		// 5
 		{
-			conf:     "update",
-			expected: "update",
-			logging:  "",
-		},
-	}
+			config: map[string]string{
+				ingtypes.GlobalLogRing:     "logs=10.0.0.10:6514,logs.local:601",
+				ingtypes.GlobalLogRingSize: "1048576",
+			},
+			expected: []*hatypes.LogRing{
+				{
+					Name: "logs",
+					Servers: []*hatypes.LogRingServer{
+						{Name: "srv01", Endpoint: "10.0.0.10:6514"},
+						{Name: "srv02", Endpoint: "logs.local:601"},
+					},
+					Size: 1048576,
+				},
+			},
+		},
+		// 6
+		{
+			config: map[string]string{
+				ingtypes.GlobalLogRing: `
+logs=10.0.0.10:6514
+logs=10.0.0.11:6514
+
+audit=[::1]:601,
+`,
+				ingtypes.GlobalSyslogEndpoint: "ring@logs",
+			},
+			expected: []*hatypes.LogRing{
+				{
+					Name: "logs",
+					Servers: []*hatypes.LogRingServer{
+						{Name: "srv01", Endpoint: "10.0.0.10:6514"},
+					},
+				},
+				{
+					Name: "audit",
+					Servers: []*hatypes.LogRingServer{
+						{Name: "srv01", Endpoint: "[::1]:601"},
+					},
+				},
+			},
+			syslog:  "ring@logs",
+			logging: `WARN ignoring log ring 'logs=10.0.0.11:6514': name 'logs' is already in use`,
+		},
+		// 7
+		{
+			config: map[string]string{
+				ingtypes.GlobalSyslogEndpoint: "ring@logs",
+			},
+			logging: `WARN ignoring syslog endpoint 'ring@logs': log ring was not declared`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(test.config)
+		c.createUpdater().buildGlobalLogRing(d)
+		c.createUpdater().buildGlobalSyslog(d)
+		c.compareObjects("log ring", i, d.global.LogRings, test.expected)
+		c.compareObjects("syslog endpoint", i, d.global.Syslog.Endpoint, test.syslog)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestDynamic(t *testing.T) {
+	testCases := []struct {
+		config        map[string]string
+		staticSecrets bool
+		expected      convtypes.DynamicConfig
+		logging       string
+	}{
+		// 0
+		{
+			config: map[string]string{
+				ingtypes.GlobalCrossNamespaceSecretsCA: "error",
+			},
+			logging: `
+WARN ignoring invalid value 'error' on global 'cross-namespace-secrets-ca', using 'deny'
+`,
+		},
+		// 1
+		{
+			config: map[string]string{
+				ingtypes.GlobalCrossNamespaceSecretsCA:  "allow",
+				ingtypes.GlobalCrossNamespaceSecretsCrt: "fail",
+			},
+			expected: convtypes.DynamicConfig{
+				CrossNamespaceSecretCA: true,
+			},
+			logging: `
+WARN ignoring invalid value 'fail' on global 'cross-namespace-secrets-crt', using 'deny'
+`,
+		},
+		// 2
+		{
+			config: map[string]string{
+				ingtypes.GlobalCrossNamespaceSecretsCA:  "deny",
+				ingtypes.GlobalCrossNamespaceSecretsCrt: "allow",
+			},
+			expected: convtypes.DynamicConfig{
+				CrossNamespaceSecretCertificate: true,
+			},
+		},
+		// 3
+		{
+			config: map[string]string{
+				ingtypes.GlobalCrossNamespaceConfigMaps:    "allow",
+				ingtypes.GlobalCrossNamespaceSecretsCA:     "allow",
+				ingtypes.GlobalCrossNamespaceSecretsCrt:    "allow",
+				ingtypes.GlobalCrossNamespaceSecretsPasswd: "allow",
+				ingtypes.GlobalCrossNamespaceServices:      "allow",
+			},
+			expected: convtypes.DynamicConfig{
+				CrossNamespaceConfigMaps:        true,
+				CrossNamespaceSecretCA:          true,
+				CrossNamespaceSecretCertificate: true,
+				CrossNamespaceSecretPasswd:      true,
+				CrossNamespaceServices:          true,
+			},
+		},
+		// 4
+		{
+			config: map[string]string{
+				ingtypes.GlobalCrossNamespaceSecretsCA: "allow",
+			},
+			staticSecrets: true,
+			expected: convtypes.DynamicConfig{
+				CrossNamespaceSecretCA:          true,
+				CrossNamespaceSecretCertificate: true,
+				CrossNamespaceSecretPasswd:      true,
+				StaticCrossNamespaceSecrets:     true,
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(test.config)
+		u := c.createUpdater()
+		u.options.DynamicConfig.StaticCrossNamespaceSecrets = test.staticSecrets
+		u.buildGlobalDynamic(d)
+		if !reflect.DeepEqual(*u.options.DynamicConfig, test.expected) {
+			c.compareObjects("dynamic", i, *u.options.DynamicConfig, test.expected)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestExtraFrontends(t *testing.T) {
+	testCases := []struct {
+		config   string
+		expected []*hatypes.ExtraFrontend
+		logging  string
+	}{
+		// 0
+		{
+			config: "",
+		},
+		// 1
+		{
+			config:  "internal=:8080",
+			logging: `WARN ignoring extra frontend 'internal=:8080': expected name, http bind, https bind and optional default certificate separated by '='`,
+		},
+		// 2
+		{
+			config:  "Internal=:8080=:8443",
+			logging: `WARN ignoring extra frontend 'Internal=:8080=:8443': invalid name: Internal`,
+		},
+		// 3
+		{
+			config:  "internal==",
+			logging: `WARN ignoring extra frontend 'internal==': missing bind`,
+		},
+		// 4
+		{
+			config:  `internal=:8080 name "http"=:8443`,
+			logging: `WARN ignoring extra frontend 'internal=:8080 name "http"=:8443': invalid bind: :8080 name "http"`,
+		},
+		// 5
+		{
+			config: `
+internal=:8080=:8443
+internal=:9080=:9443
+`,
+			expected: []*hatypes.ExtraFrontend{
+				{Name: "internal", HTTPBind: ":8080", HTTPSBind: ":8443"},
+			},
+			logging: `WARN ignoring extra frontend 'internal=:9080=:9443': name 'internal' is already in use`,
+		},
+		// 6
+		{
+			config: `
+internal=127.0.0.1:8080=127.0.0.1:8443 interface eth1=system/ingress-default
+tls-only==:9443=system/missing
+`,
+			expected: []*hatypes.ExtraFrontend{
+				{
+					Name:           "internal",
+					HTTPBind:       "127.0.0.1:8080",
+					HTTPSBind:      "127.0.0.1:8443 interface eth1",
+					DefaultCrtFile: "/tls/tls-default.pem",
+					DefaultCrtHash: "2bca2d9ed30b7d0c7230d504d91e8c9704a0501c",
+				},
+				{Name: "tls-only", HTTPSBind: ":9443"},
+			},
+			logging: `WARN using default certificate on extra frontend 'tls-only': secret not found: 'system/missing'`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(map[string]string{ingtypes.GlobalExtraFrontends: test.config})
+		c.createUpdater().buildGlobalExtraFrontends(d)
+		c.compareObjects("extra frontends", i, c.haproxy.Frontend().Extras, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestExtraFrontendsSyslog(t *testing.T) {
+	testCases := []struct {
+		config   map[string]string
+		expected []*hatypes.ExtraFrontend
+		logging  string
+	}{
+		// 0
+		{
+			config: map[string]string{},
+		},
+		// 1
+		{
+			config: map[string]string{
+				ingtypes.GlobalExtraFrontendsSyslog: "internal=10.0.0.10:514",
+			},
+			expected: []*hatypes.ExtraFrontend{
+				{Name: "internal", HTTPBind: ":8080", HTTPSBind: ":8443", SyslogEndpoint: "10.0.0.10:514"},
+			},
+		},
+		// 2
+		{
+			config: map[string]string{
+				ingtypes.GlobalExtraFrontendsSyslog: "internal=10.0.0.10:514=%ci:%cp [%tr] %ft host=%[var(req.host)]",
+			},
+			expected: []*hatypes.ExtraFrontend{
+				{Name: "internal", HTTPBind: ":8080", HTTPSBind: ":8443", SyslogEndpoint: "10.0.0.10:514", HTTPLogFormat: "%ci:%cp [%tr] %ft host=%[var(req.host)]"},
+			},
+		},
+		// 3
+		{
+			config: map[string]string{
+				ingtypes.GlobalExtraFrontendsSyslog: "internal==%ci %ft",
+			},
+			logging: `WARN ignoring extra frontend syslog 'internal==%ci %ft': log format requires a syslog endpoint`,
+		},
+		// 4
+		{
+			config: map[string]string{
+				ingtypes.GlobalSyslogEndpoint:       "127.0.0.1:1514",
+				ingtypes.GlobalExtraFrontendsSyslog: "internal==%ci %ft",
+			},
+			expected: []*hatypes.ExtraFrontend{
+				{Name: "internal", HTTPBind: ":8080", HTTPSBind: ":8443", HTTPLogFormat: "%ci %ft"},
+			},
+		},
+		// 5
+		{
+			config: map[string]string{
+				ingtypes.GlobalExtraFrontendsSyslog: `
+internal
+external=10.0.0.10:514
+internal=
+internal=10.0.0.10:514 len 2048
+internal=ring@logs
+`,
+			},
+			logging: `
+WARN ignoring extra frontend syslog 'internal': expected name, syslog endpoint and optional log format separated by '='
+WARN ignoring extra frontend syslog 'external=10.0.0.10:514': extra frontend 'external' was not declared
+WARN ignoring extra frontend syslog 'internal=': missing syslog endpoint and log format
+WARN ignoring extra frontend syslog 'internal=10.0.0.10:514 len 2048': invalid syslog endpoint: 10.0.0.10:514 len 2048
+WARN ignoring extra frontend syslog 'internal=ring@logs': log ring was not declared: logs
+`,
+		},
+		// 6
+		{
+			config: map[string]string{
+				ingtypes.GlobalLogRing: "logs=10.0.0.10:6514",
+				ingtypes.GlobalExtraFrontendsSyslog: `
+internal=ring@logs
+internal=10.0.0.10:514
+`,
+			},
+			expected: []*hatypes.ExtraFrontend{
+				{Name: "internal", HTTPBind: ":8080", HTTPSBind: ":8443", SyslogEndpoint: "ring@logs"},
+			},
+			logging: `WARN ignoring extra frontend syslog 'internal=10.0.0.10:514': syslog of extra frontend 'internal' was already declared`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		test.config[ingtypes.GlobalExtraFrontends] = "internal=:8080=:8443"
+		d := c.createGlobalData(test.config)
+		u := c.createUpdater()
+		u.buildGlobalExtraFrontends(d)
+		u.buildGlobalLogRing(d)
+		u.buildGlobalSyslog(d)
+		if test.expected == nil {
+			test.expected = []*hatypes.ExtraFrontend{
+				{Name: "internal", HTTPBind: ":8080", HTTPSBind: ":8443"},
+			}
+		}
+		c.compareObjects("extra frontends syslog", i, c.haproxy.Frontend().Extras, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestForwardFor(t *testing.T) {
+	testCases := []struct {
+		conf     string
+		expected string
+		logging  string
+	}{
+		// 0
+		{
+			conf:     "",
+			expected: "add",
+			logging:  "",
+		},
+		// 1
+		{
+			conf:     "non",
+			expected: "add",
+			logging:  "WARN Invalid forwardfor value option on configmap: 'non'. Using 'add' instead",
+		},
+		// 2
+		{
+			conf:     "add",
+			expected: "add",
+			logging:  "",
+		},
+		// 3
+		{
+			conf:     "ignore",
+			expected: "ignore",
+			logging:  "",
+		},
+		// 4
+		{
+			conf:     "ifmissing",
+			expected: "ifmissing",
+			logging:  "",
+		},
+		// 5
+		{
+			conf:     "update",
+			expected: "update",
+			logging:  "",
+		},
+	}
 	for i, test := range testCases {
 		c := setup(t)
 		d := c.createGlobalData(map[string]string{ingtypes.GlobalForwardfor: test.conf})
@@ -473,6 +1182,186 @@ func TestForwardFor(t *testing.T) {
 	}
 }
 
+func TestPrometheus(t *testing.T) {
+	testCases := []struct {
+		scope         string
+		serverMetrics string
+		expected      []string
+		logging       string
+	}{
+		// 0
+		{
+			serverMetrics: "true",
+		},
+		// 1
+		{
+			serverMetrics: "false",
+			expected:      []string{"global", "frontend", "backend"},
+		},
+		// 2
+		{
+			scope:         "backend, frontend",
+			serverMetrics: "true",
+			expected:      []string{"frontend", "backend"},
+		},
+		// 3
+		{
+			scope:         "server,backend",
+			serverMetrics: "false",
+			expected:      []string{"backend"},
+		},
+		// 4
+		{
+			scope:         "frontend,listener",
+			serverMetrics: "true",
+			expected:      []string{"frontend"},
+			logging:       `WARN ignoring invalid prometheus scope: listener`,
+		},
+		// 5
+		{
+			scope:         "listener",
+			serverMetrics: "true",
+			logging:       `WARN ignoring invalid prometheus scope: listener`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(map[string]string{
+			ingtypes.GlobalPrometheusPort:          "9105",
+			ingtypes.GlobalPrometheusScope:         test.scope,
+			ingtypes.GlobalPrometheusServerMetrics: test.serverMetrics,
+		})
+		c.createUpdater().buildGlobalPrometheus(d)
+		expected := hatypes.PromConfig{Port: 9105, Scopes: test.expected}
+		c.compareObjects("prometheus", i, d.global.Prometheus, expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestGeoIP(t *testing.T) {
+	testCases := []struct {
+		conf     map[string]string
+		expected hatypes.GeoIPConfig
+		logging  string
+	}{
+		// 0
+		{
+			conf: map[string]string{
+				ingtypes.GlobalGeoIPDatabaseFormat: "maxmind",
+			},
+		},
+		// 1
+		{
+			conf: map[string]string{
+				ingtypes.GlobalGeoIPDatabase:       "/var/lib/geoip",
+				ingtypes.GlobalGeoIPDatabaseFormat: "maxmind",
+			},
+			expected: hatypes.GeoIPConfig{
+				Database: "/var/lib/geoip",
+				Format:   "maxmind",
+			},
+		},
+		// 2
+		{
+			conf: map[string]string{
+				ingtypes.GlobalGeoIPCountryHeader:  "X-Country-Code",
+				ingtypes.GlobalGeoIPDatabase:       "/var/lib/geoip/IP2LOCATION-LITE-DB1.CSV",
+				ingtypes.GlobalGeoIPDatabaseFormat: "ip2location",
+			},
+			expected: hatypes.GeoIPConfig{
+				CountryHeader: "X-Country-Code",
+				Database:      "/var/lib/geoip/IP2LOCATION-LITE-DB1.CSV",
+				Format:        "ip2location",
+			},
+		},
+		// 3
+		{
+			conf: map[string]string{
+				ingtypes.GlobalGeoIPDatabase:       "/var/lib/geoip",
+				ingtypes.GlobalGeoIPDatabaseFormat: "dbip",
+			},
+			logging: `WARN ignoring GeoIP database, unsupported format: dbip`,
+		},
+		// 4
+		{
+			conf: map[string]string{
+				ingtypes.GlobalGeoIPCountryHeader:  "X-Country Code",
+				ingtypes.GlobalGeoIPDatabase:       "/var/lib/geoip",
+				ingtypes.GlobalGeoIPDatabaseFormat: "maxmind",
+			},
+			expected: hatypes.GeoIPConfig{
+				Database: "/var/lib/geoip",
+				Format:   "maxmind",
+			},
+			logging: `WARN ignoring invalid GeoIP country header name: X-Country Code`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(test.conf)
+		c.createUpdater().buildGlobalGeoIP(d)
+		c.compareObjects("geoip", i, d.global.GeoIP, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestTracing(t *testing.T) {
+	testCases := []struct {
+		conf     map[string]string
+		expected hatypes.TracingConfig
+		logging  string
+	}{
+		// 0
+		{
+			conf: map[string]string{
+				ingtypes.GlobalUniqueIDHeader: "X-Request-ID",
+			},
+		},
+		// 1
+		{
+			conf: map[string]string{
+				ingtypes.GlobalTraceContext: "true",
+			},
+			expected: hatypes.TracingConfig{
+				TraceContext: true,
+			},
+		},
+		// 2
+		{
+			conf: map[string]string{
+				ingtypes.GlobalUniqueIDFormat: "%[uuid]",
+				ingtypes.GlobalUniqueIDHeader: "X-Request-ID",
+			},
+			expected: hatypes.TracingConfig{
+				UniqueIDFormat: "%[uuid]",
+				UniqueIDHeader: "X-Request-ID",
+			},
+		},
+		// 3
+		{
+			conf: map[string]string{
+				ingtypes.GlobalTraceContext:   "true",
+				ingtypes.GlobalUniqueIDFormat: "%[uuid]",
+				ingtypes.GlobalUniqueIDHeader: "X-Request ID",
+			},
+			expected: hatypes.TracingConfig{
+				TraceContext: true,
+			},
+			logging: `WARN ignoring unique-id-format, invalid unique-id-header name: X-Request ID`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(test.conf)
+		c.createUpdater().buildGlobalTracing(d)
+		c.compareObjects("tracing", i, d.global.Tracing, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestFrontingProxy(t *testing.T) {
 	testCases := []struct {
 		ann      map[string]string
@@ -581,6 +1470,74 @@ func TestDisableCpuMap(t *testing.T) {
 	}
 }
 
+func TestNbthread(t *testing.T) {
+	testCases := []struct {
+		ann           map[string]string
+		availableCPUs int
+		expThreads    int
+		expCPUMap     string
+		logging       string
+	}{
+		// 0
+		{
+			ann: map[string]string{
+				ingtypes.GlobalNbthread: "4",
+			},
+			availableCPUs: 2,
+			expThreads:    4,
+			expCPUMap:     "auto:1/1-4 0-3",
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.GlobalNbthread: "auto",
+			},
+			availableCPUs: 3,
+			expThreads:    3,
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.GlobalNbthread: "auto",
+				ingtypes.GlobalCPUMap:   "auto:1/1-3 2-4",
+			},
+			availableCPUs: 3,
+			expThreads:    3,
+			expCPUMap:     "auto:1/1-3 2-4",
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.GlobalNbthread: "auto",
+			},
+			expThreads: 1,
+			logging:    `WARN invalid value of nbthread configmap option (0), using 1`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.GlobalNbthread: "none",
+			},
+			availableCPUs: 3,
+			expThreads:    1,
+			logging:       `WARN invalid value of nbthread configmap option (0), using 1`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		test.ann[ingtypes.GlobalNbprocBalance] = "1"
+		test.ann[ingtypes.GlobalUseCPUMap] = "true"
+		d := c.createGlobalData(test.ann)
+		u := c.createUpdater()
+		u.options.AvailableCPUs = test.availableCPUs
+		u.buildGlobalProc(d)
+		c.compareObjects("nbthread", i, d.global.Procs.Nbthread, test.expThreads)
+		c.compareObjects("cpu map", i, d.global.Procs.CPUMap, test.expCPUMap)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestPathTypeOrder(t *testing.T) {
 	testCases := []struct {
 		order    string
@@ -627,6 +1584,135 @@ func TestPathTypeOrder(t *testing.T) {
 	}
 }
 
+func TestPeers(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		selector map[string]string
+		noPod    bool
+		expected hatypes.PeersConfig
+		logging  string
+	}{
+		// 0
+		{
+			ann:      map[string]string{},
+			expected: hatypes.PeersConfig{},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.GlobalPeersPort: "70000",
+			},
+			expected: hatypes.PeersConfig{},
+			logging:  `WARN ignoring invalid peers port: 70000`,
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.GlobalPeersPort: "10000",
+			},
+			expected: hatypes.PeersConfig{
+				LocalPeer: "ingress-controller-0",
+				Port:      10000,
+			},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.GlobalPeersPort:    "10000",
+				ingtypes.GlobalPeersService: "notfound",
+			},
+			expected: hatypes.PeersConfig{
+				LocalPeer: "ingress-controller-0",
+				Port:      10000,
+			},
+			logging: `WARN ignoring peers service: service not found: 'notfound'`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.GlobalPeersPort:    "10000",
+				ingtypes.GlobalPeersService: "ingress-peers",
+			},
+			expected: hatypes.PeersConfig{
+				LocalPeer: "ingress-controller-0",
+				Port:      10000,
+				Servers: []*hatypes.PeersServer{
+					{Name: "10.0.0.13", Endpoint: "10.0.0.13:10000"},
+					{Name: "ingress-controller-1", Endpoint: "10.0.0.12:10000"},
+					{Name: "ingress-controller-2", Endpoint: "10.0.0.11:10000"},
+				},
+			},
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.GlobalPeersPort: "10000",
+			},
+			selector: map[string]string{"app": "other"},
+			expected: hatypes.PeersConfig{
+				LocalPeer: "ingress-controller-0",
+				Port:      10000,
+			},
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.GlobalPeersPort: "10000",
+			},
+			selector: map[string]string{"app": "ingress"},
+			expected: hatypes.PeersConfig{
+				LocalPeer: "ingress-controller-0",
+				Port:      10000,
+				Servers: []*hatypes.PeersServer{
+					{Name: "10.0.0.13", Endpoint: "10.0.0.13:10000"},
+					{Name: "ingress-controller-1", Endpoint: "10.0.0.12:10000"},
+					{Name: "ingress-controller-2", Endpoint: "10.0.0.11:10000"},
+				},
+			},
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.GlobalPeersPort: "10000",
+			},
+			selector: map[string]string{"app": "ingress"},
+			noPod:    true,
+			expected: hatypes.PeersConfig{
+				LocalPeer: "ingress-controller-0",
+				Port:      10000,
+			},
+			logging: `WARN ignoring peers auto discovery: pod not found: 'ingress-controller/ingress-controller-0'`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		if !test.noPod {
+			c.cache.PodList = map[string]*api.Pod{
+				"ingress-controller/ingress-controller-0": {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "ingress-controller-0",
+						Namespace: "ingress-controller",
+						Labels:    map[string]string{"app": "ingress"},
+					},
+				},
+			}
+		}
+		svc, ep := conv_helper.CreateService("ingress-controller/ingress-peers", "10000", "10.0.0.10,10.0.0.11,10.0.0.12,10.0.0.13")
+		svc.Spec.Selector = test.selector
+		ep.Endpoints[0].TargetRef.Name = "ingress-controller-0"
+		ep.Endpoints[1].TargetRef.Name = "ingress-controller-2"
+		ep.Endpoints[2].TargetRef.Name = "ingress-controller-1"
+		ep.Endpoints[3].TargetRef = nil
+		c.cache.SvcList = append(c.cache.SvcList, svc)
+		c.cache.EpList["ingress-controller/ingress-peers"] = ep
+		d := c.createGlobalData(test.ann)
+		c.createUpdater().buildGlobalPeers(d)
+		c.compareObjects("peers", i, d.global.Peers, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestSecurity(t *testing.T) {
 	testCases := []struct {
 		ann      map[string]string
@@ -17,8 +17,17 @@ limitations under the License.
 package annotations
 
 import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	ingutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/utils"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 )
 
 func (c *updater) buildHostAuthTLS(d *hostData) {
@@ -42,6 +51,7 @@ func (c *updater) buildHostAuthTLS(d *hostData) {
 		tls.CRLHash = crlfile.SHA1Hash
 	} else {
 		c.logger.Error("error building TLS auth config on %s: %v", tlsSecret.Source, err)
+		tlsSecret.Source.RecordWarning(c.cache, "InvalidSecret", fmt.Sprintf("TLS authentication was ignored: %v", err))
 	}
 	if tls.CAFilename == "" && d.mapper.Get(ingtypes.HostAuthTLSStrict).Bool() {
 		// Here we have a misconfigured auth-tls and auth-tls-strict as `true`.
@@ -71,6 +81,145 @@ func (c *updater) buildHostCertSigner(d *hostData) {
 	// just the warnings, ingress.syncIngress() has already added the domains
 }
 
+func (c *updater) buildHostFrontend(d *hostData) {
+	frontend := d.mapper.Get(ingtypes.HostFrontend)
+	if frontend.Value == "" {
+		return
+	}
+	if c.haproxy.Frontend().FindExtra(frontend.Value) == nil {
+		c.logger.Warn("ignoring frontend on %v: extra frontend '%s' was not declared", frontend.Source, frontend.Value)
+		return
+	}
+	if d.mapper.Get(ingtypes.HostSSLPassthrough).Bool() {
+		c.logger.Warn("ignoring frontend on %v: ssl-passthrough is only supported in the default frontend", frontend.Source)
+		return
+	}
+	d.host.Frontend = frontend.Value
+}
+
+// TODO Move all magic strings to a single place
+const systemCAFile = "/etc/ssl/certs/ca-certificates.crt"
+
+var (
+	oidcCredentialRegex = regexp.MustCompile(`^[!-~]+$`)
+	oidcPathRegex       = regexp.MustCompile(`^/[!-~]*$`)
+	oidcScopeRegex      = regexp.MustCompile(`^[A-Za-z0-9_.:/-]+$`)
+)
+
+func (c *updater) buildHostOIDC(d *hostData) {
+	issuer := d.mapper.Get(ingtypes.HostOIDCIssuer)
+	if issuer.Value == "" {
+		return
+	}
+	external := c.haproxy.Global().External
+	if external.IsExternal() && !external.HasLua {
+		c.logger.Warn("OIDC authentication on %v needs Lua json module, install lua-json4 and enable 'external-has-lua' global config", issuer.Source)
+		return
+	}
+	if !validURLRegex.MatchString(issuer.Value) {
+		c.logger.Warn("ignoring invalid OIDC issuer on %v: %s", issuer.Source, issuer.Value)
+		return
+	}
+	urlProto, urlHost, urlPort, _, err := ingutils.ParseURL(issuer.Value)
+	if err != nil {
+		c.logger.Warn("ignoring OIDC issuer on %v: %v", issuer.Source, err)
+		return
+	}
+	if urlProto != "https" {
+		c.logger.Warn("ignoring OIDC issuer on %v: issuer must use https: %s", issuer.Source, issuer.Value)
+		return
+	}
+	secret := d.mapper.Get(ingtypes.HostOIDCSecret)
+	if secret.Value == "" {
+		c.logger.Warn("ignoring OIDC issuer on %v: missing '%s' configuration", issuer.Source, ingtypes.HostOIDCSecret)
+		return
+	}
+	credentials := make(map[string]string, 3)
+	for _, key := range []string{"client-id", "client-secret", "cookie-secret"} {
+		content, err := c.cache.GetSecretContent(
+			secret.Source.Namespace,
+			secret.Value,
+			key,
+			convtypes.TrackingTarget{Hostname: d.host.Hostname},
+		)
+		if err != nil {
+			c.logger.Error("error reading OIDC credentials on %v: %v", secret.Source, err)
+			secret.Source.RecordWarning(c.cache, "InvalidSecret", fmt.Sprintf("OIDC authentication was ignored: %v", err))
+			return
+		}
+		value := strings.TrimSpace(string(content))
+		if !oidcCredentialRegex.MatchString(value) {
+			c.logger.Warn("ignoring OIDC issuer on %v: key '%s' of secret '%s' is empty or has invalid chars", issuer.Source, key, secret.Value)
+			return
+		}
+		credentials[key] = value
+	}
+	if len(credentials["cookie-secret"]) < 16 {
+		c.logger.Warn("ignoring OIDC issuer on %v: cookie-secret of secret '%s' should have at least 16 bytes", issuer.Source, secret.Value)
+		return
+	}
+	callback := d.mapper.Get(ingtypes.HostOIDCCallbackPath)
+	if !oidcPathRegex.MatchString(callback.Value) {
+		c.logger.Warn("ignoring OIDC issuer on %v: invalid callback path: %s", callback.Source, callback.Value)
+		return
+	}
+	var scopes []string
+	hasOpenID := false
+	s := d.mapper.Get(ingtypes.HostOIDCScopes)
+	for _, scope := range strings.Split(s.Value, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+		if !oidcScopeRegex.MatchString(scope) {
+			c.logger.Warn("ignoring invalid OIDC scope on %v: %s", s.Source, scope)
+			continue
+		}
+		hasOpenID = hasOpenID || scope == "openid"
+		scopes = append(scopes, scope)
+	}
+	if !hasOpenID {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	var ipList []string
+	var hostname string
+	if net.ParseIP(urlHost) != nil {
+		ipList = []string{urlHost}
+	} else {
+		if ipList, err = lookupHost(urlHost); err != nil {
+			c.logger.Warn("ignoring OIDC issuer with an invalid domain on %v: %v", issuer.Source, err)
+			return
+		}
+		hostname = urlHost
+	}
+	port, _ := strconv.Atoi(urlPort)
+	if port == 0 {
+		port = 443
+	}
+	backend := c.haproxy.Backends().AcquireAuthBackend(ipList, port, hostname)
+	backend.Server.Secure = true
+	backend.Server.CAFilename = systemCAFile
+	if hostname != "" {
+		backend.Server.SNI = fmt.Sprintf("str(%s)", hostname)
+		backend.Server.VerifyHost = hostname
+	}
+	authBackendName, err := c.acquireAuthBackendName(backend.BackendID())
+	if err != nil {
+		c.logger.Warn("ignoring OIDC issuer on %v: %v", issuer.Source, err)
+		return
+	}
+	d.host.OIDC = hatypes.HostOIDCConfig{
+		AuthBackendName: authBackendName,
+		CallbackPath:    callback.Value,
+		ClientID:        credentials["client-id"],
+		ClientSecret:    credentials["client-secret"],
+		CookieSecret:    base64.StdEncoding.EncodeToString([]byte(credentials["cookie-secret"])),
+		Issuer:          strings.TrimRight(issuer.Value, "/"),
+		Scopes:          strings.Join(scopes, "+"),
+	}
+}
+
 func (c *updater) buildHostRedirect(d *hostData) {
 	// TODO need a host<->host tracking if a target is found
 	redir := d.mapper.Get(ingtypes.HostRedirectFrom)
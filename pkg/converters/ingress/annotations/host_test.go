@@ -17,8 +17,10 @@ limitations under the License.
 package annotations
 
 import (
+	"fmt"
 	"testing"
 
+	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 )
@@ -317,3 +319,190 @@ func TestTLSConfig(t *testing.T) {
 		c.teardown()
 	}
 }
+
+func TestHostFrontend(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		expected string
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.HostFrontend: "internal",
+			},
+			expected: "internal",
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.HostFrontend: "public",
+			},
+			logging: `WARN ignoring frontend on ingress 'default/ing1': extra frontend 'public' was not declared`,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.HostFrontend:       "internal",
+				ingtypes.HostSSLPassthrough: "true",
+			},
+			logging: `WARN ignoring frontend on ingress 'default/ing1': ssl-passthrough is only supported in the default frontend`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.haproxy.Frontend().Extras = []*hatypes.ExtraFrontend{{Name: "internal", HTTPSBind: ":8443"}}
+		d := c.createHostData(source, test.ann, map[string]string{})
+		c.createUpdater().buildHostFrontend(d)
+		c.compareObjects("frontend", i, d.host.Frontend, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestHostOIDC(t *testing.T) {
+	secret := conv_helper.SecretContent{"default/oidc": {
+		"client-id":     []byte("app1"),
+		"client-secret": []byte("s3cr3t\n"),
+		"cookie-secret": []byte("0123456789abcdef"),
+	}}
+	testCases := []struct {
+		ann      map[string]string
+		secrets  conv_helper.SecretContent
+		expected hatypes.HostOIDCConfig
+		expCA    string
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.HostOIDCIssuer: "http://auth.local",
+				ingtypes.HostOIDCSecret: "oidc",
+			},
+			secrets: secret,
+			logging: `WARN ignoring OIDC issuer on ingress 'default/ing1': issuer must use https: http://auth.local`,
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.HostOIDCIssuer: "https://auth.local",
+			},
+			logging: `WARN ignoring OIDC issuer on ingress 'default/ing1': missing 'oidc-secret' configuration`,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.HostOIDCIssuer: "https://auth.local",
+				ingtypes.HostOIDCSecret: "oidc",
+			},
+			logging: `ERROR error reading OIDC credentials on ingress 'default/ing1': secret not found: 'default/oidc'`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.HostOIDCIssuer: "https://auth.local",
+				ingtypes.HostOIDCSecret: "oidc",
+			},
+			secrets: conv_helper.SecretContent{"default/oidc": {
+				"client-id":     []byte("app1"),
+				"client-secret": []byte("s3cr3t"),
+				"cookie-secret": []byte("0123"),
+			}},
+			logging: `WARN ignoring OIDC issuer on ingress 'default/ing1': cookie-secret of secret 'oidc' should have at least 16 bytes`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.HostOIDCIssuer: "https://auth.local",
+				ingtypes.HostOIDCSecret: "oidc",
+			},
+			secrets: conv_helper.SecretContent{"default/oidc": {
+				"client-id":     []byte("app 1"),
+				"client-secret": []byte("s3cr3t"),
+				"cookie-secret": []byte("0123456789abcdef"),
+			}},
+			logging: `WARN ignoring OIDC issuer on ingress 'default/ing1': key 'client-id' of secret 'oidc' is empty or has invalid chars`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.HostOIDCIssuer:       "https://auth.local",
+				ingtypes.HostOIDCSecret:       "oidc",
+				ingtypes.HostOIDCCallbackPath: "callback",
+			},
+			secrets: secret,
+			logging: `WARN ignoring OIDC issuer on ingress 'default/ing1': invalid callback path: callback`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.HostOIDCIssuer: "https://auth.local/realms/r1/",
+				ingtypes.HostOIDCSecret: "oidc",
+			},
+			secrets: secret,
+			expected: hatypes.HostOIDCConfig{
+				AuthBackendName: "_auth_4001",
+				CallbackPath:    "/_oidc/callback",
+				ClientID:        "app1",
+				ClientSecret:    "s3cr3t",
+				CookieSecret:    "MDEyMzQ1Njc4OWFiY2RlZg==",
+				Issuer:          "https://auth.local/realms/r1",
+				Scopes:          "openid+email+profile",
+			},
+			expCA: "/etc/ssl/certs/ca-certificates.crt",
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.HostOIDCIssuer:       "https://auth.local:8443",
+				ingtypes.HostOIDCSecret:       "oidc",
+				ingtypes.HostOIDCCallbackPath: "/oauth2/callback",
+				ingtypes.HostOIDCScopes:       "email, groups,invalid scope",
+			},
+			secrets: secret,
+			expected: hatypes.HostOIDCConfig{
+				AuthBackendName: "_auth_4001",
+				CallbackPath:    "/oauth2/callback",
+				ClientID:        "app1",
+				ClientSecret:    "s3cr3t",
+				CookieSecret:    "MDEyMzQ1Njc4OWFiY2RlZg==",
+				Issuer:          "https://auth.local:8443",
+				Scopes:          "openid+email+groups",
+			},
+			expCA:   "/etc/ssl/certs/ca-certificates.crt",
+			logging: `WARN ignoring invalid OIDC scope on ingress 'default/ing1': invalid scope`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	annDefault := map[string]string{
+		ingtypes.HostOIDCCallbackPath: "/_oidc/callback",
+		ingtypes.HostOIDCScopes:       "openid,email,profile",
+	}
+	lookupHost = func(host string) (addrs []string, err error) {
+		if host == "auth.local" {
+			return []string{"10.0.0.2"}, nil
+		}
+		return nil, fmt.Errorf("host not found: %s", host)
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.haproxy.Frontend().AuthProxy.RangeStart = 4001
+		c.haproxy.Frontend().AuthProxy.RangeEnd = 4009
+		c.cache.SecretContent = test.secrets
+		d := c.createHostData(source, test.ann, annDefault)
+		c.createUpdater().buildHostOIDC(d)
+		c.compareObjects("oidc", i, d.host.OIDC, test.expected)
+		var ca string
+		if bindList := c.haproxy.Frontend().AuthProxy.BindList; len(bindList) > 0 {
+			ca = c.haproxy.Backends().FindBackendID(bindList[0].Backend).Server.CAFilename
+		}
+		c.compareObjects("oidc ca", i, ca, test.expCA)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
@@ -20,6 +20,9 @@ import (
 	"fmt"
 	"strconv"
 
+	api "k8s.io/api/core/v1"
+
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 )
@@ -27,6 +30,7 @@ import (
 // MapBuilder ...
 type MapBuilder struct {
 	logger      types.Logger
+	cache       convtypes.Cache
 	annDefaults map[string]string
 }
 
@@ -63,9 +67,10 @@ type ConfigValue struct {
 }
 
 // NewMapBuilder ...
-func NewMapBuilder(logger types.Logger, annDefaults map[string]string) *MapBuilder {
+func NewMapBuilder(logger types.Logger, cache convtypes.Cache, annDefaults map[string]string) *MapBuilder {
 	return &MapBuilder{
 		logger:      logger,
+		cache:       cache,
 		annDefaults: annDefaults,
 	}
 }
@@ -109,6 +114,7 @@ func (c *Mapper) addAnnotation(source *Source, path hatypes.PathLink, key, value
 	if validator, found := validators[key]; found {
 		var ok bool
 		if realValue, ok = validator(validate{logger: c.logger, source: source, key: key, value: value}); !ok {
+			source.RecordWarning(c.cache, "InvalidAnnotation", fmt.Sprintf("%s was ignored: invalid value: %s", key, value))
 			return false
 		}
 	}
@@ -227,6 +233,24 @@ func (m *PathConfig) String() string {
 	return fmt.Sprintf("%+v", *m)
 }
 
+// RecordWarning creates a Warning event in the resource that declared a
+// configuration, so users can find their own misconfigurations with kubectl
+// describe. Only ingress and service resources are currently supported.
+func (s *Source) RecordWarning(cache convtypes.Cache, reason, message string) {
+	if s == nil || cache == nil {
+		return
+	}
+	cache.RecordEvent(s.Type, s.Namespace, s.Name, api.EventTypeWarning, reason, message)
+}
+
+// ObjectRef ...
+func (s *Source) ObjectRef() (kind, namespace, name string) {
+	if s == nil {
+		return "", "", ""
+	}
+	return s.Type, s.Namespace, s.Name
+}
+
 // String ...
 func (s *Source) String() string {
 	return s.Type + " '" + s.FullName() + "'"
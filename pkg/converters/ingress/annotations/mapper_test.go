@@ -60,11 +60,12 @@ func TestAddAnnotation(t *testing.T) {
 	pathPath := hatypes.CreatePathLink("domain.local", "/path", hatypes.MatchBegin)
 	pathURL := hatypes.CreatePathLink("domain.local", "/url", hatypes.MatchBegin)
 	testCases := []struct {
-		ann     []ann
-		getKey  string
-		expMiss bool
-		expVal  string
-		expLog  string
+		ann       []ann
+		getKey    string
+		expMiss   bool
+		expVal    string
+		expLog    string
+		expEvents []string
 	}{
 		// 0
 		{
@@ -124,10 +125,20 @@ func TestAddAnnotation(t *testing.T) {
 			getKey:  "auth-basic",
 			expMiss: true,
 		},
+		// 6
+		{
+			ann: []ann{
+				{srcing1, pathRoot, "hsts", "yes", false},
+			},
+			getKey:    "hsts",
+			expMiss:   true,
+			expLog:    "WARN ignoring invalid bool expression on ingress 'default/ing1' key 'hsts': yes",
+			expEvents: []string{"ingress default/ing1 Warning InvalidAnnotation: hsts was ignored: invalid value: yes"},
+		},
 	}
 	for i, test := range testCases {
 		c := setup(t)
-		mapper := NewMapBuilder(c.logger, map[string]string{}).NewMapper()
+		mapper := NewMapBuilder(c.logger, c.cache, map[string]string{}).NewMapper()
 		for j, ann := range test.ann {
 			if conflict := mapper.addAnnotation(ann.src, ann.path, ann.key, ann.val); conflict != ann.expConflict {
 				t.Errorf("expect conflict '%t' on '// %d (%d)', but was '%t'", ann.expConflict, i, j, conflict)
@@ -145,6 +156,7 @@ func TestAddAnnotation(t *testing.T) {
 			t.Errorf("expect '%s' on '%d', but was '%s'", test.expVal, i, v)
 		}
 		c.logger.CompareLogging(test.expLog)
+		c.compareObjects("events", i, c.cache.Events, test.expEvents)
 		c.teardown()
 	}
 }
@@ -197,7 +209,7 @@ func TestGetAnnotation(t *testing.T) {
 	}
 	for i, test := range testCases {
 		c := setup(t)
-		mapper := NewMapBuilder(c.logger, map[string]string{}).NewMapper()
+		mapper := NewMapBuilder(c.logger, c.cache, map[string]string{}).NewMapper()
 		for j, ann := range test.ann {
 			if conflict := mapper.addAnnotation(ann.src, ann.path, ann.key, ann.val); conflict != ann.expConflict {
 				t.Errorf("expect conflict '%t' on '// %d (%d)', but was '%t'", ann.expConflict, i, j, conflict)
@@ -285,7 +297,7 @@ func TestGetDefault(t *testing.T) {
 	pathRoot := hatypes.CreatePathLink("domain.local", "/", hatypes.MatchBegin)
 	for i, test := range testCases {
 		c := setup(t)
-		mapper := NewMapBuilder(c.logger, test.annDefaults).NewMapper()
+		mapper := NewMapBuilder(c.logger, c.cache, test.annDefaults).NewMapper()
 		mapper.AddAnnotations(&Source{}, pathRoot, test.ann)
 		for key, exp := range test.expAnn {
 			value := mapper.Get(key).Value
@@ -17,6 +17,7 @@ limitations under the License.
 package annotations
 
 import (
+	"fmt"
 	"net"
 	"regexp"
 	"strings"
@@ -39,25 +40,36 @@ type Updater interface {
 }
 
 // NewUpdater ...
-func NewUpdater(haproxy haproxy.Config, options *convtypes.ConverterOptions) Updater {
+func NewUpdater(haproxy haproxy.Config, options *convtypes.ConverterOptions, rateLimits map[string]*hatypes.RateLimit) Updater {
 	return &updater{
-		haproxy: haproxy,
-		options: options,
-		logger:  options.Logger,
-		cache:   options.Cache,
-		tracker: options.Tracker,
-		fakeCA:  options.FakeCAFile,
+		haproxy:    haproxy,
+		options:    options,
+		logger:     options.Logger,
+		cache:      options.Cache,
+		tracker:    options.Tracker,
+		fakeCA:     options.FakeCAFile,
+		rateLimits: rateLimits,
 	}
 }
 
 type updater struct {
-	haproxy haproxy.Config
-	options *convtypes.ConverterOptions
-	logger  types.Logger
-	cache   convtypes.Cache
-	tracker convtypes.Tracker
-	fakeCA  convtypes.CrtFile
-	srcIPs  map[string][]net.IP
+	haproxy    haproxy.Config
+	options    *convtypes.ConverterOptions
+	logger     types.Logger
+	cache      convtypes.Cache
+	tracker    convtypes.Tracker
+	fakeCA     convtypes.CrtFile
+	srcIPs     map[string][]net.IP
+	zones      map[string]string
+	snippet    *snippetPolicy
+	rateLimits map[string]*hatypes.RateLimit
+}
+
+// snippetPolicy is the parsed content of the config-snippet-policy global
+// config key. rules maps a namespace, or `*` for the missing ones, to its
+// allowed keywords. A namespace without rules doesn't allow any snippet.
+type snippetPolicy struct {
+	rules map[string]map[string]bool
 }
 
 type globalData struct {
@@ -136,6 +148,97 @@ func (c *updater) splitDualCIDR(cidrlist *ConfigValue) (allow, deny []string) {
 	return allow, deny
 }
 
+var regexSnippetKeyword = regexp.MustCompile(`^([a-z-]+/)?(\*|[a-z][a-z0-9.-]*)$`)
+
+func (c *updater) getSnippetPolicy(mapper *Mapper) *snippetPolicy {
+	if c.snippet != nil {
+		return c.snippet
+	}
+	c.snippet = &snippetPolicy{}
+	for _, line := range utils.LineToSlice(mapper.Get(ingtypes.GlobalConfigSnippetPolicy).Value) {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		pos := strings.Index(line, ":")
+		var namespace string
+		if pos > 0 {
+			namespace = strings.TrimSpace(line[:pos])
+		}
+		if namespace == "" {
+			c.logger.Warn("ignoring snippet policy without namespace: %s", line)
+			continue
+		}
+		allowed := map[string]bool{}
+		for _, keyword := range utils.Split(line[pos+1:], ",") {
+			switch keyword {
+			case "allow":
+				allowed["*"] = true
+			case "deny":
+			default:
+				if !regexSnippetKeyword.MatchString(keyword) {
+					c.logger.Warn("ignoring invalid keyword on snippet policy of namespace '%s': %s", namespace, keyword)
+					continue
+				}
+				allowed[keyword] = true
+			}
+		}
+		if c.snippet.rules == nil {
+			c.snippet.rules = map[string]map[string]bool{}
+		}
+		c.snippet.rules[namespace] = allowed
+	}
+	return c.snippet
+}
+
+// validateSnippet reads a snippet from the configuration key, and returns it
+// if allowed by the snippet policy of the namespace of the resource that
+// declared it. Snippets without a source, e.g. from the global ConfigMap,
+// are always allowed. section is the kind of proxy the snippet is added to,
+// used to match section qualified keywords like `backend/http-request`.
+func (c *updater) validateSnippet(mapper *Mapper, key, section string) []string {
+	config := mapper.Get(key)
+	snippet := utils.LineToSlice(config.Value)
+	if len(snippet) == 0 || config.Source == nil {
+		return snippet
+	}
+	policy := c.getSnippetPolicy(mapper)
+	if policy.rules == nil {
+		return snippet
+	}
+	allowed, found := policy.rules[config.Source.Namespace]
+	if !found {
+		allowed = policy.rules["*"]
+	}
+	var reason string
+	if len(allowed) == 0 {
+		reason = fmt.Sprintf("configuration snippets are not allowed on namespace '%s'", config.Source.Namespace)
+	} else if !allowed["*"] && !allowed[section+"/*"] {
+		var denied []string
+		found := map[string]bool{}
+		for _, line := range snippet {
+			fields := strings.Fields(line)
+			if len(fields) == 0 || fields[0][0] == '#' {
+				continue
+			}
+			keyword := fields[0]
+			if !allowed[keyword] && !allowed[section+"/"+keyword] && !found[keyword] {
+				denied = append(denied, keyword)
+				found[keyword] = true
+			}
+		}
+		if len(denied) > 0 {
+			reason = fmt.Sprintf("keywords not allowed on namespace '%s': %s", config.Source.Namespace, strings.Join(denied, ","))
+		}
+	}
+	if reason != "" {
+		c.logger.Warn("ignoring %s on %v: %s", key, config.Source, reason)
+		config.Source.RecordWarning(c.cache, "SnippetNotAllowed", fmt.Sprintf("%s was ignored: %s", key, reason))
+		return nil
+	}
+	return snippet
+}
+
 func (c *updater) UpdateGlobalConfig(haproxyConfig haproxy.Config, mapper *Mapper) {
 	d := &globalData{
 		acmeData: haproxyConfig.AcmeData(),
@@ -144,43 +247,55 @@ func (c *updater) UpdateGlobalConfig(haproxyConfig haproxy.Config, mapper *Mappe
 	}
 	// TODO Move all magic strings to a single place
 	d.global.AdminSocket = "/var/run/haproxy/admin.sock"
+	d.global.HandoffSocket = c.options.HandoffSocket
 	d.global.MaxConn = mapper.Get(ingtypes.GlobalMaxConnections).Int()
 	d.global.DefaultBackendRedir = mapper.Get(ingtypes.GlobalDefaultBackendRedirect).String()
 	d.global.DefaultBackendRedirCode = mapper.Get(ingtypes.GlobalDefaultBackendRedirectCode).Int()
 	d.global.DrainSupport.Drain = mapper.Get(ingtypes.GlobalDrainSupport).Bool()
 	d.global.DrainSupport.Redispatch = mapper.Get(ingtypes.GlobalDrainSupportRedispatch).Bool()
-	d.global.Cookie.Key = mapper.Get(ingtypes.GlobalCookieKey).Value
 	d.global.External.HasLua = mapper.Get(ingtypes.GlobalExternalHasLua).Bool()
 	d.global.External.MasterSocket = c.options.MasterSocket
 	d.global.LoadServerState = mapper.Get(ingtypes.GlobalLoadServerState).Bool()
 	d.global.Master.ExitOnFailure = mapper.Get(ingtypes.GlobalMasterExitOnFailure).Bool()
+	d.global.Master.MaxOldWorkers = mapper.Get(ingtypes.GlobalMaxOldWorkers).Int()
 	d.global.Master.WorkerMaxReloads = mapper.Get(ingtypes.GlobalWorkerMaxReloads).Int()
 	d.global.StrictHost = mapper.Get(ingtypes.GlobalStrictHost).Bool()
 	d.global.UseHTX = mapper.Get(ingtypes.GlobalUseHTX).Bool()
 	//
 	c.haproxy.Frontend().RedirectFromCode = mapper.Get(ingtypes.GlobalRedirectFromCode).Int()
-	c.haproxy.Frontend().RedirectToCode = mapper.Get(ingtypes.GlobalRedirectToCode).Int()
+	c.haproxy.Frontend().RedirectToCode = mapper.Get(ingtypes.BackRedirectToCode).Int()
 	//
 	c.buildGlobalAcme(d)
 	c.buildGlobalAuthProxy(d)
 	c.buildGlobalBind(d)
+	c.buildGlobalCookieKey(d)
 	c.buildGlobalCustomConfig(d)
 	c.buildGlobalDNS(d)
 	c.buildGlobalDynamic(d)
+	c.buildGlobalExtraFrontends(d)
 	c.buildGlobalForwardFor(d)
+	c.buildGlobalGeoIP(d)
+	c.buildGlobalHTTP3(d)
 	c.buildGlobalHTTPStoHTTP(d)
+	c.buildGlobalLogForward(d)
+	c.buildGlobalLogRing(d)
+	c.buildGlobalLuaScripts(d)
 	c.buildGlobalModSecurity(d)
 	c.buildGlobalPathTypeOrder(d)
+	c.buildGlobalPeers(d)
 	c.buildGlobalProc(d)
+	c.buildGlobalPrometheus(d)
 	c.buildSecurity(d)
+	c.buildGlobalSPOE(d)
 	c.buildGlobalSSL(d)
 	c.buildGlobalStats(d)
 	c.buildGlobalSyslog(d)
 	c.buildGlobalTimeout(d)
+	c.buildGlobalTracing(d)
 }
 
 func (c *updater) UpdateTCPPortConfig(tcp *hatypes.TCPServicePort, mapper *Mapper) {
-	tcp.CustomConfig = utils.LineToSlice(mapper.Get(ingtypes.TCPConfigTCPService).Value)
+	tcp.CustomConfig = c.validateSnippet(mapper, ingtypes.TCPConfigTCPService, "tcp-service")
 	tcp.LogFormat = mapper.Get(ingtypes.TCPTCPServiceLogFormat).Value
 	tcp.ProxyProt = mapper.Get(ingtypes.TCPTCPServiceProxyProto).Bool()
 }
@@ -200,6 +315,8 @@ func (c *updater) UpdateHostConfig(host *hatypes.Host, mapper *Mapper) {
 	host.VarNamespace = mapper.Get(ingtypes.HostVarNamespace).Bool()
 	c.buildHostAuthTLS(data)
 	c.buildHostCertSigner(data)
+	c.buildHostFrontend(data)
+	c.buildHostOIDC(data)
 	c.buildHostRedirect(data)
 	c.buildHostSSLPassthrough(data)
 	c.buildHostTLSConfig(data)
@@ -212,32 +329,56 @@ func (c *updater) UpdateBackendConfig(backend *hatypes.Backend, mapper *Mapper)
 	}
 	// TODO check ModeTCP with HTTP annotations
 	backend.BalanceAlgorithm = mapper.Get(ingtypes.BackBalanceAlgorithm).Value
-	backend.CustomConfig = utils.LineToSlice(mapper.Get(ingtypes.BackConfigBackend).Value)
 	backend.Server.MaxConn = mapper.Get(ingtypes.BackMaxconnServer).Int()
 	backend.Server.MaxQueue = mapper.Get(ingtypes.BackMaxQueueServer).Int()
+	c.buildBackendABTest(data)
 	c.buildBackendAffinity(data)
 	c.buildBackendAuthExternal(data)
 	c.buildBackendAuthHTTP(data)
+	c.buildBackendBalanceHash(data)
+	c.buildBackendBlockUserAgents(data)
 	c.buildBackendBlueGreenBalance(data)
+	c.buildBackendBlueGreenCanary(data)
 	c.buildBackendBlueGreenSelector(data)
 	c.buildBackendBodySize(data)
+	c.buildBackendCache(data)
+	c.buildBackendCompression(data)
 	c.buildBackendCors(data)
+	c.buildBackendCustomConfig(data)
+	c.buildBackendDenyMethods(data)
 	c.buildBackendDNS(data)
 	c.buildBackendDynamic(data)
 	c.buildBackendAgentCheck(data)
+	c.buildBackendErrorPages(data)
 	c.buildBackendHeaders(data)
 	c.buildBackendHealthCheck(data)
 	c.buildBackendHSTS(data)
+	c.buildBackendHTTPHeaders(data)
+	c.buildBackendJWT(data)
 	c.buildBackendLimit(data)
+	c.buildBackendLua(data)
+	c.buildBackendMaintenance(data)
+	c.buildBackendMirror(data)
 	c.buildBackendOAuth(data)
+	c.buildBackendOutlier(data)
+	c.buildBackendPodWeight(data)
 	c.buildBackendProtocol(data)
+	c.buildBackendGRPCWeb(data)
 	c.buildBackendProxyProtocol(data)
+	c.buildBackendRateLimit(data)
+	c.buildBackendRetry(data)
 	c.buildBackendRewriteURL(data)
+	c.buildBackendRoutes(data)
+	c.buildBackendSecurityHeaders(data)
 	c.buildBackendServerNaming(data)
+	c.buildBackendServiceImportWeight(data)
+	c.buildBackendSlowStart(data)
 	c.buildBackendSourceAddressIntf(data)
+	c.buildBackendSPOE(data)
 	c.buildBackendSSL(data)
 	c.buildBackendSSLRedirect(data)
 	c.buildBackendTimeout(data)
+	c.buildBackendTopology(data)
 	c.buildBackendWAF(data)
 	c.buildBackendWhitelistHTTP(data)
 	c.buildBackendWhitelistTCP(data)
@@ -23,6 +23,7 @@ import (
 	"testing"
 
 	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/tracker"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
@@ -108,6 +109,112 @@ func TestSplitDualCIDR(t *testing.T) {
 	}
 }
 
+func TestValidateSnippet(t *testing.T) {
+	testCases := []struct {
+		policy    string
+		snippet   string
+		global    bool
+		section   string
+		expected  []string
+		expEvents []string
+		logging   string
+	}{
+		// 0
+		{
+			snippet:  "http-request deny",
+			expected: []string{"http-request deny"},
+		},
+		// 1
+		{
+			policy:    "*: deny",
+			snippet:   "http-request deny",
+			expEvents: []string{"ingress default/ing1 Warning SnippetNotAllowed: config-backend was ignored: configuration snippets are not allowed on namespace 'default'"},
+			logging:   `WARN ignoring config-backend on ingress 'default/ing1': configuration snippets are not allowed on namespace 'default'`,
+		},
+		// 2
+		{
+			policy:   "*: deny\ndefault: allow",
+			snippet:  "http-request deny",
+			expected: []string{"http-request deny"},
+		},
+		// 3
+		{
+			policy:   "default: http-request, timeout",
+			snippet:  "http-request deny\n# timeouts\ntimeout server 1m",
+			expected: []string{"http-request deny", "# timeouts", "timeout server 1m"},
+		},
+		// 4
+		{
+			policy:    "default: http-request",
+			snippet:   "http-request deny\nuse-server srv1 if { path /app }\nuse-server srv2\nlua-load /tmp/x.lua",
+			expEvents: []string{"ingress default/ing1 Warning SnippetNotAllowed: config-backend was ignored: keywords not allowed on namespace 'default': use-server,lua-load"},
+			logging:   `WARN ignoring config-backend on ingress 'default/ing1': keywords not allowed on namespace 'default': use-server,lua-load`,
+		},
+		// 5
+		{
+			policy:   "*: backend/*",
+			snippet:  "use-server srv1",
+			expected: []string{"use-server srv1"},
+		},
+		// 6
+		{
+			policy:    "*: backend/*, tcp-service/timeout",
+			snippet:   "timeout client 1m\ntcp-request content reject",
+			section:   "tcp-service",
+			expEvents: []string{"ingress default/ing1 Warning SnippetNotAllowed: config-backend was ignored: keywords not allowed on namespace 'default': tcp-request"},
+			logging:   `WARN ignoring config-backend on ingress 'default/ing1': keywords not allowed on namespace 'default': tcp-request`,
+		},
+		// 7
+		{
+			policy:    "team1: allow",
+			snippet:   "http-request deny",
+			expEvents: []string{"ingress default/ing1 Warning SnippetNotAllowed: config-backend was ignored: configuration snippets are not allowed on namespace 'default'"},
+			logging:   `WARN ignoring config-backend on ingress 'default/ing1': configuration snippets are not allowed on namespace 'default'`,
+		},
+		// 8
+		{
+			policy:   "*: deny",
+			snippet:  "http-request deny",
+			global:   true,
+			expected: []string{"http-request deny"},
+		},
+		// 9
+		{
+			policy:   "allow\n: deny\ndefault: http-request, Http-Response, timeout server",
+			snippet:  "http-request deny",
+			expected: []string{"http-request deny"},
+			logging: `
+WARN ignoring snippet policy without namespace: allow
+WARN ignoring snippet policy without namespace: : deny
+WARN ignoring invalid keyword on snippet policy of namespace 'default': Http-Response
+WARN ignoring invalid keyword on snippet policy of namespace 'default': timeout server`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		ann := map[string]string{}
+		annDefault := map[string]string{
+			ingtypes.GlobalConfigSnippetPolicy: test.policy,
+		}
+		if test.global {
+			annDefault[ingtypes.BackConfigBackend] = test.snippet
+		} else {
+			ann[ingtypes.BackConfigBackend] = test.snippet
+		}
+		section := test.section
+		if section == "" {
+			section = "backend"
+		}
+		d := c.createBackendData("default/app", source, ann, annDefault)
+		snippet := c.createUpdater().validateSnippet(d.mapper, ingtypes.BackConfigBackend, section)
+		c.compareObjects("snippet", i, snippet, test.expected)
+		c.compareObjects("events", i, c.cache.Events, test.expEvents)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 type testConfig struct {
 	t       *testing.T
 	haproxy haproxy.Config
@@ -149,7 +256,7 @@ func (c *testConfig) createUpdater() *updater {
 }
 
 func (c *testConfig) createBackendData(svcFullName string, source *Source, ann, annDefault map[string]string) *backData {
-	mapper := NewMapBuilder(c.logger, annDefault).NewMapper()
+	mapper := NewMapBuilder(c.logger, c.cache, annDefault).NewMapper()
 	mapper.AddAnnotations(source, hatypes.CreatePathLink("domain.local", "/", hatypes.MatchBegin), ann)
 	svcName := strings.Split(svcFullName, "/")
 	namespace := svcName[0]
@@ -201,7 +308,7 @@ func (c *testConfig) createBackendMappingData(
 }
 
 func (c *testConfig) createHostData(source *Source, ann, annDefault map[string]string) *hostData {
-	mapper := NewMapBuilder(c.logger, annDefault).NewMapper()
+	mapper := NewMapBuilder(c.logger, c.cache, annDefault).NewMapper()
 	mapper.AddAnnotations(source, hatypes.CreatePathLink("domain.local", "/", hatypes.MatchBegin), ann)
 	return &hostData{
 		host:   &hatypes.Host{},
@@ -218,6 +325,6 @@ func (c *testConfig) compareObjects(name string, index int, actual, expected int
 func (c *testConfig) createGlobalData(config map[string]string) *globalData {
 	return &globalData{
 		global: &hatypes.Global{},
-		mapper: NewMapBuilder(c.logger, config).NewMapper(),
+		mapper: NewMapBuilder(c.logger, c.cache, config).NewMapper(),
 	}
 }
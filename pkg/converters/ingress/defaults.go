@@ -33,6 +33,8 @@ func createDefaults() map[string]string {
 		types.TCPTCPServiceLogFormat: "default",
 		//
 		types.HostAuthTLSStrict:     "false",
+		types.HostOIDCCallbackPath:  "/_oidc/callback",
+		types.HostOIDCScopes:        "openid,email,profile",
 		types.HostSSLAlwaysAddHTTPS: "false",
 		types.HostSSLCiphers:        defaultSSLCiphers,
 		types.HostSSLCipherSuites:   defaultSSLCipherSuites,
@@ -47,21 +49,38 @@ func createDefaults() map[string]string {
 		types.BackBackendServerSlotsInc:  "1",
 		types.BackSlotsMinFree:           "6",
 		types.BackBalanceAlgorithm:       "roundrobin",
+		types.BackBlueGreenCanaryMatch:   "exact",
+		types.BackCacheMaxAge:            "60s",
+		types.BackCacheTotalMaxSize:      "64m",
+		types.BackCompressionAlgo:        "gzip",
+		types.BackCompressionTypes:       "text/html text/plain text/css text/javascript application/javascript application/json application/xml image/svg+xml",
 		types.BackCorsAllowHeaders:       "DNT,X-CustomHeader,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type,Authorization",
 		types.BackCorsAllowMethods:       "GET, PUT, POST, DELETE, PATCH, OPTIONS",
 		types.BackCorsAllowOrigin:        "*",
 		types.BackCorsMaxAge:             "86400",
+		types.BackDenyMethodsStatus:      "405",
 		types.BackDynamicScaling:         "true",
+		types.BackGRPCWeb:                "false",
 		types.BackHealthCheckInterval:    "2s",
 		types.BackHSTS:                   "true",
 		types.BackHSTSIncludeSubdomains:  "false",
 		types.BackHSTSMaxAge:             "15768000",
 		types.BackHSTSPreload:            "false",
 		types.BackInitialWeight:          "1",
+		types.BackJWTAlgorithm:           "RS256",
+		types.BackLimitDenyStatus:        "429",
+		types.BackMirrorPercentage:       "100",
 		types.BackOAuthHeaders:           "X-Auth-Request-Email",
+		types.BackOutlierErrorLimit:      "10",
+		types.BackOutlierOnError:         "mark-down",
+		types.BackRedirectToCode:         "302",
+		types.BackRedirectToType:         "location",
 		types.BackSessionCookieDynamic:   "true",
 		types.BackSessionCookiePreserve:  "false",
 		types.BackSessionCookieValue:     "server-name",
+		types.BackSessionStickExpire:     "30m",
+		types.BackSessionStickKey:        "src",
+		types.BackSessionStickSize:       "100k",
 		types.BackSSLRedirect:            "true",
 		types.BackSSLCipherSuitesBackend: defaultSSLCipherSuites,
 		types.BackSSLCiphersBackend:      defaultSSLCiphers,
@@ -73,7 +92,10 @@ func createDefaults() map[string]string {
 		types.BackTimeoutServer:          "50s",
 		types.BackTimeoutServerFin:       "50s",
 		types.BackTimeoutTunnel:          "1h",
+		types.BackTopologyRemoteWeight:   "10",
+		types.BackBlockUserAgentsAction:  "deny",
 		types.BackWAFMode:                "deny",
+		types.BackWAFRuleset:             "default",
 		//
 		types.GlobalAcmeExpiring:                 "30",
 		types.GlobalAuthProxy:                    "_front__auth:14415-14499",
@@ -85,9 +107,12 @@ func createDefaults() map[string]string {
 		types.GlobalDNSTimeoutRetry:              "1s",
 		types.GlobalDrainSupportRedispatch:       "true",
 		types.GlobalForwardfor:                   "add",
+		types.GlobalGeoIPDatabaseFormat:          "maxmind",
 		types.GlobalHealthzPort:                  "10253",
 		types.GlobalHTTPPort:                     "80",
 		types.GlobalHTTPSPort:                    "443",
+		types.GlobalHTTP3:                        "false",
+		types.GlobalHTTP3AltSvcMaxAge:            "86400",
 		types.GlobalMasterExitOnFailure:          "true",
 		types.GlobalMaxConnections:               "2000",
 		types.GlobalModsecurityTimeoutConnect:    "5s",
@@ -99,8 +124,13 @@ func createDefaults() map[string]string {
 		types.GlobalNbthread:                     "2",
 		types.GlobalNoTLSRedirectLocations:       "/.well-known/acme-challenge",
 		types.GlobalPathTypeOrder:                "exact,prefix,begin,regex",
+		types.GlobalPrometheusServerMetrics:      "true",
 		types.GlobalRedirectFromCode:             "302",
-		types.GlobalRedirectToCode:               "302",
+		types.GlobalSPOETimeoutConnect:           "5s",
+		types.GlobalSPOETimeoutHello:             "100ms",
+		types.GlobalSPOETimeoutIdle:              "30s",
+		types.GlobalSPOETimeoutProcessing:        "1s",
+		types.GlobalSPOETimeoutServer:            "5s",
 		types.GlobalSSLDHDefaultMaxSize:          "2048",
 		types.GlobalSSLHeadersPrefix:             "X-SSL",
 		types.GlobalSSLOptions:                   defaultSSLOptions,
@@ -111,6 +141,8 @@ func createDefaults() map[string]string {
 		types.GlobalTimeoutClient:                "50s",
 		types.GlobalTimeoutClientFin:             "50s",
 		types.GlobalTimeoutStop:                  "10m",
+		types.GlobalTraceContext:                 "false",
+		types.GlobalUniqueIDHeader:               "X-Request-ID",
 		types.GlobalUseCPUMap:                    "true",
 		types.GlobalUseForwardedProto:            "true",
 		types.GlobalUseHTX:                       "true",
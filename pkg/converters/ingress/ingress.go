@@ -17,15 +17,22 @@ limitations under the License.
 package ingress
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 
 	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networking "k8s.io/api/networking/v1"
 
+	mcsv1alpha1 "github.com/jcmoraisjr/haproxy-ingress/pkg/api/mcs/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	ingutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/utils"
@@ -34,17 +41,18 @@ import (
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
 
 // Config ...
 type Config interface {
 	NeedFullSync() bool
 	Sync(full bool)
-	ReadAnnotations(backend *hatypes.Backend, services []*api.Service, pathLinks []hatypes.PathLink)
+	ReadAnnotations(backend *hatypes.Backend, services []*api.Service, pathLinks []hatypes.PathLink, middlewares []string)
 }
 
 // NewIngressConverter ...
-func NewIngressConverter(options *convtypes.ConverterOptions, haproxy haproxy.Config, changed *convtypes.ChangedObjects) Config {
+func NewIngressConverter(options *convtypes.ConverterOptions, haproxy haproxy.Config, changed *convtypes.ChangedObjects, resourceConfigs convtypes.ResourceConfigs) Config {
 	if options.DefaultConfig == nil {
 		options.DefaultConfig = createDefaults
 	}
@@ -67,13 +75,14 @@ func NewIngressConverter(options *convtypes.ConverterOptions, haproxy haproxy.Co
 		cache:              options.Cache,
 		tracker:            options.Tracker,
 		defaultBackSource:  annotations.Source{Name: "<default-backend>", Type: "ingress"},
-		mapBuilder:         annotations.NewMapBuilder(options.Logger, defaultConfig),
-		updater:            annotations.NewUpdater(haproxy, options),
-		globalConfig:       annotations.NewMapBuilder(options.Logger, defaultConfig).NewMapper(),
+		mapBuilder:         annotations.NewMapBuilder(options.Logger, options.Cache, defaultConfig),
+		updater:            annotations.NewUpdater(haproxy, options, resourceConfigs.RateLimits),
+		globalConfig:       annotations.NewMapBuilder(options.Logger, options.Cache, defaultConfig).NewMapper(),
 		tcpsvcAnnotations:  map[*hatypes.TCPServicePort]*annotations.Mapper{},
 		hostAnnotations:    map[*hatypes.Host]*annotations.Mapper{},
 		backendAnnotations: map[*hatypes.Backend]*annotations.Mapper{},
 		ingressClasses:     map[string]*ingressClassConfig{},
+		resourceConfigs:    resourceConfigs,
 	}
 	c.readDefaultCertificate()
 	return c
@@ -95,9 +104,10 @@ type converter struct {
 	hostAnnotations    map[*hatypes.Host]*annotations.Mapper
 	backendAnnotations map[*hatypes.Backend]*annotations.Mapper
 	ingressClasses     map[string]*ingressClassConfig
+	resourceConfigs    convtypes.ResourceConfigs
 }
 
-func (c *converter) ReadAnnotations(backend *hatypes.Backend, services []*api.Service, pathLinks []hatypes.PathLink) {
+func (c *converter) ReadAnnotations(backend *hatypes.Backend, services []*api.Service, pathLinks []hatypes.PathLink, middlewares []string) {
 	mapper := c.mapBuilder.NewMapper()
 	for _, service := range services {
 		source := &annotations.Source{
@@ -111,6 +121,18 @@ func (c *converter) ReadAnnotations(backend *hatypes.Backend, services []*api.Se
 			if len(conflict) > 0 {
 				c.logger.Warn("skipping %s annotation(s) due to conflict: %v", source, conflict)
 			}
+			c.addBackendResourceConfig(mapper, service.Namespace, service.Name, pathLink)
+		}
+	}
+	if len(middlewares) > 0 {
+		// backends of HTTPRoutes are named after the route
+		source := &annotations.Source{
+			Namespace: backend.Namespace,
+			Name:      backend.Name,
+			Type:      "HTTPRoute",
+		}
+		for _, pathLink := range pathLinks {
+			c.addMiddlewareConfig(mapper, source, pathLink, middlewares)
 		}
 	}
 	c.updater.UpdateBackendConfig(backend, mapper)
@@ -123,7 +145,7 @@ type ingressClassConfig struct {
 }
 
 func (c *converter) NeedFullSync() bool {
-	needFullSync := c.defaultCrtNeedFullSync() || c.globalConfigNeedFullSync()
+	needFullSync := c.defaultCrtNeedFullSync() || c.globalConfigNeedFullSync() || c.peersNeedFullSync() || c.luaScriptsNeedFullSync() || c.cookieKeyNeedFullSync()
 	if needFullSync && c.defaultCrt == c.options.FakeCrtFile {
 		c.logger.Info("using auto generated fake certificate")
 	}
@@ -163,6 +185,97 @@ func (c *converter) globalConfigNeedFullSync() bool {
 	return new != nil && !reflect.DeepEqual(cur, new)
 }
 
+// peersNeedFullSync is true if the service or the endpoints used to
+// discover the haproxy peers changed, peers are configured in the global
+// section, which is only updated in a full sync.
+func (c *converter) peersNeedFullSync() bool {
+	if c.globalConfig.Get(ingtypes.GlobalPeersPort).Int() == 0 {
+		return false
+	}
+	serviceNames := map[string]bool{}
+	var controllerPod *api.Pod
+	if serviceName := c.globalConfig.Get(ingtypes.GlobalPeersService).Value; serviceName != "" {
+		if !strings.Contains(serviceName, "/") {
+			serviceName = c.cache.GetPodNamespace() + "/" + serviceName
+		}
+		serviceNames[serviceName] = true
+	} else {
+		// auto discovery, see buildGlobalPeers(); changed services are
+		// also compared with the controller pod, so a removed service
+		// is taken into account
+		pod, err := c.cache.GetPod(c.cache.GetPodNamespace() + "/" + c.cache.GetPodName())
+		if err != nil {
+			return false
+		}
+		controllerPod = pod
+		services, _ := c.cache.GetControllerServices()
+		for _, svc := range services {
+			serviceNames[svc.Namespace+"/"+svc.Name] = true
+		}
+	}
+	ch := c.changed
+	for _, ep := range ch.EndpointsNew {
+		if serviceNames[convutils.EndpointSliceService(ep)] {
+			return true
+		}
+	}
+	for _, services := range [][]*api.Service{ch.ServicesDel, ch.ServicesUpd, ch.ServicesAdd} {
+		for _, svc := range services {
+			if serviceNames[svc.Namespace+"/"+svc.Name] {
+				return true
+			}
+			if controllerPod != nil && convutils.ServiceSelectsPod(svc, controllerPod) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// luaScriptsNeedFullSync is true if the ConfigMap with the custom Lua
+// scripts changed, scripts are loaded in the global section, which is
+// only updated in a full sync.
+func (c *converter) luaScriptsNeedFullSync() bool {
+	configMapName := c.globalConfig.Get(ingtypes.GlobalLuaScripts).Value
+	if configMapName == "" {
+		return false
+	}
+	if !strings.Contains(configMapName, "/") {
+		configMapName = c.cache.GetPodNamespace() + "/" + configMapName
+	}
+	ch := c.changed
+	for _, configMaps := range [][]*api.ConfigMap{ch.ConfigMapsDel, ch.ConfigMapsUpd, ch.ConfigMapsAdd} {
+		for _, cm := range configMaps {
+			if cm.Namespace+"/"+cm.Name == configMapName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cookieKeyNeedFullSync is true if the secret with the cookie key changed,
+// the key is configured in the global section, and it is also used to hash
+// the cookie value of all the backends with cookie affinity.
+func (c *converter) cookieKeyNeedFullSync() bool {
+	secretName := c.globalConfig.Get(ingtypes.GlobalCookieKeySecret).Value
+	if secretName == "" {
+		return false
+	}
+	if !strings.Contains(secretName, "/") {
+		secretName = c.cache.GetPodNamespace() + "/" + secretName
+	}
+	ch := c.changed
+	for _, secrets := range [][]*api.Secret{ch.SecretsDel, ch.SecretsUpd, ch.SecretsAdd} {
+		for _, secret := range secrets {
+			if secret.Namespace+"/"+secret.Name == secretName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (c *converter) readDefaultCertificate() {
 	crt := c.options.FakeCrtFile
 	if c.options.DefaultCrtSecret != "" {
@@ -206,6 +319,7 @@ func (c *converter) syncFull() {
 	for _, ing := range ingList {
 		c.syncIngress(ing)
 	}
+	c.syncHostDefaultBackends(c.haproxy.Hosts().Items())
 	c.fullSyncAnnotations()
 	c.syncEndpointCookies()
 }
@@ -247,10 +361,10 @@ func (c *converter) syncPartial() {
 		}
 		return serviceList
 	}
-	ep2names := func(endpoints []*api.Endpoints) []string {
+	ep2names := func(endpoints []*discoveryv1.EndpointSlice) []string {
 		epList := make([]string, len(endpoints))
 		for i, ep := range endpoints {
-			epList[i] = ep.Namespace + "/" + ep.Name
+			epList[i] = convutils.EndpointSliceService(ep)
 		}
 		return epList
 	}
@@ -261,6 +375,13 @@ func (c *converter) syncPartial() {
 		}
 		return secretList
 	}
+	ipList2names := func(ipLists []*v1alpha1.IPList) []string {
+		ipListNames := make([]string, len(ipLists))
+		for i, ipList := range ipLists {
+			ipListNames[i] = ipList.Name
+		}
+		return ipListNames
+	}
 	pod2names := func(pods []*api.Pod) []string {
 		podList := make([]string, len(pods))
 		for i, pod := range pods {
@@ -293,6 +414,10 @@ func (c *converter) syncPartial() {
 	addSecretNames := secret2names(c.changed.SecretsAdd)
 	oldSecretNames := append(delSecretNames, updSecretNames...)
 	addPodNames := pod2names(c.changed.PodsNew)
+	delIPListNames := ipList2names(c.changed.IPListsDel)
+	updIPListNames := ipList2names(c.changed.IPListsUpd)
+	addIPListNames := ipList2names(c.changed.IPListsAdd)
+	oldIPListNames := append(delIPListNames, updIPListNames...)
 	c.trackAddedIngress()
 	dirtyIngs, dirtyHosts, dirtyBacks, dirtyUsers, dirtyStorages :=
 		c.tracker.GetDirtyLinks(
@@ -302,6 +427,7 @@ func (c *converter) syncPartial() {
 			oldSvcNames, addSvcNames,
 			oldSecretNames, addSecretNames,
 			addPodNames,
+			oldIPListNames, addIPListNames,
 		)
 	c.tracker.DeleteHostnames(dirtyHosts)
 	c.tracker.DeleteBackends(dirtyBacks)
@@ -359,6 +485,7 @@ func (c *converter) syncPartial() {
 	for _, ing := range ingList {
 		c.syncIngress(ing)
 	}
+	c.syncHostDefaultBackends(c.haproxy.Hosts().ItemsAdd())
 	c.partialSyncAnnotations()
 	c.syncChangedEndpointCookies()
 }
@@ -400,6 +527,14 @@ func (c *converter) trackAddedIngress() {
 }
 
 func (c *converter) findBackend(namespace string, backend *networking.IngressBackend) *hatypes.Backend {
+	if isServiceImportBackend(backend) {
+		svcImport, err := c.cache.GetServiceImport(namespace, backend.Resource.Name)
+		if err != nil || len(svcImport.Spec.Ports) == 0 {
+			return nil
+		}
+		port := strconv.Itoa(int(svcImport.Spec.Ports[0].Port))
+		return c.haproxy.Backends().FindBackend(namespace, svcImport.Name+convutils.ServiceImportSuffix, port)
+	}
 	svcName, svcPort, err := readServiceNamePort(backend)
 	if err != nil {
 		return nil
@@ -482,6 +617,7 @@ func (c *converter) syncIngressHTTP(source *annotations.Source, ing *networking.
 		}
 		if err != nil {
 			c.logger.Warn("skipping default backend of %v: %v", source, err)
+			source.RecordWarning(c.cache, "InvalidBackend", fmt.Sprintf("default backend was ignored: %v", err))
 		}
 	}
 	for _, rule := range ing.Spec.Rules {
@@ -492,12 +628,22 @@ func (c *converter) syncIngressHTTP(source *annotations.Source, ing *networking.
 		ingressClass := c.readIngressClass(source, hostname, ing.Spec.IngressClassName)
 		sslpassthrough, _ := strconv.ParseBool(annHost[ingtypes.HostSSLPassthrough])
 		host := c.addHost(hostname, source, annHost)
+		hostPathLink := hatypes.CreatePathLink(hostname, "/", hatypes.MatchExact)
+		c.addAuthConfig(c.hostAnnotations[host], source, hostPathLink, annBack[ingtypes.BackAuthConfig])
+		c.addHostResourceConfig(c.hostAnnotations[host], hostPathLink)
+		c.addIngressClassConfig(c.hostAnnotations[host], source, hostPathLink, ingressClass)
 		for _, path := range rule.HTTP.Paths {
 			uri := path.Path
 			if uri == "" {
 				uri = "/"
 			}
 			match := c.readPathType(path, annBack[ingtypes.BackPathType])
+			if match == hatypes.MatchRegex {
+				if err := convutils.ValidateRegexPath(uri); err != nil {
+					c.logger.Warn("skipping path '%s' on %v: %v", uri, source, err)
+					continue
+				}
+			}
 			if sslpassthrough && uri == "/" {
 				if host.FindPath(uri) != nil {
 					c.logger.Warn("skipping redeclared ssl-passthrough root path on %v", source)
@@ -508,40 +654,75 @@ func (c *converter) syncIngressHTTP(source *annotations.Source, ing *networking.
 				continue
 			}
 			if redirectTo := annBack[ingtypes.BackRedirectTo]; redirectTo != "" {
-				host.AddRedirect(uri, match, redirectTo)
-				continue
-			}
-			svcName, svcPort, err := readServiceNamePort(&path.Backend)
-			if err != nil {
-				c.logger.Warn("skipping backend config of %v: %v", source, err)
+				c.addRedirect(source, host, uri, match, redirectTo, annBack)
 				continue
 			}
 			pathLink := hatypes.CreatePathLink(hostname, uri, match)
-			fullSvcName := ing.Namespace + "/" + svcName
-			backend, err := c.addBackendWithClass(source, pathLink, fullSvcName, svcPort, annBack, ingressClass)
+			var backend *hatypes.Backend
+			var fullSvcName string
+			var err error
+			if isServiceImportBackend(&path.Backend) {
+				fullImportName := ing.Namespace + "/" + path.Backend.Resource.Name
+				backend, err = c.addServiceImportBackend(source, pathLink, fullImportName, annBack, ingressClass)
+			} else {
+				var svcName, svcPort string
+				svcName, svcPort, err = readServiceNamePort(&path.Backend)
+				if err == nil {
+					fullSvcName = ing.Namespace + "/" + svcName
+					backend, err = c.addBackendWithClass(source, pathLink, fullSvcName, svcPort, annBack, ingressClass)
+				}
+			}
 			if err != nil {
 				c.logger.Warn("skipping backend config of %v: %v", source, err)
+				source.RecordWarning(c.cache, "InvalidBackend", fmt.Sprintf("path '%s' of host '%s' was ignored: %v", uri, hostname, err))
 				continue
 			}
-			host.AddPath(backend, uri, match)
+			host.AddPath(backend, uri, match).Source = hatypes.PathSource{Namespace: ing.Namespace, Name: ing.Name}
 			sslpasshttpport := annHost[ingtypes.HostSSLPassthroughHTTPPort]
 			if sslpassthrough && sslpasshttpport != "" {
-				if _, err := c.addBackend(source, pathLink, fullSvcName, sslpasshttpport, annBack); err != nil {
+				if fullSvcName == "" {
+					c.logger.Warn("skipping http port config of ssl-passthrough on %v: not supported on ServiceImport backends", source)
+				} else if _, err := c.addBackend(source, pathLink, fullSvcName, sslpasshttpport, annBack); err != nil {
 					c.logger.Warn("skipping http port config of ssl-passthrough on %v: %v", source, err)
+					source.RecordWarning(c.cache, "InvalidBackend", fmt.Sprintf("http port of ssl-passthrough was ignored: %v", err))
 				}
 			}
-			// pre-building the auth-url backend
-			// TODO move to updater.buildBackendAuthExternal()
-			if url := annBack[ingtypes.BackAuthURL]; url != "" {
+			// pre-building the auth-url and mirror-url backends
+			// TODO move to updater.buildBackendAuthExternal() and updater.buildBackendMirror()
+			for _, key := range []string{ingtypes.BackAuthURL, ingtypes.BackMirrorURL} {
+				url := annBack[key]
+				if url == "" {
+					continue
+				}
 				urlProto, urlHost, urlPort, _, _ := ingutils.ParseURL(url)
 				if (urlProto == "service" || urlProto == "svc") && urlHost != "" && urlPort != "" {
-					authSvcName := urlHost
-					if strings.Index(authSvcName, "/") < 0 {
-						authSvcName = ing.Namespace + "/" + authSvcName
+					svcName := urlHost
+					if strings.Index(svcName, "/") < 0 {
+						svcName = ing.Namespace + "/" + svcName
 					}
-					_, err := c.addBackend(source, pathLink, authSvcName, urlPort, map[string]string{})
+					_, err := c.addBackend(source, pathLink, svcName, urlPort, map[string]string{})
 					if err != nil {
-						c.logger.Warn("skipping auth-url on %v: %v", source, err)
+						c.logger.Warn("skipping %s on %v: %v", key, source, err)
+						source.RecordWarning(c.cache, "InvalidBackend", fmt.Sprintf("%s was ignored: %v", key, err))
+					}
+				}
+			}
+			// pre-building the backends of the alternate routes
+			for _, key := range []string{ingtypes.BackHeaderRoutes, ingtypes.BackQueryRoutes, ingtypes.BackMethodRoutes} {
+				parseRoute := ingutils.ParseRoute
+				if key == ingtypes.BackMethodRoutes {
+					parseRoute = ingutils.ParseMethodRoute
+				}
+				for _, line := range utils.LineToSlice(annBack[key]) {
+					route, err := parseRoute(line)
+					if err != nil {
+						// invalid routes are logged by the updater
+						continue
+					}
+					_, err = c.addBackend(source, pathLink, ing.Namespace+"/"+route.Service, route.Port, map[string]string{})
+					if err != nil {
+						c.logger.Warn("skipping %s on %v: %v", key, source, err)
+						source.RecordWarning(c.cache, "InvalidBackend", fmt.Sprintf("%s was ignored: %v", key, err))
 					}
 				}
 			}
@@ -623,6 +804,7 @@ func (c *converter) syncIngressTCP(source *annotations.Source, ing *networking.I
 		err := addIngressBackend("", ing.Spec.DefaultBackend)
 		if err != nil {
 			c.logger.Warn("skipping default backend on %v: %v", source, err)
+			source.RecordWarning(c.cache, "InvalidBackend", fmt.Sprintf("default backend was ignored: %v", err))
 		}
 	}
 	for _, rule := range ing.Spec.Rules {
@@ -637,6 +819,7 @@ func (c *converter) syncIngressTCP(source *annotations.Source, ing *networking.I
 			err := addIngressBackend(rule.Host, &path.Backend)
 			if err != nil {
 				c.logger.Warn("skipping path declaration on %v: %v", source, err)
+				source.RecordWarning(c.cache, "InvalidBackend", fmt.Sprintf("backend of host '%s' was ignored: %v", rule.Host, err))
 			}
 		}
 	}
@@ -788,10 +971,57 @@ func (c *converter) addDefaultHostBackend(source *annotations.Source, fullSvcNam
 		return err
 	}
 	host := c.addHost(hostname, source, annHost)
-	host.AddPath(backend, uri, match)
+	host.AddPath(backend, uri, match).Source = hatypes.PathSource{Namespace: source.Namespace, Name: source.Name}
 	return nil
 }
 
+var hostDefaultBackendRegex = regexp.MustCompile(`^([a-z0-9]([-a-z0-9]*[a-z0-9])?)(:([-a-z0-9]+))?$`)
+
+// syncHostDefaultBackends adds the catch-all path of the hosts that declare
+// their own default backend. This should be called after all the ingress
+// resources of the hosts are parsed, so a root path explicitly declared in
+// any of them has precedence.
+func (c *converter) syncHostDefaultBackends(hosts map[string]*hatypes.Host) {
+	hostnames := make([]string, 0, len(hosts))
+	for hostname := range hosts {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+	for _, hostname := range hostnames {
+		host := hosts[hostname]
+		mapper, found := c.hostAnnotations[host]
+		if !found {
+			continue
+		}
+		config := mapper.Get(ingtypes.HostDefaultBackendService)
+		if config.Source == nil || config.Value == "" {
+			continue
+		}
+		if mapper.Get(ingtypes.HostSSLPassthrough).Bool() {
+			c.logger.Warn("ignoring %s on %v: cannot be used on ssl-passthrough hosts", ingtypes.HostDefaultBackendService, config.Source)
+			continue
+		}
+		uri := "/"
+		match := hatypes.MatchBegin
+		if host.FindPath(uri, match) != nil {
+			c.logger.Warn("ignoring %s on %v: path '%s' was already declared on host '%s'", ingtypes.HostDefaultBackendService, config.Source, uri, hostname)
+			continue
+		}
+		svc := hostDefaultBackendRegex.FindStringSubmatch(config.Value)
+		if svc == nil {
+			c.logger.Warn("ignoring %s on %v: invalid service name, expected '<service>[:<port>]': %s", ingtypes.HostDefaultBackendService, config.Source, config.Value)
+			continue
+		}
+		pathLink := hatypes.CreatePathLink(hostname, uri, match)
+		backend, err := c.addBackend(config.Source, pathLink, config.Source.Namespace+"/"+svc[1], svc[4], map[string]string{})
+		if err != nil {
+			c.logger.Warn("ignoring %s on %v: %v", ingtypes.HostDefaultBackendService, config.Source, err)
+			continue
+		}
+		host.AddPath(backend, uri, match).Source = hatypes.PathSource{Namespace: config.Source.Namespace, Name: config.Source.Name}
+	}
+}
+
 func (c *converter) addTCPService(source *annotations.Source, hostname string, port int, ann map[string]string) (*hatypes.TCPServiceHost, error) {
 	tcpPort, tcpHost := c.haproxy.TCPServices().AcquireTCPService(hostname)
 	if !tcpHost.Backend.IsEmpty() {
@@ -854,8 +1084,6 @@ func (c *converter) addBackendWithClass(source *annotations.Source, pathLink hat
 	}
 	backend := c.haproxy.Backends().AcquireBackend(namespace, svcName, port.TargetPort.String())
 	c.tracker.TrackBackend(convtypes.IngressType, source.FullName(), backend.BackendID())
-	// TODO converg backend Port and DNSPort; see also tmpl's server-template
-	backend.DNSPort = readDNSPort(svc.Spec.ClusterIP == api.ClusterIPNone, port)
 	mapper, found := c.backendAnnotations[backend]
 	if !found {
 		// New backend, initialize with service annotations, giving precedence
@@ -868,40 +1096,41 @@ func (c *converter) addBackendWithClass(source *annotations.Source, pathLink hat
 		}, pathLink, ann)
 		c.backendAnnotations[backend] = mapper
 	}
+	c.addBackendResourceConfig(mapper, namespace, svcName, pathLink)
 	// Merging Ingress annotations
 	conflict := mapper.AddAnnotations(source, pathLink, ann)
 	if len(conflict) > 0 {
 		c.logger.Warn("skipping backend '%s:%s' annotation(s) from %v due to conflict: %v",
 			svcName, svcPort, source, conflict)
 	}
-	// Merging IngressClass Parameters with less priority
-	if ingressClass != nil {
-		if cfg := c.readParameters(ingressClass, hostname); cfg != nil {
-			// Using a work around to add a per resource default config:
-			// we add IngressClass Parameters after service and ingress annotations,
-			// ignoring conflicts. This would really conflict with other Parameters
-			// only if the same host+path is declared twice, but such duplication is
-			// already filtred out in the ingress parsing.
-			_ = mapper.AddAnnotations(source, pathLink, cfg)
-		}
-	}
+	c.addMiddlewareConfig(mapper, source, pathLink, utils.Split(ann[ingtypes.BackMiddlewares], ","))
+	c.addAuthConfig(mapper, source, pathLink, ann[ingtypes.BackAuthConfig])
+	c.addHostResourceConfig(mapper, pathLink)
+	c.addIngressClassConfig(mapper, source, pathLink, ingressClass)
+	// TODO converg backend Port and DNSPort; see also tmpl's server-template
+	backend.DNSPort = readDNSPort(svc.Spec.ClusterIP == api.ClusterIPNone, port, mapper.Get(ingtypes.BackUseResolverSRV).Bool())
 	// Configure endpoints
 	if !found {
-		backend.Server.InitialWeight = mapper.Get(ingtypes.BackInitialWeight).Int()
-		switch mapper.Get(ingtypes.BackBackendServerNaming).Value {
-		case "ip":
-			backend.EpNaming = hatypes.EpIPPort
-		case "pod":
-			backend.EpNaming = hatypes.EpTargetRef
-		default:
-			backend.EpNaming = hatypes.EpSequence
-		}
+		initBackendServers(backend, mapper)
 		if mapper.Get(ingtypes.BackServiceUpstream).Bool() {
 			if addr, err := convutils.CreateSvcEndpoint(svc, port); err == nil {
 				backend.AcquireEndpoint(addr.IP, addr.Port, addr.TargetRef)
 			} else {
 				c.logger.Error("error adding IP of service '%s': %v", fullSvcName, err)
 			}
+		} else if svc.Spec.Type == api.ServiceTypeExternalName && mapper.Get(ingtypes.BackUseResolver).Value != "" {
+			// HAProxy resolves the external name in runtime, the lookup here
+			// is only used to find how many server slots should be created
+			backend.ExternalName = svc.Spec.ExternalName
+			// the external name doesn't have the SRV records of the named ports,
+			// so the port number of the service is always used
+			backend.DNSPort = strconv.Itoa(int(port.Port))
+			if err := c.addEndpoints(svc, port, backend); err != nil {
+				c.logger.Warn("error resolving external name of service '%s', using one server slot: %v", fullSvcName, err)
+			}
+			if len(backend.Endpoints) == 0 {
+				backend.AddEmptyEndpoint()
+			}
 		} else {
 			if err := c.addEndpoints(svc, port, backend); err != nil {
 				c.logger.Error("error adding endpoints of service '%s': %v", fullSvcName, err)
@@ -911,7 +1140,162 @@ func (c *converter) addBackendWithClass(source *annotations.Source, pathLink hat
 	return backend, nil
 }
 
-func readDNSPort(headlessService bool, port *api.ServicePort) string {
+// addServiceImportBackend adds a backend whose endpoints are the merged
+// endpoints of all the clusters exporting a service. ServiceImports are
+// tracked as services, using convutils.ServiceImportSuffix in the name so
+// they don't conflict with a local service of the same name.
+func (c *converter) addServiceImportBackend(source *annotations.Source, pathLink hatypes.PathLink, fullImportName string, ann map[string]string, ingressClass *networking.IngressClass) (*hatypes.Backend, error) {
+	svcImport, err := c.cache.GetServiceImport(source.Namespace, fullImportName)
+	hostname := pathLink.Hostname()
+	fullSvcName := fullImportName + convutils.ServiceImportSuffix
+	if err != nil {
+		c.tracker.TrackMissingOnHostname(convtypes.ServiceType, fullSvcName, hostname)
+		return nil, err
+	}
+	c.tracker.TrackHostname(convtypes.ServiceType, fullSvcName, hostname)
+	if len(svcImport.Spec.Ports) == 0 {
+		return nil, fmt.Errorf("service import '%s' does not declare any port", fullImportName)
+	}
+	// resource backends do not declare a port, the first one is used
+	port := &svcImport.Spec.Ports[0]
+	svcName := svcImport.Name + convutils.ServiceImportSuffix
+	backend := c.haproxy.Backends().AcquireBackend(svcImport.Namespace, svcName, strconv.Itoa(int(port.Port)))
+	c.tracker.TrackBackend(convtypes.IngressType, source.FullName(), backend.BackendID())
+	mapper, found := c.backendAnnotations[backend]
+	if !found {
+		mapper = c.mapBuilder.NewMapper()
+		c.backendAnnotations[backend] = mapper
+	}
+	conflict := mapper.AddAnnotations(source, pathLink, ann)
+	if len(conflict) > 0 {
+		c.logger.Warn("skipping backend '%s:%d' annotation(s) from %v due to conflict: %v",
+			svcName, port.Port, source, conflict)
+	}
+	c.addMiddlewareConfig(mapper, source, pathLink, utils.Split(ann[ingtypes.BackMiddlewares], ","))
+	c.addAuthConfig(mapper, source, pathLink, ann[ingtypes.BackAuthConfig])
+	c.addHostResourceConfig(mapper, pathLink)
+	c.addIngressClassConfig(mapper, source, pathLink, ingressClass)
+	backend.DNSPort = strconv.Itoa(int(port.Port))
+	if !found {
+		initBackendServers(backend, mapper)
+		if err := c.addServiceImportEndpoints(svcImport, port, backend); err != nil {
+			c.logger.Error("error adding endpoints of service import '%s': %v", fullImportName, err)
+		}
+	}
+	return backend, nil
+}
+
+// addBackendResourceConfig merges the configuration of the Backend resource
+// that configures the service. It has less priority than the annotations of
+// the service, and more priority than the annotations of the ingress.
+func (c *converter) addBackendResourceConfig(mapper *annotations.Mapper, namespace, svcName string, pathLink hatypes.PathLink) {
+	if backendConfig, found := c.resourceConfigs.Backends[namespace+"/"+svcName]; found {
+		source := &annotations.Source{
+			Namespace: backendConfig.Namespace,
+			Name:      backendConfig.Name,
+			Type:      "Backend",
+		}
+		if conflict := mapper.AddAnnotations(source, pathLink, backendConfig.Config); len(conflict) > 0 {
+			c.logger.Warn("skipping %s configuration(s) due to conflict: %v", source, conflict)
+		}
+	}
+}
+
+// addMiddlewareConfig merges the configuration of the Middleware resources
+// referenced by an ingress or HTTPRoute, which should be in the same
+// namespace. It has less priority than the annotations, and more priority
+// than the Host resource. The first Middleware of the list has precedence.
+func (c *converter) addMiddlewareConfig(mapper *annotations.Mapper, source *annotations.Source, pathLink hatypes.PathLink, middlewares []string) {
+	for _, name := range middlewares {
+		if name == "" {
+			continue
+		}
+		middlewareConfig, found := c.resourceConfigs.Middlewares[source.Namespace+"/"+name]
+		if !found {
+			c.logger.Warn("skipping Middleware '%s' on %v: Middleware not found", name, source)
+			continue
+		}
+		// conflicts with the annotations and with the former Middlewares are
+		// ignored, the same way of the IngressClass Parameters
+		_ = mapper.AddAnnotations(&annotations.Source{
+			Namespace: middlewareConfig.Namespace,
+			Name:      middlewareConfig.Name,
+			Type:      "Middleware",
+		}, pathLink, middlewareConfig.Config)
+	}
+}
+
+// addAuthConfig merges the configuration of the AuthConfig resource
+// referenced by an ingress or Host resource, which should be in the same
+// namespace. It has less priority than the configuration of the resource
+// that references it. OIDC keys are only read when merged into the
+// mapper of the host.
+func (c *converter) addAuthConfig(mapper *annotations.Mapper, source *annotations.Source, pathLink hatypes.PathLink, name string) {
+	if name == "" {
+		return
+	}
+	authConfig, found := c.resourceConfigs.AuthConfigs[source.Namespace+"/"+name]
+	if !found {
+		c.logger.Warn("skipping AuthConfig '%s' on %v: AuthConfig not found", name, source)
+		return
+	}
+	// conflicts with the referencing resource are ignored, the same way of
+	// the Middlewares
+	_ = mapper.AddAnnotations(&annotations.Source{
+		Namespace: authConfig.Namespace,
+		Name:      authConfig.Name,
+		Type:      "AuthConfig",
+	}, pathLink, authConfig.Config)
+}
+
+// addHostResourceConfig merges the configuration of the Host resource that
+// configures the hostname. It has less priority than the annotations, and
+// more priority than the IngressClass Parameters.
+func (c *converter) addHostResourceConfig(mapper *annotations.Mapper, pathLink hatypes.PathLink) {
+	if hostConfig, found := c.resourceConfigs.Hosts[pathLink.Hostname()]; found {
+		source := &annotations.Source{
+			Namespace: hostConfig.Namespace,
+			Name:      hostConfig.Name,
+			Type:      "Host",
+		}
+		// conflicts with the annotations are ignored, the same way of the
+		// IngressClass Parameters, see addIngressClassConfig()
+		_ = mapper.AddAnnotations(source, pathLink, hostConfig.Config)
+		c.addAuthConfig(mapper, source, pathLink, hostConfig.Config[ingtypes.BackAuthConfig])
+	}
+}
+
+// addIngressClassConfig merges IngressClass Parameters with less priority
+func (c *converter) addIngressClassConfig(mapper *annotations.Mapper, source *annotations.Source, pathLink hatypes.PathLink, ingressClass *networking.IngressClass) {
+	if ingressClass != nil {
+		if cfg := c.readParameters(ingressClass, pathLink.Hostname()); cfg != nil {
+			// Using a work around to add a per resource default config:
+			// we add IngressClass Parameters after service and ingress annotations,
+			// ignoring conflicts. This would really conflict with other Parameters
+			// only if the same host+path is declared twice, but such duplication is
+			// already filtred out in the ingress parsing.
+			_ = mapper.AddAnnotations(source, pathLink, cfg)
+		}
+	}
+}
+
+func initBackendServers(backend *hatypes.Backend, mapper *annotations.Mapper) {
+	backend.Server.InitialWeight = mapper.Get(ingtypes.BackInitialWeight).Int()
+	switch mapper.Get(ingtypes.BackBackendServerNaming).Value {
+	case "ip":
+		backend.EpNaming = hatypes.EpIPPort
+	case "pod":
+		backend.EpNaming = hatypes.EpTargetRef
+	default:
+		backend.EpNaming = hatypes.EpSequence
+	}
+}
+
+func readDNSPort(headlessService bool, port *api.ServicePort, useSRV bool) string {
+	if useSRV && port.Name != "" {
+		// SRV records are only published for named ports
+		return port.Name
+	}
 	targetPort := port.TargetPort.String()
 	targetPortNum, _ := strconv.Atoi(targetPort)
 	if targetPortNum > 0 {
@@ -944,10 +1328,21 @@ func (c *converter) syncBackendEndpointCookies(backend *hatypes.Backend) {
 					}
 				}
 			}
+			if backend.Cookie.Hash && ep.CookieValue != "" {
+				ep.CookieValue = hashCookieValue(c.haproxy.Global().Cookie.Key, ep.CookieValue)
+			}
 		}
 	}
 }
 
+// hashCookieValue creates a keyed hash of a server identifier, so the pod
+// names, UIDs or IPs aren't disclosed in the affinity cookie.
+func hashCookieValue(key, value string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(value))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
 func (c *converter) addTLS(source *annotations.Source, hostname, secretName string) convtypes.CrtFile {
 	if secretName != "" {
 		tlsFile, err := c.cache.GetTLSSecretPath(
@@ -959,6 +1354,7 @@ func (c *converter) addTLS(source *annotations.Source, hostname, secretName stri
 			return tlsFile
 		}
 		c.logger.Warn("using default certificate due to an error reading secret '%s' on %s: %v", secretName, source, err)
+		source.RecordWarning(c.cache, "InvalidSecret", fmt.Sprintf("using default certificate on host '%s': %v", hostname, err))
 	}
 	return c.defaultCrt
 }
@@ -969,11 +1365,15 @@ func (c *converter) addEndpoints(svc *api.Service, svcPort *api.ServicePort, bac
 		return err
 	}
 	for _, addr := range ready {
-		backend.AcquireEndpoint(addr.IP, addr.Port, addr.TargetRef)
+		ep := backend.AcquireEndpoint(addr.IP, addr.Port, addr.TargetRef)
+		ep.Zone = addr.Zone
+		ep.ForZones = strings.Join(addr.ForZones, ",")
 	}
 	if c.globalConfig.Get(ingtypes.GlobalDrainSupport).Bool() {
 		for _, addr := range notReady {
 			ep := backend.AcquireEndpoint(addr.IP, addr.Port, addr.TargetRef)
+			ep.Zone = addr.Zone
+			ep.ForZones = strings.Join(addr.ForZones, ",")
 			ep.Weight = 0
 		}
 		pods, err := c.cache.GetTerminatingPods(svc, convtypes.TrackingTarget{Backend: backend.BackendID()})
@@ -994,6 +1394,67 @@ func (c *converter) addEndpoints(svc *api.Service, svcPort *api.ServicePort, bac
 	return nil
 }
 
+func (c *converter) addServiceImportEndpoints(svcImport *mcsv1alpha1.ServiceImport, port *mcsv1alpha1.ServicePort, backend *hatypes.Backend) error {
+	ready, notReady, err := convutils.CreateServiceImportEndpoints(c.cache, svcImport, port)
+	if err != nil {
+		return err
+	}
+	for _, addr := range ready {
+		ep := backend.AcquireEndpoint(addr.IP, addr.Port, addr.TargetRef)
+		ep.Cluster = addr.Cluster
+		ep.Zone = addr.Zone
+	}
+	if c.globalConfig.Get(ingtypes.GlobalDrainSupport).Bool() {
+		// terminating pods of remote clusters are not available,
+		// only not ready endpoints are drained
+		for _, addr := range notReady {
+			ep := backend.AcquireEndpoint(addr.IP, addr.Port, addr.TargetRef)
+			ep.Cluster = addr.Cluster
+			ep.Zone = addr.Zone
+			ep.Weight = 0
+		}
+	}
+	return nil
+}
+
+var (
+	redirectCodeRegex   = regexp.MustCompile(`^30[12378]$`)
+	redirectSchemeRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*$`)
+)
+
+func (c *converter) addRedirect(source *annotations.Source, host *hatypes.Host, uri string, match hatypes.MatchType, redirectTo string, annBack map[string]string) {
+	redirectType := hatypes.RedirectType(strings.ToLower(c.globalConfig.Get(ingtypes.BackRedirectToType).Value))
+	if value, found := annBack[ingtypes.BackRedirectToType]; found {
+		redirectType = hatypes.RedirectType(strings.ToLower(value))
+	}
+	switch redirectType {
+	case "":
+		redirectType = hatypes.RedirectLocation
+	case hatypes.RedirectLocation, hatypes.RedirectPrefix:
+	case hatypes.RedirectScheme:
+		if !redirectSchemeRegex.MatchString(redirectTo) {
+			c.logger.Warn("skipping redirect of path '%s' on %v: invalid scheme: %s", uri, source, redirectTo)
+			return
+		}
+	default:
+		c.logger.Warn("ignoring invalid redirect-to-type '%s' on %v, using 'location' instead", redirectType, source)
+		redirectType = hatypes.RedirectLocation
+	}
+	// the frontend uses the global code if the ingress doesn't declare its own
+	var redirectCode int
+	if code, found := annBack[ingtypes.BackRedirectToCode]; found {
+		if redirectCodeRegex.MatchString(code) {
+			redirectCode, _ = strconv.Atoi(code)
+		} else {
+			c.logger.Warn("ignoring invalid redirect-to-code '%s' on %v, using the global code instead", code, source)
+		}
+	}
+	host.AddRedirect(uri, match, redirectTo)
+	path := host.FindPath(uri, match)[0]
+	path.RedirToCode = redirectCode
+	path.RedirToType = redirectType
+}
+
 func (c *converter) readAnnotations(source *annotations.Source, ann map[string]string) (annTCP, annHost, annBack map[string]string) {
 	keys := c.readConfigKeys(source, ann)
 	annTCP = make(map[string]string, len(keys))
@@ -1062,7 +1523,10 @@ func (c *converter) parseParameters(ingressClass *networking.IngressClass, track
 	if parameters == nil {
 		return nil
 	}
-	// Currently only ConfigMap is supported
+	if parameters.APIGroup != nil && *parameters.APIGroup == v1alpha1.GroupName {
+		return c.parseParametersResource(ingressClass)
+	}
+	// Other than our own resources, only ConfigMap is supported
 	if parameters.APIGroup != nil && *parameters.APIGroup != "" {
 		c.logger.Warn("unsupported Parameters' APIGroup on IngressClass '%s': %s", ingressClass.Name, *parameters.APIGroup)
 		return nil
@@ -1090,8 +1554,71 @@ func (c *converter) parseParameters(ingressClass *networking.IngressClass, track
 	}
 }
 
+func (c *converter) parseParametersResource(ingressClass *networking.IngressClass) *ingressClassConfig {
+	parameters := ingressClass.Spec.Parameters
+	if parameters.Kind != "IngressClassParameters" {
+		c.logger.Warn("unsupported Parameters' Kind on IngressClass '%s': %s", ingressClass.Name, parameters.Kind)
+		return nil
+	}
+	if parameters.Scope != nil && *parameters.Scope != networking.IngressClassParametersReferenceScopeCluster {
+		c.logger.Warn("unsupported Parameters' Scope on IngressClass '%s': %s, IngressClassParameters is cluster scoped", ingressClass.Name, *parameters.Scope)
+		return nil
+	}
+	params, err := c.cache.GetIngressClassParameters(parameters.Name)
+	if err != nil {
+		c.logger.Warn("error reading IngressClassParameters on IngressClass '%s': %v", ingressClass.Name, err)
+		return nil
+	}
+	// resourceName is not assigned, changes in IngressClassParameters start a full sync
+	return &ingressClassConfig{
+		config: ingressClassParametersConfig(&params.Spec),
+	}
+}
+
+// ingressClassParametersConfig converts the spec of an IngressClassParameters
+// to configuration keys. Typed fields have precedence over the Config field.
+func ingressClassParametersConfig(spec *v1alpha1.IngressClassParametersSpec) map[string]string {
+	config := make(map[string]string, len(spec.Config))
+	for key, value := range spec.Config {
+		config[key] = value
+	}
+	add := func(key, value string) {
+		if value != "" {
+			config[key] = value
+		}
+	}
+	add(ingtypes.HostFrontend, spec.Frontend)
+	if timeouts := spec.Timeouts; timeouts != nil {
+		add(ingtypes.BackTimeoutConnect, timeouts.Connect)
+		add(ingtypes.BackTimeoutHTTPRequest, timeouts.HTTPRequest)
+		add(ingtypes.BackTimeoutKeepAlive, timeouts.KeepAlive)
+		add(ingtypes.BackTimeoutQueue, timeouts.Queue)
+		add(ingtypes.BackTimeoutServer, timeouts.Server)
+		add(ingtypes.BackTimeoutServerFin, timeouts.ServerFin)
+		add(ingtypes.BackTimeoutTunnel, timeouts.Tunnel)
+	}
+	if tls := spec.TLS; tls != nil {
+		add(ingtypes.HostSSLCiphers, tls.Ciphers)
+		add(ingtypes.HostSSLCipherSuites, tls.CipherSuites)
+		add(ingtypes.HostSSLOptionsHost, tls.Options)
+		add(ingtypes.HostTLSALPN, tls.ALPN)
+		if tls.Redirect != nil {
+			config[ingtypes.BackSSLRedirect] = strconv.FormatBool(*tls.Redirect)
+		}
+	}
+	return config
+}
+
+func isServiceImportBackend(backend *networking.IngressBackend) bool {
+	res := backend.Resource
+	return res != nil && res.APIGroup != nil && *res.APIGroup == mcsv1alpha1.GroupName && res.Kind == "ServiceImport"
+}
+
 func readServiceNamePort(backend *networking.IngressBackend) (string, string, error) {
 	if backend.Service == nil {
+		if isServiceImportBackend(backend) {
+			return "", "", fmt.Errorf("ServiceImport backend is only supported on ingress paths")
+		}
 		return "", "", fmt.Errorf("resource backend is not supported yet")
 	}
 	serviceName := backend.Service.Name
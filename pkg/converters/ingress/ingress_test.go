@@ -24,6 +24,7 @@ import (
 
 	"github.com/kylelemons/godebug/diff"
 	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networking "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -31,6 +32,8 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/scheme"
 
+	mcsv1alpha1 "github.com/jcmoraisjr/haproxy-ingress/pkg/api/mcs/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
 	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
@@ -61,6 +64,8 @@ func TestSyncSvcNotFound(t *testing.T) {
 
 	c.logger.CompareLogging(`
 WARN skipping backend config of ingress 'default/echo': service not found: 'default/notfound'`)
+	c.compareEvents(`
+ingress default/echo Warning InvalidBackend: path '/' of host 'echo.example.com' was ignored: service not found: 'default/notfound'`)
 }
 
 func TestSyncDefaultSvcNotFound(t *testing.T) {
@@ -175,6 +180,91 @@ func TestSyncSvcUpstream(t *testing.T) {
     port: 8080` + defaultBackendConfig)
 }
 
+func TestSyncSvcExternalNameResolver(t *testing.T) {
+	testCases := []struct {
+		port       string
+		ann        map[string]string
+		backPort   string
+		expDNSPort string
+	}{
+		// 0
+		{
+			port:       "8080",
+			backPort:   "8080",
+			expDNSPort: "8080",
+		},
+		// 1
+		{
+			port:       "http:8080:web",
+			backPort:   "web",
+			expDNSPort: "8080",
+		},
+		// 2
+		{
+			port: "http:8080:8080",
+			ann: map[string]string{
+				"ingress.kubernetes.io/use-resolver-srv": "true",
+			},
+			backPort:   "8080",
+			expDNSPort: "8080",
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		ann := map[string]string{
+			"ingress.kubernetes.io/use-resolver": "kube-dns",
+		}
+		for k, v := range test.ann {
+			ann[k] = v
+		}
+		svc, _ := c.createSvc1Ann("default/echo", test.port, "", ann)
+		svc.Spec.Type = api.ServiceTypeExternalName
+		svc.Spec.ExternalName = "localhost"
+		c.Sync(
+			c.createIng1("default/echo1", "echo1.example.com", "/", "echo:8080"),
+		)
+		backend := c.hconfig.Backends().FindBackend("default", "echo", test.backPort)
+		if backend == nil {
+			t.Errorf("backend not found on %d", i)
+		} else {
+			if backend.ExternalName != "localhost" {
+				t.Errorf("external name differs on %d - expected: localhost - actual: %s", i, backend.ExternalName)
+			}
+			if backend.DNSPort != test.expDNSPort {
+				t.Errorf("dns port differs on %d - expected: %s - actual: %s", i, test.expDNSPort, backend.DNSPort)
+			}
+			if len(backend.Endpoints) == 0 {
+				t.Errorf("expected at least one server slot on %d", i)
+			}
+		}
+		c.teardown()
+	}
+}
+
+func TestSyncRoutes(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1("default/echo", "8080", "172.17.0.11")
+	c.createSvc1("default/echo-beta", "8080", "172.17.0.12")
+	c.createSvc1("default/echo-acme", "8080", "172.17.0.13")
+	c.createSvc1("default/echo-write", "8080", "172.17.0.14")
+	c.Sync(
+		c.createIng1Ann("default/echo", "echo.example.com", "/", "echo:8080", map[string]string{
+			"ingress.kubernetes.io/header-routes": "X-Tenant=acme echo-acme:8080",
+			"ingress.kubernetes.io/method-routes": "POST,PUT echo-write:8080",
+			"ingress.kubernetes.io/query-routes":  "version=beta echo-beta:8080\nversion=v3 echo-v3:8080",
+		}),
+	)
+
+	for _, name := range []string{"echo-acme", "echo-beta", "echo-write"} {
+		if c.hconfig.Backends().FindBackend("default", name, "8080") == nil {
+			t.Errorf("expected backend of the alternate route '%s'", name)
+		}
+	}
+	c.logger.CompareLogging(`WARN skipping query-routes on ingress 'default/echo': service not found: 'default/echo-v3'`)
+}
+
 func TestSyncSingle(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -280,10 +370,8 @@ func TestSyncDrainSupport(t *testing.T) {
 
 	svc, ep := c.createSvc1("default/echo", "http:8080:http", "172.17.1.101,172.17.1.102")
 	svcName := svc.Namespace + "/" + svc.Name
-	ss := &ep.Subsets[0]
-	addr := ss.Addresses
-	ss.Addresses = []api.EndpointAddress{addr[0]}
-	ss.NotReadyAddresses = []api.EndpointAddress{addr[1]}
+	notReady := false
+	ep.Endpoints[1].Conditions.Ready = &notReady
 	pod1 := c.createPod1("default/echo-xxxxx", "172.17.1.103", "http:8080")
 	pod2 := c.createPod1("default/echo-yyyyy", "172.17.1.104", "none:8080")
 	c.cache.TermPodList[svcName] = []*api.Pod{pod1, pod2}
@@ -319,6 +407,52 @@ func TestSyncDrainSupport(t *testing.T) {
 	c.logger.CompareLogging("WARN skipping endpoint 172.17.1.104 of service default/echo: port 'http' was not found")
 }
 
+func TestSyncServiceImport(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1Auto()
+	c.createSvcImport1("default/echo", "http:8080", "cluster-a=172.17.1.101,172.17.1.102;cluster-b=172.18.1.101")
+	c.Sync(
+		c.createIngSvcImport1("default/echo1", "echo.example.com", "/app", "echo"),
+		c.createIngSvcImport1("default/echo2", "echo.example.com", "/other", "other"),
+		c.createIng1("default/echo3", "echo.example.com", "/", "echo:8080"),
+	)
+
+	c.compareConfigFront(`
+- hostname: echo.example.com
+  paths:
+  - path: /app
+    backend: default_echo.clusterset_8080
+  - path: /
+    backend: default_echo_8080`)
+
+	c.compareConfigBack(`
+- id: default_echo.clusterset_8080
+  endpoints:
+  - ip: 172.17.1.101
+    port: 8080
+  - ip: 172.17.1.102
+    port: 8080
+  - ip: 172.18.1.101
+    port: 8080
+- id: default_echo_8080
+  endpoints:
+  - ip: 172.17.0.11
+    port: 8080` + defaultBackendConfig)
+
+	clusters := []string{}
+	for _, ep := range c.hconfig.Backends().FindBackend("default", "echo.clusterset", "8080").Endpoints {
+		clusters = append(clusters, ep.Cluster)
+	}
+	if expected := []string{"cluster-a", "cluster-a", "cluster-b"}; !reflect.DeepEqual(clusters, expected) {
+		t.Errorf("clusters differ, expected: %v, actual: %v", expected, clusters)
+	}
+
+	c.logger.CompareLogging(`
+WARN skipping backend config of ingress 'default/echo2': service import not found: 'default/other'`)
+}
+
 func TestSyncRootPathLast(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -454,6 +588,8 @@ func TestSyncTLSSecretNotFound(t *testing.T) {
 
 	c.logger.CompareLogging(`
 WARN using default certificate due to an error reading secret 'ing-tls' on ingress 'default/echo': secret not found: 'default/ing-tls'`)
+	c.compareEvents(`
+ingress default/echo Warning InvalidSecret: using default certificate on host 'echo.example.com': secret not found: 'default/ing-tls'`)
 }
 
 func TestSyncTLSCustom(t *testing.T) {
@@ -603,8 +739,13 @@ func TestSyncTLSSecretWithoutHost(t *testing.T) {
 
 func TestSyncIngressClass(t *testing.T) {
 	apiGroup1 := "some.io"
+	apiGroup2 := v1alpha1.GroupName
+	scopeNamespace := networking.IngressClassParametersReferenceScopeNamespace
+	scopeCluster := networking.IngressClassParametersReferenceScopeCluster
+	redirect := false
 	testCases := []struct {
 		parameters *networking.IngressClassParametersReference
+		expected   map[string]string
 		logging    string
 	}{
 		// 0
@@ -640,10 +781,79 @@ func TestSyncIngressClass(t *testing.T) {
 			},
 			logging: ``,
 		},
+		// 4
+		{
+			parameters: &networking.IngressClassParametersReference{
+				APIGroup: &apiGroup2,
+				Kind:     "TCPService",
+				Name:     "params",
+			},
+			logging: `WARN unsupported Parameters' Kind on IngressClass 'haproxy-config': TCPService`,
+		},
+		// 5
+		{
+			parameters: &networking.IngressClassParametersReference{
+				APIGroup: &apiGroup2,
+				Kind:     "IngressClassParameters",
+				Name:     "params",
+				Scope:    &scopeNamespace,
+			},
+			logging: `WARN unsupported Parameters' Scope on IngressClass 'haproxy-config': Namespace, IngressClassParameters is cluster scoped`,
+		},
+		// 6
+		{
+			parameters: &networking.IngressClassParametersReference{
+				APIGroup: &apiGroup2,
+				Kind:     "IngressClassParameters",
+				Name:     "none",
+			},
+			logging: `WARN error reading IngressClassParameters on IngressClass 'haproxy-config': IngressClassParameters not found: none`,
+		},
+		// 7
+		{
+			parameters: &networking.IngressClassParametersReference{
+				APIGroup: &apiGroup2,
+				Kind:     "IngressClassParameters",
+				Name:     "params",
+				Scope:    &scopeCluster,
+			},
+			expected: map[string]string{
+				"balance-algorithm":  "leastconn",
+				"frontend":           "internal",
+				"ssl-ciphers":        "ECDHE-ECDSA-AES128-GCM-SHA256",
+				"ssl-options-host":   "ssl-min-ver TLSv1.2",
+				"ssl-redirect":       "false",
+				"timeout-connect":    "2s",
+				"timeout-server":     "1m",
+				"timeout-server-fin": "10s",
+			},
+		},
 	}
-	for _, test := range testCases {
+	for i, test := range testCases {
 		c := setup(t)
 		c.cache.ConfigMapList = map[string]*api.ConfigMap{"ingress-controller/config": {}}
+		c.cache.IngClassPars = []*v1alpha1.IngressClassParameters{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "params",
+			},
+			Spec: v1alpha1.IngressClassParametersSpec{
+				Frontend: "internal",
+				Timeouts: &v1alpha1.IngressClassTimeouts{
+					Connect:   "2s",
+					Server:    "1m",
+					ServerFin: "10s",
+				},
+				TLS: &v1alpha1.IngressClassTLS{
+					Ciphers:  "ECDHE-ECDSA-AES128-GCM-SHA256",
+					Options:  "ssl-min-ver TLSv1.2",
+					Redirect: &redirect,
+				},
+				Config: map[string]string{
+					"balance-algorithm": "leastconn",
+					"timeout-connect":   "5s",
+				},
+			},
+		}}
 		c.cache.SecretTLSPath["system/default"] = "/tls/tls-default.pem"
 		conv := c.createConverter()
 		ingClass := networking.IngressClass{
@@ -654,7 +864,10 @@ func TestSyncIngressClass(t *testing.T) {
 				Parameters: test.parameters,
 			},
 		}
-		_ = conv.readParameters(&ingClass, "echo.example.com")
+		config := conv.readParameters(&ingClass, "echo.example.com")
+		if !reflect.DeepEqual(config, test.expected) {
+			t.Errorf("config differs on %d - expected: %v - actual: %v", i, test.expected, config)
+		}
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
@@ -796,6 +1009,30 @@ func TestPathType(t *testing.T) {
 	}
 }
 
+func TestPathTypeRegex(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1Auto()
+	ann := map[string]string{"ingress.kubernetes.io/path-type": "regex"}
+	c.Sync(
+		c.createIng1Ann("default/echo1", "echo.example.com", "/app[0-9]+", "echo:8080", ann),
+		c.createIng1Ann("default/echo2", "echo.example.com", "/app(", "echo:8080", ann),
+		c.createIng1Ann("default/echo3", "echo.example.com", "/app /sub", "echo:8080", ann),
+	)
+
+	c.compareConfigFront(`
+- hostname: echo.example.com
+  paths:
+  - path: /app[0-9]+
+    match: regex
+    backend: default_echo_8080`)
+
+	c.logger.CompareLogging(`
+WARN skipping path '/app(' on ingress 'default/echo2': invalid regex: error parsing regexp: missing closing ): ` + "`/app(`" + `
+WARN skipping path '/app /sub' on ingress 'default/echo3': regex path cannot have whitespaces`)
+}
+
 func TestSyncBackendDefault(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -828,6 +1065,8 @@ func TestSyncBackendSvcNotFound(t *testing.T) {
 
 	c.logger.CompareLogging(`
 WARN skipping default backend of ingress 'default/echo': service not found: 'default/notfound'`)
+	c.compareEvents(`
+ingress default/echo Warning InvalidBackend: default backend was ignored: service not found: 'default/notfound'`)
 }
 
 func TestSyncBackendReuseDefaultSvc(t *testing.T) {
@@ -892,6 +1131,130 @@ func TestSyncDefaultBackendReusedPath2(t *testing.T) {
 WARN skipping redeclared path '/' type 'begin' on ingress 'default/echo2'`)
 }
 
+func TestSyncEndpointCookieHash(t *testing.T) {
+	testCases := []struct {
+		hash     bool
+		dynamic  bool
+		expected []string
+	}{
+		// 0
+		{
+			expected: []string{"srv001", "srv002"},
+		},
+		// 1
+		{
+			hash:     true,
+			expected: []string{"16cd08a037e10989", "9b7a7c9706483536"},
+		},
+		// 2
+		{
+			hash:     true,
+			dynamic:  true,
+			expected: []string{"", ""},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.SecretTLSPath["system/default"] = "/tls/tls-default.pem"
+		conv := c.createConverter()
+		c.hconfig.Global().Cookie.Key = "s3cr3t"
+		b := c.hconfig.Backends().AcquireBackend("default", "echo", "8080")
+		b.Cookie.Name = "ingress"
+		b.Cookie.Dynamic = test.dynamic
+		b.Cookie.Hash = test.hash
+		b.AcquireEndpoint("172.17.0.11", 8080, "")
+		b.AcquireEndpoint("172.17.0.12", 8080, "")
+		conv.syncBackendEndpointCookies(b)
+		var actual []string
+		for _, ep := range b.Endpoints {
+			actual = append(actual, ep.CookieValue)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("cookie values differ on %d - expected: %v - actual: %v", i, test.expected, actual)
+		}
+		c.teardown()
+	}
+}
+
+func TestSyncHostDefaultBackend(t *testing.T) {
+	type ing struct {
+		name, path, service string
+		ann                 map[string]string
+	}
+	testCases := []struct {
+		ings    []ing
+		front   string
+		logging string
+	}{
+		// 0
+		{
+			ings: []ing{
+				{"default/echo1", "/app", "echo1:8080", map[string]string{"ingress.kubernetes.io/default-backend-service": "echo2:8080"}},
+			},
+			front: `
+- hostname: echo.example.com
+  paths:
+  - path: /app
+    backend: default_echo1_8080
+  - path: /
+    backend: default_echo2_8080`,
+		},
+		// 1
+		{
+			ings: []ing{
+				{"default/echo1", "/app", "echo1:8080", map[string]string{"ingress.kubernetes.io/default-backend-service": "echo2"}},
+				{"default/echo3", "/", "echo3:8080", nil},
+			},
+			front: `
+- hostname: echo.example.com
+  paths:
+  - path: /app
+    backend: default_echo1_8080
+  - path: /
+    backend: default_echo3_8080`,
+			logging: `WARN ignoring default-backend-service on ingress 'default/echo1': path '/' was already declared on host 'echo.example.com'`,
+		},
+		// 2
+		{
+			ings: []ing{
+				{"default/echo1", "/app", "echo1:8080", map[string]string{"ingress.kubernetes.io/default-backend-service": "echo4:8080"}},
+			},
+			front: `
+- hostname: echo.example.com
+  paths:
+  - path: /app
+    backend: default_echo1_8080`,
+			logging: `WARN ignoring default-backend-service on ingress 'default/echo1': service not found: 'default/echo4'`,
+		},
+		// 3
+		{
+			ings: []ing{
+				{"default/echo1", "/app", "echo1:8080", map[string]string{"ingress.kubernetes.io/default-backend-service": "other/echo2:8080"}},
+			},
+			front: `
+- hostname: echo.example.com
+  paths:
+  - path: /app
+    backend: default_echo1_8080`,
+			logging: `WARN ignoring default-backend-service on ingress 'default/echo1': invalid service name, expected '<service>[:<port>]': other/echo2:8080`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.createSvc1("default/echo1", "8080", "172.17.0.11")
+		c.createSvc1("default/echo2", "8080", "172.17.0.12")
+		c.createSvc1("default/echo3", "8080", "172.17.0.13")
+		ings := make([]*networking.Ingress, len(test.ings))
+		for j, ing := range test.ings {
+			ings[j] = c.createIng1Ann(ing.name, "echo.example.com", ing.path, ing.service, ing.ann)
+		}
+		c.Sync(ings...)
+		c.compareConfigFront(test.front)
+		c.logger.CompareLoggingID(strconv.Itoa(i), test.logging)
+		c.teardown()
+	}
+}
+
 func TestSyncEmptyHTTP(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -1432,7 +1795,7 @@ WARN using default certificate due to an error reading secret 'default/tls1' on
 				*slice = append(*slice, secret)
 			}
 		}
-		endp := func(slice *[]*api.Endpoints, params [][]string) {
+		endp := func(slice *[]*discoveryv1.EndpointSlice, params [][]string) {
 			for _, param := range params {
 				_, ep := conv_helper.CreateService(param[0], param[1], param[2])
 				*slice = append(*slice, ep)
@@ -1476,7 +1839,7 @@ func TestSyncPartialDefaultBackend(t *testing.T) {
 
 	// the mock of the default backend is hardcoded to system/default:8080 at 172.17.0.99
 	_, ep := conv_helper.CreateService("system/default", "8080", "172.17.0.90")
-	c.cache.Changed.EndpointsNew = []*api.Endpoints{ep}
+	c.cache.Changed.EndpointsNew = []*discoveryv1.EndpointSlice{ep}
 	c.Sync()
 
 	c.compareConfigFront(`[]`)
@@ -1960,6 +2323,62 @@ func TestSyncAnnFrontDefault(t *testing.T) {
     backend: default_echo_8080`)
 }
 
+func TestReadDNSPort(t *testing.T) {
+	testCases := []struct {
+		headless bool
+		port     api.ServicePort
+		useSRV   bool
+		expected string
+	}{
+		// 0
+		{
+			port:     api.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)},
+			expected: "80",
+		},
+		// 1
+		{
+			headless: true,
+			port:     api.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)},
+			expected: "8080",
+		},
+		// 2
+		{
+			port:     api.ServicePort{Name: "http", Port: 80, TargetPort: intstr.FromString("web")},
+			expected: "http",
+		},
+		// 3
+		{
+			port:     api.ServicePort{Port: 80, TargetPort: intstr.FromString("web")},
+			expected: "web",
+		},
+		// 4
+		{
+			headless: true,
+			port:     api.ServicePort{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080)},
+			expected: "8080",
+		},
+		// 5
+		{
+			headless: true,
+			port:     api.ServicePort{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080)},
+			useSRV:   true,
+			expected: "http",
+		},
+		// 6
+		{
+			port:     api.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)},
+			useSRV:   true,
+			expected: "80",
+		},
+	}
+	for i, test := range testCases {
+		actual := readDNSPort(test.headless, &test.port, test.useSRV)
+		if actual != test.expected {
+			t.Errorf("dns port differs on %d - expected: %s - actual: %s", i, test.expected, actual)
+		}
+	}
+}
+
 func TestSyncAnnBack(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -2036,6 +2455,210 @@ func TestSyncAnnBacksSvcIng(t *testing.T) {
   maxconnserver: 10` + defaultBackendConfig)
 }
 
+func TestSyncAnnHostResource(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.resConfigs.Hosts = map[string]*convtypes.ResourceConfig{
+		"echo.example.com": {
+			Namespace: "default",
+			Name:      "echo",
+			Config: map[string]string{
+				"app-root":          "/app",
+				"balance-algorithm": "leastconn",
+				"maxconn-server":    "10",
+			},
+		},
+	}
+	c.createSvc1Auto()
+	c.Sync(
+		c.createIng1Ann("default/echo1", "echo.example.com", "/", "echo:8080", map[string]string{
+			"ingress.kubernetes.io/balance-algorithm": "first",
+		}),
+		c.createIng1("default/echo2", "echo2.example.com", "/", "echo:8080"),
+	)
+
+	c.compareConfigFront(`
+- hostname: echo.example.com
+  paths:
+  - path: /
+    backend: default_echo_8080
+  rootredirect: /app
+- hostname: echo2.example.com
+  paths:
+  - path: /
+    backend: default_echo_8080`)
+
+	c.compareConfigBack(`
+- id: default_echo_8080
+  endpoints:
+  - ip: 172.17.0.11
+    port: 8080
+  balancealgorithm: first
+  maxconnserver: 10` + defaultBackendConfig)
+}
+
+func TestSyncAnnBackendResource(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.resConfigs.Backends = map[string]*convtypes.ResourceConfig{
+		"default/echo": {
+			Namespace: "default",
+			Name:      "echo",
+			Config: map[string]string{
+				"balance-algorithm": "leastconn",
+				"maxconn-server":    "10",
+			},
+		},
+	}
+	c.createSvc1AutoAnn(map[string]string{
+		"ingress.kubernetes.io/maxconn-server": "20",
+	})
+	c.Sync(c.createIng1Ann("default/echo", "echo.example.com", "/", "echo:8080", map[string]string{
+		"ingress.kubernetes.io/balance-algorithm": "first",
+	}))
+
+	c.compareConfigBack(`
+- id: default_echo_8080
+  endpoints:
+  - ip: 172.17.0.11
+    port: 8080
+  balancealgorithm: leastconn
+  maxconnserver: 20` + defaultBackendConfig)
+
+	c.logger.CompareLogging(`
+WARN skipping Backend 'default/echo' configuration(s) due to conflict: [maxconn-server]
+WARN skipping backend 'echo:8080' annotation(s) from ingress 'default/echo' due to conflict: [balance-algorithm]`)
+}
+
+func TestSyncAnnMiddlewareResource(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.resConfigs.Middlewares = map[string]*convtypes.ResourceConfig{
+		"default/limits": {
+			Namespace: "default",
+			Name:      "limits",
+			Config: map[string]string{
+				"balance-algorithm": "leastconn",
+				"maxconn-server":    "10",
+			},
+		},
+		"default/queue": {
+			Namespace: "default",
+			Name:      "queue",
+			Config: map[string]string{
+				"maxconn-server":  "20",
+				"proxy-body-size": "32768",
+			},
+		},
+		"other/timeouts": {
+			Namespace: "other",
+			Name:      "timeouts",
+			Config: map[string]string{
+				"timeout-server": "1m",
+			},
+		},
+	}
+	c.createSvc1Auto()
+	c.Sync(c.createIng1Ann("default/echo", "echo.example.com", "/", "echo:8080", map[string]string{
+		"ingress.kubernetes.io/balance-algorithm": "first",
+		"ingress.kubernetes.io/middlewares":       "limits, queue, timeouts",
+	}))
+
+	c.compareConfigBack(`
+- id: default_echo_8080
+  endpoints:
+  - ip: 172.17.0.11
+    port: 8080
+  paths:
+  - path: /
+    match: begin
+    maxbodysize: 32768
+  balancealgorithm: first
+  maxconnserver: 10` + defaultBackendConfig)
+
+	c.logger.CompareLogging(`
+WARN skipping Middleware 'timeouts' on ingress 'default/echo': Middleware not found`)
+}
+
+func TestSyncAnnAuthConfigResource(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.resConfigs.AuthConfigs = map[string]*convtypes.ResourceConfig{
+		"default/limits": {
+			Namespace: "default",
+			Name:      "limits",
+			Config: map[string]string{
+				"balance-algorithm": "leastconn",
+				"maxconn-server":    "10",
+			},
+		},
+		"default/queue": {
+			Namespace: "default",
+			Name:      "queue",
+			Config: map[string]string{
+				"maxconn-server":  "20",
+				"proxy-body-size": "32768",
+			},
+		},
+	}
+	c.resConfigs.Hosts = map[string]*convtypes.ResourceConfig{
+		"echo2.example.com": {
+			Namespace: "default",
+			Name:      "echo2",
+			Config: map[string]string{
+				"auth-config": "queue",
+			},
+		},
+		"echo3.example.com": {
+			Namespace: "default",
+			Name:      "echo3",
+			Config: map[string]string{
+				"auth-config": "missing",
+			},
+		},
+	}
+	c.createSvc1("default/echo1", "8080", "172.17.0.11")
+	c.createSvc1("default/echo2", "8080", "172.17.0.12")
+	c.createSvc1("default/echo3", "8080", "172.17.0.13")
+	c.Sync(
+		c.createIng1Ann("default/echo1", "echo1.example.com", "/", "echo1:8080", map[string]string{
+			"ingress.kubernetes.io/balance-algorithm": "first",
+			"ingress.kubernetes.io/auth-config":       "limits",
+		}),
+		c.createIng1("default/echo2", "echo2.example.com", "/", "echo2:8080"),
+		c.createIng1("default/echo3", "echo3.example.com", "/", "echo3:8080"),
+	)
+
+	c.compareConfigBack(`
+- id: default_echo1_8080
+  endpoints:
+  - ip: 172.17.0.11
+    port: 8080
+  balancealgorithm: first
+  maxconnserver: 10
+- id: default_echo2_8080
+  endpoints:
+  - ip: 172.17.0.12
+    port: 8080
+  paths:
+  - path: /
+    match: begin
+    maxbodysize: 32768
+  maxconnserver: 20
+- id: default_echo3_8080
+  endpoints:
+  - ip: 172.17.0.13
+    port: 8080` + defaultBackendConfig)
+
+	c.logger.CompareLogging(`
+WARN skipping AuthConfig 'missing' on Host 'default/echo3': AuthConfig not found
+WARN skipping AuthConfig 'missing' on Host 'default/echo3': AuthConfig not found`)
+}
+
 func TestSyncAnnBackDefault(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -2157,6 +2780,91 @@ func TestSyncAnnAuthURL(t *testing.T) {
 	c.logger.CompareLogging(`WARN skipping auth-url on ingress 'default/echo2': service not found: 'default/authsvc2'`)
 }
 
+func TestSyncAnnRedirectTo(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		expTo    string
+		expCode  int
+		expType  hatypes.RedirectType
+		expFound bool
+		logging  string
+	}{
+		// 0
+		{
+			ann: map[string]string{
+				"ingress.kubernetes.io/redirect-to": "https://app.local",
+			},
+			expTo:    "https://app.local",
+			expType:  hatypes.RedirectLocation,
+			expFound: true,
+		},
+		// 1
+		{
+			ann: map[string]string{
+				"ingress.kubernetes.io/redirect-to":      "https://app.local",
+				"ingress.kubernetes.io/redirect-to-code": "301",
+				"ingress.kubernetes.io/redirect-to-type": "Prefix",
+			},
+			expTo:    "https://app.local",
+			expCode:  301,
+			expType:  hatypes.RedirectPrefix,
+			expFound: true,
+		},
+		// 2
+		{
+			ann: map[string]string{
+				"ingress.kubernetes.io/redirect-to":      "https",
+				"ingress.kubernetes.io/redirect-to-code": "308",
+				"ingress.kubernetes.io/redirect-to-type": "scheme",
+			},
+			expTo:    "https",
+			expCode:  308,
+			expType:  hatypes.RedirectScheme,
+			expFound: true,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				"ingress.kubernetes.io/redirect-to":      "https://app.local",
+				"ingress.kubernetes.io/redirect-to-code": "200",
+				"ingress.kubernetes.io/redirect-to-type": "permanent",
+			},
+			expTo:    "https://app.local",
+			expType:  hatypes.RedirectLocation,
+			expFound: true,
+			logging: `
+WARN ignoring invalid redirect-to-type 'permanent' on ingress 'default/echo', using 'location' instead
+WARN ignoring invalid redirect-to-code '200' on ingress 'default/echo', using the global code instead`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				"ingress.kubernetes.io/redirect-to":      "https://app.local",
+				"ingress.kubernetes.io/redirect-to-type": "scheme",
+			},
+			logging: `WARN skipping redirect of path '/app' on ingress 'default/echo': invalid scheme: https://app.local`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.createSvc1("default/echo", "8080", "172.17.1.101")
+		c.Sync(c.createIng1Ann("default/echo", "echo.example.com", "/app", "echo:8080", test.ann))
+		paths := c.hconfig.Hosts().AcquireHost("echo.example.com").FindPath("/app")
+		if !test.expFound {
+			if len(paths) > 0 {
+				c.t.Errorf("redirect path found in %d, but it should be skipped", i)
+			}
+		} else if len(paths) == 0 {
+			c.t.Errorf("redirect path not found in %d", i)
+		} else if path := paths[0]; path.RedirTo != test.expTo || path.RedirToCode != test.expCode || path.RedirToType != test.expType {
+			c.t.Errorf("redirect differs in %d: expected '%s' code %d type '%s', but was '%s' code %d type '%s'",
+				i, test.expTo, test.expCode, test.expType, path.RedirTo, path.RedirToCode, path.RedirToType)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestSyncAnnPassthrough(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -2167,13 +2875,16 @@ func TestSyncAnnPassthrough(t *testing.T) {
 		Port:       8443,
 		TargetPort: intstr.FromInt(8443),
 	}
-	epPort := api.EndpointPort{
-		Name:     "https",
-		Port:     8443,
-		Protocol: api.ProtocolTCP,
+	epPortName := "https"
+	epPortNumber := int32(8443)
+	epPortProto := api.ProtocolTCP
+	epPort := discoveryv1.EndpointPort{
+		Name:     &epPortName,
+		Port:     &epPortNumber,
+		Protocol: &epPortProto,
 	}
 	svc.Spec.Ports = append(svc.Spec.Ports, svcPort)
-	ep.Subsets[0].Ports = append(ep.Subsets[0].Ports, epPort)
+	ep.Ports = append(ep.Ports, epPort)
 	c.Sync(
 		c.createIng1Ann("default/echo1", "echo1.example.com", "/", "echo:8443",
 			map[string]string{
@@ -2251,13 +2962,14 @@ WARN skipping redeclared ssl-passthrough root path on ingress 'default/echo2a'
  * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
 
 type testConfig struct {
-	t       *testing.T
-	decode  func(data []byte, defaults *schema.GroupVersionKind, into runtime.Object) (runtime.Object, *schema.GroupVersionKind, error)
-	hconfig haproxy.Config
-	logger  *types_helper.LoggerMock
-	cache   *conv_helper.CacheMock
-	tracker convtypes.Tracker
-	updater *updaterMock
+	t           *testing.T
+	decode      func(data []byte, defaults *schema.GroupVersionKind, into runtime.Object) (runtime.Object, *schema.GroupVersionKind, error)
+	hconfig     haproxy.Config
+	logger      *types_helper.LoggerMock
+	cache       *conv_helper.CacheMock
+	tracker     convtypes.Tracker
+	updater     *updaterMock
+	resConfigs  convtypes.ResourceConfigs
 }
 
 func setup(t *testing.T) *testConfig {
@@ -2331,26 +3043,27 @@ func (c *testConfig) createConverter() *converter {
 		},
 		c.hconfig,
 		c.cache.SwapChangedObjects(),
+		c.resConfigs,
 	).(*converter)
 }
 
-func (c *testConfig) createSvc1Auto() (*api.Service, *api.Endpoints) {
+func (c *testConfig) createSvc1Auto() (*api.Service, *discoveryv1.EndpointSlice) {
 	return c.createSvc1("default/echo", "8080", "172.17.0.11")
 }
 
-func (c *testConfig) createSvc1AutoAnn(ann map[string]string) (*api.Service, *api.Endpoints) {
+func (c *testConfig) createSvc1AutoAnn(ann map[string]string) (*api.Service, *discoveryv1.EndpointSlice) {
 	svc, ep := c.createSvc1Auto()
 	svc.SetAnnotations(ann)
 	return svc, ep
 }
 
-func (c *testConfig) createSvc1Ann(name, port, endpoints string, ann map[string]string) (*api.Service, *api.Endpoints) {
+func (c *testConfig) createSvc1Ann(name, port, endpoints string, ann map[string]string) (*api.Service, *discoveryv1.EndpointSlice) {
 	svc, ep := c.createSvc1(name, port, endpoints)
 	svc.SetAnnotations(ann)
 	return svc, ep
 }
 
-func (c *testConfig) createSvc1(name, port, endpoints string) (*api.Service, *api.Endpoints) {
+func (c *testConfig) createSvc1(name, port, endpoints string) (*api.Service, *discoveryv1.EndpointSlice) {
 	svc, ep := conv_helper.CreateService(name, port, endpoints)
 	// TODO change SvcList to map
 	var has bool
@@ -2368,6 +3081,32 @@ func (c *testConfig) createSvc1(name, port, endpoints string) (*api.Service, *ap
 	return svc, ep
 }
 
+func (c *testConfig) createSvcImport1(name, port, endpoints string) *mcsv1alpha1.ServiceImport {
+	sname := strings.Split(name, "/")
+	sport := strings.Split(port, ":")
+	portNumber, _ := strconv.Atoi(sport[1])
+	svcImport := &mcsv1alpha1.ServiceImport{
+		ObjectMeta: metav1.ObjectMeta{Namespace: sname[0], Name: sname[1]},
+		Spec: mcsv1alpha1.ServiceImportSpec{
+			Ports: []mcsv1alpha1.ServicePort{{Name: sport[0], Port: int32(portNumber)}},
+		},
+	}
+	if c.cache.SvcImpSlices == nil {
+		c.cache.SvcImpSlices = map[string][]*discoveryv1.EndpointSlice{}
+	}
+	for _, clusterEndpoints := range strings.Split(endpoints, ";") {
+		cep := strings.Split(clusterEndpoints, "=")
+		_, slice := conv_helper.CreateService(name, port, cep[1])
+		slice.Labels = map[string]string{
+			mcsv1alpha1.LabelServiceName:   sname[1],
+			mcsv1alpha1.LabelSourceCluster: cep[0],
+		}
+		c.cache.SvcImpSlices[name] = append(c.cache.SvcImpSlices[name], slice)
+	}
+	c.cache.SvcImpList = append(c.cache.SvcImpList, svcImport)
+	return svcImport
+}
+
 func (c *testConfig) createPod1(name, ip, port string) *api.Pod {
 	pname := strings.Split(name, "/")
 	pport := strings.Split(port, ":")
@@ -2431,6 +3170,27 @@ spec:
 	return ing
 }
 
+func (c *testConfig) createIngSvcImport1(name, hostname, path, svcImport string) *networking.Ingress {
+	sname := strings.Split(name, "/")
+	return c.createObject(`
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: ` + sname[1] + `
+  namespace: ` + sname[0] + `
+spec:
+  rules:
+  - host: ` + hostname + `
+    http:
+      paths:
+      - path: ` + path + `
+        backend:
+          resource:
+            apiGroup: multicluster.x-k8s.io
+            kind: ServiceImport
+            name: ` + svcImport).(*networking.Ingress)
+}
+
 func (c *testConfig) createIng1Ann(name, hostname, path, service string, ann map[string]string) *networking.Ingress {
 	ing := c.createIng1(name, hostname, path, service)
 	ing.SetAnnotations(ann)
@@ -2558,6 +3318,10 @@ func (u *updaterMock) UpdateBackendConfig(backend *hatypes.Backend, mapper *anno
 	}
 }
 
+func (c *testConfig) compareEvents(expected string) {
+	c.compareText(strings.Join(c.cache.Events, "\n"), expected)
+}
+
 func (c *testConfig) compareConfigTCPService(expected string) {
 	c.compareText(conv_helper.MarshalTCPServices(c.hconfig.TCPServices().BuildSortedItems()...), expected)
 }
@@ -42,6 +42,12 @@ const (
 	HostAuthTLSStrict          = "auth-tls-strict"
 	HostAuthTLSVerifyClient    = "auth-tls-verify-client"
 	HostCertSigner             = "cert-signer"
+	HostDefaultBackendService  = "default-backend-service"
+	HostFrontend               = "frontend"
+	HostOIDCCallbackPath       = "oidc-callback-path"
+	HostOIDCIssuer             = "oidc-issuer"
+	HostOIDCScopes             = "oidc-scopes"
+	HostOIDCSecret             = "oidc-secret"
 	HostRedirectFrom           = "redirect-from"
 	HostRedirectFromRegex      = "redirect-from-regex"
 	HostServerAlias            = "server-alias"
@@ -65,6 +71,12 @@ var (
 		HostAuthTLSStrict:          {},
 		HostAuthTLSVerifyClient:    {},
 		HostCertSigner:             {},
+		HostDefaultBackendService:  {},
+		HostFrontend:               {},
+		HostOIDCCallbackPath:       {},
+		HostOIDCIssuer:             {},
+		HostOIDCScopes:             {},
+		HostOIDCSecret:             {},
 		HostServerAlias:            {},
 		HostRedirectFrom:           {},
 		HostRedirectFromRegex:      {},
@@ -82,16 +94,27 @@ var (
 
 // Backend Annotations
 const (
+	BackABTestKey              = "ab-test-key"
+	BackABTestSplit            = "ab-test-split"
 	BackAffinity               = "affinity"
+	BackAfterResponseHdrsAdd   = "after-response-headers-add"
+	BackAfterResponseHdrsDel   = "after-response-headers-del"
+	BackAfterResponseHdrsSet   = "after-response-headers-set"
 	BackAgentCheckAddr         = "agent-check-addr"
 	BackAgentCheckInterval     = "agent-check-interval"
 	BackAgentCheckPort         = "agent-check-port"
 	BackAgentCheckSend         = "agent-check-send"
+	BackAllowlistConfigMap     = "allowlist-configmap"
+	BackAllowlistCountries     = "allowlist-countries"
+	BackAllowlistIPList        = "allowlist-iplist"
 	BackAllowlistSourceRange   = "allowlist-source-range"
+	BackAuthConfig             = "auth-config"
+	BackAuthGroups             = "auth-groups"
 	BackAuthRealm              = "auth-realm"
 	BackAuthSecret             = "auth-secret"
 	BackAuthSignin             = "auth-signin"
 	BackAuthTLSCertHeader      = "auth-tls-cert-header"
+	BackAuthForwardHeaders     = "auth-forward-headers"
 	BackAuthHeadersFail        = "auth-headers-fail"
 	BackAuthHeadersRequest     = "auth-headers-request"
 	BackAuthHeadersSucceed     = "auth-headers-succeed"
@@ -102,11 +125,29 @@ const (
 	BackBackendServerNaming    = "backend-server-naming"
 	BackBackendServerSlotsInc  = "backend-server-slots-increment"
 	BackBalanceAlgorithm       = "balance-algorithm"
+	BackBalanceHashFactor      = "balance-hash-balance-factor"
+	BackBalanceHashKey         = "balance-hash-key"
+	BackBlockUserAgents        = "block-user-agents"
+	BackBlockUserAgentsAction  = "block-user-agents-action"
+	BackBlockUserAgentsCM      = "block-user-agents-configmap"
 	BackBlueGreenBalance       = "blue-green-balance"
+	BackBlueGreenCanary        = "blue-green-canary"
+	BackBlueGreenCanaryCookie  = "blue-green-canary-cookie"
+	BackBlueGreenCanaryHeader  = "blue-green-canary-header"
+	BackBlueGreenCanaryMatch   = "blue-green-canary-match"
 	BackBlueGreenCookie        = "blue-green-cookie"
 	BackBlueGreenDeploy        = "blue-green-deploy"
 	BackBlueGreenHeader        = "blue-green-header"
 	BackBlueGreenMode          = "blue-green-mode"
+	BackCache                  = "cache"
+	BackCacheMaxAge            = "cache-max-age"
+	BackCacheMaxObjectSize     = "cache-max-object-size"
+	BackCacheStatusHeader      = "cache-status-header"
+	BackCacheTotalMaxSize      = "cache-total-max-size"
+	BackCompression            = "compression"
+	BackCompressionAlgo        = "compression-algo"
+	BackCompressionMinSize     = "compression-min-size"
+	BackCompressionTypes       = "compression-types"
 	BackConfigBackend          = "config-backend"
 	BackCorsAllowCredentials   = "cors-allow-credentials"
 	BackCorsAllowHeaders       = "cors-allow-headers"
@@ -115,32 +156,86 @@ const (
 	BackCorsEnable             = "cors-enable"
 	BackCorsExposeHeaders      = "cors-expose-headers"
 	BackCorsMaxAge             = "cors-max-age"
+	BackDenyMethods            = "deny-methods"
+	BackDenyMethodsStatus      = "deny-methods-status"
+	BackDenylistConfigMap      = "denylist-configmap"
+	BackDenylistCountries      = "denylist-countries"
+	BackDenylistIPList         = "denylist-iplist"
 	BackDenylistSourceRange    = "denylist-source-range"
 	BackDynamicScaling         = "dynamic-scaling"
+	BackErrorPages             = "error-pages"
+	BackFastCGIDocroot         = "fcgi-docroot"
+	BackFastCGIIndex           = "fcgi-index"
+	BackFastCGIParams          = "fcgi-params"
+	BackGRPCWeb                = "grpc-web"
+	BackHeaderRoutes           = "header-routes"
 	BackHeaders                = "headers"
 	BackHealthCheckAddr        = "health-check-addr"
+	BackHealthCheckExpect      = "health-check-expect"
 	BackHealthCheckFallCount   = "health-check-fall-count"
+	BackHealthCheckHeaders     = "health-check-headers"
+	BackHealthCheckHost        = "health-check-host"
 	BackHealthCheckInterval    = "health-check-interval"
+	BackHealthCheckMethod      = "health-check-method"
 	BackHealthCheckPort        = "health-check-port"
 	BackHealthCheckRiseCount   = "health-check-rise-count"
+	BackHealthCheckTCPSequence = "health-check-tcp-sequence"
+	BackHealthCheckTimeout     = "health-check-timeout"
 	BackHealthCheckURI         = "health-check-uri"
 	BackHSTS                   = "hsts"
 	BackHSTSIncludeSubdomains  = "hsts-include-subdomains"
 	BackHSTSMaxAge             = "hsts-max-age"
 	BackHSTSPreload            = "hsts-preload"
 	BackInitialWeight          = "initial-weight"
+	BackJWTAlgorithm           = "jwt-algorithm"
+	BackJWTAudience            = "jwt-audience"
+	BackJWTClaimsHeaders       = "jwt-claims-headers"
+	BackJWTIssuer              = "jwt-issuer"
+	BackJWTJWKSURL             = "jwt-jwks-url"
+	BackJWTKeySecret           = "jwt-key-secret"
 	BackLimitConnections       = "limit-connections"
+	BackLimitDenyStatus        = "limit-deny-status"
+	BackLimitHeader            = "limit-header"
+	BackLimitReqRPS            = "limit-req-rps"
 	BackLimitRPS               = "limit-rps"
 	BackLimitWhitelist         = "limit-whitelist"
+	BackLuaActions             = "lua-actions"
+	BackLuaFetchHeaders        = "lua-fetch-headers"
+	BackMaintenance            = "maintenance"
+	BackMaintenanceAllowlist   = "maintenance-allowlist"
+	BackMaintenanceRedirect    = "maintenance-redirect"
 	BackMaxconnServer          = "maxconn-server"
 	BackMaxQueueServer         = "maxqueue-server"
+	BackMethodRoutes           = "method-routes"
+	BackMiddlewares            = "middlewares"
+	BackMirrorPercentage       = "mirror-percentage"
+	BackMirrorURL              = "mirror-url"
 	BackOAuth                  = "oauth"
 	BackOAuthHeaders           = "oauth-headers"
 	BackOAuthURIPrefix         = "oauth-uri-prefix"
+	BackOutlierErrorLimit      = "outlier-error-limit"
+	BackOutlierObserve         = "outlier-observe"
+	BackOutlierOnError         = "outlier-on-error"
 	BackPathType               = "path-type"
+	BackPodWeightAnnotation    = "pod-weight-annotation"
 	BackProxyBodySize          = "proxy-body-size"
 	BackProxyProtocol          = "proxy-protocol"
+	BackProxyProtocolV2Options = "proxy-protocol-v2-options"
+	BackProxyProtocolV2TLVs    = "proxy-protocol-v2-tlvs"
+	BackQueryRoutes            = "query-routes"
+	BackRateLimit              = "rate-limit"
 	BackRedirectTo             = "redirect-to"
+	BackRedirectToCode         = "redirect-to-code"
+	BackRedirectToType         = "redirect-to-type"
+	BackRequestHeadersAdd      = "request-headers-add"
+	BackRequestHeadersDel      = "request-headers-del"
+	BackRequestHeadersSet      = "request-headers-set"
+	BackResponseHeadersAdd     = "response-headers-add"
+	BackResponseHeadersDel     = "response-headers-del"
+	BackResponseHeadersSet     = "response-headers-set"
+	BackRetries                = "retries"
+	BackRetryOn                = "retry-on"
+	BackRetryRedispatch        = "retry-redispatch"
 	BackRewriteTarget          = "rewrite-target"
 	BackSlotsMinFree           = "slots-min-free"
 	BackSecureBackends         = "secure-backends"
@@ -148,16 +243,28 @@ const (
 	BackSecureSNI              = "secure-sni"
 	BackSecureVerifyCASecret   = "secure-verify-ca-secret"
 	BackSecureVerifyHostname   = "secure-verify-hostname"
+	BackSecurityHeaders        = "security-headers"
+	BackServiceImportWeight    = "service-import-cluster-weight"
 	BackServiceUpstream        = "service-upstream"
 	BackSessionCookieDynamic   = "session-cookie-dynamic"
+	BackSessionCookieHash      = "session-cookie-hash"
+	BackSessionCookieHTTPOnly  = "session-cookie-httponly"
 	BackSessionCookieKeywords  = "session-cookie-keywords"
+	BackSessionCookieMaxAge    = "session-cookie-max-age"
 	BackSessionCookieName      = "session-cookie-name"
+	BackSessionCookiePath      = "session-cookie-path"
 	BackSessionCookiePreserve  = "session-cookie-preserve"
 	BackSessionCookieSameSite  = "session-cookie-same-site"
+	BackSessionCookieSecure    = "session-cookie-secure"
 	BackSessionCookieShared    = "session-cookie-shared"
 	BackSessionCookieStrategy  = "session-cookie-strategy"
 	BackSessionCookieValue     = "session-cookie-value-strategy"
+	BackSessionStickExpire     = "session-stick-expire"
+	BackSessionStickKey        = "session-stick-key"
+	BackSessionStickSize       = "session-stick-size"
+	BackSlowStart              = "slow-start"
 	BackSourceAddressIntf      = "source-address-intf"
+	BackSPOEBackendAgents      = "spoe-backend-agents"
 	BackSSLCipherSuitesBackend = "ssl-cipher-suites-backend"
 	BackSSLCiphersBackend      = "ssl-ciphers-backend"
 	BackSSLFingerprintLower    = "ssl-fingerprint-lower"
@@ -170,9 +277,13 @@ const (
 	BackTimeoutServer          = "timeout-server"
 	BackTimeoutServerFin       = "timeout-server-fin"
 	BackTimeoutTunnel          = "timeout-tunnel"
+	BackTopologyAwareRouting   = "topology-aware-routing"
+	BackTopologyRemoteWeight   = "topology-remote-weight"
 	BackUseResolver            = "use-resolver"
+	BackUseResolverSRV         = "use-resolver-srv"
 	BackWAF                    = "waf"
 	BackWAFMode                = "waf-mode"
+	BackWAFRuleset             = "waf-ruleset"
 	BackWhitelistSourceRange   = "whitelist-source-range"
 )
 
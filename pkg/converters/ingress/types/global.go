@@ -33,14 +33,20 @@ const (
 	GlobalBindIPAddrPrometheus         = "bind-ip-addr-prometheus"
 	GlobalBindIPAddrStats              = "bind-ip-addr-stats"
 	GlobalBindIPAddrTCP                = "bind-ip-addr-tcp"
+	GlobalBindQUIC                     = "bind-quic"
 	GlobalConfigDefaults               = "config-defaults"
 	GlobalConfigFrontend               = "config-frontend"
 	GlobalConfigGlobal                 = "config-global"
 	GlobalConfigProxy                  = "config-proxy"
 	GlobalConfigSections               = "config-sections"
+	GlobalConfigSnippetCheck           = "config-snippet-check"
+	GlobalConfigSnippetPolicy          = "config-snippet-policy"
 	GlobalConfigTCP                    = "config-tcp"
 	GlobalCookieKey                    = "cookie-key"
+	GlobalCookieKeySecret              = "cookie-key-secret"
+	GlobalCorazaEndpoints              = "coraza-endpoints"
 	GlobalCPUMap                       = "cpu-map"
+	GlobalCrossNamespaceConfigMaps     = "cross-namespace-configmaps"
 	GlobalCrossNamespaceSecretsCA      = "cross-namespace-secrets-ca"
 	GlobalCrossNamespaceSecretsCrt     = "cross-namespace-secrets-crt"
 	GlobalCrossNamespaceSecretsPasswd  = "cross-namespace-secrets-passwd"
@@ -56,8 +62,13 @@ const (
 	GlobalDrainSupport                 = "drain-support"
 	GlobalDrainSupportRedispatch       = "drain-support-redispatch"
 	GlobalExternalHasLua               = "external-has-lua"
+	GlobalExtraFrontends               = "extra-frontends"
+	GlobalExtraFrontendsSyslog         = "extra-frontends-syslog"
 	GlobalForwardfor                   = "forwardfor"
 	GlobalFrontingProxyPort            = "fronting-proxy-port"
+	GlobalGeoIPCountryHeader           = "geoip-country-header"
+	GlobalGeoIPDatabase                = "geoip-database"
+	GlobalGeoIPDatabaseFormat          = "geoip-database-format"
 	GlobalGroupname                    = "groupname"
 	GlobalHealthzPort                  = "healthz-port"
 	GlobalHTTPLogFormat                = "http-log-format"
@@ -65,9 +76,20 @@ const (
 	GlobalHTTPSLogFormat               = "https-log-format"
 	GlobalHTTPSPort                    = "https-port"
 	GlobalHTTPStoHTTPPort              = "https-to-http-port"
+	GlobalHTTP3                        = "http3"
+	GlobalHTTP3AltSvcMaxAge            = "http3-alt-svc-max-age"
+	GlobalHTTP3AltSvcPort              = "http3-alt-svc-port"
+	GlobalHTTP3ConnTxBuffersLimit      = "http3-conn-tx-buffers-limit"
+	GlobalHTTP3MaxIdleTimeout          = "http3-max-idle-timeout"
+	GlobalHTTP3MaxStreamsBidi          = "http3-max-streams-bidi"
 	GlobalLoadServerState              = "load-server-state"
+	GlobalLogForward                   = "log-forward"
+	GlobalLogRing                      = "log-ring"
+	GlobalLogRingSize                  = "log-ring-size"
+	GlobalLuaScripts                   = "lua-scripts"
 	GlobalMasterExitOnFailure          = "master-exit-on-failure"
 	GlobalMaxConnections               = "max-connections"
+	GlobalMaxOldWorkers                = "max-old-workers"
 	GlobalModsecurityEndpoints         = "modsecurity-endpoints"
 	GlobalModsecurityTimeoutConnect    = "modsecurity-timeout-connect"
 	GlobalModsecurityTimeoutHello      = "modsecurity-timeout-hello"
@@ -79,10 +101,21 @@ const (
 	GlobalNbthread                     = "nbthread"
 	GlobalNoTLSRedirectLocations       = "no-tls-redirect-locations"
 	GlobalPathTypeOrder                = "path-type-order"
+	GlobalPeersPort                    = "peers-port"
+	GlobalPeersService                 = "peers-service"
 	GlobalUsername                     = "username"
 	GlobalPrometheusPort               = "prometheus-port"
+	GlobalPrometheusScope              = "prometheus-scope"
+	GlobalPrometheusServerMetrics      = "prometheus-server-metrics"
 	GlobalRedirectFromCode             = "redirect-from-code"
-	GlobalRedirectToCode               = "redirect-to-code"
+	GlobalSPOEAgents                   = "spoe-agents"
+	GlobalSPOEFrontendAgents           = "spoe-frontend-agents"
+	GlobalSPOEMessages                 = "spoe-messages"
+	GlobalSPOETimeoutConnect           = "spoe-timeout-connect"
+	GlobalSPOETimeoutHello             = "spoe-timeout-hello"
+	GlobalSPOETimeoutIdle              = "spoe-timeout-idle"
+	GlobalSPOETimeoutProcessing        = "spoe-timeout-processing"
+	GlobalSPOETimeoutServer            = "spoe-timeout-server"
 	GlobalSSLDHDefaultMaxSize          = "ssl-dh-default-max-size"
 	GlobalSSLDHParam                   = "ssl-dh-param"
 	GlobalSSLEngine                    = "ssl-engine"
@@ -102,7 +135,11 @@ const (
 	GlobalTCPLogFormat                 = "tcp-log-format"
 	GlobalTimeoutClient                = "timeout-client"
 	GlobalTimeoutClientFin             = "timeout-client-fin"
+	GlobalTimeoutGrace                 = "timeout-grace"
 	GlobalTimeoutStop                  = "timeout-stop"
+	GlobalTraceContext                 = "trace-context"
+	GlobalUniqueIDFormat               = "unique-id-format"
+	GlobalUniqueIDHeader               = "unique-id-header"
 	GlobalUseChroot                    = "use-chroot"
 	GlobalUseCPUMap                    = "use-cpu-map"
 	GlobalUseForwardedProto            = "use-forwarded-proto"
@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Route is an alternate service that should receive the requests whose
+// attribute, e.g. a query parameter or a header, matches Values.
+type Route struct {
+	Name    string
+	Regex   bool
+	Values  []string
+	Service string
+	Port    string
+}
+
+var (
+	routeNameRegex    = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	routeMethodRegex  = regexp.MustCompile(`^[A-Za-z]+$`)
+	routeServiceRegex = regexp.MustCompile(`^([a-z0-9]([-a-z0-9]*[a-z0-9])?):([-a-z0-9]+)$`)
+)
+
+// ParseRoute parses a single route declaration, which has the syntax
+// `<name>=<value>[,<value>...] <service>:<port>` for exact matches, or
+// `<name>~<regex> <service>:<port>` for a regular expression match.
+func ParseRoute(route string) (*Route, error) {
+	fields := strings.Fields(route)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid route syntax, expected '<name>=<value> <service>:<port>': %s", route)
+	}
+	cond := fields[0]
+	pos := strings.IndexAny(cond, "=~")
+	if pos < 0 {
+		return nil, fmt.Errorf("missing '=' or '~' on route condition: %s", cond)
+	}
+	r := &Route{
+		Name:  cond[:pos],
+		Regex: cond[pos] == '~',
+	}
+	if !routeNameRegex.MatchString(r.Name) {
+		return nil, fmt.Errorf("invalid name on route condition: %s", cond)
+	}
+	values, err := parseRouteValues(cond[pos+1:], r.Regex)
+	if err != nil {
+		return nil, err
+	}
+	r.Values = values
+	svc := routeServiceRegex.FindStringSubmatch(fields[1])
+	if svc == nil {
+		return nil, fmt.Errorf("invalid service name or missing port, expected '<service>:<port>': %s", fields[1])
+	}
+	r.Service = svc[1]
+	r.Port = svc[3]
+	return r, nil
+}
+
+// ParseMethodRoute parses a single method route declaration, which has the
+// syntax `<method>[,<method>...] <service>:<port>`.
+func ParseMethodRoute(route string) (*Route, error) {
+	fields := strings.Fields(route)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid route syntax, expected '<method> <service>:<port>': %s", route)
+	}
+	methods, err := ParseMethods(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	svc := routeServiceRegex.FindStringSubmatch(fields[1])
+	if svc == nil {
+		return nil, fmt.Errorf("invalid service name or missing port, expected '<service>:<port>': %s", fields[1])
+	}
+	return &Route{
+		Values:  methods,
+		Service: svc[1],
+		Port:    svc[3],
+	}, nil
+}
+
+// ParseMethods parses a comma and/or space separated list of HTTP methods,
+// returning them in upper case and without duplicates.
+func ParseMethods(value string) ([]string, error) {
+	var methods []string
+	for _, m := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if !routeMethodRegex.MatchString(m) {
+			return nil, fmt.Errorf("invalid HTTP method: %s", m)
+		}
+		m = strings.ToUpper(m)
+		dup := false
+		for _, method := range methods {
+			if method == m {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			methods = append(methods, m)
+		}
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("missing HTTP method")
+	}
+	return methods, nil
+}
+
+func parseRouteValues(value string, regex bool) ([]string, error) {
+	if value == "" {
+		return nil, fmt.Errorf("missing value on route condition")
+	}
+	if strings.ContainsAny(value, `'`) {
+		return nil, fmt.Errorf("invalid char on route value: %s", value)
+	}
+	if regex {
+		if _, err := regexp.Compile(value); err != nil {
+			return nil, fmt.Errorf("invalid regex on route condition: %w", err)
+		}
+		return []string{value}, nil
+	}
+	values := strings.Split(value, ",")
+	for _, v := range values {
+		if v == "" {
+			return nil, fmt.Errorf("empty value on route condition: %s", value)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRoute(t *testing.T) {
+	testCases := []struct {
+		route string
+		exp   *Route
+		err   string
+	}{
+		// 0
+		{
+			route: "version=beta echo-beta:8080",
+			exp:   &Route{Name: "version", Values: []string{"beta"}, Service: "echo-beta", Port: "8080"},
+		},
+		// 1
+		{
+			route: "  version=beta,preview   echo-beta:http ",
+			exp:   &Route{Name: "version", Values: []string{"beta", "preview"}, Service: "echo-beta", Port: "http"},
+		},
+		// 2
+		{
+			route: `version~^v2\. echo-v2:8080`,
+			exp:   &Route{Name: "version", Regex: true, Values: []string{`^v2\.`}, Service: "echo-v2", Port: "8080"},
+		},
+		// 3
+		{
+			route: "version=beta",
+			err:   "invalid route syntax, expected '<name>=<value> <service>:<port>': version=beta",
+		},
+		// 4
+		{
+			route: "version echo:8080",
+			err:   "missing '=' or '~' on route condition: version",
+		},
+		// 5
+		{
+			route: "=beta echo:8080",
+			err:   "invalid name on route condition: =beta",
+		},
+		// 6
+		{
+			route: "version= echo:8080",
+			err:   "missing value on route condition",
+		},
+		// 7
+		{
+			route: "version=beta,,v2 echo:8080",
+			err:   "empty value on route condition: beta,,v2",
+		},
+		// 8
+		{
+			route: "version=it's echo:8080",
+			err:   "invalid char on route value: it's",
+		},
+		// 9
+		{
+			route: "version~v2( echo:8080",
+			err:   "invalid regex on route condition: error parsing regexp: missing closing ): `v2(`",
+		},
+		// 10
+		{
+			route: "version=beta echo",
+			err:   "invalid service name or missing port, expected '<service>:<port>': echo",
+		},
+		// 11
+		{
+			route: "version=beta default/echo:8080",
+			err:   "invalid service name or missing port, expected '<service>:<port>': default/echo:8080",
+		},
+	}
+	for i, test := range testCases {
+		route, err := ParseRoute(test.route)
+		var errStr string
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != test.err {
+			t.Errorf("error differs on %d - expected: '%s', actual: '%s'", i, test.err, errStr)
+		}
+		if !reflect.DeepEqual(route, test.exp) {
+			t.Errorf("route differs on %d - expected: %+v, actual: %+v", i, test.exp, route)
+		}
+	}
+}
+
+func TestParseMethodRoute(t *testing.T) {
+	testCases := []struct {
+		route string
+		exp   *Route
+		err   string
+	}{
+		// 0
+		{
+			route: "POST echo-write:8080",
+			exp:   &Route{Values: []string{"POST"}, Service: "echo-write", Port: "8080"},
+		},
+		// 1
+		{
+			route: "  post,PUT,Delete,put  echo-write:http ",
+			exp:   &Route{Values: []string{"POST", "PUT", "DELETE"}, Service: "echo-write", Port: "http"},
+		},
+		// 2
+		{
+			route: "POST",
+			err:   "invalid route syntax, expected '<method> <service>:<port>': POST",
+		},
+		// 3
+		{
+			route: "POST,,PUT echo:8080",
+			exp:   &Route{Values: []string{"POST", "PUT"}, Service: "echo", Port: "8080"},
+		},
+		// 4
+		{
+			route: "M-SEARCH echo:8080",
+			err:   "invalid HTTP method: M-SEARCH",
+		},
+		// 5
+		{
+			route: ", echo:8080",
+			err:   "missing HTTP method",
+		},
+		// 6
+		{
+			route: "POST echo",
+			err:   "invalid service name or missing port, expected '<service>:<port>': echo",
+		},
+	}
+	for i, test := range testCases {
+		route, err := ParseMethodRoute(test.route)
+		var errStr string
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != test.err {
+			t.Errorf("error differs on %d - expected: '%s', actual: '%s'", i, test.err, errStr)
+		}
+		if !reflect.DeepEqual(route, test.exp) {
+			t.Errorf("route differs on %d - expected: %+v, actual: %+v", i, test.exp, route)
+		}
+	}
+}
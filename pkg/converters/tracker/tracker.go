@@ -52,6 +52,8 @@ type tracker struct {
 	// configMap
 	configMapHostname stringStringMap
 	hostnameConfigMap stringStringMap
+	configMapBackend  stringBackendMap
+	backendConfigMap  backendStringMap
 	// service
 	serviceHostname stringStringMap
 	hostnameService stringStringMap
@@ -65,12 +67,17 @@ type tracker struct {
 	// pod
 	podBackend stringBackendMap
 	backendPod backendStringMap
+	// ipList
+	ipListBackend stringBackendMap
+	backendIPList backendStringMap
 	// ingressClass (missing)
 	ingressClassHostnameMissing stringStringMap
 	hostnameIngressClassMissing stringStringMap
 	// configMap (missing)
 	configMapHostnameMissing stringStringMap
 	hostnameConfigMapMissing stringStringMap
+	configMapBackendMissing  stringBackendMap
+	backendConfigMapMissing  backendStringMap
 	// service (missing)
 	serviceHostnameMissing stringStringMap
 	hostnameServiceMissing stringStringMap
@@ -79,6 +86,9 @@ type tracker struct {
 	hostnameSecretMissing stringStringMap
 	secretBackendMissing  stringBackendMap
 	backendSecretMissing  backendStringMap
+	// ipList (missing)
+	ipListBackendMissing stringBackendMap
+	backendIPListMissing backendStringMap
 	// gateway
 	secretGateway  map[string]empty
 	serviceGateway map[string]empty
@@ -138,12 +148,18 @@ func (t *tracker) TrackBackend(rtype convtypes.ResourceType, name string, backen
 	case convtypes.IngressType:
 		addStringBackendTracking(&t.ingressBackend, name, backendID)
 		addBackendStringTracking(&t.backendIngress, backendID, name)
+	case convtypes.ConfigMapType:
+		addStringBackendTracking(&t.configMapBackend, name, backendID)
+		addBackendStringTracking(&t.backendConfigMap, backendID, name)
 	case convtypes.SecretType:
 		addStringBackendTracking(&t.secretBackend, name, backendID)
 		addBackendStringTracking(&t.backendSecret, backendID, name)
 	case convtypes.PodType:
 		addStringBackendTracking(&t.podBackend, name, backendID)
 		addBackendStringTracking(&t.backendPod, backendID, name)
+	case convtypes.IPListType:
+		addStringBackendTracking(&t.ipListBackend, name, backendID)
+		addBackendStringTracking(&t.backendIPList, backendID, name)
 	default:
 		panic(fmt.Errorf("unsupported resource type %d", rtype))
 	}
@@ -212,9 +228,15 @@ func (t *tracker) TrackMissingOnHostname(rtype convtypes.ResourceType, name, hos
 func (t *tracker) TrackMissingOnBackend(rtype convtypes.ResourceType, name string, backendID hatypes.BackendID) {
 	validName(rtype, name)
 	switch rtype {
+	case convtypes.ConfigMapType:
+		addStringBackendTracking(&t.configMapBackendMissing, name, backendID)
+		addBackendStringTracking(&t.backendConfigMapMissing, backendID, name)
 	case convtypes.SecretType:
 		addStringBackendTracking(&t.secretBackendMissing, name, backendID)
 		addBackendStringTracking(&t.backendSecretMissing, backendID, name)
+	case convtypes.IPListType:
+		addStringBackendTracking(&t.ipListBackendMissing, name, backendID)
+		addBackendStringTracking(&t.backendIPListMissing, backendID, name)
 	default:
 		panic(fmt.Errorf("unsupported resource type %d", rtype))
 	}
@@ -257,7 +279,7 @@ func validName(rtype convtypes.ResourceType, name string) {
 	if name == "" {
 		panic(fmt.Errorf("tracking resource name cannot be empty"))
 	}
-	namespaced := rtype != convtypes.IngressClassType
+	namespaced := rtype != convtypes.IngressClassType && rtype != convtypes.IPListType
 	slashCount := strings.Count(name, "/")
 	if (!namespaced && slashCount != 0) || (namespaced && slashCount != 1) {
 		panic(fmt.Errorf("invalid resource name: %s", name))
@@ -279,6 +301,7 @@ func (t *tracker) GetDirtyLinks(
 	oldServiceList, addServiceList []string,
 	oldSecretList, addSecretList []string,
 	addPodList []string,
+	oldIPListList, addIPListList []string,
 ) (dirtyIngs, dirtyHosts []string, dirtyBacks []hatypes.BackendID, dirtyUsers, dirtyStorages []string) {
 	ingsMap := make(map[string]empty)
 	hostsMap := make(map[string]empty)
@@ -339,6 +362,12 @@ func (t *tracker) GetDirtyLinks(
 				build(t.getIngressByHostname(hostname))
 			}
 		}
+		for _, backend := range t.getBackendsByConfigMap(className) {
+			if _, found := backsMap[backend]; !found {
+				backsMap[backend] = empty{}
+				build(t.getIngressByBackend(backend))
+			}
+		}
 	}
 	for _, className := range addConfigMapList {
 		for _, hostname := range t.getHostnamesByConfigMapMissing(className) {
@@ -347,6 +376,12 @@ func (t *tracker) GetDirtyLinks(
 				build(t.getIngressByHostname(hostname))
 			}
 		}
+		for _, backend := range t.getBackendsByConfigMapMissing(className) {
+			if _, found := backsMap[backend]; !found {
+				backsMap[backend] = empty{}
+				build(t.getIngressByBackend(backend))
+			}
+		}
 	}
 	//
 	for _, svcName := range oldServiceList {
@@ -408,6 +443,23 @@ func (t *tracker) GetDirtyLinks(
 			}
 		}
 	}
+	//
+	for _, ipListName := range oldIPListList {
+		for _, backend := range t.getBackendsByIPList(ipListName) {
+			if _, found := backsMap[backend]; !found {
+				backsMap[backend] = empty{}
+				build(t.getIngressByBackend(backend))
+			}
+		}
+	}
+	for _, ipListName := range addIPListList {
+		for _, backend := range t.getBackendsByIPListMissing(ipListName) {
+			if _, found := backsMap[backend]; !found {
+				backsMap[backend] = empty{}
+				build(t.getIngressByBackend(backend))
+			}
+		}
+	}
 
 	// convert hostsMap and backsMap to slices
 	if len(ingsMap) > 0 {
@@ -497,6 +549,14 @@ func (t *tracker) DeleteBackends(backends []hatypes.BackendID) {
 			deleteStringBackendTracking(&t.ingressBackend, ing, backend)
 		}
 		deleteBackendStringMapKey(&t.backendIngress, backend)
+		for configMap := range t.backendConfigMap[backend] {
+			deleteStringBackendTracking(&t.configMapBackend, configMap, backend)
+		}
+		deleteBackendStringMapKey(&t.backendConfigMap, backend)
+		for configMap := range t.backendConfigMapMissing[backend] {
+			deleteStringBackendTracking(&t.configMapBackendMissing, configMap, backend)
+		}
+		deleteBackendStringMapKey(&t.backendConfigMapMissing, backend)
 		for secret := range t.backendSecret[backend] {
 			deleteStringBackendTracking(&t.secretBackend, secret, backend)
 		}
@@ -509,6 +569,14 @@ func (t *tracker) DeleteBackends(backends []hatypes.BackendID) {
 			deleteStringBackendTracking(&t.podBackend, pod, backend)
 		}
 		deleteBackendStringMapKey(&t.backendPod, backend)
+		for ipList := range t.backendIPList[backend] {
+			deleteStringBackendTracking(&t.ipListBackend, ipList, backend)
+		}
+		deleteBackendStringMapKey(&t.backendIPList, backend)
+		for ipList := range t.backendIPListMissing[backend] {
+			deleteStringBackendTracking(&t.ipListBackendMissing, ipList, backend)
+		}
+		deleteBackendStringMapKey(&t.backendIPListMissing, backend)
 	}
 }
 
@@ -600,6 +668,20 @@ func (t *tracker) getHostnamesByConfigMapMissing(configMapName string) []string
 	return getStringTracking(t.configMapHostnameMissing[configMapName])
 }
 
+func (t *tracker) getBackendsByConfigMap(configMapName string) []hatypes.BackendID {
+	if t.configMapBackend == nil {
+		return nil
+	}
+	return getBackendTracking(t.configMapBackend[configMapName])
+}
+
+func (t *tracker) getBackendsByConfigMapMissing(configMapName string) []hatypes.BackendID {
+	if t.configMapBackendMissing == nil {
+		return nil
+	}
+	return getBackendTracking(t.configMapBackendMissing[configMapName])
+}
+
 func (t *tracker) getHostnamesByService(serviceName string) []string {
 	if t.serviceHostname == nil {
 		return nil
@@ -656,6 +738,77 @@ func (t *tracker) getBackendsByPod(podName string) []hatypes.BackendID {
 	return getBackendTracking(t.podBackend[podName])
 }
 
+func (t *tracker) getBackendsByIPList(ipListName string) []hatypes.BackendID {
+	if t.ipListBackend == nil {
+		return nil
+	}
+	return getBackendTracking(t.ipListBackend[ipListName])
+}
+
+func (t *tracker) getBackendsByIPListMissing(ipListName string) []hatypes.BackendID {
+	if t.ipListBackendMissing == nil {
+		return nil
+	}
+	return getBackendTracking(t.ipListBackendMissing[ipListName])
+}
+
+// Dump returns a copy of the tracked links, indexed by the kind of the link,
+// e.g. `ingress/hostname`, and the name of the tracked resource. Links of
+// missing resources have the `missing` suffix, e.g. `secret/backend/missing`.
+func (t *tracker) Dump() map[string]map[string][]string {
+	dump := map[string]map[string][]string{}
+	addString := func(kind string, tracking stringStringMap) {
+		for name, values := range tracking {
+			addDumpLink(dump, kind, name, getStringTracking(values))
+		}
+	}
+	addBackend := func(kind string, tracking stringBackendMap) {
+		for name, values := range tracking {
+			backends := make([]string, 0, len(values))
+			for backend := range values {
+				backends = append(backends, backend.String())
+			}
+			addDumpLink(dump, kind, name, backends)
+		}
+	}
+	addString("ingress/hostname", t.ingressHostname)
+	addBackend("ingress/backend", t.ingressBackend)
+	addString("ingress/storage", t.ingressStorages)
+	addString("ingressclass/hostname", t.ingressClassHostname)
+	addString("configmap/hostname", t.configMapHostname)
+	addBackend("configmap/backend", t.configMapBackend)
+	addString("service/hostname", t.serviceHostname)
+	addString("secret/hostname", t.secretHostname)
+	addBackend("secret/backend", t.secretBackend)
+	addString("secret/userlist", t.secretUserlist)
+	addBackend("pod/backend", t.podBackend)
+	addBackend("iplist/backend", t.ipListBackend)
+	addString("ingressclass/hostname/missing", t.ingressClassHostnameMissing)
+	addString("configmap/hostname/missing", t.configMapHostnameMissing)
+	addBackend("configmap/backend/missing", t.configMapBackendMissing)
+	addString("service/hostname/missing", t.serviceHostnameMissing)
+	addString("secret/hostname/missing", t.secretHostnameMissing)
+	addBackend("secret/backend/missing", t.secretBackendMissing)
+	addBackend("iplist/backend/missing", t.ipListBackendMissing)
+	for name := range t.secretGateway {
+		addDumpLink(dump, "secret/gateway", name, nil)
+	}
+	for name := range t.serviceGateway {
+		addDumpLink(dump, "service/gateway", name, nil)
+	}
+	return dump
+}
+
+func addDumpLink(dump map[string]map[string][]string, kind, name string, values []string) {
+	links, found := dump[kind]
+	if !found {
+		links = map[string][]string{}
+		dump[kind] = links
+	}
+	sort.Strings(values)
+	links[name] = values
+}
+
 func addStringTracking(trackingRef *stringStringMap, key, value string) {
 	if *trackingRef == nil {
 		*trackingRef = stringStringMap{}
@@ -93,6 +93,8 @@ func TestGetDirtyLinks(t *testing.T) {
 		oldSecretList       []string
 		addSecretList       []string
 		addPodList          []string
+		oldIPListList       []string
+		addIPListList       []string
 		//
 		expDirtyIngs     []string
 		expDirtyHosts    []string
@@ -316,6 +318,45 @@ func TestGetDirtyLinks(t *testing.T) {
 			addConfigMapList: []string{"ingress/config"},
 			expDirtyHosts:    []string{"app1.local"},
 		},
+		// 23
+		{
+			trackedBacks: []backTracking{
+				{convtypes.ConfigMapType, "default/errors", back1a},
+			},
+			oldConfigMapList: []string{"default/errors"},
+			expDirtyBacks:    []hatypes.BackendID{back1b},
+		},
+		// 24
+		{
+			trackedMissingBacks: []backTracking{
+				{convtypes.ConfigMapType, "default/errors", back1a},
+			},
+			addConfigMapList: []string{"default/errors"},
+			expDirtyBacks:    []hatypes.BackendID{back1b},
+		},
+		// 25
+		{
+			trackedBacks: []backTracking{
+				{convtypes.IPListType, "office", back1a},
+			},
+			oldIPListList: []string{"office"},
+			expDirtyBacks: []hatypes.BackendID{back1b},
+		},
+		// 26
+		{
+			trackedMissingBacks: []backTracking{
+				{convtypes.IPListType, "office", back1a},
+			},
+			addIPListList: []string{"office"},
+			expDirtyBacks: []hatypes.BackendID{back1b},
+		},
+		// 27
+		{
+			trackedBacks: []backTracking{
+				{convtypes.IPListType, "office", back1a},
+			},
+			addIPListList: []string{"office"},
+		},
 	}
 	for i, test := range testCases {
 		c := setup(t)
@@ -350,6 +391,8 @@ func TestGetDirtyLinks(t *testing.T) {
 				test.oldSecretList,
 				test.addSecretList,
 				test.addPodList,
+				test.oldIPListList,
+				test.addIPListList,
 			)
 		sort.Strings(dirtyIngs)
 		sort.Strings(dirtyHosts)
@@ -783,6 +826,62 @@ func TestDeleteStorages(t *testing.T) {
 	}
 }
 
+func TestDump(t *testing.T) {
+	testCases := []struct {
+		trackedHosts   []hostTracking
+		trackedBacks   []backTracking
+		trackedMissing []hostTracking
+		expDump        map[string]map[string][]string
+	}{
+		// 0
+		{
+			expDump: map[string]map[string][]string{},
+		},
+		// 1
+		{
+			trackedHosts: []hostTracking{
+				{convtypes.IngressType, "default/ingress1", "domain2.local"},
+				{convtypes.IngressType, "default/ingress1", "domain1.local"},
+				{convtypes.SecretType, "default/secret1", "domain1.local"},
+			},
+			trackedBacks: []backTracking{
+				{convtypes.IngressType, "default/ingress1", back1a},
+			},
+			trackedMissing: []hostTracking{
+				{convtypes.ServiceType, "default/svc3", "domain1.local"},
+			},
+			expDump: map[string]map[string][]string{
+				"ingress/hostname": {
+					"default/ingress1": {"domain1.local", "domain2.local"},
+				},
+				"ingress/backend": {
+					"default/ingress1": {"default_svc1_8080"},
+				},
+				"secret/hostname": {
+					"default/secret1": {"domain1.local"},
+				},
+				"service/hostname/missing": {
+					"default/svc3": {"domain1.local"},
+				},
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		for _, trackedHost := range test.trackedHosts {
+			c.tracker.TrackHostname(trackedHost.rtype, trackedHost.name, trackedHost.hostname)
+		}
+		for _, trackedBack := range test.trackedBacks {
+			c.tracker.TrackBackend(trackedBack.rtype, trackedBack.name, trackedBack.backend)
+		}
+		for _, trackedMissing := range test.trackedMissing {
+			c.tracker.TrackMissingOnHostname(trackedMissing.rtype, trackedMissing.name, trackedMissing.hostname)
+		}
+		c.compareObjects("dump", i, c.tracker.Dump(), test.expDump)
+		c.teardown()
+	}
+}
+
 type testConfig struct {
 	t       *testing.T
 	tracker *tracker
@@ -20,9 +20,13 @@ import (
 	"time"
 
 	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	gateway "sigs.k8s.io/gateway-api/apis/v1alpha1"
 
+	mcsv1alpha1 "github.com/jcmoraisjr/haproxy-ingress/pkg/api/mcs/v1alpha1"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/api/v1alpha1"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 )
 
@@ -31,22 +35,87 @@ type Cache interface {
 	GetIngress(ingressName string) (*networking.Ingress, error)
 	GetIngressList() ([]*networking.Ingress, error)
 	GetIngressClass(className string) (*networking.IngressClass, error)
+	GetIngressClassParameters(name string) (*v1alpha1.IngressClassParameters, error)
+	GetGlobal(name string) (*v1alpha1.Global, error)
+	UpdateGlobalStatus(global *v1alpha1.Global, condition metav1.Condition) error
 	GetGateway(gatewayName string) (*gateway.Gateway, error)
 	GetGatewayList() ([]*gateway.Gateway, error)
 	GetHTTPRouteList(namespace string, match map[string]string) ([]*gateway.HTTPRoute, error)
+	GetHostList() ([]*v1alpha1.Host, error)
+	UpdateHostStatus(host *v1alpha1.Host, condition metav1.Condition) error
+	GetBackendList() ([]*v1alpha1.Backend, error)
+	UpdateBackendStatus(backend *v1alpha1.Backend, condition metav1.Condition) error
+	GetMiddlewareList() ([]*v1alpha1.Middleware, error)
+	UpdateMiddlewareStatus(middleware *v1alpha1.Middleware, condition metav1.Condition) error
+	GetAuthConfigList() ([]*v1alpha1.AuthConfig, error)
+	UpdateAuthConfigStatus(authConfig *v1alpha1.AuthConfig, condition metav1.Condition) error
+	GetIPListList() ([]*v1alpha1.IPList, error)
+	GetIPList(name string, track TrackingTarget) (*v1alpha1.IPList, error)
+	UpdateIPListStatus(ipList *v1alpha1.IPList, condition metav1.Condition) error
+	GetRateLimitList() ([]*v1alpha1.RateLimit, error)
+	UpdateRateLimitStatus(rateLimit *v1alpha1.RateLimit, condition metav1.Condition) error
+	GetTCPServiceList() ([]*v1alpha1.TCPService, error)
+	UpdateTCPServiceStatus(tcpService *v1alpha1.TCPService, condition metav1.Condition) error
+	GetUDPServiceList() ([]*v1alpha1.UDPService, error)
+	UpdateUDPServiceStatus(udpService *v1alpha1.UDPService, condition metav1.Condition) error
 	GetService(defaultNamespace, serviceName string) (*api.Service, error)
-	GetEndpoints(service *api.Service) (*api.Endpoints, error)
+	GetEndpointSlices(service *api.Service) ([]*discoveryv1.EndpointSlice, error)
+	GetServiceImport(defaultNamespace, importName string) (*mcsv1alpha1.ServiceImport, error)
+	GetServiceImportSlices(svcImport *mcsv1alpha1.ServiceImport) ([]*discoveryv1.EndpointSlice, error)
 	GetConfigMap(configMapName string) (*api.ConfigMap, error)
+	GetConfigMapData(defaultNamespace, configMapName string, track TrackingTarget) (map[string]string, error)
 	GetTerminatingPods(service *api.Service, track TrackingTarget) ([]*api.Pod, error)
 	GetPod(podName string) (*api.Pod, error)
+	GetControllerServices() ([]*api.Service, error)
+	GetNode(nodeName string) (*api.Node, error)
+	GetPodName() string
 	GetPodNamespace() string
 	GetTLSSecretPath(defaultNamespace, secretName string, track TrackingTarget) (CrtFile, error)
 	GetCASecretPath(defaultNamespace, secretName string, track TrackingTarget) (ca, crl File, err error)
 	GetDHSecretPath(defaultNamespace, secretName string) (File, error)
+	GetPublicKeySecretPath(defaultNamespace, secretName string, track TrackingTarget) (File, error)
+	GetJWKSKeys(url string) ([]JWKSKey, error)
 	GetPasswdSecretContent(defaultNamespace, secretName string, track TrackingTarget) ([]byte, error)
+	GetSecretContent(defaultNamespace, secretName, keyName string, track TrackingTarget) ([]byte, error)
+	RecordEvent(kind, namespace, name, eventtype, reason, message string)
 	SwapChangedObjects() *ChangedObjects
 }
 
+// SnippetChecker ...
+type SnippetChecker interface {
+	CheckBackend(snippet []string) error
+}
+
+// ResourceConfig is the configuration declared in a custom resource,
+// converted to configuration keys
+type ResourceConfig struct {
+	Namespace string
+	Name      string
+	Config    map[string]string
+}
+
+// ResourceConfigs are the configurations of the custom resources that
+// configure hostnames, backends and paths of the ingress resources
+type ResourceConfigs struct {
+	// Hosts are the Host resources, indexed by the hostname
+	Hosts map[string]*ResourceConfig
+
+	// Backends are the Backend resources, indexed by the namespace and
+	// name of the service
+	Backends map[string]*ResourceConfig
+
+	// Middlewares are the Middleware resources, indexed by their namespace
+	// and name
+	Middlewares map[string]*ResourceConfig
+
+	// AuthConfigs are the AuthConfig resources, indexed by their namespace
+	// and name
+	AuthConfigs map[string]*ResourceConfig
+
+	// RateLimits are the RateLimit resources, indexed by their name
+	RateLimits map[string]*hatypes.RateLimit
+}
+
 // ChangedObjects ...
 type ChangedObjects struct {
 	//
@@ -72,7 +141,13 @@ type ChangedObjects struct {
 	//
 	BackendPoliciesDel, BackendPoliciesUpd, BackendPoliciesAdd []*gateway.BackendPolicy
 	//
-	EndpointsNew []*api.Endpoints
+	TCPServicesDel, TCPServicesUpd, TCPServicesAdd []*v1alpha1.TCPService
+	//
+	UDPServicesDel, UDPServicesUpd, UDPServicesAdd []*v1alpha1.UDPService
+	//
+	IPListsDel, IPListsUpd, IPListsAdd []*v1alpha1.IPList
+	//
+	EndpointsNew []*discoveryv1.EndpointSlice
 	//
 	ServicesDel, ServicesUpd, ServicesAdd []*api.Service
 	//
@@ -95,13 +170,14 @@ type Tracker interface {
 	TrackMissingOnHostname(rtype ResourceType, name, hostname string)
 	TrackStorage(rtype ResourceType, name, storage string)
 	TrackGateway(rtype ResourceType, name string)
-	GetDirtyLinks(oldIngressList, addIngressList, oldIngressClassList, addIngressClassList, oldConfigMapList, addConfigMapList, oldServiceList, addServiceList, oldSecretList, addSecretList, addPodList []string) (dirtyIngs, dirtyHosts []string, dirtyBacks []hatypes.BackendID, dirtyUsers, dirtyStorages []string)
+	GetDirtyLinks(oldIngressList, addIngressList, oldIngressClassList, addIngressClassList, oldConfigMapList, addConfigMapList, oldServiceList, addServiceList, oldSecretList, addSecretList, addPodList, oldIPListList, addIPListList []string) (dirtyIngs, dirtyHosts []string, dirtyBacks []hatypes.BackendID, dirtyUsers, dirtyStorages []string)
 	GetGatewayChanged(oldSecretList, addSecretList, oldServiceList, addServiceList []string) bool
 	DeleteHostnames(hostnames []string)
 	DeleteBackends(backends []hatypes.BackendID)
 	DeleteUserlists(userlists []string)
 	DeleteStorages(storages []string)
 	DeleteGateway()
+	Dump() map[string]map[string][]string
 }
 
 // TrackingTarget ...
@@ -114,7 +190,7 @@ type TrackingTarget struct {
 
 // AnnotationReader ...
 type AnnotationReader interface {
-	ReadAnnotations(backend *hatypes.Backend, services []*api.Service, pathLinks []hatypes.PathLink)
+	ReadAnnotations(backend *hatypes.Backend, services []*api.Service, pathLinks []hatypes.PathLink, middlewares []string)
 }
 
 // File ...
@@ -123,6 +199,12 @@ type File struct {
 	SHA1Hash string
 }
 
+// JWKSKey ...
+type JWKSKey struct {
+	KID  string
+	File File
+}
+
 // CrtFile ...
 type CrtFile struct {
 	Filename   string
@@ -152,4 +234,7 @@ const (
 
 	// PodType ...
 	PodType
+
+	// IPListType ...
+	IPListType
 )
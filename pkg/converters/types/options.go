@@ -27,6 +27,8 @@ type ConverterOptions struct {
 	Tracker          Tracker
 	DynamicConfig    *DynamicConfig
 	MasterSocket     string
+	GlobalResource   string
+	HandoffSocket    string
 	DefaultConfig    func() map[string]string
 	DefaultBackend   string
 	DefaultCrtSecret string
@@ -35,10 +37,14 @@ type ConverterOptions struct {
 	AnnotationPrefix []string
 	AcmeTrackTLSAnn  bool
 	HasGateway       bool
+	HasCRDs          bool
+	AvailableCPUs    int
+	SnippetChecker   SnippetChecker
 }
 
 // DynamicConfig ...
 type DynamicConfig struct {
+	CrossNamespaceConfigMaps        bool
 	CrossNamespaceSecretCertificate bool
 	CrossNamespaceSecretCA          bool
 	CrossNamespaceSecretPasswd      bool
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+var (
+	tcpCheckHexRegex     = regexp.MustCompile(`^([0-9A-Fa-f]{2})+$`)
+	tcpCheckPatternRegex = regexp.MustCompile(`^[^\s"']+$`)
+)
+
+// ParseTCPCheckSequence parses a list of tcp-check rules, one per item.
+// Empty items and items starting with a hash `#` are ignored. Supported
+// rules are:
+//
+//	send <string>
+//	send-binary <hex-string>
+//	expect [!] <string|rstring|binary|rbinary> <pattern>
+func ParseTCPCheckSequence(lines []string) ([]*hatypes.TCPCheckRule, error) {
+	var rules []*hatypes.TCPCheckRule
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "send":
+			value := strings.TrimSpace(line[len(fields[0]):])
+			if value == "" || strings.Contains(value, `"`) {
+				return nil, fmt.Errorf("invalid tcp check send: %s", line)
+			}
+			rules = append(rules, &hatypes.TCPCheckRule{Action: "send", Value: value})
+		case "send-binary":
+			if len(fields) != 2 || !tcpCheckHexRegex.MatchString(fields[1]) {
+				return nil, fmt.Errorf("invalid tcp check send-binary: %s", line)
+			}
+			rules = append(rules, &hatypes.TCPCheckRule{Action: "send-binary", Value: fields[1]})
+		case "expect":
+			match := fields[1:]
+			negate := len(match) > 0 && match[0] == "!"
+			if negate {
+				match = match[1:]
+			}
+			if len(match) != 2 || !tcpCheckPatternRegex.MatchString(match[1]) {
+				return nil, fmt.Errorf("invalid tcp check expect: %s", line)
+			}
+			switch match[0] {
+			case "string", "rstring", "rbinary":
+			case "binary":
+				if !tcpCheckHexRegex.MatchString(match[1]) {
+					return nil, fmt.Errorf("invalid tcp check expect: %s", line)
+				}
+			default:
+				return nil, fmt.Errorf("invalid tcp check expect match '%s': %s", match[0], line)
+			}
+			rule := &hatypes.TCPCheckRule{Action: "expect", Match: match[0], Value: match[1]}
+			if negate {
+				rule.Match = "! " + rule.Match
+			}
+			rules = append(rules, rule)
+		default:
+			return nil, fmt.Errorf("unsupported tcp check action '%s': %s", fields[0], line)
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+func TestParseTCPCheckSequence(t *testing.T) {
+	testCases := []struct {
+		lines    []string
+		expected []*hatypes.TCPCheckRule
+		err      string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			lines: []string{"", "# redis", `send PING\r\n`, "expect string +PONG"},
+			expected: []*hatypes.TCPCheckRule{
+				{Action: "send", Value: `PING\r\n`},
+				{Action: "expect", Match: "string", Value: "+PONG"},
+			},
+		},
+		// 2
+		{
+			lines: []string{`send  HELO my host\r\n`, "expect rstring ^250", "send-binary 0a0D", "expect ! binary 00ff"},
+			expected: []*hatypes.TCPCheckRule{
+				{Action: "send", Value: `HELO my host\r\n`},
+				{Action: "expect", Match: "rstring", Value: "^250"},
+				{Action: "send-binary", Value: "0a0D"},
+				{Action: "expect", Match: "! binary", Value: "00ff"},
+			},
+		},
+		// 3
+		{
+			lines: []string{"send"},
+			err:   "invalid tcp check send: send",
+		},
+		// 4
+		{
+			lines: []string{`send "PING"`},
+			err:   `invalid tcp check send: send "PING"`,
+		},
+		// 5
+		{
+			lines: []string{"send-binary 0a0"},
+			err:   "invalid tcp check send-binary: send-binary 0a0",
+		},
+		// 6
+		{
+			lines: []string{"expect string two words"},
+			err:   "invalid tcp check expect: expect string two words",
+		},
+		// 7
+		{
+			lines: []string{"expect binary zz"},
+			err:   "invalid tcp check expect: expect binary zz",
+		},
+		// 8
+		{
+			lines: []string{"expect status 200"},
+			err:   "invalid tcp check expect match 'status': expect status 200",
+		},
+		// 9
+		{
+			lines: []string{"expect"},
+			err:   "invalid tcp check expect: expect",
+		},
+		// 10
+		{
+			lines: []string{"connect port 8080"},
+			err:   "unsupported tcp check action 'connect': connect port 8080",
+		},
+	}
+	for i, test := range testCases {
+		rules, err := ParseTCPCheckSequence(test.lines)
+		if !reflect.DeepEqual(rules, test.expected) {
+			t.Errorf("rules differ on %d - expected: %+v - actual: %+v", i, test.expected, rules)
+		}
+		var errstr string
+		if err != nil {
+			errstr = fmt.Sprintf("%v", err)
+		}
+		if errstr != test.err {
+			t.Errorf("error differ on %d - expected: %s - actual: %s", i, test.err, errstr)
+		}
+	}
+}
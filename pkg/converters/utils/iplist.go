@@ -0,0 +1,43 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"net"
+	"strings"
+)
+
+// ParseIPList splits the entries of an IPList resource in valid IPs and
+// CIDRs, without duplicates, and invalid ones.
+func ParseIPList(entries []string) (valid, invalid []string) {
+	valid = []string{}
+	dupEntries := map[string]bool{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if net.ParseIP(entry) == nil {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				invalid = append(invalid, entry)
+				continue
+			}
+		}
+		if !dupEntries[entry] {
+			dupEntries[entry] = true
+			valid = append(valid, entry)
+		}
+	}
+	return valid, invalid
+}
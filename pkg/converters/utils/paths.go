@@ -0,0 +1,36 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ValidateRegexPath checks if a path of the regex type can be safely added
+// to a map file. Whitespaces would split the map entry, and an invalid
+// expression would make haproxy refuse the whole configuration.
+func ValidateRegexPath(path string) error {
+	if strings.ContainsAny(path, " \t\r\n") {
+		return fmt.Errorf("regex path cannot have whitespaces")
+	}
+	if _, err := regexp.Compile(path); err != nil {
+		return fmt.Errorf("invalid regex: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+)
+
+func TestValidateRegexPath(t *testing.T) {
+	testCases := []struct {
+		path string
+		err  string
+	}{
+		// 0
+		{
+			path: "/app",
+		},
+		// 1
+		{
+			path: `/app[0-9]+/?$`,
+		},
+		// 2
+		{
+			path: `/(api|v[0-9]+)/\d+`,
+		},
+		// 3
+		{
+			path: "/app /sub",
+			err:  "regex path cannot have whitespaces",
+		},
+		// 4
+		{
+			path: "/app\t",
+			err:  "regex path cannot have whitespaces",
+		},
+		// 5
+		{
+			path: "/app(",
+			err:  "invalid regex: error parsing regexp: missing closing ): `/app(`",
+		},
+		// 6
+		{
+			path: "/app[0-9",
+			err:  "invalid regex: error parsing regexp: missing closing ]: `[0-9`",
+		},
+	}
+	for i, test := range testCases {
+		var errStr string
+		if err := ValidateRegexPath(test.path); err != nil {
+			errStr = err.Error()
+		}
+		if errStr != test.err {
+			t.Errorf("error differs on %d - expected: '%s', actual: '%s'", i, test.err, errStr)
+		}
+	}
+}
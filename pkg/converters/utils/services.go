@@ -22,7 +22,10 @@ import (
 	"strconv"
 
 	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
+	mcsv1alpha1 "github.com/jcmoraisjr/haproxy-ingress/pkg/api/mcs/v1alpha1"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 )
 
@@ -71,6 +74,9 @@ type Endpoint struct {
 	IP        string
 	Port      int
 	TargetRef string
+	Zone      string
+	ForZones  []string
+	Cluster   string
 }
 
 // CreateEndpoints ...
@@ -79,31 +85,137 @@ func CreateEndpoints(cache types.Cache, svc *api.Service, svcPort *api.ServicePo
 		ready, err := createEndpointsExternalName(svc, svcPort)
 		return ready, nil, err
 	}
-	endpoints, err := cache.GetEndpoints(svc)
+	slices, err := cache.GetEndpointSlices(svc)
 	if err != nil {
 		return nil, nil, err
 	}
-	for _, subset := range endpoints.Subsets {
-		for _, epPort := range subset.Ports {
-			if matchPort(svcPort, &epPort) {
-				port := int(epPort.Port)
-				for _, addr := range subset.Addresses {
-					ready = append(ready, newEndpointAddr(&addr, port))
-				}
-				for _, addr := range subset.NotReadyAddresses {
-					notReady = append(notReady, newEndpointAddr(&addr, port))
+	ready, notReady = readSliceEndpoints(slices, serviceAddressTypes(svc), svcPort.Name)
+	return ready, notReady, nil
+}
+
+// FindServiceImportPort ...
+func FindServiceImportPort(svcImport *mcsv1alpha1.ServiceImport, servicePort string) *mcsv1alpha1.ServicePort {
+	for _, port := range svcImport.Spec.Ports {
+		if port.Name == servicePort || strconv.Itoa(int(port.Port)) == servicePort {
+			return &port
+		}
+	}
+	return nil
+}
+
+// CreateServiceImportEndpoints merges the endpoints of all the clusters
+// exporting a service. The id of the cluster is added to every endpoint.
+func CreateServiceImportEndpoints(cache types.Cache, svcImport *mcsv1alpha1.ServiceImport, port *mcsv1alpha1.ServicePort) (ready, notReady []*Endpoint, err error) {
+	slices, err := cache.GetServiceImportSlices(svcImport)
+	if err != nil {
+		return nil, nil, err
+	}
+	addrTypes := map[discoveryv1.AddressType]bool{
+		discoveryv1.AddressTypeIPv4: true,
+		discoveryv1.AddressTypeIPv6: true,
+	}
+	if len(svcImport.Spec.IPs) > 0 {
+		// only the family of the primary ClusterSet IP, see serviceAddressTypes()
+		if ip := net.ParseIP(svcImport.Spec.IPs[0]); ip != nil && ip.To4() == nil {
+			delete(addrTypes, discoveryv1.AddressTypeIPv4)
+		} else {
+			delete(addrTypes, discoveryv1.AddressTypeIPv6)
+		}
+	}
+	ready, notReady = readSliceEndpoints(slices, addrTypes, port.Name)
+	return ready, notReady, nil
+}
+
+func readSliceEndpoints(slices []*discoveryv1.EndpointSlice, addrTypes map[discoveryv1.AddressType]bool, portName string) (ready, notReady []*Endpoint) {
+	// an endpoint can be found in more than one slice while it is being moved
+	found := map[string]bool{}
+	for _, slice := range slices {
+		if !addrTypes[slice.AddressType] {
+			continue
+		}
+		cluster := slice.Labels[mcsv1alpha1.LabelSourceCluster]
+		for _, epPort := range slice.Ports {
+			if !matchPort(portName, &epPort) {
+				continue
+			}
+			port := int(*epPort.Port)
+			for i := range slice.Endpoints {
+				ep := &slice.Endpoints[i]
+				isReady := ep.Conditions.Ready == nil || *ep.Conditions.Ready
+				for _, ip := range ep.Addresses {
+					key := net.JoinHostPort(ip, strconv.Itoa(port))
+					if found[key] {
+						continue
+					}
+					found[key] = true
+					endpoint := newEndpointAddr(ep, ip, port)
+					endpoint.Cluster = cluster
+					if isReady {
+						ready = append(ready, endpoint)
+					} else {
+						notReady = append(notReady, endpoint)
+					}
 				}
 			}
 		}
 	}
-	return ready, notReady, nil
+	return ready, notReady
+}
+
+// serviceAddressTypes returns the address types of the EndpointSlices that
+// should be used. Only the primary family of dual-stack services is used,
+// otherwise every pod would be added twice.
+func serviceAddressTypes(svc *api.Service) map[discoveryv1.AddressType]bool {
+	if len(svc.Spec.IPFamilies) == 0 {
+		return map[discoveryv1.AddressType]bool{
+			discoveryv1.AddressTypeIPv4: true,
+			discoveryv1.AddressTypeIPv6: true,
+		}
+	}
+	if svc.Spec.IPFamilies[0] == api.IPv6Protocol {
+		return map[discoveryv1.AddressType]bool{discoveryv1.AddressTypeIPv6: true}
+	}
+	return map[discoveryv1.AddressType]bool{discoveryv1.AddressTypeIPv4: true}
 }
 
-func matchPort(svcPort *api.ServicePort, epPort *api.EndpointPort) bool {
-	if epPort.Protocol != api.ProtocolTCP {
+func matchPort(portName string, epPort *discoveryv1.EndpointPort) bool {
+	if epPort.Port == nil {
 		return false
 	}
-	return svcPort.Name == "" || svcPort.Name == epPort.Name
+	if epPort.Protocol != nil && *epPort.Protocol != api.ProtocolTCP {
+		return false
+	}
+	var name string
+	if epPort.Name != nil {
+		name = *epPort.Name
+	}
+	return portName == "" || portName == name
+}
+
+// ServiceImportSuffix is added to the name of a ServiceImport when it is
+// used as a service name, e.g. in backend names and in the tracking. A
+// ServiceImport usually has the same name of the exported service, and a
+// dot cannot be used in service names.
+const ServiceImportSuffix = ".clusterset"
+
+// EndpointSliceService returns the namespace and name of the service of an
+// EndpointSlice. Slices of an imported service return the ServiceImport
+// name followed by ServiceImportSuffix.
+func EndpointSliceService(slice *discoveryv1.EndpointSlice) string {
+	if name, found := slice.Labels[mcsv1alpha1.LabelServiceName]; found {
+		return slice.Namespace + "/" + name + ServiceImportSuffix
+	}
+	return slice.Namespace + "/" + slice.Labels[discoveryv1.LabelServiceName]
+}
+
+// ServiceSelectsPod is true if the selector of a service matches the labels
+// of a pod of the same namespace. Services without a selector, e.g. the
+// ExternalName ones, don't select any pod.
+func ServiceSelectsPod(svc *api.Service, pod *api.Pod) bool {
+	if svc.Namespace != pod.Namespace || len(svc.Spec.Selector) == 0 {
+		return false
+	}
+	return labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels))
 }
 
 // CreateSvcEndpoint ...
@@ -134,12 +246,21 @@ func createEndpointsExternalName(svc *api.Service, svcPort *api.ServicePort) (en
 	return endpoints, nil
 }
 
-func newEndpointAddr(addr *api.EndpointAddress, port int) *Endpoint {
-	return &Endpoint{
-		IP:        addr.IP,
+func newEndpointAddr(ep *discoveryv1.Endpoint, ip string, port int) *Endpoint {
+	endpoint := &Endpoint{
+		IP:        ip,
 		Port:      port,
-		TargetRef: targetRefToString(addr.TargetRef),
+		TargetRef: targetRefToString(ep.TargetRef),
+	}
+	if ep.Zone != nil {
+		endpoint.Zone = *ep.Zone
+	}
+	if ep.Hints != nil {
+		for _, zone := range ep.Hints.ForZones {
+			endpoint.ForZones = append(endpoint.ForZones, zone.Name)
+		}
 	}
+	return endpoint
 }
 
 func targetRefToString(targetRef *api.ObjectReference) string {
@@ -20,10 +20,14 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"strings"
 	"testing"
 
 	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	mcsv1alpha1 "github.com/jcmoraisjr/haproxy-ingress/pkg/api/mcs/v1alpha1"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
 )
 
@@ -103,17 +107,12 @@ func TestCreateEndpoints(t *testing.T) {
 	for _, test := range testCases {
 		c := setup(t)
 		svc, ep := helper_test.CreateService("default/echo", test.declarePort, test.endpoints)
-		for _, ss := range ep.Subsets {
-			for i := range ss.Addresses {
-				ss.Addresses[i].TargetRef = nil
-			}
-			for i := range ss.NotReadyAddresses {
-				ss.NotReadyAddresses[i].TargetRef = nil
-			}
+		for i := range ep.Endpoints {
+			ep.Endpoints[i].TargetRef = nil
 		}
 		cache := &helper_test.CacheMock{
 			SvcList: []*api.Service{svc},
-			EpList:  map[string]*api.Endpoints{"default/echo": ep},
+			EpList:  map[string]*discoveryv1.EndpointSlice{"default/echo": ep},
 		}
 		port := FindServicePort(svc, test.findPort)
 		var endpoints []*Endpoint
@@ -127,6 +126,213 @@ func TestCreateEndpoints(t *testing.T) {
 	}
 }
 
+func TestCreateEndpointsSlices(t *testing.T) {
+	testCases := []struct {
+		families    []api.IPFamily
+		slices      [][]string
+		expReady    []*Endpoint
+		expNotReady []*Endpoint
+	}{
+		// 0
+		{
+			slices: [][]string{
+				{"IPv4", "172.17.0.11", "172.17.0.12"},
+				{"IPv4", "172.17.0.13", "172.17.0.12"},
+			},
+			expReady: []*Endpoint{
+				{IP: "172.17.0.11", Port: 8080},
+				{IP: "172.17.0.12", Port: 8080},
+				{IP: "172.17.0.13", Port: 8080},
+			},
+		},
+		// 1
+		{
+			families: []api.IPFamily{api.IPv4Protocol, api.IPv6Protocol},
+			slices: [][]string{
+				{"IPv4", "172.17.0.11"},
+				{"IPv6", "fd00::11"},
+			},
+			expReady: []*Endpoint{
+				{IP: "172.17.0.11", Port: 8080},
+			},
+		},
+		// 2
+		{
+			families: []api.IPFamily{api.IPv6Protocol, api.IPv4Protocol},
+			slices: [][]string{
+				{"IPv4", "172.17.0.11"},
+				{"IPv6", "fd00::11"},
+			},
+			expReady: []*Endpoint{
+				{IP: "fd00::11", Port: 8080},
+			},
+		},
+		// 3
+		{
+			slices: [][]string{
+				{"FQDN", "app.local"},
+				{"IPv6", "fd00::11"},
+			},
+			expReady: []*Endpoint{
+				{IP: "fd00::11", Port: 8080},
+			},
+		},
+		// 4
+		{
+			slices: [][]string{
+				{"IPv4", "172.17.0.11", "!172.17.0.12", "172.17.0.13@zone-a", "172.17.0.14@zone-a:zone-b"},
+			},
+			expReady: []*Endpoint{
+				{IP: "172.17.0.11", Port: 8080},
+				{IP: "172.17.0.13", Port: 8080, Zone: "zone-a"},
+				{IP: "172.17.0.14", Port: 8080, Zone: "zone-a", ForZones: []string{"zone-b"}},
+			},
+			expNotReady: []*Endpoint{
+				{IP: "172.17.0.12", Port: 8080},
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		svc, _ := helper_test.CreateService("default/echo", "8080", "")
+		svc.Spec.IPFamilies = test.families
+		var slices []*discoveryv1.EndpointSlice
+		for _, addrs := range test.slices {
+			_, slice := helper_test.CreateService("default/echo", "8080", "")
+			slice.AddressType = discoveryv1.AddressType(addrs[0])
+			for _, addr := range addrs[1:] {
+				ready := addr[0] != '!'
+				addr = strings.TrimPrefix(addr, "!")
+				ep := discoveryv1.Endpoint{Conditions: discoveryv1.EndpointConditions{Ready: &ready}}
+				if pos := strings.Index(addr, "@"); pos > 0 {
+					zones := strings.Split(addr[pos+1:], ":")
+					ep.Zone = &zones[0]
+					if len(zones) > 1 {
+						ep.Hints = &discoveryv1.EndpointHints{ForZones: []discoveryv1.ForZone{{Name: zones[1]}}}
+					}
+					addr = addr[:pos]
+				}
+				ep.Addresses = []string{addr}
+				slice.Endpoints = append(slice.Endpoints, ep)
+			}
+			slices = append(slices, slice)
+		}
+		cache := &helper_test.CacheMock{
+			SvcList:     []*api.Service{svc},
+			EpSliceList: map[string][]*discoveryv1.EndpointSlice{"default/echo": slices},
+		}
+		ready, notReady, err := CreateEndpoints(cache, svc, FindServicePort(svc, "8080"))
+		if err != nil {
+			t.Errorf("%d: CreateEndpoints raised an unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(ready, test.expReady) {
+			t.Errorf("%d: ready endpoints differ: expected=%+v actual=%+v", i, test.expReady, ready)
+		}
+		if !reflect.DeepEqual(notReady, test.expNotReady) {
+			t.Errorf("%d: not ready endpoints differ: expected=%+v actual=%+v", i, test.expNotReady, notReady)
+		}
+		c.teardown()
+	}
+}
+
+func TestCreateServiceImportEndpoints(t *testing.T) {
+	testCases := []struct {
+		ips         []string
+		slices      [][]string
+		expReady    []*Endpoint
+		expNotReady []*Endpoint
+	}{
+		// 0
+		{
+			slices: [][]string{
+				{"cluster-a", "IPv4", "172.17.0.11", "172.17.0.12"},
+				{"cluster-b", "IPv4", "172.18.0.11", "!172.18.0.12"},
+			},
+			expReady: []*Endpoint{
+				{IP: "172.17.0.11", Port: 8080, Cluster: "cluster-a"},
+				{IP: "172.17.0.12", Port: 8080, Cluster: "cluster-a"},
+				{IP: "172.18.0.11", Port: 8080, Cluster: "cluster-b"},
+			},
+			expNotReady: []*Endpoint{
+				{IP: "172.18.0.12", Port: 8080, Cluster: "cluster-b"},
+			},
+		},
+		// 1
+		{
+			ips: []string{"fd00::100"},
+			slices: [][]string{
+				{"cluster-a", "IPv4", "172.17.0.11"},
+				{"cluster-a", "IPv6", "fd00::11"},
+				{"cluster-b", "IPv6", "fd01::11"},
+			},
+			expReady: []*Endpoint{
+				{IP: "fd00::11", Port: 8080, Cluster: "cluster-a"},
+				{IP: "fd01::11", Port: 8080, Cluster: "cluster-b"},
+			},
+		},
+		// 2
+		{
+			ips: []string{"10.96.0.100"},
+			slices: [][]string{
+				{"cluster-a", "IPv4", "172.17.0.11"},
+				{"cluster-a", "IPv6", "fd00::11"},
+			},
+			expReady: []*Endpoint{
+				{IP: "172.17.0.11", Port: 8080, Cluster: "cluster-a"},
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		svcImport := &mcsv1alpha1.ServiceImport{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "echo"},
+			Spec: mcsv1alpha1.ServiceImportSpec{
+				Ports: []mcsv1alpha1.ServicePort{{Name: "http", Port: 80}},
+				IPs:   test.ips,
+			},
+		}
+		portName := "http"
+		portNumber := int32(8080)
+		var slices []*discoveryv1.EndpointSlice
+		for _, addrs := range test.slices {
+			slice := &discoveryv1.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "default",
+					Labels: map[string]string{
+						mcsv1alpha1.LabelServiceName:   "echo",
+						mcsv1alpha1.LabelSourceCluster: addrs[0],
+					},
+				},
+				AddressType: discoveryv1.AddressType(addrs[1]),
+				Ports:       []discoveryv1.EndpointPort{{Name: &portName, Port: &portNumber}},
+			}
+			for _, addr := range addrs[2:] {
+				ready := addr[0] != '!'
+				slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+					Addresses:  []string{strings.TrimPrefix(addr, "!")},
+					Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+				})
+			}
+			slices = append(slices, slice)
+		}
+		cache := &helper_test.CacheMock{
+			SvcImpList:   []*mcsv1alpha1.ServiceImport{svcImport},
+			SvcImpSlices: map[string][]*discoveryv1.EndpointSlice{"default/echo": slices},
+		}
+		ready, notReady, err := CreateServiceImportEndpoints(cache, svcImport, FindServiceImportPort(svcImport, "http"))
+		if err != nil {
+			t.Errorf("%d: CreateServiceImportEndpoints raised an unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(ready, test.expReady) {
+			t.Errorf("%d: ready endpoints differ: expected=%+v actual=%+v", i, test.expReady, ready)
+		}
+		if !reflect.DeepEqual(notReady, test.expNotReady) {
+			t.Errorf("%d: not ready endpoints differ: expected=%+v actual=%+v", i, test.expNotReady, notReady)
+		}
+		c.teardown()
+	}
+}
+
 type config struct {
 	t *testing.T
 }
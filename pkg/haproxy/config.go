@@ -18,7 +18,10 @@ package haproxy
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/jinzhu/copier"
@@ -34,6 +37,7 @@ type Config interface {
 	WriteTCPServicesMaps() error
 	WriteFrontendMaps() error
 	WriteBackendMaps() error
+	WriteLuaScripts() error
 	AcmeData() *hatypes.AcmeData
 	Global() *hatypes.Global
 	TCPBackends() *hatypes.TCPBackends
@@ -171,36 +175,34 @@ func (c *config) WriteFrontendMaps() error {
 	}
 	mapBuilder := hatypes.CreateMaps(c.global.MatchOrder)
 	mapsDir := c.options.mapsDir
-	fmaps := &hatypes.FrontendMaps{
-		HTTPHostMap:  mapBuilder.AddMap(mapsDir + "/_front_http_host.map"),
-		HTTPSHostMap: mapBuilder.AddMap(mapsDir + "/_front_https_host.map"),
-		HTTPSSNIMap:  mapBuilder.AddMap(mapsDir + "/_front_https_sni.map"),
-		//
-		RedirFromRootMap:  mapBuilder.AddMap(mapsDir + "/_front_redir_fromroot.map"),
-		RedirFromMap:      mapBuilder.AddMap(mapsDir + "/_front_redir_from.map"),
-		RedirToMap:        mapBuilder.AddMap(mapsDir + "/_front_redir_to.map"),
-		SSLPassthroughMap: mapBuilder.AddMap(mapsDir + "/_front_sslpassthrough.map"),
-		VarNamespaceMap:   mapBuilder.AddMap(mapsDir + "/_front_namespace.map"),
-		//
-		TLSAuthList:           mapBuilder.AddMap(mapsDir + "/_front_tls_auth.list"),
-		TLSNeedCrtList:        mapBuilder.AddMap(mapsDir + "/_front_tls_needcrt.list"),
-		TLSInvalidCrtPagesMap: mapBuilder.AddMap(mapsDir + "/_front_tls_invalidcrt_pages.map"),
-		TLSMissingCrtPagesMap: mapBuilder.AddMap(mapsDir + "/_front_tls_missingcrt_pages.map"),
-		//
-		DefaultHostMap: mapBuilder.AddMap(mapsDir + "/_front_defaulthost.map"),
-	}
 	// TODO crtList* to be removed after implement a template to the crt list
 	c.frontend.CrtListFile = mapsDir + "/_front_bind_crt.list"
-	var crtListItems []*hatypes.HostsMapEntry
-	crtListItems = append(crtListItems, &hatypes.HostsMapEntry{Key: c.frontend.DefaultCrtFile + " !*"})
+	defaultFront := newFrontendMapsBuilder(mapBuilder, mapsDir+"/_front", c.frontend.DefaultCrtFile)
+	extraFronts := make(map[string]*frontendMapsBuilder, len(c.frontend.Extras))
+	for _, extra := range c.frontend.Extras {
+		extra.CrtListFile = fmt.Sprintf("%s/_front_%s_bind_crt.list", mapsDir, extra.Name)
+		crtFile := extra.DefaultCrtFile
+		if crtFile == "" {
+			crtFile = c.frontend.DefaultCrtFile
+		}
+		extraFronts[extra.Name] = newFrontendMapsBuilder(mapBuilder, mapsDir+"/_front_"+extra.Name, crtFile)
+	}
 	hasVarNamespace := c.hosts.HasVarNamespace()
 	defaultHost := c.hosts.DefaultHost()
 	if defaultHost != nil && !defaultHost.SSLPassthrough() {
 		for _, path := range defaultHost.Paths {
-			fmaps.DefaultHostMap.AddHostnamePathMapping("", path, path.Backend.ID)
+			defaultFront.fmaps.DefaultHostMap.AddHostnamePathMapping("", path, path.Backend.ID)
+			for _, extraFront := range extraFronts {
+				extraFront.fmaps.DefaultHostMap.AddHostnamePathMapping("", path, path.Backend.ID)
+			}
 		}
 	}
 	for _, host := range c.hosts.BuildSortedItems() {
+		front := defaultFront
+		if extraFront, found := extraFronts[host.Frontend]; found && !host.SSLPassthrough() {
+			front = extraFront
+		}
+		fmaps := front.fmaps
 		for _, path := range host.Paths {
 			backendID := path.Backend.ID
 			// IMPLEMENT check if host.Alias.AliasName was already used as a hostname
@@ -232,6 +234,7 @@ func (c *config) WriteFrontendMaps() error {
 			} else if path.RedirTo != "" {
 				fmaps.RedirToMap.AddHostnamePathMapping(host.Hostname, path, path.RedirTo)
 				fmaps.RedirToMap.AddAliasPathMapping(host.Alias, path, path.RedirTo)
+				front.redirToPaths = append(front.redirToPaths, redirToPath{host: host, path: path})
 			}
 			if hasVarNamespace {
 				// add "-" on missing paths to avoid overlap
@@ -266,6 +269,12 @@ func (c *config) WriteFrontendMaps() error {
 				}
 			}
 		}
+		if oidc := host.OIDC; oidc.AuthBackendName != "" {
+			fmaps.OIDCMap.AddHostnameMapping(host.Hostname, strings.Join([]string{
+				oidc.AuthBackendName, oidc.Issuer, oidc.ClientID, oidc.ClientSecret,
+				oidc.CookieSecret, oidc.Scopes, oidc.CallbackPath,
+			}, " "))
+		}
 		// TODO wildcard/alias/alias-regex hostname can overlap
 		// a configured domain which doesn't have rootRedirect
 		if host.RootRedirect != "" {
@@ -275,9 +284,9 @@ func (c *config) WriteFrontendMaps() error {
 		tls := host.TLS
 		crtFile := tls.TLSFilename
 		if crtFile == "" {
-			crtFile = c.frontend.DefaultCrtFile
+			crtFile = front.defaultCrt
 		}
-		if crtFile != c.frontend.DefaultCrtFile ||
+		if crtFile != front.defaultCrt ||
 			tls.ALPN != "" ||
 			tls.CAFilename != "" ||
 			tls.Ciphers != "" ||
@@ -314,23 +323,111 @@ func (c *config) WriteFrontendMaps() error {
 			} else {
 				crtListEntry = fmt.Sprintf("%s [%s] %s", crtFile, strings.Join(bindConf, " "), host.Hostname)
 			}
-			crtListItems = append(crtListItems, &hatypes.HostsMapEntry{Key: crtListEntry})
+			front.crtListItems = append(front.crtListItems, &hatypes.HostsMapEntry{Key: crtListEntry})
 		}
 	}
-	if err := c.options.mapsTemplate.WriteOutput(crtListItems, c.frontend.CrtListFile); err != nil {
+	if err := c.options.mapsTemplate.WriteOutput(defaultFront.crtListItems, c.frontend.CrtListFile); err != nil {
 		return err
 	}
+	for _, extra := range c.frontend.Extras {
+		if err := c.options.mapsTemplate.WriteOutput(extraFronts[extra.Name].crtListItems, extra.CrtListFile); err != nil {
+			return err
+		}
+	}
+	defaultFront.addRedirToTypes(c.frontend.RedirectToCode)
+	for _, extraFront := range extraFronts {
+		extraFront.addRedirToTypes(c.frontend.RedirectToCode)
+	}
 	if err := writeMaps(mapBuilder, c.options.mapsTemplate); err != nil {
 		return err
 	}
-	c.frontend.Maps = fmaps
+	c.frontend.Maps = defaultFront.fmaps
+	for _, extra := range c.frontend.Extras {
+		extra.Maps = extraFronts[extra.Name].fmaps
+	}
 	return nil
 }
 
-// WriteBackendMaps reads the model and writes haproxy's maps
-// used in the backends. Should be called before write the main
-// config file. This func doesn't change model state, except the
-// link to the backend maps.
+type frontendMapsBuilder struct {
+	fmaps        *hatypes.FrontendMaps
+	crtListItems []*hatypes.HostsMapEntry
+	defaultCrt   string
+	redirToPaths []redirToPath
+}
+
+type redirToPath struct {
+	host *hatypes.Host
+	path *hatypes.HostPath
+}
+
+func newFrontendMapsBuilder(mapBuilder *hatypes.HostsMaps, prefix, defaultCrt string) *frontendMapsBuilder {
+	return &frontendMapsBuilder{
+		fmaps: &hatypes.FrontendMaps{
+			HTTPHostMap:  mapBuilder.AddMap(prefix + "_http_host.map"),
+			HTTPSHostMap: mapBuilder.AddMap(prefix + "_https_host.map"),
+			HTTPSSNIMap:  mapBuilder.AddMap(prefix + "_https_sni.map"),
+			//
+			RedirFromRootMap:  mapBuilder.AddMap(prefix + "_redir_fromroot.map"),
+			RedirFromMap:      mapBuilder.AddMap(prefix + "_redir_from.map"),
+			RedirToMap:        mapBuilder.AddMap(prefix + "_redir_to.map"),
+			RedirToTypeMap:    mapBuilder.AddMap(prefix + "_redir_to_type.map"),
+			SSLPassthroughMap: mapBuilder.AddMap(prefix + "_sslpassthrough.map"),
+			VarNamespaceMap:   mapBuilder.AddMap(prefix + "_namespace.map"),
+			OIDCMap:           mapBuilder.AddMap(prefix + "_oidc.map"),
+			//
+			TLSAuthList:           mapBuilder.AddMap(prefix + "_tls_auth.list"),
+			TLSNeedCrtList:        mapBuilder.AddMap(prefix + "_tls_needcrt.list"),
+			TLSInvalidCrtPagesMap: mapBuilder.AddMap(prefix + "_tls_invalidcrt_pages.map"),
+			TLSMissingCrtPagesMap: mapBuilder.AddMap(prefix + "_tls_missingcrt_pages.map"),
+			//
+			DefaultHostMap: mapBuilder.AddMap(prefix + "_defaulthost.map"),
+		},
+		crtListItems: []*hatypes.HostsMapEntry{{Key: defaultCrt + " !*"}},
+		defaultCrt:   defaultCrt,
+	}
+}
+
+// addRedirToTypes maps the redirect type and status code of the redirect-to
+// paths, used to find the non default ones. All the paths are mapped if at
+// least one of them doesn't use the default, so a distinct path that
+// overlaps the requested one doesn't override its own type and code.
+func (f *frontendMapsBuilder) addRedirToTypes(defaultCode int) {
+	defaultRedir := hatypes.RedirectTo{Type: hatypes.RedirectLocation, Code: defaultCode}
+	redirs := make([]hatypes.RedirectTo, len(f.redirToPaths))
+	redirTypes := map[hatypes.RedirectTo]bool{}
+	for i, redirPath := range f.redirToPaths {
+		redir := hatypes.RedirectTo{Type: redirPath.path.RedirToType, Code: redirPath.path.RedirToCode}
+		if redir.Type == "" {
+			redir.Type = hatypes.RedirectLocation
+		}
+		if redir.Code == 0 {
+			redir.Code = defaultCode
+		}
+		redirs[i] = redir
+		if redir != defaultRedir {
+			redirTypes[redir] = true
+		}
+	}
+	if len(redirTypes) == 0 {
+		return
+	}
+	for i, redirPath := range f.redirToPaths {
+		value := redirs[i].String()
+		f.fmaps.RedirToTypeMap.AddHostnamePathMapping(redirPath.host.Hostname, redirPath.path, value)
+		f.fmaps.RedirToTypeMap.AddAliasPathMapping(redirPath.host.Alias, redirPath.path, value)
+	}
+	for redir := range redirTypes {
+		f.fmaps.RedirToTypes = append(f.fmaps.RedirToTypes, redir)
+	}
+	sort.Slice(f.fmaps.RedirToTypes, func(i, j int) bool {
+		return f.fmaps.RedirToTypes[i].String() < f.fmaps.RedirToTypes[j].String()
+	})
+}
+
+// WriteBackendMaps reads the model and writes haproxy's maps, error
+// pages and source lists used in the backends. Should be called before
+// write the main config file. This func doesn't change model state,
+// except the link to the backend maps, error page and source list files.
 func (c *config) WriteBackendMaps() error {
 	// TODO rename HostMap types to HAProxyMap
 	if !c.backends.Changed() {
@@ -339,6 +436,18 @@ func (c *config) WriteBackendMaps() error {
 	}
 	mapBuilder := hatypes.CreateMaps(c.global.MatchOrder)
 	for _, backend := range c.backends.ItemsAdd() {
+		for _, page := range backend.ErrorPages {
+			page.Filename = fmt.Sprintf("%s/_back_%s_error%d.http", c.options.mapsDir, backend.ID, page.Code)
+			if err := writeErrorPage(page); err != nil {
+				return err
+			}
+		}
+		for i, list := range backend.SourceLists {
+			list.Filename = fmt.Sprintf("%s/_back_%s_src%02d.list", c.options.mapsDir, backend.ID, i+1)
+			if err := writeSourceList(list); err != nil {
+				return err
+			}
+		}
 		if backend.NeedACL() {
 			mapsPrefix := c.options.mapsDir + "/_back_" + backend.ID
 			pathsMap := mapBuilder.AddMap(mapsPrefix + "_idpath.map")
@@ -366,6 +475,37 @@ func (c *config) WriteBackendMaps() error {
 	return writeMaps(mapBuilder, c.options.mapsTemplate)
 }
 
+// WriteLuaScripts writes the custom Lua scripts loaded by haproxy, and
+// links the scripts to their files.
+func (c *config) WriteLuaScripts() error {
+	for _, script := range c.global.LuaScripts {
+		script.Filename = c.options.mapsDir + "/_lua_" + script.Name
+		if err := ioutil.WriteFile(script.Filename, []byte(script.Content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeErrorPage writes the raw HTTP response used by haproxy's errorfile
+func writeErrorPage(page *hatypes.ErrorPage) error {
+	response := fmt.Sprintf("HTTP/1.0 %d %s\r\n"+
+		"Cache-Control: no-cache\r\n"+
+		"Connection: close\r\n"+
+		"Content-Type: text/html\r\n"+
+		"\r\n%s", page.Code, http.StatusText(page.Code), page.Content)
+	return ioutil.WriteFile(page.Filename, []byte(response), 0644)
+}
+
+// writeSourceList writes the patterns file used by an acl `src -f`
+func writeSourceList(list *hatypes.SourceList) error {
+	var content string
+	if len(list.Entries) > 0 {
+		content = strings.Join(list.Entries, "\n") + "\n"
+	}
+	return ioutil.WriteFile(list.Filename, []byte(content), 0644)
+}
+
 func writeMaps(maps *hatypes.HostsMaps, template *template.Config) error {
 	for _, hmap := range maps.Items {
 		for _, matchFile := range hmap.MatchFiles() {
@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+)
+
+// DryRun writes the configuration files and validates them, without
+// sending commands to haproxy or reloading it. It should only be called
+// by an instance created for the dry run, whose directories are not read
+// by a running haproxy. files has the same content of ConfigFiles(). A
+// nil files means that the configuration couldn't be written, otherwise
+// err is the outcome of the validation.
+func (i *instance) DryRun() (files map[string]string, err error) {
+	if i.config == nil {
+		return nil, fmt.Errorf("configuration wasn't parsed")
+	}
+	i.config.SyncConfig()
+	i.config.Shrink()
+	if err := i.config.WriteTCPServicesMaps(); err != nil {
+		return nil, fmt.Errorf("error building tcp services maps: %w", err)
+	}
+	if err := i.config.WriteFrontendMaps(); err != nil {
+		return nil, fmt.Errorf("error building frontend maps: %w", err)
+	}
+	if err := i.config.WriteBackendMaps(); err != nil {
+		return nil, fmt.Errorf("error building backend maps: %w", err)
+	}
+	if err := i.config.WriteLuaScripts(); err != nil {
+		return nil, fmt.Errorf("error writing lua scripts: %w", err)
+	}
+	if err := i.updateGeoIP(); err != nil {
+		return nil, fmt.Errorf("error converting GeoIP database: %w", err)
+	}
+	if i.options.SortEndpointsBy != "random" {
+		i.config.Backends().SortChangedEndpoints(i.options.SortEndpointsBy)
+	}
+	i.config.Backends().FillSourceIPs()
+	if err := i.writeConfig(); err != nil {
+		return nil, fmt.Errorf("error writing configuration: %w", err)
+	}
+	files, err = i.ConfigFiles()
+	if err != nil {
+		return nil, err
+	}
+	return files, i.check()
+}
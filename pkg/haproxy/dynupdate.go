@@ -20,13 +20,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
-	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 )
 
@@ -36,6 +36,8 @@ type dynUpdater struct {
 	socket  string
 	cmd     func(socket string, observer func(duration time.Duration), commands ...string) ([]string, error)
 	cmdCnt  int
+	reasons []string
+	attrs   []string
 	metrics types.Metrics
 }
 
@@ -59,12 +61,15 @@ func (i *instance) newDynUpdater() *dynUpdater {
 		logger:  i.logger,
 		config:  i.config.(*config),
 		socket:  i.config.Global().AdminSocket,
-		cmd:     utils.HAProxyCommand,
+		cmd:     i.process.command,
 		metrics: i.metrics,
 	}
 }
 
 func (d *dynUpdater) update() bool {
+	if !d.config.hasCommittedData() {
+		d.reasons = []string{"initial"}
+	}
 	updated := d.config.hasCommittedData() && d.checkConfigChange()
 	if !updated {
 		// Need to reload, time to adjust empty slots according to config
@@ -83,6 +88,7 @@ func (d *dynUpdater) checkConfigChange() bool {
 	var diff []string
 	if d.config.globalOld != nil && !reflect.DeepEqual(d.config.globalOld, d.config.global) {
 		diff = append(diff, "global")
+		d.addChangedAttrs("global", d.config.globalOld, d.config.global)
 	}
 	if d.config.tcpbackends.Changed() {
 		diff = append(diff, "tcp-services (configmap)")
@@ -102,6 +108,8 @@ func (d *dynUpdater) checkConfigChange() bool {
 	if !d.backendUpdated() {
 		diff = append(diff, "backends")
 	}
+	d.reasons = diff
+	sort.Strings(d.attrs)
 	if len(diff) > 0 {
 		d.logger.InfoV(2, "need to reload due to config changes: %v", diff)
 		return false
@@ -109,6 +117,27 @@ func (d *dynUpdater) checkConfigChange() bool {
 	return true
 }
 
+func (d *dynUpdater) addAttr(attr string) {
+	d.attrs = append(d.attrs, attr)
+}
+
+// addChangedAttrs adds the name of the exported fields whose values
+// differ between old and cur, two pointers to the same struct type
+func (d *dynUpdater) addChangedAttrs(prefix string, old, cur interface{}) {
+	oldValue := reflect.ValueOf(old).Elem()
+	curValue := reflect.ValueOf(cur).Elem()
+	for i := 0; i < curValue.NumField(); i++ {
+		field := curValue.Type().Field(i)
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), curValue.Field(i).Interface()) {
+			d.addAttr(prefix + "/" + field.Name)
+		}
+	}
+}
+
 func (d *dynUpdater) frontendUpdated() bool {
 	updated := true
 
@@ -121,6 +150,7 @@ func (d *dynUpdater) frontendUpdated() bool {
 		h, found := hosts[id]
 		if !found {
 			d.logger.InfoV(2, "added host '%s'", id)
+			d.addAttr("host:" + id + "/added")
 			updated = false
 		} else {
 			h.cur = host
@@ -130,6 +160,7 @@ func (d *dynUpdater) frontendUpdated() bool {
 	for _, pair := range hosts {
 		if pair.cur == nil {
 			d.logger.InfoV(2, "removed host '%s'", pair.old.Hostname)
+			d.addAttr("host:" + pair.old.Hostname + "/removed")
 			updated = false
 		} else if !d.checkHostPair(pair) {
 			updated = false
@@ -153,6 +184,7 @@ func (d *dynUpdater) backendUpdated() bool {
 		back, found := backends[id]
 		if !found {
 			d.logger.InfoV(2, "added backend '%s'", id)
+			d.addAttr("backend:" + id + "/added")
 			updated = false
 		} else {
 			back.cur = backend
@@ -185,6 +217,7 @@ func (d *dynUpdater) checkHostPair(pair *hostPair) bool {
 	oldHostCopy.TLS.TLSNotAfter = curHost.TLS.TLSNotAfter
 	if !reflect.DeepEqual(&oldHostCopy, curHost) {
 		d.logger.InfoV(2, "diff outside server certificate of host '%s'", curHost.Hostname)
+		d.addChangedAttrs("host:"+curHost.Hostname, &oldHostCopy, curHost)
 		updated = false
 	}
 
@@ -213,14 +246,22 @@ func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 	oldBackCopy.ID = curBack.ID
 	oldBackCopy.Dynamic = curBack.Dynamic
 	oldBackCopy.Endpoints = curBack.Endpoints
+	oldBackCopy.SourceLists = curBack.SourceLists
 	if !reflect.DeepEqual(&oldBackCopy, curBack) {
 		d.logger.InfoV(2, "diff outside endpoints of backend '%s'", curBack.ID)
+		d.addChangedAttrs("backend:"+curBack.ID, &oldBackCopy, curBack)
+		updated = false
+	}
+
+	// source lists can be updated if the lists themselves are the same
+	if !d.checkSourceLists(oldBack, curBack) {
 		updated = false
 	}
 
 	// can decrease endpoints, cannot increase
 	if len(oldBack.Endpoints) < len(curBack.Endpoints) {
 		d.logger.InfoV(2, "added endpoints on backend '%s'", curBack.ID)
+		d.addAttr("backend:" + curBack.ID + "/Endpoints")
 		// cannot continue -- missing empty slots in the backend
 		return false
 	}
@@ -242,6 +283,7 @@ func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 	if !curBack.Dynamic.DynUpdate {
 		if updated && !reflect.DeepEqual(oldBack.Endpoints, curBack.Endpoints) {
 			d.logger.InfoV(2, "backend '%s' changed and its dynamic-scaling is 'false'", curBack.ID)
+			d.addAttr("backend:" + curBack.ID + "/Endpoints")
 			return false
 		}
 		return updated
@@ -275,6 +317,8 @@ func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 	// Try to dynamically remove/update/add endpoints.
 	// Targets being used here only to have predictable results (tests).
 	// Endpoint.Label != "" means use-server of blue/green config, need reload
+	// Endpoint.ABTestRange != "" means use-server of A/B test config, need reload
+	// Endpoint.Backup cannot be changed via runtime api, need reload
 	sort.Strings(targets)
 	for _, target := range targets {
 		pair := endpoints[target]
@@ -284,7 +328,7 @@ func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 			added = added[1:]
 		}
 		if pair.cur == nil {
-			if !d.execDisableEndpoint(curBack.ID, pair.old) || pair.old.Label != "" {
+			if !d.execDisableEndpoint(curBack.ID, pair.old) || pair.old.Label != "" || pair.old.ABTestRange != "" || pair.old.Backup {
 				updated = false
 			}
 			empty = append(empty, pair.old)
@@ -299,7 +343,7 @@ func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 			// if cookie doesn't match here and preserving the value is
 			// important, don't even enable the endpoint before reloading
 			updated = false
-		} else if !d.execEnableEndpoint(curBack.ID, nil, added[i]) || added[i].Label != "" {
+		} else if !d.execEnableEndpoint(curBack.ID, nil, added[i]) || added[i].Label != "" || added[i].ABTestRange != "" || added[i].Backup {
 			updated = false
 		}
 	}
@@ -326,12 +370,31 @@ func (d *dynUpdater) checkEndpointPair(backend *hatypes.Backend, pair *epPair) b
 		return false
 	}
 	updated := d.execEnableEndpoint(backend.ID, pair.old, pair.cur)
-	if !updated || pair.old.Label != "" || pair.cur.Label != "" {
+	if !updated || pair.old.Label != "" || pair.cur.Label != "" || pair.old.ABTestRange != "" || pair.cur.ABTestRange != "" || pair.old.Backup != pair.cur.Backup {
 		return false
 	}
 	return true
 }
 
+func (d *dynUpdater) checkSourceLists(oldBack, curBack *hatypes.Backend) bool {
+	if len(oldBack.SourceLists) != len(curBack.SourceLists) {
+		d.logger.InfoV(2, "added or removed source lists on backend '%s'", curBack.ID)
+		return false
+	}
+	updated := true
+	for i, curList := range curBack.SourceLists {
+		oldList := oldBack.SourceLists[i]
+		if oldList.Name != curList.Name || oldList.Filename != curList.Filename {
+			d.logger.InfoV(2, "added or removed source lists on backend '%s'", curBack.ID)
+			return false
+		}
+		if !reflect.DeepEqual(oldList.Entries, curList.Entries) && !d.execUpdateSourceList(curBack.ID, curList) {
+			updated = false
+		}
+	}
+	return updated
+}
+
 func (d *dynUpdater) alignSlots() {
 	for _, back := range d.config.Backends().Items() {
 		if !back.Dynamic.DynUpdate {
@@ -403,6 +466,46 @@ func (d *dynUpdater) execUpdateCert(hostname, filename string) bool {
 	return true
 }
 
+var regexACLVersion = regexp.MustCompile(`New version created: ([0-9]+)`)
+
+// execUpdateSourceList replaces the content of a source list using a new
+// version of the acl, so the old content is used up to the commit.
+func (d *dynUpdater) execUpdateSourceList(backname string, list *hatypes.SourceList) bool {
+	msg, err := d.execCommand(d.metrics.HAProxySetACLResponseTime, []string{"prepare acl " + list.Filename})
+	if err != nil {
+		d.logger.Error("error preparing source list '%s' on backend '%s': %v", list.Name, backname, err)
+		return false
+	}
+	var match []string
+	if len(msg) > 0 {
+		match = regexACLVersion.FindStringSubmatch(msg[0])
+	}
+	if match == nil {
+		d.logger.Warn("cannot update source list '%s' on backend '%s': unexpected response: %s",
+			list.Name, backname, strings.TrimSpace(strings.Join(msg, " ")))
+		return false
+	}
+	version := match[1]
+	cmd := make([]string, 0, len(list.Entries)+1)
+	for _, entry := range list.Entries {
+		cmd = append(cmd, fmt.Sprintf("add acl @%s %s %s", version, list.Filename, entry))
+	}
+	cmd = append(cmd, fmt.Sprintf("commit acl @%s %s", version, list.Filename))
+	msg, err = d.execCommand(d.metrics.HAProxySetACLResponseTime, cmd)
+	if err != nil {
+		d.logger.Error("error updating source list '%s' on backend '%s': %v", list.Name, backname, err)
+		return false
+	}
+	for _, m := range msg {
+		if m = strings.TrimSpace(m); m != "" {
+			d.logger.Warn("cannot update source list '%s' on backend '%s': %s", list.Name, backname, m)
+			return false
+		}
+	}
+	d.logger.InfoV(2, "updated source list '%s' with %d entries on backend '%s'", list.Name, len(list.Entries), backname)
+	return true
+}
+
 func (d *dynUpdater) execDisableEndpoint(backname string, ep *hatypes.Endpoint) bool {
 	server := fmt.Sprintf("set server %s/%s ", backname, ep.Name)
 	cmd := []string{
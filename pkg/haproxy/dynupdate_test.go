@@ -922,6 +922,125 @@ INFO-V(2) removed host 'domain2.local'
 INFO-V(2) need to reload due to config changes: [hosts]
 `,
 		},
+		// 33
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AddSourceList("default/ips", []string{"10.0.0.0/8"}).Filename = "/tmp/_back_src01.list"
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AddSourceList("default/ips", []string{"10.0.0.0/8", "192.168.0.0/16"}).Filename = "/tmp/_back_src01.list"
+			},
+			dynamic: true,
+			cmd: `
+prepare acl /tmp/_back_src01.list
+add acl @1 /tmp/_back_src01.list 10.0.0.0/8
+add acl @1 /tmp/_back_src01.list 192.168.0.0/16
+commit acl @1 /tmp/_back_src01.list
+`,
+			cmdOutput: []string{
+				"New version created: 1\n",
+			},
+			logging: `
+INFO-V(2) updated source list 'default/ips' with 2 entries on backend 'default_app_8080'
+`,
+		},
+		// 34
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AddSourceList("default/ips", []string{"10.0.0.0/8"}).Filename = "/tmp/_back_src01.list"
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AddSourceList("default/ips", []string{}).Filename = "/tmp/_back_src01.list"
+			},
+			dynamic: false,
+			cmd: `
+prepare acl /tmp/_back_src01.list
+`,
+			cmdOutput: []string{
+				"Unknown ACL identifier. Please use #<id> or <file>.\n",
+			},
+			logging: `
+WARN cannot update source list 'default/ips' on backend 'default_app_8080': unexpected response: Unknown ACL identifier. Please use #<id> or <file>.
+INFO-V(2) need to reload due to config changes: [backends]
+`,
+		},
+		// 35
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AddSourceList("default/ips", []string{"10.0.0.0/8"}).Filename = "/tmp/_back_src01.list"
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AddSourceList("default/ips", []string{"10.0.0.0/8"}).Filename = "/tmp/_back_src01.list"
+				b.AddSourceList("default/office", []string{"192.168.0.0/16"}).Filename = "/tmp/_back_src02.list"
+			},
+			dynamic: false,
+			logging: `
+INFO-V(2) added or removed source lists on backend 'default_app_8080'
+INFO-V(2) need to reload due to config changes: [backends]
+`,
+		},
+		// 36
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AllBackups = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AddEmptyEndpoint()
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AllBackups = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "").Backup = true
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv002:172.17.0.3:8080:1",
+			},
+			dynamic: false,
+			cmd: `
+set server default_app_8080/srv002 addr 172.17.0.3 port 8080
+set server default_app_8080/srv002 state ready
+set server default_app_8080/srv002 weight 1`,
+			logging: `
+INFO-V(2) added endpoint '172.17.0.3:8080' weight '1' state 'ready' on backend/server 'default_app_8080/srv002'
+INFO-V(2) need to reload due to config changes: [backends]`,
+		},
+		// 37
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AllBackups = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "").Backup = true
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AllBackups = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv002:172.17.0.3:8080:1",
+			},
+			dynamic: false,
+			cmd: `
+set server default_app_8080/srv002 addr 172.17.0.3 port 8080
+set server default_app_8080/srv002 state ready
+set server default_app_8080/srv002 weight 1`,
+			logging: `
+INFO-V(2) updated endpoint '172.17.0.3:8080' weight '1' state 'ready' on backend/server 'default_app_8080/srv002'
+INFO-V(2) need to reload due to config changes: [backends]`,
+		},
 	}
 	readFile = func(filename string) ([]byte, error) {
 		return []byte("<content>"), nil
@@ -946,12 +1065,20 @@ INFO-V(2) need to reload due to config changes: [hosts]
 			test.doconfig2(c)
 		}
 		var cmd string
+		cmdOutput := test.cmdOutput
 		dynUpdater := c.instance.newDynUpdater()
 		dynUpdater.cmd = func(socket string, observer func(duration time.Duration), command ...string) ([]string, error) {
+			var output []string
 			for _, c := range command {
 				cmd = cmd + c + "\n"
+				if len(cmdOutput) > 0 {
+					output = append(output, cmdOutput[0])
+					cmdOutput = cmdOutput[1:]
+				} else {
+					output = append(output, "")
+				}
 			}
-			return test.cmdOutput, nil
+			return output, nil
 		}
 		dynamic := dynUpdater.update()
 		var actual []string
@@ -974,3 +1101,93 @@ INFO-V(2) need to reload due to config changes: [hosts]
 		c.teardown()
 	}
 }
+
+func TestDynUpdateAttrs(t *testing.T) {
+	testCases := []struct {
+		doconfig1  func(c *testConfig)
+		doconfig2  func(c *testConfig)
+		expReasons []string
+		expAttrs   []string
+	}{
+		// 0
+		{
+			doconfig1: func(c *testConfig) {
+				c.config.Hosts().AcquireHost("domain1.local")
+				c.config.Backends().AcquireBackend("default", "app", "8080")
+			},
+			doconfig2: func(c *testConfig) {
+				c.config.Hosts().AcquireHost("domain1.local")
+				c.config.Backends().AcquireBackend("default", "app", "8080")
+			},
+		},
+		// 1
+		{
+			doconfig1: func(c *testConfig) {
+				c.config.Global().Timeout.Client = "50s"
+			},
+			doconfig2: func(c *testConfig) {
+				c.config.Global().Timeout.Client = "60s"
+				c.config.Global().MaxConn = 10000
+			},
+			expReasons: []string{"global"},
+			expAttrs:   []string{"global/MaxConn", "global/Timeout"},
+		},
+		// 2
+		{
+			doconfig1: func(c *testConfig) {
+				h := c.config.Hosts().AcquireHost("domain1.local")
+				h.TLS.TLSFilename = "/tmp/domain1.pem"
+				c.config.Hosts().AcquireHost("domain2.local")
+			},
+			doconfig2: func(c *testConfig) {
+				h := c.config.Hosts().AcquireHost("domain1.local")
+				h.TLS.TLSFilename = "/tmp/domain2.pem"
+				h.RootRedirect = "/app"
+				c.config.Hosts().AcquireHost("domain3.local")
+			},
+			expReasons: []string{"hosts"},
+			expAttrs:   []string{"host:domain1.local/RootRedirect", "host:domain1.local/TLS", "host:domain2.local/removed", "host:domain3.local/added"},
+		},
+		// 3
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.Cookie.Name = "serverid"
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.Cookie.Name = "srv"
+				c.config.Backends().AcquireBackend("default", "app", "8081")
+			},
+			expReasons: []string{"backends"},
+			expAttrs:   []string{"backend:default_app_8080/Cookie", "backend:default_app_8081/added"},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		test.doconfig1(c)
+		c.instance.config.Commit()
+		hostnames := []string{}
+		for hostname := range c.config.hosts.Items() {
+			hostnames = append(hostnames, hostname)
+		}
+		c.config.Hosts().RemoveAll(hostnames)
+		backendIDs := []types.BackendID{}
+		for _, backend := range c.config.Backends().Items() {
+			backendIDs = append(backendIDs, backend.BackendID())
+		}
+		c.config.Backends().RemoveAll(backendIDs)
+		test.doconfig2(c)
+		dynUpdater := c.instance.newDynUpdater()
+		dynUpdater.update()
+		if !reflect.DeepEqual(dynUpdater.reasons, test.expReasons) {
+			t.Errorf("reasons differ on %d - expected: %v - actual: %v", i, test.expReasons, dynUpdater.reasons)
+		}
+		if !reflect.DeepEqual(dynUpdater.attrs, test.expAttrs) {
+			t.Errorf("attributes differ on %d - expected: %v - actual: %v", i, test.expAttrs, dynUpdater.attrs)
+		}
+		// logging order depends on the iteration over the changed hosts and backends
+		c.logger.Logging = []string{}
+		c.teardown()
+	}
+}
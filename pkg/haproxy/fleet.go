@@ -0,0 +1,258 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// FleetOptions configures the external fleet mode, where the rendered
+// configuration files are pushed to haproxy instances running outside
+// of the cluster.
+//
+// Every fleet member runs an agent, see FleetAgent, which implements the
+// following HTTP endpoints: `PUT /config` stages a gzipped tarball with
+// the configuration files, whose names are absolute paths without the
+// leading slash; `POST /apply` copies the staged files over the local
+// ones without reloading; `POST /reload` applies and validates the staged
+// files and reloads haproxy; `POST /rollback` restores the files replaced
+// by the last apply or reload and reloads haproxy; `GET /healthz` responds
+// 200 if haproxy is up and running; `POST /runtime` sends the request body
+// as a single command to the haproxy's runtime API and responds with its
+// output.
+type FleetOptions struct {
+	Members       []string
+	Token         string
+	RootCAs       *x509.CertPool
+	Dirs          []string
+	HealthTimeout time.Duration
+}
+
+type fleet struct {
+	logger  types.Logger
+	options *FleetOptions
+	client  *http.Client
+}
+
+func newFleet(logger types.Logger, options *FleetOptions) *fleet {
+	return &fleet{
+		logger:  logger,
+		options: options,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: options.RootCAs},
+			},
+		},
+	}
+}
+
+// push stages the configuration files in all the members
+func (f *fleet) push() error {
+	payload, err := f.buildPayload()
+	if err != nil {
+		return fmt.Errorf("error building configuration payload: %w", err)
+	}
+	for _, member := range f.options.Members {
+		if _, err := f.do(member, http.MethodPut, "/config", payload); err != nil {
+			return fmt.Errorf("error pushing configuration to %s: %w", member, err)
+		}
+	}
+	f.logger.InfoV(2, "configuration (%d bytes) pushed to %d fleet member(s)", len(payload), len(f.options.Members))
+	return nil
+}
+
+// apply pushes the configuration files to all the members, which copy
+// them over their local files without reloading. Used after dynamic
+// updates, so a member restarted out of the controller's control starts
+// with the current state.
+func (f *fleet) apply() error {
+	if err := f.push(); err != nil {
+		return err
+	}
+	for _, member := range f.options.Members {
+		if out, err := f.do(member, http.MethodPost, "/apply", nil); err != nil {
+			return fmt.Errorf("error applying configuration to %s: %w\n%s", member, err, out)
+		}
+	}
+	return nil
+}
+
+// reload pushes the configuration files to all the members and, only if
+// all of them succeed, reloads the members one at a time, waiting each
+// reloaded member to be healthy before moving on to the next one. If a
+// member fails, the members already reloaded are rolled back, so the
+// fleet doesn't stay partially on the new configuration.
+func (f *fleet) reload() error {
+	if err := f.push(); err != nil {
+		return err
+	}
+	for j, member := range f.options.Members {
+		if out, err := f.do(member, http.MethodPost, "/reload", nil); err != nil {
+			// the agent restores its own files if the reload fails
+			f.rollback(f.options.Members[:j])
+			return fmt.Errorf("error reloading %s: %w\n%s", member, err, out)
+		}
+		if err := f.waitHealthy(member); err != nil {
+			f.rollback(f.options.Members[:j+1])
+			return err
+		}
+		f.logger.InfoV(2, "fleet member %s successfully reloaded", member)
+	}
+	return nil
+}
+
+func (f *fleet) rollback(members []string) {
+	for _, member := range members {
+		if out, err := f.do(member, http.MethodPost, "/rollback", nil); err != nil {
+			f.logger.Error("error rolling back fleet member %s: %v\n%s", member, err, out)
+			continue
+		}
+		if err := f.waitHealthy(member); err != nil {
+			f.logger.Error("error rolling back fleet member %s: %v", member, err)
+			continue
+		}
+		f.logger.Warn("fleet member %s was rolled back to the former configuration", member)
+	}
+}
+
+func (f *fleet) waitHealthy(member string) error {
+	timeout := time.Now().Add(f.options.HealthTimeout)
+	for {
+		_, err := f.do(member, http.MethodGet, "/healthz", nil)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(timeout) {
+			return fmt.Errorf("fleet member %s is not healthy after reload: %w", member, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// command sends the runtime API commands to all the members, and has
+// the same signature of hautils.HAProxyCommand, so it can be used by
+// the dynamic updater. The output of the first member is returned.
+func (f *fleet) command(socket string, observer func(duration time.Duration), command ...string) ([]string, error) {
+	var msg []string
+	for _, cmd := range command {
+		start := time.Now()
+		var response string
+		for j, member := range f.options.Members {
+			out, err := f.do(member, http.MethodPost, "/runtime", []byte(cmd))
+			if err != nil {
+				return msg, fmt.Errorf("error sending command to %s: %w", member, err)
+			}
+			if j == 0 {
+				response = strings.TrimRight(string(out), "\n")
+			}
+		}
+		msg = append(msg, response)
+		if observer != nil {
+			observer(time.Since(start))
+		}
+	}
+	return msg, nil
+}
+
+func (f *fleet) do(member, method, path string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(member, "/")+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if f.options.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.options.Token)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	out, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return out, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return out, nil
+}
+
+// buildPayload creates a gzipped tarball with all the files found in the
+// configured directories. File names are stored with their absolute
+// paths, so references in the configuration files don't need to change.
+func (f *fleet) buildPayload() ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, dir := range f.options.Dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			hdr := &tar.Header{
+				Name:    strings.TrimPrefix(path, "/"),
+				Mode:    int64(info.Mode().Perm()),
+				Size:    int64(len(content)),
+				ModTime: info.ModTime(),
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			_, err = tw.Write(content)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,302 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+type fleetAgentMock struct {
+	name     string
+	failPath string
+	mutex    *sync.Mutex
+	calls    *[]string
+	files    []string
+}
+
+func (a *fleetAgentMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mutex.Lock()
+	*a.calls = append(*a.calls, a.name+" "+r.Method+" "+r.URL.Path)
+	a.mutex.Unlock()
+	if r.Header.Get("Authorization") != "Bearer s3cr3t" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path == a.failPath {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	switch r.URL.Path {
+	case "/config":
+		gz, _ := gzip.NewReader(r.Body)
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			content, _ := ioutil.ReadAll(tr)
+			a.files = append(a.files, hdr.Name+"="+string(content))
+		}
+	case "/runtime":
+		cmd, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write([]byte(a.name + ": " + string(cmd) + "\n"))
+	}
+}
+
+func TestFleetReload(t *testing.T) {
+	testCases := []struct {
+		failMember int
+		failPath   string
+		expCalls   []string
+		expError   string
+	}{
+		// 0
+		{
+			failMember: -1,
+			expCalls: []string{
+				"m0 PUT /config",
+				"m1 PUT /config",
+				"m0 POST /reload",
+				"m0 GET /healthz",
+				"m1 POST /reload",
+				"m1 GET /healthz",
+			},
+		},
+		// 1
+		{
+			failMember: 1,
+			failPath:   "/config",
+			expCalls: []string{
+				"m0 PUT /config",
+				"m1 PUT /config",
+			},
+			expError: "error pushing configuration to",
+		},
+		// 2
+		{
+			failMember: 0,
+			failPath:   "/reload",
+			expCalls: []string{
+				"m0 PUT /config",
+				"m1 PUT /config",
+				"m0 POST /reload",
+			},
+			expError: "error reloading",
+		},
+		// 3
+		{
+			failMember: 0,
+			failPath:   "/healthz",
+			expCalls: []string{
+				"m0 PUT /config",
+				"m1 PUT /config",
+				"m0 POST /reload",
+				"m0 GET /healthz",
+				"m0 POST /rollback",
+				"m0 GET /healthz",
+			},
+			expError: "is not healthy after reload",
+		},
+		// 4
+		{
+			failMember: 1,
+			failPath:   "/reload",
+			expCalls: []string{
+				"m0 PUT /config",
+				"m1 PUT /config",
+				"m0 POST /reload",
+				"m0 GET /healthz",
+				"m1 POST /reload",
+				"m0 POST /rollback",
+				"m0 GET /healthz",
+			},
+			expError: "error reloading",
+		},
+		// 5
+		{
+			failMember: 1,
+			failPath:   "/healthz",
+			expCalls: []string{
+				"m0 PUT /config",
+				"m1 PUT /config",
+				"m0 POST /reload",
+				"m0 GET /healthz",
+				"m1 POST /reload",
+				"m1 GET /healthz",
+				"m0 POST /rollback",
+				"m0 GET /healthz",
+				"m1 POST /rollback",
+				"m1 GET /healthz",
+			},
+			expError: "is not healthy after reload",
+		},
+	}
+	tmpdir, err := ioutil.TempDir("", "fleet")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	if err := ioutil.WriteFile(filepath.Join(tmpdir, "haproxy.cfg"), []byte("global"), 0644); err != nil {
+		t.Fatalf("error writing config file: %v", err)
+	}
+	for i, test := range testCases {
+		var calls []string
+		mutex := &sync.Mutex{}
+		var agents []*fleetAgentMock
+		var members []string
+		for j := 0; j < 2; j++ {
+			agent := &fleetAgentMock{name: fmt.Sprintf("m%d", j), mutex: mutex, calls: &calls}
+			if j == test.failMember {
+				agent.failPath = test.failPath
+			}
+			server := httptest.NewServer(agent)
+			defer server.Close()
+			agents = append(agents, agent)
+			members = append(members, server.URL)
+		}
+		logger := helper_test.NewLoggerMock(t)
+		f := newFleet(logger, &FleetOptions{
+			Members: members,
+			Token:   "s3cr3t",
+			Dirs:    []string{tmpdir, filepath.Join(tmpdir, "missing")},
+		})
+		err := f.reload()
+		if test.expError == "" && err != nil {
+			t.Errorf("unexpected error on %d: %v", i, err)
+		} else if test.expError != "" && (err == nil || !strings.Contains(err.Error(), test.expError)) {
+			t.Errorf("expected error '%s' on %d, but found: %v", test.expError, i, err)
+		}
+		if !reflect.DeepEqual(calls, test.expCalls) {
+			t.Errorf("calls differ on %d - expected: %v - actual: %v", i, test.expCalls, calls)
+		}
+		expFile := strings.TrimPrefix(filepath.Join(tmpdir, "haproxy.cfg"), "/") + "=global"
+		if test.failPath != "/config" && !reflect.DeepEqual(agents[0].files, []string{expFile}) {
+			t.Errorf("files differ on %d - expected: %v - actual: %v", i, []string{expFile}, agents[0].files)
+		}
+	}
+}
+
+func TestFleetApply(t *testing.T) {
+	testCases := []struct {
+		failMember int
+		failPath   string
+		expCalls   []string
+		expError   string
+	}{
+		// 0
+		{
+			failMember: -1,
+			expCalls: []string{
+				"m0 PUT /config",
+				"m1 PUT /config",
+				"m0 POST /apply",
+				"m1 POST /apply",
+			},
+		},
+		// 1
+		{
+			failMember: 0,
+			failPath:   "/config",
+			expCalls: []string{
+				"m0 PUT /config",
+			},
+			expError: "error pushing configuration to",
+		},
+		// 2
+		{
+			failMember: 1,
+			failPath:   "/apply",
+			expCalls: []string{
+				"m0 PUT /config",
+				"m1 PUT /config",
+				"m0 POST /apply",
+				"m1 POST /apply",
+			},
+			expError: "error applying configuration to",
+		},
+	}
+	for i, test := range testCases {
+		var calls []string
+		mutex := &sync.Mutex{}
+		var members []string
+		for j := 0; j < 2; j++ {
+			agent := &fleetAgentMock{name: fmt.Sprintf("m%d", j), mutex: mutex, calls: &calls}
+			if j == test.failMember {
+				agent.failPath = test.failPath
+			}
+			server := httptest.NewServer(agent)
+			defer server.Close()
+			members = append(members, server.URL)
+		}
+		f := newFleet(helper_test.NewLoggerMock(t), &FleetOptions{Members: members, Token: "s3cr3t"})
+		err := f.apply()
+		if test.expError == "" && err != nil {
+			t.Errorf("unexpected error on %d: %v", i, err)
+		} else if test.expError != "" && (err == nil || !strings.Contains(err.Error(), test.expError)) {
+			t.Errorf("expected error '%s' on %d, but found: %v", test.expError, i, err)
+		}
+		if !reflect.DeepEqual(calls, test.expCalls) {
+			t.Errorf("calls differ on %d - expected: %v - actual: %v", i, test.expCalls, calls)
+		}
+	}
+}
+
+func TestFleetCommand(t *testing.T) {
+	var calls []string
+	mutex := &sync.Mutex{}
+	var members []string
+	for _, name := range []string{"m0", "m1"} {
+		server := httptest.NewServer(&fleetAgentMock{name: name, mutex: mutex, calls: &calls})
+		defer server.Close()
+		members = append(members, server.URL)
+	}
+	f := newFleet(helper_test.NewLoggerMock(t), &FleetOptions{Members: members, Token: "s3cr3t"})
+	var observed int
+	msg, err := f.command("", func(duration time.Duration) { observed++ }, "show info", "show stat")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expMsg := []string{"m0: show info", "m0: show stat"}
+	if !reflect.DeepEqual(msg, expMsg) {
+		t.Errorf("messages differ - expected: %v - actual: %v", expMsg, msg)
+	}
+	sort.Strings(calls)
+	expCalls := []string{"m0 POST /runtime", "m0 POST /runtime", "m1 POST /runtime", "m1 POST /runtime"}
+	if !reflect.DeepEqual(calls, expCalls) {
+		t.Errorf("calls differ - expected: %v - actual: %v", expCalls, calls)
+	}
+	if observed != 2 {
+		t.Errorf("expected 2 observed commands, found %d", observed)
+	}
+}
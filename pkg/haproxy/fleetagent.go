@@ -0,0 +1,378 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	hautils "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// FleetAgentOptions configures the agent that runs side by side with
+// haproxy in every member of an external fleet. Token is mandatory, requests
+// are refused if it is empty. Dirs has the absolute path of the directories
+// the pushed files can be written to.
+type FleetAgentOptions struct {
+	Token        string
+	Dirs         []string
+	HAProxyCfg   string
+	MasterSocket string
+	AdminSocket  string
+	StagingDir   string
+	BackupDir    string
+}
+
+// FleetAgent implements the endpoints used by the controller in the
+// external fleet mode, see FleetOptions. The pushed files are staged
+// and only copied over the local files on apply or reload. The files
+// replaced by the last apply or reload are saved, so a rollback can
+// restore them and reload haproxy with the previous configuration.
+type FleetAgent struct {
+	logger  types.Logger
+	options *FleetAgentOptions
+	mutex   sync.Mutex
+	root    string
+	backup  *fleetBackup
+	check   func() error
+	reload  func() error
+	healthy func() error
+	command func(cmd string) (string, error)
+}
+
+// fleetBackup has the files that didn't exist before the last apply,
+// the ones that did exist are copied to the backup dir
+type fleetBackup struct {
+	created []string
+}
+
+// NewFleetAgent ...
+func NewFleetAgent(logger types.Logger, options *FleetAgentOptions) *FleetAgent {
+	a := &FleetAgent{
+		logger:  logger,
+		options: options,
+		root:    "/",
+	}
+	a.check = func() error {
+		return checkConfig(options.HAProxyCfg)
+	}
+	a.reload = func() error {
+		if _, err := hautils.HAProxyCommand(options.MasterSocket, nil, "reload"); err != nil {
+			return fmt.Errorf("error sending reload to master socket: %w", err)
+		}
+		return a.healthy()
+	}
+	a.healthy = func() error {
+		procs, err := hautils.HAProxyProcs(options.MasterSocket)
+		if err != nil {
+			return fmt.Errorf("error reading procs from master socket: %w", err)
+		}
+		if len(procs.Workers) == 0 {
+			return fmt.Errorf("haproxy does not have any running worker")
+		}
+		return nil
+	}
+	a.command = func(cmd string) (string, error) {
+		out, err := hautils.HAProxyCommand(options.AdminSocket, nil, cmd)
+		return strings.Join(out, "\n"), err
+	}
+	return a
+}
+
+func (a *FleetAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="haproxy-ingress"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	var out string
+	var status int
+	var err error
+	switch r.Method + " " + r.URL.Path {
+	case "PUT /config":
+		status, err = http.StatusBadRequest, a.stage(r.Body)
+	case "POST /apply":
+		status, err = http.StatusInternalServerError, a.apply()
+	case "POST /reload":
+		status, err = a.applyReload()
+	case "POST /rollback":
+		status, err = a.rollback()
+	case "GET /healthz":
+		status, err = http.StatusServiceUnavailable, a.healthy()
+	case "POST /runtime":
+		var cmd []byte
+		if cmd, err = ioutil.ReadAll(r.Body); err == nil {
+			out, err = a.command(string(cmd))
+		}
+		status = http.StatusInternalServerError
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		a.logger.Warn("fleet agent: %s %s failed: %v", r.Method, r.URL.Path, err)
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if out != "" {
+		_, _ = w.Write([]byte(out + "\n"))
+	}
+}
+
+func (a *FleetAgent) authorized(r *http.Request) bool {
+	if a.options.Token == "" {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.options.Token)) == 1
+}
+
+// stage extracts the gzipped tarball built by fleet.buildPayload()
+// in the staging dir, replacing the files of a former push
+func (a *FleetAgent) stage(payload io.Reader) error {
+	if err := os.RemoveAll(a.options.StagingDir); err != nil {
+		return err
+	}
+	if err := a.extract(payload); err != nil {
+		// a partially staged configuration should not be applied
+		_ = os.RemoveAll(a.options.StagingDir)
+		return err
+	}
+	return nil
+}
+
+func (a *FleetAgent) extract(payload io.Reader) error {
+	gz, err := gzip.NewReader(payload)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		name := filepath.Clean(hdr.Name)
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid file name: %s", hdr.Name)
+		}
+		if !a.allowed(name) {
+			return fmt.Errorf("file outside of the allowed directories: %s", hdr.Name)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := fleetWriteFile(filepath.Join(a.options.StagingDir, name), content, os.FileMode(hdr.Mode).Perm()); err != nil {
+			return err
+		}
+	}
+}
+
+// allowed checks if name, a cleaned path relative to the root dir,
+// is inside one of the directories the pushed files can be written to
+func (a *FleetAgent) allowed(name string) bool {
+	path := "/" + name
+	for _, dir := range a.options.Dirs {
+		dir = filepath.Clean(dir)
+		if dir != "/" && strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// apply copies the staged files over the local ones, saving the replaced
+// files first. Used by dynamic updates, whose changes are already running,
+// so a haproxy restarted out of the controller's control uses them.
+func (a *FleetAgent) apply() error {
+	files, err := fleetListFiles(a.options.StagingDir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("there isn't a staged configuration")
+	}
+	if err := os.RemoveAll(a.options.BackupDir); err != nil {
+		return err
+	}
+	backup := &fleetBackup{}
+	for _, name := range files {
+		content, info, err := fleetReadFile(filepath.Join(a.root, name))
+		if os.IsNotExist(err) {
+			backup.created = append(backup.created, name)
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := fleetWriteFile(filepath.Join(a.options.BackupDir, name), content, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	// from now on a rollback can restore the files, even if the copy fails midway
+	a.backup = backup
+	return fleetCopyFiles(a.options.StagingDir, a.root, files)
+}
+
+// applyReload applies the staged files, validates them and reloads haproxy.
+// The former files are restored if the validation or the reload fails,
+// so a failed reload doesn't need a rollback.
+func (a *FleetAgent) applyReload() (int, error) {
+	if err := a.apply(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err := a.check(); err != nil {
+		if errRestore := a.restore(); errRestore != nil {
+			a.logger.Error("fleet agent: error restoring the former configuration: %v", errRestore)
+		}
+		return http.StatusBadRequest, err
+	}
+	if err := a.reload(); err != nil {
+		if errRestore := a.restore(); errRestore != nil {
+			a.logger.Error("fleet agent: error restoring the former configuration: %v", errRestore)
+		} else if errReload := a.reload(); errReload != nil {
+			a.logger.Error("fleet agent: error reloading the former configuration: %v", errReload)
+		}
+		return http.StatusInternalServerError, err
+	}
+	a.logger.Info("fleet agent: haproxy successfully reloaded")
+	return http.StatusOK, nil
+}
+
+// rollback restores the files replaced by the last apply or reload, and
+// reloads haproxy. Used by the controller if a member fails after a
+// successful reload, so all the members go back to the same state.
+func (a *FleetAgent) rollback() (int, error) {
+	if a.backup == nil {
+		return http.StatusConflict, fmt.Errorf("there isn't a configuration to roll back to")
+	}
+	if err := a.restore(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err := a.reload(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	a.logger.Info("fleet agent: haproxy reloaded with the former configuration")
+	return http.StatusOK, nil
+}
+
+func (a *FleetAgent) restore() error {
+	files, err := fleetListFiles(a.options.BackupDir)
+	if err != nil {
+		return err
+	}
+	if err := fleetCopyFiles(a.options.BackupDir, a.root, files); err != nil {
+		return err
+	}
+	for _, name := range a.backup.created {
+		if err := os.Remove(filepath.Join(a.root, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	a.backup = nil
+	return nil
+}
+
+// fleetListFiles lists the regular files of dir, relative to dir
+func fleetListFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			name, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, name)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func fleetCopyFiles(srcDir, dstDir string, files []string) error {
+	for _, name := range files {
+		content, info, err := fleetReadFile(filepath.Join(srcDir, name))
+		if err != nil {
+			return err
+		}
+		if err := fleetWriteFile(filepath.Join(dstDir, name), content, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fleetReadFile(path string) ([]byte, os.FileInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := ioutil.ReadFile(path)
+	return content, info, err
+}
+
+// fleetWriteFile writes to a temporary file which is renamed afterwards,
+// so haproxy never reads a partially written file
+func fleetWriteFile(path string, content []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,296 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestFleetAgent(t *testing.T) {
+	testCases := []struct {
+		files      map[string]string
+		requests   []string
+		token      string
+		noToken    bool
+		checkErr   bool
+		reloadErr  int
+		healthErr  bool
+		expStatus  []int
+		expFiles   map[string]string
+		expReloads int
+		expOutput  string
+	}{
+		// 0
+		{
+			files:      map[string]string{"etc/haproxy/haproxy.cfg": "v2", "etc/haproxy/maps/_global.map": "m2"},
+			requests:   []string{"PUT /config", "POST /reload"},
+			expStatus:  []int{200, 200},
+			expFiles:   map[string]string{"etc/haproxy/haproxy.cfg": "v2", "etc/haproxy/maps/_global.map": "m2"},
+			expReloads: 1,
+		},
+		// 1
+		{
+			files:     map[string]string{"etc/haproxy/haproxy.cfg": "v2", "etc/haproxy/maps/_global.map": "m2"},
+			requests:  []string{"PUT /config", "POST /reload"},
+			checkErr:  true,
+			expStatus: []int{200, 400},
+			expFiles:  map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+		},
+		// 2
+		{
+			files:      map[string]string{"etc/haproxy/haproxy.cfg": "v2"},
+			requests:   []string{"PUT /config", "POST /reload"},
+			reloadErr:  1,
+			expStatus:  []int{200, 500},
+			expFiles:   map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+			expReloads: 2,
+		},
+		// 3
+		{
+			files:      map[string]string{"etc/haproxy/haproxy.cfg": "v2", "etc/haproxy/maps/_global.map": "m2"},
+			requests:   []string{"PUT /config", "POST /reload", "POST /rollback"},
+			expStatus:  []int{200, 200, 200},
+			expFiles:   map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+			expReloads: 2,
+		},
+		// 4
+		{
+			requests:  []string{"POST /rollback"},
+			expStatus: []int{409},
+			expFiles:  map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+		},
+		// 5
+		{
+			files:      map[string]string{"etc/haproxy/haproxy.cfg": "v2"},
+			requests:   []string{"PUT /config", "POST /reload", "POST /rollback", "POST /rollback"},
+			expStatus:  []int{200, 200, 200, 409},
+			expFiles:   map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+			expReloads: 2,
+		},
+		// 6
+		{
+			files:     map[string]string{"etc/haproxy/haproxy.cfg": "v2"},
+			requests:  []string{"PUT /config", "POST /apply"},
+			expStatus: []int{200, 200},
+			expFiles:  map[string]string{"etc/haproxy/haproxy.cfg": "v2"},
+		},
+		// 7
+		{
+			requests:  []string{"POST /apply", "POST /reload"},
+			expStatus: []int{500, 500},
+			expFiles:  map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+		},
+		// 8
+		{
+			files:     map[string]string{"../etc/passwd": "root"},
+			requests:  []string{"PUT /config"},
+			expStatus: []int{400},
+			expFiles:  map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+		},
+		// 9
+		{
+			files:     map[string]string{"etc/haproxy/haproxy.cfg": "v2", "var/../../etc/passwd": "root"},
+			requests:  []string{"PUT /config", "POST /reload"},
+			expStatus: []int{400, 500},
+			expFiles:  map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+		},
+		// 10
+		{
+			files:     map[string]string{"etc/haproxy/haproxy.cfg": "v2"},
+			requests:  []string{"PUT /config", "POST /reload"},
+			token:     "wrong",
+			expStatus: []int{401, 401},
+			expFiles:  map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+		},
+		// 11
+		{
+			requests:  []string{"GET /healthz", "GET /reload"},
+			expStatus: []int{200, 404},
+			expFiles:  map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+		},
+		// 12
+		{
+			requests:  []string{"GET /healthz"},
+			healthErr: true,
+			expStatus: []int{503},
+			expFiles:  map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+		},
+		// 13
+		{
+			requests:  []string{"POST /runtime"},
+			expStatus: []int{200},
+			expFiles:  map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+			expOutput: "out: show info\n",
+		},
+		// 14
+		{
+			files:     map[string]string{"etc/haproxy/haproxy.cfg": "v2", "etc/passwd": "root"},
+			requests:  []string{"PUT /config", "POST /reload"},
+			expStatus: []int{400, 500},
+			expFiles:  map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+		},
+		// 15
+		{
+			files:     map[string]string{"etc/haproxy.d/haproxy.cfg": "v2"},
+			requests:  []string{"PUT /config"},
+			expStatus: []int{400},
+			expFiles:  map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+		},
+		// 16
+		{
+			files:     map[string]string{"etc/haproxy": "v2"},
+			requests:  []string{"PUT /config"},
+			expStatus: []int{400},
+			expFiles:  map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+		},
+		// 17
+		{
+			files:      map[string]string{"etc/haproxy/haproxy.cfg": "v2", "var/lib/haproxy/crt/default.pem": "crt"},
+			requests:   []string{"PUT /config", "POST /reload"},
+			expStatus:  []int{200, 200},
+			expFiles:   map[string]string{"etc/haproxy/haproxy.cfg": "v2", "var/lib/haproxy/crt/default.pem": "crt"},
+			expReloads: 1,
+		},
+		// 18
+		{
+			files:     map[string]string{"etc/haproxy/haproxy.cfg": "v2"},
+			requests:  []string{"PUT /config", "POST /reload", "GET /healthz"},
+			noToken:   true,
+			expStatus: []int{401, 401, 401},
+			expFiles:  map[string]string{"etc/haproxy/haproxy.cfg": "v1"},
+		},
+	}
+	for i, test := range testCases {
+		dir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Errorf("error creating tempdir: %v", err)
+			continue
+		}
+		root := filepath.Join(dir, "root")
+		_ = fleetWriteFile(filepath.Join(root, "etc/haproxy/haproxy.cfg"), []byte("v1"), 0644)
+		var reloads int
+		agentToken := "s3cr3t"
+		if test.noToken {
+			agentToken = ""
+		}
+		agent := NewFleetAgent(helper_test.NewLoggerMock(t), &FleetAgentOptions{
+			Token:      agentToken,
+			Dirs:       []string{"/etc/haproxy", "/var/lib/haproxy/crt/"},
+			StagingDir: filepath.Join(dir, "staging"),
+			BackupDir:  filepath.Join(dir, "backup"),
+		})
+		agent.root = root
+		agent.check = func() error {
+			if test.checkErr {
+				return fmt.Errorf("invalid config")
+			}
+			return nil
+		}
+		agent.reload = func() error {
+			reloads++
+			if reloads <= test.reloadErr {
+				return fmt.Errorf("reload failed")
+			}
+			return nil
+		}
+		agent.healthy = func() error {
+			if test.healthErr {
+				return fmt.Errorf("haproxy is down")
+			}
+			return nil
+		}
+		agent.command = func(cmd string) (string, error) {
+			return "out: " + cmd, nil
+		}
+		token := test.token
+		if token == "" {
+			token = "s3cr3t"
+		}
+		var status []int
+		var output string
+		for _, request := range test.requests {
+			req := strings.Fields(request)
+			var body []byte
+			switch req[1] {
+			case "/config":
+				body = buildFleetPayload(test.files)
+			case "/runtime":
+				body = []byte("show info")
+			}
+			r := httptest.NewRequest(req[0], req[1], bytes.NewReader(body))
+			r.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			agent.ServeHTTP(w, r)
+			status = append(status, w.Code)
+			if w.Code == http.StatusOK {
+				output = w.Body.String()
+			}
+		}
+		if !reflect.DeepEqual(status, test.expStatus) {
+			t.Errorf("status differ on %d - expected: %v - actual: %v", i, test.expStatus, status)
+		}
+		files := map[string]string{}
+		names, _ := fleetListFiles(root)
+		for _, name := range names {
+			content, _ := ioutil.ReadFile(filepath.Join(root, name))
+			files[name] = string(content)
+		}
+		if !reflect.DeepEqual(files, test.expFiles) {
+			t.Errorf("files differ on %d - expected: %v - actual: %v", i, test.expFiles, files)
+		}
+		if reloads != test.expReloads {
+			t.Errorf("reloads differ on %d - expected: %d - actual: %d", i, test.expReloads, reloads)
+		}
+		if output != test.expOutput {
+			t.Errorf("output differs on %d - expected: %q - actual: %q", i, test.expOutput, output)
+		}
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func buildFleetPayload(files map[string]string) []byte {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		content := files[name]
+		_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})
+		_, _ = tw.Write([]byte(content))
+	}
+	_ = tw.Close()
+	_ = gz.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,311 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// GeoIP country databases are converted to a haproxy map, whose keys are
+// networks and values are ISO 3166 country codes, used by `map_ip`.
+
+const (
+	maxmindBlocksIPv4 = "GeoLite2-Country-Blocks-IPv4.csv"
+	maxmindBlocksIPv6 = "GeoLite2-Country-Blocks-IPv6.csv"
+	maxmindLocations  = "GeoLite2-Country-Locations-en.csv"
+)
+
+type geoipState struct {
+	mutex    sync.Mutex
+	database string
+	format   string
+	// version of the database currently converted to the map file,
+	// empty if the conversion failed or didn't happen yet
+	version string
+}
+
+// updateGeoIP converts the GeoIP database to a haproxy map if it changed
+// since the last conversion, and links the map file to the global config.
+// The last converted map of the same database continues to be used if the
+// conversion fails.
+func (i *instance) updateGeoIP() error {
+	geoip := &i.config.Global().GeoIP
+	state := &i.geoip
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	if state.database != geoip.Database || state.format != geoip.Format {
+		state.database = geoip.Database
+		state.format = geoip.Format
+		state.version = ""
+	}
+	if geoip.Database == "" {
+		return nil
+	}
+	mapFile := i.options.HAProxyMapsDir + "/_geoip_country.map"
+	version, err := geoipVersion(geoip.Format, geoip.Database)
+	if err == nil && version != state.version {
+		var count int
+		count, err = writeGeoIPMap(geoip.Format, geoip.Database, mapFile)
+		if err == nil {
+			i.logger.Info("GeoIP database converted to a map with %d networks", count)
+			state.version = version
+		}
+	}
+	if state.version != "" {
+		geoip.MapFile = mapFile
+		geoip.Version = state.version
+	}
+	return err
+}
+
+// GeoIPOutdated is true if the GeoIP database changed since the last
+// conversion. The conversion itself happens in the next update.
+func (i *instance) GeoIPOutdated() bool {
+	state := &i.geoip
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	if state.database == "" {
+		return false
+	}
+	version, err := geoipVersion(state.format, state.database)
+	return err == nil && version != state.version
+}
+
+func geoipFiles(format, database string) []string {
+	if format == "maxmind" {
+		return []string{
+			filepath.Join(database, maxmindLocations),
+			filepath.Join(database, maxmindBlocksIPv4),
+			filepath.Join(database, maxmindBlocksIPv6),
+		}
+	}
+	return []string{database}
+}
+
+// geoipVersion identifies the content of a database based on the
+// modification time and size of its files.
+func geoipVersion(format, database string) (string, error) {
+	var version []string
+	for _, file := range geoipFiles(format, database) {
+		info, err := os.Stat(file)
+		if os.IsNotExist(err) && strings.HasSuffix(file, maxmindBlocksIPv6) {
+			// IPv6 networks are optional
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		version = append(version, fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size()))
+	}
+	return strings.Join(version, ","), nil
+}
+
+func writeGeoIPMap(format, database, filename string) (count int, err error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".geoip-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	if format == "maxmind" {
+		count, err = convertMaxmind(database, w)
+	} else {
+		count, err = convertIP2Location(database, w)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return 0, err
+	}
+	return count, os.Rename(tmp.Name(), filename)
+}
+
+// convertMaxmind reads the GeoLite2 or GeoIP2 Country database in the CSV
+// format, extracted in the database directory.
+func convertMaxmind(database string, w io.Writer) (int, error) {
+	countries := map[string]string{}
+	err := readCSV(filepath.Join(database, maxmindLocations), []string{"geoname_id", "country_iso_code"}, func(row []string) error {
+		if row[1] != "" {
+			countries[row[0]] = row[1]
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, blocks := range []string{maxmindBlocksIPv4, maxmindBlocksIPv6} {
+		filename := filepath.Join(database, blocks)
+		if _, err := os.Stat(filename); os.IsNotExist(err) && blocks == maxmindBlocksIPv6 {
+			continue
+		}
+		err := readCSV(filename, []string{"network", "geoname_id", "registered_country_geoname_id"}, func(row []string) error {
+			country := countries[row[1]]
+			if country == "" {
+				country = countries[row[2]]
+			}
+			if country == "" {
+				return nil
+			}
+			if _, _, err := net.ParseCIDR(row[0]); err != nil {
+				return err
+			}
+			count++
+			_, err := fmt.Fprintf(w, "%s %s\n", row[0], country)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+// convertIP2Location reads the IP2Location country database (DB1) in the
+// CSV format, either the IPv4 or the IPv6 version. Ranges are converted
+// to the networks that cover them.
+func convertIP2Location(database string, w io.Writer) (int, error) {
+	file, err := os.Open(database)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	maxIPv4 := big.NewInt(0xffffffff)
+	mappedIPv4 := big.NewInt(0xffff00000000)
+	maxMappedIPv4 := big.NewInt(0xffffffffffff)
+	var count int
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(row) < 3 {
+			return 0, fmt.Errorf("%s: line %d: missing fields", database, line)
+		}
+		country := row[2]
+		if country == "-" || country == "" {
+			continue
+		}
+		from, okFrom := new(big.Int).SetString(row[0], 10)
+		to, okTo := new(big.Int).SetString(row[1], 10)
+		if !okFrom || !okTo || from.Cmp(to) > 0 {
+			return 0, fmt.Errorf("%s: line %d: invalid range: %s-%s", database, line, row[0], row[1])
+		}
+		bits := 128
+		if to.Cmp(maxIPv4) <= 0 {
+			bits = 32
+		} else if from.Cmp(mappedIPv4) >= 0 && to.Cmp(maxMappedIPv4) <= 0 {
+			// IPv4-mapped addresses of the IPv6 database
+			from.Sub(from, mappedIPv4)
+			to.Sub(to, mappedIPv4)
+			bits = 32
+		}
+		for _, cidr := range rangeToCIDRs(from, to, bits) {
+			count++
+			if _, err := fmt.Fprintf(w, "%s %s\n", cidr, country); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return count, nil
+}
+
+// rangeToCIDRs lists the smallest number of networks that cover the
+// range of addresses from `from` to `to`, both included.
+func rangeToCIDRs(from, to *big.Int, bits int) []string {
+	var cidrs []string
+	one := big.NewInt(1)
+	from = new(big.Int).Set(from)
+	for from.Cmp(to) <= 0 {
+		// grow the network while it's aligned and doesn't pass `to`
+		size := 0
+		for size < bits && from.Bit(size) == 0 {
+			last := new(big.Int).Lsh(one, uint(size+1))
+			last.Add(last, from).Sub(last, one)
+			if last.Cmp(to) > 0 {
+				break
+			}
+			size++
+		}
+		ip := net.IP(from.FillBytes(make([]byte, bits/8)))
+		cidrs = append(cidrs, fmt.Sprintf("%s/%d", ip, bits-size))
+		from.Add(from, new(big.Int).Lsh(one, uint(size)))
+	}
+	return cidrs
+}
+
+// readCSV reads a CSV file with a header, calling `fn` on every row
+// with the values of the requested columns.
+func readCSV(filename string, columns []string, fn func(row []string) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%s: error reading header: %w", filename, err)
+	}
+	idx := make([]int, len(columns))
+	for i, column := range columns {
+		idx[i] = -1
+		for j, name := range header {
+			if name == column {
+				idx[i] = j
+			}
+		}
+		if idx[i] < 0 {
+			return fmt.Errorf("%s: column not found: %s", filename, column)
+		}
+	}
+	row := make([]string, len(columns))
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		for i := range columns {
+			row[i] = record[idx[i]]
+		}
+		if err := fn(row); err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRangeToCIDRs(t *testing.T) {
+	testCases := []struct {
+		from, to string
+		bits     int
+		expected []string
+	}{
+		// 0
+		{
+			from:     "16777216", // 1.0.0.0
+			to:       "16777471", // 1.0.0.255
+			bits:     32,
+			expected: []string{"1.0.0.0/24"},
+		},
+		// 1
+		{
+			from:     "16777217", // 1.0.0.1
+			to:       "16777218", // 1.0.0.2
+			bits:     32,
+			expected: []string{"1.0.0.1/32", "1.0.0.2/32"},
+		},
+		// 2
+		{
+			from:     "16777472", // 1.0.1.0
+			to:       "16778239", // 1.0.3.255
+			bits:     32,
+			expected: []string{"1.0.1.0/24", "1.0.2.0/23"},
+		},
+		// 3
+		{
+			from:     "0",
+			to:       "4294967295",
+			bits:     32,
+			expected: []string{"0.0.0.0/0"},
+		},
+		// 4
+		{
+			from:     "42540766411282592856903984951653826560", // 2001:db8::
+			to:       "42540766411282592875350729025363378175", // 2001:db8:0:ffff:ffff:ffff:ffff:ffff
+			bits:     128,
+			expected: []string{"2001:db8::/64"},
+		},
+	}
+	for i, test := range testCases {
+		from, _ := new(big.Int).SetString(test.from, 10)
+		to, _ := new(big.Int).SetString(test.to, 10)
+		actual := rangeToCIDRs(from, to, test.bits)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("cidrs differ on %d - expected: %v - actual: %v", i, test.expected, actual)
+		}
+	}
+}
+
+func TestWriteGeoIPMap(t *testing.T) {
+	testCases := []struct {
+		format   string
+		files    map[string]string
+		expected string
+		expErr   string
+	}{
+		// 0
+		{
+			format: "maxmind",
+			files: map[string]string{
+				maxmindLocations: `geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name,is_in_european_union
+3469034,en,SA,"South America",BR,Brazil,0
+6255148,en,EU,Europe,,,0
+`,
+				maxmindBlocksIPv4: `network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider
+1.0.0.0/24,3469034,3469034,,0,0
+1.0.1.0/24,,3469034,,0,0
+1.0.2.0/24,6255148,6255148,,0,0
+`,
+				maxmindBlocksIPv6: `network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider
+2001:db8::/32,3469034,3469034,,0,0
+`,
+			},
+			expected: `
+1.0.0.0/24 BR
+1.0.1.0/24 BR
+2001:db8::/32 BR
+`,
+		},
+		// 1
+		{
+			format: "maxmind",
+			files: map[string]string{
+				maxmindLocations: `geoname_id,country_iso_code
+3469034,BR
+`,
+				maxmindBlocksIPv4: `network,geoname_id,registered_country_geoname_id
+1.0.0.0/24,3469034,3469034
+`,
+			},
+			expected: `
+1.0.0.0/24 BR
+`,
+		},
+		// 2
+		{
+			format: "maxmind",
+			files: map[string]string{
+				maxmindLocations: `geoname_id,country_iso_code
+3469034,BR
+`,
+				maxmindBlocksIPv4: `network,geoname_id
+1.0.0.0/24,3469034
+`,
+			},
+			expErr: "column not found: registered_country_geoname_id",
+		},
+		// 3
+		{
+			format: "ip2location",
+			files: map[string]string{
+				"db1.csv": `"0","16777215","-","-"
+"16777216","16777471","US","United States of America"
+"16777472","16778239","CN","China"
+`,
+			},
+			expected: `
+1.0.0.0/24 US
+1.0.1.0/24 CN
+1.0.2.0/23 CN
+`,
+		},
+		// 4
+		{
+			format: "ip2location",
+			files: map[string]string{
+				"db1.csv": `"281470698520576","281470698520831","US","United States of America"
+"42540766411282592856903984951653826560","42540766411282592875350729025363378175","BR","Brazil"
+`,
+			},
+			expected: `
+1.0.0.0/24 US
+2001:db8::/64 BR
+`,
+		},
+		// 5
+		{
+			format: "ip2location",
+			files: map[string]string{
+				"db1.csv": `"16777471","16777216","US","United States of America"
+`,
+			},
+			expErr: "line 1: invalid range: 16777471-16777216",
+		},
+	}
+	for i, test := range testCases {
+		dir, err := ioutil.TempDir("", "geoip")
+		if err != nil {
+			t.Fatalf("error creating tempdir: %v", err)
+		}
+		for name, content := range test.files {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatalf("error writing database: %v", err)
+			}
+		}
+		database := dir
+		if test.format == "ip2location" {
+			database = filepath.Join(dir, "db1.csv")
+		}
+		mapFile := filepath.Join(dir, "country.map")
+		_, err = writeGeoIPMap(test.format, database, mapFile)
+		var actualErr string
+		if err != nil {
+			actualErr = err.Error()
+		}
+		if !strings.HasSuffix(actualErr, test.expErr) || (test.expErr == "") != (actualErr == "") {
+			t.Errorf("error differs on %d - expected: '%s' - actual: '%s'", i, test.expErr, actualErr)
+		}
+		if test.expErr == "" {
+			content, _ := ioutil.ReadFile(mapFile)
+			if actual, expected := string(content), strings.TrimPrefix(test.expected, "\n"); actual != expected {
+				t.Errorf("map differs on %d - expected:\n%s\nactual:\n%s", i, expected, actual)
+			}
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func TestUpdateGeoIP(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+	database := filepath.Join(c.tempdir, "db1.csv")
+	writeDB := func(content string, mtime time.Time) {
+		if err := ioutil.WriteFile(database, []byte(content), 0644); err != nil {
+			t.Fatalf("error writing database: %v", err)
+		}
+		if err := os.Chtimes(database, mtime, mtime); err != nil {
+			t.Fatalf("error changing database mtime: %v", err)
+		}
+	}
+	mapFile := filepath.Join(c.tempdir, "_geoip_country.map")
+	readMap := func() string {
+		content, _ := ioutil.ReadFile(mapFile)
+		return string(content)
+	}
+	now := time.Now()
+	geoip := &c.instance.config.Global().GeoIP
+	geoip.Database = database
+	geoip.Format = "ip2location"
+
+	// missing database
+	if err := c.instance.updateGeoIP(); err == nil {
+		t.Errorf("expected an error reading a missing database")
+	}
+	if geoip.MapFile != "" || c.instance.GeoIPOutdated() {
+		t.Errorf("expected an unlinked and up to date map: '%s'", geoip.MapFile)
+	}
+
+	// first conversion
+	writeDB(`"16777216","16777471","US","United States of America"`, now.Add(-time.Hour))
+	if !c.instance.GeoIPOutdated() {
+		t.Errorf("expected an outdated map after creating the database")
+	}
+	if err := c.instance.updateGeoIP(); err != nil {
+		t.Errorf("error converting the database: %v", err)
+	}
+	if geoip.MapFile != mapFile || geoip.Version == "" || c.instance.GeoIPOutdated() {
+		t.Errorf("expected a linked and up to date map: '%s' '%s'", geoip.MapFile, geoip.Version)
+	}
+	if actual := readMap(); actual != "1.0.0.0/24 US\n" {
+		t.Errorf("unexpected map content: %s", actual)
+	}
+
+	// changed to an invalid database, last map continues to be used
+	version := geoip.Version
+	writeDB(`"16777216","US"`, now)
+	if !c.instance.GeoIPOutdated() {
+		t.Errorf("expected an outdated map after changing the database")
+	}
+	if err := c.instance.updateGeoIP(); err == nil {
+		t.Errorf("expected an error reading an invalid database")
+	}
+	if geoip.MapFile != mapFile || geoip.Version != version {
+		t.Errorf("expected the last map: '%s' '%s'", geoip.MapFile, geoip.Version)
+	}
+	if actual := readMap(); actual != "1.0.0.0/24 US\n" {
+		t.Errorf("unexpected map content: %s", actual)
+	}
+
+	// fixed database
+	writeDB(`"16777216","16777471","BR","Brazil"`, now.Add(time.Hour))
+	if err := c.instance.updateGeoIP(); err != nil {
+		t.Errorf("error converting the database: %v", err)
+	}
+	if geoip.Version == version || c.instance.GeoIPOutdated() {
+		t.Errorf("expected a new and up to date map: '%s'", geoip.Version)
+	}
+	if actual := readMap(); actual != "1.0.0.0/24 BR\n" {
+		t.Errorf("unexpected map content: %s", actual)
+	}
+
+	// database removed from the configuration
+	geoip.Database = ""
+	geoip.MapFile = ""
+	if err := c.instance.updateGeoIP(); err != nil || geoip.MapFile != "" || c.instance.GeoIPOutdated() {
+		t.Errorf("expected an unlinked map: '%s' %v", geoip.MapFile, err)
+	}
+	c.logger.CompareLogging(`
+INFO GeoIP database converted to a map with 1 networks
+INFO GeoIP database converted to a map with 1 networks`)
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// haproxy doesn't have per-host counters, so the traffic of a host is
+// aggregated from the stats of the backends its paths point to. A backend
+// shared by more than one host, or by more than one resource of the same
+// host, has its traffic added to all of them.
+
+type hostTrafficKey struct {
+	hostname  string
+	namespace string
+	name      string
+}
+
+// backendTraffic has the fields of a backend read from `show stat`
+type backendTraffic struct {
+	requests int
+	errors   int
+	ttime    int
+}
+
+// hostTraffic is the traffic of a host since the last read
+type hostTraffic struct {
+	requests int
+	errors   int
+	ttime    time.Duration
+}
+
+type hostTrafficState struct {
+	mutex    sync.Mutex
+	backends map[string]backendTraffic
+	hosts    map[hostTrafficKey]bool
+}
+
+// CalcHostTrafficMetric reads the stats of the backends and adds the
+// requests, server errors and response time of every host/resource pair
+// to the host traffic metrics.
+func (i *instance) CalcHostTrafficMetric() {
+	if !i.up {
+		return
+	}
+	hostBackends := map[hostTrafficKey]map[string]bool{}
+	for _, host := range i.config.Hosts().Items() {
+		for _, path := range host.Paths {
+			if path.Backend.ID == "" || path.Source.Name == "" {
+				continue
+			}
+			key := hostTrafficKey{
+				hostname:  host.Hostname,
+				namespace: path.Source.Namespace,
+				name:      path.Source.Name,
+			}
+			if hostBackends[key] == nil {
+				hostBackends[key] = map[string]bool{}
+			}
+			hostBackends[key][path.Backend.ID] = true
+		}
+	}
+	// backends only, see `show stat` on haproxy's management guide
+	msg, err := i.process.command(i.config.Global().AdminSocket, nil, "show stat -1 2 -1")
+	if err != nil {
+		i.logger.Error("error reading admin socket: %v", err)
+		return
+	}
+	stats, err := readBackendTraffic(msg[0])
+	if err != nil {
+		i.logger.Error("error reading backend stats: %v", err)
+		return
+	}
+	state := &i.hostTraffic
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	traffic := state.update(hostBackends, stats)
+	for key := range state.hosts {
+		if _, found := traffic[key]; !found {
+			i.metrics.DeleteHostTraffic(key.hostname, key.namespace, key.name)
+		}
+	}
+	state.hosts = make(map[hostTrafficKey]bool, len(traffic))
+	for key, t := range traffic {
+		state.hosts[key] = true
+		i.metrics.AddHostTraffic(key.hostname, key.namespace, key.name, t.requests, t.errors)
+		if t.requests > 0 {
+			i.metrics.ObserveHostResponseTime(key.hostname, key.namespace, key.name, t.ttime)
+		}
+	}
+}
+
+// update calculates the traffic of the hosts since the last read, and
+// stores the current stats of the backends for the next one. haproxy
+// resets the counters on reloads, so a counter lower than the former
+// one is used as is.
+func (s *hostTrafficState) update(hostBackends map[hostTrafficKey]map[string]bool, stats map[string]backendTraffic) map[hostTrafficKey]hostTraffic {
+	delta := func(current, last int) int {
+		if current < last {
+			return current
+		}
+		return current - last
+	}
+	traffic := make(map[hostTrafficKey]hostTraffic, len(hostBackends))
+	for key, backends := range hostBackends {
+		var t hostTraffic
+		var ttimeSum int
+		for backend := range backends {
+			stat, found := stats[backend]
+			if !found {
+				continue
+			}
+			last := s.backends[backend]
+			requests := delta(stat.requests, last.requests)
+			t.requests += requests
+			t.errors += delta(stat.errors, last.errors)
+			ttimeSum += stat.ttime * requests
+		}
+		if t.requests > 0 {
+			t.ttime = time.Duration(ttimeSum/t.requests) * time.Millisecond
+		}
+		traffic[key] = t
+	}
+	s.backends = stats
+	return traffic
+}
+
+// readBackendTraffic reads the total number of requests, the number of
+// 5xx responses and the average total time of the last 1024 requests,
+// in milliseconds, of the backends of a `show stat` output.
+func readBackendTraffic(stat string) (map[string]backendTraffic, error) {
+	lines := strings.Split(stat, "\n")
+	header := strings.Split(strings.TrimPrefix(lines[0], "# "), ",")
+	pxname, svname, reqTot, hrsp5xx, ttime := -1, -1, -1, -1, -1
+	for i, field := range header {
+		switch field {
+		case "pxname":
+			pxname = i
+		case "svname":
+			svname = i
+		case "req_tot":
+			reqTot = i
+		case "hrsp_5xx":
+			hrsp5xx = i
+		case "ttime":
+			ttime = i
+		}
+	}
+	if pxname < 0 || svname < 0 || reqTot < 0 || hrsp5xx < 0 || ttime < 0 {
+		return nil, fmt.Errorf("missing pxname, svname, req_tot, hrsp_5xx or ttime fields")
+	}
+	traffic := map[string]backendTraffic{}
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, ",")
+		if len(fields) < len(header) || fields[svname] != "BACKEND" {
+			continue
+		}
+		var t backendTraffic
+		t.requests, _ = strconv.Atoi(fields[reqTot])
+		t.errors, _ = strconv.Atoi(fields[hrsp5xx])
+		t.ttime, _ = strconv.Atoi(fields[ttime])
+		traffic[fields[pxname]] = t
+	}
+	return traffic, nil
+}
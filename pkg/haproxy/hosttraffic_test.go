@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadBackendTraffic(t *testing.T) {
+	const header = "# pxname,svname,qcur,req_tot,hrsp_5xx,ttime,"
+	testCases := []struct {
+		stat     string
+		expected map[string]backendTraffic
+		expErr   string
+	}{
+		// 0
+		{
+			stat:     header,
+			expected: map[string]backendTraffic{},
+		},
+		// 1
+		{
+			stat: header + `
+default_app_8080,BACKEND,0,120,3,25,
+default_app_8080,srv001,0,60,1,20,
+default_other_8080,BACKEND,0,10,0,400,
+default_broken_8080,BACKEND,0`,
+			expected: map[string]backendTraffic{
+				"default_app_8080":   {requests: 120, errors: 3, ttime: 25},
+				"default_other_8080": {requests: 10, errors: 0, ttime: 400},
+			},
+		},
+		// 2
+		{
+			stat:   "# pxname,svname,qcur,req_tot",
+			expErr: "missing pxname, svname, req_tot, hrsp_5xx or ttime fields",
+		},
+	}
+	for i, test := range testCases {
+		actual, err := readBackendTraffic(test.stat)
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != test.expErr {
+			t.Errorf("error differs on %d - expected: %s, actual: %s", i, test.expErr, errMsg)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("traffic differs on %d - expected: %v, actual: %v", i, test.expected, actual)
+		}
+	}
+}
+
+func TestHostTrafficUpdate(t *testing.T) {
+	echo := hostTrafficKey{hostname: "d1.local", namespace: "default", name: "echo"}
+	app := hostTrafficKey{hostname: "d1.local", namespace: "default", name: "app"}
+	hostBackends := map[hostTrafficKey]map[string]bool{
+		echo: {"default_echo_8080": true},
+		app:  {"default_app_8080": true, "default_api_8080": true},
+	}
+	testCases := []struct {
+		stats    map[string]backendTraffic
+		expected map[hostTrafficKey]hostTraffic
+	}{
+		// 0
+		{
+			stats: map[string]backendTraffic{
+				"default_echo_8080": {requests: 10, errors: 1, ttime: 20},
+			},
+			expected: map[hostTrafficKey]hostTraffic{
+				echo: {requests: 10, errors: 1, ttime: 20 * time.Millisecond},
+				app:  {},
+			},
+		},
+		// 1
+		{
+			stats: map[string]backendTraffic{
+				"default_echo_8080": {requests: 15, errors: 1, ttime: 30},
+				"default_app_8080":  {requests: 30, errors: 2, ttime: 10},
+				"default_api_8080":  {requests: 10, errors: 0, ttime: 50},
+			},
+			expected: map[hostTrafficKey]hostTraffic{
+				echo: {requests: 5, errors: 0, ttime: 30 * time.Millisecond},
+				app:  {requests: 40, errors: 2, ttime: 20 * time.Millisecond},
+			},
+		},
+		// 2 - haproxy reloaded, counters were reset
+		{
+			stats: map[string]backendTraffic{
+				"default_echo_8080": {requests: 3, errors: 0, ttime: 40},
+				"default_app_8080":  {requests: 40, errors: 2, ttime: 10},
+				"default_api_8080":  {requests: 10, errors: 0, ttime: 50},
+			},
+			expected: map[hostTrafficKey]hostTraffic{
+				echo: {requests: 3, errors: 0, ttime: 40 * time.Millisecond},
+				app:  {requests: 10, errors: 0, ttime: 10 * time.Millisecond},
+			},
+		},
+	}
+	var state hostTrafficState
+	for i, test := range testCases {
+		actual := state.update(hostBackends, test.stats)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("host traffic differs on %d - expected: %v, actual: %v", i, test.expected, actual)
+		}
+	}
+}
@@ -18,18 +18,19 @@ package haproxy
 
 import (
 	"fmt"
-	"os/exec"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/acme"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/template"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
-	hautils "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
@@ -39,17 +40,25 @@ type InstanceOptions struct {
 	AcmeSigner        acme.Signer
 	AcmeQueue         utils.Queue
 	BackendShards     int
+	DrainTimeout      time.Duration
+	Fleet             *FleetOptions
 	HAProxyCfgDir     string
 	HAProxyMapsDir    string
+	HAProxyPidFile    string
+	HandoffSocket     string
 	LeaderElector     types.LeaderElector
+	MasterSocket      string
 	MaxOldConfigFiles int
 	Metrics           types.Metrics
+	Process           string
 	ReloadStrategy    string
+	RollbackOnFailure bool
 	SortEndpointsBy   string
 	StopCh            chan struct{}
 	ValidateConfig    bool
 	// TODO Fake is used to skip real haproxy calls. Use a mock instead.
-	fake bool
+	fake         bool
+	fakeCheckErr error
 }
 
 // Instance ...
@@ -58,30 +67,64 @@ type Instance interface {
 	ParseTemplates() error
 	Config() Config
 	CalcIdleMetric()
+	CalcOldProcsMetric()
+	CalcEjectionsMetric()
+	CalcBlockedUserAgentsMetric()
+	CalcHostTrafficMetric()
+	Degraded() bool
+	GeoIPOutdated() bool
+	LastReload() *ReloadStatus
 	Update(timer *utils.Timer)
+	DryRun() (files map[string]string, err error)
+	ConfigFiles() (map[string]string, error)
+	Drain()
+	Shutdown()
+}
+
+// ReloadStatus describes the haproxy reload made by the last update
+type ReloadStatus struct {
+	// Reasons lists the parts of the configuration that couldn't be
+	// dynamically updated, e.g. global, hosts or backends
+	Reasons []string
+	// Attributes lists the changed attributes of the global config,
+	// hosts and backends, e.g. `global/Timeout` or `backend:default_echo_8080/Cookie`
+	Attributes []string
+	Success    bool
 }
 
 // CreateInstance ...
 func CreateInstance(logger types.Logger, options InstanceOptions) Instance {
-	return &instance{
+	i := &instance{
 		logger:      logger,
 		options:     &options,
 		haproxyTmpl: template.CreateConfig(),
 		mapsTmpl:    template.CreateConfig(),
 		modsecTmpl:  template.CreateConfig(),
+		spoeTmpl:    template.CreateConfig(),
+		corazaTmpl:  template.CreateConfig(),
 		metrics:     options.Metrics,
 	}
+	i.process = createProcess(logger, i.options)
+	return i
 }
 
 type instance struct {
 	up          bool
+	degraded    bool
+	lastGood    map[string][]byte
+	lastReload  *ReloadStatus
+	process     process
 	logger      types.Logger
 	options     *InstanceOptions
 	haproxyTmpl *template.Config
 	mapsTmpl    *template.Config
 	modsecTmpl  *template.Config
+	spoeTmpl    *template.Config
+	corazaTmpl  *template.Config
 	config      Config
 	metrics     types.Metrics
+	geoip       geoipState
+	hostTraffic hostTrafficState
 }
 
 func (i *instance) AcmeCheck(source string) (int, error) {
@@ -139,10 +182,30 @@ func (i *instance) ParseTemplates() error {
 	i.haproxyTmpl.ClearTemplates()
 	i.mapsTmpl.ClearTemplates()
 	i.modsecTmpl.ClearTemplates()
+	i.spoeTmpl.ClearTemplates()
+	i.corazaTmpl.ClearTemplates()
 	if err := i.modsecTmpl.NewTemplate(
 		"modsecurity.tmpl",
 		"/etc/templates/modsecurity/modsecurity.tmpl",
-		"/etc/haproxy/spoe-modsecurity.conf",
+		filepath.Join(i.options.HAProxyCfgDir, "spoe-modsecurity.conf"),
+		0,
+		1024,
+	); err != nil {
+		return err
+	}
+	if err := i.spoeTmpl.NewTemplate(
+		"spoe.tmpl",
+		"/etc/templates/spoe/spoe.tmpl",
+		filepath.Join(i.options.HAProxyCfgDir, "spoe-agents.conf"),
+		0,
+		1024,
+	); err != nil {
+		return err
+	}
+	if err := i.corazaTmpl.NewTemplate(
+		"coraza.tmpl",
+		"/etc/templates/coraza/coraza.tmpl",
+		filepath.Join(i.options.HAProxyCfgDir, "spoe-coraza.conf"),
 		0,
 		1024,
 	); err != nil {
@@ -151,7 +214,7 @@ func (i *instance) ParseTemplates() error {
 	if err := i.haproxyTmpl.NewTemplate(
 		"haproxy.tmpl",
 		"/etc/templates/haproxy/haproxy.tmpl",
-		"/etc/haproxy/haproxy.cfg",
+		filepath.Join(i.options.HAProxyCfgDir, "haproxy.cfg"),
 		i.options.MaxOldConfigFiles,
 		16384,
 	); err != nil {
@@ -185,7 +248,7 @@ func (i *instance) CalcIdleMetric() {
 	if !i.up {
 		return
 	}
-	msg, err := hautils.HAProxyCommand(i.config.Global().AdminSocket, i.metrics.HAProxyShowInfoResponseTime, "show info")
+	msg, err := i.process.command(i.config.Global().AdminSocket, i.metrics.HAProxyShowInfoResponseTime, "show info")
 	if err != nil {
 		i.logger.Error("error reading admin socket: %v", err)
 		return
@@ -202,6 +265,175 @@ func (i *instance) CalcIdleMetric() {
 	i.metrics.AddIdleFactor(idle)
 }
 
+func (i *instance) CalcOldProcsMetric() {
+	if !i.up || i.options.Process == ProcessExternal {
+		return
+	}
+	procs, err := i.process.oldProcs()
+	if err != nil {
+		i.logger.Warn("error reading old haproxy processes: %v", err)
+		return
+	}
+	i.metrics.SetOldProcs(len(procs))
+}
+
+// CalcEjectionsMetric updates the number of times the servers of the
+// backends with outlier detection were marked as down, either by health
+// checks or by the observed traffic. haproxy resets the counters on reloads.
+func (i *instance) CalcEjectionsMetric() {
+	if !i.up {
+		return
+	}
+	backends := map[string]bool{}
+	for _, backend := range i.config.Backends().Items() {
+		if backend.Server.Observe != "" {
+			backends[backend.ID] = true
+		}
+	}
+	if len(backends) == 0 {
+		i.metrics.ClearBackendEjections()
+		return
+	}
+	// servers only, see `show stat` on haproxy's management guide
+	msg, err := i.process.command(i.config.Global().AdminSocket, nil, "show stat -1 4 -1")
+	if err != nil {
+		i.logger.Error("error reading admin socket: %v", err)
+		return
+	}
+	ejections, err := readEjections(msg[0], backends)
+	if err != nil {
+		i.logger.Error("error reading server stats: %v", err)
+		return
+	}
+	i.metrics.ClearBackendEjections()
+	for backend, count := range ejections {
+		i.metrics.SetBackendEjections(backend, count)
+	}
+}
+
+// readEjections sums the `chkdown` field, the number of UP to DOWN
+// transitions, of the servers of the listed backends.
+func readEjections(stat string, backends map[string]bool) (map[string]int, error) {
+	lines := strings.Split(stat, "\n")
+	header := strings.Split(strings.TrimPrefix(lines[0], "# "), ",")
+	pxname, chkdown := -1, -1
+	for i, field := range header {
+		switch field {
+		case "pxname":
+			pxname = i
+		case "chkdown":
+			chkdown = i
+		}
+	}
+	if pxname < 0 || chkdown < 0 {
+		return nil, fmt.Errorf("missing pxname or chkdown fields")
+	}
+	ejections := make(map[string]int, len(backends))
+	for backend := range backends {
+		ejections[backend] = 0
+	}
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, ",")
+		if len(fields) <= chkdown || !backends[fields[pxname]] {
+			continue
+		}
+		count, _ := strconv.Atoi(fields[chkdown])
+		ejections[fields[pxname]] += count
+	}
+	return ejections, nil
+}
+
+// CalcBlockedUserAgentsMetric updates the number of requests blocked by
+// every user-agent pattern, tracked in the _blocked_user_agents stick table.
+// haproxy resets the table on reloads.
+func (i *instance) CalcBlockedUserAgentsMetric() {
+	if !i.up {
+		return
+	}
+	if !i.config.Backends().HasBlockUserAgents() {
+		i.metrics.ClearBlockedUserAgents()
+		return
+	}
+	msg, err := i.process.command(i.config.Global().AdminSocket, nil, "show table _blocked_user_agents")
+	if err != nil {
+		i.logger.Error("error reading admin socket: %v", err)
+		return
+	}
+	i.metrics.ClearBlockedUserAgents()
+	for pattern, count := range readBlockedUserAgents(msg[0]) {
+		i.metrics.SetBlockedUserAgents(pattern, count)
+	}
+}
+
+var tableEntryRegex = regexp.MustCompile(`^0x[0-9a-f]+: key=(.*) use=[0-9]+ exp=[0-9]+ http_req_cnt=([0-9]+)`)
+
+// readBlockedUserAgents reads the request count of every key of a `show table` output.
+func readBlockedUserAgents(table string) map[string]int {
+	counts := map[string]int{}
+	for _, line := range strings.Split(table, "\n") {
+		match := tableEntryRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		count, _ := strconv.Atoi(match[2])
+		counts[match[1]] = count
+	}
+	return counts
+}
+
+// stopOldProcs hard-stops the oldest haproxy processes if the number of
+// old processes is greater than max-old-workers. Embedded haproxy only,
+// haproxy running as a sidecar should use worker-max-reloads instead.
+func (i *instance) stopOldProcs() {
+	procs, err := i.process.oldProcs()
+	if err != nil {
+		i.logger.Warn("error reading old haproxy processes: %v", err)
+		return
+	}
+	maxOld := i.config.Global().Master.MaxOldWorkers
+	if maxOld > 0 && len(procs) > maxOld && i.process.canStopOldProcs() {
+		stop := procs[:len(procs)-maxOld]
+		for _, pid := range stop {
+			if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+				i.logger.Warn("error stopping old haproxy process %d: %v", pid, err)
+			}
+		}
+		i.logger.Info("stopped %d old haproxy process(es) due to max-old-workers: %v", len(stop), stop)
+		procs = procs[len(stop):]
+	}
+	i.metrics.SetOldProcs(len(procs))
+}
+
+func (i *instance) Degraded() bool {
+	return i.degraded
+}
+
+func (i *instance) LastReload() *ReloadStatus {
+	return i.lastReload
+}
+
+// Drain disables the healthz frontend, so load balancers that check the
+// haproxy's health stop sending new connections to this instance.
+func (i *instance) Drain() {
+	if !i.up || i.options.fake {
+		return
+	}
+	if _, err := i.process.command(i.config.Global().AdminSocket, nil, "disable frontend healthz"); err != nil {
+		i.logger.Warn("error disabling the healthz frontend: %v", err)
+		return
+	}
+	i.logger.Info("healthz frontend disabled, haproxy is draining")
+}
+
+func (i *instance) Shutdown() {
+	if !i.up || i.options.fake {
+		return
+	}
+	if err := i.process.stop(); err != nil {
+		i.logger.Warn("error stopping haproxy: %v", err)
+	}
+}
+
 func (i *instance) Update(timer *utils.Timer) {
 	i.acmeUpdate()
 	i.haproxyUpdate(timer)
@@ -230,6 +462,7 @@ func (i *instance) acmeUpdate() {
 }
 
 func (i *instance) haproxyUpdate(timer *utils.Timer) {
+	i.lastReload = nil
 	// nil config, just ignore
 	if i.config == nil {
 		return
@@ -258,6 +491,14 @@ func (i *instance) haproxyUpdate(timer *utils.Timer) {
 		i.metrics.IncUpdateNoop()
 		return
 	}
+	if err := i.config.WriteLuaScripts(); err != nil {
+		i.logger.Error("error writing lua scripts: %v", err)
+		i.metrics.IncUpdateNoop()
+		return
+	}
+	if err := i.updateGeoIP(); err != nil {
+		i.logger.Error("error converting GeoIP database: %v", err)
+	}
 	timer.Tick("write_maps")
 	if !i.options.fake {
 		// TODO update tests and remove `if !fake` above
@@ -265,6 +506,13 @@ func (i *instance) haproxyUpdate(timer *utils.Timer) {
 	}
 	updater := i.newDynUpdater()
 	updated := updater.update()
+	reasons := updater.reasons
+	if i.degraded {
+		// haproxy is running the last known good configuration,
+		// a reload is needed to apply the current state
+		updated = false
+		reasons = append(reasons, "degraded")
+	}
 	if i.options.SortEndpointsBy != "random" {
 		i.config.Backends().SortChangedEndpoints(i.options.SortEndpointsBy)
 	} else if !updated {
@@ -288,14 +536,15 @@ func (i *instance) haproxyUpdate(timer *utils.Timer) {
 	i.updateCertExpiring()
 	if updated {
 		if updater.cmdCnt > 0 {
+			var err error
 			if i.options.ValidateConfig {
-				var err error
-				if err = i.check(); err != nil {
-					i.logger.Error("error validating config file:\n%v", err)
-				}
+				err = i.checkDynamicUpdate()
 				timer.Tick("validate_cfg")
 				i.metrics.UpdateSuccessful(err == nil)
 			}
+			if p, ok := i.process.(*externalProcess); ok && err == nil {
+				i.applyFleet(p.fleet)
+			}
 			i.logger.Info("haproxy updated without needing to reload. Commands sent: %d", updater.cmdCnt)
 			i.metrics.IncUpdateDynamic()
 		} else {
@@ -305,19 +554,22 @@ func (i *instance) haproxyUpdate(timer *utils.Timer) {
 		return
 	}
 	i.metrics.IncUpdateFull()
+	i.lastReload = &ReloadStatus{Reasons: reasons, Attributes: updater.attrs}
 	if err := i.reload(); err != nil {
 		i.logger.Error("error reloading server:\n%v", err)
 		i.metrics.UpdateSuccessful(false)
+		i.rollback()
 		timer.Tick("reload_haproxy")
 		return
 	}
 	i.up = true
+	i.lastReload.Success = true
 	i.metrics.UpdateSuccessful(true)
-	if i.config.Global().External.IsExternal() {
-		i.logger.Info("haproxy successfully reloaded (external)")
-	} else {
-		i.logger.Info("haproxy successfully reloaded (embedded)")
+	i.saveLastGood()
+	if !i.options.fake && i.options.Process != ProcessExternal {
+		i.stopOldProcs()
 	}
+	i.logger.Info("haproxy successfully reloaded (%s)", i.processMode())
 	timer.Tick("reload_haproxy")
 }
 
@@ -367,6 +619,20 @@ func (i *instance) writeConfig() (err error) {
 		return err
 	}
 	//
+	// spoe agents template execution
+	//
+	err = i.spoeTmpl.Write(i.config)
+	if err != nil {
+		return err
+	}
+	//
+	// coraza template execution
+	//
+	err = i.corazaTmpl.Write(i.config)
+	if err != nil {
+		return err
+	}
+	//
 	// haproxy template execution
 	//
 	//   a single template is used to generate all haproxy cfg files
@@ -406,6 +672,150 @@ func (i *instance) writeConfig() (err error) {
 	return err
 }
 
+// ConfigFiles returns the content of all the files found in the configuration
+// and maps directories, indexed by their path relative to the configuration
+// directory.
+func (i *instance) ConfigFiles() (map[string]string, error) {
+	files := map[string]string{}
+	for _, dir := range []string{i.options.HAProxyCfgDir, i.options.HAProxyMapsDir} {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			name, err := filepath.Rel(i.options.HAProxyCfgDir, path)
+			if err != nil {
+				return err
+			}
+			if _, found := files[name]; found {
+				return nil
+			}
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files[name] = string(content)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading configuration files: %w", err)
+		}
+	}
+	return files, nil
+}
+
+// lastGoodFiles lists the configuration files that should be saved
+// and restored when rollbackOnFailure is enabled
+func (i *instance) lastGoodFiles() []string {
+	var files []string
+	for _, dir := range []string{i.options.HAProxyCfgDir, i.options.HAProxyMapsDir} {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			i.logger.Warn("error reading config dir: %v", err)
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.Mode().IsRegular() {
+				continue
+			}
+			if dir == i.options.HAProxyCfgDir && !strings.HasSuffix(name, ".cfg") && !strings.HasSuffix(name, ".conf") {
+				// rotated config files and other non haproxy config files
+				continue
+			}
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	return files
+}
+
+func (i *instance) saveLastGood() {
+	if i.degraded {
+		i.logger.Info("haproxy recovered from a failed update, last known good configuration was updated")
+		i.degraded = false
+		i.metrics.SetDegraded(false)
+	}
+	if !i.options.RollbackOnFailure {
+		return
+	}
+	lastGood := make(map[string][]byte)
+	for _, file := range i.lastGoodFiles() {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			i.logger.Warn("cannot save last known good configuration: %v", err)
+			return
+		}
+		lastGood[file] = content
+	}
+	i.lastGood = lastGood
+}
+
+// checkDynamicUpdate validates the configuration files of an update that
+// was applied via the runtime API. The changes are already running when the
+// files are validated, so haproxy is reloaded after a rollback, otherwise
+// the files on disk would not match the running haproxy, and the next
+// reload would silently revert the changes applied via the runtime API.
+func (i *instance) checkDynamicUpdate() error {
+	err := i.check()
+	if err == nil {
+		return nil
+	}
+	i.logger.Error("error validating config file:\n%v", err)
+	if !i.rollback() {
+		return err
+	}
+	i.lastReload = &ReloadStatus{Reasons: []string{"rollback"}}
+	if err := i.reload(); err != nil {
+		i.logger.Error("error reloading the last known good configuration:\n%v", err)
+		return err
+	}
+	i.lastReload.Success = true
+	i.logger.Info("haproxy reloaded with the last known good configuration (%s)", i.processMode())
+	return err
+}
+
+// applyFleet copies the configuration files of a dynamic update over the
+// files of the fleet members. The members are already running the changes,
+// but their files diverge if the apply fails, so the instance is flagged as
+// degraded and the next update reloads all the members.
+func (i *instance) applyFleet(f *fleet) {
+	if err := f.apply(); err != nil {
+		i.logger.Error("error applying configuration to the fleet: %v", err)
+		i.metrics.UpdateSuccessful(false)
+		i.degraded = true
+		i.metrics.SetDegraded(true)
+	}
+}
+
+// rollback restores the configuration files of the last successful reload,
+// so haproxy keeps a consistent state with the running configuration if a
+// reload happens outside of the controller, e.g. due to a crash. The running
+// haproxy still uses the last known good configuration because a failed
+// reload doesn't stop the old instance. Returns true if the files were
+// restored.
+func (i *instance) rollback() bool {
+	if !i.options.RollbackOnFailure || i.lastGood == nil {
+		return false
+	}
+	for _, file := range i.lastGoodFiles() {
+		if _, found := i.lastGood[file]; !found && filepath.Dir(file) == i.options.HAProxyCfgDir {
+			// new config file, e.g. a new backend shard, not part of the last known good state
+			if err := os.Remove(file); err != nil {
+				i.logger.Warn("error removing config file: %v", err)
+			}
+		}
+	}
+	for file, content := range i.lastGood {
+		if err := ioutil.WriteFile(file, content, 0644); err != nil {
+			i.logger.Error("error restoring last known good configuration: %v", err)
+			return false
+		}
+	}
+	i.degraded = true
+	i.metrics.SetDegraded(true)
+	i.logger.Warn("last known good configuration was restored")
+	return true
+}
+
 func (i *instance) updateCertExpiring() {
 	hostsAdd := i.config.Hosts().ItemsAdd()
 	hostsDel := i.config.Hosts().ItemsDel()
@@ -436,19 +846,9 @@ func (i *instance) updateCertExpiring() {
 func (i *instance) check() error {
 	if i.options.fake {
 		i.logger.Info("(test) check was skipped")
-		return nil
-	}
-	if i.config.Global().External.IsExternal() {
-		// TODO check config on remote haproxy
-	} else {
-		// TODO Move all magic strings to a single place
-		out, err := exec.Command("haproxy", "-c", "-f", i.options.HAProxyCfgDir).CombinedOutput()
-		outstr := string(out)
-		if err != nil {
-			return fmt.Errorf(outstr)
-		}
+		return i.options.fakeCheckErr
 	}
-	return nil
+	return i.process.check()
 }
 
 func (i *instance) reload() error {
@@ -456,58 +856,29 @@ func (i *instance) reload() error {
 		i.logger.Info("(test) reload was skipped")
 		return nil
 	}
-	if i.config.Global().External.IsExternal() {
-		return i.reloadExternal()
+	if !i.up {
+		if err := i.process.start(); err != nil {
+			return err
+		}
 	}
-	return i.reloadEmbedded()
-}
-
-func (i *instance) reloadEmbedded() error {
-	state := "0"
 	if i.config.Global().LoadServerState {
-		state = "1"
-	}
-	// TODO Move all magic strings to a single place
-	out, err := exec.Command("/haproxy-reload.sh", i.options.ReloadStrategy, i.options.HAProxyCfgDir, state).CombinedOutput()
-	outstr := string(out)
-	if len(outstr) > 0 {
-		i.logger.Warn("output from haproxy:\n%v", outstr)
+		if i.options.Process == ProcessExternal {
+			i.logger.Warn("load-server-state is not supported in the external process mode")
+		} else if err := i.saveServerState(); err != nil {
+			i.logger.Warn("error saving server state, servers will start with their initial state: %v", err)
+		}
 	}
-	return err
+	return i.process.reload(i.config.Global())
 }
 
-func (i *instance) reloadExternal() error {
-	socket := i.config.Global().External.MasterSocket
-	if !i.up {
-		// first run, wait until the external haproxy is running
-		// and successfully listening to the master socket.
-		var j int
-		i.logger.Info("waiting for the external haproxy...")
-		for {
-			var err error
-			if _, err = hautils.HAProxyCommand(socket, nil, "show proc"); err == nil {
-				break
-			}
-			j++
-			if j%10 == 0 {
-				i.logger.Info("cannot connect to the master socket '%s': %v", socket, err)
-			}
-			select {
-			case <-i.options.StopCh:
-				return fmt.Errorf("received sigterm")
-			case <-time.After(time.Second):
-			}
-		}
-	}
-	if _, err := hautils.HAProxyCommand(socket, nil, "reload"); err != nil {
-		return fmt.Errorf("error sending reload to master socket: %w", err)
+// processMode is the process mode used in the log messages. Tests don't
+// configure the process mode, the master socket is used instead.
+func (i *instance) processMode() string {
+	if i.options.Process != "" {
+		return i.options.Process
 	}
-	out, err := hautils.HAProxyProcs(socket)
-	if err != nil {
-		return fmt.Errorf("error reading procs from master socket: %w", err)
-	}
-	if len(out.Workers) == 0 {
-		return fmt.Errorf("external haproxy was not successfully reloaded")
+	if i.config.Global().External.IsExternal() {
+		return "external"
 	}
-	return nil
+	return ProcessEmbedded
 }
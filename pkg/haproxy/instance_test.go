@@ -20,10 +20,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/kylelemons/godebug/diff"
@@ -92,10 +96,36 @@ func TestBackends(t *testing.T) {
 				b.Cookie.Name = "Ingress"
 				b.Cookie.Strategy = "insert"
 				b.Cookie.Keywords = "indirect nocache httponly"
-				b.Cookie.SameSite = true
+				b.Cookie.SameSite = "None"
+				b.Cookie.Secure = true
 			},
 			expected: `
     cookie Ingress insert attr SameSite=None secure indirect nocache httponly`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Cookie.Name = "Ingress"
+				b.Cookie.Strategy = "insert"
+				b.Cookie.Keywords = "indirect nocache"
+				b.Cookie.SameSite = "Strict"
+				b.Cookie.Secure = true
+				b.Cookie.HTTPOnly = true
+				b.Cookie.Path = "/app"
+				b.Cookie.MaxAge = 3600
+			},
+			expected: `
+    cookie Ingress insert attr SameSite=Strict secure httponly attr Path=/app attr Max-Age=3600 indirect nocache`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.BalanceAlgorithm = "hdr(X-Image-Id)"
+				b.HashBalance.Type = "consistent"
+				b.HashBalance.BalanceFactor = 150
+			},
+			expected: `
+    balance hdr(X-Image-Id)
+    hash-type consistent
+    hash-balance-factor 150`,
 		},
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
@@ -324,6 +354,31 @@ d1.local#/ path01`,
     http-request replace-path ^/app(.*)$       /other/\1
     http-request replace-path ^/app/sub(.*)$       /other/\1`,
 		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/api")[0].Link).RewriteURL = "/other"
+				b.FindBackendPath(h.FindPath("/api/v([0-9]+)/(.*)")[0].Link).RewriteURL = `/v\1/\2`
+			},
+			path:  []string{"/", "/app", "/api", "/path", "/api/v([0-9]+)/(.*)"},
+			match: []hatypes.MatchType{hatypes.MatchBegin, hatypes.MatchExact, hatypes.MatchBegin, hatypes.MatchPrefix, hatypes.MatchRegex},
+			expected: `
+    # path01 = d1.local/
+    # path03 = d1.local/api
+    # path05 = d1.local/api/v([0-9]+)/(.*)
+    # path02 = d1.local/app
+    # path04 = d1.local/path
+    http-request set-var(txn.pathID) var(req.base),map_str(/etc/haproxy/maps/_back_d1_app_8080_idpath__exact.map)
+    http-request set-var(txn.pathID) var(req.base),map_dir(/etc/haproxy/maps/_back_d1_app_8080_idpath__prefix_02.map) if !{ var(txn.pathID) -m found }
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map) if !{ var(txn.pathID) -m found }
+    http-request set-var(txn.pathID) var(req.base),map_reg(/etc/haproxy/maps/_back_d1_app_8080_idpath__regex.map) if !{ var(txn.pathID) -m found }
+    http-request replace-path ^/api(.*)$       /other\1     if { var(txn.pathID) path03 }
+    http-request replace-path ^/api/v([0-9]+)/(.*)     /v\1/\2     if { var(txn.pathID) path05 }`,
+			expFronts: "<<frontends-default-match-4>>",
+			expCheck: map[string]string{
+				"_back_d1_app_8080_idpath__regex.map": `
+^d1\.local#/api/v([0-9]+)/(.*) path05`,
+			},
+		},
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
 				b.FindBackendPath(h.FindPath("/path1")[0].Link).RewriteURL = "/sub1"
@@ -546,6 +601,78 @@ d1.local#/api path02`,
     acl deny_rule_tcp src 10.0.0.0/8 192.168.0.0/16
     acl deny_exception_tcp src 192.168.95.0/24
     tcp-request content reject if deny_rule_tcp !deny_exception_tcp`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Server.Observe = "layer7"
+				b.Server.ErrorLimit = 10
+				b.Server.OnError = "mark-down"
+			},
+			srvsuffix: "observe layer7 error-limit 10 on-error mark-down",
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Server.MaxConn = 100
+				b.Server.SlowStart = "30s"
+			},
+			srvsuffix: "maxconn 100 slowstart 30s",
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Retry.Retries = "2"
+				b.Retry.RetryOn = []string{"conn-failure", "empty-response", "503"}
+				b.Retry.Redispatch = "true"
+			},
+			expected: `
+    retries 2
+    retry-on conn-failure empty-response 503
+    option redispatch`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Retry.Redispatch = "true"
+				b.Retry.RedispatchInterval = -1
+			},
+			expected: `
+    option redispatch -1`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Retry.Retries = "0"
+				b.Retry.Redispatch = "false"
+			},
+			expected: `
+    retries 0
+    no option redispatch`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Retry.RetryOn = []string{"conn-failure"}
+				b.ModeTCP = true
+			},
+			expected: ``,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Compression.Algos = []string{"gzip"}
+			},
+			expected: `
+    filter compression
+    compression algo gzip`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Compression = hatypes.Compression{
+					Algos:   []string{"gzip", "deflate"},
+					MinSize: 1024,
+					Types:   []string{"text/html", "application/json"},
+				}
+			},
+			expected: `
+    filter compression
+    compression algo gzip deflate
+    compression type text/html application/json
+    compression minsize-res 1024`,
 		},
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
@@ -569,6 +696,78 @@ d1.local#/api path02`,
 			expCheck: map[string]string{
 				"_back_d1_app_8080_idpath__begin.map": `
 d1.local#/app path02
+d1.local#/ path01`,
+			},
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Timeout.Server = "10s"
+				b.FindBackendPath(h.FindPath("/app")[0].Link).Timeout = hatypes.PathTimeoutConfig{
+					Server: "5m",
+					Tunnel: "2h",
+				}
+			},
+			path: []string{"/", "/app"},
+			expected: `
+    timeout server 10s
+    # path01 = d1.local/
+    # path02 = d1.local/app
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request set-timeout server 5m if { var(txn.pathID) path02 }
+    http-request set-timeout tunnel 2h if { var(txn.pathID) path02 }`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/")[0].Link).Maintenance = hatypes.Maintenance{Enabled: true}
+			},
+			expected: `
+    http-request deny deny_status 503`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/app")[0].Link).Maintenance = hatypes.Maintenance{
+					AllowList: []string{"10.0.0.0/8", "192.168.1.10"},
+					Enabled:   true,
+				}
+				b.FindBackendPath(h.FindPath("/api")[0].Link).Maintenance = hatypes.Maintenance{
+					Enabled:     true,
+					RedirectURL: "https://status.local/",
+				}
+			},
+			path: []string{"/", "/app", "/api"},
+			expected: `
+    # path01 = d1.local/
+    # path03 = d1.local/api
+    # path02 = d1.local/app
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request redirect location https://status.local/ code 302 if { var(txn.pathID) path03 }
+    acl maintenance_allow2 src 10.0.0.0/8 192.168.1.10
+    http-request deny deny_status 503 if { var(txn.pathID) path02 } !maintenance_allow2`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/")[0].Link).Mirror = hatypes.Mirror{AuthBackendName: "_auth_4001", Percentage: 100}
+				b.FindBackendPath(h.FindPath("/app")[0].Link).Mirror = hatypes.Mirror{AuthBackendName: "_auth_4001", Percentage: 100}
+			},
+			path: []string{"/", "/app"},
+			expected: `
+    option http-buffer-request
+    http-request lua.mirror _auth_4001`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/app")[0].Link).Mirror = hatypes.Mirror{AuthBackendName: "_auth_4001", Percentage: 25}
+			},
+			path: []string{"/", "/app"},
+			expected: `
+    # path01 = d1.local/
+    # path02 = d1.local/app
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    option http-buffer-request
+    http-request lua.mirror _auth_4001 if { rand(100) lt 25 } { var(txn.pathID) path02 }`,
+			expCheck: map[string]string{
+				"_back_d1_app_8080_idpath__begin.map": `
+d1.local#/app path02
 d1.local#/ path01`,
 			},
 		},
@@ -652,6 +851,151 @@ d1.local#/ path01`,
     http-request lua.auth-intercept _auth_4001 /oauth2/auth HEAD '.*' '-' '-' if { var(txn.pathID) path01 }
     http-request redirect location http://auth.local/login if !{ var(txn.auth_response_successful) -m bool } { var(txn.pathID) path01 }
     http-request set-header X-Auth-Request-Email %[var(req.auth_response_header.x_auth_request_email)] if { var(req.auth_response_header.x_auth_request_email) -m found } { var(txn.pathID) path01 }`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				auth := &b.FindBackendPath(h.FindPath("/app1")[0].Link).AuthExternal
+				auth.AuthBackendName = "_auth_4001"
+				auth.AuthPath = "/oauth2/auth"
+				auth.ForwardHeaders = true
+				auth.HeadersRequest = []string{".*"}
+				auth.HeadersSucceed = []string{".*"}
+				auth.HeadersFail = []string{".*"}
+				auth.Method = "GET"
+			},
+			path: []string{"/app1", "/app2"},
+			expected: `
+    # path01 = d1.local/app1
+    # path02 = d1.local/app2
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request set-header X-Real-IP %[src] if { var(txn.pathID) path01 }
+    http-request set-header X-Forwarded-Method %[method] if { var(txn.pathID) path01 }
+    http-request set-header X-Forwarded-Host %[req.hdr(host)] if { var(txn.pathID) path01 }
+    http-request set-header X-Forwarded-Uri %[pathq] if { var(txn.pathID) path01 }
+    http-request set-header X-Original-Method %[method] if { var(txn.pathID) path01 }
+    http-request set-header X-Original-URL %[req.hdr(x-forwarded-proto)]://%[req.hdr(host)]%[pathq] if { var(txn.pathID) path01 }
+    http-request lua.auth-intercept _auth_4001 /oauth2/auth GET '.*' '.*' '.*' if { var(txn.pathID) path01 }
+    http-request deny if !{ var(txn.auth_response_successful) -m bool } { var(txn.pathID) path01 }`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/")[0].Link).JWT = hatypes.JWT{
+					Algorithm:   "RS256",
+					KeyFilename: "/var/haproxy/ssl/jwt.pem",
+				}
+			},
+			expected: `
+    http-request set-var(txn.jwt_alg) http_auth_bearer,jwt_header_query('$.alg')
+    http-request set-var(txn.jwt_exp) http_auth_bearer,jwt_payload_query('$.exp','int')
+    http-request deny deny_status 401 if !{ var(txn.jwt_alg) -m str RS256 }
+    http-request deny deny_status 401 if !{ http_auth_bearer,jwt_verify(txn.jwt_alg,"/var/haproxy/ssl/jwt.pem") -m int 1 }
+    http-request deny deny_status 401 if !{ date,neg,add(txn.jwt_exp) -m int gt 0 }`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/app2")[0].Link).JWT = hatypes.JWT{
+					Algorithm: "ES256",
+					Audience:  "app",
+					ClaimHeaders: []hatypes.BackendHeader{
+						{Name: "X-User", Value: "sub"},
+					},
+					Issuer:      "https://auth.local/",
+					KeyFilename: "/var/haproxy/ssl/jwt.pem",
+				}
+			},
+			path: []string{"/app1", "/app2"},
+			expected: `
+    # path01 = d1.local/app1
+    # path02 = d1.local/app2
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request set-var(txn.jwt_alg) http_auth_bearer,jwt_header_query('$.alg') if { var(txn.pathID) path02 }
+    http-request set-var(txn.jwt_exp) http_auth_bearer,jwt_payload_query('$.exp','int') if { var(txn.pathID) path02 }
+    http-request deny deny_status 401 if { var(txn.pathID) path02 } !{ var(txn.jwt_alg) -m str ES256 }
+    http-request deny deny_status 401 if { var(txn.pathID) path02 } !{ http_auth_bearer,jwt_verify(txn.jwt_alg,"/var/haproxy/ssl/jwt.pem") -m int 1 }
+    http-request deny deny_status 401 if { var(txn.pathID) path02 } !{ date,neg,add(txn.jwt_exp) -m int gt 0 }
+    http-request deny deny_status 401 if { var(txn.pathID) path02 } !{ http_auth_bearer,jwt_payload_query('$.iss') -m str https://auth.local/ }
+    http-request deny deny_status 401 if { var(txn.pathID) path02 } !{ http_auth_bearer,jwt_payload_query('$.aud') -m str app } !{ http_auth_bearer,jwt_payload_query('$.aud[0]') -m str app } !{ http_auth_bearer,jwt_payload_query('$.aud[1]') -m str app } !{ http_auth_bearer,jwt_payload_query('$.aud[2]') -m str app } !{ http_auth_bearer,jwt_payload_query('$.aud[3]') -m str app } !{ http_auth_bearer,jwt_payload_query('$.aud[4]') -m str app }
+    http-request set-header X-User %[http_auth_bearer,jwt_payload_query('$.sub')] if { var(txn.pathID) path02 }`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/")[0].Link).JWT = hatypes.JWT{
+					Algorithm: "RS256",
+					JWKS: []hatypes.JWTKey{
+						{KID: "key1", Filename: "/var/haproxy/ssl/jwks_1.pem"},
+						{Filename: "/var/haproxy/ssl/jwks_2.pem"},
+					},
+					JWKSURL: "https://auth.local/jwks.json",
+				}
+			},
+			expected: `
+    http-request set-var(txn.jwt_alg) http_auth_bearer,jwt_header_query('$.alg')
+    http-request set-var(txn.jwt_exp) http_auth_bearer,jwt_payload_query('$.exp','int')
+    http-request deny deny_status 401 if !{ var(txn.jwt_alg) -m str RS256 }
+    http-request set-var(txn.jwt_kid) http_auth_bearer,jwt_header_query('$.kid')
+    http-request set-var(txn.jwt_valid) bool(1) if { var(txn.jwt_kid) -m str key1 } { http_auth_bearer,jwt_verify(txn.jwt_alg,"/var/haproxy/ssl/jwks_1.pem") -m int 1 }
+    http-request set-var(txn.jwt_valid) bool(1) if { http_auth_bearer,jwt_verify(txn.jwt_alg,"/var/haproxy/ssl/jwks_2.pem") -m int 1 }
+    http-request deny deny_status 401 if !{ var(txn.jwt_valid) -m bool }
+    http-request deny deny_status 401 if !{ date,neg,add(txn.jwt_exp) -m int gt 0 }`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/")[0].Link).JWT = hatypes.JWT{
+					Algorithm: "RS256",
+					JWKSURL:   "https://auth.local/jwks.json",
+				}
+			},
+			expected: `
+    http-request set-var(txn.jwt_alg) http_auth_bearer,jwt_header_query('$.alg')
+    http-request set-var(txn.jwt_exp) http_auth_bearer,jwt_payload_query('$.exp','int')
+    http-request deny deny_status 401 if !{ var(txn.jwt_alg) -m str RS256 }
+    http-request set-var(txn.jwt_kid) http_auth_bearer,jwt_header_query('$.kid')
+    http-request deny deny_status 401 if !{ var(txn.jwt_valid) -m bool }
+    http-request deny deny_status 401 if !{ date,neg,add(txn.jwt_exp) -m int gt 0 }`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/app1")[0].Link).HTTPHeaders = hatypes.HTTPHeaders{
+					RequestAdd:  []hatypes.BackendHeader{{Name: "X-Tenant", Value: "'app'"}},
+					RequestDel:  []string{"X-Debug"},
+					RequestSet:  []hatypes.BackendHeader{{Name: "X-Env", Value: "'prod'"}},
+					ResponseAdd: []hatypes.BackendHeader{{Name: "Link", Value: "'</style.css>; rel=preload'"}},
+					ResponseDel: []string{"Server"},
+					ResponseSet: []hatypes.BackendHeader{{Name: "Cache-Control", Value: "'no-store'"}},
+				}
+			},
+			path: []string{"/app1", "/app2"},
+			expected: `
+    # path01 = d1.local/app1
+    # path02 = d1.local/app2
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request del-header X-Debug if { var(txn.pathID) path01 }
+    http-request set-header X-Env 'prod' if { var(txn.pathID) path01 }
+    http-request add-header X-Tenant 'app' if { var(txn.pathID) path01 }
+    http-response del-header Server if { var(txn.pathID) path01 }
+    http-response set-header Cache-Control 'no-store' if { var(txn.pathID) path01 }
+    http-response add-header Link '</style.css>; rel=preload' if { var(txn.pathID) path01 }`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/app1")[0].Link).HTTPHeaders = hatypes.HTTPHeaders{
+					ResponseSet: []hatypes.BackendHeader{{Name: "Cache-Control", Value: "'no-store'"}},
+				}
+				b.FindBackendPath(h.FindPath("/app2")[0].Link).HTTPHeaders = hatypes.HTTPHeaders{
+					AfterResponseAdd: []hatypes.BackendHeader{{Name: "X-Served-By", Value: "'edge'"}},
+					AfterResponseDel: []string{"Server"},
+					AfterResponseSet: []hatypes.BackendHeader{{Name: "Cache-Control", Value: "'no-store'"}},
+				}
+			},
+			path: []string{"/app1", "/app2"},
+			expected: `
+    # path01 = d1.local/app1
+    # path02 = d1.local/app2
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-response set-header Cache-Control 'no-store' if { var(txn.pathID) path01 }
+    http-after-response del-header Server if { var(txn.pathID) path02 }
+    http-after-response set-header Cache-Control 'no-store' if { var(txn.pathID) path02 }
+    http-after-response add-header X-Served-By 'edge' if { var(txn.pathID) path02 }`,
 		},
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
@@ -677,6 +1021,64 @@ d1.local#/ path01`,
     option httpchk /check`,
 			srvsuffix: "check port 4000",
 		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.HealthCheck.URI = "/check"
+				b.HealthCheck.Interval = "2s"
+				b.HealthCheck.Expect = "status 200-299"
+				b.HealthCheck.Timeout = "1s"
+			},
+			expected: `
+    option httpchk /check
+    http-check expect status 200-299
+    timeout check 1s`,
+			srvsuffix: "check inter 2s",
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.HealthCheck.URI = "/check"
+				b.HealthCheck.Interval = "2s"
+				b.HealthCheck.Method = "HEAD"
+				b.HealthCheck.Host = "app.local"
+				b.HealthCheck.Headers = []*hatypes.BackendHeader{
+					{Name: "X-Check", Value: "true"},
+					{Name: "Authorization", Value: "Bearer 0123"},
+				}
+				b.HealthCheck.Expect = "! rstring ^fail"
+			},
+			expected: `
+    option httpchk
+    http-check send meth HEAD uri /check ver HTTP/1.1 hdr Host app.local hdr X-Check "true" hdr Authorization "Bearer 0123"
+    http-check expect ! rstring ^fail`,
+			srvsuffix: "check inter 2s",
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.HealthCheck.URI = "/check"
+				b.HealthCheck.Interval = "2s"
+				b.HealthCheck.Headers = []*hatypes.BackendHeader{
+					{Name: "X-Check", Value: "true"},
+				}
+			},
+			expected: `
+    option httpchk
+    http-check send meth GET uri /check hdr X-Check "true"`,
+			srvsuffix: "check inter 2s",
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.HealthCheck.Interval = "2s"
+				b.HealthCheck.TCPCheck = []*hatypes.TCPCheckRule{
+					{Action: "send", Value: `PING\r\n`},
+					{Action: "expect", Match: "string", Value: "+PONG"},
+				}
+			},
+			expected: `
+    option tcp-check
+    tcp-check send "PING\r\n"
+    tcp-check expect string +PONG`,
+			srvsuffix: "check inter 2s",
+		},
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
 				b.AgentCheck.Port = 8000
@@ -765,6 +1167,32 @@ d1.local#/ path01`,
 			},
 			srvsuffix: "proto h2 alpn h2 ssl verify none",
 		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Server.Protocol = "h2"
+				b.GRPCWeb = true
+				b.FindBackendPath(h.FindPath("/app")[0].Link).RewriteURL = "/"
+			},
+			path: []string{"/app"},
+			expected: `
+    http-request allow if { fe_name _front__grpc_web }
+    http-response allow if { fe_name _front__grpc_web }
+    http-after-response allow if { fe_name _front__grpc_web }
+    http-request replace-path ^/app/?(.*)$     /\1
+    http-request use-service lua.grpc-web if { req.hdr(content-type) -m beg application/grpc-web }`,
+			srvsuffix: "proto h2",
+			expFronts: `frontend _front__grpc_web
+    mode http
+    bind unix@/var/run/haproxy/grpc-web.sock mode 600
+    no log
+    http-request set-src hdr(x-haproxy-grpc-web-src)
+    http-request set-var(req.host) hdr(host),field(1,:),lower
+    http-request set-var(txn.grpc_web_backend) hdr(x-haproxy-grpc-web-backend)
+    http-request del-header x-haproxy-grpc-web-src
+    http-request del-header x-haproxy-grpc-web-backend
+    use_backend %[var(txn.grpc_web_backend)]
+<<frontends-default>>`,
+		},
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
 				b.Server.Protocol = "h2"
@@ -776,6 +1204,7 @@ d1.local#/ path01`,
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
 				b.Limit.Connections = 200
+				b.Limit.DenyStatus = 429
 				b.Limit.RPS = 20
 				b.Limit.Whitelist = []string{"192.168.0.0/16", "10.1.1.101"}
 			},
@@ -788,6 +1217,7 @@ d1.local#/ path01`,
 		},
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Limit.DenyStatus = 429
 				b.Limit.RPS = 20
 			},
 			expected: `
@@ -798,11 +1228,26 @@ d1.local#/ path01`,
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
 				b.Limit.Connections = 200
+				b.Limit.DenyStatus = 429
 			},
 			expected: `
     stick-table type ip size 200k expire 5m store conn_cur,conn_rate(1s)
     http-request track-sc1 src
     http-request deny deny_status 429 if { sc1_conn_cur gt 200 }`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Limit.DenyStatus = 503
+				b.Limit.Header = "X-Api-Key"
+				b.Limit.ReqRPS = 50
+				b.Limit.Whitelist = []string{"10.0.0.0/8"}
+			},
+			expected: `
+    stick-table type string len 64 size 200k expire 5m store conn_cur,conn_rate(1s),http_req_rate(1s)
+    http-request track-sc1 req.hdr(X-Api-Key) if { req.hdr(X-Api-Key) -m found }
+    http-request track-sc1 src if !{ req.hdr(X-Api-Key) -m found }
+    acl wlist_conn src 10.0.0.0/8
+    http-request deny deny_status 503 if !wlist_conn { sc1_http_req_rate gt 50 }`,
 		},
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
@@ -824,6 +1269,17 @@ d1.local#/ path01`,
 			},
 			srvsuffix: "send-proxy-v2",
 		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Server.SendProxy = "send-proxy-v2"
+				b.Server.ProxyV2.Options = []string{"ssl", "unique-id"}
+				b.Server.ProxyV2.TLVs = []*hatypes.ProxyV2TLV{
+					{ID: 0xE0, Format: "d1"},
+					{ID: 0xE1, Format: "%[req.hdr(host)]"},
+				}
+			},
+			srvsuffix: "send-proxy-v2 proxy-v2-options ssl,unique-id set-proxy-v2-tlv-fmt(0xE0) d1 set-proxy-v2-tlv-fmt(0xE1) %[req.hdr(host)]",
+		},
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
 				b.BlueGreen.CookieName = "ServerName"
@@ -871,20 +1327,89 @@ d1.local#/ path01`,
     server s32 172.17.0.132:8080 weight 100
     server s33 172.17.0.133:8080 weight 100`,
 		},
-		// simulates a config where the cookie value is a pod id
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
-				b.Cookie.Name = "serverId"
-				b.Cookie.Strategy = "insert"
-				b.Cookie.Keywords = "nocache"
-				b.EpCookieStrategy = hatypes.EpCookiePodUID
-				ep1 := *endpointS1
-				b.Endpoints = []*hatypes.Endpoint{&ep1}
-				b.Endpoints[0].CookieValue = "9d344d6c-6069-4aee-85e6-9348e70c71e6"
+				b.BlueGreen.Canary.HeaderName = "X-Canary"
+				b.BlueGreen.Canary.HeaderValue = "always"
+				e1, e2, e3 := *endpointS31, *endpointS32, *endpointS33
+				b.Endpoints = []*hatypes.Endpoint{&e1, &e2, &e3}
+				b.Endpoints[2].Canary = true
+				b.Endpoints[2].Weight = 0
 			},
-			srvsuffix: "cookie 9d344d6c-6069-4aee-85e6-9348e70c71e6",
+			skipSrv: true,
 			expected: `
-    cookie serverId insert nocache`,
+    acl bluegreen-canary req.hdr(X-Canary) -m str always
+    use-server s33 if bluegreen-canary
+    server s31 172.17.0.131:8080 weight 100
+    server s32 172.17.0.132:8080 weight 100
+    server s33 172.17.0.133:8080 weight 0`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.ABTest.Buckets = 10000
+				b.ABTest.Fetch = "req.cook(uid)"
+				b.BlueGreen.HeaderName = "X-Svc"
+				e1, e2, e3 := *endpointS31, *endpointS32, *endpointS33
+				b.Endpoints = []*hatypes.Endpoint{&e1, &e2, &e3}
+				b.Endpoints[0].ABTestRange = "0:4499"
+				b.Endpoints[0].Label = "blue"
+				b.Endpoints[1].ABTestRange = "4500:8999"
+				b.Endpoints[1].Label = "blue"
+				b.Endpoints[2].ABTestRange = "9000:9999"
+				b.Endpoints[2].Label = "green"
+			},
+			skipSrv: true,
+			expected: `
+    http-request set-var(txn.abtest) req.cook(uid),xxh32,mod(10000)
+    use-server s31 if { req.hdr(X-Svc) blue }
+    use-server s32 if { req.hdr(X-Svc) blue }
+    use-server s33 if { req.hdr(X-Svc) green }
+    use-server s31 if { var(txn.abtest) -m int 0:4499 }
+    use-server s32 if { var(txn.abtest) -m int 4500:8999 }
+    use-server s33 if { var(txn.abtest) -m int 9000:9999 }
+    server s31 172.17.0.131:8080 weight 100
+    server s32 172.17.0.132:8080 weight 100
+    server s33 172.17.0.133:8080 weight 100`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.BlueGreen.Canary.CookieName = "canary"
+				b.BlueGreen.Canary.CookieValue = "^(qa|dev)-.*"
+				b.BlueGreen.Canary.HeaderName = "X-Canary"
+				b.BlueGreen.Canary.HeaderValue = "^(qa|dev)$"
+				b.BlueGreen.Canary.Regex = true
+				b.BlueGreen.HeaderName = "X-Svc"
+				e1, e2, e3 := *endpointS31, *endpointS32, *endpointS33
+				b.Endpoints = []*hatypes.Endpoint{&e1, &e2, &e3}
+				b.Endpoints[0].Label = "blue"
+				b.Endpoints[1].Canary = true
+				b.Endpoints[2].Canary = true
+			},
+			skipSrv: true,
+			expected: `
+    acl bluegreen-canary req.hdr(X-Canary) -m reg ^(qa|dev)$
+    acl bluegreen-canary req.cook(canary) -m reg ^(qa|dev)-.*
+    use-server s32 if bluegreen-canary
+    use-server s33 if bluegreen-canary
+    use-server s31 if { req.hdr(X-Svc) blue }
+    server s31 172.17.0.131:8080 weight 100
+    server s32 172.17.0.132:8080 weight 100
+    server s33 172.17.0.133:8080 weight 100`,
+		},
+		// simulates a config where the cookie value is a pod id
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Cookie.Name = "serverId"
+				b.Cookie.Strategy = "insert"
+				b.Cookie.Keywords = "nocache"
+				b.EpCookieStrategy = hatypes.EpCookiePodUID
+				ep1 := *endpointS1
+				b.Endpoints = []*hatypes.Endpoint{&ep1}
+				b.Endpoints[0].CookieValue = "9d344d6c-6069-4aee-85e6-9348e70c71e6"
+			},
+			srvsuffix: "cookie 9d344d6c-6069-4aee-85e6-9348e70c71e6",
+			expected: `
+    cookie serverId insert nocache`,
 		},
 		// simulates a config where the cookie "preserve" option is used
 		{
@@ -901,6 +1426,15 @@ d1.local#/ path01`,
 			expected: `
     cookie serverId insert preserve nocache`,
 		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.AllBackups = true
+				b.Endpoints[0].Backup = true
+			},
+			srvsuffix: "backup",
+			expected: `
+    option allbackups`,
+		},
 	}
 	for _, test := range testCases {
 		c := setup(t)
@@ -970,6 +1504,75 @@ backend d1_app_8080
  *
  * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
 
+func TestReadEjections(t *testing.T) {
+	const header = "# pxname,svname,qcur,status,chkfail,chkdown,hanafail"
+	testCases := []struct {
+		stat     string
+		expected map[string]int
+		expErr   string
+	}{
+		// 0
+		{
+			stat:     header,
+			expected: map[string]int{"default_app_8080": 0},
+		},
+		// 1
+		{
+			stat: header + `
+default_app_8080,srv001,0,UP,0,2,5
+default_app_8080,srv002,0,DOWN,1,3,7
+default_other_8080,srv001,0,UP,0,4,0
+default_app_8080,srv003`,
+			expected: map[string]int{"default_app_8080": 5},
+		},
+		// 2
+		{
+			stat:   "# pxname,svname,qcur,status",
+			expErr: "missing pxname or chkdown fields",
+		},
+	}
+	for i, test := range testCases {
+		actual, err := readEjections(test.stat, map[string]bool{"default_app_8080": true})
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != test.expErr {
+			t.Errorf("error differs on %d - expected: %s, actual: %s", i, test.expErr, errMsg)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("ejections differ on %d - expected: %v, actual: %v", i, test.expected, actual)
+		}
+	}
+}
+
+func TestReadBlockedUserAgents(t *testing.T) {
+	testCases := []struct {
+		table    string
+		expected map[string]int
+	}{
+		// 0
+		{
+			table:    "# table: _blocked_user_agents, type: string, size:1024, used:0\n",
+			expected: map[string]int{},
+		},
+		// 1
+		{
+			table: `# table: _blocked_user_agents, type: string, size:1024, used:2
+0x55d1c3a0b2f0: key=curl use=0 exp=0 http_req_cnt=3
+0x55d1c3a0b3a0: key=AhrefsBot use=1 exp=0 http_req_cnt=12
+`,
+			expected: map[string]int{"curl": 3, "AhrefsBot": 12},
+		},
+	}
+	for i, test := range testCases {
+		actual := readBlockedUserAgents(test.table)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("blocked user agents differ on %d - expected: %v, actual: %v", i, test.expected, actual)
+		}
+	}
+}
+
 func TestInstanceClean(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -1014,6 +1617,45 @@ backend d1_app_8080
 	c.logger.CompareLogging(defaultLogging)
 }
 
+func TestInstanceDryRun(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	files, err := c.instance.DryRun()
+	if err != nil {
+		t.Errorf("unexpected error on dry run: %v", err)
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	c.compareText("files", strings.Join(names, "\n"), `
+_front_bind_crt.list
+_front_http_host__begin.map
+_front_https_host__begin.map
+haproxy.cfg`)
+	c.checkMap("_front_http_host__begin.map", `
+d1.local#/ d1_app_8080
+`)
+	if !strings.Contains(files["haproxy.cfg"], "backend d1_app_8080") {
+		t.Errorf("missing backend d1_app_8080 on haproxy.cfg: %s", files["haproxy.cfg"])
+	}
+	if c.instance.LastReload() != nil {
+		t.Errorf("dry run should not reload haproxy")
+	}
+	c.logger.CompareLogging(`
+INFO (test) check was skipped`)
+}
+
 func TestInstanceBareHTTP(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -1135,6 +1777,9 @@ global
     hard-stop-after 15m
     lua-prepend-path /etc/haproxy/lua/?.lua
     lua-load /etc/haproxy/lua/auth-request.lua
+    lua-load /etc/haproxy/lua/oidc.lua
+    lua-load /etc/haproxy/lua/mirror.lua
+    lua-load /etc/haproxy/lua/grpc-web.lua
     lua-load /etc/haproxy/lua/services.lua
     ssl-dh-param-file /var/haproxy/tls/dhparam.pem
     ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
@@ -1193,6 +1838,9 @@ global
     hard-stop-after 15m
     lua-prepend-path /etc/haproxy/lua/?.lua
     lua-load /etc/haproxy/lua/auth-request.lua
+    lua-load /etc/haproxy/lua/oidc.lua
+    lua-load /etc/haproxy/lua/mirror.lua
+    lua-load /etc/haproxy/lua/grpc-web.lua
     lua-load /etc/haproxy/lua/services.lua
     ssl-dh-param-file /var/haproxy/tls/dhparam.pem
     ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
@@ -1238,6 +1886,7 @@ func TestInstanceEmptyExternal(t *testing.T) {
 
 	c.config.global.External.MasterSocket = "/tmp/master.sock"
 	c.config.global.Master.WorkerMaxReloads = 20
+	c.config.global.Timeout.Grace = "10s"
 	c.config.global.Security.Username = "external"
 	c.config.global.Security.Groupname = "external"
 
@@ -1253,6 +1902,7 @@ global
     stats socket /var/run/haproxy.sock level admin expose-fd listeners mode 600
     maxconn 2000
     hard-stop-after 15m
+    grace 10s
     mworker-max-reloads 20
     lua-load /etc/haproxy/lua/services.lua
     ssl-dh-param-file /var/haproxy/tls/dhparam.pem
@@ -1385,6 +2035,9 @@ global
     hard-stop-after 15m
     lua-prepend-path /etc/haproxy/lua/?.lua
     lua-load /etc/haproxy/lua/auth-request.lua
+    lua-load /etc/haproxy/lua/oidc.lua
+    lua-load /etc/haproxy/lua/mirror.lua
+    lua-load /etc/haproxy/lua/grpc-web.lua
     lua-load /etc/haproxy/lua/services.lua
     ssl-dh-param-file /var/haproxy/tls/dhparam.pem
     ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
@@ -1404,92 +2057,548 @@ backend _error404
 	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceMatch(t *testing.T) {
+func TestInstanceHandoffSocket(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
-	b := c.config.Backends().AcquireBackend("default", "d1", "8080")
-	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-	h := c.config.Hosts().AcquireHost("d1.local")
-	h.AddPath(b, "/app", hatypes.MatchPrefix)
-	h.AddPath(b, "/api/v[0-9]+/", hatypes.MatchRegex)
+	c.config.global.HandoffSocket = "/var/run/haproxy-handoff/haproxy.sock"
+
+	c.config.Hosts().AcquireHost("empty").AddPath(c.config.Backends().AcquireBackend("default", "empty", "8080"), "/", hatypes.MatchBegin)
 	c.Update()
 
+	c.checkConfig(`
+global
+    daemon
+    unix-bind mode 0600
+    stats socket /var/run/haproxy.sock level admin expose-fd listeners mode 600
+    stats socket /var/run/haproxy-handoff/haproxy.sock level admin expose-fd listeners mode 600
+    maxconn 2000
+    hard-stop-after 15m
+    lua-prepend-path /etc/haproxy/lua/?.lua
+    lua-load /etc/haproxy/lua/auth-request.lua
+    lua-load /etc/haproxy/lua/oidc.lua
+    lua-load /etc/haproxy/lua/mirror.lua
+    lua-load /etc/haproxy/lua/grpc-web.lua
+    lua-load /etc/haproxy/lua/services.lua
+    ssl-dh-param-file /var/haproxy/tls/dhparam.pem
+    ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-bind-ciphersuites TLS_AES_128_GCM_SHA256
+    ssl-default-bind-options no-sslv3
+    ssl-default-server-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-server-ciphersuites TLS_AES_128_GCM_SHA256
+<<defaults>>
+backend default_empty_8080
+    mode http
+backend _error404
+    mode http
+    http-request use-service lua.send-404
+<<frontends-default>>
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceErrorPages(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b.ErrorPages = []*hatypes.ErrorPage{
+		{Code: 404, Content: "<html>not found</html>"},
+		{Code: 503, Content: "<html>unavailable</html>"},
+	}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	c.Update()
 	c.checkConfig(`
 <<global>>
 <<defaults>>
-backend default_d1_8080
+backend d1_app_8080
     mode http
+    errorfile 404 /etc/haproxy/maps/_back_d1_app_8080_error404.http
+    errorfile 503 /etc/haproxy/maps/_back_d1_app_8080_error503.http
     server s1 172.17.0.11:8080 weight 100
 <<backends-default>>
-frontend _front_http
-    mode http
-    bind :80
-    <<set-req-base>>
-    <<http-headers>>
-    http-request set-var(req.backend) var(req.base),map_dir(/etc/haproxy/maps/_front_http_host__prefix.map)
-    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map) if !{ var(req.backend) -m found }
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }
-    default_backend _error404
-frontend _front_https
-    mode http
-    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
-    <<set-req-base>>
-    http-request set-var(req.hostbackend) var(req.base),map_dir(/etc/haproxy/maps/_front_https_host__prefix.map)
-    http-request set-var(req.hostbackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_host__regex.map) if !{ var(req.hostbackend) -m found }
-    <<https-headers>>
-    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
-    default_backend _error404
+<<frontends-default>>
 <<support>>
 `)
+	content, err := ioutil.ReadFile(c.tempdir + "/_back_d1_app_8080_error503.http")
+	if err != nil {
+		t.Errorf("error reading error page: %v", err)
+	}
+	expected := "HTTP/1.0 503 Service Unavailable\r\n" +
+		"Cache-Control: no-cache\r\n" +
+		"Connection: close\r\n" +
+		"Content-Type: text/html\r\n" +
+		"\r\n<html>unavailable</html>"
+	c.compareText("error page", string(content), expected)
+	c.logger.CompareLogging(defaultLogging)
+}
 
-	c.checkMap("_front_http_host__prefix.map", `
-d1.local#/app default_d1_8080`)
-	c.checkMap("_front_http_host__regex.map", `
-^d1\.local#/api/v[0-9]+/ default_d1_8080`)
-	c.checkMap("_front_https_host__prefix.map", `
-d1.local#/app default_d1_8080`)
-	c.checkMap("_front_https_host__regex.map", `
-^d1\.local#/api/v[0-9]+/ default_d1_8080`)
+func TestInstanceSourceList(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/app", hatypes.MatchBegin)
+	h.AddPath(b, "/api", hatypes.MatchBegin)
+	b.AddSourceList("d1/allow", []string{"10.0.0.0/8", "192.168.0.0/16"})
+	b.AddSourceList("d1/deny", []string{"172.16.0.0/12"})
+	allow := b.FindBackendPath(h.FindPath("/app")[0].Link)
+	allow.AllowedIPHTTP.Rule = []string{"127.0.0.1"}
+	allow.AllowedIPHTTP.SourceList = "d1/allow"
+	deny := b.FindBackendPath(h.FindPath("/api")[0].Link)
+	deny.DeniedIPHTTP.Exception = []string{"172.17.0.0/16"}
+	deny.DeniedIPHTTP.SourceList = "d1/deny"
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+	b.ModeTCP = true
+	b.AddSourceList("d2/allow", nil)
+	b.AllowedIPTCP.SourceList = "d2/allow"
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
 
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    # path02 = d1.local/api
+    # path01 = d1.local/app
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    acl allow_rule_src1 src 127.0.0.1
+    acl allow_list_src1 src -f /etc/haproxy/maps/_back_d1_app_8080_src01.list
+    http-request deny if { var(txn.pathID) path01 } !allow_rule_src1 !allow_list_src1
+    acl deny_exception_src0 src 172.17.0.0/16
+    acl deny_list_src0 src -f /etc/haproxy/maps/_back_d1_app_8080_src02.list
+    http-request deny if { var(txn.pathID) path02 } deny_list_src0 !deny_exception_src0
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode tcp
+    acl allow_list_tcp src -f /etc/haproxy/maps/_back_d2_app_8080_src01.list
+    tcp-request content reject if !allow_list_tcp
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+	content, err := ioutil.ReadFile(c.tempdir + "/_back_d1_app_8080_src01.list")
+	if err != nil {
+		t.Errorf("error reading source list: %v", err)
+	}
+	c.compareText("source list", string(content), "10.0.0.0/8\n192.168.0.0/16\n")
 	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceFrontingProxy(t *testing.T) {
-	var (
-		frontUseProto = `
-    <<set-req-base>>
-    http-request set-header X-Forwarded-Proto http if !fronting-proxy
-    http-request del-header X-SSL-Client-CN if !fronting-proxy
-    http-request del-header X-SSL-Client-DN if !fronting-proxy
-    http-request del-header X-SSL-Client-SHA1 if !fronting-proxy
-    http-request del-header X-SSL-Client-Cert if !fronting-proxy
-    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }`
-		aclFrontExact = `
-    acl tls-has-crt ssl_c_used
-    acl tls-need-crt ssl_fc_sni -i -m str -f /etc/haproxy/maps/_front_tls_needcrt__exact.list
-    acl tls-host-need-crt var(req.host) -i -m str -f /etc/haproxy/maps/_front_tls_needcrt__exact.list
-    acl tls-has-invalid-crt ssl_c_verify gt 0
-    acl tls-check-crt ssl_fc_sni -i -m str -f /etc/haproxy/maps/_front_tls_auth__exact.list`
-		aclFrontRegex = `
-    acl tls-has-crt ssl_c_used
-    acl tls-need-crt ssl_fc_sni -i -m reg -f /etc/haproxy/maps/_front_tls_needcrt__regex.list
-    acl tls-host-need-crt var(req.host) -i -m reg -f /etc/haproxy/maps/_front_tls_needcrt__regex.list
-    acl tls-has-invalid-crt ssl_c_verify gt 0
-    acl tls-check-crt ssl_fc_sni -i -m reg -f /etc/haproxy/maps/_front_tls_auth__regex.list`
-		aclBackWithSockID = `
-    acl fronting-proxy so_id 11
-    acl https-request ssl_fc
-    acl https-request var(txn.proto) https`
-		aclBackWithHdr = `
-    acl fronting-proxy hdr(X-Forwarded-Proto) -m found
-    acl https-request ssl_fc
-    acl https-request var(txn.proto) https`
-		setHeaderWithACL = `
-    http-request set-header X-SSL-Client-CN   %{+Q}[ssl_c_s_dn(cn)]   if local-offload
-    http-request set-header X-SSL-Client-DN   %{+Q}[ssl_c_s_dn]       if local-offload
-    http-request set-header X-SSL-Client-SHA1 %{+Q}[ssl_c_sha1,hex]   if local-offload
+func TestInstanceGeoIP(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	database := c.tempdir + "/IP2LOCATION-LITE-DB1.CSV"
+	err := ioutil.WriteFile(database, []byte(`"16777216","16777471","US","United States of America"`), 0644)
+	if err != nil {
+		t.Fatalf("error writing database: %v", err)
+	}
+	geoip := &c.config.Global().GeoIP
+	geoip.Database = database
+	geoip.Format = "ip2location"
+	geoip.CountryHeader = "X-Country-Code"
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/app", hatypes.MatchBegin)
+	h.AddPath(b, "/api", hatypes.MatchBegin)
+	allow := b.FindBackendPath(h.FindPath("/app")[0].Link)
+	allow.AllowedIPHTTP.Rule = []string{"10.0.0.0/8"}
+	allow.AllowedIPHTTP.Countries = []string{"BR", "US"}
+	deny := b.FindBackendPath(h.FindPath("/api")[0].Link)
+	deny.DeniedIPHTTP.Exception = []string{"172.17.0.0/16"}
+	deny.DeniedIPHTTP.Countries = []string{"CN"}
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+	b.ModeTCP = true
+	b.DeniedIPTCP.Countries = []string{"CN"}
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    # path02 = d1.local/api
+    # path01 = d1.local/app
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    acl allow_rule_src1 src 10.0.0.0/8
+    acl allow_country_src1 src,map_ip(/etc/haproxy/maps/_geoip_country.map) -m str BR US
+    http-request deny if { var(txn.pathID) path01 } !allow_rule_src1 !allow_country_src1
+    acl deny_exception_src0 src 172.17.0.0/16
+    acl deny_country_src0 src,map_ip(/etc/haproxy/maps/_geoip_country.map) -m str CN
+    http-request deny if { var(txn.pathID) path02 } deny_country_src0 !deny_exception_src0
+    http-request del-header X-Country-Code
+    http-request set-header X-Country-Code %[src,map_ip(/etc/haproxy/maps/_geoip_country.map)] if { src,map_ip(/etc/haproxy/maps/_geoip_country.map) -m found }
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode tcp
+    acl deny_country_tcp src,map_ip(/etc/haproxy/maps/_geoip_country.map) -m str CN
+    tcp-request content reject if deny_country_tcp
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+	content, err := ioutil.ReadFile(c.tempdir + "/_geoip_country.map")
+	if err != nil {
+		t.Errorf("error reading geoip map: %v", err)
+	}
+	c.compareText("geoip map", string(content), "1.0.0.0/24 US\n")
+	c.logger.CompareLogging(`
+INFO GeoIP database converted to a map with 1 networks` + defaultLogging)
+}
+
+func TestInstanceCache(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	cache1 := hatypes.Cache{MaxAge: 60, TotalMaxSize: 64}
+	cache2 := hatypes.Cache{MaxAge: 300, MaxObjectSize: 1048576, StatusHeader: "X-Cache-Status", TotalMaxSize: 128}
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.AddPath(b, "/static", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/static")[0].Link).Cache = cache2
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+	b.Compression.Algos = []string{"gzip"}
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/")[0].Link).Cache = cache1
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+cache cache_128m_1048576_300s
+    total-max-size 128
+    max-object-size 1048576
+    max-age 300
+    process-vary on
+cache cache_64m_0_60s
+    total-max-size 64
+    max-age 60
+    process-vary on
+backend d1_app_8080
+    mode http
+    # path01 = d1.local/
+    # path02 = d1.local/static
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    filter cache cache_128m_1048576_300s
+    http-request cache-use cache_128m_1048576_300s if { var(txn.pathID) path02 }
+    http-response cache-store cache_128m_1048576_300s if { var(txn.pathID) path02 }
+    http-response set-header X-Cache-Status %[res.cache_hit,iif(HIT,MISS)] if { var(txn.pathID) path02 }
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    filter cache cache_64m_0_60s
+    filter compression
+    compression algo gzip
+    http-request cache-use cache_64m_0_60s
+    http-response cache-store cache_64m_0_60s
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceRateLimit(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	limitAPI := hatypes.RateLimit{Name: "api", Requests: 10, Period: "1s", Expire: "60s", DenyStatus: 429}
+	limitLogin := hatypes.RateLimit{
+		Name:       "login",
+		Header:     "X-User",
+		Requests:   10,
+		Period:     "120s",
+		Expire:     "120s",
+		DenyStatus: 403,
+		DenyHeaders: []hatypes.BackendHeader{
+			{Name: "Retry-After", Value: "'60'"},
+		},
+	}
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.AddPath(b, "/api", hatypes.MatchBegin)
+	h.AddPath(b, "/login", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/api")[0].Link).RateLimit = limitAPI
+	b.FindBackendPath(h.FindPath("/login")[0].Link).RateLimit = limitLogin
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/")[0].Link).RateLimit = limitAPI
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend _ratelimit_api
+    stick-table type ip size 200k expire 60s store http_req_rate(1s)
+backend _ratelimit_login
+    stick-table type string len 64 size 200k expire 120s store http_req_rate(120s)
+backend d1_app_8080
+    mode http
+    # path01 = d1.local/
+    # path02 = d1.local/api
+    # path03 = d1.local/login
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request track-sc0 src table _ratelimit_api if { var(txn.pathID) path02 }
+    http-request deny deny_status 429 if { sc0_http_req_rate gt 10 } { var(txn.pathID) path02 }
+    http-request track-sc0 req.hdr(X-User) table _ratelimit_login if { req.hdr(X-User) -m found } { var(txn.pathID) path03 }
+    http-request track-sc0 src table _ratelimit_login if !{ req.hdr(X-User) -m found } { var(txn.pathID) path03 }
+    http-request deny deny_status 403 content-type text/plain string "Too many requests" hdr Retry-After '60' if { sc0_http_req_rate gt 10 } { var(txn.pathID) path03 }
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    http-request track-sc0 src table _ratelimit_api
+    http-request deny deny_status 429 if { sc0_http_req_rate gt 10 }
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceLua(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	c.config.Global().LuaScripts = []*hatypes.LuaScript{
+		{Name: "10-auth.lua", Content: "-- auth", Actions: []string{"check_token"}},
+		{Name: "20-headers.lua", Content: "-- headers", Fetches: []string{"client_tier"}},
+	}
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.AddPath(b, "/api", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/api")[0].Link).Lua = hatypes.PathLua{
+		Actions: []hatypes.LuaAction{
+			{Phase: "http-request", Name: "check_token", Args: []string{"Authorization"}},
+		},
+		Headers: []hatypes.LuaHeader{
+			{Name: "X-Tier", Fetch: "client_tier"},
+			{Name: "X-Tier-Level", Fetch: "client_tier", Args: []string{"level", "1"}},
+		},
+	}
+
+	c.Update()
+	c.checkConfig(`
+global
+    daemon
+    unix-bind mode 0600
+    stats socket /var/run/haproxy.sock level admin expose-fd listeners mode 600
+    maxconn 2000
+    hard-stop-after 15m
+    lua-prepend-path /etc/haproxy/lua/?.lua
+    lua-load /etc/haproxy/lua/auth-request.lua
+    lua-load /etc/haproxy/lua/oidc.lua
+    lua-load /etc/haproxy/lua/mirror.lua
+    lua-load /etc/haproxy/lua/grpc-web.lua
+    lua-load /etc/haproxy/lua/services.lua
+    lua-load /etc/haproxy/maps/_lua_10-auth.lua
+    lua-load /etc/haproxy/maps/_lua_20-headers.lua
+    ssl-dh-param-file /var/haproxy/tls/dhparam.pem
+    ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-bind-ciphersuites TLS_AES_128_GCM_SHA256
+    ssl-default-bind-options no-sslv3
+    ssl-default-server-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-server-ciphersuites TLS_AES_128_GCM_SHA256
+<<defaults>>
+backend d1_app_8080
+    mode http
+    # path01 = d1.local/
+    # path02 = d1.local/api
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request set-header X-Tier %[lua.client_tier] if { var(txn.pathID) path02 }
+    http-request set-header X-Tier-Level %[lua.client_tier(level,1)] if { var(txn.pathID) path02 }
+    http-request lua.check_token Authorization if { var(txn.pathID) path02 }
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+	content, err := ioutil.ReadFile(c.tempdir + "/_lua_10-auth.lua")
+	if err != nil {
+		t.Errorf("error reading lua script: %v", err)
+	}
+	c.compareText("lua script", string(content), "-- auth")
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceFastCGI(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "9000")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b.Server.Protocol = "fcgi"
+	b.FastCGI = hatypes.FastCGIApp{
+		Docroot: "/var/www/html",
+		Index:   "index.php",
+		Params: []*hatypes.FastCGIParam{
+			{Name: "REDIRECT_STATUS", Value: "200"},
+		},
+	}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+fcgi-app d1_app_9000
+    docroot /var/www/html
+    index index.php
+    set-param REDIRECT_STATUS 200
+backend d1_app_9000
+    mode http
+    use-fcgi-app d1_app_9000
+    server s1 172.17.0.11:8080 weight 100 proto fcgi
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceMatch(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	b := c.config.Backends().AcquireBackend("default", "d1", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h := c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/app", hatypes.MatchPrefix)
+	h.AddPath(b, "/api/v[0-9]+/", hatypes.MatchRegex)
+	c.Update()
+
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend default_d1_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),map_dir(/etc/haproxy/maps/_front_http_host__prefix.map)
+    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map) if !{ var(req.backend) -m found }
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),map_dir(/etc/haproxy/maps/_front_https_host__prefix.map)
+    http-request set-var(req.hostbackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_host__regex.map) if !{ var(req.hostbackend) -m found }
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+
+	c.checkMap("_front_http_host__prefix.map", `
+d1.local#/app default_d1_8080`)
+	c.checkMap("_front_http_host__regex.map", `
+^d1\.local#/api/v[0-9]+/ default_d1_8080`)
+	c.checkMap("_front_https_host__prefix.map", `
+d1.local#/app default_d1_8080`)
+	c.checkMap("_front_https_host__regex.map", `
+^d1\.local#/api/v[0-9]+/ default_d1_8080`)
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceFrontingProxy(t *testing.T) {
+	var (
+		frontUseProto = `
+    <<set-req-base>>
+    http-request set-header X-Forwarded-Proto http if !fronting-proxy
+    http-request del-header X-SSL-Client-CN if !fronting-proxy
+    http-request del-header X-SSL-Client-DN if !fronting-proxy
+    http-request del-header X-SSL-Client-SHA1 if !fronting-proxy
+    http-request del-header X-SSL-Client-Cert if !fronting-proxy
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }`
+		aclFrontExact = `
+    acl tls-has-crt ssl_c_used
+    acl tls-need-crt ssl_fc_sni -i -m str -f /etc/haproxy/maps/_front_tls_needcrt__exact.list
+    acl tls-host-need-crt var(req.host) -i -m str -f /etc/haproxy/maps/_front_tls_needcrt__exact.list
+    acl tls-has-invalid-crt ssl_c_verify gt 0
+    acl tls-check-crt ssl_fc_sni -i -m str -f /etc/haproxy/maps/_front_tls_auth__exact.list`
+		aclFrontRegex = `
+    acl tls-has-crt ssl_c_used
+    acl tls-need-crt ssl_fc_sni -i -m reg -f /etc/haproxy/maps/_front_tls_needcrt__regex.list
+    acl tls-host-need-crt var(req.host) -i -m reg -f /etc/haproxy/maps/_front_tls_needcrt__regex.list
+    acl tls-has-invalid-crt ssl_c_verify gt 0
+    acl tls-check-crt ssl_fc_sni -i -m reg -f /etc/haproxy/maps/_front_tls_auth__regex.list`
+		aclBackWithSockID = `
+    acl fronting-proxy so_id 11
+    acl https-request ssl_fc
+    acl https-request var(txn.proto) https`
+		aclBackWithHdr = `
+    acl fronting-proxy hdr(X-Forwarded-Proto) -m found
+    acl https-request ssl_fc
+    acl https-request var(txn.proto) https`
+		setHeaderWithACL = `
+    http-request set-header X-SSL-Client-CN   %{+Q}[ssl_c_s_dn(cn)]   if local-offload
+    http-request set-header X-SSL-Client-DN   %{+Q}[ssl_c_s_dn]       if local-offload
+    http-request set-header X-SSL-Client-SHA1 %{+Q}[ssl_c_sha1,hex]   if local-offload
     http-response set-header Strict-Transport-Security "max-age=15768000; includeSubDomains; preload" if https-request`
 		setHeaderSSLWithACL = `
     http-request set-header X-SSL-Client-CN   %{+Q}[ssl_c_s_dn(cn)]   if local-offload
@@ -1499,295 +2608,1640 @@ func TestInstanceFrontingProxy(t *testing.T) {
 		setHeaderNoACL = `
     http-request set-header X-SSL-Client-CN   %{+Q}[ssl_c_s_dn(cn)]
     http-request set-header X-SSL-Client-DN   %{+Q}[ssl_c_s_dn]
-    http-request set-header X-SSL-Client-SHA1 %{+Q}[ssl_c_sha1,hex]
-    http-response set-header Strict-Transport-Security "max-age=15768000; includeSubDomains; preload"`
-		setvarBegin = `
+    http-request set-header X-SSL-Client-SHA1 %{+Q}[ssl_c_sha1,hex]
+    http-response set-header Strict-Transport-Security "max-age=15768000; includeSubDomains; preload"`
+		setvarBegin = `
+    http-request set-var(req.snibase) ssl_fc_sni,lower,concat(\#,req.path)
+    http-request set-var(req.snibackend) var(req.snibase),lower,map_beg(/etc/haproxy/maps/_front_https_sni__begin.map)
+    http-request set-var(req.snibackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_sni__begin.map) if !{ var(req.snibackend) -m found } !tls-has-crt !tls-host-need-crt
+    http-request set-var(req.tls_nocrt_redir) str(_internal) if !tls-has-crt tls-need-crt
+    http-request set-var(req.tls_invalidcrt_redir) str(_internal) if tls-has-invalid-crt tls-check-crt`
+		setvarRegex = `
+    http-request set-var(req.snibase) ssl_fc_sni,lower,concat(\#,req.path)
+    http-request set-var(req.snibackend) var(req.snibase),map_reg(/etc/haproxy/maps/_front_https_sni__regex.map)
+    http-request set-var(req.snibackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_sni__regex.map) if !{ var(req.snibackend) -m found } !tls-has-crt !tls-host-need-crt
+    http-request set-var(req.tls_nocrt_redir) str(_internal) if !tls-has-crt tls-need-crt
+    http-request set-var(req.tls_invalidcrt_redir) str(_internal) if tls-has-invalid-crt tls-check-crt`
+	)
+	testCases := []struct {
+		frontingBind      string
+		domain            string
+		useProto          bool
+		sslRedirect       bool
+		expectedACLBack   string
+		expectedSetHeader string
+		expectedFront     string
+		expectedMap       string
+		expectedRegexMap  string
+		expectedACLFront  string
+		expectedSetvar    string
+	}{
+		// 0
+		{
+			frontingBind:    ":8000",
+			domain:          "d1.local",
+			useProto:        true,
+			sslRedirect:     false,
+			expectedACLBack: aclBackWithSockID,
+			expectedSetHeader: `
+    http-request set-var(txn.proto) hdr(X-Forwarded-Proto)
+    http-request redirect scheme https if fronting-proxy !{ hdr(X-Forwarded-Proto) https }` + setHeaderWithACL,
+			expectedFront: `
+    mode http
+    bind :80
+    bind :8000 id 11
+    acl fronting-proxy so_id 11` + frontUseProto,
+			expectedMap:      "d1.local#/ d1_app_8080",
+			expectedACLFront: aclFrontExact,
+			expectedSetvar:   setvarBegin,
+		},
+		// 1
+		{
+			frontingBind:    ":8000",
+			domain:          "*.d1.local",
+			useProto:        true,
+			sslRedirect:     false,
+			expectedACLBack: aclBackWithSockID,
+			expectedSetHeader: `
+    http-request set-var(txn.proto) hdr(X-Forwarded-Proto)
+    http-request redirect scheme https if fronting-proxy !{ hdr(X-Forwarded-Proto) https }` + setHeaderWithACL,
+			expectedFront: `
+    mode http
+    bind :80
+    bind :8000 id 11
+    acl fronting-proxy so_id 11
+    <<set-req-base>>
+    http-request set-header X-Forwarded-Proto http if !fronting-proxy
+    http-request del-header X-SSL-Client-CN if !fronting-proxy
+    http-request del-header X-SSL-Client-DN if !fronting-proxy
+    http-request del-header X-SSL-Client-SHA1 if !fronting-proxy
+    http-request del-header X-SSL-Client-Cert if !fronting-proxy
+    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }`,
+			expectedRegexMap: `^[^.]+\.d1\.local#/ d1_app_8080`,
+			expectedACLFront: aclFrontRegex,
+			expectedSetvar:   setvarRegex,
+		},
+		// 2
+		{
+			frontingBind:    ":80",
+			domain:          "d1.local",
+			useProto:        true,
+			sslRedirect:     false,
+			expectedACLBack: aclBackWithHdr,
+			expectedSetHeader: `
+    http-request set-var(txn.proto) hdr(X-Forwarded-Proto)
+    http-request redirect scheme https if fronting-proxy !{ hdr(X-Forwarded-Proto) https }` + setHeaderWithACL,
+			expectedFront: `
+    mode http
+    bind :80
+    acl fronting-proxy hdr(X-Forwarded-Proto) -m found` + frontUseProto,
+			expectedMap:      "d1.local#/ d1_app_8080",
+			expectedACLFront: aclFrontExact,
+			expectedSetvar:   setvarBegin,
+		},
+		// 3
+		{
+			frontingBind:      ":8000",
+			domain:            "d1.local",
+			useProto:          false,
+			sslRedirect:       false,
+			expectedACLBack:   ``,
+			expectedSetHeader: setHeaderSSLWithACL,
+			expectedFront: `
+    mode http
+    bind :80
+    bind :8000 id 11
+    <<set-req-base>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }`,
+			expectedMap:      "d1.local#/ d1_app_8080",
+			expectedACLFront: aclFrontExact,
+			expectedSetvar:   setvarBegin,
+		},
+		// 4
+		{
+			frontingBind:      ":8000",
+			domain:            "*.d1.local",
+			useProto:          false,
+			sslRedirect:       false,
+			expectedACLBack:   ``,
+			expectedSetHeader: setHeaderSSLWithACL,
+			expectedFront: `
+    mode http
+    bind :80
+    bind :8000 id 11
+    <<set-req-base>>
+    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }`,
+			expectedRegexMap: `^[^.]+\.d1\.local#/ d1_app_8080`,
+			expectedACLFront: aclFrontRegex,
+			expectedSetvar:   setvarRegex,
+		},
+		// 5
+		{
+			frontingBind:      ":80",
+			domain:            "d1.local",
+			useProto:          false,
+			sslRedirect:       false,
+			expectedACLBack:   ``,
+			expectedSetHeader: setHeaderSSLWithACL,
+			expectedFront: `
+    mode http
+    bind :80
+    <<set-req-base>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }`,
+			expectedMap:      "d1.local#/ d1_app_8080",
+			expectedACLFront: aclFrontExact,
+			expectedSetvar:   setvarBegin,
+		},
+		// 6
+		{
+			frontingBind:    ":8000",
+			domain:          "d1.local",
+			useProto:        true,
+			sslRedirect:     true,
+			expectedACLBack: aclBackWithSockID,
+			expectedSetHeader: `
+    http-request set-var(txn.proto) hdr(X-Forwarded-Proto)
+    http-request redirect scheme https if fronting-proxy !{ hdr(X-Forwarded-Proto) https }
+    http-request redirect scheme https if !fronting-proxy !https-request` + setHeaderNoACL,
+			expectedFront: `
+    mode http
+    bind :80
+    bind :8000 id 11
+    acl fronting-proxy so_id 11` + frontUseProto,
+			expectedMap:      "d1.local#/ d1_app_8080",
+			expectedACLFront: aclFrontExact,
+			expectedSetvar:   setvarBegin,
+		},
+		// 7
+		{
+			frontingBind:    ":80",
+			domain:          "d1.local",
+			useProto:        true,
+			sslRedirect:     true,
+			expectedACLBack: aclBackWithHdr,
+			expectedSetHeader: `
+    http-request set-var(txn.proto) hdr(X-Forwarded-Proto)
+    http-request redirect scheme https if fronting-proxy !{ hdr(X-Forwarded-Proto) https }
+    http-request redirect scheme https if !fronting-proxy !https-request` + setHeaderNoACL,
+			expectedFront: `
+    mode http
+    bind :80
+    acl fronting-proxy hdr(X-Forwarded-Proto) -m found` + frontUseProto,
+			expectedMap:      "d1.local#/ d1_app_8080",
+			expectedACLFront: aclFrontExact,
+			expectedSetvar:   setvarBegin,
+		},
+		// 8
+		{
+			frontingBind:      ":8000",
+			domain:            "d1.local",
+			useProto:          false,
+			sslRedirect:       true,
+			expectedACLBack:   ``,
+			expectedSetHeader: setHeaderNoACL,
+			expectedFront: `
+    mode http
+    bind :80
+    bind :8000 id 11
+    <<set-req-base>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }`,
+			expectedMap:      "d1.local#/ d1_app_8080",
+			expectedACLFront: aclFrontExact,
+			expectedSetvar:   setvarBegin,
+		},
+		// 9
+		{
+			frontingBind:      ":80",
+			domain:            "d1.local",
+			useProto:          false,
+			sslRedirect:       true,
+			expectedACLBack:   ``,
+			expectedSetHeader: setHeaderNoACL,
+			expectedFront: `
+    mode http
+    bind :80
+    <<set-req-base>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }`,
+			expectedMap:      "d1.local#/ d1_app_8080",
+			expectedACLFront: aclFrontExact,
+			expectedSetvar:   setvarBegin,
+		},
+	}
+	for _, test := range testCases {
+		c := setup(t)
+
+		var h *hatypes.Host
+		var b *hatypes.Backend
+
+		b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+		h = c.config.Hosts().AcquireHost(test.domain)
+		h.AddPath(b, "/", hatypes.MatchBegin)
+		b.Endpoints = []*hatypes.Endpoint{endpointS1}
+		b.FindBackendPath(h.FindPath("/")[0].Link).SSLRedirect = test.sslRedirect
+		b.FindBackendPath(h.FindPath("/")[0].Link).HSTS = hatypes.HSTS{
+			Enabled:    true,
+			MaxAge:     15768000,
+			Subdomains: true,
+			Preload:    true,
+		}
+		h.TLS.CAHash = "1"
+		h.TLS.CAFilename = "/var/haproxy/ssl/ca.pem"
+		c.config.Global().Bind.FrontingBind = test.frontingBind
+		c.config.Global().Bind.FrontingSockID = 11
+		c.config.Global().Bind.FrontingUseProto = test.useProto
+
+		c.Update()
+		c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http` + test.expectedACLBack + `
+    acl local-offload ssl_fc` + test.expectedSetHeader + `
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+frontend _front_http` + test.expectedFront + `
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-header X-Forwarded-Proto https
+    http-request del-header X-SSL-Client-CN
+    http-request del-header X-SSL-Client-DN
+    http-request del-header X-SSL-Client-SHA1
+    http-request del-header X-SSL-Client-Cert` + test.expectedACLFront + test.expectedSetvar + `
+    http-request use-service lua.send-421 if tls-has-crt { ssl_fc_has_sni } !{ ssl_fc_sni,strcmp(req.host) eq 0 }
+    http-request use-service lua.send-496 if { var(req.tls_nocrt_redir) _internal }
+    http-request use-service lua.send-421 if !tls-has-crt tls-host-need-crt
+    http-request use-service lua.send-495 if { var(req.tls_invalidcrt_redir) _internal }
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    use_backend %[var(req.snibackend)] if { var(req.snibackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+		if test.expectedMap != "" {
+			c.checkMap("_front_http_host__begin.map", test.expectedMap)
+		}
+		if test.expectedRegexMap != "" {
+			c.checkMap("_front_http_host__regex.map", test.expectedRegexMap)
+		}
+		c.logger.CompareLogging(defaultLogging)
+		c.teardown()
+	}
+}
+
+func TestInstanceTCPServices(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	b2 := c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b2.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
+	b3 := c.config.Backends().AcquireBackend("d3", "app", "8080")
+	b3.Endpoints = []*hatypes.Endpoint{endpointS31, endpointS32}
+
+	services := []struct {
+		port      int
+		hostname  string
+		backend   hatypes.BackendID
+		proxyProt bool
+		tls       hatypes.TLSConfig
+		custom    []string
+	}{
+		{
+			port: 7000,
+		},
+		{
+			port:    7001,
+			backend: b.BackendID(),
+		},
+		{
+			port:      7002,
+			backend:   b.BackendID(),
+			proxyProt: true,
+		},
+		{
+			port:      7003,
+			backend:   b.BackendID(),
+			proxyProt: true,
+			tls: hatypes.TLSConfig{
+				TLSFilename: "/ssl/7003.pem",
+			},
+		},
+		{
+			port:    7004,
+			backend: b.BackendID(),
+			tls: hatypes.TLSConfig{
+				TLSFilename: "/ssl/7004.pem",
+			},
+		},
+		{
+			port:    7005,
+			backend: b.BackendID(),
+			tls: hatypes.TLSConfig{
+				TLSFilename: "/ssl/7005.pem",
+				CAFilename:  "/ssl/ca-7005.pem",
+			},
+		},
+		{
+			port:    7006,
+			backend: b.BackendID(),
+			tls: hatypes.TLSConfig{
+				TLSFilename: "/ssl/7006.pem",
+				CAFilename:  "/ssl/ca-7006.pem",
+				CRLFilename: "/ssl/crl-7006.pem",
+			},
+		},
+		{
+			port:    7007,
+			backend: b.BackendID(),
+			tls: hatypes.TLSConfig{
+				ALPN:        "h2,http/1.1",
+				TLSFilename: "/ssl/7007.pem",
+			},
+		},
+		{
+			port:    7008,
+			backend: b.BackendID(),
+			tls: hatypes.TLSConfig{
+				TLSFilename:      "/ssl/7008.pem",
+				CAFilename:       "/ssl/ca-7008.pem",
+				CAVerifyOptional: true,
+			},
+		},
+		{
+			port:    7009,
+			backend: b.BackendID(),
+			tls: hatypes.TLSConfig{
+				TLSFilename:  "/ssl/7009.pem",
+				Ciphers:      "ECDHE-ECDSA-AES128-GCM-SHA256",
+				CipherSuites: "TLS_AES_128_GCM_SHA256",
+			},
+		},
+		{
+			port:    7010,
+			backend: b.BackendID(),
+			tls: hatypes.TLSConfig{
+				TLSFilename: "/ssl/7010.pem",
+				Options:     "force-tlsv13",
+			},
+		},
+		{
+			port:    7011,
+			backend: b.BackendID(),
+		},
+		{
+			port:     7011,
+			hostname: "local2",
+			backend:  b2.BackendID(),
+		},
+		{
+			port:     7011,
+			hostname: "local3",
+			backend:  b3.BackendID(),
+		},
+		{
+			port:     7011,
+			hostname: "*.local4",
+			backend:  b3.BackendID(),
+		},
+		{
+			port:    7012,
+			backend: b.BackendID(),
+			custom:  []string{"## custom for TCP 7012"},
+		},
+		{
+			port:    7013,
+			backend: b.BackendID(),
+			custom:  []string{"## custom for TCP 7013", "## multi line"},
+		},
+	}
+
+	for _, svc := range services {
+		hostname := svc.hostname
+		if hostname == "" {
+			hostname = hatypes.DefaultHost
+		}
+		p, h := c.config.TCPServices().AcquireTCPService(fmt.Sprintf("%s:%d", hostname, svc.port))
+		p.ProxyProt = svc.proxyProt
+		p.TLS = svc.tls
+		p.CustomConfig = svc.custom
+		h.Backend = svc.backend
+	}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+    server s22 172.17.0.122:8080 weight 100
+backend d3_app_8080
+    mode http
+    server s31 172.17.0.131:8080 weight 100
+    server s32 172.17.0.132:8080 weight 100
+<<backends-default>>
+frontend _front_tcp_7000
+    bind :7000
+    mode tcp
+frontend _front_tcp_7001
+    bind :7001
+    mode tcp
+    default_backend d1_app_8080
+frontend _front_tcp_7002
+    bind :7002 accept-proxy
+    mode tcp
+    default_backend d1_app_8080
+frontend _front_tcp_7003
+    bind :7003 accept-proxy ssl crt /ssl/7003.pem
+    mode tcp
+    default_backend d1_app_8080
+frontend _front_tcp_7004
+    bind :7004 ssl crt /ssl/7004.pem
+    mode tcp
+    default_backend d1_app_8080
+frontend _front_tcp_7005
+    bind :7005 ssl crt /ssl/7005.pem ca-file /ssl/ca-7005.pem verify required
+    mode tcp
+    default_backend d1_app_8080
+frontend _front_tcp_7006
+    bind :7006 ssl crt /ssl/7006.pem ca-file /ssl/ca-7006.pem verify required crl-file /ssl/crl-7006.pem
+    mode tcp
+    default_backend d1_app_8080
+frontend _front_tcp_7007
+    bind :7007 ssl crt /ssl/7007.pem alpn h2,http/1.1
+    mode tcp
+    default_backend d1_app_8080
+frontend _front_tcp_7008
+    bind :7008 ssl crt /ssl/7008.pem ca-file /ssl/ca-7008.pem verify optional
+    mode tcp
+    default_backend d1_app_8080
+frontend _front_tcp_7009
+    bind :7009 ssl crt /ssl/7009.pem ciphers ECDHE-ECDSA-AES128-GCM-SHA256 ciphersuites TLS_AES_128_GCM_SHA256
+    mode tcp
+    default_backend d1_app_8080
+frontend _front_tcp_7010
+    bind :7010 ssl crt /ssl/7010.pem force-tlsv13
+    mode tcp
+    default_backend d1_app_8080
+frontend _front_tcp_7011
+    bind :7011
+    mode tcp
+    tcp-request inspect-delay 5s
+    tcp-request content set-var(req.tcpback) req.ssl_sni,lower,map_str(/etc/haproxy/maps/_tcp_sni_7011__exact.map)
+    tcp-request content set-var(req.tcpback) req.ssl_sni,lower,map_reg(/etc/haproxy/maps/_tcp_sni_7011__regex.map) if !{ var(req.tcpback) -m found }
+    tcp-request content accept if { req.ssl_hello_type 1 }
+    use_backend %[var(req.tcpback)] if { var(req.tcpback) -m found }
+    default_backend d1_app_8080
+frontend _front_tcp_7012
+    bind :7012
+    mode tcp
+    ## custom for TCP 7012
+    default_backend d1_app_8080
+frontend _front_tcp_7013
+    bind :7013
+    mode tcp
+    ## custom for TCP 7013
+    ## multi line
+    default_backend d1_app_8080
+<<frontends-default>>
+<<support>>
+`)
+	c.checkMap("_tcp_sni_7011__exact.map", `
+local2 d2_app_8080
+local3 d3_app_8080`)
+	c.checkMap("_tcp_sni_7011__regex.map", `
+^[^.]+\.local4$ d3_app_8080`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceTCPBackend(t *testing.T) {
+	testCases := []struct {
+		doconfig func(c *testConfig)
+		expected string
+		logging  string
+	}{
+		// 0
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("postgresql", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+			},
+			expected: `
+listen _tcp_postgresql_5432
+    bind :5432
+    mode tcp
+    server srv001 172.17.0.2:5432`,
+		},
+		// 1
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+				b.CheckInterval = "2s"
+			},
+			expected: `
+listen _tcp_pq_5432
+    bind :5432
+    mode tcp
+    server srv001 172.17.0.2:5432 check port 5432 inter 2s
+    server srv002 172.17.0.3:5432 check port 5432 inter 2s`,
+		},
+		// 2
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.SSL.Filename = "/var/haproxy/ssl/pq.pem"
+				b.ProxyProt.EncodeVersion = "v2"
+			},
+			expected: `
+listen _tcp_pq_5432
+    bind :5432 ssl crt /var/haproxy/ssl/pq.pem
+    mode tcp
+    server srv001 172.17.0.2:5432 send-proxy-v2`,
+		},
+		// 3
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.SSL.Filename = "/var/haproxy/ssl/pq.pem"
+				b.ProxyProt.Decode = true
+				b.ProxyProt.EncodeVersion = "v1"
+				b.CheckInterval = "2s"
+				c.config.Global().Bind.TCPBindIP = "127.0.0.1"
+			},
+			expected: `
+listen _tcp_pq_5432
+    bind 127.0.0.1:5432 ssl crt /var/haproxy/ssl/pq.pem accept-proxy
+    mode tcp
+    server srv001 172.17.0.2:5432 check port 5432 inter 2s send-proxy`,
+		},
+		// 4
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.SSL.Filename = "/var/haproxy/ssl/pq.pem"
+				b.SSL.CAFilename = "/var/haproxy/ssl/pqca.pem"
+				b.ProxyProt.EncodeVersion = "v2"
+			},
+			expected: `
+listen _tcp_pq_5432
+    bind :5432 ssl crt /var/haproxy/ssl/pq.pem ca-file /var/haproxy/ssl/pqca.pem verify required
+    mode tcp
+    server srv001 172.17.0.2:5432 send-proxy-v2`,
+		},
+		// 5
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.SSL.Filename = "/var/haproxy/ssl/pq.pem"
+				b.SSL.CAFilename = "/var/haproxy/ssl/pqca.pem"
+				b.SSL.CRLFilename = "/var/haproxy/ssl/pqcrl.pem"
+				b.ProxyProt.EncodeVersion = "v2"
+			},
+			expected: `
+listen _tcp_pq_5432
+    bind :5432 ssl crt /var/haproxy/ssl/pq.pem ca-file /var/haproxy/ssl/pqca.pem verify required crl-file /var/haproxy/ssl/pqcrl.pem
+    mode tcp
+    server srv001 172.17.0.2:5432 send-proxy-v2`,
+		},
+		// 6
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.AllowList = []string{"10.0.0.0/8", "192.168.0.1"}
+			},
+			expected: `
+listen _tcp_pq_5432
+    bind :5432
+    mode tcp
+    acl allow_list src 10.0.0.0/8 192.168.0.1
+    tcp-request connection reject if !allow_list
+    server srv001 172.17.0.2:5432`,
+		},
+		// 7
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("redis", 6379)
+				b.AddEndpoint("172.17.0.2", 6379)
+				b.CheckInterval = "2s"
+				b.CheckRules = []*hatypes.TCPCheckRule{
+					{Action: "send", Value: `PING\r\n`},
+					{Action: "expect", Match: "string", Value: "+PONG"},
+					{Action: "send-binary", Value: "0a0d"},
+					{Action: "expect", Match: "! rstring", Value: "^-ERR"},
+				}
+			},
+			expected: `
+listen _tcp_redis_6379
+    bind :6379
+    mode tcp
+    option tcp-check
+    tcp-check send "PING\r\n"
+    tcp-check expect string +PONG
+    tcp-check send-binary 0a0d
+    tcp-check expect ! rstring ^-ERR
+    server srv001 172.17.0.2:6379 check port 6379 inter 2s`,
+		},
+		// 8
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("redis", 6379)
+				b.AddEndpoint("172.17.0.2", 6379)
+				b.CheckRules = []*hatypes.TCPCheckRule{
+					{Action: "send", Value: `PING\r\n`},
+				}
+			},
+			expected: `
+listen _tcp_redis_6379
+    bind :6379
+    mode tcp
+    server srv001 172.17.0.2:6379`,
+		},
+	}
+	for _, test := range testCases {
+		c := setup(t)
+		test.doconfig(c)
+		c.Update()
+		c.checkConfig(`
+<<global>>
+<<defaults>>` + test.expected + `
+backend _error404
+    mode http
+    http-request use-service lua.send-404
+<<frontend-http-clean>>
+    default_backend _error404
+<<frontend-https-clean>>
+    default_backend _error404
+<<support>>
+`)
+		logging := test.logging
+		if logging == "" {
+			logging = defaultLogging
+		}
+		c.logger.CompareLogging(logging)
+		c.teardown()
+	}
+}
+
+func TestInstanceDefaultHost(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	def := c.config.Backends().AcquireBackend("default", "default-backend", "8080")
+	def.Endpoints = []*hatypes.Endpoint{endpointS0}
+	c.config.Backends().DefaultBackend = def
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+	hdef := c.config.Hosts().AcquireHost(hatypes.DefaultHost)
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.VarNamespace = true
+	hdef.AddPath(b, "/", hatypes.MatchBegin)
+	hdef.AddPath(b, "/app1", hatypes.MatchExact)
+	hdef.AddPath(b, "/app2", hatypes.MatchPrefix)
+	b.FindBackendPath(hdef.FindPath("/")[0].Link).SSLRedirect = true
+	b.FindBackendPath(hdef.FindPath("/app1")[0].Link).RewriteURL = "/"
+	b.FindBackendPath(hdef.FindPath("/app2")[0].Link).MaxBodySize = 32768
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.VarNamespace = true
+	h.AddPath(b, "/app11", hatypes.MatchBegin)
+	hdef.AddPath(b, "/app12", hatypes.MatchExact)
+	hdef.AddPath(b, "/app13", hatypes.MatchPrefix)
+	b.FindBackendPath(h.FindPath("/app11")[0].Link).SSLRedirect = true
+	b.FindBackendPath(hdef.FindPath("/app12")[0].Link).RewriteURL = "/"
+	b.FindBackendPath(hdef.FindPath("/app13")[0].Link).MaxBodySize = 65536
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    acl https-request ssl_fc
+    # path01 = <default>/
+    # path02 = <default>/app1
+    # path03 = <default>/app2
+    http-request set-var(txn.pathID) var(req.path),map_str(/etc/haproxy/maps/_back_d1_app_8080_idpathdef__exact.map)
+    http-request set-var(txn.pathID) var(req.path),map_dir(/etc/haproxy/maps/_back_d1_app_8080_idpathdef__prefix_02.map) if !{ var(txn.pathID) -m found }
+    http-request set-var(txn.pathID) var(req.path),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpathdef__begin.map) if !{ var(txn.pathID) -m found }
+    http-request redirect scheme https if !https-request { var(txn.pathID) path01 }
+    http-request use-service lua.send-413 if { var(txn.pathID) path03 } { req.body_size,sub(32768) gt 0 }
+    http-request replace-path ^/app1/?(.*)$     /\1     if { var(txn.pathID) path02 }
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    acl https-request ssl_fc
+    # path02 = <default>/app12
+    # path03 = <default>/app13
+    # path01 = d2.local/app11
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d2_app_8080_idpath__begin.map)
+    http-request set-var(txn.pathID) var(req.path),map_str(/etc/haproxy/maps/_back_d2_app_8080_idpathdef__exact.map) if !{ var(txn.pathID) -m found }
+    http-request set-var(txn.pathID) var(req.path),map_dir(/etc/haproxy/maps/_back_d2_app_8080_idpathdef__prefix.map) if !{ var(txn.pathID) -m found }
+    http-request redirect scheme https if !https-request { var(txn.pathID) path01 }
+    http-request use-service lua.send-413 if { var(txn.pathID) path03 } { req.body_size,sub(65536) gt 0 }
+    http-request replace-path ^/app12/?(.*)$     /\1     if { var(txn.pathID) path02 }
+    server s1 172.17.0.11:8080 weight 100
+backend default_default-backend_8080
+    mode http
+    server s0 172.17.0.99:8080 weight 100
+frontend _front_http
+    mode http
+    bind :80
+    <<set-req-base>>
+    http-request set-var(txn.namespace) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_namespace__begin.map)
+    http-request set-var(txn.namespace) str(-) if !{ var(txn.namespace) -m found }
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    http-request set-var(req.defaultbackend) var(req.path),map_str(/etc/haproxy/maps/_front_defaulthost__exact.map) if !{ var(req.backend) -m found }
+    http-request set-var(req.defaultbackend) var(req.path),map_dir(/etc/haproxy/maps/_front_defaulthost__prefix_02.map) if !{ var(req.backend) -m found } !{ var(req.defaultbackend) -m found }
+    http-request set-var(req.defaultbackend) var(req.path),lower,map_beg(/etc/haproxy/maps/_front_defaulthost__begin.map) if !{ var(req.backend) -m found } !{ var(req.defaultbackend) -m found }
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    use_backend %[var(req.defaultbackend)]
+    default_backend default_default-backend_8080
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    http-request set-var(req.defaultbackend) var(req.path),map_str(/etc/haproxy/maps/_front_defaulthost__exact.map) if !{ var(req.hostbackend) -m found }
+    http-request set-var(req.defaultbackend) var(req.path),map_dir(/etc/haproxy/maps/_front_defaulthost__prefix_02.map) if !{ var(req.hostbackend) -m found } !{ var(req.defaultbackend) -m found }
+    http-request set-var(req.defaultbackend) var(req.path),lower,map_beg(/etc/haproxy/maps/_front_defaulthost__begin.map) if !{ var(req.hostbackend) -m found } !{ var(req.defaultbackend) -m found }
+    http-request set-var(txn.namespace) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_namespace__begin.map)
+    http-request set-var(txn.namespace) str(-) if !{ var(txn.namespace) -m found }
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    use_backend %[var(req.defaultbackend)]
+    default_backend default_default-backend_8080
+<<support>>
+`)
+
+	c.checkMap("_front_http_host__begin.map", `
+d2.local#/app11 d2_app_8080
+`)
+	c.checkMap("_front_bind_crt.list", `
+/var/haproxy/ssl/certs/default.pem !*
+`)
+	c.checkMap("_front_namespace__begin.map", `
+d2.local#/app11 d2
+`)
+	c.checkMap("_front_https_host__begin.map", `
+d2.local#/app11 d2_app_8080
+`)
+	c.checkMap("_front_defaulthost__prefix_02.map", `
+/app2 d1_app_8080
+/app13 d2_app_8080`)
+	c.checkMap("_front_defaulthost__exact.map", `
+/app1 d1_app_8080
+/app12 d2_app_8080`)
+	c.checkMap("_front_defaulthost__begin.map", `
+/ d1_app_8080`)
+	c.checkMap("_back_d1_app_8080_idpathdef__exact.map", `
+/app1 path02`)
+	c.checkMap("_back_d1_app_8080_idpathdef__prefix_02.map", `
+/app2 path03`)
+	c.checkMap("_back_d1_app_8080_idpathdef__begin.map", `
+/ path01`)
+	c.checkMap("_back_d2_app_8080_idpathdef__exact.map", `
+/app12 path02`)
+	c.checkMap("_back_d2_app_8080_idpathdef__prefix.map", `
+/app13 path03`)
+	c.checkMap("_back_d2_app_8080_idpath__begin.map", `
+d2.local#/app11 path01`)
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceUseDefaultCrt(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.TLS.UseDefaultCrt = false
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.TLS.UseDefaultCrt = true
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+	c.checkMap("_front_http_host__begin.map", `
+d1.local#/ d1_app_8080
+d2.local#/ d2_app_8080
+`)
+	c.checkMap("_front_https_host__begin.map", `
+d2.local#/ d2_app_8080
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+func TestInstanceStrictHost(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/path", hatypes.MatchBegin)
+	c.config.Global().StrictHost = true
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+	c.checkMap("_front_http_host__begin.map", `
+d1.local#/path d1_app_8080
+d1.local#/ _error404
+`)
+	c.checkMap("_front_https_host__begin.map", `
+d1.local#/path d1_app_8080
+d1.local#/ _error404
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceStrictHostDefaultHost(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/path", hatypes.MatchBegin)
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+	h = c.config.Hosts().AcquireHost(hatypes.DefaultHost)
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	c.config.Global().StrictHost = true
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    http-request set-var(req.path) path
+    http-request set-var(req.host) hdr(host),field(1,:),lower
+    http-request set-var(req.base) var(req.host),concat(\#,req.path)
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    http-request set-var(req.defaultbackend) var(req.path),lower,map_beg(/etc/haproxy/maps/_front_defaulthost__begin.map) if !{ var(req.backend) -m found }
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    use_backend %[var(req.defaultbackend)]
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    http-request set-var(req.path) path
+    http-request set-var(req.host) hdr(host),field(1,:),lower
+    http-request set-var(req.base) var(req.host),concat(\#,req.path)
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    http-request set-var(req.defaultbackend) var(req.path),lower,map_beg(/etc/haproxy/maps/_front_defaulthost__begin.map) if !{ var(req.hostbackend) -m found }
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    use_backend %[var(req.defaultbackend)]
+    default_backend _error404
+<<support>>
+`)
+	c.checkMap("_front_http_host__begin.map", `
+d1.local#/path d1_app_8080
+d1.local#/ d2_app_8080
+`)
+	c.checkMap("_front_https_host__begin.map", `
+d1.local#/path d1_app_8080
+d1.local#/ d2_app_8080
+`)
+	c.checkMap("_front_defaulthost__begin.map", `
+/ d2_app_8080`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceFrontend(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	def := c.config.Backends().AcquireBackend("default", "default-backend", "8080")
+	def.Endpoints = []*hatypes.Endpoint{endpointS0}
+	c.config.Backends().DefaultBackend = def
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/")[0].Link).SSLRedirect = true
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h.VarNamespace = true
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/d1.pem"
+	h.TLS.TLSHash = "1"
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/app", hatypes.MatchPrefix)
+	b.FindBackendPath(h.FindPath("/app")[0].Link).SSLRedirect = true
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/d2.pem"
+	h.TLS.TLSHash = "2"
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    acl https-request ssl_fc
+    http-request redirect scheme https if !https-request
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    acl https-request ssl_fc
+    http-request redirect scheme https if !https-request
+    server s1 172.17.0.11:8080 weight 100
+backend default_default-backend_8080
+    mode http
+    server s0 172.17.0.99:8080 weight 100
+frontend _front_http
+    mode http
+    bind :80
+    <<set-req-base>>
+    http-request set-var(txn.namespace) var(req.base),map_dir(/etc/haproxy/maps/_front_namespace__prefix.map)
+    http-request set-var(txn.namespace) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_namespace__begin.map) if !{ var(txn.namespace) -m found }
+    http-request set-var(txn.namespace) str(-) if !{ var(txn.namespace) -m found }
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),map_dir(/etc/haproxy/maps/_front_http_host__prefix.map)
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map) if !{ var(req.backend) -m found }
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend default_default-backend_8080
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),map_dir(/etc/haproxy/maps/_front_https_host__prefix.map)
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map) if !{ var(req.hostbackend) -m found }
+    http-request set-var(txn.namespace) var(req.base),map_dir(/etc/haproxy/maps/_front_namespace__prefix.map)
+    http-request set-var(txn.namespace) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_namespace__begin.map) if !{ var(txn.namespace) -m found }
+    http-request set-var(txn.namespace) str(-) if !{ var(txn.namespace) -m found }
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend default_default-backend_8080
+<<support>>
+`)
+
+	c.checkMap("_front_http_host__begin.map", `
+d1.local#/ d1_app_8080
+`)
+	c.checkMap("_front_http_host__prefix.map", `
+d2.local#/app d2_app_8080
+`)
+	c.checkMap("_front_https_host__begin.map", `
+d1.local#/ d1_app_8080
+`)
+	c.checkMap("_front_https_host__prefix.map", `
+d2.local#/app d2_app_8080
+`)
+	c.checkMap("_front_namespace__begin.map", `
+d1.local#/ d1
+`)
+	c.checkMap("_front_namespace__prefix.map", `
+d2.local#/app -
+`)
+
+	c.checkMap("_front_bind_crt.list", `
+/var/haproxy/ssl/certs/default.pem !*
+/var/haproxy/ssl/certs/d1.pem d1.local
+/var/haproxy/ssl/certs/d2.pem d2.local
+`)
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceFrontendCA(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	def := c.config.Backends().AcquireBackend("default", "default-backend", "8080")
+	def.Endpoints = []*hatypes.Endpoint{endpointS0}
+	c.config.Backends().DefaultBackend = def
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d", "app", "8080")
+	h = c.config.Hosts().AcquireHost("*.d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.TLS.CAFilename = "/var/haproxy/ssl/ca/d1.local.pem"
+	h.TLS.CAHash = "1"
+	h.TLS.CAErrorPage = "http://d1.local/error.html"
+
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.TLS.CAFilename = "/var/haproxy/ssl/ca/d2.local.pem"
+	h.TLS.CAHash = "2"
+	h.TLS.CRLFilename = "/var/haproxy/ssl/ca/d2.local.crl.pem"
+	h.TLS.CRLHash = "2"
+	h.TLS.CAErrorPage = "http://d2.local/error.html"
+
+	h = c.config.Hosts().AcquireHost("d3.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.TLS.Ciphers = "ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384"
+
+	h = c.config.Hosts().AcquireHost("d4.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.TLS.CipherSuites = "TLS_CHACHA20_POLY1305_SHA256:TLS_AES_128_GCM_SHA256"
+
+	h = c.config.Hosts().AcquireHost("d5.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.TLS.ALPN = "h2"
+
+	h = c.config.Hosts().AcquireHost("d6.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.TLS.Options = "ssl-min-ver TLSv1.0 ssl-max-ver TLSv1.2"
+
+	for _, path := range b.Paths {
+		path.SSLRedirect = true
+	}
+	b.TLS.AddCertHeader = true
+	b.TLS.FingerprintLower = true
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d_app_8080
+    mode http
+    acl https-request ssl_fc
+    acl local-offload ssl_fc
+    http-request redirect scheme https if !https-request
+    http-request set-header X-SSL-Client-CN   %{+Q}[ssl_c_s_dn(cn)]
+    http-request set-header X-SSL-Client-DN   %{+Q}[ssl_c_s_dn]
+    http-request set-header X-SSL-Client-SHA1 %{+Q}[ssl_c_sha1,hex,lower]
+    http-request set-header X-SSL-Client-Cert %{+Q}[ssl_c_der,base64]
+    server s1 172.17.0.11:8080 weight 100
+backend default_default-backend_8080
+    mode http
+    server s0 172.17.0.99:8080 weight 100
+frontend _front_http
+    mode http
+    bind :80
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map) if !{ var(req.backend) -m found }
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend default_default-backend_8080
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    <<https-headers>>
+    acl tls-has-crt ssl_c_used
+    acl tls-need-crt ssl_fc_sni -i -m str -f /etc/haproxy/maps/_front_tls_needcrt__exact.list
+    acl tls-need-crt ssl_fc_sni -i -m reg -f /etc/haproxy/maps/_front_tls_needcrt__regex.list
+    acl tls-host-need-crt var(req.host) -i -m str -f /etc/haproxy/maps/_front_tls_needcrt__exact.list
+    acl tls-host-need-crt var(req.host) -i -m reg -f /etc/haproxy/maps/_front_tls_needcrt__regex.list
+    acl tls-has-invalid-crt ssl_c_verify gt 0
+    acl tls-check-crt ssl_fc_sni -i -m str -f /etc/haproxy/maps/_front_tls_auth__exact.list
+    acl tls-check-crt ssl_fc_sni -i -m reg -f /etc/haproxy/maps/_front_tls_auth__regex.list
     http-request set-var(req.snibase) ssl_fc_sni,lower,concat(\#,req.path)
     http-request set-var(req.snibackend) var(req.snibase),lower,map_beg(/etc/haproxy/maps/_front_https_sni__begin.map)
+    http-request set-var(req.snibackend) var(req.snibase),map_reg(/etc/haproxy/maps/_front_https_sni__regex.map) if !{ var(req.snibackend) -m found }
     http-request set-var(req.snibackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_sni__begin.map) if !{ var(req.snibackend) -m found } !tls-has-crt !tls-host-need-crt
-    http-request set-var(req.tls_nocrt_redir) str(_internal) if !tls-has-crt tls-need-crt
-    http-request set-var(req.tls_invalidcrt_redir) str(_internal) if tls-has-invalid-crt tls-check-crt`
-		setvarRegex = `
-    http-request set-var(req.snibase) ssl_fc_sni,lower,concat(\#,req.path)
-    http-request set-var(req.snibackend) var(req.snibase),map_reg(/etc/haproxy/maps/_front_https_sni__regex.map)
     http-request set-var(req.snibackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_sni__regex.map) if !{ var(req.snibackend) -m found } !tls-has-crt !tls-host-need-crt
-    http-request set-var(req.tls_nocrt_redir) str(_internal) if !tls-has-crt tls-need-crt
-    http-request set-var(req.tls_invalidcrt_redir) str(_internal) if tls-has-invalid-crt tls-check-crt`
-	)
+    http-request set-var(req.tls_nocrt_redir) ssl_fc_sni,lower,map_str(/etc/haproxy/maps/_front_tls_missingcrt_pages__exact.map,_internal) if !tls-has-crt tls-need-crt
+    http-request set-var(req.tls_nocrt_redir) ssl_fc_sni,lower,map_reg(/etc/haproxy/maps/_front_tls_missingcrt_pages__regex.map,_internal) if { var(req.tls_nocrt_redir) _internal }
+    http-request set-var(req.tls_invalidcrt_redir) ssl_fc_sni,lower,map_str(/etc/haproxy/maps/_front_tls_invalidcrt_pages__exact.map,_internal) if tls-has-invalid-crt tls-check-crt
+    http-request set-var(req.tls_invalidcrt_redir) ssl_fc_sni,lower,map_reg(/etc/haproxy/maps/_front_tls_invalidcrt_pages__regex.map,_internal) if { var(req.tls_invalidcrt_redir) _internal }
+    http-request redirect location %[var(req.tls_nocrt_redir)] code 303 if { var(req.tls_nocrt_redir) -m found } !{ var(req.tls_nocrt_redir) _internal }
+    http-request redirect location %[var(req.tls_invalidcrt_redir)] code 303 if { var(req.tls_invalidcrt_redir) -m found } !{ var(req.tls_invalidcrt_redir) _internal }
+    http-request use-service lua.send-421 if tls-has-crt { ssl_fc_has_sni } !{ ssl_fc_sni,strcmp(req.host) eq 0 }
+    http-request use-service lua.send-496 if { var(req.tls_nocrt_redir) _internal }
+    http-request use-service lua.send-421 if !tls-has-crt tls-host-need-crt
+    http-request use-service lua.send-495 if { var(req.tls_invalidcrt_redir) _internal }
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    use_backend %[var(req.snibackend)] if { var(req.snibackend) -m found }
+    default_backend default_default-backend_8080
+<<support>>
+`)
+
+	c.checkMap("_front_http_host__begin.map", `
+d2.local#/ d_app_8080
+d3.local#/ d_app_8080
+d4.local#/ d_app_8080
+d5.local#/ d_app_8080
+d6.local#/ d_app_8080
+`)
+	c.checkMap("_front_http_host__regex.map", `
+^[^.]+\.d1\.local#/ d_app_8080
+`)
+	c.checkMap("_front_bind_crt.list", `
+/var/haproxy/ssl/certs/default.pem !*
+/var/haproxy/ssl/certs/default.pem [ca-file /var/haproxy/ssl/ca/d1.local.pem verify optional] *.d1.local
+/var/haproxy/ssl/certs/default.pem [ca-file /var/haproxy/ssl/ca/d2.local.pem verify optional crl-file /var/haproxy/ssl/ca/d2.local.crl.pem] d2.local
+/var/haproxy/ssl/certs/default.pem [ciphers ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384] d3.local
+/var/haproxy/ssl/certs/default.pem [ciphersuites TLS_CHACHA20_POLY1305_SHA256:TLS_AES_128_GCM_SHA256] d4.local
+/var/haproxy/ssl/certs/default.pem [alpn h2] d5.local
+/var/haproxy/ssl/certs/default.pem [ssl-min-ver TLSv1.0 ssl-max-ver TLSv1.2] d6.local
+`)
+	c.checkMap("_front_https_host__begin.map", `
+d3.local#/ d_app_8080
+d4.local#/ d_app_8080
+d5.local#/ d_app_8080
+d6.local#/ d_app_8080
+`)
+	c.checkMap("_front_https_sni__begin.map", `
+d2.local#/ d_app_8080
+`)
+	c.checkMap("_front_https_sni__regex.map", `
+^[^.]+\.d1\.local#/ d_app_8080
+`)
+	c.checkMap("_front_tls_needcrt__exact.list", `
+d2.local
+`)
+	c.checkMap("_front_tls_needcrt__regex.list", `
+^[^.]+\.d1\.local$
+`)
+	c.checkMap("_front_tls_auth__exact.list", `
+d2.local
+`)
+	c.checkMap("_front_tls_auth__regex.list", `
+^[^.]+\.d1\.local$
+`)
+	c.checkMap("_front_tls_missingcrt_pages__exact.map", `
+d2.local http://d2.local/error.html
+`)
+	c.checkMap("_front_tls_invalidcrt_pages__regex.map", `
+^[^.]+\.d1\.local$ http://d1.local/error.html
+`)
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceFrontendAuth(t *testing.T) {
+	type back struct {
+		iplist   []string
+		port     int
+		hostname string
+	}
 	testCases := []struct {
-		frontingBind      string
-		domain            string
-		useProto          bool
-		sslRedirect       bool
-		expectedACLBack   string
-		expectedSetHeader string
-		expectedFront     string
-		expectedMap       string
-		expectedRegexMap  string
-		expectedACLFront  string
-		expectedSetvar    string
+		backs    []back
+		expback  string
+		expfront string
 	}{
-		// 0
 		{
-			frontingBind:    ":8000",
-			domain:          "d1.local",
-			useProto:        true,
-			sslRedirect:     false,
-			expectedACLBack: aclBackWithSockID,
-			expectedSetHeader: `
-    http-request set-var(txn.proto) hdr(X-Forwarded-Proto)
-    http-request redirect scheme https if fronting-proxy !{ hdr(X-Forwarded-Proto) https }` + setHeaderWithACL,
-			expectedFront: `
+			backs: []back{
+				{iplist: []string{"10.0.0.1", "10.0.0.2"}, port: 8080},
+			},
+			expback: `
+backend _auth_backend001_8080
     mode http
-    bind :80
-    bind :8000 id 11
-    acl fronting-proxy so_id 11` + frontUseProto,
-			expectedMap:      "d1.local#/ d1_app_8080",
-			expectedACLFront: aclFrontExact,
-			expectedSetvar:   setvarBegin,
+    server srv001 10.0.0.1:8080 weight 1
+    server srv002 10.0.0.2:8080 weight 1`,
+			expfront: `
+backend _auth_4001
+    mode http
+    server _auth_4001 127.0.0.1:4001
+frontend _front__auth
+    mode http
+    bind 127.0.0.1:4001
+    use_backend _auth_backend001_8080`,
 		},
-		// 1
 		{
-			frontingBind:    ":8000",
-			domain:          "*.d1.local",
-			useProto:        true,
-			sslRedirect:     false,
-			expectedACLBack: aclBackWithSockID,
-			expectedSetHeader: `
-    http-request set-var(txn.proto) hdr(X-Forwarded-Proto)
-    http-request redirect scheme https if fronting-proxy !{ hdr(X-Forwarded-Proto) https }` + setHeaderWithACL,
-			expectedFront: `
+			backs: []back{
+				{iplist: []string{"10.0.0.1", "10.0.0.2"}, port: 8080},
+				{iplist: []string{"10.0.0.3"}, port: 8080},
+				{iplist: []string{"10.0.0.3"}, port: 8080, hostname: "app1.local"},
+			},
+			expback: `
+backend _auth_backend001_8080
+    mode http
+    server srv001 10.0.0.1:8080 weight 1
+    server srv002 10.0.0.2:8080 weight 1
+backend _auth_backend002_8080
+    mode http
+    server srv001 10.0.0.3:8080 weight 1
+backend _auth_backend003_8080
+    mode http
+    http-request set-header Host app1.local
+    server srv001 10.0.0.3:8080 weight 1`,
+			expfront: `
+backend _auth_4001
+    mode http
+    server _auth_4001 127.0.0.1:4001
+backend _auth_4002
+    mode http
+    server _auth_4002 127.0.0.1:4002
+backend _auth_4003
+    mode http
+    server _auth_4003 127.0.0.1:4003
+frontend _front__auth
+    mode http
+    bind 127.0.0.1:4001 id 14001
+    bind 127.0.0.1:4002 id 14002
+    bind 127.0.0.1:4003 id 14003
+    use_backend _auth_backend001_8080 if { so_id 14001 }
+    use_backend _auth_backend002_8080 if { so_id 14002 }
+    use_backend _auth_backend003_8080 if { so_id 14003 }`,
+		},
+	}
+	for _, test := range testCases {
+		c := setup(t)
+
+		var h *hatypes.Host
+		var b *hatypes.Backend
+
+		b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS1}
+		h = c.config.Hosts().AcquireHost("d1.local")
+		h.AddPath(b, "/", hatypes.MatchBegin)
+
+		auth := &c.config.Frontend().AuthProxy
+		auth.Name = "_front__auth"
+		auth.RangeStart = 4001
+		auth.RangeEnd = 4010
+
+		for _, back := range test.backs {
+			backend := c.config.Backends().AcquireAuthBackend(back.iplist, back.port, back.hostname)
+			_, _ = c.config.Frontend().AcquireAuthBackendName(backend.BackendID())
+		}
+
+		c.Update()
+		c.checkConfig(`
+<<global>>
+<<defaults>>` + test.expback + `
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>` + test.expfront + `
+<<frontends-default>>
+<<support>>
+`)
+
+		c.logger.CompareLogging(defaultLogging)
+		c.teardown()
+	}
+}
+
+func TestInstanceSomePaths(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	def := c.config.Backends().AcquireBackend("default", "default-backend", "8080")
+	def.Endpoints = []*hatypes.Endpoint{endpointS0}
+	c.config.Backends().DefaultBackend = def
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d", "app0", "8080")
+	h = c.config.Hosts().AcquireHost("d.local")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/")[0].Link).SSLRedirect = true
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	b = c.config.Backends().AcquireBackend("d", "app1", "8080")
+	h.AddPath(b, "/app", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/app")[0].Link).SSLRedirect = true
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	b = c.config.Backends().AcquireBackend("d", "app2", "8080")
+	h.AddPath(b, "/app/sub", hatypes.MatchBegin)
+	b.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
+
+	b = c.config.Backends().AcquireBackend("d", "app3", "8080")
+	h.AddPath(b, "/sub", hatypes.MatchBegin)
+	b.Endpoints = []*hatypes.Endpoint{endpointS31, endpointS32, endpointS33}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d_app0_8080
     mode http
-    bind :80
-    bind :8000 id 11
-    acl fronting-proxy so_id 11
-    <<set-req-base>>
-    http-request set-header X-Forwarded-Proto http if !fronting-proxy
-    http-request del-header X-SSL-Client-CN if !fronting-proxy
-    http-request del-header X-SSL-Client-DN if !fronting-proxy
-    http-request del-header X-SSL-Client-SHA1 if !fronting-proxy
-    http-request del-header X-SSL-Client-Cert if !fronting-proxy
-    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map)
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }`,
-			expectedRegexMap: `^[^.]+\.d1\.local#/ d1_app_8080`,
-			expectedACLFront: aclFrontRegex,
-			expectedSetvar:   setvarRegex,
-		},
-		// 2
-		{
-			frontingBind:    ":80",
-			domain:          "d1.local",
-			useProto:        true,
-			sslRedirect:     false,
-			expectedACLBack: aclBackWithHdr,
-			expectedSetHeader: `
-    http-request set-var(txn.proto) hdr(X-Forwarded-Proto)
-    http-request redirect scheme https if fronting-proxy !{ hdr(X-Forwarded-Proto) https }` + setHeaderWithACL,
-			expectedFront: `
+    acl https-request ssl_fc
+    http-request redirect scheme https if !https-request
+    server s1 172.17.0.11:8080 weight 100
+backend d_app1_8080
     mode http
-    bind :80
-    acl fronting-proxy hdr(X-Forwarded-Proto) -m found` + frontUseProto,
-			expectedMap:      "d1.local#/ d1_app_8080",
-			expectedACLFront: aclFrontExact,
-			expectedSetvar:   setvarBegin,
-		},
-		// 3
-		{
-			frontingBind:      ":8000",
-			domain:            "d1.local",
-			useProto:          false,
-			sslRedirect:       false,
-			expectedACLBack:   ``,
-			expectedSetHeader: setHeaderSSLWithACL,
-			expectedFront: `
+    acl https-request ssl_fc
+    http-request redirect scheme https if !https-request
+    server s1 172.17.0.11:8080 weight 100
+backend d_app2_8080
     mode http
-    bind :80
-    bind :8000 id 11
-    <<set-req-base>>
-    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }`,
-			expectedMap:      "d1.local#/ d1_app_8080",
-			expectedACLFront: aclFrontExact,
-			expectedSetvar:   setvarBegin,
-		},
-		// 4
-		{
-			frontingBind:      ":8000",
-			domain:            "*.d1.local",
-			useProto:          false,
-			sslRedirect:       false,
-			expectedACLBack:   ``,
-			expectedSetHeader: setHeaderSSLWithACL,
-			expectedFront: `
+    server s21 172.17.0.121:8080 weight 100
+    server s22 172.17.0.122:8080 weight 100
+backend d_app3_8080
     mode http
-    bind :80
-    bind :8000 id 11
-    <<set-req-base>>
-    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map)
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }`,
-			expectedRegexMap: `^[^.]+\.d1\.local#/ d1_app_8080`,
-			expectedACLFront: aclFrontRegex,
-			expectedSetvar:   setvarRegex,
-		},
-		// 5
-		{
-			frontingBind:      ":80",
-			domain:            "d1.local",
-			useProto:          false,
-			sslRedirect:       false,
-			expectedACLBack:   ``,
-			expectedSetHeader: setHeaderSSLWithACL,
-			expectedFront: `
+    server s31 172.17.0.131:8080 weight 100
+    server s32 172.17.0.132:8080 weight 100
+    server s33 172.17.0.133:8080 weight 100
+backend default_default-backend_8080
     mode http
-    bind :80
-    <<set-req-base>>
-    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }`,
-			expectedMap:      "d1.local#/ d1_app_8080",
-			expectedACLFront: aclFrontExact,
-			expectedSetvar:   setvarBegin,
-		},
-		// 6
-		{
-			frontingBind:    ":8000",
-			domain:          "d1.local",
-			useProto:        true,
-			sslRedirect:     true,
-			expectedACLBack: aclBackWithSockID,
-			expectedSetHeader: `
-    http-request set-var(txn.proto) hdr(X-Forwarded-Proto)
-    http-request redirect scheme https if fronting-proxy !{ hdr(X-Forwarded-Proto) https }
-    http-request redirect scheme https if !fronting-proxy !https-request` + setHeaderNoACL,
-			expectedFront: `
+    server s0 172.17.0.99:8080 weight 100
+<<frontend-http>>
+    default_backend default_default-backend_8080
+<<frontend-https>>
+    default_backend default_default-backend_8080
+<<support>>
+`)
+
+	c.checkMap("_front_http_host__begin.map", `
+d.local#/sub d_app3_8080
+d.local#/app/sub d_app2_8080
+d.local#/app d_app1_8080
+d.local#/ d_app0_8080
+`)
+	c.checkMap("_front_https_host__begin.map", `
+d.local#/sub d_app3_8080
+d.local#/app/sub d_app2_8080
+d.local#/app d_app1_8080
+d.local#/ d_app0_8080
+`)
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceBackendRoutes(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d", "app-beta", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+
+	b = c.config.Backends().AcquireBackend("d", "app-v2", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS31}
+
+	b = c.config.Backends().AcquireBackend("d", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b.Routes = []*hatypes.BackendRoute{
+		{Type: hatypes.RouteMatchHeader, Name: "X-Env", Regex: true, Values: []string{"^preview-"}, Backend: "d_app-beta_8080"},
+		{Type: hatypes.RouteMatchQuery, Name: "version", Values: []string{"beta", "preview"}, Backend: "d_app-beta_8080"},
+		{Type: hatypes.RouteMatchQuery, Name: "version", Regex: true, Values: []string{`^v2\.`}, Backend: "d_app-v2_8080"},
+		{Type: hatypes.RouteMatchMethod, Values: []string{"POST", "PUT"}, Backend: "d_app-v2_8080"},
+	}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d_app-beta_8080
     mode http
-    bind :80
-    bind :8000 id 11
-    acl fronting-proxy so_id 11` + frontUseProto,
-			expectedMap:      "d1.local#/ d1_app_8080",
-			expectedACLFront: aclFrontExact,
-			expectedSetvar:   setvarBegin,
-		},
-		// 7
-		{
-			frontingBind:    ":80",
-			domain:          "d1.local",
-			useProto:        true,
-			sslRedirect:     true,
-			expectedACLBack: aclBackWithHdr,
-			expectedSetHeader: `
-    http-request set-var(txn.proto) hdr(X-Forwarded-Proto)
-    http-request redirect scheme https if fronting-proxy !{ hdr(X-Forwarded-Proto) https }
-    http-request redirect scheme https if !fronting-proxy !https-request` + setHeaderNoACL,
-			expectedFront: `
+    server s21 172.17.0.121:8080 weight 100
+backend d_app-v2_8080
     mode http
-    bind :80
-    acl fronting-proxy hdr(X-Forwarded-Proto) -m found` + frontUseProto,
-			expectedMap:      "d1.local#/ d1_app_8080",
-			expectedACLFront: aclFrontExact,
-			expectedSetvar:   setvarBegin,
-		},
-		// 8
-		{
-			frontingBind:      ":8000",
-			domain:            "d1.local",
-			useProto:          false,
-			sslRedirect:       true,
-			expectedACLBack:   ``,
-			expectedSetHeader: setHeaderNoACL,
-			expectedFront: `
+    server s31 172.17.0.131:8080 weight 100
+backend d_app_8080
     mode http
-    bind :80
-    bind :8000 id 11
-    <<set-req-base>>
-    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }`,
-			expectedMap:      "d1.local#/ d1_app_8080",
-			expectedACLFront: aclFrontExact,
-			expectedSetvar:   setvarBegin,
-		},
-		// 9
-		{
-			frontingBind:      ":80",
-			domain:            "d1.local",
-			useProto:          false,
-			sslRedirect:       true,
-			expectedACLBack:   ``,
-			expectedSetHeader: setHeaderNoACL,
-			expectedFront: `
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+frontend _front_http
     mode http
     bind :80
     <<set-req-base>>
-    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }`,
-			expectedMap:      "d1.local#/ d1_app_8080",
-			expectedACLFront: aclFrontExact,
-			expectedSetvar:   setvarBegin,
-		},
-	}
-	for _, test := range testCases {
-		c := setup(t)
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    http-request set-var(req.backend_route) str(d_app-beta_8080) if { var(req.backend) -m str d_app_8080 } !{ var(req.backend_route) -m found } { req.hdr(X-Env) -m reg '^preview-' }
+    http-request set-var(req.backend_route) str(d_app-beta_8080) if { var(req.backend) -m str d_app_8080 } !{ var(req.backend_route) -m found } { urlp(version) -m str 'beta' 'preview' }
+    http-request set-var(req.backend_route) str(d_app-v2_8080) if { var(req.backend) -m str d_app_8080 } !{ var(req.backend_route) -m found } { urlp(version) -m reg '^v2\.' }
+    http-request set-var(req.backend_route) str(d_app-v2_8080) if { var(req.backend) -m str d_app_8080 } !{ var(req.backend_route) -m found } { method POST PUT }
+    http-request set-var(req.backend) var(req.backend_route) if { var(req.backend_route) -m found }
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    http-request set-var(req.path) path
+    http-request set-var(req.host) hdr(host),field(1,:),lower
+    http-request set-var(req.base) var(req.host),concat(\#,req.path)
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    http-request set-var(req.hostbackend_route) str(d_app-beta_8080) if { var(req.hostbackend) -m str d_app_8080 } !{ var(req.hostbackend_route) -m found } { req.hdr(X-Env) -m reg '^preview-' }
+    http-request set-var(req.hostbackend_route) str(d_app-beta_8080) if { var(req.hostbackend) -m str d_app_8080 } !{ var(req.hostbackend_route) -m found } { urlp(version) -m str 'beta' 'preview' }
+    http-request set-var(req.hostbackend_route) str(d_app-v2_8080) if { var(req.hostbackend) -m str d_app_8080 } !{ var(req.hostbackend_route) -m found } { urlp(version) -m reg '^v2\.' }
+    http-request set-var(req.hostbackend_route) str(d_app-v2_8080) if { var(req.hostbackend) -m str d_app_8080 } !{ var(req.hostbackend_route) -m found } { method POST PUT }
+    http-request set-var(req.hostbackend) var(req.hostbackend_route) if { var(req.hostbackend_route) -m found }
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
 
-		var h *hatypes.Host
-		var b *hatypes.Backend
+	c.logger.CompareLogging(defaultLogging)
+}
 
-		b = c.config.Backends().AcquireBackend("d1", "app", "8080")
-		h = c.config.Hosts().AcquireHost(test.domain)
-		h.AddPath(b, "/", hatypes.MatchBegin)
-		b.Endpoints = []*hatypes.Endpoint{endpointS1}
-		b.FindBackendPath(h.FindPath("/")[0].Link).SSLRedirect = test.sslRedirect
-		b.FindBackendPath(h.FindPath("/")[0].Link).HSTS = hatypes.HSTS{
-			Enabled:    true,
-			MaxAge:     15768000,
-			Subdomains: true,
-			Preload:    true,
-		}
-		h.TLS.CAHash = "1"
-		h.TLS.CAFilename = "/var/haproxy/ssl/ca.pem"
-		c.config.Global().Bind.FrontingBind = test.frontingBind
-		c.config.Global().Bind.FrontingSockID = 11
-		c.config.Global().Bind.FrontingUseProto = test.useProto
+func TestInstanceCustomFrontend(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
 
-		c.Update()
-		c.checkConfig(`
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	c.config.Global().CustomFrontend = []string{
+		"# new header",
+		"http-response set-header X-Server HAProxy",
+	}
+
+	c.Update()
+	c.checkConfig(`
 <<global>>
 <<defaults>>
 backend d1_app_8080
-    mode http` + test.expectedACLBack + `
-    acl local-offload ssl_fc` + test.expectedSetHeader + `
+    mode http
     server s1 172.17.0.11:8080 weight 100
 <<backends-default>>
-frontend _front_http` + test.expectedFront + `
+frontend _front_http
+    mode http
+    bind :80
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    # new header
+    http-response set-header X-Server HAProxy
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
     default_backend _error404
 frontend _front_https
     mode http
     bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
     <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
     http-request set-header X-Forwarded-Proto https
     http-request del-header X-SSL-Client-CN
     http-request del-header X-SSL-Client-DN
     http-request del-header X-SSL-Client-SHA1
-    http-request del-header X-SSL-Client-Cert` + test.expectedACLFront + test.expectedSetvar + `
-    http-request use-service lua.send-421 if tls-has-crt { ssl_fc_has_sni } !{ ssl_fc_sni,strcmp(req.host) eq 0 }
-    http-request use-service lua.send-496 if { var(req.tls_nocrt_redir) _internal }
-    http-request use-service lua.send-421 if !tls-has-crt tls-host-need-crt
-    http-request use-service lua.send-495 if { var(req.tls_invalidcrt_redir) _internal }
+    http-request del-header X-SSL-Client-Cert
+    # new header
+    http-response set-header X-Server HAProxy
     use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
-    use_backend %[var(req.snibackend)] if { var(req.snibackend) -m found }
     default_backend _error404
 <<support>>
 `)
-		if test.expectedMap != "" {
-			c.checkMap("_front_http_host__begin.map", test.expectedMap)
-		}
-		if test.expectedRegexMap != "" {
-			c.checkMap("_front_http_host__regex.map", test.expectedRegexMap)
-		}
-		c.logger.CompareLogging(defaultLogging)
-		c.teardown()
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceHTTP3(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	c.config.Global().HTTP3 = hatypes.HTTP3Config{
+		Enabled:        true,
+		Bind:           "quic4@:443",
+		AltSvcMaxAge:   3600,
+		AltSvcPort:     443,
+		MaxIdleTimeout: "30s",
+		MaxStreamsBidi: 50,
 	}
+
+	c.Update()
+	c.checkConfig(`
+global
+    daemon
+    unix-bind mode 0600
+    stats socket /var/run/haproxy.sock level admin expose-fd listeners mode 600
+    maxconn 2000
+    hard-stop-after 15m
+    lua-prepend-path /etc/haproxy/lua/?.lua
+    lua-load /etc/haproxy/lua/auth-request.lua
+    lua-load /etc/haproxy/lua/oidc.lua
+    lua-load /etc/haproxy/lua/mirror.lua
+    lua-load /etc/haproxy/lua/grpc-web.lua
+    lua-load /etc/haproxy/lua/services.lua
+    ssl-dh-param-file /var/haproxy/tls/dhparam.pem
+    tune.quic.frontend.max-idle-timeout 30s
+    tune.quic.frontend.max-streams-bidi 50
+    ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-bind-ciphersuites TLS_AES_128_GCM_SHA256
+    ssl-default-bind-options no-sslv3
+    ssl-default-server-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-server-ciphersuites TLS_AES_128_GCM_SHA256
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+<<frontend-http>>
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    bind quic4@:443 ssl alpn h3 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    <<https-headers>>
+    http-response set-header alt-svc "h3=\":443\"; ma=3600"
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceTCPServices(t *testing.T) {
+func TestInstanceExtraFrontends(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
@@ -1798,140 +4252,127 @@ func TestInstanceTCPServices(t *testing.T) {
 	b.Endpoints = []*hatypes.Endpoint{endpointS1}
 	h = c.config.Hosts().AcquireHost("d1.local")
 	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSHash = "1"
+	h.TLS.TLSFilename = "/var/haproxy/ssl/d1.pem"
 
-	b2 := c.config.Backends().AcquireBackend("d2", "app", "8080")
-	b2.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
-	b3 := c.config.Backends().AcquireBackend("d3", "app", "8080")
-	b3.Endpoints = []*hatypes.Endpoint{endpointS31, endpointS32}
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.UseDefaultCrt = true
+	h.Frontend = "internal"
 
-	services := []struct {
-		port      int
-		hostname  string
-		backend   hatypes.BackendID
-		proxyProt bool
-		tls       hatypes.TLSConfig
-		custom    []string
-	}{
-		{
-			port: 7000,
-		},
-		{
-			port:    7001,
-			backend: b.BackendID(),
-		},
-		{
-			port:      7002,
-			backend:   b.BackendID(),
-			proxyProt: true,
-		},
-		{
-			port:      7003,
-			backend:   b.BackendID(),
-			proxyProt: true,
-			tls: hatypes.TLSConfig{
-				TLSFilename: "/ssl/7003.pem",
-			},
-		},
-		{
-			port:    7004,
-			backend: b.BackendID(),
-			tls: hatypes.TLSConfig{
-				TLSFilename: "/ssl/7004.pem",
-			},
-		},
-		{
-			port:    7005,
-			backend: b.BackendID(),
-			tls: hatypes.TLSConfig{
-				TLSFilename: "/ssl/7005.pem",
-				CAFilename:  "/ssl/ca-7005.pem",
-			},
-		},
-		{
-			port:    7006,
-			backend: b.BackendID(),
-			tls: hatypes.TLSConfig{
-				TLSFilename: "/ssl/7006.pem",
-				CAFilename:  "/ssl/ca-7006.pem",
-				CRLFilename: "/ssl/crl-7006.pem",
-			},
-		},
-		{
-			port:    7007,
-			backend: b.BackendID(),
-			tls: hatypes.TLSConfig{
-				ALPN:        "h2,http/1.1",
-				TLSFilename: "/ssl/7007.pem",
-			},
-		},
-		{
-			port:    7008,
-			backend: b.BackendID(),
-			tls: hatypes.TLSConfig{
-				TLSFilename:      "/ssl/7008.pem",
-				CAFilename:       "/ssl/ca-7008.pem",
-				CAVerifyOptional: true,
-			},
-		},
-		{
-			port:    7009,
-			backend: b.BackendID(),
-			tls: hatypes.TLSConfig{
-				TLSFilename:  "/ssl/7009.pem",
-				Ciphers:      "ECDHE-ECDSA-AES128-GCM-SHA256",
-				CipherSuites: "TLS_AES_128_GCM_SHA256",
-			},
-		},
-		{
-			port:    7010,
-			backend: b.BackendID(),
-			tls: hatypes.TLSConfig{
-				TLSFilename: "/ssl/7010.pem",
-				Options:     "force-tlsv13",
-			},
-		},
-		{
-			port:    7011,
-			backend: b.BackendID(),
-		},
-		{
-			port:     7011,
-			hostname: "local2",
-			backend:  b2.BackendID(),
-		},
+	c.config.Frontend().Extras = []*hatypes.ExtraFrontend{
 		{
-			port:     7011,
-			hostname: "local3",
-			backend:  b3.BackendID(),
+			Name:           "internal",
+			HTTPBind:       "127.0.0.1:8080",
+			HTTPSBind:      "127.0.0.1:8443",
+			DefaultCrtFile: "/var/haproxy/ssl/internal.pem",
 		},
 		{
-			port:     7011,
-			hostname: "*.local4",
-			backend:  b3.BackendID(),
+			Name:      "tls-only",
+			HTTPSBind: ":9443",
 		},
+	}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+<<frontend-http>>
+    default_backend _error404
+<<frontend-https>>
+    default_backend _error404
+frontend _front_http_internal
+    mode http
+    bind 127.0.0.1:8080
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_internal_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https_internal
+    mode http
+    bind 127.0.0.1:8443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_internal_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_internal_https_host__begin.map)
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+frontend _front_https_tls-only
+    mode http
+    bind :9443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_tls-only_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+
+	c.checkMap("_front_http_host__begin.map", `
+d1.local#/ d1_app_8080
+`)
+	c.checkMap("_front_https_host__begin.map", `
+d1.local#/ d1_app_8080
+`)
+	c.checkMap("_front_bind_crt.list", `
+/var/haproxy/ssl/certs/default.pem !*
+/var/haproxy/ssl/d1.pem d1.local
+`)
+	c.checkMap("_front_internal_http_host__begin.map", `
+d2.local#/ d2_app_8080
+`)
+	c.checkMap("_front_internal_https_host__begin.map", `
+d2.local#/ d2_app_8080
+`)
+	c.checkMap("_front_internal_bind_crt.list", `
+/var/haproxy/ssl/internal.pem !*
+`)
+	c.checkMap("_front_tls-only_bind_crt.list", `
+/var/haproxy/ssl/certs/default.pem !*
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceExtraFrontendsSyslog(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.Frontend = "team-a"
+
+	c.config.Frontend().Extras = []*hatypes.ExtraFrontend{
 		{
-			port:    7012,
-			backend: b.BackendID(),
-			custom:  []string{"## custom for TCP 7012"},
+			Name:           "team-a",
+			HTTPBind:       ":8080",
+			SyslogEndpoint: "10.0.0.10:514",
+			HTTPLogFormat:  "%ci:%cp %ft %b/%s %ST %B",
 		},
 		{
-			port:    7013,
-			backend: b.BackendID(),
-			custom:  []string{"## custom for TCP 7013", "## multi line"},
+			Name:     "team-b",
+			HTTPBind: ":9080",
 		},
 	}
-
-	for _, svc := range services {
-		hostname := svc.hostname
-		if hostname == "" {
-			hostname = hatypes.DefaultHost
-		}
-		p, h := c.config.TCPServices().AcquireTCPService(fmt.Sprintf("%s:%d", hostname, svc.port))
-		p.ProxyProt = svc.proxyProt
-		p.TLS = svc.tls
-		p.CustomConfig = svc.custom
-		h.Backend = svc.backend
-	}
+	syslog := &c.config.Global().Syslog
+	syslog.Format = "rfc5424"
+	syslog.Length = 1024
 
 	c.Update()
 	c.checkConfig(`
@@ -1943,346 +4384,347 @@ backend d1_app_8080
 backend d2_app_8080
     mode http
     server s21 172.17.0.121:8080 weight 100
-    server s22 172.17.0.122:8080 weight 100
-backend d3_app_8080
-    mode http
-    server s31 172.17.0.131:8080 weight 100
-    server s32 172.17.0.132:8080 weight 100
 <<backends-default>>
-frontend _front_tcp_7000
-    bind :7000
-    mode tcp
-frontend _front_tcp_7001
-    bind :7001
-    mode tcp
-    default_backend d1_app_8080
-frontend _front_tcp_7002
-    bind :7002 accept-proxy
-    mode tcp
-    default_backend d1_app_8080
-frontend _front_tcp_7003
-    bind :7003 accept-proxy ssl crt /ssl/7003.pem
-    mode tcp
-    default_backend d1_app_8080
-frontend _front_tcp_7004
-    bind :7004 ssl crt /ssl/7004.pem
-    mode tcp
-    default_backend d1_app_8080
-frontend _front_tcp_7005
-    bind :7005 ssl crt /ssl/7005.pem ca-file /ssl/ca-7005.pem verify required
-    mode tcp
-    default_backend d1_app_8080
-frontend _front_tcp_7006
-    bind :7006 ssl crt /ssl/7006.pem ca-file /ssl/ca-7006.pem verify required crl-file /ssl/crl-7006.pem
-    mode tcp
-    default_backend d1_app_8080
-frontend _front_tcp_7007
-    bind :7007 ssl crt /ssl/7007.pem alpn h2,http/1.1
-    mode tcp
-    default_backend d1_app_8080
-frontend _front_tcp_7008
-    bind :7008 ssl crt /ssl/7008.pem ca-file /ssl/ca-7008.pem verify optional
-    mode tcp
-    default_backend d1_app_8080
-frontend _front_tcp_7009
-    bind :7009 ssl crt /ssl/7009.pem ciphers ECDHE-ECDSA-AES128-GCM-SHA256 ciphersuites TLS_AES_128_GCM_SHA256
-    mode tcp
-    default_backend d1_app_8080
-frontend _front_tcp_7010
-    bind :7010 ssl crt /ssl/7010.pem force-tlsv13
-    mode tcp
-    default_backend d1_app_8080
-frontend _front_tcp_7011
-    bind :7011
-    mode tcp
-    tcp-request inspect-delay 5s
-    tcp-request content set-var(req.tcpback) req.ssl_sni,lower,map_str(/etc/haproxy/maps/_tcp_sni_7011__exact.map)
-    tcp-request content set-var(req.tcpback) req.ssl_sni,lower,map_reg(/etc/haproxy/maps/_tcp_sni_7011__regex.map) if !{ var(req.tcpback) -m found }
-    tcp-request content accept if { req.ssl_hello_type 1 }
-    use_backend %[var(req.tcpback)] if { var(req.tcpback) -m found }
-    default_backend d1_app_8080
-frontend _front_tcp_7012
-    bind :7012
-    mode tcp
-    ## custom for TCP 7012
-    default_backend d1_app_8080
-frontend _front_tcp_7013
-    bind :7013
-    mode tcp
-    ## custom for TCP 7013
-    ## multi line
-    default_backend d1_app_8080
 <<frontends-default>>
+frontend _front_http_team-a
+    mode http
+    bind :8080
+    no log
+    log 10.0.0.10:514 len 1024 format rfc5424 local0
+    log-format %ci:%cp %ft %b/%s %ST %B
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_team-a_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_http_team-b
+    mode http
+    bind :9080
+    <<set-req-base>>
+    <<http-headers>>
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
 <<support>>
 `)
-	c.checkMap("_tcp_sni_7011__exact.map", `
-local2 d2_app_8080
-local3 d3_app_8080`)
-	c.checkMap("_tcp_sni_7011__regex.map", `
-^[^.]+\.local4$ d3_app_8080`)
 	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceTCPBackend(t *testing.T) {
-	testCases := []struct {
-		doconfig func(c *testConfig)
-		expected string
-		logging  string
-	}{
-		// 0
-		{
-			doconfig: func(c *testConfig) {
-				b := c.config.TCPBackends().Acquire("postgresql", 5432)
-				b.AddEndpoint("172.17.0.2", 5432)
-			},
-			expected: `
-listen _tcp_postgresql_5432
-    bind :5432
-    mode tcp
-    server srv001 172.17.0.2:5432`,
-		},
-		// 1
+func TestInstanceLogForward(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	c.config.Global().LogForward = []*hatypes.LogForward{
 		{
-			doconfig: func(c *testConfig) {
-				b := c.config.TCPBackends().Acquire("pq", 5432)
-				b.AddEndpoint("172.17.0.2", 5432)
-				b.AddEndpoint("172.17.0.3", 5432)
-				b.CheckInterval = "2s"
-			},
-			expected: `
-listen _tcp_pq_5432
-    bind :5432
-    mode tcp
-    server srv001 172.17.0.2:5432 check port 5432 inter 2s
-    server srv002 172.17.0.3:5432 check port 5432 inter 2s`,
+			Name:       "syslog",
+			DgramBinds: []string{"udp@:514"},
+			Binds:      []string{":601"},
+			Targets:    []string{"udp@10.0.0.10:514", "tcp@10.0.0.11:601"},
 		},
-		// 2
 		{
-			doconfig: func(c *testConfig) {
-				b := c.config.TCPBackends().Acquire("pq", 5432)
-				b.AddEndpoint("172.17.0.2", 5432)
-				b.SSL.Filename = "/var/haproxy/ssl/pq.pem"
-				b.ProxyProt.EncodeVersion = "v2"
-			},
-			expected: `
-listen _tcp_pq_5432
-    bind :5432 ssl crt /var/haproxy/ssl/pq.pem
-    mode tcp
-    server srv001 172.17.0.2:5432 send-proxy-v2`,
+			Name:       "audit",
+			DgramBinds: []string{"udp@:1514"},
+			Targets:    []string{"udp@10.0.0.12:514"},
 		},
-		// 3
+	}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+log-forward _logfwd_syslog
+    dgram-bind udp@:514
+    bind :601
+    timeout client 50s
+    log udp@10.0.0.10:514
+    log tcp@10.0.0.11:601
+log-forward _logfwd_audit
+    dgram-bind udp@:1514
+    log udp@10.0.0.12:514
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceUDPServices(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	c.config.Global().UDPServices = []*hatypes.UDPService{
 		{
-			doconfig: func(c *testConfig) {
-				b := c.config.TCPBackends().Acquire("pq", 5432)
-				b.AddEndpoint("172.17.0.2", 5432)
-				b.SSL.Filename = "/var/haproxy/ssl/pq.pem"
-				b.ProxyProt.Decode = true
-				b.ProxyProt.EncodeVersion = "v1"
-				b.CheckInterval = "2s"
-				c.config.Global().Bind.TCPBindIP = "127.0.0.1"
-			},
-			expected: `
-listen _tcp_pq_5432
-    bind 127.0.0.1:5432 ssl crt /var/haproxy/ssl/pq.pem accept-proxy
-    mode tcp
-    server srv001 172.17.0.2:5432 check port 5432 inter 2s send-proxy`,
+			Name:       "default_syslog",
+			Port:       1514,
+			DgramBinds: []string{"udp@:1514", "udp@127.0.0.1:2514"},
+			Targets:    []string{"udp@172.17.0.101:514 sample 1:2", "udp@172.17.0.102:514 sample 2:2"},
 		},
-		// 4
+	}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+log-forward _udpsvc_default_syslog
+    dgram-bind udp@:1514
+    dgram-bind udp@127.0.0.1:2514
+    log udp@172.17.0.101:514 sample 1:2
+    log udp@172.17.0.102:514 sample 2:2
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceLogRing(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	c.config.Global().LogRings = []*hatypes.LogRing{
 		{
-			doconfig: func(c *testConfig) {
-				b := c.config.TCPBackends().Acquire("pq", 5432)
-				b.AddEndpoint("172.17.0.2", 5432)
-				b.SSL.Filename = "/var/haproxy/ssl/pq.pem"
-				b.SSL.CAFilename = "/var/haproxy/ssl/pqca.pem"
-				b.ProxyProt.EncodeVersion = "v2"
+			Name: "logs",
+			Servers: []*hatypes.LogRingServer{
+				{Name: "srv01", Endpoint: "10.0.0.10:6514"},
+				{Name: "srv02", Endpoint: "10.0.0.11:6514"},
 			},
-			expected: `
-listen _tcp_pq_5432
-    bind :5432 ssl crt /var/haproxy/ssl/pq.pem ca-file /var/haproxy/ssl/pqca.pem verify required
-    mode tcp
-    server srv001 172.17.0.2:5432 send-proxy-v2`,
+			Size: 1048576,
 		},
-		// 5
 		{
-			doconfig: func(c *testConfig) {
-				b := c.config.TCPBackends().Acquire("pq", 5432)
-				b.AddEndpoint("172.17.0.2", 5432)
-				b.SSL.Filename = "/var/haproxy/ssl/pq.pem"
-				b.SSL.CAFilename = "/var/haproxy/ssl/pqca.pem"
-				b.SSL.CRLFilename = "/var/haproxy/ssl/pqcrl.pem"
-				b.ProxyProt.EncodeVersion = "v2"
+			Name: "audit",
+			Servers: []*hatypes.LogRingServer{
+				{Name: "srv01", Endpoint: "10.0.0.12:601"},
 			},
-			expected: `
-listen _tcp_pq_5432
-    bind :5432 ssl crt /var/haproxy/ssl/pq.pem ca-file /var/haproxy/ssl/pqca.pem verify required crl-file /var/haproxy/ssl/pqcrl.pem
-    mode tcp
-    server srv001 172.17.0.2:5432 send-proxy-v2`,
 		},
 	}
-	for _, test := range testCases {
-		c := setup(t)
-		test.doconfig(c)
-		c.Update()
-		c.checkConfig(`
-<<global>>
-<<defaults>>` + test.expected + `
-backend _error404
+	syslog := &c.config.Global().Syslog
+	syslog.Endpoint = "ring@logs"
+	syslog.Format = "rfc5424"
+	syslog.Length = 2048
+	syslog.Tag = "ingress"
+
+	c.Update()
+	c.checkConfig(`
+global
+    daemon
+    unix-bind mode 0600
+    stats socket /var/run/haproxy.sock level admin expose-fd listeners mode 600
+    maxconn 2000
+    hard-stop-after 15m
+    log ring@logs len 2048 format rfc5424 local0
+    log-tag ingress
+    lua-prepend-path /etc/haproxy/lua/?.lua
+    lua-load /etc/haproxy/lua/auth-request.lua
+    lua-load /etc/haproxy/lua/oidc.lua
+    lua-load /etc/haproxy/lua/mirror.lua
+    lua-load /etc/haproxy/lua/grpc-web.lua
+    lua-load /etc/haproxy/lua/services.lua
+    ssl-dh-param-file /var/haproxy/tls/dhparam.pem
+    ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-bind-ciphersuites TLS_AES_128_GCM_SHA256
+    ssl-default-bind-options no-sslv3
+    ssl-default-server-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-server-ciphersuites TLS_AES_128_GCM_SHA256
+<<defaults>>
+ring logs
+    format rfc5424
+    maxlen 2048
+    size 1048576
+    timeout connect 5s
+    timeout server 10s
+    server srv01 10.0.0.10:6514 log-proto octet-count
+    server srv02 10.0.0.11:6514 log-proto octet-count
+ring audit
+    format rfc5424
+    maxlen 2048
+    timeout connect 5s
+    timeout server 10s
+    server srv01 10.0.0.12:601 log-proto octet-count
+backend d1_app_8080
     mode http
-    http-request use-service lua.send-404
-<<frontend-http-clean>>
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    option httplog
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
     default_backend _error404
-<<frontend-https-clean>>
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    option httplog
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
     default_backend _error404
 <<support>>
 `)
-		logging := test.logging
-		if logging == "" {
-			logging = defaultLogging
-		}
-		c.logger.CompareLogging(logging)
-		c.teardown()
-	}
+	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceDefaultHost(t *testing.T) {
+func TestInstancePeers(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
-	def := c.config.Backends().AcquireBackend("default", "default-backend", "8080")
-	def.Endpoints = []*hatypes.Endpoint{endpointS0}
-	c.config.Backends().DefaultBackend = def
-
 	var h *hatypes.Host
 	var b *hatypes.Backend
-	hdef := c.config.Hosts().AcquireHost(hatypes.DefaultHost)
 
 	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
 	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b.Limit.DenyStatus = 429
+	b.Limit.RPS = 20
 	h = c.config.Hosts().AcquireHost("d1.local")
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
-	h.VarNamespace = true
-	hdef.AddPath(b, "/", hatypes.MatchBegin)
-	hdef.AddPath(b, "/app1", hatypes.MatchExact)
-	hdef.AddPath(b, "/app2", hatypes.MatchPrefix)
-	b.FindBackendPath(hdef.FindPath("/")[0].Link).SSLRedirect = true
-	b.FindBackendPath(hdef.FindPath("/app1")[0].Link).RewriteURL = "/"
-	b.FindBackendPath(hdef.FindPath("/app2")[0].Link).MaxBodySize = 32768
-
-	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
-	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-	h = c.config.Hosts().AcquireHost("d2.local")
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
-	h.VarNamespace = true
-	h.AddPath(b, "/app11", hatypes.MatchBegin)
-	hdef.AddPath(b, "/app12", hatypes.MatchExact)
-	hdef.AddPath(b, "/app13", hatypes.MatchPrefix)
-	b.FindBackendPath(h.FindPath("/app11")[0].Link).SSLRedirect = true
-	b.FindBackendPath(hdef.FindPath("/app12")[0].Link).RewriteURL = "/"
-	b.FindBackendPath(hdef.FindPath("/app13")[0].Link).MaxBodySize = 65536
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	c.config.Global().Peers = hatypes.PeersConfig{
+		LocalPeer: "ingress-0",
+		Port:      10000,
+		Servers: []*hatypes.PeersServer{
+			{Name: "ingress-1", Endpoint: "10.0.0.11:10000"},
+			{Name: "ingress-2", Endpoint: "10.0.0.12:10000"},
+		},
+	}
 
 	c.Update()
 	c.checkConfig(`
-<<global>>
+global
+    daemon
+    unix-bind mode 0600
+    stats socket /var/run/haproxy.sock level admin expose-fd listeners mode 600
+    maxconn 2000
+    localpeer ingress-0
+    hard-stop-after 15m
+    lua-prepend-path /etc/haproxy/lua/?.lua
+    lua-load /etc/haproxy/lua/auth-request.lua
+    lua-load /etc/haproxy/lua/oidc.lua
+    lua-load /etc/haproxy/lua/mirror.lua
+    lua-load /etc/haproxy/lua/grpc-web.lua
+    lua-load /etc/haproxy/lua/services.lua
+    ssl-dh-param-file /var/haproxy/tls/dhparam.pem
+    ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-bind-ciphersuites TLS_AES_128_GCM_SHA256
+    ssl-default-bind-options no-sslv3
+    ssl-default-server-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-server-ciphersuites TLS_AES_128_GCM_SHA256
 <<defaults>>
+peers ingress
+    bind :10000
+    server ingress-0
+    server ingress-1 10.0.0.11:10000
+    server ingress-2 10.0.0.12:10000
 backend d1_app_8080
     mode http
-    acl https-request ssl_fc
-    # path01 = <default>/
-    # path02 = <default>/app1
-    # path03 = <default>/app2
-    http-request set-var(txn.pathID) var(req.path),map_str(/etc/haproxy/maps/_back_d1_app_8080_idpathdef__exact.map)
-    http-request set-var(txn.pathID) var(req.path),map_dir(/etc/haproxy/maps/_back_d1_app_8080_idpathdef__prefix_02.map) if !{ var(txn.pathID) -m found }
-    http-request set-var(txn.pathID) var(req.path),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpathdef__begin.map) if !{ var(txn.pathID) -m found }
-    http-request redirect scheme https if !https-request { var(txn.pathID) path01 }
-    http-request use-service lua.send-413 if { var(txn.pathID) path03 } { req.body_size,sub(32768) gt 0 }
-    http-request replace-path ^/app1/?(.*)$     /\1     if { var(txn.pathID) path02 }
-    server s1 172.17.0.11:8080 weight 100
-backend d2_app_8080
-    mode http
-    acl https-request ssl_fc
-    # path02 = <default>/app12
-    # path03 = <default>/app13
-    # path01 = d2.local/app11
-    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d2_app_8080_idpath__begin.map)
-    http-request set-var(txn.pathID) var(req.path),map_str(/etc/haproxy/maps/_back_d2_app_8080_idpathdef__exact.map) if !{ var(txn.pathID) -m found }
-    http-request set-var(txn.pathID) var(req.path),map_dir(/etc/haproxy/maps/_back_d2_app_8080_idpathdef__prefix.map) if !{ var(txn.pathID) -m found }
-    http-request redirect scheme https if !https-request { var(txn.pathID) path01 }
-    http-request use-service lua.send-413 if { var(txn.pathID) path03 } { req.body_size,sub(65536) gt 0 }
-    http-request replace-path ^/app12/?(.*)$     /\1     if { var(txn.pathID) path02 }
+    stick-table type ip size 200k expire 5m peers ingress store conn_cur,conn_rate(1s)
+    http-request track-sc1 src
+    http-request deny deny_status 429 if { sc1_conn_rate gt 20 }
     server s1 172.17.0.11:8080 weight 100
-backend default_default-backend_8080
-    mode http
-    server s0 172.17.0.99:8080 weight 100
+<<backends-default>>
 frontend _front_http
     mode http
     bind :80
     <<set-req-base>>
-    http-request set-var(txn.namespace) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_namespace__begin.map)
-    http-request set-var(txn.namespace) str(-) if !{ var(txn.namespace) -m found }
     <<http-headers>>
     http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    http-request set-var(req.defaultbackend) var(req.path),map_str(/etc/haproxy/maps/_front_defaulthost__exact.map) if !{ var(req.backend) -m found }
-    http-request set-var(req.defaultbackend) var(req.path),map_dir(/etc/haproxy/maps/_front_defaulthost__prefix_02.map) if !{ var(req.backend) -m found } !{ var(req.defaultbackend) -m found }
-    http-request set-var(req.defaultbackend) var(req.path),lower,map_beg(/etc/haproxy/maps/_front_defaulthost__begin.map) if !{ var(req.backend) -m found } !{ var(req.defaultbackend) -m found }
     use_backend %[var(req.backend)] if { var(req.backend) -m found }
-    use_backend %[var(req.defaultbackend)]
-    default_backend default_default-backend_8080
+    default_backend _error404
 frontend _front_https
     mode http
     bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
     <<set-req-base>>
     http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
-    http-request set-var(req.defaultbackend) var(req.path),map_str(/etc/haproxy/maps/_front_defaulthost__exact.map) if !{ var(req.hostbackend) -m found }
-    http-request set-var(req.defaultbackend) var(req.path),map_dir(/etc/haproxy/maps/_front_defaulthost__prefix_02.map) if !{ var(req.hostbackend) -m found } !{ var(req.defaultbackend) -m found }
-    http-request set-var(req.defaultbackend) var(req.path),lower,map_beg(/etc/haproxy/maps/_front_defaulthost__begin.map) if !{ var(req.hostbackend) -m found } !{ var(req.defaultbackend) -m found }
-    http-request set-var(txn.namespace) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_namespace__begin.map)
-    http-request set-var(txn.namespace) str(-) if !{ var(txn.namespace) -m found }
     <<https-headers>>
     use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
-    use_backend %[var(req.defaultbackend)]
-    default_backend default_default-backend_8080
+    default_backend _error404
 <<support>>
 `)
+	c.logger.CompareLogging(defaultLogging)
+}
 
-	c.checkMap("_front_http_host__begin.map", `
-d2.local#/app11 d2_app_8080
-`)
-	c.checkMap("_front_bind_crt.list", `
-/var/haproxy/ssl/certs/default.pem !*
-`)
-	c.checkMap("_front_namespace__begin.map", `
-d2.local#/app11 d2
-`)
-	c.checkMap("_front_https_host__begin.map", `
-d2.local#/app11 d2_app_8080
-`)
-	c.checkMap("_front_defaulthost__prefix_02.map", `
-/app2 d1_app_8080
-/app13 d2_app_8080`)
-	c.checkMap("_front_defaulthost__exact.map", `
-/app1 d1_app_8080
-/app12 d2_app_8080`)
-	c.checkMap("_front_defaulthost__begin.map", `
-/ d1_app_8080`)
-	c.checkMap("_back_d1_app_8080_idpathdef__exact.map", `
-/app1 path02`)
-	c.checkMap("_back_d1_app_8080_idpathdef__prefix_02.map", `
-/app2 path03`)
-	c.checkMap("_back_d1_app_8080_idpathdef__begin.map", `
-/ path01`)
-	c.checkMap("_back_d2_app_8080_idpathdef__exact.map", `
-/app12 path02`)
-	c.checkMap("_back_d2_app_8080_idpathdef__prefix.map", `
-/app13 path03`)
-	c.checkMap("_back_d2_app_8080_idpath__begin.map", `
-d2.local#/app11 path01`)
+func TestInstanceStickAffinity(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b.Stick = hatypes.StickConfig{
+		Expire: "30m",
+		Match:  "req.cook(JSESSIONID)",
+		Size:   "100k",
+		Store:  "res.cook(JSESSIONID)",
+		Type:   "string",
+	}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
 
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+	b.Stick = hatypes.StickConfig{
+		Expire: "1h",
+		Match:  "src",
+		Size:   "1m",
+		Type:   "ip",
+	}
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend _stick_d1_app_8080
+    stick-table type string len 128 size 100k expire 30m
+backend d1_app_8080
+    mode http
+    stick match req.cook(JSESSIONID) table _stick_d1_app_8080
+    stick store-response res.cook(JSESSIONID) table _stick_d1_app_8080
+    server s1 172.17.0.11:8080 weight 100
+backend _stick_d2_app_8080
+    stick-table type ip size 1m expire 1h
+backend d2_app_8080
+    mode http
+    stick on src table _stick_d2_app_8080
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
 	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceUseDefaultCrt(t *testing.T) {
+func TestInstanceCustomSections(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
@@ -2292,39 +4734,61 @@ func TestInstanceUseDefaultCrt(t *testing.T) {
 	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
 	b.Endpoints = []*hatypes.Endpoint{endpointS1}
 	h = c.config.Hosts().AcquireHost("d1.local")
-	h.TLS.UseDefaultCrt = false
 	h.AddPath(b, "/", hatypes.MatchBegin)
 
-	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
-	b.Endpoints = []*hatypes.Endpoint{endpointS21}
-	h = c.config.Hosts().AcquireHost("d2.local")
-	h.TLS.UseDefaultCrt = true
-	h.AddPath(b, "/", hatypes.MatchBegin)
+	c.config.Global().CustomSections = []string{
+		"cache icons",
+		"	total-max-size 4",
+		"	max-age 240",
+	}
 
 	c.Update()
 	c.checkConfig(`
 <<global>>
 <<defaults>>
+cache icons
+	total-max-size 4
+	max-age 240
 backend d1_app_8080
     mode http
     server s1 172.17.0.11:8080 weight 100
-backend d2_app_8080
-    mode http
-    server s21 172.17.0.121:8080 weight 100
 <<backends-default>>
 <<frontends-default>>
 <<support>>
 `)
-	c.checkMap("_front_http_host__begin.map", `
-d1.local#/ d1_app_8080
-d2.local#/ d2_app_8080
-`)
-	c.checkMap("_front_https_host__begin.map", `
-d2.local#/ d2_app_8080
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceCustomTCP(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	tcp := c.config.TCPBackends().Acquire("default_postgresql", 5432)
+	tcp.AddEndpoint("172.17.0.11", 5432)
+
+	c.config.global.CustomTCP = []string{"## custom for TCP", "## multi line"}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+listen _tcp_default_postgresql_5432
+    bind :5432
+    mode tcp
+    ## custom for TCP
+    ## multi line
+    server srv001 172.17.0.11:5432
+<<backends-default>>
+<<frontend-http-clean>>
+    default_backend _error404
+<<frontend-https-clean>>
+    default_backend _error404
+<<support>>
 `)
 	c.logger.CompareLogging(defaultLogging)
 }
-func TestInstanceStrictHost(t *testing.T) {
+
+func TestInstanceCustomProxy(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
@@ -2334,32 +4798,244 @@ func TestInstanceStrictHost(t *testing.T) {
 	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
 	b.Endpoints = []*hatypes.Endpoint{endpointS1}
 	h = c.config.Hosts().AcquireHost("d1.local")
-	h.AddPath(b, "/path", hatypes.MatchBegin)
-	c.config.Global().StrictHost = true
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.SetSSLPassthrough(true)
+
+	auth := &c.config.Frontend().AuthProxy
+	auth.Name = "_front__auth"
+	auth.RangeStart = 4001
+	auth.RangeEnd = 4010
+	authBackend := c.config.Backends().AcquireAuthBackend([]string{"172.17.100.11"}, 5000, "")
+	_, _ = c.config.Frontend().AcquireAuthBackendName(authBackend.BackendID())
+
+	tcp := c.config.tcpbackends.Acquire("default_pgsql", 5432)
+	tcp.AddEndpoint("172.17.0.21", 5432)
+
+	_, tcpHost := c.config.tcpservices.AcquireTCPService(hatypes.DefaultHost + ":7001")
+	tcpHost.Backend = b.BackendID()
+
+	c.config.Global().CustomProxy = map[string][]string{
+		"missing":                 {"## comment"},
+		"_tcp_default_pgsql_5432": {"## custom for _tcp_default_pgsql_5432"},
+		"_auth_backend001_5000":   {"## custom for _auth_backend001_5000"},
+		"d1_app_8080":             {"## custom for d1_app_8080"},
+		"_redirect_https":         {"## custom for _redirect_https"},
+		"_error404":               {"## custom for _error404", "## line 2"},
+		"_auth_4001":              {"## custom for _auth_4001"},
+		"_front__auth":            {"## custom for _front__auth"},
+		"_front_tcp_7001":         {"## custom for _front_tcp_7001"},
+		"_front__tls":             {"## custom for _front__tls"},
+		"_front_http":             {"## custom for _front_http"},
+		"_front_https":            {"## custom for _front_https"},
+		"stats":                   {"## custom for stats"},
+		"healthz":                 {"## custom for healthz"},
+	}
 
 	c.Update()
 	c.checkConfig(`
 <<global>>
 <<defaults>>
+listen _tcp_default_pgsql_5432
+    bind :5432
+    mode tcp
+    ## custom for _tcp_default_pgsql_5432
+    server srv001 172.17.0.21:5432
+backend _auth_backend001_5000
+    mode http
+    ## custom for _auth_backend001_5000
+    server srv001 172.17.100.11:5000 weight 1
 backend d1_app_8080
     mode http
-    server s1 172.17.0.11:8080 weight 100
+    ## custom for d1_app_8080
+    server s1 172.17.0.11:8080 weight 100
+backend _redirect_https
+    mode http
+    ## custom for _redirect_https
+    http-request redirect scheme https
+backend _error404
+    mode http
+    ## custom for _error404
+    ## line 2
+    http-request use-service lua.send-404
+backend _auth_4001
+    mode http
+    ## custom for _auth_4001
+    server _auth_4001 127.0.0.1:4001
+frontend _front__auth
+    mode http
+    bind 127.0.0.1:4001
+    ## custom for _front__auth
+    use_backend _auth_backend001_5000
+frontend _front_tcp_7001
+    bind :7001
+    mode tcp
+    ## custom for _front_tcp_7001
+    default_backend d1_app_8080
+listen _front__tls
+    mode tcp
+    bind :443
+    tcp-request inspect-delay 5s
+    tcp-request content set-var(req.sslpassback) req.ssl_sni,lower,map_str(/etc/haproxy/maps/_front_sslpassthrough__exact.map)
+    ## custom for _front__tls
+    tcp-request content accept if { req.ssl_hello_type 1 }
+    use_backend %[var(req.sslpassback)] if { var(req.sslpassback) -m found }
+    server _default_server_https_socket unix@/var/run/haproxy/_https_socket.sock send-proxy-v2
+frontend _front_http
+    mode http
+    bind :80
+    http-request set-var(req.path) path
+    http-request set-var(req.host) hdr(host),field(1,:),lower
+    http-request set-var(req.base) var(req.host),concat(\#,req.path)
+    http-request set-header X-Forwarded-Proto http
+    http-request del-header X-SSL-Client-CN
+    http-request del-header X-SSL-Client-DN
+    http-request del-header X-SSL-Client-SHA1
+    http-request del-header X-SSL-Client-Cert
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    ## custom for _front_http
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind unix@/var/run/haproxy/_https_socket.sock accept-proxy ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    http-request set-header X-Forwarded-Proto https
+    http-request del-header X-SSL-Client-CN
+    http-request del-header X-SSL-Client-DN
+    http-request del-header X-SSL-Client-SHA1
+    http-request del-header X-SSL-Client-Cert
+    ## custom for _front_https
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+listen stats
+    mode http
+    bind :1936
+    stats enable
+    stats uri /
+    no log
+    option httpclose
+    stats show-legends
+    ## custom for stats
+frontend healthz
+    mode http
+    bind :10253
+    monitor-uri /healthz
+    http-request use-service lua.send-404
+    no log
+    ## custom for healthz
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceSSLPassthrough(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/")[0].Link).SSLRedirect = true
+	b.Endpoints = []*hatypes.Endpoint{endpointS31}
+	// TODO should ingress converter configure mode tcp?
+	b.ModeTCP = true
+	h.SetSSLPassthrough(true)
+
+	b = c.config.Backends().AcquireBackend("d3", "app-ssl", "8443")
+	h = c.config.Hosts().AcquireHost("d3.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.Endpoints = []*hatypes.Endpoint{endpointS41s}
+	b.ModeTCP = true
+	h.SetSSLPassthrough(true)
+
+	b = c.config.Backends().AcquireBackend("d3", "app1-http", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS41h}
+	h.HTTPPassthroughBackend = b.ID
+
+	b = c.config.Backends().AcquireBackend("d3", "app2-http", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS41h}
+	h.AddPath(b, "/app", hatypes.MatchBegin)
+
+	b = c.config.Backends().AcquireBackend("d4", "app4-ssl", "8443")
+	h = c.config.Hosts().AcquireHost(hatypes.DefaultHost)
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.Endpoints = []*hatypes.Endpoint{endpointS41s}
+	b.ModeTCP = true
+	h.SetSSLPassthrough(true)
+
+	b = c.config.Backends().AcquireBackend("d4", "app4-http", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS41h}
+	h.HTTPPassthroughBackend = b.ID
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d2_app_8080
+    mode tcp
+    server s31 172.17.0.131:8080 weight 100
+backend d3_app-ssl_8443
+    mode tcp
+    server s41s 172.17.0.141:8443 weight 100
+backend d3_app1-http_8080
+    mode http
+    server s41h 172.17.0.141:8080 weight 100
+backend d3_app2-http_8080
+    mode http
+    server s41h 172.17.0.141:8080 weight 100
+backend d4_app4-http_8080
+    mode http
+    server s41h 172.17.0.141:8080 weight 100
+backend d4_app4-ssl_8443
+    mode tcp
+    server s41s 172.17.0.141:8443 weight 100
+backend _redirect_https
+    mode http
+    http-request redirect scheme https
 <<backends-default>>
-<<frontends-default>>
+listen _front__tls
+    mode tcp
+    bind :443
+    tcp-request inspect-delay 5s
+    tcp-request content set-var(req.sslpassback) req.ssl_sni,lower,map_str(/etc/haproxy/maps/_front_sslpassthrough__exact.map)
+    tcp-request content accept if { req.ssl_hello_type 1 }
+    use_backend %[var(req.sslpassback)] if { var(req.sslpassback) -m found }
+    use_backend d4_app4-ssl_8443
+    server _default_server_https_socket unix@/var/run/haproxy/_https_socket.sock send-proxy-v2
+<<frontend-http>>
+    use_backend d4_app4-http_8080
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind unix@/var/run/haproxy/_https_socket.sock accept-proxy ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    http-request set-header X-Forwarded-Proto https
+    http-request del-header X-SSL-Client-CN
+    http-request del-header X-SSL-Client-DN
+    http-request del-header X-SSL-Client-SHA1
+    http-request del-header X-SSL-Client-Cert
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
 <<support>>
+`)
+
+	c.checkMap("_front_sslpassthrough__exact.map", `
+d2.local d2_app_8080
+d3.local d3_app-ssl_8443
 `)
 	c.checkMap("_front_http_host__begin.map", `
-d1.local#/path d1_app_8080
-d1.local#/ _error404
+d2.local#/ _redirect_https
+d3.local#/app d3_app2-http_8080
+d3.local#/ d3_app1-http_8080
 `)
-	c.checkMap("_front_https_host__begin.map", `
-d1.local#/path d1_app_8080
-d1.local#/ _error404
+	c.checkMap("_front_bind_crt.list", `
+/var/haproxy/ssl/certs/default.pem !*
 `)
+
 	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceStrictHostDefaultHost(t *testing.T) {
+func TestInstanceRootRedirect(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
@@ -2367,18 +5043,27 @@ func TestInstanceStrictHostDefaultHost(t *testing.T) {
 	var b *hatypes.Backend
 
 	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	h = c.config.Hosts().AcquireHost("*.d1.local")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.RootRedirect = "/app"
 	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-	h = c.config.Hosts().AcquireHost("d1.local")
-	h.AddPath(b, "/path", hatypes.MatchBegin)
 
 	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.AddPath(b, "/app1", hatypes.MatchBegin)
+	h.AddPath(b, "/app2", hatypes.MatchBegin)
+	h.RootRedirect = "/app1"
+	for _, path := range b.Paths {
+		path.SSLRedirect = true
+	}
 	b.Endpoints = []*hatypes.Endpoint{endpointS21}
-	h = c.config.Hosts().AcquireHost(hatypes.DefaultHost)
-	h.AddPath(b, "/", hatypes.MatchBegin)
-
-	c.config.Global().StrictHost = true
 
 	c.Update()
+
 	c.checkConfig(`
 <<global>>
 <<defaults>>
@@ -2387,74 +5072,89 @@ backend d1_app_8080
     server s1 172.17.0.11:8080 weight 100
 backend d2_app_8080
     mode http
+    acl https-request ssl_fc
+    http-request redirect scheme https if !https-request
     server s21 172.17.0.121:8080 weight 100
 <<backends-default>>
 frontend _front_http
     mode http
     bind :80
-    http-request set-var(req.path) path
-    http-request set-var(req.host) hdr(host),field(1,:),lower
-    http-request set-var(req.base) var(req.host),concat(\#,req.path)
+    <<set-req-base>>
+    http-request set-var(req.rootredir) var(req.host),map_str(/etc/haproxy/maps/_front_redir_fromroot__exact.map)
+    http-request set-var(req.rootredir) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_fromroot__regex.map) if !{ var(req.rootredir) -m found }
+    http-request redirect location %[var(req.rootredir)] if { path / } { var(req.rootredir) -m found }
     <<http-headers>>
     http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    http-request set-var(req.defaultbackend) var(req.path),lower,map_beg(/etc/haproxy/maps/_front_defaulthost__begin.map) if !{ var(req.backend) -m found }
+    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map) if !{ var(req.backend) -m found }
     use_backend %[var(req.backend)] if { var(req.backend) -m found }
-    use_backend %[var(req.defaultbackend)]
     default_backend _error404
 frontend _front_https
     mode http
     bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
-    http-request set-var(req.path) path
-    http-request set-var(req.host) hdr(host),field(1,:),lower
-    http-request set-var(req.base) var(req.host),concat(\#,req.path)
+    <<set-req-base>>
     http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
-    http-request set-var(req.defaultbackend) var(req.path),lower,map_beg(/etc/haproxy/maps/_front_defaulthost__begin.map) if !{ var(req.hostbackend) -m found }
+    http-request set-var(req.hostbackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_host__regex.map) if !{ var(req.hostbackend) -m found }
+    http-request set-var(req.rootredir) var(req.host),map_str(/etc/haproxy/maps/_front_redir_fromroot__exact.map)
+    http-request set-var(req.rootredir) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_fromroot__regex.map) if !{ var(req.rootredir) -m found }
+    http-request redirect location %[var(req.rootredir)] if { path / } { var(req.rootredir) -m found }
     <<https-headers>>
     use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
-    use_backend %[var(req.defaultbackend)]
     default_backend _error404
 <<support>>
+`)
+
+	c.checkMap("_front_http_host__regex.map", `
+^[^.]+\.d1\.local#/ d1_app_8080
 `)
 	c.checkMap("_front_http_host__begin.map", `
-d1.local#/path d1_app_8080
-d1.local#/ d2_app_8080
+d2.local#/app2 d2_app_8080
+d2.local#/app1 d2_app_8080
+`)
+	c.checkMap("_front_http_host__regex.map", `
+^[^.]+\.d1\.local#/ d1_app_8080
+`)
+	c.checkMap("_front_redir_fromroot__exact.map", `
+d2.local /app1
+`)
+	c.checkMap("_front_redir_fromroot__regex.map", `
+^[^.]+\.d1\.local$ /app
 `)
 	c.checkMap("_front_https_host__begin.map", `
-d1.local#/path d1_app_8080
-d1.local#/ d2_app_8080
+d2.local#/app2 d2_app_8080
+d2.local#/app1 d2_app_8080
 `)
-	c.checkMap("_front_defaulthost__begin.map", `
-/ d2_app_8080`)
+	c.checkMap("_front_https_host__regex.map", `
+^[^.]+\.d1\.local#/ d1_app_8080
+`)
+
 	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceFrontend(t *testing.T) {
+func TestInstanceOIDC(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
-	def := c.config.Backends().AcquireBackend("default", "default-backend", "8080")
-	def.Endpoints = []*hatypes.Endpoint{endpointS0}
-	c.config.Backends().DefaultBackend = def
-
 	var h *hatypes.Host
 	var b *hatypes.Backend
 
 	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
 	h = c.config.Hosts().AcquireHost("d1.local")
 	h.AddPath(b, "/", hatypes.MatchBegin)
-	b.FindBackendPath(h.FindPath("/")[0].Link).SSLRedirect = true
-	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-	h.VarNamespace = true
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/d1.pem"
-	h.TLS.TLSHash = "1"
+	h.OIDC = hatypes.HostOIDCConfig{
+		AuthBackendName: "_auth_4001",
+		CallbackPath:    "/_oidc/callback",
+		ClientID:        "app1",
+		ClientSecret:    "s3cr3t",
+		CookieSecret:    "MDEyMzQ1Njc4OWFiY2RlZg==",
+		Issuer:          "https://auth.local",
+		Scopes:          "openid+email",
+	}
 
 	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
 	h = c.config.Hosts().AcquireHost("d2.local")
-	h.AddPath(b, "/app", hatypes.MatchPrefix)
-	b.FindBackendPath(h.FindPath("/app")[0].Link).SSLRedirect = true
-	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/d2.pem"
-	h.TLS.TLSHash = "2"
+	h.AddPath(b, "/", hatypes.MatchBegin)
 
 	c.Update()
 	c.checkConfig(`
@@ -2462,150 +5162,80 @@ func TestInstanceFrontend(t *testing.T) {
 <<defaults>>
 backend d1_app_8080
     mode http
-    acl https-request ssl_fc
-    http-request redirect scheme https if !https-request
     server s1 172.17.0.11:8080 weight 100
 backend d2_app_8080
     mode http
-    acl https-request ssl_fc
-    http-request redirect scheme https if !https-request
-    server s1 172.17.0.11:8080 weight 100
-backend default_default-backend_8080
-    mode http
-    server s0 172.17.0.99:8080 weight 100
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
 frontend _front_http
     mode http
     bind :80
     <<set-req-base>>
-    http-request set-var(txn.namespace) var(req.base),map_dir(/etc/haproxy/maps/_front_namespace__prefix.map)
-    http-request set-var(txn.namespace) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_namespace__begin.map) if !{ var(txn.namespace) -m found }
-    http-request set-var(txn.namespace) str(-) if !{ var(txn.namespace) -m found }
     <<http-headers>>
-    http-request set-var(req.backend) var(req.base),map_dir(/etc/haproxy/maps/_front_http_host__prefix.map)
-    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map) if !{ var(req.backend) -m found }
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    http-request set-var(req.oidc) var(req.host),map_str(/etc/haproxy/maps/_front_oidc__exact.map)
+    http-request redirect scheme https if { var(req.oidc) -m found }
     use_backend %[var(req.backend)] if { var(req.backend) -m found }
-    default_backend default_default-backend_8080
+    default_backend _error404
 frontend _front_https
     mode http
     bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
     <<set-req-base>>
-    http-request set-var(req.hostbackend) var(req.base),map_dir(/etc/haproxy/maps/_front_https_host__prefix.map)
-    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map) if !{ var(req.hostbackend) -m found }
-    http-request set-var(txn.namespace) var(req.base),map_dir(/etc/haproxy/maps/_front_namespace__prefix.map)
-    http-request set-var(txn.namespace) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_namespace__begin.map) if !{ var(txn.namespace) -m found }
-    http-request set-var(txn.namespace) str(-) if !{ var(txn.namespace) -m found }
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
     <<https-headers>>
+    http-request set-var(req.oidc) var(req.host),map_str(/etc/haproxy/maps/_front_oidc__exact.map)
+    http-request lua.oidc-auth if { var(req.oidc) -m found }
+    http-response add-header Set-Cookie %[var(txn.oidc_set_cookie)] if { var(txn.oidc_set_cookie) -m found }
     use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
-    default_backend default_default-backend_8080
+    default_backend _error404
 <<support>>
 `)
-
-	c.checkMap("_front_http_host__begin.map", `
-d1.local#/ d1_app_8080
-`)
-	c.checkMap("_front_http_host__prefix.map", `
-d2.local#/app d2_app_8080
-`)
-	c.checkMap("_front_https_host__begin.map", `
-d1.local#/ d1_app_8080
-`)
-	c.checkMap("_front_https_host__prefix.map", `
-d2.local#/app d2_app_8080
-`)
-	c.checkMap("_front_namespace__begin.map", `
-d1.local#/ d1
-`)
-	c.checkMap("_front_namespace__prefix.map", `
-d2.local#/app -
-`)
-
-	c.checkMap("_front_bind_crt.list", `
-/var/haproxy/ssl/certs/default.pem !*
-/var/haproxy/ssl/certs/d1.pem d1.local
-/var/haproxy/ssl/certs/d2.pem d2.local
+	c.checkMap("_front_oidc__exact.map", `
+d1.local _auth_4001 https://auth.local app1 s3cr3t MDEyMzQ1Njc4OWFiY2RlZg== openid+email /_oidc/callback
 `)
-
 	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceFrontendCA(t *testing.T) {
+func TestInstanceAlias(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
-	def := c.config.Backends().AcquireBackend("default", "default-backend", "8080")
-	def.Endpoints = []*hatypes.Endpoint{endpointS0}
-	c.config.Backends().DefaultBackend = def
-
 	var h *hatypes.Host
 	var b *hatypes.Backend
 
-	b = c.config.Backends().AcquireBackend("d", "app", "8080")
-	h = c.config.Hosts().AcquireHost("*.d1.local")
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
 	h.AddPath(b, "/", hatypes.MatchBegin)
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
-	h.TLS.CAFilename = "/var/haproxy/ssl/ca/d1.local.pem"
-	h.TLS.CAHash = "1"
-	h.TLS.CAErrorPage = "http://d1.local/error.html"
+	h.Alias.AliasName = "*.d1.local"
 
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
 	h = c.config.Hosts().AcquireHost("d2.local")
 	h.AddPath(b, "/", hatypes.MatchBegin)
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
-	h.TLS.CAFilename = "/var/haproxy/ssl/ca/d2.local.pem"
-	h.TLS.CAHash = "2"
-	h.TLS.CRLFilename = "/var/haproxy/ssl/ca/d2.local.crl.pem"
-	h.TLS.CRLHash = "2"
-	h.TLS.CAErrorPage = "http://d2.local/error.html"
+	h.Alias.AliasName = "sub.d2.local"
+	h.Alias.AliasRegex = "^[a-z]+\\.d2\\.local$"
 
+	b = c.config.Backends().AcquireBackend("d3", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS31}
 	h = c.config.Hosts().AcquireHost("d3.local")
 	h.AddPath(b, "/", hatypes.MatchBegin)
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
-	h.TLS.Ciphers = "ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384"
-
-	h = c.config.Hosts().AcquireHost("d4.local")
-	h.AddPath(b, "/", hatypes.MatchBegin)
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
-	h.TLS.CipherSuites = "TLS_CHACHA20_POLY1305_SHA256:TLS_AES_128_GCM_SHA256"
-
-	h = c.config.Hosts().AcquireHost("d5.local")
-	h.AddPath(b, "/", hatypes.MatchBegin)
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
-	h.TLS.ALPN = "h2"
-
-	h = c.config.Hosts().AcquireHost("d6.local")
-	h.AddPath(b, "/", hatypes.MatchBegin)
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
-	h.TLS.Options = "ssl-min-ver TLSv1.0 ssl-max-ver TLSv1.2"
-
-	for _, path := range b.Paths {
-		path.SSLRedirect = true
-	}
-	b.TLS.AddCertHeader = true
-	b.TLS.FingerprintLower = true
-	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h.Alias.AliasRegex = "d3\\.local$"
 
 	c.Update()
 	c.checkConfig(`
 <<global>>
 <<defaults>>
-backend d_app_8080
+backend d1_app_8080
     mode http
-    acl https-request ssl_fc
-    acl local-offload ssl_fc
-    http-request redirect scheme https if !https-request
-    http-request set-header X-SSL-Client-CN   %{+Q}[ssl_c_s_dn(cn)]
-    http-request set-header X-SSL-Client-DN   %{+Q}[ssl_c_s_dn]
-    http-request set-header X-SSL-Client-SHA1 %{+Q}[ssl_c_sha1,hex,lower]
-    http-request set-header X-SSL-Client-Cert %{+Q}[ssl_c_der,base64]
     server s1 172.17.0.11:8080 weight 100
-backend default_default-backend_8080
+backend d2_app_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+backend d3_app_8080
     mode http
-    server s0 172.17.0.99:8080 weight 100
+    server s31 172.17.0.131:8080 weight 100
+<<backends-default>>
 frontend _front_http
     mode http
     bind :80
@@ -2614,490 +5244,319 @@ frontend _front_http
     http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
     http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map) if !{ var(req.backend) -m found }
     use_backend %[var(req.backend)] if { var(req.backend) -m found }
-    default_backend default_default-backend_8080
+    default_backend _error404
 frontend _front_https
     mode http
     bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
     <<set-req-base>>
     http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    http-request set-var(req.hostbackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_host__regex.map) if !{ var(req.hostbackend) -m found }
     <<https-headers>>
-    acl tls-has-crt ssl_c_used
-    acl tls-need-crt ssl_fc_sni -i -m str -f /etc/haproxy/maps/_front_tls_needcrt__exact.list
-    acl tls-need-crt ssl_fc_sni -i -m reg -f /etc/haproxy/maps/_front_tls_needcrt__regex.list
-    acl tls-host-need-crt var(req.host) -i -m str -f /etc/haproxy/maps/_front_tls_needcrt__exact.list
-    acl tls-host-need-crt var(req.host) -i -m reg -f /etc/haproxy/maps/_front_tls_needcrt__regex.list
-    acl tls-has-invalid-crt ssl_c_verify gt 0
-    acl tls-check-crt ssl_fc_sni -i -m str -f /etc/haproxy/maps/_front_tls_auth__exact.list
-    acl tls-check-crt ssl_fc_sni -i -m reg -f /etc/haproxy/maps/_front_tls_auth__regex.list
-    http-request set-var(req.snibase) ssl_fc_sni,lower,concat(\#,req.path)
-    http-request set-var(req.snibackend) var(req.snibase),lower,map_beg(/etc/haproxy/maps/_front_https_sni__begin.map)
-    http-request set-var(req.snibackend) var(req.snibase),map_reg(/etc/haproxy/maps/_front_https_sni__regex.map) if !{ var(req.snibackend) -m found }
-    http-request set-var(req.snibackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_sni__begin.map) if !{ var(req.snibackend) -m found } !tls-has-crt !tls-host-need-crt
-    http-request set-var(req.snibackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_sni__regex.map) if !{ var(req.snibackend) -m found } !tls-has-crt !tls-host-need-crt
-    http-request set-var(req.tls_nocrt_redir) ssl_fc_sni,lower,map_str(/etc/haproxy/maps/_front_tls_missingcrt_pages__exact.map,_internal) if !tls-has-crt tls-need-crt
-    http-request set-var(req.tls_nocrt_redir) ssl_fc_sni,lower,map_reg(/etc/haproxy/maps/_front_tls_missingcrt_pages__regex.map,_internal) if { var(req.tls_nocrt_redir) _internal }
-    http-request set-var(req.tls_invalidcrt_redir) ssl_fc_sni,lower,map_str(/etc/haproxy/maps/_front_tls_invalidcrt_pages__exact.map,_internal) if tls-has-invalid-crt tls-check-crt
-    http-request set-var(req.tls_invalidcrt_redir) ssl_fc_sni,lower,map_reg(/etc/haproxy/maps/_front_tls_invalidcrt_pages__regex.map,_internal) if { var(req.tls_invalidcrt_redir) _internal }
-    http-request redirect location %[var(req.tls_nocrt_redir)] code 303 if { var(req.tls_nocrt_redir) -m found } !{ var(req.tls_nocrt_redir) _internal }
-    http-request redirect location %[var(req.tls_invalidcrt_redir)] code 303 if { var(req.tls_invalidcrt_redir) -m found } !{ var(req.tls_invalidcrt_redir) _internal }
-    http-request use-service lua.send-421 if tls-has-crt { ssl_fc_has_sni } !{ ssl_fc_sni,strcmp(req.host) eq 0 }
-    http-request use-service lua.send-496 if { var(req.tls_nocrt_redir) _internal }
-    http-request use-service lua.send-421 if !tls-has-crt tls-host-need-crt
-    http-request use-service lua.send-495 if { var(req.tls_invalidcrt_redir) _internal }
     use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
-    use_backend %[var(req.snibackend)] if { var(req.snibackend) -m found }
-    default_backend default_default-backend_8080
+    default_backend _error404
 <<support>>
 `)
 
 	c.checkMap("_front_http_host__begin.map", `
-d2.local#/ d_app_8080
-d3.local#/ d_app_8080
-d4.local#/ d_app_8080
-d5.local#/ d_app_8080
-d6.local#/ d_app_8080
-`)
-	c.checkMap("_front_http_host__regex.map", `
-^[^.]+\.d1\.local#/ d_app_8080
-`)
-	c.checkMap("_front_bind_crt.list", `
-/var/haproxy/ssl/certs/default.pem !*
-/var/haproxy/ssl/certs/default.pem [ca-file /var/haproxy/ssl/ca/d1.local.pem verify optional] *.d1.local
-/var/haproxy/ssl/certs/default.pem [ca-file /var/haproxy/ssl/ca/d2.local.pem verify optional crl-file /var/haproxy/ssl/ca/d2.local.crl.pem] d2.local
-/var/haproxy/ssl/certs/default.pem [ciphers ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384] d3.local
-/var/haproxy/ssl/certs/default.pem [ciphersuites TLS_CHACHA20_POLY1305_SHA256:TLS_AES_128_GCM_SHA256] d4.local
-/var/haproxy/ssl/certs/default.pem [alpn h2] d5.local
-/var/haproxy/ssl/certs/default.pem [ssl-min-ver TLSv1.0 ssl-max-ver TLSv1.2] d6.local
+d1.local#/ d1_app_8080
+d2.local#/ d2_app_8080
+d3.local#/ d3_app_8080
+sub.d2.local#/ d2_app_8080
 `)
 	c.checkMap("_front_https_host__begin.map", `
-d3.local#/ d_app_8080
-d4.local#/ d_app_8080
-d5.local#/ d_app_8080
-d6.local#/ d_app_8080
-`)
-	c.checkMap("_front_https_sni__begin.map", `
-d2.local#/ d_app_8080
-`)
-	c.checkMap("_front_https_sni__regex.map", `
-^[^.]+\.d1\.local#/ d_app_8080
-`)
-	c.checkMap("_front_tls_needcrt__exact.list", `
-d2.local
-`)
-	c.checkMap("_front_tls_needcrt__regex.list", `
-^[^.]+\.d1\.local$
-`)
-	c.checkMap("_front_tls_auth__exact.list", `
-d2.local
-`)
-	c.checkMap("_front_tls_auth__regex.list", `
-^[^.]+\.d1\.local$
-`)
-	c.checkMap("_front_tls_missingcrt_pages__exact.map", `
-d2.local http://d2.local/error.html
+d1.local#/ d1_app_8080
+d2.local#/ d2_app_8080
+d3.local#/ d3_app_8080
+sub.d2.local#/ d2_app_8080
 `)
-	c.checkMap("_front_tls_invalidcrt_pages__regex.map", `
-^[^.]+\.d1\.local$ http://d1.local/error.html
+	c.checkMap("_front_https_host__regex.map", `
+^[a-z]+\.d2\.local#/ d2_app_8080
+^[^.]+\.d1\.local#/ d1_app_8080
+d3\.local#/ d3_app_8080
 `)
-
 	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceFrontendAuth(t *testing.T) {
-	type back struct {
-		iplist   []string
-		port     int
-		hostname string
-	}
+func TestInstanceRedirectFrom(t *testing.T) {
 	testCases := []struct {
-		backs    []back
-		expback  string
-		expfront string
+		data     [3]hatypes.HostRedirectConfig
+		code     int
+		expHTTP  string
+		expHTTPS string
+		expMaps  map[string]string
 	}{
+		// 0
 		{
-			backs: []back{
-				{iplist: []string{"10.0.0.1", "10.0.0.2"}, port: 8080},
+			data: [3]hatypes.HostRedirectConfig{
+				{RedirectHost: "*.d1.local"},
+			},
+			code: 301,
+			expHTTP: `
+    http-request set-var(req.redirdest) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_from__regex.map) if !{ var(req.backend) -m found }
+    http-request redirect prefix //%[var(req.redirdest)] code 301 if { var(req.redirdest) -m found }`,
+			expHTTPS: `
+    http-request set-var(req.redirdest) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_from__regex.map) if !{ var(req.hostbackend) -m found }
+    http-request redirect prefix //%[var(req.redirdest)] code 301 if { var(req.redirdest) -m found }`,
+			expMaps: map[string]string{
+				"_front_redir_from__regex.map": `
+^[^.]+\.d1\.local$ d1.local
+`,
 			},
-			expback: `
-backend _auth_backend001_8080
-    mode http
-    server srv001 10.0.0.1:8080 weight 1
-    server srv002 10.0.0.2:8080 weight 1`,
-			expfront: `
-backend _auth_4001
-    mode http
-    server _auth_4001 127.0.0.1:4001
-frontend _front__auth
-    mode http
-    bind 127.0.0.1:4001
-    use_backend _auth_backend001_8080`,
 		},
+		// 1
 		{
-			backs: []back{
-				{iplist: []string{"10.0.0.1", "10.0.0.2"}, port: 8080},
-				{iplist: []string{"10.0.0.3"}, port: 8080},
-				{iplist: []string{"10.0.0.3"}, port: 8080, hostname: "app1.local"},
+			data: [3]hatypes.HostRedirectConfig{
+				{RedirectHost: "*.d1.local"},
+				{RedirectHost: "sub.d2.local", RedirectHostRegex: "^[a-z]+\\.d2\\.local$"},
+				{RedirectHostRegex: "\\.d3\\.local$"},
 			},
-			expback: `
-backend _auth_backend001_8080
-    mode http
-    server srv001 10.0.0.1:8080 weight 1
-    server srv002 10.0.0.2:8080 weight 1
-backend _auth_backend002_8080
-    mode http
-    server srv001 10.0.0.3:8080 weight 1
-backend _auth_backend003_8080
-    mode http
-    http-request set-header Host app1.local
-    server srv001 10.0.0.3:8080 weight 1`,
-			expfront: `
-backend _auth_4001
-    mode http
-    server _auth_4001 127.0.0.1:4001
-backend _auth_4002
-    mode http
-    server _auth_4002 127.0.0.1:4002
-backend _auth_4003
-    mode http
-    server _auth_4003 127.0.0.1:4003
-frontend _front__auth
-    mode http
-    bind 127.0.0.1:4001 id 14001
-    bind 127.0.0.1:4002 id 14002
-    bind 127.0.0.1:4003 id 14003
-    use_backend _auth_backend001_8080 if { so_id 14001 }
-    use_backend _auth_backend002_8080 if { so_id 14002 }
-    use_backend _auth_backend003_8080 if { so_id 14003 }`,
-		},
-	}
-	for _, test := range testCases {
-		c := setup(t)
-
-		var h *hatypes.Host
-		var b *hatypes.Backend
-
-		b = c.config.Backends().AcquireBackend("d1", "app", "8080")
-		b.Endpoints = []*hatypes.Endpoint{endpointS1}
-		h = c.config.Hosts().AcquireHost("d1.local")
-		h.AddPath(b, "/", hatypes.MatchBegin)
-
-		auth := &c.config.Frontend().AuthProxy
-		auth.Name = "_front__auth"
-		auth.RangeStart = 4001
-		auth.RangeEnd = 4010
-
-		for _, back := range test.backs {
-			backend := c.config.Backends().AcquireAuthBackend(back.iplist, back.port, back.hostname)
-			_, _ = c.config.Frontend().AcquireAuthBackendName(backend.BackendID())
-		}
-
-		c.Update()
-		c.checkConfig(`
-<<global>>
-<<defaults>>` + test.expback + `
-backend d1_app_8080
-    mode http
-    server s1 172.17.0.11:8080 weight 100
-<<backends-default>>` + test.expfront + `
-<<frontends-default>>
-<<support>>
-`)
-
-		c.logger.CompareLogging(defaultLogging)
-		c.teardown()
-	}
-}
-
-func TestInstanceSomePaths(t *testing.T) {
-	c := setup(t)
-	defer c.teardown()
-
-	def := c.config.Backends().AcquireBackend("default", "default-backend", "8080")
-	def.Endpoints = []*hatypes.Endpoint{endpointS0}
-	c.config.Backends().DefaultBackend = def
-
-	var h *hatypes.Host
-	var b *hatypes.Backend
-
-	b = c.config.Backends().AcquireBackend("d", "app0", "8080")
-	h = c.config.Hosts().AcquireHost("d.local")
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
-	h.AddPath(b, "/", hatypes.MatchBegin)
-	b.FindBackendPath(h.FindPath("/")[0].Link).SSLRedirect = true
-	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-
-	b = c.config.Backends().AcquireBackend("d", "app1", "8080")
-	h.AddPath(b, "/app", hatypes.MatchBegin)
-	b.FindBackendPath(h.FindPath("/app")[0].Link).SSLRedirect = true
-	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-
-	b = c.config.Backends().AcquireBackend("d", "app2", "8080")
-	h.AddPath(b, "/app/sub", hatypes.MatchBegin)
-	b.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
-
-	b = c.config.Backends().AcquireBackend("d", "app3", "8080")
-	h.AddPath(b, "/sub", hatypes.MatchBegin)
-	b.Endpoints = []*hatypes.Endpoint{endpointS31, endpointS32, endpointS33}
+			expHTTP: `
+    http-request set-var(req.redirdest) var(req.host),map_str(/etc/haproxy/maps/_front_redir_from__exact.map) if !{ var(req.backend) -m found }
+    http-request set-var(req.redirdest) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_from__regex.map) if !{ var(req.backend) -m found } !{ var(req.redirdest) -m found }
+    http-request redirect prefix //%[var(req.redirdest)] code 302 if { var(req.redirdest) -m found }`,
+			expHTTPS: `
+    http-request set-var(req.redirdest) var(req.host),map_str(/etc/haproxy/maps/_front_redir_from__exact.map) if !{ var(req.hostbackend) -m found }
+    http-request set-var(req.redirdest) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_from__regex.map) if !{ var(req.hostbackend) -m found } !{ var(req.redirdest) -m found }
+    http-request redirect prefix //%[var(req.redirdest)] code 302 if { var(req.redirdest) -m found }`,
+			expMaps: map[string]string{
+				"_front_redir_from__exact.map": `
+sub.d2.local d2.local
+`,
+				"_front_redir_from__regex.map": `
+^[a-z]+\.d2\.local$ d2.local
+^[^.]+\.d1\.local$ d1.local
+\.d3\.local$ d3.local
+`,
+			},
+		},
+	}
 
-	c.Update()
-	c.checkConfig(`
-<<global>>
-<<defaults>>
-backend d_app0_8080
-    mode http
-    acl https-request ssl_fc
-    http-request redirect scheme https if !https-request
-    server s1 172.17.0.11:8080 weight 100
-backend d_app1_8080
-    mode http
-    acl https-request ssl_fc
-    http-request redirect scheme https if !https-request
-    server s1 172.17.0.11:8080 weight 100
-backend d_app2_8080
-    mode http
-    server s21 172.17.0.121:8080 weight 100
-    server s22 172.17.0.122:8080 weight 100
-backend d_app3_8080
-    mode http
-    server s31 172.17.0.131:8080 weight 100
-    server s32 172.17.0.132:8080 weight 100
-    server s33 172.17.0.133:8080 weight 100
-backend default_default-backend_8080
-    mode http
-    server s0 172.17.0.99:8080 weight 100
-<<frontend-http>>
-    default_backend default_default-backend_8080
-<<frontend-https>>
-    default_backend default_default-backend_8080
-<<support>>
-`)
+	for _, test := range testCases {
+		c := setup(t)
+		defer c.teardown()
 
-	c.checkMap("_front_http_host__begin.map", `
-d.local#/sub d_app3_8080
-d.local#/app/sub d_app2_8080
-d.local#/app d_app1_8080
-d.local#/ d_app0_8080
-`)
-	c.checkMap("_front_https_host__begin.map", `
-d.local#/sub d_app3_8080
-d.local#/app/sub d_app2_8080
-d.local#/app d_app1_8080
-d.local#/ d_app0_8080
-`)
+		var h *hatypes.Host
+		var b *hatypes.Backend
 
-	c.logger.CompareLogging(defaultLogging)
-}
+		b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS1}
+		h = c.config.Hosts().AcquireHost("d1.local")
+		h.AddPath(b, "/", hatypes.MatchBegin)
+		h.Redirect = test.data[0]
 
-func TestInstanceCustomFrontend(t *testing.T) {
-	c := setup(t)
-	defer c.teardown()
+		b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS21}
+		h = c.config.Hosts().AcquireHost("d2.local")
+		h.AddPath(b, "/", hatypes.MatchBegin)
+		h.Redirect = test.data[1]
 
-	var h *hatypes.Host
-	var b *hatypes.Backend
+		b = c.config.Backends().AcquireBackend("d3", "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS31}
+		h = c.config.Hosts().AcquireHost("d3.local")
+		h.AddPath(b, "/", hatypes.MatchBegin)
+		h.Redirect = test.data[2]
 
-	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
-	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-	h = c.config.Hosts().AcquireHost("d1.local")
-	h.AddPath(b, "/", hatypes.MatchBegin)
-	c.config.Global().CustomFrontend = []string{
-		"# new header",
-		"http-response set-header X-Server HAProxy",
-	}
+		if test.code != 0 {
+			c.config.frontend.RedirectFromCode = test.code
+		} else {
+			c.config.frontend.RedirectFromCode = 302
+		}
 
-	c.Update()
-	c.checkConfig(`
+		c.Update()
+		c.checkConfig(`
 <<global>>
 <<defaults>>
 backend d1_app_8080
     mode http
     server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+backend d3_app_8080
+    mode http
+    server s31 172.17.0.131:8080 weight 100
 <<backends-default>>
 frontend _front_http
     mode http
     bind :80
-    <<set-req-base>>
-    <<http-headers>>
-    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    # new header
-    http-response set-header X-Server HAProxy
+    http-request set-var(req.path) path
+    http-request set-var(req.host) hdr(host),field(1,:),lower
+    http-request set-var(req.base) var(req.host),concat(\#,req.path)
+    http-request set-header X-Forwarded-Proto http
+    http-request del-header X-SSL-Client-CN
+    http-request del-header X-SSL-Client-DN
+    http-request del-header X-SSL-Client-SHA1
+    http-request del-header X-SSL-Client-Cert
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)` + test.expHTTP + `
     use_backend %[var(req.backend)] if { var(req.backend) -m found }
     default_backend _error404
 frontend _front_https
     mode http
     bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
-    <<set-req-base>>
-    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    http-request set-var(req.path) path
+    http-request set-var(req.host) hdr(host),field(1,:),lower
+    http-request set-var(req.base) var(req.host),concat(\#,req.path)
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)` + test.expHTTPS + `
     http-request set-header X-Forwarded-Proto https
     http-request del-header X-SSL-Client-CN
     http-request del-header X-SSL-Client-DN
     http-request del-header X-SSL-Client-SHA1
     http-request del-header X-SSL-Client-Cert
-    # new header
-    http-response set-header X-Server HAProxy
     use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
     default_backend _error404
 <<support>>
 `)
-	c.logger.CompareLogging(defaultLogging)
-}
-
-func TestInstanceCustomSections(t *testing.T) {
-	c := setup(t)
-	defer c.teardown()
-
-	var h *hatypes.Host
-	var b *hatypes.Backend
-
-	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
-	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-	h = c.config.Hosts().AcquireHost("d1.local")
-	h.AddPath(b, "/", hatypes.MatchBegin)
-
-	c.config.Global().CustomSections = []string{
-		"cache icons",
-		"	total-max-size 4",
-		"	max-age 240",
+		for file, content := range test.expMaps {
+			c.checkMap(file, content)
+		}
+		c.logger.CompareLogging(defaultLogging)
 	}
-
-	c.Update()
-	c.checkConfig(`
-<<global>>
-<<defaults>>
-cache icons
-	total-max-size 4
-	max-age 240
-backend d1_app_8080
-    mode http
-    server s1 172.17.0.11:8080 weight 100
-<<backends-default>>
-<<frontends-default>>
-<<support>>
-`)
-	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceCustomTCP(t *testing.T) {
-	c := setup(t)
-	defer c.teardown()
-
-	tcp := c.config.TCPBackends().Acquire("default_postgresql", 5432)
-	tcp.AddEndpoint("172.17.0.11", 5432)
-
-	c.config.global.CustomTCP = []string{"## custom for TCP", "## multi line"}
-
-	c.Update()
-	c.checkConfig(`
-<<global>>
-<<defaults>>
-listen _tcp_default_postgresql_5432
-    bind :5432
-    mode tcp
-    ## custom for TCP
-    ## multi line
-    server srv001 172.17.0.11:5432
-<<backends-default>>
-<<frontend-http-clean>>
-    default_backend _error404
-<<frontend-https-clean>>
-    default_backend _error404
-<<support>>
-`)
-	c.logger.CompareLogging(defaultLogging)
-}
+func TestInstanceRedirectTo(t *testing.T) {
+	testCases := []struct {
+		to       [3]string
+		code     int
+		doconfig func(h *hatypes.Host)
+		expected string
+		expMaps  map[string]string
+	}{
+		// 0
+		{
+			to: [3]string{
+				"https://app.local",
+			},
+			expected: `
+    http-request set-var(req.redirto) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_redir_to__begin.map)
+    http-request redirect location %[var(req.redirto)] code 302 if { var(req.redirto) -m found }`,
+			expMaps: map[string]string{
+				"_front_redir_to__begin.map": `
+d1.local#/ https://app.local
+`,
+			},
+		},
+		// 1
+		{
+			to: [3]string{
+				"https://app.local/login",
+				"https://app.local/app2",
+			},
+			expected: `
+    http-request set-var(req.redirto) var(req.base),map_dir(/etc/haproxy/maps/_front_redir_to__prefix_01.map)
+    http-request set-var(req.redirto) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_redir_to__begin.map) if !{ var(req.redirto) -m found }
+    http-request redirect location %[var(req.redirto)] code 302 if { var(req.redirto) -m found }`,
+			expMaps: map[string]string{
+				"_front_redir_to__prefix_01.map": `
+d1.local#/app2 https://app.local/app2
+`,
+				"_front_redir_to__begin.map": `
+d1.local#/ https://app.local/login
+`,
+			},
+		},
+		// 2
+		{
+			to: [3]string{
+				"",
+				"https://app.local/app2",
+				"https://app.local/app3",
+			},
+			expected: `
+    http-request set-var(req.redirto) var(req.base),map_dir(/etc/haproxy/maps/_front_redir_to__prefix.map)
+    http-request redirect location %[var(req.redirto)] code 302 if { var(req.redirto) -m found }`,
+			expMaps: map[string]string{
+				"_front_redir_to__prefix.map": `
+d1.local#/app3 https://app.local/app3
+d1.local#/app2 https://app.local/app2
+`,
+			},
+		},
+		// 3
+		{
+			to: [3]string{
+				"",
+				"https://app2.local",
+				"https://app.local/app3",
+			},
+			doconfig: func(h *hatypes.Host) {
+				app2 := h.FindPath("/app2")[0]
+				app2.RedirToCode = 301
+				app2.RedirToType = hatypes.RedirectPrefix
+			},
+			expected: `
+    http-request set-var(req.redirto) var(req.base),map_dir(/etc/haproxy/maps/_front_redir_to__prefix.map)
+    http-request set-var(req.redirtype) var(req.base),map_dir(/etc/haproxy/maps/_front_redir_to_type__prefix.map) if { var(req.redirto) -m found }
+    http-request redirect prefix %[var(req.redirto)] code 301 if { var(req.redirtype) -m str prefix-301 }
+    http-request redirect location %[var(req.redirto)] code 302 if { var(req.redirto) -m found }`,
+			expMaps: map[string]string{
+				"_front_redir_to__prefix.map": `
+d1.local#/app3 https://app.local/app3
+d1.local#/app2 https://app2.local
+`,
+				"_front_redir_to_type__prefix.map": `
+d1.local#/app3 location-302
+d1.local#/app2 prefix-301
+`,
+			},
+		},
+	}
 
-func TestInstanceCustomProxy(t *testing.T) {
-	c := setup(t)
-	defer c.teardown()
+	for _, test := range testCases {
+		c := setup(t)
+		defer c.teardown()
 
-	var h *hatypes.Host
-	var b *hatypes.Backend
+		var h *hatypes.Host
+		var b *hatypes.Backend
 
-	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
-	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-	h = c.config.Hosts().AcquireHost("d1.local")
-	h.AddPath(b, "/", hatypes.MatchBegin)
-	h.SetSSLPassthrough(true)
+		h = c.config.Hosts().AcquireHost("d1.local")
 
-	auth := &c.config.Frontend().AuthProxy
-	auth.Name = "_front__auth"
-	auth.RangeStart = 4001
-	auth.RangeEnd = 4010
-	authBackend := c.config.Backends().AcquireAuthBackend([]string{"172.17.100.11"}, 5000, "")
-	_, _ = c.config.Frontend().AcquireAuthBackendName(authBackend.BackendID())
+		b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS1}
+		if test.to[0] != "" {
+			h.AddRedirect("/", hatypes.MatchBegin, test.to[0])
+		} else {
+			h.AddPath(b, "/", hatypes.MatchBegin)
+		}
 
-	tcp := c.config.tcpbackends.Acquire("default_pgsql", 5432)
-	tcp.AddEndpoint("172.17.0.21", 5432)
+		b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS21}
+		if test.to[1] != "" {
+			h.AddRedirect("/app2", hatypes.MatchPrefix, test.to[1])
+		} else {
+			h.AddPath(b, "/app2", hatypes.MatchBegin)
+		}
 
-	_, tcpHost := c.config.tcpservices.AcquireTCPService(hatypes.DefaultHost + ":7001")
-	tcpHost.Backend = b.BackendID()
+		b = c.config.Backends().AcquireBackend("d3", "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS31}
+		if test.to[2] != "" {
+			h.AddRedirect("/app3", hatypes.MatchPrefix, test.to[2])
+		} else {
+			h.AddPath(b, "/app3", hatypes.MatchBegin)
+		}
 
-	c.config.Global().CustomProxy = map[string][]string{
-		"missing":                 {"## comment"},
-		"_tcp_default_pgsql_5432": {"## custom for _tcp_default_pgsql_5432"},
-		"_auth_backend001_5000":   {"## custom for _auth_backend001_5000"},
-		"d1_app_8080":             {"## custom for d1_app_8080"},
-		"_redirect_https":         {"## custom for _redirect_https"},
-		"_error404":               {"## custom for _error404", "## line 2"},
-		"_auth_4001":              {"## custom for _auth_4001"},
-		"_front__auth":            {"## custom for _front__auth"},
-		"_front_tcp_7001":         {"## custom for _front_tcp_7001"},
-		"_front__tls":             {"## custom for _front__tls"},
-		"_front_http":             {"## custom for _front_http"},
-		"_front_https":            {"## custom for _front_https"},
-		"stats":                   {"## custom for stats"},
-		"healthz":                 {"## custom for healthz"},
-	}
+		if test.doconfig != nil {
+			test.doconfig(h)
+		}
+		if test.code != 0 {
+			c.config.frontend.RedirectToCode = test.code
+		} else {
+			c.config.frontend.RedirectToCode = 302
+		}
 
-	c.Update()
-	c.checkConfig(`
+		c.Update()
+		c.checkConfig(`
 <<global>>
 <<defaults>>
-listen _tcp_default_pgsql_5432
-    bind :5432
-    mode tcp
-    ## custom for _tcp_default_pgsql_5432
-    server srv001 172.17.0.21:5432
-backend _auth_backend001_5000
-    mode http
-    ## custom for _auth_backend001_5000
-    server srv001 172.17.100.11:5000 weight 1
 backend d1_app_8080
     mode http
-    ## custom for d1_app_8080
     server s1 172.17.0.11:8080 weight 100
-backend _redirect_https
-    mode http
-    ## custom for _redirect_https
-    http-request redirect scheme https
-backend _error404
-    mode http
-    ## custom for _error404
-    ## line 2
-    http-request use-service lua.send-404
-backend _auth_4001
+backend d2_app_8080
     mode http
-    ## custom for _auth_4001
-    server _auth_4001 127.0.0.1:4001
-frontend _front__auth
+    server s21 172.17.0.121:8080 weight 100
+backend d3_app_8080
     mode http
-    bind 127.0.0.1:4001
-    ## custom for _front__auth
-    use_backend _auth_backend001_5000
-frontend _front_tcp_7001
-    bind :7001
-    mode tcp
-    ## custom for _front_tcp_7001
-    default_backend d1_app_8080
-listen _front__tls
-    mode tcp
-    bind :443
-    tcp-request inspect-delay 5s
-    tcp-request content set-var(req.sslpassback) req.ssl_sni,lower,map_str(/etc/haproxy/maps/_front_sslpassthrough__exact.map)
-    ## custom for _front__tls
-    tcp-request content accept if { req.ssl_hello_type 1 }
-    use_backend %[var(req.sslpassback)] if { var(req.sslpassback) -m found }
-    server _default_server_https_socket unix@/var/run/haproxy/_https_socket.sock send-proxy-v2
+    server s31 172.17.0.131:8080 weight 100
+<<backends-default>>
 frontend _front_http
     mode http
     bind :80
@@ -3108,384 +5567,402 @@ frontend _front_http
     http-request del-header X-SSL-Client-CN
     http-request del-header X-SSL-Client-DN
     http-request del-header X-SSL-Client-SHA1
-    http-request del-header X-SSL-Client-Cert
+    http-request del-header X-SSL-Client-Cert` + test.expected + `
     http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    ## custom for _front_http
     use_backend %[var(req.backend)] if { var(req.backend) -m found }
     default_backend _error404
 frontend _front_https
     mode http
-    bind unix@/var/run/haproxy/_https_socket.sock accept-proxy ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    http-request set-var(req.path) path
+    http-request set-var(req.host) hdr(host),field(1,:),lower
+    http-request set-var(req.base) var(req.host),concat(\#,req.path)` + test.expected + `
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
     http-request set-header X-Forwarded-Proto https
     http-request del-header X-SSL-Client-CN
     http-request del-header X-SSL-Client-DN
     http-request del-header X-SSL-Client-SHA1
     http-request del-header X-SSL-Client-Cert
-    ## custom for _front_https
     use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
     default_backend _error404
-listen stats
-    mode http
-    bind :1936
-    stats enable
-    stats uri /
-    no log
-    option httpclose
-    stats show-legends
-    ## custom for stats
-frontend healthz
-    mode http
-    bind :10253
-    monitor-uri /healthz
-    http-request use-service lua.send-404
-    no log
-    ## custom for healthz
+<<support>>
 `)
-	c.logger.CompareLogging(defaultLogging)
+		for file, content := range test.expMaps {
+			c.checkMap(file, content)
+		}
+		c.logger.CompareLogging(defaultLogging)
+	}
 }
 
-func TestInstanceSSLPassthrough(t *testing.T) {
+func TestInstanceSyslog(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
 	var h *hatypes.Host
 	var b *hatypes.Backend
 
-	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
-	h = c.config.Hosts().AcquireHost("d2.local")
-	h.AddPath(b, "/", hatypes.MatchBegin)
-	b.FindBackendPath(h.FindPath("/")[0].Link).SSLRedirect = true
-	b.Endpoints = []*hatypes.Endpoint{endpointS31}
-	// TODO should ingress converter configure mode tcp?
-	b.ModeTCP = true
-	h.SetSSLPassthrough(true)
-
-	b = c.config.Backends().AcquireBackend("d3", "app-ssl", "8443")
-	h = c.config.Hosts().AcquireHost("d3.local")
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
 	h.AddPath(b, "/", hatypes.MatchBegin)
-	b.Endpoints = []*hatypes.Endpoint{endpointS41s}
-	b.ModeTCP = true
-	h.SetSSLPassthrough(true)
-
-	b = c.config.Backends().AcquireBackend("d3", "app1-http", "8080")
-	b.Endpoints = []*hatypes.Endpoint{endpointS41h}
-	h.HTTPPassthroughBackend = b.ID
 
-	b = c.config.Backends().AcquireBackend("d3", "app2-http", "8080")
-	b.Endpoints = []*hatypes.Endpoint{endpointS41h}
-	h.AddPath(b, "/app", hatypes.MatchBegin)
+	tcpPort1, tcpHost1 := c.config.tcpservices.AcquireTCPService("<default>:7001")
+	tcpPort1.LogFormat = "default"
+	tcpHost1.Backend = b.BackendID()
 
-	b = c.config.Backends().AcquireBackend("d4", "app4-ssl", "8443")
-	h = c.config.Hosts().AcquireHost(hatypes.DefaultHost)
-	h.AddPath(b, "/", hatypes.MatchBegin)
-	b.Endpoints = []*hatypes.Endpoint{endpointS41s}
-	b.ModeTCP = true
-	h.SetSSLPassthrough(true)
+	tcpPort2, tcpHost2 := c.config.tcpservices.AcquireTCPService("<default>:7002")
+	tcpPort2.LogFormat = "%[src]"
+	tcpHost2.Backend = b.BackendID()
 
-	b = c.config.Backends().AcquireBackend("d4", "app4-http", "8080")
-	b.Endpoints = []*hatypes.Endpoint{endpointS41h}
-	h.HTTPPassthroughBackend = b.ID
+	syslog := &c.config.Global().Syslog
+	syslog.Endpoint = "127.0.0.1:1514"
+	syslog.Format = "rfc3164"
+	syslog.Length = 2048
+	syslog.Tag = "ingress"
 
 	c.Update()
 	c.checkConfig(`
-<<global>>
+global
+    daemon
+    unix-bind mode 0600
+    stats socket /var/run/haproxy.sock level admin expose-fd listeners mode 600
+    maxconn 2000
+    hard-stop-after 15m
+    log 127.0.0.1:1514 len 2048 format rfc3164 local0
+    log-tag ingress
+    lua-prepend-path /etc/haproxy/lua/?.lua
+    lua-load /etc/haproxy/lua/auth-request.lua
+    lua-load /etc/haproxy/lua/oidc.lua
+    lua-load /etc/haproxy/lua/mirror.lua
+    lua-load /etc/haproxy/lua/grpc-web.lua
+    lua-load /etc/haproxy/lua/services.lua
+    ssl-dh-param-file /var/haproxy/tls/dhparam.pem
+    ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-bind-ciphersuites TLS_AES_128_GCM_SHA256
+    ssl-default-bind-options no-sslv3
+    ssl-default-server-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-server-ciphersuites TLS_AES_128_GCM_SHA256
 <<defaults>>
-backend d2_app_8080
-    mode tcp
-    server s31 172.17.0.131:8080 weight 100
-backend d3_app-ssl_8443
-    mode tcp
-    server s41s 172.17.0.141:8443 weight 100
-backend d3_app1-http_8080
-    mode http
-    server s41h 172.17.0.141:8080 weight 100
-backend d3_app2-http_8080
-    mode http
-    server s41h 172.17.0.141:8080 weight 100
-backend d4_app4-http_8080
-    mode http
-    server s41h 172.17.0.141:8080 weight 100
-backend d4_app4-ssl_8443
-    mode tcp
-    server s41s 172.17.0.141:8443 weight 100
-backend _redirect_https
+backend d1_app_8080
     mode http
-    http-request redirect scheme https
+    server s1 172.17.0.11:8080 weight 100
 <<backends-default>>
-listen _front__tls
+frontend _front_tcp_7001
+    bind :7001
+    mode tcp
+    option tcplog
+    default_backend d1_app_8080
+frontend _front_tcp_7002
+    bind :7002
     mode tcp
-    bind :443
-    tcp-request inspect-delay 5s
-    tcp-request content set-var(req.sslpassback) req.ssl_sni,lower,map_str(/etc/haproxy/maps/_front_sslpassthrough__exact.map)
-    tcp-request content accept if { req.ssl_hello_type 1 }
-    use_backend %[var(req.sslpassback)] if { var(req.sslpassback) -m found }
-    use_backend d4_app4-ssl_8443
-    server _default_server_https_socket unix@/var/run/haproxy/_https_socket.sock send-proxy-v2
-<<frontend-http>>
-    use_backend d4_app4-http_8080
+    log-format %[src]
+    default_backend d1_app_8080
+frontend _front_http
+    mode http
+    bind :80
+    option httplog
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
     default_backend _error404
 frontend _front_https
     mode http
-    bind unix@/var/run/haproxy/_https_socket.sock accept-proxy ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
-    http-request set-header X-Forwarded-Proto https
-    http-request del-header X-SSL-Client-CN
-    http-request del-header X-SSL-Client-DN
-    http-request del-header X-SSL-Client-SHA1
-    http-request del-header X-SSL-Client-Cert
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    option httplog
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    <<https-headers>>
     use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
     default_backend _error404
 <<support>>
 `)
-
-	c.checkMap("_front_sslpassthrough__exact.map", `
-d2.local d2_app_8080
-d3.local d3_app-ssl_8443
-`)
-	c.checkMap("_front_http_host__begin.map", `
-d2.local#/ _redirect_https
-d3.local#/app d3_app2-http_8080
-d3.local#/ d3_app1-http_8080
-`)
-	c.checkMap("_front_bind_crt.list", `
-/var/haproxy/ssl/certs/default.pem !*
-`)
-
 	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceRootRedirect(t *testing.T) {
+func TestDNS(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
 	var h *hatypes.Host
 	var b *hatypes.Backend
 
+	c.config.Global().DNS = hatypes.DNSConfig{
+		ClusterDomain: "cluster.local",
+		Resolvers: []*hatypes.DNSResolver{
+			{
+				Name: "k8s",
+				Nameservers: []*hatypes.DNSNameserver{
+					{
+						Name:     "coredns1",
+						Endpoint: "10.0.1.11",
+					},
+					{
+						Name:     "coredns2",
+						Endpoint: "10.0.1.12",
+					},
+					{
+						Name:     "coredns3",
+						Endpoint: "10.0.1.13",
+					},
+				},
+				AcceptedPayloadSize: 8192,
+				HoldObsolete:        "0s",
+				HoldValid:           "1s",
+				TimeoutRetry:        "2s",
+			},
+			{
+				Name:                "kube-dns",
+				AcceptedPayloadSize: 8192,
+				HoldObsolete:        "0s",
+				HoldValid:           "1s",
+				TimeoutRetry:        "1s",
+			},
+		},
+	}
+
 	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
-	h = c.config.Hosts().AcquireHost("*.d1.local")
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
+	b.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
+	b.Resolver = "k8s"
+	h = c.config.Hosts().AcquireHost("d1.local")
 	h.AddPath(b, "/", hatypes.MatchBegin)
-	h.RootRedirect = "/app"
-	b.Endpoints = []*hatypes.Endpoint{endpointS1}
 
-	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b = c.config.Backends().AcquireBackend("d2", "app", "http")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
+	b.Resolver = "k8s"
 	h = c.config.Hosts().AcquireHost("d2.local")
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
-	h.AddPath(b, "/app1", hatypes.MatchBegin)
-	h.AddPath(b, "/app2", hatypes.MatchBegin)
-	h.RootRedirect = "/app1"
-	for _, path := range b.Paths {
-		path.SSLRedirect = true
-	}
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	b = c.config.Backends().AcquireBackend("d3", "app", "http")
+	b.DNSPort = "named"
+	b.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
+	b.Resolver = "k8s"
+	h = c.config.Hosts().AcquireHost("d3.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	b = c.config.Backends().AcquireBackend("d4", "ext", "443")
+	b.ExternalName = "api.example.com"
 	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+	b.Resolver = "kube-dns"
+	h = c.config.Hosts().AcquireHost("d4.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
 
 	c.Update()
-
 	c.checkConfig(`
 <<global>>
 <<defaults>>
+resolvers k8s
+    nameserver coredns1 10.0.1.11
+    nameserver coredns2 10.0.1.12
+    nameserver coredns3 10.0.1.13
+    accepted_payload_size 8192
+    hold obsolete         0s
+    hold valid            1s
+    timeout retry         2s
+resolvers kube-dns
+    parse-resolv-conf
+    accepted_payload_size 8192
+    hold obsolete         0s
+    hold valid            1s
+    timeout retry         1s
 backend d1_app_8080
     mode http
-    server s1 172.17.0.11:8080 weight 100
-backend d2_app_8080
+    server-template srv 2 app.d1.svc.cluster.local:8080 resolvers k8s resolve-prefer ipv4 init-addr none weight 1
+backend d2_app_http
     mode http
-    acl https-request ssl_fc
-    http-request redirect scheme https if !https-request
-    server s21 172.17.0.121:8080 weight 100
-<<backends-default>>
-frontend _front_http
+    server-template srv 2 _http._tcp.app.d2.svc.cluster.local resolvers k8s resolve-prefer ipv4 init-addr none weight 1
+backend d3_app_http
     mode http
-    bind :80
-    <<set-req-base>>
-    http-request set-var(req.rootredir) var(req.host),map_str(/etc/haproxy/maps/_front_redir_fromroot__exact.map)
-    http-request set-var(req.rootredir) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_fromroot__regex.map) if !{ var(req.rootredir) -m found }
-    http-request redirect location %[var(req.rootredir)] if { path / } { var(req.rootredir) -m found }
-    <<http-headers>>
-    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map) if !{ var(req.backend) -m found }
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }
-    default_backend _error404
-frontend _front_https
+    server-template srv 2 _named._tcp.app.d3.svc.cluster.local resolvers k8s resolve-prefer ipv4 init-addr none weight 1
+backend d4_ext_443
     mode http
-    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
-    <<set-req-base>>
-    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
-    http-request set-var(req.hostbackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_host__regex.map) if !{ var(req.hostbackend) -m found }
-    http-request set-var(req.rootredir) var(req.host),map_str(/etc/haproxy/maps/_front_redir_fromroot__exact.map)
-    http-request set-var(req.rootredir) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_fromroot__regex.map) if !{ var(req.rootredir) -m found }
-    http-request redirect location %[var(req.rootredir)] if { path / } { var(req.rootredir) -m found }
-    <<https-headers>>
-    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
-    default_backend _error404
+    server-template srv 1 api.example.com:443 resolvers kube-dns resolve-prefer ipv4 init-addr none weight 1
+<<backends-default>>
+<<frontends-default>>
 <<support>>
 `)
-
-	c.checkMap("_front_http_host__regex.map", `
-^[^.]+\.d1\.local#/ d1_app_8080
-`)
-	c.checkMap("_front_http_host__begin.map", `
-d2.local#/app2 d2_app_8080
-d2.local#/app1 d2_app_8080
-`)
-	c.checkMap("_front_http_host__regex.map", `
-^[^.]+\.d1\.local#/ d1_app_8080
-`)
-	c.checkMap("_front_redir_fromroot__exact.map", `
-d2.local /app1
-`)
-	c.checkMap("_front_redir_fromroot__regex.map", `
-^[^.]+\.d1\.local$ /app
-`)
-	c.checkMap("_front_https_host__begin.map", `
-d2.local#/app2 d2_app_8080
-d2.local#/app1 d2_app_8080
-`)
-	c.checkMap("_front_https_host__regex.map", `
-^[^.]+\.d1\.local#/ d1_app_8080
-`)
-
 	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceAlias(t *testing.T) {
-	c := setup(t)
-	defer c.teardown()
+func TestUserlist(t *testing.T) {
+	type list struct {
+		name   string
+		users  []hatypes.User
+		groups []hatypes.UserGroup
+	}
+	testCase := []struct {
+		lists    []list
+		listname string
+		realm    string
+		groups   []string
+		config   string
+	}{
+		{
+			lists: []list{
+				{
+					name: "default_usr",
+					users: []hatypes.User{
+						{Name: "usr1", Passwd: "clear1", Encrypted: false},
+					},
+				},
+			},
+			listname: "default_usr",
+			config: `
+userlist default_usr
+    user usr1 insecure-password clear1`,
+		},
+		{
+			lists: []list{
+				{
+					name: "default_auth",
+					users: []hatypes.User{
+						{Name: "usr1", Passwd: "clear1", Encrypted: false},
+						{Name: "usr2", Passwd: "xxxx", Encrypted: true},
+					},
+				},
+			},
+			listname: "default_auth",
+			realm:    "usrlist",
+			config: `
+userlist default_auth
+    user usr1 insecure-password clear1
+    user usr2 password xxxx`,
+		},
+		{
+			lists: []list{
+				{
+					name: "default_auth1",
+					users: []hatypes.User{
+						{Name: "usr1", Passwd: "clear1", Encrypted: false},
+					},
+				},
+				{
+					name: "default_auth2",
+					users: []hatypes.User{
+						{Name: "usr2", Passwd: "xxxx", Encrypted: true},
+					},
+				},
+			},
+			listname: "default_auth1",
+			realm:    "multi list",
+			config: `
+userlist default_auth1
+    user usr1 insecure-password clear1
+userlist default_auth2
+    user usr2 password xxxx`,
+		},
+		{
+			lists: []list{
+				{
+					name: "default_auth",
+					users: []hatypes.User{
+						{Name: "usr1", Passwd: "xxxx", Encrypted: true},
+						{Name: "usr2", Passwd: "yyyy", Encrypted: true},
+					},
+					groups: []hatypes.UserGroup{
+						{Name: "ops", Users: []string{"usr2"}},
+						{Name: "admin", Users: []string{"usr1", "usr2"}},
+						{Name: "empty"},
+					},
+				},
+			},
+			listname: "default_auth",
+			groups:   []string{"admin", "ops"},
+			config: `
+userlist default_auth
+    group admin users usr1,usr2
+    group empty
+    group ops users usr2
+    user usr1 password xxxx
+    user usr2 password yyyy`,
+		},
+	}
+	for _, test := range testCase {
+		c := setup(t)
 
-	var h *hatypes.Host
-	var b *hatypes.Backend
+		var h *hatypes.Host
+		var b *hatypes.Backend
 
-	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
-	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-	h = c.config.Hosts().AcquireHost("d1.local")
-	h.AddPath(b, "/", hatypes.MatchBegin)
-	h.Alias.AliasName = "*.d1.local"
+		b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS1}
+		h = c.config.Hosts().AcquireHost("d1.local")
+		h.AddPath(b, "/", hatypes.MatchBegin)
+		h.AddPath(b, "/admin", hatypes.MatchBegin)
 
-	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
-	b.Endpoints = []*hatypes.Endpoint{endpointS21}
-	h = c.config.Hosts().AcquireHost("d2.local")
-	h.AddPath(b, "/", hatypes.MatchBegin)
-	h.Alias.AliasName = "sub.d2.local"
-	h.Alias.AliasRegex = "^[a-z]+\\.d2\\.local$"
+		for _, list := range test.lists {
+			c.config.Userlists().Replace(list.name, list.users, list.groups)
+		}
+		b.FindBackendPath(h.FindPath("/admin")[0].Link).AuthHTTP = hatypes.AuthHTTP{
+			UserlistName: test.listname,
+			Realm:        test.realm,
+			Groups:       test.groups,
+		}
 
-	b = c.config.Backends().AcquireBackend("d3", "app", "8080")
-	b.Endpoints = []*hatypes.Endpoint{endpointS31}
-	h = c.config.Hosts().AcquireHost("d3.local")
-	h.AddPath(b, "/", hatypes.MatchBegin)
-	h.Alias.AliasRegex = "d3\\.local$"
+		var realm string
+		if test.realm != "" {
+			realm = fmt.Sprintf(` realm "%s"`, test.realm)
+		}
+		authCheck := "http_auth(" + test.listname + ")"
+		if len(test.groups) > 0 {
+			authCheck = "http_auth_group(" + test.listname + ") " + strings.Join(test.groups, " ")
+		}
 
-	c.Update()
-	c.checkConfig(`
+		c.Update()
+		c.checkConfig(`
 <<global>>
-<<defaults>>
+<<defaults>>` + test.config + `
 backend d1_app_8080
     mode http
+    # path01 = d1.local/
+    # path02 = d1.local/admin
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request auth` + realm + ` if { var(txn.pathID) path02 } !{ ` + authCheck + ` }
     server s1 172.17.0.11:8080 weight 100
-backend d2_app_8080
-    mode http
-    server s21 172.17.0.121:8080 weight 100
-backend d3_app_8080
-    mode http
-    server s31 172.17.0.131:8080 weight 100
 <<backends-default>>
-frontend _front_http
-    mode http
-    bind :80
-    <<set-req-base>>
-    <<http-headers>>
-    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map) if !{ var(req.backend) -m found }
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }
-    default_backend _error404
-frontend _front_https
-    mode http
-    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
-    <<set-req-base>>
-    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
-    http-request set-var(req.hostbackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_host__regex.map) if !{ var(req.hostbackend) -m found }
-    <<https-headers>>
-    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
-    default_backend _error404
+<<frontends-default>>
 <<support>>
 `)
-
-	c.checkMap("_front_http_host__begin.map", `
-d1.local#/ d1_app_8080
-d2.local#/ d2_app_8080
-d3.local#/ d3_app_8080
-sub.d2.local#/ d2_app_8080
-`)
-	c.checkMap("_front_https_host__begin.map", `
-d1.local#/ d1_app_8080
-d2.local#/ d2_app_8080
-d3.local#/ d3_app_8080
-sub.d2.local#/ d2_app_8080
-`)
-	c.checkMap("_front_https_host__regex.map", `
-^[a-z]+\.d2\.local#/ d2_app_8080
-^[^.]+\.d1\.local#/ d1_app_8080
-d3\.local#/ d3_app_8080
-`)
-	c.logger.CompareLogging(defaultLogging)
+		c.logger.CompareLogging(defaultLogging)
+		c.teardown()
+	}
 }
 
-func TestInstanceRedirectFrom(t *testing.T) {
+func TestAcme(t *testing.T) {
 	testCases := []struct {
-		data     [3]hatypes.HostRedirectConfig
-		code     int
-		expHTTP  string
-		expHTTPS string
-		expMaps  map[string]string
+		shared   bool
+		expected string
 	}{
-		// 0
 		{
-			data: [3]hatypes.HostRedirectConfig{
-				{RedirectHost: "*.d1.local"},
-			},
-			code: 301,
-			expHTTP: `
-    http-request set-var(req.redirdest) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_from__regex.map) if !{ var(req.backend) -m found }
-    http-request redirect prefix //%[var(req.redirdest)] code 301 if { var(req.redirdest) -m found }`,
-			expHTTPS: `
-    http-request set-var(req.redirdest) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_from__regex.map) if !{ var(req.hostbackend) -m found }
-    http-request redirect prefix //%[var(req.redirdest)] code 301 if { var(req.redirdest) -m found }`,
-			expMaps: map[string]string{
-				"_front_redir_from__regex.map": `
-^[^.]+\.d1\.local$ d1.local
-`,
-			},
+			shared: false,
+			expected: `
+frontend _front_http
+    mode http
+    bind :80
+    acl acme-challenge path_beg /.acme
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend _acme_challenge if acme-challenge
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404`,
 		},
-		// 1
 		{
-			data: [3]hatypes.HostRedirectConfig{
-				{RedirectHost: "*.d1.local"},
-				{RedirectHost: "sub.d2.local", RedirectHostRegex: "^[a-z]+\\.d2\\.local$"},
-				{RedirectHostRegex: "\\.d3\\.local$"},
-			},
-			expHTTP: `
-    http-request set-var(req.redirdest) var(req.host),map_str(/etc/haproxy/maps/_front_redir_from__exact.map) if !{ var(req.backend) -m found }
-    http-request set-var(req.redirdest) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_from__regex.map) if !{ var(req.backend) -m found } !{ var(req.redirdest) -m found }
-    http-request redirect prefix //%[var(req.redirdest)] code 302 if { var(req.redirdest) -m found }`,
-			expHTTPS: `
-    http-request set-var(req.redirdest) var(req.host),map_str(/etc/haproxy/maps/_front_redir_from__exact.map) if !{ var(req.hostbackend) -m found }
-    http-request set-var(req.redirdest) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_from__regex.map) if !{ var(req.hostbackend) -m found } !{ var(req.redirdest) -m found }
-    http-request redirect prefix //%[var(req.redirdest)] code 302 if { var(req.redirdest) -m found }`,
-			expMaps: map[string]string{
-				"_front_redir_from__exact.map": `
-sub.d2.local d2.local
-`,
-				"_front_redir_from__regex.map": `
-^[a-z]+\.d2\.local$ d2.local
-^[^.]+\.d1\.local$ d1.local
-\.d3\.local$ d3.local
-`,
-			},
+			shared: true,
+			expected: `
+frontend _front_http
+    mode http
+    bind :80
+    acl acme-challenge path_beg /.acme
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    use_backend _acme_challenge if acme-challenge
+    default_backend _error404`,
 		},
 	}
-
 	for _, test := range testCases {
 		c := setup(t)
-		defer c.teardown()
 
 		var h *hatypes.Host
 		var b *hatypes.Backend
@@ -3494,25 +5971,12 @@ sub.d2.local d2.local
 		b.Endpoints = []*hatypes.Endpoint{endpointS1}
 		h = c.config.Hosts().AcquireHost("d1.local")
 		h.AddPath(b, "/", hatypes.MatchBegin)
-		h.Redirect = test.data[0]
-
-		b = c.config.Backends().AcquireBackend("d2", "app", "8080")
-		b.Endpoints = []*hatypes.Endpoint{endpointS21}
-		h = c.config.Hosts().AcquireHost("d2.local")
-		h.AddPath(b, "/", hatypes.MatchBegin)
-		h.Redirect = test.data[1]
-
-		b = c.config.Backends().AcquireBackend("d3", "app", "8080")
-		b.Endpoints = []*hatypes.Endpoint{endpointS31}
-		h = c.config.Hosts().AcquireHost("d3.local")
-		h.AddPath(b, "/", hatypes.MatchBegin)
-		h.Redirect = test.data[2]
 
-		if test.code != 0 {
-			c.config.frontend.RedirectFromCode = test.code
-		} else {
-			c.config.frontend.RedirectFromCode = 302
-		}
+		acme := &c.config.Global().Acme
+		acme.Enabled = true
+		acme.Prefix = "/.acme"
+		acme.Socket = "/run/acme.sock"
+		acme.Shared = test.shared
 
 		c.Update()
 		c.checkConfig(`
@@ -3521,727 +5985,634 @@ sub.d2.local d2.local
 backend d1_app_8080
     mode http
     server s1 172.17.0.11:8080 weight 100
-backend d2_app_8080
-    mode http
-    server s21 172.17.0.121:8080 weight 100
-backend d3_app_8080
-    mode http
-    server s31 172.17.0.131:8080 weight 100
-<<backends-default>>
-frontend _front_http
-    mode http
-    bind :80
-    http-request set-var(req.path) path
-    http-request set-var(req.host) hdr(host),field(1,:),lower
-    http-request set-var(req.base) var(req.host),concat(\#,req.path)
-    http-request set-header X-Forwarded-Proto http
-    http-request del-header X-SSL-Client-CN
-    http-request del-header X-SSL-Client-DN
-    http-request del-header X-SSL-Client-SHA1
-    http-request del-header X-SSL-Client-Cert
-    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)` + test.expHTTP + `
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }
-    default_backend _error404
-frontend _front_https
+backend _acme_challenge
     mode http
-    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
-    http-request set-var(req.path) path
-    http-request set-var(req.host) hdr(host),field(1,:),lower
-    http-request set-var(req.base) var(req.host),concat(\#,req.path)
-    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)` + test.expHTTPS + `
-    http-request set-header X-Forwarded-Proto https
-    http-request del-header X-SSL-Client-CN
-    http-request del-header X-SSL-Client-DN
-    http-request del-header X-SSL-Client-SHA1
-    http-request del-header X-SSL-Client-Cert
-    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    server _acme_server unix@/run/acme.sock
+<<backends-default>>` + test.expected + `
+<<frontend-https>>
     default_backend _error404
 <<support>>
 `)
-		for file, content := range test.expMaps {
-			c.checkMap(file, content)
-		}
 		c.logger.CompareLogging(defaultLogging)
+		c.teardown()
 	}
 }
 
-func TestInstanceRedirectTo(t *testing.T) {
+func TestStats(t *testing.T) {
 	testCases := []struct {
-		to       [3]string
-		code     int
-		expected string
-		expMaps  map[string]string
+		stats          hatypes.StatsConfig
+		prom           hatypes.PromConfig
+		healtz         hatypes.HealthzConfig
+		expectedStats  string
+		expectedProm   string
+		expectedHealtz string
 	}{
 		// 0
+		{},
+		// 1
 		{
-			to: [3]string{
-				"https://app.local",
+			stats: hatypes.StatsConfig{
+				Port:        1936,
+				AcceptProxy: true,
 			},
-			expected: `
-    http-request set-var(req.redirto) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_redir_to__begin.map)
-    http-request redirect location %[var(req.redirto)] code 302 if { var(req.redirto) -m found }`,
-			expMaps: map[string]string{
-				"_front_redir_to__begin.map": `
-d1.local#/ https://app.local
-`,
+			expectedStats: `
+    bind :1936 accept-proxy`,
+		},
+		// 2
+		{
+			stats: hatypes.StatsConfig{
+				Port: 1936,
+				Auth: "usr:pwd",
 			},
+			expectedStats: `
+    bind :1936
+    stats realm HAProxy\ Statistics
+    stats auth usr:pwd`,
 		},
-		// 1
+		// 3
 		{
-			to: [3]string{
-				"https://app.local/login",
-				"https://app.local/app2",
+			stats: hatypes.StatsConfig{
+				Port:        1936,
+				TLSFilename: "/var/haproxy/ssl/stats.pem",
+				TLSHash:     "1",
 			},
-			expected: `
-    http-request set-var(req.redirto) var(req.base),map_dir(/etc/haproxy/maps/_front_redir_to__prefix_01.map)
-    http-request set-var(req.redirto) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_redir_to__begin.map) if !{ var(req.redirto) -m found }
-    http-request redirect location %[var(req.redirto)] code 302 if { var(req.redirto) -m found }`,
-			expMaps: map[string]string{
-				"_front_redir_to__prefix_01.map": `
-d1.local#/app2 https://app.local/app2
-`,
-				"_front_redir_to__begin.map": `
-d1.local#/ https://app.local/login
-`,
+			expectedStats: `
+    bind :1936 ssl crt /var/haproxy/ssl/stats.pem`,
+		},
+		// 4
+		{
+			healtz: hatypes.HealthzConfig{
+				BindIP: "127.0.0.1",
+				Port:   10253,
 			},
+			expectedHealtz: "127.0.0.1:10253",
 		},
-		// 2
+		// 5
 		{
-			to: [3]string{
-				"",
-				"https://app.local/app2",
-				"https://app.local/app3",
+			prom: hatypes.PromConfig{
+				Port: 9100,
 			},
-			expected: `
-    http-request set-var(req.redirto) var(req.base),map_dir(/etc/haproxy/maps/_front_redir_to__prefix.map)
-    http-request redirect location %[var(req.redirto)] code 302 if { var(req.redirto) -m found }`,
-			expMaps: map[string]string{
-				"_front_redir_to__prefix.map": `
-d1.local#/app3 https://app.local/app3
-d1.local#/app2 https://app.local/app2
-`,
+			expectedProm: `
+frontend prometheus
+    mode http
+    bind :9100
+    http-request use-service prometheus-exporter if { path /metrics }
+    http-request use-service lua.send-prometheus-root if { path / }
+    http-request use-service lua.send-404
+    no log`,
+		},
+		// 6
+		{
+			prom: hatypes.PromConfig{
+				BindIP: "127.0.0.1",
+				Port:   9105,
+				Scopes: []string{"global", "frontend", "backend"},
 			},
+			expectedProm: `
+frontend prometheus
+    mode http
+    bind 127.0.0.1:9105
+    http-request set-query scope=global&scope=frontend&scope=backend if { path /metrics }
+    http-request use-service prometheus-exporter if { path /metrics }
+    http-request use-service lua.send-prometheus-root if { path / }
+    http-request use-service lua.send-404
+    no log`,
 		},
 	}
-
 	for _, test := range testCases {
 		c := setup(t)
-		defer c.teardown()
-
-		var h *hatypes.Host
-		var b *hatypes.Backend
-
-		h = c.config.Hosts().AcquireHost("d1.local")
-
-		b = c.config.Backends().AcquireBackend("d1", "app", "8080")
-		b.Endpoints = []*hatypes.Endpoint{endpointS1}
-		if test.to[0] != "" {
-			h.AddRedirect("/", hatypes.MatchBegin, test.to[0])
-		} else {
-			h.AddPath(b, "/", hatypes.MatchBegin)
-		}
-
-		b = c.config.Backends().AcquireBackend("d2", "app", "8080")
-		b.Endpoints = []*hatypes.Endpoint{endpointS21}
-		if test.to[1] != "" {
-			h.AddRedirect("/app2", hatypes.MatchPrefix, test.to[1])
-		} else {
-			h.AddPath(b, "/app2", hatypes.MatchBegin)
-		}
-
-		b = c.config.Backends().AcquireBackend("d3", "app", "8080")
-		b.Endpoints = []*hatypes.Endpoint{endpointS31}
-		if test.to[2] != "" {
-			h.AddRedirect("/app3", hatypes.MatchPrefix, test.to[2])
-		} else {
-			h.AddPath(b, "/app3", hatypes.MatchBegin)
+		c.config.Global().Stats = test.stats
+		c.config.Global().Prometheus = test.prom
+		c.config.Global().Healthz = test.healtz
+		if test.expectedStats == "" {
+			test.expectedStats = "\n    bind :0"
 		}
-
-		if test.code != 0 {
-			c.config.frontend.RedirectToCode = test.code
-		} else {
-			c.config.frontend.RedirectToCode = 302
+		if test.expectedHealtz == "" {
+			test.expectedHealtz = ":0"
 		}
-
 		c.Update()
 		c.checkConfig(`
 <<global>>
 <<defaults>>
-backend d1_app_8080
-    mode http
-    server s1 172.17.0.11:8080 weight 100
-backend d2_app_8080
-    mode http
-    server s21 172.17.0.121:8080 weight 100
-backend d3_app_8080
-    mode http
-    server s31 172.17.0.131:8080 weight 100
-<<backends-default>>
-frontend _front_http
+backend _error404
     mode http
-    bind :80
-    http-request set-var(req.path) path
-    http-request set-var(req.host) hdr(host),field(1,:),lower
-    http-request set-var(req.base) var(req.host),concat(\#,req.path)
-    http-request set-header X-Forwarded-Proto http
-    http-request del-header X-SSL-Client-CN
-    http-request del-header X-SSL-Client-DN
-    http-request del-header X-SSL-Client-SHA1
-    http-request del-header X-SSL-Client-Cert` + test.expected + `
-    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    http-request use-service lua.send-404
+<<frontend-http-clean>>
     default_backend _error404
-frontend _front_https
-    mode http
-    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
-    http-request set-var(req.path) path
-    http-request set-var(req.host) hdr(host),field(1,:),lower
-    http-request set-var(req.base) var(req.host),concat(\#,req.path)` + test.expected + `
-    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
-    http-request set-header X-Forwarded-Proto https
-    http-request del-header X-SSL-Client-CN
-    http-request del-header X-SSL-Client-DN
-    http-request del-header X-SSL-Client-SHA1
-    http-request del-header X-SSL-Client-Cert
-    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+<<frontend-https-clean>>
     default_backend _error404
-<<support>>
+listen stats
+    mode http` + test.expectedStats + `
+    stats enable
+    stats uri /
+    no log
+    option httpclose
+    stats show-legends` + test.expectedProm + `
+frontend healthz
+    mode http
+    bind ` + test.expectedHealtz + `
+    monitor-uri /healthz
+    http-request use-service lua.send-404
+    no log
 `)
-		for file, content := range test.expMaps {
-			c.checkMap(file, content)
-		}
 		c.logger.CompareLogging(defaultLogging)
+		c.teardown()
+	}
+}
+
+func TestModSecurity(t *testing.T) {
+	testCases := []struct {
+		waf        string
+		wafmode    string
+		path       string
+		endpoints  []string
+		backendExp string
+		modsecExp  string
+	}{
+		{
+			waf:        "modsecurity",
+			wafmode:    "On",
+			endpoints:  []string{},
+			backendExp: ``,
+			modsecExp:  ``,
+		},
+		{
+			waf:        "",
+			wafmode:    "",
+			endpoints:  []string{"10.0.0.101:12345"},
+			backendExp: ``,
+			modsecExp: `
+    timeout connect 1s
+    timeout server  2s
+    server modsec-spoa0 10.0.0.101:12345`,
+		},
+		{
+			waf:       "modsecurity",
+			wafmode:   "deny",
+			endpoints: []string{"10.0.0.101:12345"},
+			backendExp: `
+    filter spoe engine modsecurity config /etc/haproxy/spoe-modsecurity.conf
+    http-request deny if { var(txn.modsec.code) -m int gt 0 }`,
+			modsecExp: `
+    timeout connect 1s
+    timeout server  2s
+    server modsec-spoa0 10.0.0.101:12345`,
+		},
+		{
+			waf:       "modsecurity",
+			wafmode:   "detect",
+			endpoints: []string{"10.0.0.101:12345"},
+			backendExp: `
+    filter spoe engine modsecurity config /etc/haproxy/spoe-modsecurity.conf`,
+			modsecExp: `
+    timeout connect 1s
+    timeout server  2s
+    server modsec-spoa0 10.0.0.101:12345`,
+		},
+		{
+			waf:       "modsecurity",
+			wafmode:   "deny",
+			endpoints: []string{"10.0.0.101:12345", "10.0.0.102:12345"},
+			backendExp: `
+    filter spoe engine modsecurity config /etc/haproxy/spoe-modsecurity.conf
+    http-request deny if { var(txn.modsec.code) -m int gt 0 }`,
+			modsecExp: `
+    timeout connect 1s
+    timeout server  2s
+    server modsec-spoa0 10.0.0.101:12345
+    server modsec-spoa1 10.0.0.102:12345`,
+		},
+		{
+			waf:       "modsecurity",
+			wafmode:   "deny",
+			endpoints: []string{"10.0.0.101:12345"},
+			path:      "/sub",
+			backendExp: `
+    # path02 = d1.local/
+    # path01 = d1.local/sub
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    filter spoe engine modsecurity config /etc/haproxy/spoe-modsecurity.conf
+    http-request deny if { var(txn.modsec.code) -m int gt 0 } { var(txn.pathID) path01 }`,
+			modsecExp: `
+    timeout connect 1s
+    timeout server  2s
+    server modsec-spoa0 10.0.0.101:12345`,
+		},
 	}
-}
-
-func TestInstanceSyslog(t *testing.T) {
-	c := setup(t)
-	defer c.teardown()
-
-	var h *hatypes.Host
-	var b *hatypes.Backend
+	for _, test := range testCases {
+		c := setup(t)
 
-	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
-	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-	h = c.config.Hosts().AcquireHost("d1.local")
-	h.AddPath(b, "/", hatypes.MatchBegin)
+		var h *hatypes.Host
+		var b *hatypes.Backend
 
-	tcpPort1, tcpHost1 := c.config.tcpservices.AcquireTCPService("<default>:7001")
-	tcpPort1.LogFormat = "default"
-	tcpHost1.Backend = b.BackendID()
+		b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS1}
+		h = c.config.Hosts().AcquireHost("d1.local")
+		if test.path == "" {
+			test.path = "/"
+		}
+		h.AddPath(b, test.path, hatypes.MatchBegin)
+		b.FindBackendPath(h.FindPath(test.path)[0].Link).WAF = hatypes.WAF{
+			Module: test.waf,
+			Mode:   test.wafmode,
+		}
+		if test.path != "/" {
+			h.AddPath(b, "/", hatypes.MatchBegin)
+		}
 
-	tcpPort2, tcpHost2 := c.config.tcpservices.AcquireTCPService("<default>:7002")
-	tcpPort2.LogFormat = "%[src]"
-	tcpHost2.Backend = b.BackendID()
+		globalModsec := &c.config.Global().ModSecurity
+		globalModsec.Endpoints = test.endpoints
+		globalModsec.Timeout.Connect = "1s"
+		globalModsec.Timeout.Server = "2s"
 
-	syslog := &c.config.Global().Syslog
-	syslog.Endpoint = "127.0.0.1:1514"
-	syslog.Format = "rfc3164"
-	syslog.Length = 2048
-	syslog.Tag = "ingress"
+		c.Update()
 
-	c.Update()
-	c.checkConfig(`
-global
-    daemon
-    unix-bind mode 0600
-    stats socket /var/run/haproxy.sock level admin expose-fd listeners mode 600
-    maxconn 2000
-    hard-stop-after 15m
-    log 127.0.0.1:1514 len 2048 format rfc3164 local0
-    log-tag ingress
-    lua-prepend-path /etc/haproxy/lua/?.lua
-    lua-load /etc/haproxy/lua/auth-request.lua
-    lua-load /etc/haproxy/lua/services.lua
-    ssl-dh-param-file /var/haproxy/tls/dhparam.pem
-    ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
-    ssl-default-bind-ciphersuites TLS_AES_128_GCM_SHA256
-    ssl-default-bind-options no-sslv3
-    ssl-default-server-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
-    ssl-default-server-ciphersuites TLS_AES_128_GCM_SHA256
+		var modsec string
+		if test.modsecExp != "" {
+			modsec = `
+backend spoe-modsecurity
+    mode tcp` + test.modsecExp
+		}
+		c.checkConfig(`
+<<global>>
 <<defaults>>
 backend d1_app_8080
-    mode http
+    mode http` + test.backendExp + `
     server s1 172.17.0.11:8080 weight 100
 <<backends-default>>
-frontend _front_tcp_7001
-    bind :7001
-    mode tcp
-    option tcplog
-    default_backend d1_app_8080
-frontend _front_tcp_7002
-    bind :7002
-    mode tcp
-    log-format %[src]
-    default_backend d1_app_8080
-frontend _front_http
-    mode http
-    bind :80
-    option httplog
-    <<set-req-base>>
-    <<http-headers>>
-    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }
-    default_backend _error404
-frontend _front_https
-    mode http
-    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
-    option httplog
-    <<set-req-base>>
-    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
-    <<https-headers>>
-    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
-    default_backend _error404
-<<support>>
-`)
-	c.logger.CompareLogging(defaultLogging)
+<<frontends-default>>
+<<support>>` + modsec)
+
+		c.logger.CompareLogging(defaultLogging)
+		c.teardown()
+	}
 }
 
-func TestDNS(t *testing.T) {
+func TestInstanceBlockUserAgents(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
 	var h *hatypes.Host
 	var b *hatypes.Backend
 
-	c.config.Global().DNS = hatypes.DNSConfig{
-		ClusterDomain: "cluster.local",
-		Resolvers: []*hatypes.DNSResolver{
-			{
-				Name: "k8s",
-				Nameservers: []*hatypes.DNSNameserver{
-					{
-						Name:     "coredns1",
-						Endpoint: "10.0.1.11",
-					},
-					{
-						Name:     "coredns2",
-						Endpoint: "10.0.1.12",
-					},
-					{
-						Name:     "coredns3",
-						Endpoint: "10.0.1.13",
-					},
-				},
-				AcceptedPayloadSize: 8192,
-				HoldObsolete:        "0s",
-				HoldValid:           "1s",
-				TimeoutRetry:        "2s",
-			},
-		},
-	}
-
 	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
-	b.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
-	b.Resolver = "k8s"
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
 	h = c.config.Hosts().AcquireHost("d1.local")
 	h.AddPath(b, "/", hatypes.MatchBegin)
-
-	b = c.config.Backends().AcquireBackend("d2", "app", "http")
-	b.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
-	b.Resolver = "k8s"
-	h = c.config.Hosts().AcquireHost("d2.local")
-	h.AddPath(b, "/", hatypes.MatchBegin)
-
-	b = c.config.Backends().AcquireBackend("d3", "app", "http")
-	b.DNSPort = "named"
-	b.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
-	b.Resolver = "k8s"
-	h = c.config.Hosts().AcquireHost("d3.local")
-	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.AddPath(b, "/api", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/")[0].Link).BlockUA = hatypes.BlockUserAgents{
+		Action:   "deny",
+		Patterns: []string{"AhrefsBot"},
+	}
+	b.FindBackendPath(h.FindPath("/api")[0].Link).BlockUA = hatypes.BlockUserAgents{
+		Action:   "tarpit",
+		Patterns: []string{"curl", "python-requests"},
+	}
 
 	c.Update()
 	c.checkConfig(`
 <<global>>
-<<defaults>>
-resolvers k8s
-    nameserver coredns1 10.0.1.11
-    nameserver coredns2 10.0.1.12
-    nameserver coredns3 10.0.1.13
-    accepted_payload_size 8192
-    hold obsolete         0s
-    hold valid            1s
-    timeout retry         2s
-backend d1_app_8080
-    mode http
-    server-template srv 2 app.d1.svc.cluster.local:8080 resolvers k8s resolve-prefer ipv4 init-addr none weight 1
-backend d2_app_http
-    mode http
-    server-template srv 2 _http._tcp.app.d2.svc.cluster.local resolvers k8s resolve-prefer ipv4 init-addr none weight 1
-backend d3_app_http
-    mode http
-    server-template srv 2 _named._tcp.app.d3.svc.cluster.local resolvers k8s resolve-prefer ipv4 init-addr none weight 1
-<<backends-default>>
-<<frontends-default>>
-<<support>>
-`)
-	c.logger.CompareLogging(defaultLogging)
-}
-
-func TestUserlist(t *testing.T) {
-	type list struct {
-		name  string
-		users []hatypes.User
-	}
-	testCase := []struct {
-		lists    []list
-		listname string
-		realm    string
-		config   string
-	}{
-		{
-			lists: []list{
-				{
-					name: "default_usr",
-					users: []hatypes.User{
-						{Name: "usr1", Passwd: "clear1", Encrypted: false},
-					},
-				},
-			},
-			listname: "default_usr",
-			config: `
-userlist default_usr
-    user usr1 insecure-password clear1`,
-		},
-		{
-			lists: []list{
-				{
-					name: "default_auth",
-					users: []hatypes.User{
-						{Name: "usr1", Passwd: "clear1", Encrypted: false},
-						{Name: "usr2", Passwd: "xxxx", Encrypted: true},
-					},
-				},
-			},
-			listname: "default_auth",
-			realm:    "usrlist",
-			config: `
-userlist default_auth
-    user usr1 insecure-password clear1
-    user usr2 password xxxx`,
-		},
-		{
-			lists: []list{
-				{
-					name: "default_auth1",
-					users: []hatypes.User{
-						{Name: "usr1", Passwd: "clear1", Encrypted: false},
-					},
-				},
-				{
-					name: "default_auth2",
-					users: []hatypes.User{
-						{Name: "usr2", Passwd: "xxxx", Encrypted: true},
-					},
-				},
-			},
-			listname: "default_auth1",
-			realm:    "multi list",
-			config: `
-userlist default_auth1
-    user usr1 insecure-password clear1
-userlist default_auth2
-    user usr2 password xxxx`,
-		},
+<<defaults>>
+backend d1_app_8080
+    mode http
+    # path01 = d1.local/
+    # path02 = d1.local/api
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request set-var(txn.blocked_ua) str(AhrefsBot) if { req.fhdr(user-agent) -m sub -i AhrefsBot } { var(txn.pathID) path01 }
+    http-request set-var(txn.blocked_ua) str(curl) if { req.fhdr(user-agent) -m sub -i curl } { var(txn.pathID) path02 }
+    http-request set-var(txn.blocked_ua) str(python-requests) if { req.fhdr(user-agent) -m sub -i python-requests } { var(txn.pathID) path02 }
+    http-request track-sc2 var(txn.blocked_ua) table _blocked_user_agents if { var(txn.blocked_ua) -m found }
+    http-request deny deny_status 403 if { var(txn.blocked_ua) -m found } { var(txn.pathID) path01 }
+    http-request tarpit deny_status 403 if { var(txn.blocked_ua) -m found } { var(txn.pathID) path02 }
+    server s1 172.17.0.11:8080 weight 100
+backend _blocked_user_agents
+    stick-table type string len 64 size 1k store http_req_cnt
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceDenyMethods(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.AddPath(b, "/api", hatypes.MatchBegin)
+	h.AddPath(b, "/app", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/")[0].Link).DenyMethods = hatypes.DenyMethods{
+		Methods: []string{"TRACE"},
+		Status:  405,
+	}
+	b.FindBackendPath(h.FindPath("/api")[0].Link).DenyMethods = hatypes.DenyMethods{
+		Methods: []string{"TRACE", "DELETE"},
+		Status:  403,
 	}
-	for _, test := range testCase {
-		c := setup(t)
 
-		var h *hatypes.Host
-		var b *hatypes.Backend
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    # path01 = d1.local/
+    # path02 = d1.local/api
+    # path03 = d1.local/app
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request deny deny_status 405 if { var(txn.pathID) path01 } { method TRACE }
+    http-request deny deny_status 403 if { var(txn.pathID) path02 } { method TRACE DELETE }
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
 
-		b = c.config.Backends().AcquireBackend("d1", "app", "8080")
-		b.Endpoints = []*hatypes.Endpoint{endpointS1}
-		h = c.config.Hosts().AcquireHost("d1.local")
-		h.AddPath(b, "/", hatypes.MatchBegin)
-		h.AddPath(b, "/admin", hatypes.MatchBegin)
+func TestInstanceSecurityHeaders(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
 
-		for _, list := range test.lists {
-			c.config.Userlists().Replace(list.name, list.users)
-		}
-		b.FindBackendPath(h.FindPath("/admin")[0].Link).AuthHTTP = hatypes.AuthHTTP{
-			UserlistName: test.listname,
-			Realm:        test.realm,
-		}
+	var h *hatypes.Host
+	var b *hatypes.Backend
 
-		var realm string
-		if test.realm != "" {
-			realm = fmt.Sprintf(` realm "%s"`, test.realm)
-		}
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.AddPath(b, "/api", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/")[0].Link).SecHeaders = hatypes.SecurityHeaders{
+		ContentSecurityPolicy: `'default-src '\''self'\'''`,
+		ContentTypeOptions:    "nosniff",
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+	}
+	b.FindBackendPath(h.FindPath("/api")[0].Link).SecHeaders = hatypes.SecurityHeaders{
+		ContentTypeOptions: "nosniff",
+	}
 
-		c.Update()
-		c.checkConfig(`
+	c.Update()
+	c.checkConfig(`
 <<global>>
-<<defaults>>` + test.config + `
+<<defaults>>
 backend d1_app_8080
     mode http
     # path01 = d1.local/
-    # path02 = d1.local/admin
+    # path02 = d1.local/api
     http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
-    http-request auth` + realm + ` if { var(txn.pathID) path02 } !{ http_auth(` + test.listname + `) }
+    http-response set-header X-Content-Type-Options nosniff if { var(txn.pathID) path01 }
+    http-response set-header X-Frame-Options DENY if { var(txn.pathID) path01 }
+    http-response set-header Referrer-Policy strict-origin-when-cross-origin if { var(txn.pathID) path01 }
+    http-response set-header Content-Security-Policy 'default-src '\''self'\''' if { var(txn.pathID) path01 }
+    http-response set-header X-Content-Type-Options nosniff if { var(txn.pathID) path02 }
     server s1 172.17.0.11:8080 weight 100
 <<backends-default>>
 <<frontends-default>>
 <<support>>
 `)
-		c.logger.CompareLogging(defaultLogging)
-		c.teardown()
-	}
+	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestAcme(t *testing.T) {
-	testCases := []struct {
-		shared   bool
-		expected string
-	}{
-		{
-			shared: false,
-			expected: `
-frontend _front_http
-    mode http
-    bind :80
-    acl acme-challenge path_beg /.acme
-    <<set-req-base>>
-    <<http-headers>>
-    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    use_backend _acme_challenge if acme-challenge
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }
-    default_backend _error404`,
-		},
-		{
-			shared: true,
-			expected: `
-frontend _front_http
-    mode http
-    bind :80
-    acl acme-challenge path_beg /.acme
-    <<set-req-base>>
-    <<http-headers>>
-    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    use_backend %[var(req.backend)] if { var(req.backend) -m found }
-    use_backend _acme_challenge if acme-challenge
-    default_backend _error404`,
-		},
-	}
-	for _, test := range testCases {
-		c := setup(t)
+func TestInstanceCoraza(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
 
-		var h *hatypes.Host
-		var b *hatypes.Backend
+	if err := c.instance.corazaTmpl.NewTemplate(
+		"coraza.tmpl",
+		"../../rootfs/etc/templates/coraza/coraza.tmpl",
+		filepath.Join(c.tempdir, "spoe-coraza.conf"),
+		0,
+		1024,
+	); err != nil {
+		t.Errorf("error parsing coraza.tmpl: %v", err)
+	}
 
-		b = c.config.Backends().AcquireBackend("d1", "app", "8080")
-		b.Endpoints = []*hatypes.Endpoint{endpointS1}
-		h = c.config.Hosts().AcquireHost("d1.local")
-		h.AddPath(b, "/", hatypes.MatchBegin)
+	var h *hatypes.Host
+	var b *hatypes.Backend
 
-		acme := &c.config.Global().Acme
-		acme.Enabled = true
-		acme.Prefix = "/.acme"
-		acme.Socket = "/run/acme.sock"
-		acme.Shared = test.shared
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b.WAFRuleset = "crs"
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.AddPath(b, "/api", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/")[0].Link).WAF = hatypes.WAF{Module: "coraza", Mode: "detect"}
+	b.FindBackendPath(h.FindPath("/api")[0].Link).WAF = hatypes.WAF{Module: "coraza", Mode: "deny"}
+
+	coraza := &c.config.Global().Coraza
+	coraza.Endpoints = []string{"10.0.0.101:12345"}
+	coraza.Timeout = hatypes.ModSecurityTimeoutConfig{
+		Connect:    "1s",
+		Hello:      "100ms",
+		Idle:       "30s",
+		Processing: "1s",
+		Server:     "2s",
+	}
 
-		c.Update()
-		c.checkConfig(`
+	c.Update()
+	c.checkConfig(`
 <<global>>
 <<defaults>>
 backend d1_app_8080
     mode http
+    # path01 = d1.local/
+    # path02 = d1.local/api
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    filter spoe engine coraza-crs config /etc/haproxy/spoe-coraza.conf
+    http-request redirect code 302 location %[var(txn.coraza.data)] if { var(txn.coraza.action) -m str redirect } { var(txn.pathID) path02 }
+    http-request deny deny_status 403 if { var(txn.coraza.action) -m str deny } { var(txn.pathID) path02 }
+    http-request silent-drop if { var(txn.coraza.action) -m str drop } { var(txn.pathID) path02 }
+    http-response deny deny_status 403 if { var(txn.coraza.action) -m str deny } { var(txn.pathID) path02 }
+    http-response silent-drop if { var(txn.coraza.action) -m str drop } { var(txn.pathID) path02 }
     server s1 172.17.0.11:8080 weight 100
-backend _acme_challenge
-    mode http
-    server _acme_server unix@/run/acme.sock
-<<backends-default>>` + test.expected + `
-<<frontend-https>>
-    default_backend _error404
+<<backends-default>>
+<<frontends-default>>
 <<support>>
+backend spoe-coraza
+    mode tcp
+    timeout connect 1s
+    timeout server  2s
+    server coraza-spoa0 10.0.0.101:12345
 `)
-		c.logger.CompareLogging(defaultLogging)
-		c.teardown()
-	}
+	c.checkConfigFile(`
+[coraza-crs]
+spoe-agent coraza-agent
+    messages     coraza-req coraza-res
+    option       var-prefix  coraza
+    option       set-on-error  error
+    timeout      hello       100ms
+    timeout      idle        30s
+    timeout      processing  1s
+    use-backend  spoe-coraza
+spoe-message coraza-req
+    args   app=str(crs) src-ip=src src-port=src_port dst-ip=dst dst-port=dst_port method=method path=path query=query version=req.ver headers=req.hdrs body=req.body
+    event  on-backend-http-request
+spoe-message coraza-res
+    args   app=str(crs) id=var(txn.coraza.id) version=res.ver status=status headers=res.hdrs body=res.body
+    event  on-http-response
+`, "spoe-coraza.conf")
+	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestStats(t *testing.T) {
-	testCases := []struct {
-		stats          hatypes.StatsConfig
-		prom           hatypes.PromConfig
-		healtz         hatypes.HealthzConfig
-		expectedStats  string
-		expectedProm   string
-		expectedHealtz string
-	}{
-		// 0
-		{},
-		// 1
-		{
-			stats: hatypes.StatsConfig{
-				Port:        1936,
-				AcceptProxy: true,
-			},
-			expectedStats: `
-    bind :1936 accept-proxy`,
-		},
-		// 2
-		{
-			stats: hatypes.StatsConfig{
-				Port: 1936,
-				Auth: "usr:pwd",
-			},
-			expectedStats: `
-    bind :1936
-    stats realm HAProxy\ Statistics
-    stats auth usr:pwd`,
-		},
-		// 3
-		{
-			stats: hatypes.StatsConfig{
-				Port:        1936,
-				TLSFilename: "/var/haproxy/ssl/stats.pem",
-				TLSHash:     "1",
-			},
-			expectedStats: `
-    bind :1936 ssl crt /var/haproxy/ssl/stats.pem`,
-		},
-		// 4
-		{
-			healtz: hatypes.HealthzConfig{
-				BindIP: "127.0.0.1",
-				Port:   10253,
-			},
-			expectedHealtz: "127.0.0.1:10253",
-		},
-		// 5
-		{
-			prom: hatypes.PromConfig{
-				Port: 9100,
-			},
-			expectedProm: `
-frontend prometheus
-    mode http
-    bind :9100
-    http-request use-service prometheus-exporter if { path /metrics }
-    http-request use-service lua.send-prometheus-root if { path / }
-    http-request use-service lua.send-404
-    no log`,
-		},
+func TestInstanceSPOE(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	if err := c.instance.spoeTmpl.NewTemplate(
+		"spoe.tmpl",
+		"../../rootfs/etc/templates/spoe/spoe.tmpl",
+		filepath.Join(c.tempdir, "spoe-agents.conf"),
+		0,
+		1024,
+	); err != nil {
+		t.Errorf("error parsing spoe.tmpl: %v", err)
 	}
-	for _, test := range testCases {
-		c := setup(t)
-		c.config.Global().Stats = test.stats
-		c.config.Global().Prometheus = test.prom
-		c.config.Global().Healthz = test.healtz
-		if test.expectedStats == "" {
-			test.expectedStats = "\n    bind :0"
-		}
-		if test.expectedHealtz == "" {
-			test.expectedHealtz = ":0"
-		}
-		c.Update()
-		c.checkConfig(`
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b.SPOEAgents = []string{"iprep"}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	msgIP := &hatypes.SPOEMessage{Name: "check-ip", Event: "on-frontend-http-request", Args: []string{"ip=src"}}
+	msgReq := &hatypes.SPOEMessage{Name: "check-req", Event: "on-backend-http-request", Args: []string{"path", "host=req.hdr(host)"}}
+	msgRes := &hatypes.SPOEMessage{Name: "check-res", Event: "on-http-response"}
+	c.config.Global().SPOE = hatypes.SPOEConfig{
+		Agents: []*hatypes.SPOEAgent{
+			{Name: "audit", Endpoints: []string{"10.0.0.11:12345"}, Messages: []*hatypes.SPOEMessage{msgIP}},
+			{Name: "iprep", Endpoints: []string{"10.0.0.21:12345", "10.0.0.22:12345"}, Messages: []*hatypes.SPOEMessage{msgReq, msgRes}},
+		},
+		FrontendAgents: []string{"audit"},
+		Timeout: hatypes.ModSecurityTimeoutConfig{
+			Connect:    "1s",
+			Hello:      "100ms",
+			Idle:       "30s",
+			Processing: "1s",
+			Server:     "2s",
+		},
+	}
+
+	c.Update()
+	c.checkConfig(`
 <<global>>
 <<defaults>>
-backend _error404
+backend d1_app_8080
     mode http
-    http-request use-service lua.send-404
-<<frontend-http-clean>>
-    default_backend _error404
-<<frontend-https-clean>>
+    filter spoe engine iprep config /etc/haproxy/spoe-agents.conf
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    filter spoe engine audit config /etc/haproxy/spoe-agents.conf
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
     default_backend _error404
-listen stats
-    mode http` + test.expectedStats + `
-    stats enable
-    stats uri /
-    no log
-    option httpclose
-    stats show-legends` + test.expectedProm + `
-frontend healthz
+frontend _front_https
     mode http
-    bind ` + test.expectedHealtz + `
-    monitor-uri /healthz
-    http-request use-service lua.send-404
-    no log
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    filter spoe engine audit config /etc/haproxy/spoe-agents.conf
+    http-request set-var(req.path) path
+    http-request set-var(req.host) hdr(host),field(1,:),lower
+    http-request set-var(req.base) var(req.host),concat(\#,req.path)
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+backend _spoe_audit
+    mode tcp
+    timeout connect 1s
+    timeout server  2s
+    server spoa0 10.0.0.11:12345
+backend _spoe_iprep
+    mode tcp
+    timeout connect 1s
+    timeout server  2s
+    server spoa0 10.0.0.21:12345
+    server spoa1 10.0.0.22:12345
 `)
-		c.logger.CompareLogging(defaultLogging)
-		c.teardown()
-	}
+	c.checkConfigFile(`
+[audit]
+spoe-agent audit-agent
+    messages     check-ip
+    option       var-prefix  audit
+    timeout      hello       100ms
+    timeout      idle        30s
+    timeout      processing  1s
+    use-backend  _spoe_audit
+spoe-message check-ip
+    args   ip=src
+    event  on-frontend-http-request
+[iprep]
+spoe-agent iprep-agent
+    messages     check-req check-res
+    option       var-prefix  iprep
+    timeout      hello       100ms
+    timeout      idle        30s
+    timeout      processing  1s
+    use-backend  _spoe_iprep
+spoe-message check-req
+    args   path host=req.hdr(host)
+    event  on-backend-http-request
+spoe-message check-res
+    event  on-http-response
+`, "spoe-agents.conf")
+	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestModSecurity(t *testing.T) {
+func TestInstanceTracing(t *testing.T) {
 	testCases := []struct {
-		waf        string
-		wafmode    string
-		path       string
-		endpoints  []string
-		backendExp string
-		modsecExp  string
+		tracing  hatypes.TracingConfig
+		expFront string
 	}{
+		// 0
+		{},
+		// 1
 		{
-			waf:        "modsecurity",
-			wafmode:    "On",
-			endpoints:  []string{},
-			backendExp: ``,
-			modsecExp:  ``,
-		},
-		{
-			waf:        "",
-			wafmode:    "",
-			endpoints:  []string{"10.0.0.101:12345"},
-			backendExp: ``,
-			modsecExp: `
-    timeout connect 1s
-    timeout server  2s
-    server modsec-spoa0 10.0.0.101:12345`,
-		},
-		{
-			waf:       "modsecurity",
-			wafmode:   "deny",
-			endpoints: []string{"10.0.0.101:12345"},
-			backendExp: `
-    filter spoe engine modsecurity config /etc/haproxy/spoe-modsecurity.conf
-    http-request deny if { var(txn.modsec.code) -m int gt 0 }`,
-			modsecExp: `
-    timeout connect 1s
-    timeout server  2s
-    server modsec-spoa0 10.0.0.101:12345`,
-		},
-		{
-			waf:       "modsecurity",
-			wafmode:   "detect",
-			endpoints: []string{"10.0.0.101:12345"},
-			backendExp: `
-    filter spoe engine modsecurity config /etc/haproxy/spoe-modsecurity.conf`,
-			modsecExp: `
-    timeout connect 1s
-    timeout server  2s
-    server modsec-spoa0 10.0.0.101:12345`,
+			tracing: hatypes.TracingConfig{
+				UniqueIDFormat: "%{+X}o\\ %ci:%cp_%fi:%fp_%Ts_%rt:%pid",
+				UniqueIDHeader: "X-Request-ID",
+			},
+			expFront: `
+    unique-id-format %{+X}o\ %ci:%cp_%fi:%fp_%Ts_%rt:%pid
+    http-request set-header X-Request-ID %[unique-id] if !{ req.hdr(X-Request-ID) -m found }
+    http-request capture req.hdr(X-Request-ID) len 64`,
 		},
+		// 2
 		{
-			waf:       "modsecurity",
-			wafmode:   "deny",
-			endpoints: []string{"10.0.0.101:12345", "10.0.0.102:12345"},
-			backendExp: `
-    filter spoe engine modsecurity config /etc/haproxy/spoe-modsecurity.conf
-    http-request deny if { var(txn.modsec.code) -m int gt 0 }`,
-			modsecExp: `
-    timeout connect 1s
-    timeout server  2s
-    server modsec-spoa0 10.0.0.101:12345
-    server modsec-spoa1 10.0.0.102:12345`,
+			tracing: hatypes.TracingConfig{
+				TraceContext: true,
+			},
+			expFront: `
+    http-request set-header traceparent 00-%[uuid,regsub(-,,g)]-%[uuid,regsub(-,,g),bytes(0,16)]-01 if !{ req.hdr(traceparent) -m found }
+    http-request capture req.hdr(traceparent) len 55`,
 		},
+		// 3
 		{
-			waf:       "modsecurity",
-			wafmode:   "deny",
-			endpoints: []string{"10.0.0.101:12345"},
-			path:      "/sub",
-			backendExp: `
-    # path02 = d1.local/
-    # path01 = d1.local/sub
-    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
-    filter spoe engine modsecurity config /etc/haproxy/spoe-modsecurity.conf
-    http-request deny if { var(txn.modsec.code) -m int gt 0 } { var(txn.pathID) path01 }`,
-			modsecExp: `
-    timeout connect 1s
-    timeout server  2s
-    server modsec-spoa0 10.0.0.101:12345`,
+			tracing: hatypes.TracingConfig{
+				TraceContext:   true,
+				UniqueIDFormat: "%[uuid]",
+				UniqueIDHeader: "X-Correlation-ID",
+			},
+			expFront: `
+    unique-id-format %[uuid]
+    http-request set-header X-Correlation-ID %[unique-id] if !{ req.hdr(X-Correlation-ID) -m found }
+    http-request capture req.hdr(X-Correlation-ID) len 64
+    http-request set-header traceparent 00-%[uuid,regsub(-,,g)]-%[uuid,regsub(-,,g),bytes(0,16)]-01 if !{ req.hdr(traceparent) -m found }
+    http-request capture req.hdr(traceparent) len 55`,
 		},
 	}
 	for _, test := range testCases {
@@ -4253,41 +6624,38 @@ func TestModSecurity(t *testing.T) {
 		b = c.config.Backends().AcquireBackend("d1", "app", "8080")
 		b.Endpoints = []*hatypes.Endpoint{endpointS1}
 		h = c.config.Hosts().AcquireHost("d1.local")
-		if test.path == "" {
-			test.path = "/"
-		}
-		h.AddPath(b, test.path, hatypes.MatchBegin)
-		b.FindBackendPath(h.FindPath(test.path)[0].Link).WAF = hatypes.WAF{
-			Module: test.waf,
-			Mode:   test.wafmode,
-		}
-		if test.path != "/" {
-			h.AddPath(b, "/", hatypes.MatchBegin)
-		}
+		h.AddPath(b, "/", hatypes.MatchBegin)
 
-		globalModsec := &c.config.Global().ModSecurity
-		globalModsec.Endpoints = test.endpoints
-		globalModsec.Timeout.Connect = "1s"
-		globalModsec.Timeout.Server = "2s"
+		c.config.Global().Tracing = test.tracing
 
 		c.Update()
-
-		var modsec string
-		if test.modsecExp != "" {
-			modsec = `
-backend spoe-modsecurity
-    mode tcp` + test.modsecExp
-		}
 		c.checkConfig(`
 <<global>>
 <<defaults>>
 backend d1_app_8080
-    mode http` + test.backendExp + `
+    mode http
     server s1 172.17.0.11:8080 weight 100
 <<backends-default>>
-<<frontends-default>>
-<<support>>` + modsec)
-
+frontend _front_http
+    mode http
+    bind :80` + test.expFront + `
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all` + test.expFront + `
+    http-request set-var(req.path) path
+    http-request set-var(req.host) hdr(host),field(1,:),lower
+    http-request set-var(req.base) var(req.host),concat(\#,req.path)
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
 		c.logger.CompareLogging(defaultLogging)
 		c.teardown()
 	}
@@ -4468,6 +6836,159 @@ backend d3_app_8080
 INFO-V(2) updated main cfg and 2 backend file(s): [000 002]` + defaultLogging)
 }
 
+func TestInstanceRollback(t *testing.T) {
+	c := setupOptions(testOptions{
+		t:                 t,
+		rollbackOnFailure: true,
+	})
+	defer c.teardown()
+
+	b := c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h := c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	c.Update()
+	if reload := c.instance.LastReload(); reload == nil || !reflect.DeepEqual(reload.Reasons, []string{"initial"}) {
+		t.Errorf("expected initial reload, found: %+v", reload)
+	}
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	c.config.SyncConfig()
+	if err := c.instance.writeConfig(); err != nil {
+		t.Errorf("error writing config: %v", err)
+	}
+	c.instance.rollback()
+	if !c.instance.Degraded() {
+		t.Errorf("expected degraded instance after rollback")
+	}
+
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+
+	c.Update()
+	if c.instance.Degraded() {
+		t.Errorf("expected recovered instance after a successful reload")
+	}
+	expReasons := []string{"hosts", "backends", "degraded"}
+	if reload := c.instance.LastReload(); reload == nil || !reload.Success || !reflect.DeepEqual(reload.Reasons, expReasons) {
+		t.Errorf("expected successful reload due to %v, found: %+v", expReasons, reload)
+	}
+
+	c.logger.CompareLogging(defaultLogging + `
+WARN last known good configuration was restored
+INFO-V(2) added host 'd2.local'
+INFO-V(2) added backend 'd2_app_8080'
+INFO-V(2) need to reload due to config changes: [hosts backends]
+INFO (test) reload was skipped
+INFO haproxy recovered from a failed update, last known good configuration was updated
+INFO haproxy successfully reloaded (embedded)`)
+}
+
+func TestInstanceRollbackDynamicUpdate(t *testing.T) {
+	c := setupOptions(testOptions{
+		t:                 t,
+		rollbackOnFailure: true,
+	})
+	defer c.teardown()
+
+	b := c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h := c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	c.Update()
+
+	// changes applied via the runtime API were already sent to haproxy, the
+	// restored configuration files should be applied with a reload
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+	c.config.SyncConfig()
+	if err := c.instance.writeConfig(); err != nil {
+		t.Errorf("error writing config: %v", err)
+	}
+	c.instance.options.fakeCheckErr = fmt.Errorf("invalid server")
+	if err := c.instance.checkDynamicUpdate(); err == nil {
+		t.Errorf("expected validation error")
+	}
+	if !c.instance.Degraded() {
+		t.Errorf("expected degraded instance after rollback")
+	}
+	expReasons := []string{"rollback"}
+	if reload := c.instance.LastReload(); reload == nil || !reload.Success || !reflect.DeepEqual(reload.Reasons, expReasons) {
+		t.Errorf("expected successful reload due to %v, found: %+v", expReasons, reload)
+	}
+
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+
+	c.logger.CompareLogging(defaultLogging + `
+INFO (test) check was skipped
+ERROR error validating config file:
+invalid server
+WARN last known good configuration was restored
+INFO (test) reload was skipped
+INFO haproxy reloaded with the last known good configuration (embedded)`)
+
+	// without a last known good configuration there is nothing to reload
+	c.instance.options.RollbackOnFailure = false
+	if err := c.instance.checkDynamicUpdate(); err == nil {
+		t.Errorf("expected validation error")
+	}
+	c.logger.CompareLogging(`
+INFO (test) check was skipped
+ERROR error validating config file:
+invalid server`)
+}
+
+func TestInstanceApplyFleet(t *testing.T) {
+	var calls []string
+	mutex := &sync.Mutex{}
+	var members []string
+	for j := 0; j < 2; j++ {
+		agent := &fleetAgentMock{name: fmt.Sprintf("m%d", j), mutex: mutex, calls: &calls}
+		if j == 1 {
+			agent.failPath = "/apply"
+		}
+		server := httptest.NewServer(agent)
+		defer server.Close()
+		members = append(members, server.URL)
+	}
+	c := setup(t)
+	defer c.teardown()
+
+	f := newFleet(c.logger, &FleetOptions{Members: members, Token: "s3cr3t"})
+	payload, _ := f.buildPayload()
+	c.instance.applyFleet(f)
+	if !c.instance.Degraded() {
+		t.Errorf("expected degraded instance after a failed apply")
+	}
+	expCalls := []string{"m0 PUT /config", "m1 PUT /config", "m0 POST /apply", "m1 POST /apply"}
+	if !reflect.DeepEqual(calls, expCalls) {
+		t.Errorf("calls differ - expected: %v - actual: %v", expCalls, calls)
+	}
+	c.logger.CompareLogging(fmt.Sprintf(`
+INFO-V(2) configuration (%d bytes) pushed to 2 fleet member(s)
+ERROR error applying configuration to the fleet: error applying configuration to %s: unexpected status code: 500
+`, len(payload), members[1]))
+}
+
 /* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * *
  *
  *  BUILDERS
@@ -4483,8 +7004,9 @@ type testConfig struct {
 }
 
 type testOptions struct {
-	t          *testing.T
-	shardCount int
+	t                 *testing.T
+	shardCount        int
+	rollbackOnFailure bool
 }
 
 func setup(t *testing.T) *testConfig {
@@ -4499,10 +7021,11 @@ func setupOptions(options testOptions) *testConfig {
 		t.Errorf("error creating tempdir: %v", err)
 	}
 	instance := CreateInstance(logger, InstanceOptions{
-		HAProxyCfgDir:  tempdir,
-		HAProxyMapsDir: tempdir,
-		Metrics:        helper_test.NewMetricsMock(),
-		BackendShards:  options.shardCount,
+		HAProxyCfgDir:     tempdir,
+		HAProxyMapsDir:    tempdir,
+		Metrics:           helper_test.NewMetricsMock(),
+		BackendShards:     options.shardCount,
+		RollbackOnFailure: options.rollbackOnFailure,
 		//
 		fake: true,
 	}).(*instance)
@@ -4669,6 +7192,9 @@ func (c *testConfig) checkConfigFile(expected, fileName string) {
     hard-stop-after 15m
     lua-prepend-path /etc/haproxy/lua/?.lua
     lua-load /etc/haproxy/lua/auth-request.lua
+    lua-load /etc/haproxy/lua/oidc.lua
+    lua-load /etc/haproxy/lua/mirror.lua
+    lua-load /etc/haproxy/lua/grpc-web.lua
     lua-load /etc/haproxy/lua/services.lua
     ssl-dh-param-file /var/haproxy/tls/dhparam.pem
     ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
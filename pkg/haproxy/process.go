@@ -0,0 +1,370 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	hautils "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// Process modes, defines how the controller relates with the haproxy process
+const (
+	// ProcessEmbedded starts and reloads haproxy as a child process of the controller
+	ProcessEmbedded = "embedded"
+	// ProcessSidecar reloads haproxy running in master-worker mode in another
+	// container of the same pod, via its master socket
+	ProcessSidecar = "sidecar"
+	// ProcessExternal pushes the configuration to a fleet of haproxy instances
+	// running outside of the cluster
+	ProcessExternal = "external"
+)
+
+// process abstracts the lifecycle of the haproxy process
+type process interface {
+	// start waits or starts haproxy, called before the first reload
+	start() error
+	// check validates the configuration files
+	check() error
+	// reload applies the configuration files
+	reload(global *hatypes.Global) error
+	// stop gracefully stops haproxy, if managed by the controller
+	stop() error
+	// oldProcs lists the PIDs of the old haproxy processes, which are still
+	// running and waiting for long lived connections to finish
+	oldProcs() ([]int, error)
+	// canStopOldProcs is true if old processes can be hard-stopped by the controller
+	canStopOldProcs() bool
+	// command sends commands to the haproxy's runtime API
+	command(socket string, observer func(duration time.Duration), command ...string) ([]string, error)
+}
+
+func createProcess(logger types.Logger, options *InstanceOptions) process {
+	switch options.Process {
+	case ProcessSidecar:
+		return &sidecarProcess{
+			logger:       logger,
+			masterSocket: options.MasterSocket,
+			stopCh:       options.StopCh,
+			cfgDir:       options.HAProxyCfgDir,
+		}
+	case ProcessExternal:
+		return &externalProcess{
+			fleet:  newFleet(logger, options.Fleet),
+			cfgDir: options.HAProxyCfgDir,
+		}
+	default:
+		return &embeddedProcess{
+			logger:         logger,
+			reloadStrategy: options.ReloadStrategy,
+			cfgDir:         options.HAProxyCfgDir,
+			pidFile:        options.HAProxyPidFile,
+			drainTimeout:   options.DrainTimeout,
+			handoffSocket:  options.HandoffSocket,
+			running:        haproxyRunning,
+		}
+	}
+}
+
+// embeddedProcess manages haproxy as a child process of the controller
+type embeddedProcess struct {
+	logger         types.Logger
+	reloadStrategy string
+	cfgDir         string
+	pidFile        string
+	drainTimeout   time.Duration
+	handoffSocket  string
+	running        func(pid int) bool
+	mutex          sync.Mutex
+	curPids        []int
+	oldPids        []int
+}
+
+func (p *embeddedProcess) start() error {
+	// the reload script starts haproxy if it's not running
+	return nil
+}
+
+func (p *embeddedProcess) check() error {
+	return checkConfig(p.cfgDir)
+}
+
+func (p *embeddedProcess) reload(global *hatypes.Global) error {
+	// TODO Move all magic strings to a single place
+	// the handoff socket is used to take over the listening sockets of the
+	// haproxy of a previous controller pod on the same node, see --socket-handoff-dir
+	out, err := exec.Command("/haproxy-reload.sh", p.reloadStrategy, p.cfgDir, p.handoffSocket, p.pidFile).CombinedOutput()
+	outstr := string(out)
+	if len(outstr) > 0 {
+		p.logger.Warn("output from haproxy:\n%v", outstr)
+	}
+	if err != nil {
+		return err
+	}
+	if err := p.trackPids(); err != nil {
+		p.logger.Warn("error reading haproxy pid file: %v", err)
+	}
+	return nil
+}
+
+// trackPids reads the current haproxy processes from the pid file. The
+// processes that were current in the last reload and were replaced are
+// tracked as old ones, so only processes started by the controller are
+// listed and stopped.
+func (p *embeddedProcess) trackPids() error {
+	pids, err := hautils.HAProxyPids(p.pidFile)
+	if err != nil {
+		return err
+	}
+	cur := make(map[int]bool, len(pids))
+	for _, pid := range pids {
+		cur[pid] = true
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, pid := range p.curPids {
+		if !cur[pid] {
+			p.oldPids = append(p.oldPids, pid)
+		}
+	}
+	p.curPids = pids
+	return nil
+}
+
+func (p *embeddedProcess) stop() error {
+	pids, err := hautils.HAProxyPids(p.pidFile)
+	if err != nil {
+		return err
+	}
+	for _, pid := range pids {
+		// SIGUSR1 is the haproxy's soft-stop, which waits for running connections
+		if err := syscall.Kill(pid, syscall.SIGUSR1); err != nil {
+			return fmt.Errorf("error stopping haproxy process %d: %w", pid, err)
+		}
+	}
+	p.logger.Info("haproxy soft-stop sent to %d process(es)", len(pids))
+	if p.drainTimeout <= 0 {
+		return nil
+	}
+	// old processes are also finishing their running connections
+	oldPids, _ := p.oldProcs()
+	pids = append(pids, oldPids...)
+	p.logger.Info("waiting up to %s for %d haproxy process(es) to finish", p.drainTimeout.String(), len(pids))
+	running := waitProcs(pids, p.drainTimeout, time.Second, p.running)
+	if len(running) > 0 {
+		for _, pid := range running {
+			// SIGTERM is the haproxy's hard-stop, which closes all the connections
+			_ = syscall.Kill(pid, syscall.SIGTERM)
+		}
+		return fmt.Errorf("drain timeout, hard-stop sent to %d haproxy process(es)", len(running))
+	}
+	p.logger.Info("haproxy finished all the running connections")
+	return nil
+}
+
+// oldProcs lists the old processes that are still running, from the
+// oldest to the newest one
+func (p *embeddedProcess) oldProcs() ([]int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var running []int
+	for _, pid := range p.oldPids {
+		if p.running(pid) {
+			running = append(running, pid)
+		}
+	}
+	p.oldPids = running
+	return append([]int(nil), running...), nil
+}
+
+func (p *embeddedProcess) canStopOldProcs() bool {
+	return true
+}
+
+func (p *embeddedProcess) command(socket string, observer func(duration time.Duration), command ...string) ([]string, error) {
+	return hautils.HAProxyCommand(socket, observer, command...)
+}
+
+// sidecarProcess manages haproxy running in master-worker mode in another
+// container of the same pod, via its master socket
+type sidecarProcess struct {
+	logger       types.Logger
+	masterSocket string
+	stopCh       chan struct{}
+	cfgDir       string
+}
+
+func (p *sidecarProcess) start() error {
+	// wait until the external haproxy is running
+	// and successfully listening to the master socket.
+	var j int
+	p.logger.Info("waiting for the external haproxy...")
+	for {
+		var err error
+		if _, err = hautils.HAProxyCommand(p.masterSocket, nil, "show proc"); err == nil {
+			return nil
+		}
+		j++
+		if j%10 == 0 {
+			p.logger.Info("cannot connect to the master socket '%s': %v", p.masterSocket, err)
+		}
+		select {
+		case <-p.stopCh:
+			return fmt.Errorf("received sigterm")
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (p *sidecarProcess) check() error {
+	// TODO check config on remote haproxy
+	return nil
+}
+
+func (p *sidecarProcess) reload(global *hatypes.Global) error {
+	if _, err := hautils.HAProxyCommand(p.masterSocket, nil, "reload"); err != nil {
+		return fmt.Errorf("error sending reload to master socket: %w", err)
+	}
+	out, err := hautils.HAProxyProcs(p.masterSocket)
+	if err != nil {
+		return fmt.Errorf("error reading procs from master socket: %w", err)
+	}
+	if len(out.Workers) == 0 {
+		return fmt.Errorf("external haproxy was not successfully reloaded")
+	}
+	return nil
+}
+
+func (p *sidecarProcess) stop() error {
+	// the sidecar container has its own lifecycle
+	return nil
+}
+
+func (p *sidecarProcess) oldProcs() ([]int, error) {
+	procs, err := hautils.HAProxyProcs(p.masterSocket)
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, len(procs.OldWorkers))
+	for j, proc := range procs.OldWorkers {
+		pids[j] = proc.PID
+	}
+	return pids, nil
+}
+
+func (p *sidecarProcess) canStopOldProcs() bool {
+	// should use worker-max-reloads instead
+	return false
+}
+
+func (p *sidecarProcess) command(socket string, observer func(duration time.Duration), command ...string) ([]string, error) {
+	return hautils.HAProxyCommand(socket, observer, command...)
+}
+
+// externalProcess pushes the configuration to a fleet of haproxy
+// instances running outside of the cluster
+type externalProcess struct {
+	fleet  *fleet
+	cfgDir string
+}
+
+func (p *externalProcess) start() error {
+	return nil
+}
+
+func (p *externalProcess) check() error {
+	// the haproxy binary is also available in the controller, fleet
+	// members validate the configuration again before reloading
+	return checkConfig(p.cfgDir)
+}
+
+func (p *externalProcess) reload(global *hatypes.Global) error {
+	return p.fleet.reload()
+}
+
+func (p *externalProcess) stop() error {
+	// fleet members have their own lifecycle
+	return nil
+}
+
+func (p *externalProcess) oldProcs() ([]int, error) {
+	return nil, fmt.Errorf("old processes of the fleet members cannot be read")
+}
+
+func (p *externalProcess) canStopOldProcs() bool {
+	return false
+}
+
+func (p *externalProcess) command(socket string, observer func(duration time.Duration), command ...string) ([]string, error) {
+	return p.fleet.command(socket, observer, command...)
+}
+
+// waitProcs waits until all the processes finish or the timeout expires,
+// and returns the processes that are still running.
+func waitProcs(pids []int, timeout, interval time.Duration, running func(pid int) bool) []int {
+	deadline := time.Now().Add(timeout)
+	for {
+		var remaining []int
+		for _, pid := range pids {
+			if running(pid) {
+				remaining = append(remaining, pid)
+			}
+		}
+		if len(remaining) == 0 || !time.Now().Before(deadline) {
+			return remaining
+		}
+		pids = remaining
+		time.Sleep(interval)
+	}
+}
+
+// haproxyRunning is true if pid is a running haproxy process. The command
+// name is also checked, so a finished process whose PID was reused by
+// another command isn't considered running.
+func haproxyRunning(pid int) bool {
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// the state follows the command name, which is between parenthesis;
+	// a zombie process has already finished
+	end := bytes.LastIndexByte(stat, ')')
+	start := bytes.IndexByte(stat, '(')
+	if start < 0 || end < start || string(stat[start+1:end]) != "haproxy" {
+		return false
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func checkConfig(cfgDir string) error {
+	// TODO Move all magic strings to a single place
+	out, err := exec.Command("haproxy", "-c", "-f", cfgDir).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf(string(out))
+	}
+	return nil
+}
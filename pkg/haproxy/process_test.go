@@ -0,0 +1,171 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestCreateProcess(t *testing.T) {
+	testCases := []struct {
+		process         string
+		expType         string
+		canStopOldProcs bool
+	}{
+		// 0
+		{
+			process:         "",
+			expType:         "*haproxy.embeddedProcess",
+			canStopOldProcs: true,
+		},
+		// 1
+		{
+			process:         ProcessEmbedded,
+			expType:         "*haproxy.embeddedProcess",
+			canStopOldProcs: true,
+		},
+		// 2
+		{
+			process: ProcessSidecar,
+			expType: "*haproxy.sidecarProcess",
+		},
+		// 3
+		{
+			process: ProcessExternal,
+			expType: "*haproxy.externalProcess",
+		},
+	}
+	for i, test := range testCases {
+		p := createProcess(helper_test.NewLoggerMock(t), &InstanceOptions{
+			Process: test.process,
+			Fleet:   &FleetOptions{},
+		})
+		if actual := fmt.Sprintf("%T", p); actual != test.expType {
+			t.Errorf("process type differs on %d - expected: %s - actual: %s", i, test.expType, actual)
+		}
+		if p.canStopOldProcs() != test.canStopOldProcs {
+			t.Errorf("canStopOldProcs differs on %d - expected: %v", i, test.canStopOldProcs)
+		}
+	}
+}
+
+func TestWaitProcs(t *testing.T) {
+	testCases := []struct {
+		pids     []int
+		finished map[int]int
+		timeout  time.Duration
+		expected []int
+	}{
+		// 0
+		{
+			pids: []int{10, 11},
+		},
+		// 1
+		{
+			pids:     []int{10, 11},
+			finished: map[int]int{10: 2, 11: 3},
+			timeout:  time.Second,
+		},
+		// 2
+		{
+			pids:     []int{10, 11, 12},
+			finished: map[int]int{10: 2, 11: 1000},
+			timeout:  20 * time.Millisecond,
+			expected: []int{11},
+		},
+	}
+	for i, test := range testCases {
+		checks := map[int]int{}
+		running := func(pid int) bool {
+			checks[pid]++
+			return checks[pid] < test.finished[pid]
+		}
+		remaining := waitProcs(test.pids, test.timeout, time.Millisecond, running)
+		if !reflect.DeepEqual(remaining, test.expected) {
+			t.Errorf("remaining processes differ on %d - expected: %v - actual: %v", i, test.expected, remaining)
+		}
+	}
+}
+
+func TestEmbeddedOldProcs(t *testing.T) {
+	testCases := []struct {
+		pidFiles []string
+		finished []int
+		expected []int
+	}{
+		// 0
+		{
+			pidFiles: []string{"10\n"},
+		},
+		// 1
+		{
+			pidFiles: []string{"10\n", "11\n"},
+			expected: []int{10},
+		},
+		// 2
+		{
+			pidFiles: []string{"10\n", "11\n", "11\n", "12\n", "13\n"},
+			expected: []int{10, 11, 12},
+		},
+		// 3
+		{
+			pidFiles: []string{"10\n", "11\n", "12\n", "13\n"},
+			finished: []int{11},
+			expected: []int{10, 12},
+		},
+		// 4
+		{
+			pidFiles: []string{"10\n11\n", "12\n13\n", "14\n"},
+			finished: []int{10},
+			expected: []int{11, 12, 13},
+		},
+	}
+	for i, test := range testCases {
+		dir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Errorf("error creating tempdir: %v", err)
+			continue
+		}
+		finished := map[int]bool{}
+		for _, pid := range test.finished {
+			finished[pid] = true
+		}
+		p := &embeddedProcess{
+			pidFile: filepath.Join(dir, "haproxy.pid"),
+			running: func(pid int) bool { return !finished[pid] },
+		}
+		for _, pidFile := range test.pidFiles {
+			_ = ioutil.WriteFile(p.pidFile, []byte(pidFile), 0644)
+			if err := p.trackPids(); err != nil {
+				t.Errorf("%d should not return an error: %v", i, err)
+			}
+		}
+		pids, _ := p.oldProcs()
+		if !reflect.DeepEqual(pids, test.expected) {
+			t.Errorf("old processes differ on %d - expected: %v - actual: %v", i, test.expected, pids)
+		}
+		_ = os.RemoveAll(dir)
+	}
+}
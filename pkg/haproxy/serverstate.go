@@ -0,0 +1,141 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+// TODO Move all magic strings to a single place
+const serverStateFile = "/var/lib/haproxy/state-global"
+
+// Fields of the `show servers state` output, version 1
+const (
+	stateFieldBackendName = 1
+	stateFieldServerName  = 3
+	stateFieldServerAddr  = 4
+	stateFieldUserWeight  = 7
+	stateFieldInitWeight  = 8
+	stateFieldServerPort  = 18
+	stateFieldMinCount    = 19
+)
+
+// saveServerState reads the state of the servers from the running haproxy
+// and saves the entries that still match the new configuration in the file
+// haproxy reads on startup. The admin socket is also tried before the first
+// reload, since a sidecar haproxy can outlive a controller restart.
+func (i *instance) saveServerState() error {
+	var state []string
+	out, err := i.process.command(i.config.Global().AdminSocket, nil, "show servers state")
+	if err != nil {
+		if i.up {
+			return fmt.Errorf("error reading server state: %w", err)
+		}
+		if _, err := os.Stat(serverStateFile); err == nil {
+			// haproxy isn't running yet, preserve the state saved by a previous instance
+			return nil
+		}
+	} else if len(out) > 0 {
+		state = filterServerState(strings.Split(out[0], "\n"), i.config.Backends())
+	}
+	if len(state) == 0 {
+		// haproxy warns if the state file is missing
+		state = []string{"#"}
+	}
+	return writeServerState(serverStateFile, state)
+}
+
+func writeServerState(filename string, state []string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".state-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(strings.Join(state, "\n") + "\n")
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// filterServerState removes the servers whose backend or endpoint don't
+// exist in the new configuration, so an empty slot of dynamic-scaling or a
+// server reused by another endpoint doesn't inherit a stale state. Weights
+// are reset if the configured weight changed, otherwise changes made in the
+// runtime API take precedence.
+func filterServerState(state []string, backends *hatypes.Backends) []string {
+	filtered := make([]string, 0, len(state))
+	for _, line := range state {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' || !strings.Contains(line, " ") {
+			// comments and the version number
+			filtered = append(filtered, line)
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < stateFieldMinCount {
+			continue
+		}
+		backend := backends.Items()[fields[stateFieldBackendName]]
+		if backend == nil {
+			continue
+		}
+		name := fields[stateFieldServerName]
+		if backend.Resolver != "" {
+			// server-template, addresses are assigned by the resolver
+			if idx, err := strconv.Atoi(strings.TrimPrefix(name, "srv")); err == nil && idx > 0 && idx <= len(backend.Endpoints) {
+				filtered = append(filtered, line)
+			}
+			continue
+		}
+		ep := findServerStateEndpoint(backend, name)
+		if ep == nil || !ep.Enabled ||
+			ep.IP != fields[stateFieldServerAddr] ||
+			strconv.Itoa(ep.Port) != fields[stateFieldServerPort] {
+			continue
+		}
+		if weight := strconv.Itoa(ep.Weight); fields[stateFieldInitWeight] != weight {
+			fields[stateFieldUserWeight] = weight
+			fields[stateFieldInitWeight] = weight
+			line = strings.Join(fields, " ")
+		}
+		filtered = append(filtered, line)
+	}
+	return filtered
+}
+
+func findServerStateEndpoint(backend *hatypes.Backend, name string) *hatypes.Endpoint {
+	for _, ep := range backend.Endpoints {
+		if ep.Name == name {
+			return ep
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"strings"
+	"testing"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+func TestFilterServerState(t *testing.T) {
+	const header = `1
+# be_id be_name srv_id srv_name srv_addr srv_op_state srv_admin_state srv_uweight srv_iweight srv_time_since_last_change srv_check_status srv_check_result srv_check_health srv_check_state srv_agent_state bk_f_forced_id srv_f_forced_id srv_fqdn srv_port srvrecord`
+	testCases := []struct {
+		state    string
+		expected string
+	}{
+		// 0
+		{
+			state:    header,
+			expected: header,
+		},
+		// 1
+		{
+			state: header + `
+3 default_app_8080 1 srv001 172.17.0.11 2 0 1 1 120 6 3 4 6 0 0 0 - 8080 -
+3 default_app_8080 2 srv002 172.17.0.12 0 1 1 1 120 6 3 4 6 0 0 0 - 8080 -`,
+			expected: header + `
+3 default_app_8080 1 srv001 172.17.0.11 2 0 1 1 120 6 3 4 6 0 0 0 - 8080 -
+3 default_app_8080 2 srv002 172.17.0.12 0 1 1 1 120 6 3 4 6 0 0 0 - 8080 -`,
+		},
+		// 2
+		{
+			state: header + `
+3 default_app_8080 1 srv001 172.17.0.11 2 0 1 1 120 6 3 4 6 0 0 0 - 8080 -
+3 default_app_8080 2 srv002 172.17.0.99 2 0 1 1 120 6 3 4 6 0 0 0 - 8080 -
+3 default_app_8080 3 srv003 127.0.0.1 0 1 1 1 120 6 3 4 6 0 0 0 - 1023 -
+4 default_old_8080 1 srv001 172.17.0.21 2 0 1 1 120 6 3 4 6 0 0 0 - 8080 -`,
+			expected: header + `
+3 default_app_8080 1 srv001 172.17.0.11 2 0 1 1 120 6 3 4 6 0 0 0 - 8080 -`,
+		},
+		// 3
+		{
+			state: header + `
+3 default_app_8080 1 srv001 172.17.0.11 2 0 1 1 120 6 3 4 6 0 0 0 - 8081 -
+3 default_app_8080 2 srv002 172.17.0.12 2 0 1 1 120`,
+			expected: header,
+		},
+		// 4
+		{
+			state: header + `
+5 default_weight_8080 1 srv001 172.17.0.31 2 0 5 5 120 6 3 4 6 0 0 0 - 8080 -
+5 default_weight_8080 2 srv002 172.17.0.32 2 0 0 2 120 6 3 4 6 0 0 0 - 8080 -`,
+			expected: header + `
+5 default_weight_8080 1 srv001 172.17.0.31 2 0 2 2 120 6 3 4 6 0 0 0 - 8080 -
+5 default_weight_8080 2 srv002 172.17.0.32 2 0 0 2 120 6 3 4 6 0 0 0 - 8080 -`,
+		},
+		// 5
+		{
+			state: header + `
+6 default_dns_8080 1 srv1 172.17.0.41 2 0 1 1 120 6 3 4 6 0 0 0 dns.local 8080 -
+6 default_dns_8080 2 srv2 172.17.0.42 2 0 1 1 120 6 3 4 6 0 0 0 dns.local 8080 -
+6 default_dns_8080 3 srv3 - 0 5 1 1 120 1 0 0 0 0 0 0 - 0 -`,
+			expected: header + `
+6 default_dns_8080 1 srv1 172.17.0.41 2 0 1 1 120 6 3 4 6 0 0 0 dns.local 8080 -
+6 default_dns_8080 2 srv2 172.17.0.42 2 0 1 1 120 6 3 4 6 0 0 0 dns.local 8080 -`,
+		},
+	}
+	backends := hatypes.CreateBackends(0)
+	app := backends.AcquireBackend("default", "app", "8080")
+	app.AcquireEndpoint("172.17.0.11", 8080, "").Weight = 1
+	app.AcquireEndpoint("172.17.0.12", 8080, "").Weight = 1
+	app.AddEmptyEndpoint()
+	weight := backends.AcquireBackend("default", "weight", "8080")
+	weight.AcquireEndpoint("172.17.0.31", 8080, "").Weight = 2
+	weight.AcquireEndpoint("172.17.0.32", 8080, "").Weight = 2
+	dns := backends.AcquireBackend("default", "dns", "8080")
+	dns.Resolver = "k8s"
+	dns.AcquireEndpoint("172.17.0.41", 8080, "")
+	dns.AcquireEndpoint("172.17.0.42", 8080, "")
+	for i, test := range testCases {
+		actual := strings.Join(filterServerState(strings.Split(test.state, "\n"), backends), "\n")
+		if actual != test.expected {
+			t.Errorf("server state differs on %d - expected:\n%s\nactual:\n%s", i, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// scratchBackend is the configuration used to check a backend snippet in
+// isolation. ACLs commonly referenced by backend snippets are declared, so
+// snippets using them are not refused.
+const scratchBackend = `defaults
+    mode http
+    timeout connect 5s
+    timeout client 50s
+    timeout server 50s
+backend _snippet_check
+    acl https-request ssl_fc
+    acl fronting-proxy always_false
+`
+
+// maxSnippetResults limits the number of cached results. The cache is
+// cleared when the limit is reached.
+const maxSnippetResults = 1024
+
+// SnippetChecker validates configuration snippets in isolation, adding
+// them to a scratch configuration file which is checked by haproxy.
+// Results are cached, so an unchanged snippet is checked only once.
+type SnippetChecker struct {
+	mutex   sync.Mutex
+	results map[string]error
+	check   func(cfgFile string) error
+}
+
+// NewSnippetChecker ...
+func NewSnippetChecker() *SnippetChecker {
+	return &SnippetChecker{
+		results: map[string]error{},
+		check:   checkConfig,
+	}
+}
+
+// CheckBackend checks if the lines of a snippet are valid in a backend section.
+func (s *SnippetChecker) CheckBackend(snippet []string) error {
+	cfg := scratchBackend + "    " + strings.Join(snippet, "\n    ") + "\n"
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err, found := s.results[cfg]; found {
+		return err
+	}
+	f, err := ioutil.TempFile("", "snippet-*.cfg")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(cfg)
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+	err = s.check(f.Name())
+	if len(s.results) >= maxSnippetResults {
+		s.results = map[string]error{}
+	}
+	s.results[cfg] = err
+	return err
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSnippetCheckerBackend(t *testing.T) {
+	var checked []string
+	s := NewSnippetChecker()
+	s.check = func(cfgFile string) error {
+		content, err := ioutil.ReadFile(cfgFile)
+		if err != nil {
+			return err
+		}
+		cfg := string(content)
+		checked = append(checked, cfg)
+		if strings.Contains(cfg, "invalid") {
+			return fmt.Errorf("unknown keyword 'invalid'")
+		}
+		return nil
+	}
+	testCases := []struct {
+		snippet  []string
+		expError string
+		expCheck bool
+	}{
+		// 0
+		{
+			snippet:  []string{"http-request set-header X-Id 1", "timeout server 1m"},
+			expCheck: true,
+		},
+		// 1
+		{
+			snippet:  []string{"invalid keyword"},
+			expError: "unknown keyword 'invalid'",
+			expCheck: true,
+		},
+		// 2
+		{
+			snippet: []string{"http-request set-header X-Id 1", "timeout server 1m"},
+		},
+		// 3
+		{
+			snippet:  []string{"invalid keyword"},
+			expError: "unknown keyword 'invalid'",
+		},
+	}
+	for i, test := range testCases {
+		checked = nil
+		err := s.CheckBackend(test.snippet)
+		var actualError string
+		if err != nil {
+			actualError = err.Error()
+		}
+		if actualError != test.expError {
+			t.Errorf("error differs on %d - expected: '%s' - actual: '%s'", i, test.expError, actualError)
+		}
+		if actualCheck := len(checked) > 0; actualCheck != test.expCheck {
+			t.Errorf("check differs on %d - expected: %t - actual: %t", i, test.expCheck, actualCheck)
+		}
+		if test.expCheck {
+			expected := "\nbackend _snippet_check\n    acl https-request ssl_fc\n    acl fronting-proxy always_false\n    " + strings.Join(test.snippet, "\n    ") + "\n"
+			if !strings.HasSuffix(checked[0], expected) {
+				t.Errorf("config differs on %d - expected suffix: %s - actual: %s", i, expected, checked[0])
+			}
+		}
+	}
+}
@@ -159,6 +159,68 @@ func (b *Backend) AddBackendPath(link PathLink) *BackendPath {
 	return backendPath
 }
 
+// FindSourceList ...
+func (b *Backend) FindSourceList(name string) *SourceList {
+	for _, list := range b.SourceLists {
+		if list.Name == name {
+			return list
+		}
+	}
+	return nil
+}
+
+// AddSourceList adds a list of IPs and CIDRs shared by the access
+// configs of the backend. The first list with the same name wins.
+func (b *Backend) AddSourceList(name string, entries []string) *SourceList {
+	sourceList := b.FindSourceList(name)
+	if sourceList != nil {
+		return sourceList
+	}
+	sourceList = &SourceList{
+		Name:    name,
+		Entries: entries,
+	}
+	b.SourceLists = append(b.SourceLists, sourceList)
+	sort.Slice(b.SourceLists, func(i, j int) bool {
+		return b.SourceLists[i].Name < b.SourceLists[j].Name
+	})
+	return sourceList
+}
+
+// Caches lists the distinct caches used by the paths of the backend.
+func (b *Backend) Caches() []Cache {
+	var caches []Cache
+	names := map[string]bool{}
+	for _, path := range b.Paths {
+		cache := path.Cache
+		if cache.TotalMaxSize > 0 && !names[cache.Name()] {
+			names[cache.Name()] = true
+			caches = append(caches, cache)
+		}
+	}
+	sort.Slice(caches, func(i, j int) bool {
+		return caches[i].Name() < caches[j].Name()
+	})
+	return caches
+}
+
+// RateLimits lists the distinct rate limits used by the paths of the backend.
+func (b *Backend) RateLimits() []RateLimit {
+	var rateLimits []RateLimit
+	names := map[string]bool{}
+	for _, path := range b.Paths {
+		rateLimit := path.RateLimit
+		if rateLimit.Name != "" && !names[rateLimit.Name] {
+			names[rateLimit.Name] = true
+			rateLimits = append(rateLimits, rateLimit)
+		}
+	}
+	sort.Slice(rateLimits, func(i, j int) bool {
+		return rateLimits[i].Name < rateLimits[j].Name
+	})
+	return rateLimits
+}
+
 // Hostnames ...
 func (b *Backend) Hostnames() []string {
 	hmap := make(map[string]struct{}, len(b.Paths))
@@ -213,6 +275,16 @@ func (b *Backend) HasHSTS() bool {
 	return false
 }
 
+// HasMirror ...
+func (b *Backend) HasMirror() bool {
+	for _, path := range b.Paths {
+		if path.Mirror.AuthBackendName != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // HasModsec is a method to verify if a Backend has ModSecurity Enabled
 func (b *Backend) HasModsec() bool {
 	for _, path := range b.Paths {
@@ -223,6 +295,26 @@ func (b *Backend) HasModsec() bool {
 	return false
 }
 
+// HasBlockUserAgents ...
+func (b *Backend) HasBlockUserAgents() bool {
+	for _, path := range b.Paths {
+		if len(path.BlockUA.Patterns) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// HasCoraza is a method to verify if a Backend has Coraza Enabled
+func (b *Backend) HasCoraza() bool {
+	for _, path := range b.Paths {
+		if path.WAF.Module == "coraza" {
+			return true
+		}
+	}
+	return false
+}
+
 // HasSSLRedirect ...
 func (b *Backend) HasSSLRedirect() bool {
 	for _, path := range b.Paths {
@@ -366,6 +458,17 @@ func (b *BackendPathConfig) PathIDs(index int) []string {
 	return pathIDs
 }
 
+// Name ...
+func (c Cache) Name() string {
+	return fmt.Sprintf("cache_%dm_%d_%ds", c.TotalMaxSize, c.MaxObjectSize, c.MaxAge)
+}
+
+// TableName is the name of the backend that declares the stick table of
+// the rate limit.
+func (r RateLimit) TableName() string {
+	return "_ratelimit_" + r.Name
+}
+
 // IsEmpty ...
 func (ep *Endpoint) IsEmpty() bool {
 	return ep.IP == "127.0.0.1"
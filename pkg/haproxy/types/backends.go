@@ -182,6 +182,95 @@ func (b *Backends) BuildSortedItems() []*Backend {
 	return nil
 }
 
+// Caches lists the distinct caches used by all the backends. The main cfg
+// declares all of them, regardless of the backend shards.
+func (b *Backends) Caches() []Cache {
+	var caches []Cache
+	names := map[string]bool{}
+	for _, backend := range b.items {
+		for _, cache := range backend.Caches() {
+			if !names[cache.Name()] {
+				names[cache.Name()] = true
+				caches = append(caches, cache)
+			}
+		}
+	}
+	sort.Slice(caches, func(i, j int) bool {
+		return caches[i].Name() < caches[j].Name()
+	})
+	return caches
+}
+
+// RateLimits lists the distinct rate limits used by all the backends. The
+// main cfg declares the stick tables of all of them, regardless of the
+// backend shards.
+func (b *Backends) RateLimits() []RateLimit {
+	var rateLimits []RateLimit
+	names := map[string]bool{}
+	for _, backend := range b.items {
+		for _, rateLimit := range backend.RateLimits() {
+			if !names[rateLimit.Name] {
+				names[rateLimit.Name] = true
+				rateLimits = append(rateLimits, rateLimit)
+			}
+		}
+	}
+	sort.Slice(rateLimits, func(i, j int) bool {
+		return rateLimits[i].Name < rateLimits[j].Name
+	})
+	return rateLimits
+}
+
+// HasBlockUserAgents returns true if any backend blocks user agents, so the
+// stick table that counts the blocked requests should be declared.
+func (b *Backends) HasBlockUserAgents() bool {
+	for _, backend := range b.items {
+		if backend.HasBlockUserAgents() {
+			return true
+		}
+	}
+	return false
+}
+
+// HasGRPCWeb returns true if any backend translates gRPC-Web requests, so
+// the frontend that receives the translated requests should be declared.
+func (b *Backends) HasGRPCWeb() bool {
+	for _, backend := range b.items {
+		if backend.GRPCWeb {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildRoutedItems lists the backends which have alternate routes, sorted
+// by their IDs. Routes are declared in the frontends, so the main cfg needs
+// them regardless of the backend shards.
+func (b *Backends) BuildRoutedItems() []*Backend {
+	routed := map[string]*Backend{}
+	for id, backend := range b.items {
+		if len(backend.Routes) > 0 {
+			routed[id] = backend
+		}
+	}
+	return b.buildSortedItems(routed)
+}
+
+// CorazaRulesets lists the distinct Coraza rulesets used by all the backends,
+// each one is declared as a distinct SPOE scope.
+func (b *Backends) CorazaRulesets() []string {
+	var rulesets []string
+	names := map[string]bool{}
+	for _, backend := range b.items {
+		if backend.HasCoraza() && !names[backend.WAFRuleset] {
+			names[backend.WAFRuleset] = true
+			rulesets = append(rulesets, backend.WAFRuleset)
+		}
+	}
+	sort.Strings(rulesets)
+	return rulesets
+}
+
 // BuildSortedShard ...
 func (b *Backends) BuildSortedShard(shardRef int) []*Backend {
 	return b.buildSortedItems(b.shards[shardRef])
@@ -205,9 +294,10 @@ func (b *Backends) BuildUsedAuthBackends() map[string]bool {
 	usedNames := map[string]bool{}
 	for _, backend := range b.items {
 		for _, path := range backend.Paths {
-			name := path.AuthExternal.AuthBackendName
-			if name != "" {
-				usedNames[name] = true
+			for _, name := range []string{path.AuthExternal.AuthBackendName, path.Mirror.AuthBackendName} {
+				if name != "" {
+					usedNames[name] = true
+				}
 			}
 		}
 	}
@@ -85,6 +85,16 @@ func hasBackend(backends []BackendID, backend BackendID) bool {
 	return false
 }
 
+// FindExtra ...
+func (f *Frontend) FindExtra(name string) *ExtraFrontend {
+	for _, extra := range f.Extras {
+		if extra.Name == name {
+			return extra
+		}
+	}
+	return nil
+}
+
 // Changed ...
 func (f *Frontend) Changed() bool {
 	return f.changed
@@ -99,3 +109,8 @@ func (f *Frontend) Commit() {
 func (f *Frontend) String() string {
 	return fmt.Sprintf("%+v", *f)
 }
+
+// String ...
+func (r RedirectTo) String() string {
+	return fmt.Sprintf("%s-%d", r.Type, r.Code)
+}
@@ -121,6 +121,16 @@ func (c *AcmeCerts) AddDomains(domains []string) {
 	}
 }
 
+// FindLogRing ...
+func (g *Global) FindLogRing(name string) *LogRing {
+	for _, ring := range g.LogRings {
+		if ring.Name == name {
+			return ring
+		}
+	}
+	return nil
+}
+
 // IsExternal ...
 func (e *ExternalConfig) IsExternal() bool {
 	return e.MasterSocket != ""
@@ -219,8 +219,8 @@ func hasMatch(path *HostPath, match []MatchType) bool {
 }
 
 // AddPath ...
-func (h *Host) AddPath(backend *Backend, path string, match MatchType) {
-	h.addPath(path, match, backend, "")
+func (h *Host) AddPath(backend *Backend, path string, match MatchType) *HostPath {
+	return h.addPath(path, match, backend, "")
 }
 
 // AddRedirect ...
@@ -233,7 +233,7 @@ type hostResolver struct {
 	crtHash       *string
 }
 
-func (h *Host) addPath(path string, match MatchType, backend *Backend, redirTo string) {
+func (h *Host) addPath(path string, match MatchType, backend *Backend, redirTo string) *HostPath {
 	link := CreatePathLink(h.Hostname, path, match)
 	var hback HostBackend
 	if backend != nil {
@@ -252,14 +252,15 @@ func (h *Host) addPath(path string, match MatchType, backend *Backend, redirTo s
 	} else if redirTo == "" {
 		hback = HostBackend{ID: "_error404"}
 	}
-	h.Paths = append(h.Paths, &HostPath{
+	hpath := &HostPath{
 		Path:    path,
 		Link:    link,
 		Match:   match,
 		Backend: hback,
 		RedirTo: redirTo,
 		order:   len(h.Paths),
-	})
+	}
+	h.Paths = append(h.Paths, hpath)
 	// reverse order in order to avoid overlap of sub-paths
 	sort.Slice(h.Paths, func(i, j int) bool {
 		p1 := h.Paths[i]
@@ -269,6 +270,7 @@ func (h *Host) addPath(path string, match MatchType, backend *Backend, redirTo s
 		}
 		return p1.Path > p2.Path
 	})
+	return hpath
 }
 
 // RemovePath ...
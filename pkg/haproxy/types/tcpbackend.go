@@ -79,6 +79,14 @@ func (b *TCPBackends) Commit() {
 	b.itemsDel = map[int]*TCPBackend{}
 }
 
+// Remove ...
+func (b *TCPBackends) Remove(port int) {
+	if item, found := b.items[port]; found {
+		b.itemsDel[port] = item
+		delete(b.items, port)
+	}
+}
+
 // RemoveAll ...
 func (b *TCPBackends) RemoveAll() {
 	for port, item := range b.items {
@@ -59,20 +59,31 @@ type Global struct {
 	SSL                     SSLConfig
 	DNS                     DNSConfig
 	ModSecurity             ModSecurityConfig
+	Coraza                  ModSecurityConfig
+	SPOE                    SPOEConfig
 	Cookie                  CookieConfig
 	DrainSupport            DrainConfig
 	Acme                    Acme
 	ForwardFor              string
+	GeoIP                   GeoIPConfig
 	LoadServerState         bool
+	LogForward              []*LogForward
+	UDPServices             []*UDPService
+	LogRings                []*LogRing
+	LuaScripts              []*LuaScript
 	AdminSocket             string
+	HandoffSocket           string
 	External                ExternalConfig
 	Healthz                 HealthzConfig
+	HTTP3                   HTTP3Config
 	Master                  MasterConfig
 	MatchOrder              []MatchType
+	Peers                   PeersConfig
 	Prometheus              PromConfig
 	Security                SecurityConfig
 	Stats                   StatsConfig
 	StrictHost              bool
+	Tracing                 TracingConfig
 	UseHTX                  bool
 	DefaultBackendRedir     string
 	DefaultBackendRedirCode int
@@ -84,6 +95,25 @@ type Global struct {
 	CustomTCP               []string
 }
 
+// LuaScript is a custom Lua script, and the actions and sample fetches
+// it registers.
+type LuaScript struct {
+	Name     string
+	Content  string
+	Filename string
+	Actions  []string
+	Fetches  []string
+}
+
+// GeoIPConfig ...
+type GeoIPConfig struct {
+	CountryHeader string
+	Database      string
+	Format        string
+	MapFile       string
+	Version       string
+}
+
 // GlobalBindConfig ...
 type GlobalBindConfig struct {
 	AcceptProxy      bool
@@ -95,6 +125,53 @@ type GlobalBindConfig struct {
 	FrontingUseProto bool
 }
 
+// TracingConfig ...
+type TracingConfig struct {
+	TraceContext   bool
+	UniqueIDFormat string
+	UniqueIDHeader string
+}
+
+// HTTP3Config ...
+type HTTP3Config struct {
+	Enabled            bool
+	Bind               string
+	AltSvcMaxAge       int
+	AltSvcPort         int
+	ConnTxBuffersLimit int
+	MaxIdleTimeout     string
+	MaxStreamsBidi     int
+}
+
+// LogForward ...
+type LogForward struct {
+	Name       string
+	DgramBinds []string
+	Binds      []string
+	Targets    []string
+}
+
+// UDPService ...
+type UDPService struct {
+	Name       string
+	Port       int
+	DgramBinds []string
+	Targets    []string
+}
+
+// LogRing ...
+type LogRing struct {
+	Name    string
+	Servers []*LogRingServer
+	Size    int
+}
+
+// LogRingServer ...
+type LogRingServer struct {
+	Name     string
+	Endpoint string
+}
+
 // ProcsConfig ...
 type ProcsConfig struct {
 	Nbproc          int
@@ -124,6 +201,7 @@ type TimeoutConfig struct {
 	BackendTimeoutConfig
 	Client    string
 	ClientFin string
+	Grace     string
 	Stop      string
 }
 
@@ -170,15 +248,50 @@ type DNSNameserver struct {
 	Endpoint string
 }
 
+// PeersConfig ...
+type PeersConfig struct {
+	LocalPeer string
+	Port      int
+	Servers   []*PeersServer
+}
+
+// PeersServer ...
+type PeersServer struct {
+	Name     string
+	Endpoint string
+}
+
 // ModSecurityConfig ...
 type ModSecurityConfig struct {
 	Endpoints []string
 	Timeout   ModSecurityTimeoutConfig
 }
 
+// SPOEConfig ...
+type SPOEConfig struct {
+	Agents         []*SPOEAgent
+	FrontendAgents []string
+	Timeout        ModSecurityTimeoutConfig
+}
+
+// SPOEAgent is a Stream Processing Offload Agent, the external service
+// that receives the messages and whose backend is named after the agent.
+type SPOEAgent struct {
+	Name      string
+	Endpoints []string
+	Messages  []*SPOEMessage
+}
+
+// SPOEMessage ...
+type SPOEMessage struct {
+	Name  string
+	Event string
+	Args  []string
+}
+
 // CookieConfig ...
 type CookieConfig struct {
-	Key string
+	Key string `json:"-"`
 }
 
 // DrainConfig ...
@@ -202,6 +315,7 @@ type HealthzConfig struct {
 // MasterConfig ...
 type MasterConfig struct {
 	ExitOnFailure    bool
+	MaxOldWorkers    int
 	WorkerMaxReloads int
 }
 
@@ -209,6 +323,7 @@ type MasterConfig struct {
 type PromConfig struct {
 	BindIP string
 	Port   int
+	Scopes []string
 }
 
 // SecurityConfig ...
@@ -221,7 +336,7 @@ type SecurityConfig struct {
 // StatsConfig ...
 type StatsConfig struct {
 	AcceptProxy bool
-	Auth        string
+	Auth        string `json:"-"`
 	BindIP      string
 	Port        int
 	TLSFilename string
@@ -291,7 +406,9 @@ type TCPBackend struct {
 	Name          string
 	Port          int
 	Endpoints     []*TCPEndpoint
+	AllowList     []string
 	CheckInterval string
+	CheckRules    []*TCPCheckRule
 	SSL           TCPSSL
 	ProxyProt     TCPProxyProt
 }
@@ -367,8 +484,10 @@ type FrontendMaps struct {
 	RedirFromRootMap  *HostsMap
 	RedirFromMap      *HostsMap
 	RedirToMap        *HostsMap
+	RedirToTypeMap    *HostsMap
 	SSLPassthroughMap *HostsMap
 	VarNamespaceMap   *HostsMap
+	OIDCMap           *HostsMap
 	//
 	TLSAuthList           *HostsMap
 	TLSNeedCrtList        *HostsMap
@@ -376,6 +495,15 @@ type FrontendMaps struct {
 	TLSMissingCrtPagesMap *HostsMap
 	//
 	DefaultHostMap *HostsMap
+	//
+	RedirToTypes []RedirectTo
+}
+
+// RedirectTo is a redirect type and status code, other than the
+// frontend's default, used by one or more redirect-to paths.
+type RedirectTo struct {
+	Type RedirectType
+	Code int
 }
 
 // AuthProxy ...
@@ -410,6 +538,24 @@ type Frontend struct {
 	//
 	RedirectFromCode int
 	RedirectToCode   int
+	//
+	Extras []*ExtraFrontend
+}
+
+// ExtraFrontend is an additional pair of HTTP and HTTPS frontends,
+// with its own binds and default certificate, which serves the hosts
+// assigned to it
+type ExtraFrontend struct {
+	Name           string
+	HTTPBind       string
+	HTTPSBind      string
+	DefaultCrtFile string
+	DefaultCrtHash string
+	CrtListFile    string
+	Maps           *FrontendMaps
+	//
+	SyslogEndpoint string
+	HTTPLogFormat  string
 }
 
 // DefaultHost ...
@@ -435,7 +581,9 @@ type Host struct {
 	//
 	Alias                  HostAliasConfig
 	Redirect               HostRedirectConfig
+	Frontend               string
 	HTTPPassthroughBackend string
+	OIDC                   HostOIDCConfig
 	RootRedirect           string
 	TLS                    HostTLSConfig
 	VarNamespace           bool
@@ -475,14 +623,34 @@ type PathLink struct {
 // declared, the default backend will be used. If the default backend is
 // empty, a default 404 page generated by HAProxy will be used.
 type HostPath struct {
-	order   int
-	Path    string
-	Link    PathLink
-	Match   MatchType
-	Backend HostBackend
-	RedirTo string
+	order       int
+	Path        string
+	Link        PathLink
+	Match       MatchType
+	Backend     HostBackend
+	RedirTo     string
+	RedirToCode int
+	RedirToType RedirectType
+	Source      PathSource
+}
+
+// PathSource is the resource, e.g. an Ingress or an HTTPRoute,
+// that declared the path
+type PathSource struct {
+	Namespace string
+	Name      string
 }
 
+// RedirectType ...
+type RedirectType string
+
+// ...
+const (
+	RedirectLocation = RedirectType("location")
+	RedirectPrefix   = RedirectType("prefix")
+	RedirectScheme   = RedirectType("scheme")
+)
+
 // HostBackend ...
 type HostBackend struct {
 	ID        string
@@ -504,6 +672,17 @@ type HostRedirectConfig struct {
 	RedirectHostRegex string
 }
 
+// HostOIDCConfig ...
+type HostOIDCConfig struct {
+	AuthBackendName string
+	CallbackPath    string
+	ClientID        string
+	ClientSecret    string `json:"-"`
+	CookieSecret    string `json:"-"` // base64 encoded
+	Issuer          string
+	Scopes          string
+}
+
 // HostTLSConfig ...
 type HostTLSConfig struct {
 	TLSConfig
@@ -575,28 +754,64 @@ type Backend struct {
 	//
 	// per backend config
 	//
+	ABTest           ABTestConfig
 	AgentCheck       AgentCheck
+	AllBackups       bool
 	AllowedIPTCP     AccessConfig
 	BalanceAlgorithm string
 	BlueGreen        BlueGreenConfig
+	Compression      Compression
 	Cookie           Cookie
 	CustomConfig     []string
 	DeniedIPTCP      AccessConfig
 	Dynamic          DynBackendConfig
 	EpCookieStrategy EndpointCookieStrategy
+	ErrorPages       []*ErrorPage
+	ExternalName     string
+	FastCGI          FastCGIApp
+	GRPCWeb          bool
+	HashBalance      HashBalanceConfig
 	Headers          []*BackendHeader
 	HealthCheck      HealthCheck
 	Limit            BackendLimit
 	ModeTCP          bool
 	Resolver         string
+	Retry            BackendRetry
+	Routes           []*BackendRoute
 	Server           ServerConfig
+	SourceLists      []*SourceList
+	SPOEAgents       []string
+	Stick            StickConfig
 	Timeout          BackendTimeoutConfig
 	TLS              BackendTLSConfig
+	WAFRuleset       string
+}
+
+// Cache is the configuration of a HAProxy cache section, whose name is
+// derived from its sizes and max age, so paths with the same config share
+// the same cache. Cache is disabled if TotalMaxSize is zero.
+type Cache struct {
+	MaxAge        int
+	MaxObjectSize int64
+	StatusHeader  string
+	TotalMaxSize  int
+}
+
+// Compression ...
+type Compression struct {
+	Algos   []string
+	MinSize int64
+	Types   []string
 }
 
 // Endpoint ...
 type Endpoint struct {
+	ABTestRange string
+	Backup      bool
+	Canary      bool
+	Cluster     string
 	Enabled     bool
+	ForZones    string
 	Label       string
 	IP          string
 	Name        string
@@ -605,15 +820,32 @@ type Endpoint struct {
 	Target      string
 	TargetRef   string
 	Weight      int
+	Zone        string
 	CookieValue string
 }
 
+// ABTestConfig ...
+type ABTestConfig struct {
+	Buckets int
+	Fetch   string
+}
+
 // BlueGreenConfig ...
 type BlueGreenConfig struct {
+	Canary     BlueGreenCanary
 	CookieName string
 	HeaderName string
 }
 
+// BlueGreenCanary ...
+type BlueGreenCanary struct {
+	CookieName  string
+	CookieValue string
+	HeaderName  string
+	HeaderValue string
+	Regex       bool
+}
+
 // BackendPathConfig ...
 type BackendPathConfig struct {
 	items []*BackendPathItem
@@ -644,15 +876,73 @@ type BackendPath struct {
 	AllowedIPHTTP AccessConfig
 	AuthHTTP      AuthHTTP
 	AuthExternal  AuthExternal
+	BlockUA       BlockUserAgents
+	Cache         Cache
 	Cors          Cors
 	DeniedIPHTTP  AccessConfig
+	DenyMethods   DenyMethods
 	HSTS          HSTS
+	HTTPHeaders   HTTPHeaders
+	JWT           JWT
+	Lua           PathLua
+	Maintenance   Maintenance
 	MaxBodySize   int64
+	Mirror        Mirror
+	RateLimit     RateLimit
 	RewriteURL    string
+	SecHeaders    SecurityHeaders
 	SSLRedirect   bool
+	Timeout       PathTimeoutConfig
 	WAF           WAF
 }
 
+// PathLua ...
+type PathLua struct {
+	Actions []LuaAction
+	Headers []LuaHeader
+}
+
+// LuaAction is a custom Lua action, Phase is either http-request or
+// http-response.
+type LuaAction struct {
+	Phase string
+	Name  string
+	Args  []string
+}
+
+// LuaHeader is a request header whose value is a custom Lua sample fetch.
+type LuaHeader struct {
+	Name  string
+	Fetch string
+	Args  []string
+}
+
+// ErrorPage ...
+type ErrorPage struct {
+	Code     int
+	Content  string
+	Filename string
+}
+
+// HashBalanceConfig ...
+type HashBalanceConfig struct {
+	BalanceFactor int
+	Type          string
+}
+
+// FastCGIApp ...
+type FastCGIApp struct {
+	Docroot string
+	Index   string
+	Params  []*FastCGIParam
+}
+
+// FastCGIParam ...
+type FastCGIParam struct {
+	Name  string
+	Value string
+}
+
 // BackendHeader ...
 type BackendHeader struct {
 	Name  string
@@ -677,24 +967,49 @@ type DynBackendConfig struct {
 // HealthCheck ...
 type HealthCheck struct {
 	Addr      string
+	Expect    string
 	FallCount int
+	Headers   []*BackendHeader
+	Host      string
 	Interval  string
+	Method    string
 	Port      int
 	RiseCount int
+	TCPCheck  []*TCPCheckRule
+	Timeout   string
 	URI       string
 }
 
+// TCPCheckRule ...
+type TCPCheckRule struct {
+	Action string
+	Match  string
+	Value  string
+}
+
 // BackendLimit ...
 type BackendLimit struct {
 	Connections int
+	DenyStatus  int
+	Header      string
+	ReqRPS      int
 	RPS         int
 	Whitelist   []string
 }
 
 // AccessConfig ...
 type AccessConfig struct {
-	Rule      []string
-	Exception []string
+	Rule       []string
+	Exception  []string
+	Countries  []string
+	SourceList string
+}
+
+// SourceList ...
+type SourceList struct {
+	Name     string
+	Entries  []string
+	Filename string
 }
 
 // ServerConfig ...
@@ -707,17 +1022,63 @@ type ServerConfig struct {
 	CRLHash       string
 	CrtFilename   string
 	CrtHash       string
+	ErrorLimit    int
 	InitialWeight int
 	MaxConn       int
 	MaxQueue      int
+	Observe       string
+	OnError       string
 	Options       string
 	Protocol      string
+	ProxyV2       ProxyV2Config
 	Secure        bool
 	SendProxy     string
+	SlowStart     string
 	SNI           string
 	VerifyHost    string
 }
 
+// ProxyV2Config ...
+type ProxyV2Config struct {
+	Options []string
+	TLVs    []*ProxyV2TLV
+}
+
+// ProxyV2TLV ...
+type ProxyV2TLV struct {
+	ID     int
+	Format string
+}
+
+// BackendRetry ...
+type BackendRetry struct {
+	// Redispatch is `true`, `false` or empty if the defaults should be used
+	Redispatch         string
+	RedispatchInterval int
+	Retries            string
+	RetryOn            []string
+}
+
+// RouteMatchType is the request attribute used to choose an alternate backend.
+type RouteMatchType string
+
+// ...
+const (
+	RouteMatchHeader = RouteMatchType("header")
+	RouteMatchMethod = RouteMatchType("method")
+	RouteMatchQuery  = RouteMatchType("query")
+)
+
+// BackendRoute is an alternate backend that receives the requests of the
+// backend whose attribute, e.g. a header or a query parameter, matches Values.
+type BackendRoute struct {
+	Type    RouteMatchType
+	Name    string
+	Regex   bool
+	Values  []string
+	Backend string
+}
+
 // BackendTimeoutConfig ...
 type BackendTimeoutConfig struct {
 	Connect     string
@@ -729,6 +1090,12 @@ type BackendTimeoutConfig struct {
 	Tunnel      string
 }
 
+// PathTimeoutConfig ...
+type PathTimeoutConfig struct {
+	Server string
+	Tunnel string
+}
+
 // BackendTLSConfig ...
 type BackendTLSConfig struct {
 	AddCertHeader    bool
@@ -746,18 +1113,33 @@ type UserlistConfig struct {
 type Cookie struct {
 	Name     string
 	Dynamic  bool
+	Hash     bool
+	HTTPOnly bool
+	MaxAge   int
+	Path     string
 	Preserve bool
-	SameSite bool
+	SameSite string
+	Secure   bool
 	Shared   bool
 	Strategy string
 	Keywords string
 }
 
+// StickConfig ...
+type StickConfig struct {
+	Expire string
+	Match  string
+	Size   string
+	Store  string
+	Type   string
+}
+
 // AuthExternal ...
 type AuthExternal struct {
 	AllowedPath     string
 	AuthBackendName string
 	AuthPath        string
+	ForwardHeaders  bool
 	HeadersFail     []string
 	HeadersRequest  []string
 	HeadersSucceed  []string
@@ -766,10 +1148,24 @@ type AuthExternal struct {
 	RedirectOnFail  string
 }
 
+// Maintenance ...
+type Maintenance struct {
+	AllowList   []string
+	Enabled     bool
+	RedirectURL string
+}
+
+// Mirror ...
+type Mirror struct {
+	AuthBackendName string
+	Percentage      int
+}
+
 // AuthHTTP ...
 type AuthHTTP struct {
 	UserlistName string
 	Realm        string
+	Groups       []string
 }
 
 // Cors ...
@@ -792,6 +1188,76 @@ type HSTS struct {
 	Preload    bool
 }
 
+// SecurityHeaders are the security related response headers, an empty
+// value means that the header should not be added.
+type SecurityHeaders struct {
+	ContentSecurityPolicy string
+	ContentTypeOptions    string
+	FrameOptions          string
+	ReferrerPolicy        string
+}
+
+// HTTPHeaders ...
+type HTTPHeaders struct {
+	AfterResponseAdd []BackendHeader
+	AfterResponseDel []string
+	AfterResponseSet []BackendHeader
+	RequestAdd       []BackendHeader
+	RequestDel       []string
+	RequestSet       []BackendHeader
+	ResponseAdd      []BackendHeader
+	ResponseDel      []string
+	ResponseSet      []BackendHeader
+}
+
+// JWT ...
+type JWT struct {
+	Algorithm    string
+	Audience     string
+	ClaimHeaders []BackendHeader
+	Issuer       string
+	JWKS         []JWTKey
+	JWKSURL      string
+	KeyFilename  string
+	KeyHash      string
+}
+
+// JWTKey ...
+type JWTKey struct {
+	KID      string
+	Filename string
+	Hash     string
+}
+
+// BlockUserAgents lists the user-agent patterns whose requests should be
+// denied or tarpitted.
+type BlockUserAgents struct {
+	Action   string
+	Patterns []string
+}
+
+// DenyMethods lists the HTTP methods whose requests should be denied
+// with Status.
+type DenyMethods struct {
+	Methods []string
+	Status  int
+}
+
+// RateLimit is a named request rate limit, see the RateLimit custom
+// resource. All the paths referencing the same name share the counters of
+// the same stick table, declared once in the main cfg. Requests are counted
+// by the source IP, or by the value of Header if it is declared. RateLimit
+// is disabled if Name is empty.
+type RateLimit struct {
+	Name        string
+	Header      string
+	Requests    int
+	Period      string
+	Expire      string
+	DenyStatus  int
+	DenyHeaders []BackendHeader
+}
+
 // WAF Defines the WAF Config structure for the Backend
 type WAF struct {
 	// Mode defines On or DetectionOnly
@@ -807,13 +1273,20 @@ type Userlists struct {
 
 // Userlist ...
 type Userlist struct {
+	Name   string
+	Users  []User
+	Groups []UserGroup
+}
+
+// UserGroup ...
+type UserGroup struct {
 	Name  string
-	Users []User
+	Users []string
 }
 
 // User ...
 type User struct {
 	Name      string
-	Passwd    string
+	Passwd    string `json:"-"`
 	Encrypted bool
 }
@@ -32,14 +32,18 @@ func CreateUserlists() *Userlists {
 }
 
 // Replace ...
-func (u *Userlists) Replace(name string, users []User) *Userlist {
+func (u *Userlists) Replace(name string, users []User, groups []UserGroup) *Userlist {
 	userlist := &Userlist{
-		Name:  name,
-		Users: users,
+		Name:   name,
+		Users:  users,
+		Groups: groups,
 	}
 	sort.Slice(users, func(i, j int) bool {
 		return users[i].Name < users[j].Name
 	})
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
 	u.items[name] = userlist
 	u.itemsAdd[name] = userlist
 	return userlist
@@ -89,6 +93,16 @@ func (u *Userlists) Commit() {
 	u.itemsDel = map[string]*Userlist{}
 }
 
+// HasGroup ...
+func (u *Userlist) HasGroup(name string) bool {
+	for _, group := range u.Groups {
+		if group.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (u *Userlist) String() string {
 	return fmt.Sprintf("%+v", *u)
 }
@@ -19,6 +19,7 @@ package utils
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"strconv"
 	"strings"
@@ -176,3 +177,18 @@ func buildProcTable(procOutput string) *ProcTable {
 	}
 	return &procTable
 }
+
+// HAProxyPids reads the PIDs of the current haproxy processes from pidFile
+func HAProxyPids(pidFile string) ([]int, error) {
+	pidContent, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, pid := range strings.Fields(string(pidContent)) {
+		if p, err := strconv.Atoi(pid); err == nil {
+			pids = append(pids, p)
+		}
+	}
+	return pids, nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultElasticsearchIndex = "haproxy-ingress"
+
+// newElasticsearchShipper ships the records to the bulk API of
+// Elasticsearch, endpoint is the URL of the server followed by the index
+// or data stream name, e.g. http://elasticsearch:9200/haproxy-ingress
+func newElasticsearchShipper(endpoint string) (shipper, error) {
+	u, err := parseHTTPEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	index := strings.Trim(u.Path, "/")
+	if index == "" {
+		index = defaultElasticsearchIndex
+	} else if strings.Contains(index, "/") {
+		return nil, fmt.Errorf("invalid index name: %s", index)
+	}
+	u.Path = "/_bulk"
+	return &elasticsearchShipper{
+		url:    u.String(),
+		index:  index,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+type elasticsearchShipper struct {
+	url    string
+	index  string
+	client *http.Client
+}
+
+func (s *elasticsearchShipper) ship(ctx context.Context, records []*Record) error {
+	action, err := json.Marshal(map[string]interface{}{
+		// `create` works on both indices and data streams
+		"create": map[string]string{"_index": s.index},
+	})
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	for _, rec := range records {
+		doc, err := json.Marshal(rec.Document())
+		if err != nil {
+			return err
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(doc)
+		body.WriteByte('\n')
+	}
+	resBody, err := httpPost(ctx, s.client, s.url, "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return err
+	}
+	// the bulk API responds 200 even if some of the documents fail, which
+	// is reported in the `errors` field. The whole batch is retried, so
+	// documents might be duplicated.
+	var res struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(resBody, &res); err != nil {
+		return fmt.Errorf("error reading bulk response: %v", err)
+	}
+	if res.Errors {
+		for _, item := range res.Items {
+			for _, result := range item {
+				if result.Status < 200 || result.Status > 299 {
+					return fmt.Errorf("bulk request failed: %s: %s", result.Error.Type, result.Error.Reason)
+				}
+			}
+		}
+		return fmt.Errorf("bulk request failed")
+	}
+	return nil
+}
+
+func (s *elasticsearchShipper) close() error {
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logsink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// newKafkaShipper ships the records to a Kafka topic, endpoint is a comma
+// separated list of brokers followed by the topic name, e.g.
+// kafka-0:9092,kafka-1:9092/haproxy-ingress
+func newKafkaShipper(endpoint string) (shipper, error) {
+	pos := strings.LastIndex(endpoint, "/")
+	if pos <= 0 || pos == len(endpoint)-1 {
+		return nil, fmt.Errorf("endpoint should be <broker>[,<broker>...]/<topic>: %s", endpoint)
+	}
+	brokers := strings.Split(endpoint[:pos], ",")
+	for _, broker := range brokers {
+		if broker == "" {
+			return nil, fmt.Errorf("endpoint has an empty broker address: %s", endpoint)
+		}
+	}
+	return &kafkaShipper{
+		writer: &kafka.Writer{
+			Addr:     kafka.TCP(brokers...),
+			Topic:    endpoint[pos+1:],
+			Balancer: &kafka.LeastBytes{},
+			// batches are built by the sink, BatchSize and BatchTimeout
+			// only need to not split them further
+			BatchSize:    1 << 20,
+			BatchTimeout: 10 * time.Millisecond,
+			WriteTimeout: 30 * time.Second,
+			RequiredAcks: kafka.RequireOne,
+		},
+	}, nil
+}
+
+type kafkaShipper struct {
+	writer *kafka.Writer
+}
+
+func (s *kafkaShipper) ship(ctx context.Context, records []*Record) error {
+	msgs := make([]kafka.Message, len(records))
+	for i, rec := range records {
+		value, err := json.Marshal(rec.Document())
+		if err != nil {
+			return err
+		}
+		msgs[i] = kafka.Message{Value: value, Time: rec.Time}
+	}
+	return s.writer.WriteMessages(ctx, msgs...)
+}
+
+func (s *kafkaShipper) close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logsink
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// Options ...
+type Options struct {
+	Logger     types.Logger
+	Metrics    types.Metrics
+	Listen     string
+	Type       string
+	Endpoint   string
+	BatchSize  int
+	BatchWait  time.Duration
+	BufferSize int
+}
+
+// Sink receives the access logs sent by haproxy and ships them, in
+// batches, to a log store.
+//
+// Received logs are added to a bounded queue. Receivers stop reading
+// from the socket while the queue is full, so a log store that cannot
+// keep up makes haproxy buffer or drop logs, instead of making the
+// controller grow its memory usage. Batches that fail to ship are
+// retried until they succeed or the sink is shut down.
+type Sink interface {
+	Start() error
+	Shutdown()
+}
+
+// shipper sends a batch of records to a log store
+type shipper interface {
+	ship(ctx context.Context, records []*Record) error
+	close() error
+}
+
+// NewSink ...
+func NewSink(options Options) (Sink, error) {
+	if options.BatchSize <= 0 || options.BufferSize <= 0 || options.BatchWait <= 0 {
+		return nil, fmt.Errorf("batch size, batch wait and buffer size should be greater than zero")
+	}
+	rcv, err := newReceiver(options.Listen)
+	if err != nil {
+		return nil, err
+	}
+	var shp shipper
+	switch options.Type {
+	case "loki":
+		shp, err = newLokiShipper(options.Endpoint)
+	case "elasticsearch":
+		shp, err = newElasticsearchShipper(options.Endpoint)
+	case "kafka":
+		shp, err = newKafkaShipper(options.Endpoint)
+	default:
+		err = fmt.Errorf("unsupported log sink type: %s", options.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newSink(options, rcv, shp), nil
+}
+
+func newSink(options Options, rcv receiver, shp shipper) *sink {
+	return &sink{
+		logger:       options.Logger,
+		metrics:      options.Metrics,
+		receiver:     rcv,
+		shipper:      shp,
+		batchSize:    options.BatchSize,
+		batchWait:    options.BatchWait,
+		retryWait:    time.Second,
+		maxRetryWait: 30 * time.Second,
+		records:      make(chan *Record, options.BufferSize),
+		stopCh:       make(chan struct{}),
+		doneCh:       make(chan struct{}),
+	}
+}
+
+type sink struct {
+	logger       types.Logger
+	metrics      types.Metrics
+	receiver     receiver
+	shipper      shipper
+	batchSize    int
+	batchWait    time.Duration
+	retryWait    time.Duration
+	maxRetryWait time.Duration
+	records      chan *Record
+	stopCh       chan struct{}
+	doneCh       chan struct{}
+	stopOnce     sync.Once
+}
+
+func (s *sink) Start() error {
+	if err := s.receiver.listen(); err != nil {
+		return err
+	}
+	s.logger.Info("log sink listening on %s", s.receiver)
+	go s.receiver.serve(s.enqueue, s.logger)
+	go s.run()
+	return nil
+}
+
+// Shutdown stops receiving logs and makes a last attempt to ship the
+// pending ones. It should be called after haproxy is stopped.
+func (s *sink) Shutdown() {
+	s.stopOnce.Do(func() {
+		s.receiver.close()
+		close(s.stopCh)
+		<-s.doneCh
+		if err := s.shipper.close(); err != nil {
+			s.logger.Warn("error closing log sink: %v", err)
+		}
+	})
+}
+
+// enqueue adds a received log to the queue, blocking the receiver if the
+// queue is full. It returns false if the sink was shut down.
+func (s *sink) enqueue(rec *Record) bool {
+	select {
+	case s.records <- rec:
+		return true
+	case <-s.stopCh:
+		return false
+	}
+}
+
+func (s *sink) run() {
+	defer close(s.doneCh)
+	ticker := time.NewTicker(s.batchWait)
+	defer ticker.Stop()
+	batch := make([]*Record, 0, s.batchSize)
+	for {
+		select {
+		case rec := <-s.records:
+			batch = append(batch, rec)
+			if len(batch) >= s.batchSize {
+				s.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				s.flush(batch)
+				batch = batch[:0]
+			}
+		case <-s.stopCh:
+			s.drain(batch)
+			return
+		}
+	}
+}
+
+// flush ships a batch, retrying with an exponential backoff while it
+// fails. The queue is not consumed in the meantime.
+func (s *sink) flush(batch []*Record) {
+	wait := s.retryWait
+	for {
+		err := s.shipper.ship(context.Background(), batch)
+		if err == nil {
+			s.metrics.AddLogSinkRecords(len(batch))
+			return
+		}
+		s.metrics.IncLogSinkFailure()
+		s.logger.Warn("error shipping %d access log records, retrying in %s: %v", len(batch), wait, err)
+		select {
+		case <-time.After(wait):
+		case <-s.stopCh:
+			// shutting down, give it a last chance on drain()
+			s.drain(batch)
+			return
+		}
+		if wait *= 2; wait > s.maxRetryWait {
+			wait = s.maxRetryWait
+		}
+	}
+}
+
+// drain makes a single attempt to ship the batch and the records
+// remaining in the queue
+func (s *sink) drain(batch []*Record) {
+	for len(s.records) > 0 {
+		batch = append(batch, <-s.records)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for start := 0; start < len(batch); start += s.batchSize {
+		end := start + s.batchSize
+		if end > len(batch) {
+			end = len(batch)
+		}
+		if err := s.shipper.ship(ctx, batch[start:end]); err != nil {
+			s.metrics.IncLogSinkFailure()
+			s.logger.Error("error shipping access log records, %d records were lost: %v", len(batch)-start, err)
+			return
+		}
+		s.metrics.AddLogSinkRecords(end - start)
+	}
+}
@@ -0,0 +1,424 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logsink
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+type shipperMock struct {
+	mutex    sync.Mutex
+	failures int
+	batches  [][]string
+}
+
+func (s *shipperMock) ship(ctx context.Context, records []*Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return fmt.Errorf("connection refused")
+	}
+	batch := make([]string, len(records))
+	for i, rec := range records {
+		batch[i] = rec.Message
+	}
+	s.batches = append(s.batches, batch)
+	return nil
+}
+
+func (s *shipperMock) close() error {
+	return nil
+}
+
+func (s *shipperMock) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var count int
+	for _, batch := range s.batches {
+		count += len(batch)
+	}
+	return count
+}
+
+func setupSink(t *testing.T, rcv receiver, shp shipper, batchSize, bufferSize int, batchWait time.Duration) (*sink, *helper_test.LoggerMock) {
+	logger := helper_test.NewLoggerMock(t)
+	s := newSink(Options{
+		Logger:     logger,
+		Metrics:    helper_test.NewMetricsMock(),
+		BatchSize:  batchSize,
+		BatchWait:  batchWait,
+		BufferSize: bufferSize,
+	}, rcv, shp)
+	s.retryWait = time.Millisecond
+	return s, logger
+}
+
+func records(msgs ...string) []*Record {
+	recs := make([]*Record, len(msgs))
+	for i, msg := range msgs {
+		recs[i] = &Record{Message: msg}
+	}
+	return recs
+}
+
+func TestFlush(t *testing.T) {
+	shp := &shipperMock{failures: 2}
+	s, logger := setupSink(t, nil, shp, 10, 10, time.Second)
+	s.flush(records("l1", "l2"))
+	expected := [][]string{{"l1", "l2"}}
+	if !reflect.DeepEqual(shp.batches, expected) {
+		t.Errorf("batches differ - expected: %v, actual: %v", expected, shp.batches)
+	}
+	logger.CompareLogging(`
+WARN error shipping 2 access log records, retrying in 1ms: connection refused
+WARN error shipping 2 access log records, retrying in 2ms: connection refused`)
+}
+
+func TestShutdownDrain(t *testing.T) {
+	testCases := []struct {
+		failures   int
+		expected   [][]string
+		expLogging string
+	}{
+		// 0
+		{
+			expected: [][]string{{"l1", "l2"}, {"l3", "l4"}, {"l5"}},
+		},
+		// 1
+		{
+			failures:   10,
+			expLogging: "ERROR error shipping access log records, 5 records were lost: connection refused",
+		},
+	}
+	for i, test := range testCases {
+		shp := &shipperMock{failures: test.failures}
+		s, logger := setupSink(t, nil, shp, 2, 10, time.Hour)
+		s.maxRetryWait = time.Millisecond
+		for _, rec := range records("l1", "l2", "l3", "l4", "l5") {
+			s.enqueue(rec)
+		}
+		close(s.stopCh)
+		s.run()
+		if !reflect.DeepEqual(shp.batches, test.expected) {
+			t.Errorf("batches differ on %d - expected: %v, actual: %v", i, test.expected, shp.batches)
+		}
+		logging := logger.Logging
+		if len(logging) > 0 {
+			// retry warnings before shutdown depend on the order the records and the stop were read
+			logging = logging[len(logging)-1:]
+		}
+		if actual := strings.Join(logging, "\n"); actual != test.expLogging {
+			t.Errorf("logging differs on %d - expected: %s, actual: %s", i, test.expLogging, actual)
+		}
+	}
+}
+
+func TestUnixReceiver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logsink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "logsink.sock")
+	rcv, err := newReceiver("unix@" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shp := &shipperMock{}
+	s, logger := setupSink(t, rcv, shp, 10, 10, 10*time.Millisecond)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{"<134>Oct  5 11:58:01 haproxy[12]: l1", "<134>Oct  5 11:58:01 haproxy[12]: l2"} {
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.Close()
+	for start := time.Now(); shp.count() < 2 && time.Since(start) < 5*time.Second; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Shutdown()
+	var actual []string
+	for _, batch := range shp.batches {
+		actual = append(actual, batch...)
+	}
+	if expected := []string{"l1", "l2"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("records differ - expected: %v, actual: %v", expected, actual)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected socket file to be removed on shutdown")
+	}
+	logger.CompareLogging("INFO log sink listening on unix@" + path)
+}
+
+func TestReadFrame(t *testing.T) {
+	testCases := []struct {
+		stream   string
+		expected []string
+		expErr   string
+	}{
+		// 0
+		{
+			stream:   "5 <1>l14 <1>l",
+			expected: []string{"<1>l1", "<1>l"},
+		},
+		// 1
+		{
+			stream:   "<1>l1\n<1>l2\n<1>l3",
+			expected: []string{"<1>l1\n", "<1>l2\n", "<1>l3"},
+		},
+		// 2
+		{
+			stream:   "5 <1>l199999999 <1>l2",
+			expected: []string{"<1>l1"},
+			expErr:   "invalid message length: 99999999 ",
+		},
+		// 3
+		{
+			stream: "10 <1>l1",
+			expErr: "unexpected EOF",
+		},
+	}
+	for i, test := range testCases {
+		reader := bufio.NewReader(strings.NewReader(test.stream))
+		var actual []string
+		var errMsg string
+		for {
+			msg, err := readFrame(reader)
+			if err != nil {
+				if err.Error() != "EOF" {
+					errMsg = err.Error()
+				}
+				break
+			}
+			actual = append(actual, msg)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("messages differ on %d - expected: %q, actual: %q", i, test.expected, actual)
+		}
+		if errMsg != test.expErr {
+			t.Errorf("error differs on %d - expected: %s, actual: %s", i, test.expErr, errMsg)
+		}
+	}
+}
+
+func TestLokiShipper(t *testing.T) {
+	var path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		path, body = r.URL.Path, string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	shp, err := newLokiShipper(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Unix(1633435081, 0)
+	err = shp.ship(context.Background(), []*Record{
+		{Time: ts, Tag: "haproxy", Message: "l1"},
+		{Time: ts, Tag: "ingress", Hostname: "pod1", Message: "l2"},
+		{Time: ts, Tag: "haproxy", Message: "l3"},
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if path != "/loki/api/v1/push" {
+		t.Errorf("unexpected path: %s", path)
+	}
+	expected := `{"streams":[` +
+		`{"stream":{"job":"haproxy-ingress","tag":"haproxy"},"values":[["1633435081000000000","l1"],["1633435081000000000","l3"]]},` +
+		`{"stream":{"host":"pod1","job":"haproxy-ingress","tag":"ingress"},"values":[["1633435081000000000","l2"]]}]}`
+	if body != expected {
+		t.Errorf("body differs - expected: %s, actual: %s", expected, body)
+	}
+}
+
+func TestElasticsearchShipper(t *testing.T) {
+	testCases := []struct {
+		endpoint string
+		response string
+		status   int
+		expBody  string
+		expErr   string
+	}{
+		// 0
+		{
+			endpoint: "/",
+			response: `{"errors":false,"items":[{"create":{"status":201}}]}`,
+			status:   200,
+			expBody: `{"create":{"_index":"haproxy-ingress"}}
+{"@timestamp":"2021-10-05T11:58:01Z","hostname":"","message":"{\"status\":200}","status":200,"tag":"haproxy"}
+`,
+		},
+		// 1
+		{
+			endpoint: "/logs",
+			response: `{"errors":true,"items":[{"create":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [status]"}}}]}`,
+			status:   200,
+			expBody: `{"create":{"_index":"logs"}}
+{"@timestamp":"2021-10-05T11:58:01Z","hostname":"","message":"{\"status\":200}","status":200,"tag":"haproxy"}
+`,
+			expErr: "bulk request failed: mapper_parsing_exception: failed to parse field [status]",
+		},
+		// 2
+		{
+			endpoint: "/logs",
+			response: `{"error":"unauthorized"}`,
+			status:   401,
+			expBody: `{"create":{"_index":"logs"}}
+{"@timestamp":"2021-10-05T11:58:01Z","hostname":"","message":"{\"status\":200}","status":200,"tag":"haproxy"}
+`,
+			expErr: `unexpected status 401 Unauthorized: {"error":"unauthorized"}`,
+		},
+	}
+	for i, test := range testCases {
+		var path, body string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			path, body = r.URL.Path, string(b)
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.response))
+		}))
+		shp, err := newElasticsearchShipper(server.URL + test.endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = shp.ship(context.Background(), []*Record{{
+			Time:    time.Date(2021, 10, 5, 11, 58, 1, 0, time.UTC),
+			Tag:     "haproxy",
+			Message: `{"status":200}`,
+			Fields:  map[string]interface{}{"status": 200},
+		}})
+		server.Close()
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != test.expErr {
+			t.Errorf("error differs on %d - expected: %s, actual: %s", i, test.expErr, errMsg)
+		}
+		if path != "/_bulk" {
+			t.Errorf("unexpected path on %d: %s", i, path)
+		}
+		if body != test.expBody {
+			t.Errorf("body differs on %d - expected: %s, actual: %s", i, test.expBody, body)
+		}
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	testCases := []struct {
+		listen   string
+		typ      string
+		endpoint string
+		expErr   string
+	}{
+		// 0
+		{
+			listen:   "unix@/var/run/haproxy/logsink.sock",
+			typ:      "loki",
+			endpoint: "http://loki:3100",
+		},
+		// 1
+		{
+			listen:   "tcp@127.0.0.1:5140",
+			typ:      "kafka",
+			endpoint: "kafka-0:9092,kafka-1:9092/haproxy-ingress",
+		},
+		// 2
+		{
+			listen:   "udp@127.0.0.1:514",
+			typ:      "loki",
+			endpoint: "http://loki:3100",
+			expErr:   "unsupported listen protocol 'udp', should be unix or tcp",
+		},
+		// 3
+		{
+			listen:   "/var/run/haproxy/logsink.sock",
+			typ:      "loki",
+			endpoint: "http://loki:3100",
+			expErr:   "invalid listen address, should be unix@<path> or tcp@<host>:<port>: /var/run/haproxy/logsink.sock",
+		},
+		// 4
+		{
+			listen:   "unix@/var/run/haproxy/logsink.sock",
+			typ:      "elasticsearch",
+			endpoint: "elasticsearch:9200",
+			expErr:   "endpoint should be an http or https URL: elasticsearch:9200",
+		},
+		// 5
+		{
+			listen:   "unix@/var/run/haproxy/logsink.sock",
+			typ:      "elasticsearch",
+			endpoint: "http://elasticsearch:9200/logs/doc",
+			expErr:   "invalid index name: logs/doc",
+		},
+		// 6
+		{
+			listen:   "unix@/var/run/haproxy/logsink.sock",
+			typ:      "kafka",
+			endpoint: "kafka-0:9092",
+			expErr:   "endpoint should be <broker>[,<broker>...]/<topic>: kafka-0:9092",
+		},
+		// 7
+		{
+			listen:   "unix@/var/run/haproxy/logsink.sock",
+			typ:      "syslog",
+			endpoint: "syslog:514",
+			expErr:   "unsupported log sink type: syslog",
+		},
+	}
+	for i, test := range testCases {
+		_, err := NewSink(Options{
+			Listen:     test.listen,
+			Type:       test.typ,
+			Endpoint:   test.endpoint,
+			BatchSize:  10,
+			BatchWait:  time.Second,
+			BufferSize: 10,
+		})
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != test.expErr {
+			t.Errorf("error differs on %d - expected: %s, actual: %s", i, test.expErr, errMsg)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// newLokiShipper ships the records to the push API of Loki, endpoint is
+// the base URL of the Loki server, e.g. http://loki:3100
+func newLokiShipper(endpoint string) (shipper, error) {
+	u, err := parseHTTPEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/loki/api/v1/push"
+	return &lokiShipper{
+		url:    u.String(),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+type lokiShipper struct {
+	url    string
+	client *http.Client
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type lokiPush struct {
+	Streams []*lokiStream `json:"streams"`
+}
+
+// ship sends one stream per tag and hostname pair. Labels are kept to a
+// minimum, fields of JSON messages can be extracted by LogQL's json parser.
+func (s *lokiShipper) ship(ctx context.Context, records []*Record) error {
+	push := lokiPush{}
+	streams := map[[2]string]*lokiStream{}
+	for _, rec := range records {
+		key := [2]string{rec.Tag, rec.Hostname}
+		stream := streams[key]
+		if stream == nil {
+			labels := map[string]string{"job": "haproxy-ingress"}
+			if rec.Tag != "" {
+				labels["tag"] = rec.Tag
+			}
+			if rec.Hostname != "" {
+				labels["host"] = rec.Hostname
+			}
+			stream = &lokiStream{Stream: labels}
+			streams[key] = stream
+			push.Streams = append(push.Streams, stream)
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(rec.Time.UnixNano(), 10), rec.Message})
+	}
+	body, err := json.Marshal(&push)
+	if err != nil {
+		return err
+	}
+	_, err = httpPost(ctx, s.client, s.url, "application/json", body)
+	return err
+}
+
+func (s *lokiShipper) close() error {
+	return nil
+}
+
+func parseHTTPEndpoint(endpoint string) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("endpoint should be an http or https URL: %s", endpoint)
+	}
+	return u, nil
+}
+
+// httpPost sends body and returns the response body, failing if the
+// response status is not 2xx. Credentials in the URL, if any, are sent
+// by the http client as basic authentication.
+func httpPost(ctx context.Context, client *http.Client, url, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	resBody, err := ioutil.ReadAll(io.LimitReader(res.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %s: %s", res.Status, strings.TrimSpace(string(resBody)))
+	}
+	return resBody, nil
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logsink
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// maxMessageSize is the largest syslog message accepted, haproxy's
+// default is 1024 bytes, see the `len` parameter of the `log` keyword
+const maxMessageSize = 64 * 1024
+
+type receiver interface {
+	listen() error
+	serve(enqueue func(rec *Record) bool, logger types.Logger)
+	close()
+	String() string
+}
+
+// newReceiver parses the listen option, either `unix@<path>` for a unix
+// datagram socket, used by syslog-endpoint, or `tcp@<host>:<port>` for a
+// stream socket, used by the servers of a log-ring
+func newReceiver(listen string) (receiver, error) {
+	pos := strings.Index(listen, "@")
+	if pos < 0 || pos == len(listen)-1 {
+		return nil, fmt.Errorf("invalid listen address, should be unix@<path> or tcp@<host>:<port>: %s", listen)
+	}
+	proto, addr := listen[:pos], listen[pos+1:]
+	switch proto {
+	case "unix":
+		return &unixReceiver{path: addr}, nil
+	case "tcp":
+		return &tcpReceiver{addr: addr}, nil
+	}
+	return nil, fmt.Errorf("unsupported listen protocol '%s', should be unix or tcp", proto)
+}
+
+type unixReceiver struct {
+	path string
+	conn *net.UnixConn
+}
+
+func (r *unixReceiver) listen() error {
+	// removes the socket left behind by a former controller process
+	if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: r.path, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	r.conn = conn
+	return nil
+}
+
+// serve reads one syslog message per datagram. haproxy drops messages
+// while the socket buffer is full, which happens while the sink queue
+// is full and this loop is waiting on enqueue.
+func (r *unixReceiver) serve(enqueue func(rec *Record) bool, logger types.Logger) {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, _, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			if !isClosed(err) {
+				logger.Error("error reading log sink socket: %v", err)
+			}
+			return
+		}
+		if !enqueue(parseSyslog(string(buf[:n]), time.Now())) {
+			return
+		}
+	}
+}
+
+func (r *unixReceiver) close() {
+	if r.conn != nil {
+		r.conn.Close()
+		os.Remove(r.path)
+	}
+}
+
+func (r *unixReceiver) String() string {
+	return "unix@" + r.path
+}
+
+type tcpReceiver struct {
+	addr     string
+	listener net.Listener
+	mutex    sync.Mutex
+	conns    map[net.Conn]bool
+}
+
+func (r *tcpReceiver) listen() error {
+	listener, err := net.Listen("tcp", r.addr)
+	if err != nil {
+		return err
+	}
+	r.listener = listener
+	r.conns = map[net.Conn]bool{}
+	return nil
+}
+
+// serve reads the messages sent by the servers of a log-ring. haproxy
+// stops sending while the connection is stalled and buffers the logs in
+// the ring, dropping the oldest ones when the ring is full.
+func (r *tcpReceiver) serve(enqueue func(rec *Record) bool, logger types.Logger) {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			if !isClosed(err) {
+				logger.Error("error accepting log sink connection: %v", err)
+			}
+			return
+		}
+		r.mutex.Lock()
+		r.conns[conn] = true
+		r.mutex.Unlock()
+		go r.serveConn(conn, enqueue, logger)
+	}
+}
+
+func (r *tcpReceiver) serveConn(conn net.Conn, enqueue func(rec *Record) bool, logger types.Logger) {
+	defer func() {
+		r.mutex.Lock()
+		delete(r.conns, conn)
+		r.mutex.Unlock()
+		conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	for {
+		msg, err := readFrame(reader)
+		if err != nil {
+			if err != io.EOF && !isClosed(err) {
+				logger.Warn("error reading log sink connection from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if !enqueue(parseSyslog(msg, time.Now())) {
+			return
+		}
+	}
+}
+
+func (r *tcpReceiver) close() {
+	if r.listener != nil {
+		r.listener.Close()
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for conn := range r.conns {
+		conn.Close()
+	}
+}
+
+func (r *tcpReceiver) String() string {
+	return "tcp@" + r.addr
+}
+
+// readFrame reads a syslog message from a stream. Messages are framed
+// using octet counting, as sent by haproxy, or non transparent framing,
+// one message per line, see RFC 6587.
+func readFrame(reader *bufio.Reader) (string, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return "", err
+	}
+	if first[0] < '0' || first[0] > '9' {
+		msg, err := reader.ReadString('\n')
+		if err == io.EOF && msg != "" {
+			return msg, nil
+		}
+		return msg, err
+	}
+	size, err := reader.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	length, err := strconv.Atoi(strings.TrimSuffix(size, " "))
+	if err != nil || length <= 0 || length > maxMessageSize {
+		return "", fmt.Errorf("invalid message length: %s", size)
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func isClosed(err error) bool {
+	return strings.Contains(err.Error(), "use of closed network connection")
+}
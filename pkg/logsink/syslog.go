@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logsink
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Record is an access log message received from haproxy
+type Record struct {
+	Time     time.Time
+	Hostname string
+	Tag      string
+	Message  string
+	// Fields has the fields of a message in the JSON format,
+	// see the http-log-format configuration key
+	Fields map[string]interface{}
+}
+
+// Document is the structured form of the record, used by the
+// Elasticsearch and Kafka shippers. Fields of a JSON message are
+// added to the document, except the ones that have the same name
+// of the syslog fields.
+func (r *Record) Document() map[string]interface{} {
+	doc := make(map[string]interface{}, len(r.Fields)+4)
+	for k, v := range r.Fields {
+		doc[k] = v
+	}
+	doc["@timestamp"] = r.Time.UTC().Format(time.RFC3339Nano)
+	doc["hostname"] = r.Hostname
+	doc["tag"] = r.Tag
+	doc["message"] = r.Message
+	return doc
+}
+
+// parseSyslog parses a syslog message in one of the formats supported by
+// the syslog-format configuration key: rfc5424, rfc3164 or raw. now is
+// used if the message doesn't have a timestamp.
+func parseSyslog(msg string, now time.Time) *Record {
+	msg = strings.TrimRight(msg, "\r\n\x00")
+	rec := &Record{Time: now}
+	if strings.HasPrefix(msg, "<") {
+		if end := strings.IndexByte(msg, '>'); end > 0 && end <= 4 {
+			msg = msg[end+1:]
+			if strings.HasPrefix(msg, "1 ") {
+				parseRFC5424(rec, msg[2:])
+			} else {
+				parseRFC3164(rec, msg)
+			}
+		}
+	}
+	if rec.Message == "" {
+		rec.Message = msg
+	}
+	if strings.HasPrefix(rec.Message, "{") {
+		var fields map[string]interface{}
+		if json.Unmarshal([]byte(rec.Message), &fields) == nil {
+			rec.Fields = fields
+		}
+	}
+	return rec
+}
+
+// parseRFC5424 parses `TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG`
+func parseRFC5424(rec *Record, msg string) {
+	fields := strings.SplitN(msg, " ", 6)
+	if len(fields) < 6 {
+		return
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+		rec.Time = ts
+	}
+	rec.Hostname = nilValue(fields[1])
+	rec.Tag = nilValue(fields[2])
+	sd := fields[5]
+	if strings.HasPrefix(sd, "-") {
+		rec.Message = strings.TrimPrefix(sd[1:], " ")
+	} else if end := strings.Index(sd, "] "); strings.HasPrefix(sd, "[") && end > 0 {
+		rec.Message = sd[end+2:]
+	}
+}
+
+// parseRFC3164 parses `TIMESTAMP [HOSTNAME] TAG[PID]: MSG`, haproxy
+// only adds the hostname if log-send-hostname is configured
+func parseRFC3164(rec *Record, msg string) {
+	if len(msg) < len(time.Stamp)+1 {
+		return
+	}
+	if ts, err := time.ParseInLocation(time.Stamp, msg[:len(time.Stamp)], rec.Time.Location()); err == nil {
+		rec.Time = ts.AddDate(rec.Time.Year(), 0, 0)
+	}
+	msg = msg[len(time.Stamp)+1:]
+	fields := strings.SplitN(msg, " ", 3)
+	if len(fields) >= 2 && !isTag(fields[0]) {
+		rec.Hostname = fields[0]
+		msg = strings.Join(fields[1:], " ")
+	}
+	end := strings.Index(msg, ": ")
+	if end < 0 {
+		return
+	}
+	tag := msg[:end]
+	if pid := strings.IndexByte(tag, '['); pid >= 0 {
+		tag = tag[:pid]
+	}
+	rec.Tag = tag
+	rec.Message = msg[end+2:]
+}
+
+func isTag(field string) bool {
+	return strings.HasSuffix(field, ":") || strings.HasSuffix(field, "]:")
+}
+
+func nilValue(field string) string {
+	if field == "-" {
+		return ""
+	}
+	return field
+}
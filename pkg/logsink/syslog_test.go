@@ -0,0 +1,113 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logsink
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSyslog(t *testing.T) {
+	now := time.Date(2021, 10, 5, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		msg      string
+		expected *Record
+	}{
+		// 0
+		{
+			msg: "<134>1 2021-10-05T11:58:01.123Z pod1 haproxy 12 - - 10.0.0.1:4321 [05/Oct/2021:11:58:01.123] _front_http _default_backend/<NOSRV> 0/-1/-1/-1/0 404 0 - - LR-- 1/1/0/0/0 0/0 \"GET / HTTP/1.1\"\n",
+			expected: &Record{
+				Time:     time.Date(2021, 10, 5, 11, 58, 1, 123000000, time.UTC),
+				Hostname: "pod1",
+				Tag:      "haproxy",
+				Message:  "10.0.0.1:4321 [05/Oct/2021:11:58:01.123] _front_http _default_backend/<NOSRV> 0/-1/-1/-1/0 404 0 - - LR-- 1/1/0/0/0 0/0 \"GET / HTTP/1.1\"",
+			},
+		},
+		// 1
+		{
+			msg: "<134>1 2021-10-05T11:58:01Z - ingress - - [meta sequenceId=\"1\"] {\"status\":200,\"host\":\"d1.local\"}",
+			expected: &Record{
+				Time:    time.Date(2021, 10, 5, 11, 58, 1, 0, time.UTC),
+				Tag:     "ingress",
+				Message: "{\"status\":200,\"host\":\"d1.local\"}",
+				Fields:  map[string]interface{}{"status": float64(200), "host": "d1.local"},
+			},
+		},
+		// 2
+		{
+			msg: "<134>Oct  5 11:58:01 haproxy[12]: GET /app",
+			expected: &Record{
+				Time:    time.Date(2021, 10, 5, 11, 58, 1, 0, time.UTC),
+				Tag:     "haproxy",
+				Message: "GET /app",
+			},
+		},
+		// 3
+		{
+			msg: "<134>Oct  5 11:58:01 pod1 ingress: GET /app",
+			expected: &Record{
+				Time:     time.Date(2021, 10, 5, 11, 58, 1, 0, time.UTC),
+				Hostname: "pod1",
+				Tag:      "ingress",
+				Message:  "GET /app",
+			},
+		},
+		// 4
+		{
+			msg: "GET /app HTTP/1.1",
+			expected: &Record{
+				Time:    now,
+				Message: "GET /app HTTP/1.1",
+			},
+		},
+		// 5
+		{
+			msg: "{\"status\":503",
+			expected: &Record{
+				Time:    now,
+				Message: "{\"status\":503",
+			},
+		},
+	}
+	for i, test := range testCases {
+		actual := parseSyslog(test.msg, now)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("record differs on %d - expected: %+v, actual: %+v", i, test.expected, actual)
+		}
+	}
+}
+
+func TestDocument(t *testing.T) {
+	rec := &Record{
+		Time:     time.Date(2021, 10, 5, 11, 58, 1, 0, time.UTC),
+		Hostname: "pod1",
+		Tag:      "haproxy",
+		Message:  "{\"status\":200,\"tag\":\"other\"}",
+		Fields:   map[string]interface{}{"status": float64(200), "tag": "other"},
+	}
+	expected := map[string]interface{}{
+		"@timestamp": "2021-10-05T11:58:01Z",
+		"hostname":   "pod1",
+		"tag":        "haproxy",
+		"message":    "{\"status\":200,\"tag\":\"other\"}",
+		"status":     float64(200),
+	}
+	if actual := rec.Document(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("document differs - expected: %v, actual: %v", expected, actual)
+	}
+}
@@ -27,6 +27,12 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == controller.RenderCommand {
+		os.Exit(controller.Render(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == controller.FleetAgentCommand {
+		os.Exit(controller.FleetAgent(os.Args[2:]))
+	}
 	hc := controller.NewHAProxyController()
 	errCh := make(chan error)
 	go handleSignal(hc, errCh)
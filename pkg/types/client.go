@@ -19,10 +19,13 @@ package types
 import (
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
+
+	haclient "github.com/jcmoraisjr/haproxy-ingress/pkg/api/client"
 )
 
 // Client ...
 type Client interface {
 	kubernetes.Interface
 	versioned.Interface
+	haclient.Interface
 }
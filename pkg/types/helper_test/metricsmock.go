@@ -44,6 +44,10 @@ func (m *MetricsMock) HAProxySetServerResponseTime(duration time.Duration) {
 func (m *MetricsMock) HAProxySetSSLCertResponseTime(duration time.Duration) {
 }
 
+// HAProxySetACLResponseTime ...
+func (m *MetricsMock) HAProxySetACLResponseTime(duration time.Duration) {
+}
+
 // ControllerProcTime ...
 func (m *MetricsMock) ControllerProcTime(task string, duration time.Duration) {
 
@@ -65,10 +69,58 @@ func (m *MetricsMock) IncUpdateDynamic() {
 func (m *MetricsMock) IncUpdateFull() {
 }
 
+// IncReloadCause ...
+func (m *MetricsMock) IncReloadCause(reason, object string) {
+}
+
 // UpdateSuccessful ...
 func (m *MetricsMock) UpdateSuccessful(success bool) {
 }
 
+// SetDegraded ...
+func (m *MetricsMock) SetDegraded(degraded bool) {
+}
+
+// SetOldProcs ...
+func (m *MetricsMock) SetOldProcs(count int) {
+}
+
+// SetBackendEjections ...
+func (m *MetricsMock) SetBackendEjections(backend string, count int) {
+}
+
+// ClearBackendEjections ...
+func (m *MetricsMock) ClearBackendEjections() {
+}
+
+// SetBlockedUserAgents ...
+func (m *MetricsMock) SetBlockedUserAgents(pattern string, count int) {
+}
+
+// ClearBlockedUserAgents ...
+func (m *MetricsMock) ClearBlockedUserAgents() {
+}
+
+// AddHostTraffic ...
+func (m *MetricsMock) AddHostTraffic(hostname, namespace, name string, requests, errors int) {
+}
+
+// ObserveHostResponseTime ...
+func (m *MetricsMock) ObserveHostResponseTime(hostname, namespace, name string, duration time.Duration) {
+}
+
+// DeleteHostTraffic ...
+func (m *MetricsMock) DeleteHostTraffic(hostname, namespace, name string) {
+}
+
+// AddLogSinkRecords ...
+func (m *MetricsMock) AddLogSinkRecords(count int) {
+}
+
+// IncLogSinkFailure ...
+func (m *MetricsMock) IncLogSinkFailure() {
+}
+
 // SetCertExpireDate ...
 func (m *MetricsMock) SetCertExpireDate(domain, cn string, notAfter *time.Time) {
 }
@@ -24,3 +24,9 @@ type Logger interface {
 	Error(msg string, args ...interface{})
 	Fatal(msg string, args ...interface{})
 }
+
+// ObjectRef is implemented by log arguments that reference a Kubernetes
+// resource, the reference is added to the structured log lines.
+type ObjectRef interface {
+	ObjectRef() (kind, namespace, name string)
+}
@@ -25,12 +25,25 @@ type Metrics interface {
 	HAProxyShowInfoResponseTime(duration time.Duration)
 	HAProxySetServerResponseTime(duration time.Duration)
 	HAProxySetSSLCertResponseTime(duration time.Duration)
+	HAProxySetACLResponseTime(duration time.Duration)
 	ControllerProcTime(task string, duration time.Duration)
 	AddIdleFactor(idle int)
 	IncUpdateNoop()
 	IncUpdateDynamic()
 	IncUpdateFull()
+	IncReloadCause(reason, object string)
 	UpdateSuccessful(success bool)
+	SetDegraded(degraded bool)
+	SetOldProcs(count int)
+	SetBackendEjections(backend string, count int)
+	ClearBackendEjections()
+	SetBlockedUserAgents(pattern string, count int)
+	ClearBlockedUserAgents()
+	AddHostTraffic(hostname, namespace, name string, requests, errors int)
+	ObserveHostResponseTime(hostname, namespace, name string, duration time.Duration)
+	DeleteHostTraffic(hostname, namespace, name string)
+	AddLogSinkRecords(count int)
+	IncLogSinkFailure()
 	SetCertExpireDate(domain, cn string, notAfter *time.Time)
 	ClearCertExpire()
 	IncCertSigningMissing(domains string, success bool)
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// AvailableCPUs returns the number of CPUs the current container can use:
+// the CPU quota of its cgroup rounded up, limited to the CPUs the process
+// is allowed to run on.
+func AvailableCPUs() int {
+	return availableCPUs(cgroupRoot, runtime.NumCPU())
+}
+
+func availableCPUs(root string, numCPU int) int {
+	quota, period := readCPUQuota(root)
+	if quota > 0 && period > 0 {
+		if cpus := int((quota + period - 1) / period); cpus < numCPU {
+			return cpus
+		}
+	}
+	return numCPU
+}
+
+// readCPUQuota reads the CFS quota and period, in microseconds, of the
+// current cgroup. quota is zero or negative if the CPU usage isn't limited.
+func readCPUQuota(root string) (quota, period int64) {
+	// cgroup v2: "<quota> <period>", quota is "max" if not limited
+	if content, err := ioutil.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		fields := strings.Fields(string(content))
+		if len(fields) == 2 {
+			quota, _ = strconv.ParseInt(fields[0], 10, 64)
+			period, _ = strconv.ParseInt(fields[1], 10, 64)
+		}
+		return quota, period
+	}
+	// cgroup v1, quota is -1 if not limited
+	return readCgroupInt(filepath.Join(root, "cpu", "cpu.cfs_quota_us")),
+		readCgroupInt(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+}
+
+func readCgroupInt(filename string) int64 {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0
+	}
+	value, _ := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	return value
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAvailableCPUs(t *testing.T) {
+	testCases := []struct {
+		files    map[string]string
+		numCPU   int
+		expected int
+	}{
+		// 0
+		{
+			numCPU:   8,
+			expected: 8,
+		},
+		// 1
+		{
+			files:    map[string]string{"cpu.max": "max 100000\n"},
+			numCPU:   8,
+			expected: 8,
+		},
+		// 2
+		{
+			files:    map[string]string{"cpu.max": "200000 100000\n"},
+			numCPU:   8,
+			expected: 2,
+		},
+		// 3
+		{
+			files:    map[string]string{"cpu.max": "150000 100000\n"},
+			numCPU:   8,
+			expected: 2,
+		},
+		// 4
+		{
+			files:    map[string]string{"cpu.max": "50000 100000\n"},
+			numCPU:   8,
+			expected: 1,
+		},
+		// 5
+		{
+			files:    map[string]string{"cpu.max": "1600000 100000\n"},
+			numCPU:   8,
+			expected: 8,
+		},
+		// 6
+		{
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":  "-1\n",
+				"cpu/cpu.cfs_period_us": "100000\n",
+			},
+			numCPU:   4,
+			expected: 4,
+		},
+		// 7
+		{
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":  "300000\n",
+				"cpu/cpu.cfs_period_us": "100000\n",
+			},
+			numCPU:   4,
+			expected: 3,
+		},
+	}
+	for i, test := range testCases {
+		root, err := ioutil.TempDir("", "cgroup")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range test.files {
+			filename := filepath.Join(root, name)
+			if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if actual := availableCPUs(root, test.numCPU); actual != test.expected {
+			t.Errorf("available cpus differs on %d - expected: %d - actual: %d", i, test.expected, actual)
+		}
+		os.RemoveAll(root)
+	}
+}
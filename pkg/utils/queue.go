@@ -31,6 +31,7 @@ type Queue interface {
 	Notify()
 	Remove(item interface{})
 	Run()
+	SetRate(rate float32)
 	ShuttingDown() bool
 	ShutDown()
 }
@@ -39,6 +40,7 @@ type queue struct {
 	mutex       sync.Mutex
 	buildQueue  func() workqueue.RateLimitingInterface
 	workqueue   workqueue.RateLimitingInterface
+	rateMutex   sync.Mutex
 	rateLimiter flowcontrol.RateLimiter
 	running     chan struct{}
 	shutdown    chan bool
@@ -115,6 +117,25 @@ func (q *queue) Remove(item interface{}) {
 	q.forget[item] = empty{}
 }
 
+// SetRate changes the maximum number of syncs per second, starting in the
+// next sync. Zero means no rate limit.
+func (q *queue) SetRate(rate float32) {
+	q.rateMutex.Lock()
+	defer q.rateMutex.Unlock()
+	if rate > 0 {
+		q.rateLimiter = flowcontrol.NewTokenBucketRateLimiter(rate, 1)
+	} else {
+		q.rateLimiter = nil
+	}
+}
+
+func (q *queue) limiter() flowcontrol.RateLimiter {
+	// need a dedicated lock, ShutDown() holds the queue lock while Run() finishes
+	q.rateMutex.Lock()
+	defer q.rateMutex.Unlock()
+	return q.rateLimiter
+}
+
 func (q *queue) Run() {
 	if q.running != nil {
 		// queue already running
@@ -122,15 +143,16 @@ func (q *queue) Run() {
 	}
 	q.running = make(chan struct{})
 	for {
-		if q.rateLimiter != nil {
-			q.rateLimiter.Accept()
+		rateLimiter := q.limiter()
+		if rateLimiter != nil {
+			rateLimiter.Accept()
 		}
 		item, quit := q.workqueue.Get()
-		if q.rateLimiter != nil {
+		if rateLimiter != nil {
 			// waste a token if available, so Accept() can properly
 			// rate limit two consecutive calls after Get() blocks
 			// longer than the allowed rate
-			_ = q.rateLimiter.TryAccept()
+			_ = rateLimiter.TryAccept()
 		}
 		if quit {
 			if !<-q.shutdown {
@@ -165,6 +165,28 @@ func TestRateAccept(t *testing.T) {
 	check(1, 300*time.Millisecond)
 }
 
+func TestSetRate(t *testing.T) {
+	var items []string
+	q := NewQueue(func(item interface{}) {
+		items = append(items, fmt.Sprintf("%d=%s", item, time.Now().Format("15:04:05.000")))
+	})
+	q.SetRate(2)
+	go q.Run()
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		q.Add(i + 1)
+	}
+	time.Sleep(200 * time.Millisecond)
+	q.ShutDown()
+	duration := time.Now().Sub(start)
+	if len(items) != 4 {
+		t.Errorf("expected 4 items but sync was called %d time(s)", len(items))
+	}
+	if duration.Seconds() < 1 {
+		t.Errorf("expected time higher than 1s but was %s - timestamps: %v", duration.String(), items)
+	}
+}
+
 func TestNotify(t *testing.T) {
 	var items []interface{}
 	q := NewQueue(func(item interface{}) {
@@ -0,0 +1,243 @@
+-- Copyright 2021 The HAProxy Ingress Controller Authors.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- gRPC-Web to gRPC translation.
+--
+-- The gRPC-Web request of a browser is sent as a native gRPC request to
+-- the _front__grpc_web frontend, which uses the backend of the original
+-- request. The translated request is sent using HTTP/1.1, so haproxy
+-- converts the HTTP/2 trailers of the gRPC response, which have the status
+-- of the call, to chunked trailers. They are read here and sent to the
+-- browser in the trailer frame of the gRPC-Web response body. Lua filters
+-- cannot read the trailers of a response, so a filter cannot be used.
+
+local socket_addr = "unix@/var/run/haproxy/grpc-web.sock"
+
+-- the response can be a long running stream, the timeouts of the
+-- backend are applied by the frontend of the translated request
+local socket_timeout = 86400
+
+-- hop-by-hop, framing and translated headers, recreated
+-- when the request and the response are sent
+local skip_headers = {
+    ["connection"] = true,
+    ["content-length"] = true,
+    ["content-type"] = true,
+    ["keep-alive"] = true,
+    ["te"] = true,
+    ["trailer"] = true,
+    ["transfer-encoding"] = true,
+    ["upgrade"] = true,
+    ["x-haproxy-grpc-web-backend"] = true,
+    ["x-haproxy-grpc-web-src"] = true,
+}
+
+-- parse_content_type returns the suffix of a gRPC-Web content type, e.g.
+-- `+proto`, and if the messages are base64 encoded (grpc-web-text)
+local function parse_content_type(content_type)
+    local suffix = content_type:match("^application/grpc%-web%-text(.*)$")
+    if suffix ~= nil then
+        return suffix, true
+    end
+    return content_type:match("^application/grpc%-web(.*)$") or "", false
+end
+
+-- decode_text decodes a grpc-web-text body, which can be the concatenation
+-- of base64 encoded messages, each of them padded
+local function decode_text(applet, body)
+    local decoded = {}
+    for segment in body:gmatch("[^=]+=*") do
+        local data = applet.c:b64dec(segment)
+        if data == nil then
+            return nil
+        end
+        decoded[#decoded + 1] = data
+    end
+    return table.concat(decoded)
+end
+
+local function parse_header(line)
+    local name, value = line:match("^([^:]+):%s*(.-)%s*$")
+    if name == nil then
+        return nil
+    end
+    return name:lower(), value
+end
+
+local function build_request(applet, content_type, body)
+    local path = applet.path
+    if applet.qs ~= nil and applet.qs ~= "" then
+        path = path .. "?" .. applet.qs
+    end
+    local lines = { applet.method .. " " .. path .. " HTTP/1.1" }
+    for name, values in pairs(applet.headers) do
+        if not skip_headers[name] then
+            for _, value in pairs(values) do
+                lines[#lines + 1] = name .. ": " .. value
+            end
+        end
+    end
+    lines[#lines + 1] = "content-type: " .. content_type
+    lines[#lines + 1] = "content-length: " .. #body
+    lines[#lines + 1] = "te: trailers"
+    lines[#lines + 1] = "connection: close"
+    lines[#lines + 1] = "x-haproxy-grpc-web-backend: " .. applet.f:be_name()
+    lines[#lines + 1] = "x-haproxy-grpc-web-src: " .. applet.f:src()
+    return table.concat(lines, "\r\n") .. "\r\n\r\n" .. body
+end
+
+local function read_response(sock)
+    local line, err = sock:receive("*l")
+    if line == nil then
+        return nil, err
+    end
+    local status, reason = line:match("^HTTP/1%.%d (%d%d%d) ?(.*)$")
+    if status == nil then
+        return nil, "invalid status line: " .. line
+    end
+    local headers = {}
+    while true do
+        line, err = sock:receive("*l")
+        if line == nil then
+            return nil, err
+        end
+        if line == "" then
+            break
+        end
+        local name, value = parse_header(line)
+        if name ~= nil then
+            headers[#headers + 1] = { name, value }
+        end
+    end
+    return { status = tonumber(status), reason = reason, headers = headers }
+end
+
+local function send_status(applet, status)
+    applet:set_status(status)
+    applet:add_header("Content-Length", 0)
+    applet:start_response()
+end
+
+core.register_service("grpc-web", "http", function(applet)
+    local content_type = applet.headers["content-type"]
+    local suffix, text = parse_content_type(content_type and content_type[0] or "")
+    local body = applet:receive()
+    if text then
+        body = decode_text(applet, body)
+        if body == nil then
+            send_status(applet, 400)
+            return
+        end
+    end
+
+    local sock = core.tcp()
+    sock:settimeout(socket_timeout)
+    local ok, err = sock:connect(socket_addr)
+    if not ok then
+        core.Warning("Failure connecting to the gRPC-Web socket: " .. tostring(err))
+        send_status(applet, 503)
+        return
+    end
+    sock:send(build_request(applet, "application/grpc" .. suffix, body))
+    local response
+    response, err = read_response(sock)
+    if response == nil then
+        core.Warning("Failure reading the gRPC response: " .. tostring(err))
+        sock:close()
+        send_status(applet, 502)
+        return
+    end
+
+    local send = function(data)
+        if text then
+            data = applet.c:base64(data)
+        end
+        applet:send(data)
+    end
+    local chunked = false
+    local length
+    applet:set_status(response.status, response.reason)
+    for _, header in ipairs(response.headers) do
+        local name, value = header[1], header[2]
+        if name == "transfer-encoding" then
+            chunked = value:lower():find("chunked") ~= nil
+        elseif name == "content-length" then
+            length = tonumber(value)
+        elseif name == "content-type" and value:find("^application/grpc") then
+            local web = text and "application/grpc-web-text" or "application/grpc-web"
+            applet:add_header(name, web .. value:sub(#"application/grpc" + 1))
+        elseif name == "content-type" or not skip_headers[name] then
+            applet:add_header(name, value)
+        end
+    end
+    applet:start_response()
+
+    -- the gRPC messages have the same framing in both protocols,
+    -- only the trailers need to be converted
+    local trailers = {}
+    if chunked then
+        while true do
+            local line = sock:receive("*l")
+            local hex = line and line:match("^%x+")
+            local size = hex and tonumber(hex, 16)
+            if size == nil then
+                break
+            end
+            if size == 0 then
+                while true do
+                    line = sock:receive("*l")
+                    if line == nil or line == "" then
+                        break
+                    end
+                    local name, value = parse_header(line)
+                    if name ~= nil then
+                        trailers[#trailers + 1] = name .. ": " .. value .. "\r\n"
+                    end
+                end
+                break
+            end
+            local data = sock:receive(size)
+            if data == nil then
+                break
+            end
+            sock:receive("*l")
+            send(data)
+        end
+    elseif length ~= nil then
+        if length > 0 then
+            local data = sock:receive(length)
+            if data ~= nil then
+                send(data)
+            end
+        end
+    else
+        while true do
+            local data, _, partial = sock:receive(16384)
+            if data == nil then
+                if partial ~= nil and partial ~= "" then
+                    send(partial)
+                end
+                break
+            end
+            send(data)
+        end
+    end
+    sock:close()
+
+    -- trailers-only responses have the status in the headers instead
+    if #trailers > 0 then
+        local data = table.concat(trailers)
+        send("\128" .. string.pack(">I4", #data) .. data)
+    end
+end)
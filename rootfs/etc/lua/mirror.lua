@@ -0,0 +1,81 @@
+-- Copyright 2021 The HAProxy Ingress Controller Authors.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- Traffic mirroring, sends a copy of the request to a shadow backend.
+--
+-- The copy is sent by a background task, so the client request doesn't
+-- wait for the shadow backend, and the response of the shadow backend is
+-- discarded. The request body is only copied if it was already buffered,
+-- see `option http-buffer-request`.
+
+local http = require("haproxy-lua-http")
+
+-- hop-by-hop and framing headers, recreated by haproxy-lua-http
+local skip_headers = {
+    ["connection"] = true,
+    ["content-length"] = true,
+    ["keep-alive"] = true,
+    ["transfer-encoding"] = true,
+    ["upgrade"] = true,
+}
+
+local function backend_addr(be)
+    local backend = core.backends[be]
+    if backend == nil then
+        return nil
+    end
+    for _, server in pairs(backend.servers) do
+        local status = server:get_stats()["status"]
+        if status == "no check" or status:find("UP") == 1 then
+            return server:get_addr()
+        end
+    end
+    return nil
+end
+
+core.register_action("mirror", { "http-req" }, function(txn, be)
+    local addr = backend_addr(be)
+    if addr == nil then
+        txn:Warning("No servers available for mirror backend: '" .. be .. "'")
+        return
+    end
+    local headers = {}
+    for header, values in pairs(txn.http:req_get_headers()) do
+        if not skip_headers[header] then
+            for _, v in pairs(values) do
+                if headers[header] == nil then
+                    headers[header] = v
+                else
+                    headers[header] = headers[header] .. ", " .. v
+                end
+            end
+        end
+    end
+    local method = txn.sf:method():upper()
+    local url = "http://" .. addr .. txn.sf:pathq()
+    local data = txn.sf:req_body()
+    if data == "" then
+        data = nil
+    end
+    core.register_task(function()
+        local response, err = http.send(method, {
+            url = url,
+            headers = headers,
+            data = data,
+        })
+        if response == nil then
+            core.Warning("Failure in mirror backend '" .. be .. "': " .. err)
+        end
+    end)
+end, 1)
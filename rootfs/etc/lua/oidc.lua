@@ -0,0 +1,392 @@
+-- Copyright 2021 The HAProxy Ingress Controller Authors.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- OpenID Connect authentication, authorization code flow.
+--
+-- `req.oidc` has the configuration of the host, a space separated list of:
+-- auth backend name, issuer, client id, client secret, base64 encoded
+-- cookie secret, scopes and callback path.
+--
+-- The session cookie has the claims of the ID token signed with the cookie
+-- secret, so it is valid in all haproxy instances and survives reloads.
+-- Refresh tokens are kept in memory and are lost on reloads - a new
+-- authentication is made in the identity provider when the session expires
+-- and the refresh token isn't found.
+
+local http = require("haproxy-lua-http")
+local json = require("json")
+
+local session_cookie = "_oidc_session"
+local state_cookie = "_oidc_state"
+local discovery_ttl = 3600
+local state_ttl = 600
+local refresh_ttl = 86400
+
+-- discovery documents, indexed by issuer
+local providers = {}
+-- refresh tokens, indexed by session id
+local refresh_tokens = {}
+
+local function parse_config(txn)
+    local fields = {}
+    for field in (txn:get_var("req.oidc") or ""):gmatch("%S+") do
+        table.insert(fields, field)
+    end
+    if #fields ~= 7 then
+        return nil
+    end
+    return {
+        backend = fields[1],
+        issuer = fields[2],
+        client_id = fields[3],
+        client_secret = fields[4],
+        cookie_secret = fields[5],
+        scopes = fields[6],
+        callback = fields[7],
+    }
+end
+
+local function url_encode(s)
+    return (s:gsub("[^A-Za-z0-9_.~-]", function(c)
+        return string.format("%%%02X", string.byte(c))
+    end))
+end
+
+local function url_decode(s)
+    s = s:gsub("%+", " ")
+    return (s:gsub("%%(%x%x)", function(h)
+        return string.char(tonumber(h, 16))
+    end))
+end
+
+local function parse_query(query)
+    local params = {}
+    for pair in (query or ""):gmatch("[^&]+") do
+        local k, v = pair:match("^([^=]*)=?(.*)$")
+        params[url_decode(k)] = url_decode(v)
+    end
+    return params
+end
+
+local function b64url_encode(txn, s)
+    return http.base64.encode(s, function(v) return txn.c:base64(v) end)
+end
+
+local function b64url_decode(txn, s)
+    return http.base64.decode(s, function(v) return txn.c:b64dec(v) end)
+end
+
+local function sign(txn, cfg, payload)
+    return txn.c:hex(txn.c:hmac(payload, "sha256", cfg.cookie_secret))
+end
+
+-- encode_signed builds a cookie safe value with the content of a table
+-- and its signature.
+local function encode_signed(txn, cfg, data)
+    local payload = b64url_encode(txn, json.encode(data))
+    return payload .. "." .. sign(txn, cfg, payload)
+end
+
+-- decode_signed returns the table encoded by encode_signed, or nil if the
+-- value is missing or the signature doesn't match.
+local function decode_signed(txn, cfg, value)
+    if not value or value == "" then
+        return nil
+    end
+    local payload, signature = value:match("^([A-Za-z0-9_-]+)%.(%x+)$")
+    if not payload or sign(txn, cfg, payload) ~= signature then
+        return nil
+    end
+    local ok, data = pcall(json.decode, b64url_decode(txn, payload))
+    if not ok or type(data) ~= "table" then
+        return nil
+    end
+    return data
+end
+
+local function cookie(txn, name, value, max_age)
+    local attrs = "; Path=/; HttpOnly; SameSite=Lax"
+    if txn.sf:req_fhdr("x-forwarded-proto") == "https" then
+        attrs = attrs .. "; Secure"
+    end
+    if max_age then
+        attrs = attrs .. "; Max-Age=" .. max_age
+    end
+    return name .. "=" .. value .. attrs
+end
+
+local function base_url(txn)
+    return (txn.sf:req_fhdr("x-forwarded-proto") or "https") .. "://" .. txn.sf:req_fhdr("host")
+end
+
+local function backend_addr(be)
+    local backend = core.backends[be]
+    if backend == nil then
+        return nil
+    end
+    for _, server in pairs(backend.servers) do
+        local status = server:get_stats()["status"]
+        if status == "no check" or status:find("UP") == 1 then
+            return server:get_addr()
+        end
+    end
+    return nil
+end
+
+-- request sends a request to the identity provider, via the auth backend,
+-- and returns the decoded json response.
+local function request(cfg, method, path, headers, data)
+    local addr = backend_addr(cfg.backend)
+    if addr == nil then
+        return nil, "no servers available on backend '" .. cfg.backend .. "'"
+    end
+    local response, err = http.send(method, {
+        url = "http://" .. addr .. path,
+        headers = headers,
+        data = data,
+    })
+    if response == nil then
+        return nil, err
+    end
+    local ok, content = pcall(json.decode, response.content or "")
+    if response.status_code ~= 200 or not ok or type(content) ~= "table" then
+        return nil, "unexpected response from '" .. path .. "': HTTP " .. response.status_code
+    end
+    return content
+end
+
+local function issuer_path(cfg, url)
+    local prefix = cfg.issuer:match("^(https://[^/]+)")
+    if url:sub(1, #prefix) ~= prefix then
+        return nil
+    end
+    local path = url:sub(#prefix + 1)
+    if path == "" then
+        path = "/"
+    end
+    return path
+end
+
+local function discover(cfg)
+    local now = core.now().sec
+    local provider = providers[cfg.issuer]
+    if provider and provider.expire > now then
+        return provider
+    end
+    local path = issuer_path(cfg, cfg.issuer):gsub("/$", "") .. "/.well-known/openid-configuration"
+    local doc, err = request(cfg, "GET", path)
+    if doc == nil then
+        return nil, err
+    end
+    if not doc.authorization_endpoint or not doc.token_endpoint then
+        return nil, "missing authorization or token endpoint in the discovery document"
+    end
+    local token_path = issuer_path(cfg, doc.token_endpoint)
+    if token_path == nil then
+        return nil, "token endpoint is not in the same host of the issuer: " .. doc.token_endpoint
+    end
+    provider = {
+        authorization_endpoint = doc.authorization_endpoint,
+        token_path = token_path,
+        expire = now + discovery_ttl,
+    }
+    providers[cfg.issuer] = provider
+    return provider
+end
+
+-- token calls the token endpoint and returns the claims of the ID token.
+-- The ID token signature isn't verified: it was received straight from the
+-- token endpoint, whose certificate is validated by the auth backend.
+local function token(txn, cfg, params)
+    local provider, err = discover(cfg)
+    if provider == nil then
+        return nil, err
+    end
+    local form = {}
+    for k, v in pairs(params) do
+        table.insert(form, k .. "=" .. url_encode(v))
+    end
+    local credentials = url_encode(cfg.client_id) .. ":" .. url_encode(cfg.client_secret)
+    local response, err = request(cfg, "POST", provider.token_path, {
+        ["authorization"] = "Basic " .. txn.c:base64(credentials),
+        ["content-type"] = "application/x-www-form-urlencoded",
+    }, table.concat(form, "&"))
+    if response == nil then
+        return nil, err
+    end
+    local id_token = response.id_token or ""
+    local payload = id_token:match("^[^.]+%.([^.]+)%.")
+    local ok, claims = pcall(json.decode, b64url_decode(txn, payload or "") or "")
+    if not ok or type(claims) ~= "table" then
+        return nil, "invalid or missing ID token"
+    end
+    local aud = claims.aud
+    if type(aud) == "table" then
+        for _, a in ipairs(aud) do
+            if a == cfg.client_id then
+                aud = a
+            end
+        end
+    end
+    if claims.iss ~= cfg.issuer or aud ~= cfg.client_id or tonumber(claims.exp or 0) <= core.now().sec then
+        return nil, "ID token has an invalid issuer, audience or expiration"
+    end
+    return claims, response.refresh_token
+end
+
+-- new_session saves the refresh token and returns the session cookie.
+local function new_session(txn, cfg, claims, refresh_token, sid)
+    sid = sid or txn.f:uuid()
+    if refresh_token then
+        refresh_tokens[sid] = { token = refresh_token, expire = core.now().sec + refresh_ttl }
+    end
+    return encode_signed(txn, cfg, {
+        sid = sid,
+        exp = tonumber(claims.exp),
+        sub = claims.sub,
+        user = claims.preferred_username or claims.sub,
+        email = claims.email,
+    })
+end
+
+local function reply(txn, status, headers)
+    local r = txn:reply()
+    r:set_status(status)
+    for name, values in pairs(headers) do
+        for _, value in ipairs(values) do
+            r:add_header(name, value)
+        end
+    end
+    r:add_header("cache-control", "no-store")
+    txn:done(r)
+end
+
+local function login(txn, cfg)
+    local method = txn.sf:method()
+    if method ~= "GET" and method ~= "HEAD" then
+        reply(txn, 401, {})
+        return
+    end
+    local provider, err = discover(cfg)
+    if provider == nil then
+        txn:Warning("OIDC discovery of '" .. cfg.issuer .. "' failed: " .. err)
+        reply(txn, 503, {})
+        return
+    end
+    local state = txn.f:uuid()
+    local value = encode_signed(txn, cfg, {
+        state = state,
+        url = base_url(txn) .. txn.sf:pathq(),
+        exp = core.now().sec + state_ttl,
+    })
+    local sep = provider.authorization_endpoint:find("?", 1, true) and "&" or "?"
+    local location = provider.authorization_endpoint .. sep ..
+        "response_type=code" ..
+        "&client_id=" .. url_encode(cfg.client_id) ..
+        "&redirect_uri=" .. url_encode(base_url(txn) .. cfg.callback) ..
+        "&scope=" .. cfg.scopes ..
+        "&state=" .. state
+    reply(txn, 302, {
+        ["location"] = { location },
+        ["set-cookie"] = { cookie(txn, state_cookie, value, state_ttl) },
+    })
+end
+
+local function callback(txn, cfg)
+    local params = parse_query(txn.sf:query())
+    local state = decode_signed(txn, cfg, txn.sf:req_cook(state_cookie))
+    if params.error or not params.code or not state or state.state ~= params.state or state.exp <= core.now().sec then
+        reply(txn, 403, {})
+        return
+    end
+    local claims, result = token(txn, cfg, {
+        grant_type = "authorization_code",
+        code = params.code,
+        redirect_uri = base_url(txn) .. cfg.callback,
+    })
+    if claims == nil then
+        txn:Warning("OIDC authentication on '" .. cfg.issuer .. "' failed: " .. result)
+        reply(txn, 403, {})
+        return
+    end
+    -- only redirect to the same host
+    local location = state.url
+    if location:sub(1, #base_url(txn) + 1) ~= base_url(txn) .. "/" then
+        location = "/"
+    end
+    reply(txn, 302, {
+        ["location"] = { location },
+        ["set-cookie"] = {
+            cookie(txn, session_cookie, new_session(txn, cfg, claims, result)),
+            cookie(txn, state_cookie, "", 0),
+        },
+    })
+end
+
+local function refresh(txn, cfg, session)
+    local entry = refresh_tokens[session.sid]
+    if not entry then
+        return nil
+    end
+    refresh_tokens[session.sid] = nil
+    local claims, refresh_token = token(txn, cfg, {
+        grant_type = "refresh_token",
+        refresh_token = entry.token,
+    })
+    if claims == nil or claims.sub ~= session.sub then
+        return nil
+    end
+    local value = new_session(txn, cfg, claims, refresh_token or entry.token, session.sid)
+    txn:set_var("txn.oidc_set_cookie", cookie(txn, session_cookie, value))
+    return decode_signed(txn, cfg, value)
+end
+
+core.register_action("oidc-auth", { "http-req" }, function(txn)
+    local cfg = parse_config(txn)
+    if cfg == nil then
+        txn:Alert("invalid OIDC configuration")
+        reply(txn, 500, {})
+        return
+    end
+    if txn.sf:path() == cfg.callback then
+        callback(txn, cfg)
+        return
+    end
+    local session = decode_signed(txn, cfg, txn.sf:req_cook(session_cookie))
+    if session and tonumber(session.exp or 0) <= core.now().sec then
+        session = refresh(txn, cfg, session)
+    end
+    if not session then
+        login(txn, cfg)
+        return
+    end
+    txn.http:req_set_header("X-Auth-Request-User", session.user or session.sub)
+    if session.email then
+        txn.http:req_set_header("X-Auth-Request-Email", session.email)
+    else
+        txn.http:req_del_header("X-Auth-Request-Email")
+    end
+end, 0)
+
+core.register_task(function()
+    while true do
+        core.sleep(600)
+        local now = core.now().sec
+        for sid, entry in pairs(refresh_tokens) do
+            if entry.expire <= now then
+                refresh_tokens[sid] = nil
+            end
+        end
+    end
+end)
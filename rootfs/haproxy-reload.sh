@@ -17,7 +17,7 @@
 #
 # A script to help with haproxy reloads. Needs sudo if haproxy uses :80 / :443.
 #
-# ./haproxy-reload.sh <strategy> <cfg> [<need-state>]
+# ./haproxy-reload.sh <strategy> <cfg> [<handoff-socket> [<pid-file>]]
 #
 # <strategy>: `native`
 #    Uses native HAProxy soft restart. Running it for the first time starts
@@ -28,7 +28,15 @@
 #
 # <cfg>: configuration file or directory
 #
-# <need-state>: optional, defaults to `false`, anything != 0 means `true`
+# <handoff-socket>: optional, admin socket of the haproxy of a previous
+#    controller pod running on the same node. Used only on the first start,
+#    the listening sockets are taken over from that haproxy instead of being
+#    bound again, so no connection is refused or reset during the upgrade.
+#
+# <pid-file>: optional, where haproxy writes the PIDs of its current
+#    processes, defaults to /var/run/haproxy/haproxy.pid
+#
+# The server state file, if used, is saved by the controller.
 #
 # HAProxy options:
 #  -f config file
@@ -43,27 +51,22 @@ set -e
 
 PARAM_STRATEGY="$1"
 PARAM_CFG="$2"
-PARAM_STATE="${3:-0}"
+PARAM_HANDOFF="$3"
+PARAM_PIDFILE="$4"
 
 HAPROXY_SOCKET=/var/run/haproxy/admin.sock
-HAPROXY_STATE=/var/lib/haproxy/state-global
-HAPROXY_PID=/var/run/haproxy/haproxy.pid
+HAPROXY_PID="${PARAM_PIDFILE:-/var/run/haproxy/haproxy.pid}"
 OLD_PID=$(cat "$HAPROXY_PID" 2>/dev/null || :)
 
-# Only create the state file if the configuration need it
-if [ "$PARAM_STATE" != "0" ]; then
-    if [ -S "$HAPROXY_SOCKET" ]; then
-        echo "show servers state" | socat "$HAPROXY_SOCKET" - > /tmp/state && mv /tmp/state "$HAPROXY_STATE"
-    fi
-    if [ ! -s "$HAPROXY_STATE" ]; then
-        echo "#" > "$HAPROXY_STATE"
-    fi
-fi
-
 # Any strategy != `native` means `reusesocket` or `multibinder`
 # If there isn't a unix socket (eg first start) fallback to native
 if [ "$PARAM_STRATEGY" != "native" ] && [ -S "$HAPROXY_SOCKET" ]; then
     haproxy -f "$PARAM_CFG" -p "$HAPROXY_PID" -D -sf $OLD_PID -x "$HAPROXY_SOCKET"
+elif [ -z "$OLD_PID" ] && [ -n "$PARAM_HANDOFF" ] && [ -S "$PARAM_HANDOFF" ]; then
+    # First start, the previous haproxy might have already stopped, so fall
+    # back to binding the sockets if they couldn't be taken over
+    haproxy -f "$PARAM_CFG" -p "$HAPROXY_PID" -D -x "$PARAM_HANDOFF" || \
+        haproxy -f "$PARAM_CFG" -p "$HAPROXY_PID" -D
 else
     haproxy -f "$PARAM_CFG" -p "$HAPROXY_PID" -D -sf $OLD_PID
 fi
@@ -0,0 +1,318 @@
+-- Copyright 2021 The HAProxy Ingress Controller Authors.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- Tests of the gRPC-Web translation of grpc-web.lua. The haproxy's
+-- core, applet and socket APIs are mocked, so the service runs on a
+-- plain Lua interpreter, see `make test-lua`.
+
+local b64chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+local function b64enc(data)
+    local out = {}
+    for i = 1, #data, 3 do
+        local a, b, c = data:byte(i, i + 2)
+        local n = a * 65536 + (b or 0) * 256 + (c or 0)
+        local idx = {
+            math.floor(n / 262144) % 64,
+            math.floor(n / 4096) % 64,
+            math.floor(n / 64) % 64,
+            n % 64,
+        }
+        local chunk = ""
+        for j = 1, 4 do
+            chunk = chunk .. b64chars:sub(idx[j] + 1, idx[j] + 1)
+        end
+        if c == nil then
+            chunk = chunk:sub(1, 3) .. "="
+        end
+        if b == nil then
+            chunk = chunk:sub(1, 2) .. "=="
+        end
+        out[#out + 1] = chunk
+    end
+    return table.concat(out)
+end
+
+-- b64dec mimics the haproxy converter, which fails on invalid input
+local function b64dec(data)
+    if #data % 4 ~= 0 or data:find("[^%w%+/=]") or data:find("=[^=]") then
+        return nil
+    end
+    local out = {}
+    for i = 1, #data, 4 do
+        local n, pad = 0, 0
+        for j = i, i + 3 do
+            local ch = data:sub(j, j)
+            local v = 0
+            if ch == "=" then
+                pad = pad + 1
+            else
+                v = b64chars:find(ch, 1, true) - 1
+            end
+            n = n * 64 + v
+        end
+        local bytes = string.char(math.floor(n / 65536) % 256, math.floor(n / 256) % 256, n % 256)
+        out[#out + 1] = bytes:sub(1, 3 - pad)
+    end
+    return table.concat(out)
+end
+
+local function newSocket(response)
+    local sock = { response = response, pos = 1, sent = {}, closed = false }
+    function sock:settimeout(timeout) end
+    function sock:connect(addr)
+        if self.response == nil then
+            return nil, "connection refused"
+        end
+        self.addr = addr
+        return true
+    end
+    function sock:send(data)
+        self.sent[#self.sent + 1] = data
+    end
+    function sock:receive(pattern)
+        if self.pos > #self.response then
+            return nil, "closed", ""
+        end
+        if pattern == "*l" then
+            local i = self.response:find("\n", self.pos, true) or #self.response + 1
+            local line = self.response:sub(self.pos, i - 1):gsub("\r$", "")
+            self.pos = i + 1
+            return line
+        end
+        local data = self.response:sub(self.pos, self.pos + pattern - 1)
+        self.pos = self.pos + pattern
+        if #data < pattern then
+            return nil, "closed", data
+        end
+        return data
+    end
+    function sock:close()
+        self.closed = true
+    end
+    return sock
+end
+
+local function newApplet(contentType, body)
+    local applet = {
+        method = "POST",
+        path = "/echo.Echo/Say",
+        qs = "",
+        headers = {
+            ["host"] = { [0] = "echo.local" },
+            ["content-type"] = { [0] = contentType },
+            ["content-length"] = { [0] = tostring(#body) },
+            ["x-grpc-web"] = { [0] = "1" },
+        },
+        body = body,
+        resHeaders = {},
+        resBody = {},
+        f = {},
+        c = {},
+    }
+    function applet.f:be_name() return "default_echo_8080" end
+    function applet.f:src() return "10.0.0.1" end
+    function applet.c:b64dec(data) return b64dec(data) end
+    function applet.c:base64(data) return b64enc(data) end
+    function applet:receive() return self.body end
+    function applet:set_status(status, reason) self.status = status end
+    function applet:add_header(name, value) self.resHeaders[#self.resHeaders + 1] = name .. ": " .. value end
+    function applet:start_response() self.started = true end
+    function applet:send(data) self.resBody[#self.resBody + 1] = data end
+    return applet
+end
+
+local service
+local warnings = {}
+local sockets = {}
+core = {
+    register_service = function(name, mode, fn)
+        service = fn
+    end,
+    tcp = function()
+        return table.remove(sockets, 1)
+    end,
+    Warning = function(msg)
+        warnings[#warnings + 1] = msg
+    end,
+}
+-- string.pack is used by the service; this fallback allows to run
+-- the tests on interpreters older than Lua 5.3
+if string.pack == nil then
+    string.pack = function(fmt, n)
+        assert(fmt == ">I4")
+        return string.char(math.floor(n / 16777216) % 256, math.floor(n / 65536) % 256, math.floor(n / 256) % 256, n % 256)
+    end
+end
+dofile("rootfs/etc/lua/grpc-web.lua")
+
+local function frame(flag, data)
+    return string.char(flag) .. string.pack(">I4", #data) .. data
+end
+
+local function chunked(chunks, trailers)
+    local out = {}
+    for _, chunk in ipairs(chunks) do
+        out[#out + 1] = string.format("%x\r\n%s\r\n", #chunk, chunk)
+    end
+    out[#out + 1] = "0\r\n"
+    for _, trailer in ipairs(trailers) do
+        out[#out + 1] = trailer .. "\r\n"
+    end
+    out[#out + 1] = "\r\n"
+    return table.concat(out)
+end
+
+local function requestHeaders(request)
+    local headers = {}
+    local head = request:sub(1, request:find("\r\n\r\n", 1, true) - 1)
+    for line in head:gmatch("[^\r\n]+") do
+        local name, value = line:match("^([^:]+): (.*)$")
+        if name ~= nil then
+            headers[name] = value
+        end
+    end
+    return headers, request:sub(#head + 5)
+end
+
+local msg1 = frame(0, "\10\5hello")
+local msg2 = frame(0, "\10\6world!")
+local resMsg = frame(0, "\10\4pong")
+
+local testCases = {
+    -- 0 binary request, chunked response with trailers
+    {
+        contentType = "application/grpc-web+proto",
+        body = msg1,
+        response = "HTTP/1.1 200 OK\r\ncontent-type: application/grpc+proto\r\ntransfer-encoding: chunked\r\n\r\n" ..
+            chunked({ resMsg }, { "grpc-status: 0", "grpc-message: OK" }),
+        expReqContentType = "application/grpc+proto",
+        expReqBody = msg1,
+        expStatus = 200,
+        expHeaders = "content-type: application/grpc-web+proto",
+        expBody = resMsg .. frame(128, "grpc-status: 0\r\ngrpc-message: OK\r\n"),
+    },
+    -- 1 base64 request with two padded messages, base64 response
+    {
+        contentType = "application/grpc-web-text+proto",
+        body = b64enc(msg1) .. b64enc(msg2),
+        response = "HTTP/1.1 200 OK\r\ncontent-type: application/grpc+proto\r\ntransfer-encoding: chunked\r\n\r\n" ..
+            chunked({ resMsg }, { "Grpc-Status: 0" }),
+        expReqContentType = "application/grpc+proto",
+        expReqBody = msg1 .. msg2,
+        expStatus = 200,
+        expHeaders = "content-type: application/grpc-web-text+proto",
+        expBody = b64enc(resMsg) .. b64enc(frame(128, "grpc-status: 0\r\n")),
+    },
+    -- 2 response split in chunks, trailers with an error status
+    {
+        contentType = "application/grpc-web",
+        body = msg1,
+        response = "HTTP/1.1 200 OK\r\ncontent-type: application/grpc\r\ntransfer-encoding: chunked\r\n\r\n" ..
+            chunked({ resMsg:sub(1, 3), resMsg:sub(4) }, { "grpc-status: 5", "grpc-message: not found" }),
+        expReqContentType = "application/grpc",
+        expReqBody = msg1,
+        expStatus = 200,
+        expHeaders = "content-type: application/grpc-web",
+        expBody = resMsg:sub(1, 3) .. resMsg:sub(4) .. frame(128, "grpc-status: 5\r\ngrpc-message: not found\r\n"),
+    },
+    -- 3 trailers-only response, status in the headers and no trailer frame
+    {
+        contentType = "application/grpc-web+proto",
+        body = msg1,
+        response = "HTTP/1.1 200 OK\r\ncontent-type: application/grpc+proto\r\ngrpc-status: 12\r\ngrpc-message: unimplemented\r\ncontent-length: 0\r\n\r\n",
+        expReqContentType = "application/grpc+proto",
+        expReqBody = msg1,
+        expStatus = 200,
+        expHeaders = "content-type: application/grpc-web+proto|grpc-status: 12|grpc-message: unimplemented",
+        expBody = "",
+    },
+    -- 4 missing grpc-status, e.g. an error page of a proxy, is sent as is
+    {
+        contentType = "application/grpc-web+proto",
+        body = msg1,
+        response = "HTTP/1.1 503 Service Unavailable\r\ncontent-type: text/html\r\ncontent-length: 9\r\n\r\nno server",
+        expReqContentType = "application/grpc+proto",
+        expReqBody = msg1,
+        expStatus = 503,
+        expHeaders = "content-type: text/html",
+        expBody = "no server",
+    },
+    -- 5 missing grpc-status in an empty trailer section
+    {
+        contentType = "application/grpc-web+proto",
+        body = msg1,
+        response = "HTTP/1.1 200 OK\r\ncontent-type: application/grpc+proto\r\ntransfer-encoding: chunked\r\n\r\n" ..
+            chunked({ resMsg }, {}),
+        expReqContentType = "application/grpc+proto",
+        expReqBody = msg1,
+        expStatus = 200,
+        expHeaders = "content-type: application/grpc-web+proto",
+        expBody = resMsg,
+    },
+    -- 6 invalid base64 request
+    {
+        contentType = "application/grpc-web-text",
+        body = "not base64!",
+        expStatus = 400,
+        expHeaders = "Content-Length: 0",
+        expBody = "",
+    },
+    -- 7 gRPC socket unavailable
+    {
+        contentType = "application/grpc-web",
+        body = msg1,
+        expStatus = 503,
+        expHeaders = "Content-Length: 0",
+        expBody = "",
+        expWarning = "Failure connecting to the gRPC-Web socket: connection refused",
+    },
+}
+
+local failures = 0
+local function check(i, what, expected, actual)
+    if expected ~= actual then
+        failures = failures + 1
+        print(string.format("%s differs on %d - expected: %q - actual: %q", what, i, tostring(expected), tostring(actual)))
+    end
+end
+
+for i, test in ipairs(testCases) do
+    local idx = i - 1
+    warnings = {}
+    local sock = newSocket(test.response)
+    sockets = { sock }
+    local applet = newApplet(test.contentType, test.body)
+    service(applet)
+    check(idx, "status", test.expStatus, applet.status)
+    check(idx, "headers", test.expHeaders, table.concat(applet.resHeaders, "|"))
+    check(idx, "body", test.expBody, table.concat(applet.resBody))
+    check(idx, "warning", test.expWarning, warnings[1])
+    if test.expReqBody ~= nil then
+        local headers, body = requestHeaders(table.concat(sock.sent))
+        check(idx, "request content-type", test.expReqContentType, headers["content-type"])
+        check(idx, "request content-length", tostring(#test.expReqBody), headers["content-length"])
+        check(idx, "request te", "trailers", headers["te"])
+        check(idx, "request backend", "default_echo_8080", headers["x-haproxy-grpc-web-backend"])
+        check(idx, "request src", "10.0.0.1", headers["x-haproxy-grpc-web-src"])
+        check(idx, "request custom header", "1", headers["x-grpc-web"])
+        check(idx, "request body", test.expReqBody, body)
+        check(idx, "socket closed", true, sock.closed)
+    end
+end
+
+if failures > 0 then
+    os.exit(1)
+end
+print("ok")